@@ -0,0 +1,309 @@
+// Package accesslog provides a Flattener for the lines of web servers' access logs, so that they can be
+// matched against Quamina Patterns without first converting them to JSON. The format of the lines is given
+// as an nginx log_format specification, such as Combined:
+//
+//	$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"
+//
+// Each variable is a field named as the variable, without the "$", so the line
+//
+//	203.0.113.9 - - [10/Oct/2000:13:55:36 -0700] "GET /cart?item=7 HTTP/1.1" 404 153 "-" "curl/8.4.0"
+//
+// is flattened as if it were the Event
+//
+//	{
+//	  "remote_addr": "203.0.113.9", "time_local": "10/Oct/2000:13:55:36 -0700",
+//	  "request": "GET /cart?item=7 HTTP/1.1", "request_method": "GET", "uri": "/cart", "args": "item=7",
+//	  "server_protocol": "HTTP/1.1", "status": 404, "body_bytes_sent": 153, "http_user_agent": "curl/8.4.0"
+//	}
+//
+// and this Pattern matches client errors from curl:
+//
+//	{"status": [{"numeric": [">=", 400, "<", 500]}], "http_user_agent": [{"prefix": "curl/"}]}
+//
+// As the example shows, a variable whose value is "-", which is how nginx logs an empty value, is left out,
+// and $request is also split into the fields named for the nginx variables that hold its parts, unless the
+// format logs those variables itself. Variables that nginx logs as numbers, such as $status and
+// $request_time, are numbers; all others are strings. Values may use the escapes of nginx and Apache, such as
+// \x22 and \". Use the Flattener like this:
+//
+//	f, err := accesslog.NewFlattener(accesslog.Combined)
+//	q, err := quamina.New(quamina.WithFlattener(f))
+package accesslog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/internal/flatten"
+)
+
+// Formats of the lines of access logs, as log_format specifications.
+const (
+	// Common is the Common Log Format.
+	Common = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`
+	// Combined is the Combined Log Format, which is nginx's default.
+	Combined = Common + ` "$http_referer" "$http_user_agent"`
+)
+
+// numeric lists the variables that nginx logs as numbers
+var numeric = map[string]bool{
+	"body_bytes_sent":          true,
+	"bytes_sent":               true,
+	"connection":               true,
+	"connection_requests":      true,
+	"content_length":           true,
+	"msec":                     true,
+	"pid":                      true,
+	"remote_port":              true,
+	"request_length":           true,
+	"request_time":             true,
+	"server_port":              true,
+	"status":                   true,
+	"upstream_connect_time":    true,
+	"upstream_header_time":     true,
+	"upstream_response_length": true,
+	"upstream_response_time":   true,
+	"upstream_status":          true,
+}
+
+// the variables holding the parts of $request
+const (
+	requestMethod  = "request_method"
+	uri            = "uri"
+	args           = "args"
+	serverProtocol = "server_protocol"
+)
+
+// part of a log_format: literal text, or a variable
+type part struct {
+	literal  string
+	variable string
+}
+
+// Flattener flattens the lines of an access log. Like Quamina instances, a Flattener is not safe for
+// concurrent use; Copy makes one for another goroutine.
+type Flattener struct {
+	format       string
+	parts        []part
+	splitRequest bool
+	w            flatten.Writer
+	value        []byte
+}
+
+// NewFlattener returns a Flattener for lines in format, an nginx log_format specification, in which
+// variables are written $name or ${name}. It returns an error if the format has no variables or has two
+// variables with nothing between them, since there'd be no telling where one ended and the other began.
+// In a line, a variable's value runs to the next unescaped occurrence of the text that follows it in the
+// format, so a variable whose values may contain spaces, such as $upstream_response_time, which lists a
+// time for each upstream tried, should be quoted in the format, as $request is in Common.
+func NewFlattener(format string) (*Flattener, error) {
+	f := &Flattener{format: format, splitRequest: true}
+	var literal strings.Builder
+	hasVariables := false
+	for i := 0; i < len(format); {
+		if format[i] != '$' {
+			literal.WriteByte(format[i])
+			i++
+			continue
+		}
+		name, length := variableAt(format[i+1:])
+		if name == "" {
+			return nil, fmt.Errorf("log format has '$' without a variable name at %d", i)
+		}
+		if literal.Len() > 0 {
+			f.parts = append(f.parts, part{literal: literal.String()})
+			literal.Reset()
+		} else if hasVariables {
+			return nil, fmt.Errorf("log format has nothing between variables before $%s", name)
+		}
+		f.parts = append(f.parts, part{variable: name})
+		hasVariables = true
+		switch name {
+		case requestMethod, uri, args, serverProtocol:
+			f.splitRequest = false
+		}
+		i += 1 + length
+	}
+	if !hasVariables {
+		return nil, errors.New("log format has no variables")
+	}
+	if literal.Len() > 0 {
+		f.parts = append(f.parts, part{literal: literal.String()})
+	}
+	return f, nil
+}
+
+// variableAt returns the name of the variable at the start of s, which follows a '$', and the number of
+// bytes it occupies
+func variableAt(s string) (string, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 0 || !isName(s[1:end]) {
+			return "", 0
+		}
+		return s[1:end], end + 1
+	}
+	length := 0
+	for length < len(s) && isNameByte(s[length]) {
+		length++
+	}
+	return s[:length], length
+}
+
+func isName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isNameByte(s[i]) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Copy returns a new Flattener for the same format.
+func (f *Flattener) Copy() quamina.Flattener {
+	return &Flattener{format: f.format, parts: f.parts, splitRequest: f.splitRequest}
+}
+
+// Flatten parses the log line in event, which may end with a newline, returning an error if it doesn't
+// have the Flattener's format. The Fields returned are valid until the next call.
+func (f *Flattener) Flatten(event []byte, tracker quamina.SegmentsTreeTracker) ([]quamina.Field, error) {
+	f.w.Reset()
+	line := string(event)
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	pos := 0
+	for i, p := range f.parts {
+		if p.variable == "" {
+			if !strings.HasPrefix(line[pos:], p.literal) {
+				return nil, fmt.Errorf("log line at %d: expected %q", pos, p.literal)
+			}
+			pos += len(p.literal)
+			continue
+		}
+		// the value runs to the next unescaped occurrence of the literal that follows, or to the end
+		end := len(line)
+		if i+1 < len(f.parts) {
+			end = indexUnescaped(line, pos, f.parts[i+1].literal)
+			if end < 0 {
+				return nil, fmt.Errorf("log line at %d: $%s isn't followed by %q", pos, p.variable, f.parts[i+1].literal)
+			}
+		}
+		f.write(tracker, p.variable, line[pos:end])
+		pos = end
+	}
+	if pos != len(line) {
+		return nil, fmt.Errorf("log line at %d: unexpected text after the end of the format", pos)
+	}
+	return f.w.Fields(), nil
+}
+
+// indexUnescaped returns the index of the first occurrence of literal in line at or after pos, skipping
+// characters escaped with '\', or -1 if there is none
+func indexUnescaped(line string, pos int, literal string) int {
+	for i := pos; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(line[i:], literal) {
+			return i
+		}
+	}
+	return -1
+}
+
+// write writes the field for a variable, and those for the parts of $request
+func (f *Flattener) write(tracker quamina.SegmentsTreeTracker, name, raw string) {
+	if raw == "-" || raw == "" {
+		return
+	}
+	if !flatten.Used(tracker, name) && !(name == "request" && f.splitRequest) {
+		return
+	}
+	value := f.unescape(raw)
+	if numeric[name] && isNumber(value) {
+		f.w.Number(tracker, name, value)
+	} else {
+		f.w.String(tracker, name, value)
+	}
+	if name != "request" || !f.splitRequest {
+		return
+	}
+	method, rest, ok := strings.Cut(value, " ")
+	if !ok {
+		return
+	}
+	target, protocol, _ := strings.Cut(rest, " ")
+	path, query, hasQuery := strings.Cut(target, "?")
+	f.w.String(tracker, requestMethod, method)
+	f.w.String(tracker, uri, path)
+	if hasQuery {
+		f.w.String(tracker, args, query)
+	}
+	if protocol != "" {
+		f.w.String(tracker, serverProtocol, protocol)
+	}
+}
+
+// unescape decodes the escapes \xHH, which nginx writes, and \" and \\, which Apache writes; a '\' before
+// anything else is left alone
+func (f *Flattener) unescape(raw string) string {
+	if !strings.Contains(raw, `\`) {
+		return raw
+	}
+	f.value = f.value[:0]
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == '\\' && i+1 < len(raw) {
+			switch next := raw[i+1]; {
+			case next == '"' || next == '\\':
+				f.value = append(f.value, next)
+				i++
+				continue
+			case next == 'x' && i+3 < len(raw) && isHex(raw[i+2]) && isHex(raw[i+3]):
+				f.value = append(f.value, unhex(raw[i+2])<<4|unhex(raw[i+3]))
+				i += 3
+				continue
+			}
+		}
+		f.value = append(f.value, c)
+	}
+	return string(f.value)
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// isNumber reports whether s is a number as nginx logs them: digits, with perhaps a fractional part.
+// Other values of numeric variables, such as an upstream_response_time listing several upstreams, are
+// strings.
+func isNumber(s string) bool {
+	whole, fraction, hasFraction := strings.Cut(s, ".")
+	return digits(whole) && (!hasFraction || digits(fraction))
+}
+
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
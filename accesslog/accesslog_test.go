@@ -0,0 +1,131 @@
+package accesslog
+
+import (
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+const line = `203.0.113.9 - frank [10/Oct/2000:13:55:36 -0700] "GET /cart?item=7 HTTP/1.1" 404 153 "-" "curl/8.4.0"` + "\n"
+
+func matches(t *testing.T, format, pattern, line string) bool {
+	t.Helper()
+	f, err := NewFlattener(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := quamina.New(quamina.WithFlattener(f), quamina.WithEventBridgeCompat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("p", pattern); err != nil {
+		t.Fatalf("%s: %v", pattern, err)
+	}
+	xs, err := q.MatchesForEvent([]byte(line))
+	if err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+	return len(xs) == 1
+}
+
+func TestCombined(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"remote_addr": ["203.0.113.9"], "remote_user": ["frank"]}`, true},
+		{`{"time_local": ["10/Oct/2000:13:55:36 -0700"]}`, true},
+		{`{"request": ["GET /cart?item=7 HTTP/1.1"]}`, true},
+		{`{"request_method": ["GET"], "uri": ["/cart"], "args": ["item=7"], "server_protocol": ["HTTP/1.1"]}`, true},
+		{`{"status": [404], "body_bytes_sent": [153]}`, true},
+		{`{"status": ["404"]}`, false},
+		{`{"status": [{"numeric": [">=", 400, "<", 500]}], "http_user_agent": [{"prefix": "curl/"}]}`, true},
+		{`{"status": [{"numeric": [">=", 500]}]}`, false},
+		{`{"http_referer": [{"exists": false}]}`, true},
+	} {
+		if got := matches(t, Combined, test.pattern, line); got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+}
+
+func TestCommon(t *testing.T) {
+	common := `127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`
+	if !matches(t, Common, `{"uri": [{"suffix": ".gif"}], "remote_user": [{"exists": false}]}`, common) {
+		t.Error("missed")
+	}
+}
+
+func TestCustomFormat(t *testing.T) {
+	format := `${remote_addr}:$remote_port "$request" $status $request_time "$upstream_response_time" $uri`
+	custom := `10.0.0.1:51234 "POST /api?x=1 HTTP/2.0" 502 0.250 "0.120, 0.130" /api`
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"remote_addr": ["10.0.0.1"], "remote_port": [51234]}`, true},
+		{`{"request_time": [{"numeric": [">", 0.2]}]}`, true},
+		{`{"upstream_response_time": ["0.120, 0.130"]}`, true},
+		{`{"uri": ["/api"]}`, true},
+		// $uri is in the format, so $request isn't split
+		{`{"request_method": [{"exists": true}]}`, false},
+	} {
+		if got := matches(t, format, test.pattern, custom); got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	escaped := `1.2.3.4 - - [t] "GET /q\x22x\x22 HTTP/1.1" 200 0 "-" "Mozilla \"quoted\" \\ \d"`
+	for _, pattern := range []string{
+		`{"uri": ["/q\"x\""]}`,
+		`{"http_user_agent": ["Mozilla \"quoted\" \\ \\d"]}`,
+		`{"body_bytes_sent": [0]}`,
+	} {
+		if !matches(t, Combined, pattern, escaped) {
+			t.Errorf("%s missed", pattern)
+		}
+	}
+}
+
+func TestBadFormats(t *testing.T) {
+	for _, format := range []string{
+		``,
+		`no variables`,
+		`$a$b`,
+		`$a - $ - $b`,
+		`${a - $b`,
+		`${} $b`,
+	} {
+		if _, err := NewFlattener(format); err == nil {
+			t.Errorf("%q: no error", format)
+		}
+	}
+}
+
+func TestBadLines(t *testing.T) {
+	f, _ := NewFlattener(Combined)
+	q, _ := quamina.New(quamina.WithFlattener(f))
+	_ = q.AddPattern("p", `{"status": [200]}`)
+	for _, bad := range []string{
+		``,
+		`1.2.3.4 - - [t "GET / HTTP/1.1" 200 0 "-" "-"`,
+		`1.2.3.4 - - [t] "GET / HTTP/1.1" 200 0 "-" "-" extra`,
+		`1.2.3.4 - - [t] GET / HTTP/1.1 200 0 "-" "-"`,
+	} {
+		if _, err := q.MatchesForEvent([]byte(bad)); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	f, _ := NewFlattener(Combined)
+	q, _ := quamina.New(quamina.WithFlattener(f))
+	_ = q.AddPattern("p", `{"uri": ["/cart"]}`)
+	xs, err := q.Copy().MatchesForEvent([]byte(line))
+	if err != nil || len(xs) != 1 {
+		t.Errorf("copy matched %v, %v", xs, err)
+	}
+}
@@ -0,0 +1,176 @@
+package quamina
+
+// ahoCorasick is a classic Aho-Corasick automaton, used to find, in a single pass over a value, every
+// one of a set of literals that occurs anywhere in it. Quamina uses it for fields that carry lots of
+// "*literal*" shellstyle patterns; merging those into the NFA makes a large, heavily-spliced automaton
+// and running them one at a time makes matching cost linear in the number of patterns.
+//
+// The trie is stored as a slice of nodes addressed by int32 index, with node 0 as the root. Nodes
+// other than the root keep their out-edges as a sorted byte list, which is compact and, since most
+// trie nodes have only one or two children, as fast as anything. The root is consulted on nearly
+// every input byte, so it gets a dense table.
+//
+// Instances are immutable once built, which is what allows an AddPattern running in one goroutine to
+// replace the automaton while others are matching against the previous one.
+//
+// When the literals start with no more than three distinct bytes, which is common, scan skips over
+// runs of input that can't start a literal with indexByteSet3 rather than stepping through them.
+type ahoCorasick struct {
+	nodes     []acNode
+	rootTable [256]int32
+	canSkip   bool
+	skipBytes [3]byte
+}
+
+type acNode struct {
+	edgeBytes []byte  // sorted
+	edgeNext  []int32 // edgeNext[i] is the child reached on edgeBytes[i]
+	fail      int32   // the node for the longest proper suffix of this node's string that is in the trie
+	literal   int32   // index of the literal that ends exactly at this node, or -1
+	outLink   int32   // nearest node on the fail chain (excluding this one) with literal >= 0, or -1
+}
+
+// newAhoCorasick builds the automaton for the provided literals; the indices of the literals
+// in the argument slice are what scan reports. Empty literals are ignored.
+func newAhoCorasick(literals [][]byte) *ahoCorasick {
+	ac := &ahoCorasick{nodes: []acNode{{literal: -1, outLink: -1}}}
+	for i, literal := range literals {
+		if len(literal) == 0 {
+			continue
+		}
+		node := int32(0)
+		for _, b := range literal {
+			next := ac.child(node, b)
+			if next < 0 {
+				next = ac.addChild(node, b)
+			}
+			node = next
+		}
+		//nolint:gosec // literal counts are bounded far below MaxInt32 by memory
+		ac.nodes[node].literal = int32(i)
+	}
+
+	// breadth-first, so that the fail target of each node is complete before any of its children
+	// are processed
+	queue := make([]int32, 0, len(ac.nodes))
+	root := &ac.nodes[0]
+	for _, child := range root.edgeNext {
+		ac.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for i, b := range ac.nodes[node].edgeBytes {
+			child := ac.nodes[node].edgeNext[i]
+			fail := ac.nodes[node].fail
+			for {
+				next := ac.child(fail, b)
+				if next >= 0 {
+					ac.nodes[child].fail = next
+					break
+				}
+				if fail == 0 {
+					ac.nodes[child].fail = 0
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			failNode := &ac.nodes[ac.nodes[child].fail]
+			if failNode.literal >= 0 {
+				ac.nodes[child].outLink = ac.nodes[child].fail
+			} else {
+				ac.nodes[child].outLink = failNode.outLink
+			}
+			queue = append(queue, child)
+		}
+	}
+
+	for b := range ac.rootTable {
+		ac.rootTable[b] = max(ac.child(0, byte(b)), 0)
+	}
+	if first := ac.nodes[0].edgeBytes; len(first) > 0 && len(first) <= len(ac.skipBytes) {
+		ac.canSkip = true
+		for i := range ac.skipBytes {
+			ac.skipBytes[i] = first[min(i, len(first)-1)]
+		}
+	}
+	return ac
+}
+
+// child returns the trie child of node on b, or -1
+func (ac *ahoCorasick) child(node int32, b byte) int32 {
+	n := &ac.nodes[node]
+	for i, eb := range n.edgeBytes {
+		if eb == b {
+			return n.edgeNext[i]
+		}
+		if eb > b {
+			break
+		}
+	}
+	return -1
+}
+
+func (ac *ahoCorasick) addChild(node int32, b byte) int32 {
+	//nolint:gosec // trie size is bounded far below MaxInt32 by memory
+	next := int32(len(ac.nodes))
+	ac.nodes = append(ac.nodes, acNode{literal: -1, outLink: -1})
+	n := &ac.nodes[node]
+	i := 0
+	for i < len(n.edgeBytes) && n.edgeBytes[i] < b {
+		i++
+	}
+	n.edgeBytes = append(n.edgeBytes, 0)
+	copy(n.edgeBytes[i+1:], n.edgeBytes[i:])
+	n.edgeBytes[i] = b
+	n.edgeNext = append(n.edgeNext, 0)
+	copy(n.edgeNext[i+1:], n.edgeNext[i:])
+	n.edgeNext[i] = next
+	return next
+}
+
+// step returns the node reached from node on input byte b
+func (ac *ahoCorasick) step(node int32, b byte) int32 {
+	for node != 0 {
+		if next := ac.child(node, b); next >= 0 {
+			return next
+		}
+		node = ac.nodes[node].fail
+	}
+	return ac.rootTable[b]
+}
+
+// scan runs val through the automaton and calls report with the index of each literal found.
+// A literal that occurs more than once is reported more than once; callers dedupe. report
+// returns false to stop the scan early.
+func (ac *ahoCorasick) scan(val []byte, report func(literal int32) bool) {
+	node := int32(0)
+	for i := 0; i < len(val); i++ {
+		if node == 0 && ac.canSkip {
+			next := indexByteSet3(val[i:], ac.skipBytes[0], ac.skipBytes[1], ac.skipBytes[2])
+			if next < 0 {
+				return
+			}
+			i += next
+		}
+		node = ac.step(node, val[i])
+		for out := node; out >= 0; out = ac.nodes[out].outLink {
+			if lit := ac.nodes[out].literal; lit >= 0 {
+				if !report(lit) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// size estimates the bytes consumed by the automaton, for GetMatcherStats
+func (ac *ahoCorasick) size() int64 {
+	cost := int64(len(ac.rootTable)) * 4
+	for i := range ac.nodes {
+		n := &ac.nodes[i]
+		cost += mcAcNodeBase + int64(cap(n.edgeBytes)) + 4*int64(cap(n.edgeNext))
+	}
+	return cost
+}
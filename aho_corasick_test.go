@@ -0,0 +1,106 @@
+package quamina
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestAhoCorasick(t *testing.T) {
+	literals := [][]byte{[]byte("he"), []byte("she"), []byte("his"), []byte("hers"), []byte("s"), []byte("")}
+	ac := newAhoCorasick(literals)
+	var found []int32
+	ac.scan([]byte("ushers"), func(lit int32) bool {
+		found = append(found, lit)
+		return true
+	})
+	// "ushers" contains she, he, hers, and s twice
+	counts := make(map[int32]int)
+	for _, lit := range found {
+		counts[lit]++
+	}
+	wanted := map[int32]int{0: 1, 1: 1, 3: 1, 4: 2}
+	if len(counts) != len(wanted) {
+		t.Errorf("found %v", counts)
+	}
+	for lit, n := range wanted {
+		if counts[lit] != n {
+			t.Errorf("literal %s: wanted %d, got %d", literals[lit], n, counts[lit])
+		}
+	}
+
+	// early stop
+	calls := 0
+	ac.scan([]byte("ushers"), func(_ int32) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("scan didn't stop, %d calls", calls)
+	}
+}
+
+func TestAhoCorasickRandom(t *testing.T) {
+	alphabet := []byte("abc")
+	randBytes := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rand.Intn(len(alphabet))]
+		}
+		return b
+	}
+	for trial := 0; trial < 200; trial++ {
+		literals := make([][]byte, 1+rand.Intn(20))
+		for i := range literals {
+			literals[i] = randBytes(1 + rand.Intn(5))
+		}
+		ac := newAhoCorasick(literals)
+		val := randBytes(rand.Intn(40))
+		found := make(map[int32]bool)
+		ac.scan(val, func(lit int32) bool {
+			found[lit] = true
+			return true
+		})
+		for i, literal := range literals {
+			// duplicate literals: only the last one added is reported
+			if !bytes.Contains(val, literal) {
+				if found[int32(i)] {
+					t.Errorf("%s reported in %s", literal, val)
+				}
+				continue
+			}
+			reported := false
+			for j, other := range literals {
+				if bytes.Equal(other, literal) && found[int32(j)] {
+					reported = true
+				}
+			}
+			if !reported {
+				t.Errorf("%s not reported in %s", literal, val)
+			}
+		}
+	}
+}
+
+func TestAhoCorasickSkipping(t *testing.T) {
+	literals := [][]byte{[]byte("timeout"), []byte("refused"), []byte("reset"), []byte("tls")}
+	ac := newAhoCorasick(literals)
+	if !ac.canSkip {
+		t.Fatal("two distinct first bytes, should skip")
+	}
+	val := []byte(strings.Repeat("xxxxxxxx", 10) + "connection reset" + strings.Repeat("y", 33) + "timeou")
+	var found []int32
+	ac.scan(val, func(lit int32) bool {
+		found = append(found, lit)
+		return true
+	})
+	if len(found) != 1 || found[0] != 2 {
+		t.Errorf("found %v", found)
+	}
+
+	literals = append(literals, []byte("abort"), []byte("broken"))
+	if newAhoCorasick(literals).canSkip {
+		t.Error("four distinct first bytes, shouldn't skip")
+	}
+}
@@ -7,20 +7,51 @@ import (
 	"io"
 )
 
+// readAnythingButSpecial reads the value of an "anything-but" pattern, which is an array of strings, or an
+// object such as {"equals-ignore-case": ["abc", "def"]} giving strings that values must not equal, ignoring case.
+// With WithEventBridgeCompat, it may also be a single string or number, an array including numbers, or an object
+// whose one member gives the "prefix", "suffix", or "wildcard" pattern, or an array of them, that values must not
+// match.
 func readAnythingButSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
 	t, err := pb.jd.Token()
 	if err != nil {
 		return
 	}
 	pathVals = valsIn
-	fieldCount := 0
+	val := typedVal{vType: anythingButType}
+	switch tt := t.(type) {
+	case string:
+		if pb.eventBridge {
+			val.list = [][]byte{[]byte(`"` + tt + `"`)}
+			pathVals = append(pathVals, val)
+			_, err = pb.jd.Token()
+			return
+		}
+	case json.Number:
+		if pb.eventBridge {
+			val.excluded = []typedVal{{vType: numberType, val: tt.String()}}
+			pathVals = append(pathVals, val)
+			_, err = pb.jd.Token()
+			return
+		}
+	case json.Delim:
+		if tt == '{' {
+			if val.excluded, err = readAnythingButObject(pb); err != nil {
+				return
+			}
+			pathVals = append(pathVals, val)
+			_, err = pb.jd.Token()
+			return
+		}
+	}
 	delim, ok := t.(json.Delim)
 	if (!ok) || delim != '[' {
 		err = errors.New("value for anything-but must be an array")
 		return
 	}
+	fieldCount := 0
 	done := false
-	val := typedVal{vType: anythingButType}
+	var numbers bool
 	for !done {
 		t, err = pb.jd.Token()
 		if errors.Is(err, io.EOF) {
@@ -39,6 +70,16 @@ func readAnythingButSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typ
 		case string:
 			fieldCount++
 			val.list = append(val.list, []byte(`"`+tt+`"`))
+			val.excluded = append(val.excluded, typedVal{vType: stringType, val: `"` + tt + `"`})
+		case json.Number:
+			if !pb.eventBridge {
+				err = errors.New("malformed anything-but list")
+				done = true
+				break
+			}
+			fieldCount++
+			numbers = true
+			val.excluded = append(val.excluded, typedVal{vType: numberType, val: tt.String()})
 		default:
 			err = errors.New("malformed anything-but list")
 			done = true
@@ -51,6 +92,13 @@ func readAnythingButSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typ
 		err = errors.New("empty list in 'anything-but' pattern")
 		return
 	}
+
+	// lists of strings have a specialized automaton, see makeMultiAnythingButFA
+	if numbers {
+		val.list = nil
+	} else {
+		val.excluded = nil
+	}
 	pathVals = append(pathVals, val)
 
 	// this has to be a '}' or you're going to get an err from the tokenizer, so no point looking at the value
@@ -58,6 +106,75 @@ func readAnythingButSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typ
 	return
 }
 
+// readAnythingButObject reads the object in an anything-but pattern such as {"anything-but": {"prefix": "abc"}}
+// and returns the values it excludes
+func readAnythingButObject(pb *patternBuild) ([]typedVal, error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	patternType, _ := t.(string)
+	switch patternType {
+	case "equals-ignore-case":
+	case "prefix", "suffix", "wildcard":
+		if !pb.eventBridge {
+			return nil, fmt.Errorf("anything-but %s patterns are only supported by WithEventBridgeCompat", patternType)
+		}
+	default:
+		return nil, fmt.Errorf("anything-but can't be combined with %s", patternType)
+	}
+	var strs []string
+	t, err = pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tt := t.(type) {
+	case string:
+		strs = append(strs, tt)
+	case json.Delim:
+		if tt != '[' {
+			break
+		}
+		for {
+			if t, err = pb.jd.Token(); err != nil {
+				return nil, err
+			}
+			if t == json.Delim(']') {
+				break
+			}
+			str, ok := t.(string)
+			if !ok {
+				return nil, fmt.Errorf("anything-but %s list must contain only strings", patternType)
+			}
+			strs = append(strs, str)
+		}
+	}
+	if len(strs) == 0 {
+		return nil, fmt.Errorf("value for anything-but %s must be a string or a non-empty array of strings", patternType)
+	}
+
+	var excluded []typedVal
+	for _, str := range strs {
+		switch patternType {
+		case "prefix":
+			excluded = append(excluded, typedVal{vType: prefixType, val: `"` + str + `"`})
+		case "suffix":
+			excluded = append(excluded, typedVal{vType: wildcardType, val: `"*` + escapeWildcard(str) + `"`})
+		case "wildcard":
+			if err := checkWildcard(str); err != nil {
+				return nil, err
+			}
+			excluded = append(excluded, typedVal{vType: wildcardType, val: `"` + str + `"`})
+		case "equals-ignore-case":
+			excluded = append(excluded, typedVal{vType: monocaseType, val: `"` + str + `"`})
+		}
+	}
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return excluded, err
+}
+
 // makeMultiAnythingButDFA exists to handle constructs such as
 //
 // {"x": [ {"anything-but": [ "a", "b" ] } ] }
@@ -130,3 +247,77 @@ func makeOneMultiAnythingButStep(vals [][]byte, index int, success *faState) sma
 	table.pack(&u)
 	return table
 }
+
+// makeComplementFA builds the automaton for anything-but patterns other than lists of strings, which matches
+// the values that none of the excluded patterns match. It builds the automaton for the excluded patterns in
+// the usual way, makes it deterministic, and then complements it.
+func makeComplementFA(excluded []typedVal, pp printer) (*faState, *fieldMatcher) {
+	var positive *faState
+	for _, val := range excluded {
+		var fa *faState
+		valBytes := []byte(val.val)
+		switch val.vType {
+		case stringType, numberType:
+			t, _ := makeStringFA(valBytes, nil, val.vType == numberType)
+			fa = &faState{table: t}
+		case prefixType:
+			t, _ := makePrefixFA(valBytes)
+			fa = &faState{table: t}
+		case wildcardType:
+			fa, _ = makeWildCardFA(valBytes, pp)
+		case monocaseType:
+			fa, _ = makeMonocaseFA(valBytes, pp)
+		default:
+			panic("unknown value type in anything-but")
+		}
+		if positive == nil {
+			positive = fa
+		} else {
+			positive = mergeStartStates(positive, fa, pp)
+		}
+	}
+	epsilonClosure(positive)
+	nextField := newFieldMatcher()
+	return complementDFA(nfa2Dfa(positive), nextField), nextField
+}
+
+// complementDFA returns a deterministic automaton which transitions to nextField on exactly the values which
+// don't reach any fieldTransitions in dfa. Values that reach a state of dfa with fieldTransitions are done
+// for, and go to a state with no transitions at all; those for which dfa has no transition on some byte, or
+// which end without having matched, are successes.
+func complementDFA(dfa *faState, nextField *fieldMatcher) *faState {
+	success := &faState{table: newSmallTable(), fieldTransitions: []*fieldMatcher{nextField}}
+	failure := &faState{table: newSmallTable()}
+	complements := make(map[*faState]*faState)
+	var complement func(state *faState) *faState
+	complement = func(state *faState) *faState {
+		if len(state.fieldTransitions) > 0 {
+			return failure
+		}
+		if c, ok := complements[state]; ok {
+			return c
+		}
+		c := &faState{table: newSmallTable()}
+		complements[state] = c
+		u := unpackTable(&state.table)
+		var cu unpackedTable
+		for b, next := range u {
+			switch {
+			case next == nil:
+				cu[b] = success
+			case byte(b) == valueTerminator:
+				// nothing follows the valueTerminator, so the value has either matched by now or it never will
+				if len(next.fieldTransitions) > 0 {
+					cu[b] = failure
+				} else {
+					cu[b] = success
+				}
+			default:
+				cu[b] = complement(next)
+			}
+		}
+		c.table.pack(&cu)
+		return c
+	}
+	return complement(dfa)
+}
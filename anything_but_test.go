@@ -195,6 +195,8 @@ func TestParseAnythingButPattern(t *testing.T) {
 	goods := []string{
 		`{"a": [ {"anything-but": [ "foo" ] } ] }`,
 		`{"a": [ {"anything-but": [ "bif", "x", "y", "a;sldkfjas;lkdfjs" ] } ] }`,
+		`{"a": [ {"anything-but": {"equals-ignore-case": "foo"} } ] }`,
+		`{"a": [ {"anything-but": {"equals-ignore-case": [ "Internal", "Debug" ]} } ] }`,
 	}
 	bads := []string{
 		`{"a": [ {"anything-but": x } ] }`,
@@ -210,6 +212,9 @@ func TestParseAnythingButPattern(t *testing.T) {
 		`{"a": [ 2, {"anything-but": [ "foo" ] } ] }`,
 		`{"a": [ {"anything-but": [ "foo" ] }, 2 ] }`,
 		`{"a": [ {"anything-but": [ ] } ] }`,
+		`{"a": [ {"anything-but": {"prefix": "foo"} } ] }`,
+		`{"a": [ {"anything-but": {"equals-ignore-case": [ ]} } ] }`,
+		`{"a": [ {"anything-but": {"equals-ignore-case": "foo"}, "b" } ] }`,
 	}
 
 	for i, good := range goods {
@@ -229,3 +234,28 @@ func TestParseAnythingButPattern(t *testing.T) {
 		}
 	}
 }
+
+func TestAnythingButIgnoreCase(t *testing.T) {
+	q, _ := New()
+	if err := q.AddPattern("quiet", `{"level": [{"anything-but": {"equals-ignore-case": ["Internal", "Debug"]}}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("not-x", `{"id": [{"anything-but": {"equals-ignore-case": "x"}}]}`); err != nil {
+		t.Fatal(err)
+	}
+	for event, want := range map[string][]X{
+		`{"level": "internal"}`: {},
+		`{"level": "DEBUG"}`:    {},
+		`{"level": "DeBuG"}`:    {},
+		`{"level": "debugger"}`: {"quiet"},
+		`{"level": "Info"}`:     {"quiet"},
+		`{"level": ""}`:         {"quiet"},
+		`{"id": "X"}`:           {},
+		`{"id": "xx"}`:          {"not-x"},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package quamina
+
+import (
+	"errors"
+)
+
+// ErrArrayTooLong is returned, wrapped, when an Event has an array with more elements than the limit set
+// WithArrayLimit, and the ArrayLimitPolicy is ArrayLimitError.
+var ErrArrayTooLong = errors.New("array too long")
+
+// ArrayLimitPolicy says what happens to an array with more elements than the limit set WithArrayLimit.
+type ArrayLimitPolicy int
+
+const (
+	// ArrayLimitError makes matching the Event fail with an error wrapping ErrArrayTooLong.
+	ArrayLimitError ArrayLimitPolicy = iota
+	// ArrayLimitTruncate matches the Event as though the array ended at the limit.
+	ArrayLimitTruncate
+)
+
+// WithArrayLimit limits the number of elements of each array in an Event that the JSON Flattener reads. Each
+// element of an array that Patterns use becomes a field, or several, to be matched, and fields in different
+// arrays are matched in combination, so an Event carrying arrays of many thousands of elements can take far
+// longer to match than the Patterns warrant; an ordinary JSON parse would accept it. The policy says whether
+// such an Event fails or is matched with the elements up to the limit. Arrays that no Pattern uses are skipped
+// as usual, however long. The limit applies to instances created with Copy. It needs the JSON Flattener, so
+// New fails if WithFlattener provides another. This option call may not be provided more than once.
+func WithArrayLimit(elements int, policy ArrayLimitPolicy) Option {
+	return func(q *Quamina) error {
+		if q.arrayLimit != 0 {
+			return errors.New("array limit specified more than once")
+		}
+		if elements <= 0 {
+			return errors.New("array limit must be positive")
+		}
+		if policy != ArrayLimitError && policy != ArrayLimitTruncate {
+			return errors.New("unknown array limit policy")
+		}
+		q.arrayLimit = elements
+		q.arrayPolicy = policy
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package quamina
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestArrayLimit(t *testing.T) {
+	patterns := map[X]string{
+		"a3": `{"a": [3]}`,
+		"bx": `{"b": {"c": ["x"]}}`,
+		"d":  `{"d": ["z"]}`,
+	}
+	newQ := func(elements int, policy ArrayLimitPolicy) *Quamina {
+		t.Helper()
+		q, err := New(WithArrayLimit(elements, policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x, pattern := range patterns {
+			if err := q.AddPattern(x, pattern); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return q
+	}
+
+	truncating := newQ(2, ArrayLimitTruncate)
+	erring := newQ(2, ArrayLimitError)
+	tests := []struct {
+		event     string
+		truncated []X
+		tooLong   bool
+	}{
+		{`{"a": [1, 3], "d": "z"}`, []X{"a3", "d"}, false},
+		{`{"a": [1, 2, 3], "d": "z"}`, []X{"d"}, true},
+		{`{"a": [[1, 2, 3], [3]], "d": "z"}`, []X{"a3", "d"}, true},
+		{`{"b": [{"c": "y"}, {"c": ["y", "y", "x"]}, {"c": "x"}], "d": "z"}`, []X{"d"}, true},
+		{`{"a": [ 1 , "]" , [3, {"e": "]"}] ], "d": "z"}`, []X{"d"}, true},
+		// arrays that no Pattern uses are skipped, however long
+		{`{"e": [1, 2, 3, 4, 5], "d": "z"}`, []X{"d"}, false},
+		{`{"a": [], "d": "z"}`, []X{"d"}, false},
+	}
+	for _, test := range tests {
+		for _, q := range []*Quamina{truncating, truncating.Copy()} {
+			matches, err := q.MatchesForEvent([]byte(test.event))
+			if err != nil || !containsExactly(matches, test.truncated) {
+				t.Errorf("truncating %s: %v, %v", test.event, matches, err)
+			}
+		}
+		for _, q := range []*Quamina{erring, erring.Copy()} {
+			matches, err := q.MatchesForEvent([]byte(test.event))
+			if test.tooLong {
+				if !errors.Is(err, ErrArrayTooLong) {
+					t.Errorf("erring %s: %v, %v", test.event, matches, err)
+				}
+			} else if err != nil || !containsExactly(matches, test.truncated) {
+				t.Errorf("erring %s: %v, %v", test.event, matches, err)
+			}
+		}
+	}
+
+	if _, err := truncating.MatchesForEvent([]byte(`{"a": [1, 2, 3`)); err == nil {
+		t.Error("truncated Event accepted")
+	}
+	if _, err := New(WithArrayLimit(0, ArrayLimitError)); err == nil {
+		t.Error("zero limit accepted")
+	}
+	if _, err := New(WithArrayLimit(1, ArrayLimitError), WithArrayLimit(2, ArrayLimitError)); err == nil {
+		t.Error("limit specified twice")
+	}
+	if _, err := New(WithArrayLimit(1, ArrayLimitPolicy(7))); err == nil {
+		t.Error("unknown policy accepted")
+	}
+	custom := &labelRecordingFlattener{Flattener: newJSONFlattener()}
+	if _, err := New(WithArrayLimit(1, ArrayLimitError), WithFlattener(custom)); err == nil {
+		t.Error("array limit with a custom Flattener")
+	}
+}
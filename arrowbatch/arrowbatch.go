@@ -0,0 +1,287 @@
+// Package arrowbatch matches the rows of Apache Arrow record batches against Quamina Patterns, column by
+// column, using Quamina's MatchesForColumns. Only the columns, and the fields of struct columns, that Patterns
+// use are converted, each in a single pass over its values, so analytics pipelines can filter batches without
+// writing their rows out as JSON.
+//
+// A row is matched as if it were the JSON object whose members are the batch's columns. Structs are objects,
+// lists, fixed-size lists, and list views are arrays, and maps are arrays of objects with "key" and "value"
+// members. Integers, floating-point numbers, and decimals are numbers, except that NaN and the infinities are
+// the strings "NaN", "Infinity", and "-Infinity". Binary values are base64 strings, timestamps are RFC 3339
+// strings in UTC, and dates are strings such as "2024-02-29". Dictionary-encoded columns are matched as the
+// values they encode, and extension types as their storage. Values of other types are matched as the strings
+// that their arrays' ValueStr methods return.
+//
+// The package is a module of its own, so that Quamina itself keeps no dependencies outside the standard
+// library.
+package arrowbatch
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"quamina.net/go/quamina/v2"
+)
+
+// Matches returns, for each row of the batch, the X values of the Patterns added to q which match it.
+func Matches(q *quamina.Quamina, batch arrow.Record) ([][]quamina.X, error) {
+	columns, err := Columns(batch, q.FieldPaths())
+	if err != nil {
+		return nil, err
+	}
+	return q.MatchesForColumns(columns)
+}
+
+// Columns converts the columns of the batch that the paths use, as returned by Quamina's FieldPaths, to
+// quamina.Columns. If it converts none, there is a single Column without fields, so that the batch's rows are
+// still counted.
+func Columns(batch arrow.Record, paths []string) ([]quamina.Column, error) {
+	used := make(map[string]bool)
+	for _, path := range paths {
+		for i := 0; i < len(path); i++ {
+			if path[i] == quamina.SegmentSeparator[0] {
+				used[path[:i]] = true
+			}
+		}
+		used[path] = true
+	}
+
+	var columns []quamina.Column
+	for i := 0; i < int(batch.NumCols()); i++ {
+		name := batch.ColumnName(i)
+		if !used[name] {
+			continue
+		}
+		column, err := convert(name, name, batch.Column(i), used)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 && batch.NumRows() > 0 {
+		columns = append(columns, quamina.Column{Nulls: make([]bool, batch.NumRows())})
+	}
+	return columns, nil
+}
+
+// convert returns the Column named name for the array, whose values are at the path prefix
+func convert(name, prefix string, a arrow.Array, used map[string]bool) (quamina.Column, error) {
+	switch a := a.(type) {
+	case array.ExtensionArray:
+		return convert(name, prefix, a.Storage(), used)
+
+	case *array.Struct:
+		column := quamina.Column{Name: name, Nulls: nulls(a)}
+		structType := a.DataType().(*arrow.StructType)
+		for i := 0; i < a.NumField(); i++ {
+			fieldName := structType.Field(i).Name
+			fieldPrefix := prefix + quamina.SegmentSeparator + fieldName
+			if !used[fieldPrefix] {
+				continue
+			}
+			field, err := convert(fieldName, fieldPrefix, a.Field(i), used)
+			if err != nil {
+				return quamina.Column{}, err
+			}
+			column.Fields = append(column.Fields, field)
+		}
+		if column.Nulls == nil && column.Fields == nil {
+			// without Nulls, a struct none of whose fields are used would have no entries
+			column.Nulls = make([]bool, a.Len())
+		}
+		return column, nil
+
+	case array.ListLike:
+		offsets, err := listOffsets(a)
+		if err != nil {
+			return quamina.Column{}, fmt.Errorf("column %s: %w", prefix, err)
+		}
+		elements, err := convert("", prefix, a.ListValues(), used)
+		if err != nil {
+			return quamina.Column{}, err
+		}
+		return quamina.Column{Name: name, Offsets: offsets, Elements: &elements, Nulls: nulls(a)}, nil
+
+	case *array.Dictionary:
+		// the dictionary's values are converted once, however many rows use them
+		dictionary, err := convert(name, prefix, a.Dictionary(), used)
+		if err != nil {
+			return quamina.Column{}, err
+		}
+		if dictionary.Values == nil {
+			return quamina.Column{}, fmt.Errorf("column %s: dictionaries of %s aren't supported",
+				prefix, a.Dictionary().DataType())
+		}
+		column := quamina.Column{Name: name, Values: make([][]byte, a.Len()), IsNumber: dictionary.IsNumber}
+		for i := range column.Values {
+			if !a.IsNull(i) {
+				column.Values[i] = dictionary.Values[a.GetValueIndex(i)]
+			}
+		}
+		return column, nil
+	}
+	return leaf(name, a), nil
+}
+
+// nulls returns which of the array's values are null, or nil if none are
+func nulls(a arrow.Array) []bool {
+	if a.NullN() == 0 {
+		return nil
+	}
+	nulls := make([]bool, a.Len())
+	for i := range nulls {
+		nulls[i] = a.IsNull(i)
+	}
+	return nulls
+}
+
+// listOffsets returns the Offsets of a list array's values in its ListValues. Null lists may have any offsets,
+// so they're stretched over the values between their neighbours, but the values of other lists must follow
+// one another, as they do in every layout but that of list views.
+func listOffsets(a array.ListLike) ([]int, error) {
+	offsets := make([]int, a.Len()+1)
+	previousNull := true
+	for i := 0; i < a.Len(); i++ {
+		if a.IsNull(i) {
+			offsets[i+1] = offsets[i]
+			previousNull = true
+			continue
+		}
+		start, end := a.ValueOffsets(i)
+		if int(start) < offsets[i] || int(start) != offsets[i] && !previousNull {
+			return nil, fmt.Errorf("list %d's values don't follow those of list %d", i, i-1)
+		}
+		offsets[i], offsets[i+1] = int(start), int(end)
+		previousNull = false
+	}
+	return offsets, nil
+}
+
+// leaf returns a Column whose Values are written, one after another, into a single buffer
+func leaf(name string, a arrow.Array) quamina.Column {
+	column := quamina.Column{Name: name, Values: make([][]byte, a.Len())}
+	var format func(buf []byte, i int) []byte
+	switch a := a.(type) {
+	case *array.Null:
+		return column
+	case *array.Boolean:
+		format = func(buf []byte, i int) []byte { return strconv.AppendBool(buf, a.Value(i)) }
+	case *array.Int8:
+		format, column.IsNumber = signed[int8](a), true
+	case *array.Int16:
+		format, column.IsNumber = signed[int16](a), true
+	case *array.Int32:
+		format, column.IsNumber = signed[int32](a), true
+	case *array.Int64:
+		format, column.IsNumber = signed[int64](a), true
+	case *array.Uint8:
+		format, column.IsNumber = unsigned[uint8](a), true
+	case *array.Uint16:
+		format, column.IsNumber = unsigned[uint16](a), true
+	case *array.Uint32:
+		format, column.IsNumber = unsigned[uint32](a), true
+	case *array.Uint64:
+		format, column.IsNumber = unsigned[uint64](a), true
+	case *array.Float16:
+		format = func(buf []byte, i int) []byte { return appendFloat(buf, float64(a.Value(i).Float32()), 32) }
+		column.IsNumber = true
+	case *array.Float32:
+		format = func(buf []byte, i int) []byte { return appendFloat(buf, float64(a.Value(i)), 32) }
+		column.IsNumber = true
+	case *array.Float64:
+		format = func(buf []byte, i int) []byte { return appendFloat(buf, a.Value(i), 64) }
+		column.IsNumber = true
+	case *array.Decimal128:
+		scale := a.DataType().(*arrow.Decimal128Type).Scale
+		format = func(buf []byte, i int) []byte { return append(buf, a.Value(i).ToString(scale)...) }
+		column.IsNumber = true
+	case *array.Decimal256:
+		scale := a.DataType().(*arrow.Decimal256Type).Scale
+		format = func(buf []byte, i int) []byte { return append(buf, a.Value(i).ToString(scale)...) }
+		column.IsNumber = true
+	case *array.Timestamp:
+		unit := a.DataType().(*arrow.TimestampType).Unit
+		format = func(buf []byte, i int) []byte {
+			return appendTime(buf, a.Value(i).ToTime(unit).UTC(), time.RFC3339Nano)
+		}
+	case *array.Date32:
+		format = func(buf []byte, i int) []byte { return appendTime(buf, a.Value(i).ToTime(), time.DateOnly) }
+	case *array.Date64:
+		format = func(buf []byte, i int) []byte { return appendTime(buf, a.Value(i).ToTime(), time.DateOnly) }
+	case interface{ Value(int) string }:
+		// String, LargeString, and StringView
+		format = func(buf []byte, i int) []byte { return appendQuoted(buf, a.Value(i)) }
+	case interface{ Value(int) []byte }:
+		// Binary, LargeBinary, FixedSizeBinary, and BinaryView
+		format = func(buf []byte, i int) []byte {
+			buf = append(buf, '"')
+			return append(base64.StdEncoding.AppendEncode(buf, a.Value(i)), '"')
+		}
+	default:
+		format = func(buf []byte, i int) []byte { return appendQuoted(buf, a.ValueStr(i)) }
+	}
+
+	var buf []byte
+	ends := make([]int, a.Len())
+	for i := range ends {
+		if !a.IsNull(i) {
+			buf = format(buf, i)
+		}
+		ends[i] = len(buf)
+	}
+	start := 0
+	for i, end := range ends {
+		if !a.IsNull(i) {
+			column.Values[i] = buf[start:end:end]
+		}
+		start = end
+	}
+	return column
+}
+
+func signed[T int8 | int16 | int32 | int64](a interface{ Value(int) T }) func([]byte, int) []byte {
+	return func(buf []byte, i int) []byte { return strconv.AppendInt(buf, int64(a.Value(i)), 10) }
+}
+
+func unsigned[T uint8 | uint16 | uint32 | uint64](a interface{ Value(int) T }) func([]byte, int) []byte {
+	return func(buf []byte, i int) []byte { return strconv.AppendUint(buf, uint64(a.Value(i)), 10) }
+}
+
+// appendFloat writes n as JSON would, or, if JSON can't, as a string; Quamina compares numbers which don't
+// parse as strings
+func appendFloat(buf []byte, n float64, bits int) []byte {
+	switch {
+	case math.IsNaN(n):
+		return append(buf, `"NaN"`...)
+	case math.IsInf(n, 1):
+		return append(buf, `"Infinity"`...)
+	case math.IsInf(n, -1):
+		return append(buf, `"-Infinity"`...)
+	}
+	format := byte('f')
+	if abs := math.Abs(n); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	return strconv.AppendFloat(buf, n, format, -1, bits)
+}
+
+func appendTime(buf []byte, t time.Time, layout string) []byte {
+	buf = append(buf, '"')
+	return append(t.AppendFormat(buf, layout), '"')
+}
+
+// appendQuoted writes s as Quamina represents a string value, in quotation marks but otherwise unescaped
+func appendQuoted(buf []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	buf = append(buf, '"')
+	buf = append(buf, s...)
+	return append(buf, '"')
+}
@@ -0,0 +1,163 @@
+package arrowbatch
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"quamina.net/go/quamina/v2"
+)
+
+// rows are the rows of the batch that batch returns, as JSON
+var rows = []string{
+	`{"id": "a", "n": 1.5, "user": {"name": "pat", "age": 30}, "tags": ["x", "y"], "color": "red",
+	  "at": "2024-02-29T12:00:00.5Z"}`,
+	`{"id": "b", "n": 2, "user": null, "tags": [], "color": null, "at": null}`,
+	`{"id": null, "n": 3, "user": {"name": "lee", "age": null}, "tags": null, "color": "blue",
+	  "at": "1970-01-01T00:00:00Z"}`,
+}
+
+func batch(t *testing.T) arrow.Record {
+	t.Helper()
+	userType := arrow.StructOf(
+		arrow.Field{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		arrow.Field{Name: "age", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	)
+	colorType := &arrow.DictionaryType{IndexType: arrow.PrimitiveTypes.Int8, ValueType: arrow.BinaryTypes.String}
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "n", Type: arrow.PrimitiveTypes.Float64},
+		{Name: "user", Type: userType, Nullable: true},
+		{Name: "tags", Type: arrow.ListOf(arrow.BinaryTypes.String), Nullable: true},
+		{Name: "color", Type: colorType, Nullable: true},
+		{Name: "at", Type: arrow.FixedWidthTypes.Timestamp_ms, Nullable: true},
+	}, nil)
+
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer b.Release()
+	b.Field(0).(*array.StringBuilder).AppendValues([]string{"a", "b", ""}, []bool{true, true, false})
+	b.Field(1).(*array.Float64Builder).AppendValues([]float64{1.5, 2, 3}, nil)
+
+	user := b.Field(2).(*array.StructBuilder)
+	name, age := user.FieldBuilder(0).(*array.StringBuilder), user.FieldBuilder(1).(*array.Int64Builder)
+	user.Append(true)
+	name.Append("pat")
+	age.Append(30)
+	user.AppendNull()
+	user.Append(true)
+	name.Append("lee")
+	age.AppendNull()
+
+	tags := b.Field(3).(*array.ListBuilder)
+	tagValues := tags.ValueBuilder().(*array.StringBuilder)
+	tags.Append(true)
+	tagValues.AppendValues([]string{"x", "y"}, nil)
+	tags.Append(true)
+	tags.AppendNull()
+
+	color := b.Field(4).(*array.BinaryDictionaryBuilder)
+	if err := color.AppendString("red"); err != nil {
+		t.Fatal(err)
+	}
+	color.AppendNull()
+	if err := color.AppendString("blue"); err != nil {
+		t.Fatal(err)
+	}
+
+	at := b.Field(5).(*array.TimestampBuilder)
+	at.Append(arrow.Timestamp(1709208000500))
+	at.AppendNull()
+	at.Append(arrow.Timestamp(0))
+
+	return b.NewRecord()
+}
+
+// TestMatches checks that each row of the batch matches the Patterns that it does as JSON
+func TestMatches(t *testing.T) {
+	patterns := []string{
+		`{"id": ["a"]}`,
+		`{"id": [null]}`,
+		`{"n": [1.5]}`,
+		`{"n": [3.0], "user": {"name": ["lee"]}}`,
+		`{"user": [null]}`,
+		`{"user": {"age": [30]}}`,
+		`{"user": {"age": [null]}}`,
+		`{"tags": ["y"]}`,
+		`{"tags": [null]}`,
+		`{"tags": [{"exists": false}]}`,
+		`{"color": ["blue"]}`,
+		`{"color": [null]}`,
+		`{"at": [{"prefix": "2024-02-29T12:00:00.5"}]}`,
+		`{"at": ["1970-01-01T00:00:00Z"]}`,
+		`{"missing": [{"exists": false}], "id": ["b"]}`,
+	}
+	q, _ := quamina.New()
+	want, _ := quamina.New()
+	for i, pattern := range patterns {
+		if err := q.AddPattern(i, pattern); err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+		_ = want.AddPattern(i, pattern)
+	}
+	rec := batch(t)
+	defer rec.Release()
+	got, err := Matches(q, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(rows) {
+		t.Fatalf("%d results for %d rows", len(got), len(rows))
+	}
+	matched := map[quamina.X]bool{}
+	for row, event := range rows {
+		wantMatches, err := want.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got[row]) != len(wantMatches) {
+			t.Errorf("row %d: got %v, want %v", row, got[row], wantMatches)
+		}
+		for _, x := range wantMatches {
+			if !slices.Contains(got[row], x) {
+				t.Errorf("row %d: got %v, want %v", row, got[row], wantMatches)
+			}
+			matched[x] = true
+		}
+	}
+	if len(matched) != len(patterns) {
+		t.Errorf("only %d Patterns match", len(matched))
+	}
+}
+
+func TestColumns(t *testing.T) {
+	rec := batch(t)
+	defer rec.Release()
+
+	// only the columns and struct fields that the paths use are converted
+	columns, err := Columns(rec, []string{"id", "user\nname"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 2 || columns[0].Name != "id" || columns[1].Name != "user" ||
+		len(columns[1].Fields) != 1 || columns[1].Fields[0].Name != "name" {
+		t.Errorf("got %+v", columns)
+	}
+
+	// with no columns used, the rows are still counted, so Patterns which only need fields to be absent match
+	q, _ := quamina.New()
+	_ = q.AddPattern("p", `{"missing": [{"exists": false}]}`)
+	got, err := Matches(q, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(rows) {
+		t.Fatalf("%d results for %d rows", len(got), len(rows))
+	}
+	for row, matches := range got {
+		if len(matches) != 1 {
+			t.Errorf("row %d: got %v", row, matches)
+		}
+	}
+}
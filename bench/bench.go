@@ -0,0 +1,161 @@
+// Package bench measures how a Quamina instance performs with a particular set of Patterns and Events, so
+// that the effect of changes to a rule set can be evaluated reproducibly without writing benchmark code.
+// Given a pattern corpus and an event corpus, Run adds the Patterns, then matches the Events repeatedly for
+// at least a minimum duration, and reports the time taken to add the Patterns, matching throughput, memory
+// allocation, and the matcher statistics that GetMatcherStats provides, including how many fields are using
+// the prefix fast path.
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Config describes a benchmark run. Patterns and Events are required; each Pattern is added with its index
+// in Patterns as its X value.
+type Config struct {
+	Patterns []string
+	Events   [][]byte
+	// Options are passed to quamina.New.
+	Options []quamina.Option
+	// BuildMode is set on the instance before the Patterns are added.
+	BuildMode quamina.MatcherBuildMode
+	// Minimize, if true, means Minimize is called once the Patterns have been added.
+	Minimize bool
+	// MinDuration is the least time to spend matching; the whole event corpus is always matched at least
+	// once. Zero means one second.
+	MinDuration time.Duration
+}
+
+// Report holds the results of a benchmark run.
+type Report struct {
+	Patterns int
+	Events   int
+	// AddPatternTime is the total time taken to add the Patterns, including Minimize if requested.
+	AddPatternTime time.Duration
+	// Passes is the number of times the event corpus was matched.
+	Passes int
+	// MatchTime is the total time spent matching.
+	MatchTime time.Duration
+	// EventsPerSecond and BytesPerSecond measure matching throughput.
+	EventsPerSecond float64
+	BytesPerSecond  float64
+	// AllocsPerEvent and AllocBytesPerEvent are the average heap allocations per MatchesForEvent call.
+	AllocsPerEvent     float64
+	AllocBytesPerEvent float64
+	// MatchedEvents is the number of Events in the corpus that matched at least one Pattern, and Matches the
+	// total number of matches for the corpus; these are from the first pass.
+	MatchedEvents int
+	Matches       int
+	// MatcherStats is what GetMatcherStats returned once the Patterns were added.
+	MatcherStats map[string]float64
+}
+
+// Run performs the benchmark described by cfg.
+func Run(cfg Config) (*Report, error) {
+	if len(cfg.Patterns) == 0 {
+		return nil, errors.New("no patterns")
+	}
+	if len(cfg.Events) == 0 {
+		return nil, errors.New("no events")
+	}
+	minDuration := cfg.MinDuration
+	if minDuration == 0 {
+		minDuration = time.Second
+	}
+
+	q, err := quamina.New(cfg.Options...)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BuildMode != quamina.BuiltForComfort {
+		if err := q.SetMatcherBuildMode(cfg.BuildMode); err != nil {
+			return nil, err
+		}
+	}
+	report := &Report{Patterns: len(cfg.Patterns), Events: len(cfg.Events)}
+
+	start := time.Now()
+	for i, pattern := range cfg.Patterns {
+		if err := q.AddPattern(i, pattern); err != nil {
+			return nil, fmt.Errorf("pattern %d: %w", i, err)
+		}
+	}
+	if cfg.Minimize {
+		q.Minimize()
+	}
+	report.AddPatternTime = time.Since(start)
+	report.MatcherStats = q.GetMatcherStats()
+
+	// the first pass, which checks the events, counts matches, and warms up the buffers, isn't timed
+	var eventBytes int
+	for i, event := range cfg.Events {
+		matches, err := q.MatchesForEvent(event)
+		if err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+		if len(matches) > 0 {
+			report.MatchedEvents++
+		}
+		report.Matches += len(matches)
+		eventBytes += len(event)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start = time.Now()
+	for report.MatchTime < minDuration {
+		for _, event := range cfg.Events {
+			_, _ = q.MatchesForEvent(event)
+		}
+		report.Passes++
+		report.MatchTime = time.Since(start)
+	}
+	runtime.ReadMemStats(&after)
+
+	calls := float64(report.Passes * len(cfg.Events))
+	seconds := report.MatchTime.Seconds()
+	report.EventsPerSecond = calls / seconds
+	report.BytesPerSecond = float64(report.Passes*eventBytes) / seconds
+	report.AllocsPerEvent = float64(after.Mallocs-before.Mallocs) / calls
+	report.AllocBytesPerEvent = float64(after.TotalAlloc-before.TotalAlloc) / calls
+	return report, nil
+}
+
+// String formats the report for people to read.
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "patterns: %d, added in %v\n", r.Patterns, r.AddPatternTime)
+	fmt.Fprintf(&sb, "events: %d, %d matched, %d matches\n", r.Events, r.MatchedEvents, r.Matches)
+	fmt.Fprintf(&sb, "matching: %.0f events/s, %.2f MB/s, %.0f ns/event over %d passes\n",
+		r.EventsPerSecond, r.BytesPerSecond/1e6, 1e9/r.EventsPerSecond, r.Passes)
+	fmt.Fprintf(&sb, "allocation: %.2f allocs/event, %.0f bytes/event\n", r.AllocsPerEvent, r.AllocBytesPerEvent)
+	fmt.Fprintf(&sb, "matcher: %.0f states, %.0f bytes, %.0f prefix fast paths",
+		r.MatcherStats["states"], r.MatcherStats["bytes"], r.MatcherStats["prefixFastPaths"])
+	return sb.String()
+}
+
+// ReadCorpus reads a corpus of Patterns or Events with one JSON object on each line, as in JSON Lines
+// files. Blank lines are skipped.
+func ReadCorpus(r io.Reader) ([][]byte, error) {
+	var corpus [][]byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		corpus = append(corpus, bytes.Clone(line))
+	}
+	return corpus, scanner.Err()
+}
@@ -0,0 +1,75 @@
+package bench
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func TestRun(t *testing.T) {
+	patterns, err := ReadCorpus(strings.NewReader(`
+{"type": ["order"], "path": [{"prefix": "/api/"}]}
+
+{"type": ["refund"]}
+{"amount": [{"wildcard": "*.99"}]}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 3 {
+		t.Fatalf("%d patterns", len(patterns))
+	}
+	var patternStrings []string
+	for _, pattern := range patterns {
+		patternStrings = append(patternStrings, string(pattern))
+	}
+	events := [][]byte{
+		[]byte(`{"type": "order", "path": "/api/v1/orders", "amount": "10.99"}`),
+		[]byte(`{"type": "refund", "path": "/web"}`),
+		[]byte(`{"type": "other"}`),
+	}
+
+	for _, minimize := range []bool{false, true} {
+		report, err := Run(Config{
+			Patterns:    patternStrings,
+			Events:      events,
+			BuildMode:   quamina.BuiltForSpeed,
+			Minimize:    minimize,
+			MinDuration: 10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Patterns != 3 || report.Events != 3 || report.MatchedEvents != 2 || report.Matches != 3 {
+			t.Errorf("report %+v", report)
+		}
+		if report.Passes < 1 || report.MatchTime < 10*time.Millisecond || report.EventsPerSecond <= 0 {
+			t.Errorf("report %+v", report)
+		}
+		if report.MatcherStats["prefixFastPaths"] != 1 {
+			t.Errorf("stats %v", report.MatcherStats)
+		}
+		if !strings.Contains(report.String(), "events: 3, 2 matched, 3 matches") {
+			t.Errorf("String() = %s", report)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	events := [][]byte{[]byte(`{"a": 1}`)}
+	bad := []Config{
+		{Events: events},
+		{Patterns: []string{`{"a": [1]}`}},
+		{Patterns: []string{`{"a": 1}`}, Events: events},
+		{Patterns: []string{`{"a": [1]}`}, Events: [][]byte{[]byte(`{"a": `)}},
+		{Patterns: []string{`{"a": [1]}`}, Events: events, BuildMode: quamina.BuiltForSpeed,
+			Options: []quamina.Option{quamina.WithPatternDeletion(true)}},
+	}
+	for i, cfg := range bad {
+		if _, err := Run(cfg); err == nil {
+			t.Errorf("config %d accepted", i)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+// Package bson provides a Flattener for BSON documents, so that Quamina can match the events of MongoDB
+// change streams, and other BSON, without first converting them to JSON. A document is flattened as if it
+// were its JSON form, with values of types JSON doesn't have written like this:
+//
+//   - ObjectIds are strings of 24 hex digits, as in "5f8a7b6c5d4e3f2a1b0c9d8e"
+//   - Decimal128s are numbers, written in their string form, as in 1.5E+3 and 0.001; numbers beyond the range
+//     of float64 can't be compared numerically, and match only as strings
+//   - NaN and the infinities, as doubles or Decimal128s, are the strings "NaN", "Infinity", and "-Infinity"
+//   - datetimes are strings in UTC with milliseconds, as in "2024-02-29T12:00:00.500Z", so that they sort as
+//     strings in the order of time; those outside the years 0 to 9999 are numbers of milliseconds since 1970
+//   - timestamps are objects of seconds and increment, as in {"t": 1700000000, "i": 1}
+//   - binary data is a string in base64, except that UUIDs are strings of hex digits and hyphens
+//   - regular expressions are strings such as "/^a.*z/i"; JavaScript code and symbols are strings
+//   - DBPointers are objects {"$ref": "db.coll", "$id": "..."}
+//   - undefined is null, and MinKey and MaxKey are the strings "MinKey" and "MaxKey"
+//
+// So this Pattern matches change events for orders with an amount, stored as Decimal128, of over 1000:
+//
+//	{"operationType": ["insert"], "ns": {"coll": ["orders"]}, "fullDocument": {"amount": [{"numeric": [">", 1000]}]}}
+//
+// The Flattener reads only the parts of a document that Patterns use, skipping over others without checking
+// them. Use it like this:
+//
+//	q, err := quamina.New(quamina.WithFlattener(bson.NewFlattener()))
+package bson
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/internal/flatten"
+)
+
+// maxDepth limits the nesting of documents and arrays; MongoDB stores documents at most 100 deep, and change
+// events wrap them in a few more
+const maxDepth = 200
+
+// the element types, from bsonspec.org
+const (
+	typeDouble     = 0x01
+	typeString     = 0x02
+	typeDocument   = 0x03
+	typeArray      = 0x04
+	typeBinary     = 0x05
+	typeUndefined  = 0x06
+	typeObjectID   = 0x07
+	typeBool       = 0x08
+	typeDateTime   = 0x09
+	typeNull       = 0x0a
+	typeRegex      = 0x0b
+	typeDBPointer  = 0x0c
+	typeJavaScript = 0x0d
+	typeSymbol     = 0x0e
+	typeCodeScope  = 0x0f
+	typeInt32      = 0x10
+	typeTimestamp  = 0x11
+	typeInt64      = 0x12
+	typeDecimal128 = 0x13
+	typeMinKey     = 0xff
+	typeMaxKey     = 0x7f
+)
+
+// binary subtypes that are written differently
+const (
+	subtypeOldBinary = 0x02
+	subtypeUUID      = 0x04
+)
+
+// Flattener flattens BSON documents. Like Quamina instances, a Flattener is not safe for concurrent use;
+// Copy makes one for another goroutine.
+type Flattener struct {
+	w     flatten.Writer
+	doc   []byte
+	depth int
+	buf   []byte
+}
+
+// NewFlattener returns a Flattener for BSON documents.
+func NewFlattener() *Flattener {
+	return &Flattener{}
+}
+
+// Copy returns a new Flattener.
+func (f *Flattener) Copy() quamina.Flattener {
+	return NewFlattener()
+}
+
+// Flatten reads the BSON document in event, returning an error if the parts of it that it reads aren't
+// well-formed. The Fields returned are valid until the next call.
+func (f *Flattener) Flatten(event []byte, tracker quamina.SegmentsTreeTracker) ([]quamina.Field, error) {
+	f.w.Reset()
+	f.doc = event
+	f.depth = 0
+	end, err := f.documentEnd(0)
+	if err != nil {
+		return nil, err
+	}
+	if end != len(event) {
+		return nil, f.error(end, "trailing bytes after the document")
+	}
+	if err := f.document(0, end, tracker, nil); err != nil {
+		return nil, err
+	}
+	return f.w.Fields(), nil
+}
+
+// document writes the elements of the document or array at pos, which ends at end, as the members of the
+// object at node or, if array isn't nil, as the elements of array
+func (f *Flattener) document(pos, end int, node quamina.SegmentsTreeTracker, array *flatten.Array) error {
+	if f.depth++; f.depth > maxDepth {
+		return f.error(pos, fmt.Sprintf("documents nested more than %d deep", maxDepth))
+	}
+	defer func() { f.depth-- }()
+	if f.doc[end-1] != 0 {
+		return f.error(end-1, "document not terminated")
+	}
+	for pos += 4; pos < end-1; {
+		t := f.doc[pos]
+		name, next, err := f.cstring(pos+1, end-1)
+		if err != nil {
+			return err
+		}
+		var sink flatten.Sink
+		switch {
+		case array != nil:
+			array.Next()
+			sink = array
+		case flatten.Used(node, name):
+			sink = f.w.Member(node, name)
+		}
+		if pos, err = f.value(t, next, end-1, sink); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// value writes the value of type t at pos, which must end by end, to sink, or just skips it if sink is nil,
+// and returns the position after it
+func (f *Flattener) value(t byte, pos, end int, sink flatten.Sink) (int, error) {
+	var size int
+	switch t {
+	case typeUndefined, typeNull, typeMinKey, typeMaxKey:
+	case typeBool:
+		size = 1
+	case typeInt32:
+		size = 4
+	case typeDouble, typeDateTime, typeTimestamp, typeInt64:
+		size = 8
+	case typeObjectID:
+		size = 12
+	case typeDecimal128:
+		size = 16
+	case typeString, typeJavaScript, typeSymbol:
+		n, err := f.stringEnd(pos)
+		if err != nil {
+			return 0, err
+		}
+		size = n - pos
+	case typeDBPointer:
+		n, err := f.stringEnd(pos)
+		if err != nil {
+			return 0, err
+		}
+		size = n - pos + 12
+	case typeDocument, typeArray, typeCodeScope:
+		// code with scope starts with its length, like a document
+		n, err := f.documentEnd(pos)
+		if err != nil {
+			return 0, err
+		}
+		size = n - pos
+	case typeBinary:
+		if pos+5 > end {
+			return 0, f.error(pos, "binary data truncated")
+		}
+		size = 5 + int(binary.LittleEndian.Uint32(f.doc[pos:]))
+		if size < 5 {
+			return 0, f.error(pos, "bad length")
+		}
+	case typeRegex:
+		_, next, err := f.cstring(pos, end)
+		if err != nil {
+			return 0, err
+		}
+		if _, next, err = f.cstring(next, end); err != nil {
+			return 0, err
+		}
+		size = next - pos
+	default:
+		return 0, f.error(pos-1, fmt.Sprintf("unknown element type 0x%02x", t))
+	}
+	if size > end-pos {
+		return 0, f.error(pos, "value runs past the end of its document")
+	}
+	if sink == nil {
+		return pos + size, nil
+	}
+	v := f.doc[pos : pos+size]
+
+	switch t {
+	case typeDouble:
+		f.writeDouble(sink, math.Float64frombits(binary.LittleEndian.Uint64(v)))
+	case typeString, typeJavaScript, typeSymbol:
+		s, err := f.string(pos)
+		if err != nil {
+			return 0, err
+		}
+		sink.String(s)
+	case typeDocument:
+		if node, ok := sink.Object(); ok {
+			if err := f.document(pos, pos+size, node, nil); err != nil {
+				return 0, err
+			}
+		}
+	case typeArray:
+		if array, ok := sink.Array(); ok {
+			err := f.document(pos, pos+size, nil, array)
+			array.End()
+			if err != nil {
+				return 0, err
+			}
+		}
+	case typeBinary:
+		f.writeBinary(sink, v[4], v[5:])
+	case typeUndefined, typeNull:
+		sink.Literal("null")
+	case typeObjectID:
+		f.buf = hex.AppendEncode(f.buf[:0], v)
+		sink.String(string(f.buf))
+	case typeBool:
+		switch v[0] {
+		case 0:
+			sink.Literal("false")
+		case 1:
+			sink.Literal("true")
+		default:
+			return 0, f.error(pos, "bad boolean")
+		}
+	case typeDateTime:
+		writeDateTime(sink, int64(binary.LittleEndian.Uint64(v)))
+	case typeRegex:
+		pattern, next, _ := f.cstring(pos, end)
+		options, _, _ := f.cstring(next, end)
+		sink.String("/" + pattern + "/" + options)
+	case typeDBPointer:
+		ns, err := f.string(pos)
+		if err != nil {
+			return 0, err
+		}
+		if node, ok := sink.Object(); ok {
+			f.w.String(node, "$ref", ns)
+			f.buf = hex.AppendEncode(f.buf[:0], v[size-12:])
+			f.w.String(node, "$id", string(f.buf))
+		}
+	case typeCodeScope:
+		// the code, and then the scope, which is left out
+		if size < 14 {
+			return 0, f.error(pos, "bad code with scope")
+		}
+		code, err := f.string(pos + 4)
+		if err != nil {
+			return 0, err
+		}
+		sink.String(code)
+	case typeInt32:
+		sink.Number(strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(v))), 10))
+	case typeTimestamp:
+		if node, ok := sink.Object(); ok {
+			f.w.Number(node, "t", strconv.FormatUint(uint64(binary.LittleEndian.Uint32(v[4:])), 10))
+			f.w.Number(node, "i", strconv.FormatUint(uint64(binary.LittleEndian.Uint32(v)), 10))
+		}
+	case typeInt64:
+		sink.Number(strconv.FormatInt(int64(binary.LittleEndian.Uint64(v)), 10))
+	case typeDecimal128:
+		f.writeDecimal(sink, binary.LittleEndian.Uint64(v[8:]), binary.LittleEndian.Uint64(v))
+	case typeMinKey:
+		sink.String("MinKey")
+	case typeMaxKey:
+		sink.String("MaxKey")
+	}
+	return pos + size, nil
+}
+
+// documentEnd reads the length of the document at pos, returning where it ends
+func (f *Flattener) documentEnd(pos int) (int, error) {
+	if pos+4 > len(f.doc) {
+		return 0, f.error(pos, "length truncated")
+	}
+	n := int64(int32(binary.LittleEndian.Uint32(f.doc[pos:])))
+	if n < 5 || n > int64(len(f.doc)-pos) {
+		return 0, f.error(pos, "bad document length")
+	}
+	return pos + int(n), nil
+}
+
+// stringEnd reads the length of the string at pos, which doesn't include the length itself but does include
+// the string's terminating zero byte, returning where it ends
+func (f *Flattener) stringEnd(pos int) (int, error) {
+	if pos+4 > len(f.doc) {
+		return 0, f.error(pos, "length truncated")
+	}
+	n := int64(int32(binary.LittleEndian.Uint32(f.doc[pos:])))
+	if n < 1 || n > int64(len(f.doc)-pos-4) {
+		return 0, f.error(pos, "bad string length")
+	}
+	return pos + 4 + int(n), nil
+}
+
+// string reads the string at pos
+func (f *Flattener) string(pos int) (string, error) {
+	end, err := f.stringEnd(pos)
+	if err != nil {
+		return "", err
+	}
+	if f.doc[end-1] != 0 {
+		return "", f.error(end-1, "string not terminated")
+	}
+	return string(f.doc[pos+4 : end-1]), nil
+}
+
+// cstring reads the zero-terminated string at pos, which must end before end, returning it and the position
+// after it
+func (f *Flattener) cstring(pos, end int) (string, int, error) {
+	for i := pos; i < end; i++ {
+		if f.doc[i] == 0 {
+			return string(f.doc[pos:i]), i + 1, nil
+		}
+	}
+	return "", 0, f.error(pos, "name not terminated")
+}
+
+func (f *Flattener) error(pos int, message string) error {
+	return fmt.Errorf("bson document at %d: %s", pos, message)
+}
+
+// writeDouble writes a double, with NaN and the infinities as strings
+func (f *Flattener) writeDouble(sink flatten.Sink, n float64) {
+	switch {
+	case math.IsNaN(n):
+		sink.String("NaN")
+	case math.IsInf(n, 1):
+		sink.String("Infinity")
+	case math.IsInf(n, -1):
+		sink.String("-Infinity")
+	default:
+		format := byte('f')
+		if abs := math.Abs(n); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+		f.buf = strconv.AppendFloat(f.buf[:0], n, format, -1, 64)
+		sink.Number(string(f.buf))
+	}
+}
+
+// writeBinary writes binary data of the subtype, UUIDs as hex digits and hyphens and the rest in base64
+func (f *Flattener) writeBinary(sink flatten.Sink, subtype byte, data []byte) {
+	if subtype == subtypeOldBinary && len(data) >= 4 && int(binary.LittleEndian.Uint32(data)) == len(data)-4 {
+		data = data[4:]
+	}
+	if subtype == subtypeUUID && len(data) == 16 {
+		f.buf = f.buf[:0]
+		for i, group := range []int{4, 2, 2, 2, 6} {
+			if i > 0 {
+				f.buf = append(f.buf, '-')
+			}
+			f.buf = hex.AppendEncode(f.buf, data[:group])
+			data = data[group:]
+		}
+		sink.String(string(f.buf))
+		return
+	}
+	sink.String(base64.StdEncoding.EncodeToString(data))
+}
+
+// writeDateTime writes a datetime, which is milliseconds since 1970
+func writeDateTime(sink flatten.Sink, ms int64) {
+	t := time.UnixMilli(ms).UTC()
+	if t.Year() < 0 || t.Year() > 9999 {
+		sink.Number(strconv.FormatInt(ms, 10))
+		return
+	}
+	sink.String(t.Format("2006-01-02T15:04:05.000Z"))
+}
@@ -0,0 +1,211 @@
+package bson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// doc returns a document with the elements
+func doc(elements ...[]byte) []byte {
+	body := bytes.Join(elements, nil)
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(4+len(body)+1)), append(body, 0)...)
+}
+
+// el returns an element of type t
+func el(t byte, name string, value ...[]byte) []byte {
+	return append(append([]byte{t}, name+"\x00"...), bytes.Join(value, nil)...)
+}
+
+func str(s string) []byte {
+	return append(binary.LittleEndian.AppendUint32(nil, uint32(len(s)+1)), s+"\x00"...)
+}
+
+func i32(n int32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(n))
+}
+
+func i64(n int64) []byte {
+	return binary.LittleEndian.AppendUint64(nil, uint64(n))
+}
+
+func f64(n float64) []byte {
+	return i64(int64(math.Float64bits(n)))
+}
+
+func dec(negative bool, coefficient uint64, exponent int) []byte {
+	high, low := decimal(negative, coefficient, exponent)
+	return binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, low), high)
+}
+
+var objectID = []byte{0x65, 0x5f, 0x2a, 0x1b, 0x0c, 0x9d, 0x8e, 0x7f, 0x60, 0x51, 0x42, 0x33}
+
+// insert is a change event for the insertion of an order
+var insert = doc(
+	el(typeDocument, "_id", doc(el(typeString, "_data", str("8265")))),
+	el(typeString, "operationType", str("insert")),
+	el(typeTimestamp, "clusterTime", i32(1), i32(1700000000)),
+	el(typeDateTime, "wallTime", i64(1709208000500)),
+	el(typeDocument, "ns", doc(el(typeString, "db", str("shop")), el(typeString, "coll", str("orders")))),
+	el(typeDocument, "documentKey", doc(el(typeObjectID, "_id", objectID))),
+	el(typeDocument, "fullDocument", doc(
+		el(typeObjectID, "_id", objectID),
+		el(typeDecimal128, "amount", dec(false, 125050, -2)),
+		el(typeArray, "items", doc(
+			el(typeDocument, "0", doc(el(typeString, "sku", str("a")), el(typeInt32, "qty", i32(2)))),
+			el(typeDocument, "1", doc(el(typeString, "sku", str("b")), el(typeInt32, "qty", i32(5)))),
+		)),
+		el(typeDocument, "customer", doc(
+			el(typeBinary, "uuid", i32(16), []byte{subtypeUUID},
+				[]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}),
+			el(typeString, "email", str("pat@example.com")),
+		)),
+		el(typeBinary, "signature", i32(3), []byte{0}, []byte("abc")),
+		el(typeBinary, "legacy", i32(7), []byte{subtypeOldBinary}, i32(3), []byte("abc")),
+		el(typeNull, "note"),
+		el(typeUndefined, "old"),
+		el(typeBool, "paid", []byte{1}),
+		el(typeBool, "gift", []byte{0}),
+		el(typeArray, "tags", doc(el(typeString, "0", str("x")), el(typeString, "1", str("y")))),
+		el(typeDouble, "score", f64(4.5)),
+		el(typeDouble, "ratio", f64(math.NaN())),
+		el(typeInt64, "big", i64(5000000000)),
+		el(typeRegex, "match", []byte("^a.*z\x00i\x00")),
+		el(typeJavaScript, "code", str("return 1")),
+		el(typeCodeScope, "scoped", i32(4+4+9+5), str("return x"), doc()),
+		el(typeSymbol, "symbol", str("sym")),
+		el(typeDBPointer, "pointer", str("shop.users"), objectID),
+		el(typeMinKey, "low"),
+		el(typeMaxKey, "high"),
+		el(typeDateTime, "ancient", i64(-70000000000000)),
+	)),
+)
+
+func matches(t *testing.T, event []byte, pattern string) bool {
+	t.Helper()
+	q, err := quamina.New(quamina.WithFlattener(NewFlattener()), quamina.WithEventBridgeCompat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("p", pattern); err != nil {
+		t.Fatalf("%s: %v", pattern, err)
+	}
+	matches, err := q.MatchesForEvent(event)
+	if err != nil {
+		t.Fatalf("%s: %v", pattern, err)
+	}
+	return len(matches) == 1
+}
+
+func TestFlatten(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"operationType": ["insert"], "ns": {"db": ["shop"], "coll": ["orders"]}}`, true},
+		{`{"_id": {"_data": ["8265"]}}`, true},
+		{`{"clusterTime": {"t": [1700000000], "i": [1]}}`, true},
+		{`{"wallTime": ["2024-02-29T12:00:00.500Z"]}`, true},
+		{`{"wallTime": [{"prefix": "2024-02-29"}]}`, true},
+		{`{"documentKey": {"_id": ["655f2a1b0c9d8e7f60514233"]}}`, true},
+		{`{"fullDocument": {"_id": ["655f2a1b0c9d8e7f60514233"]}}`, true},
+		{`{"fullDocument": {"amount": [1250.5]}}`, true},
+		{`{"fullDocument": {"amount": ["1250.50"]}}`, false},
+		{`{"fullDocument": {"amount": [{"numeric": [">", 1000, "<", 1250.51]}]}}`, true},
+		{`{"fullDocument": {"items": {"sku": ["a"], "qty": [2]}}}`, true},
+		{`{"fullDocument": {"items": {"sku": ["a"], "qty": [5]}}}`, false},
+		{`{"fullDocument": {"customer": {"uuid": ["12345678-9abc-def0-1234-56789abcdef0"]}}}`, true},
+		{`{"fullDocument": {"customer": {"email": [{"suffix": "@example.com"}]}}}`, true},
+		{`{"fullDocument": {"signature": ["YWJj"], "legacy": ["YWJj"]}}`, true},
+		{`{"fullDocument": {"note": [null], "old": [null]}}`, true},
+		{`{"fullDocument": {"paid": [true], "gift": [false]}}`, true},
+		{`{"fullDocument": {"tags": ["y"]}}`, true},
+		{`{"fullDocument": {"score": [4.5], "ratio": ["NaN"]}}`, true},
+		{`{"fullDocument": {"big": [{"numeric": [">", 4294967296]}]}}`, true},
+		{`{"fullDocument": {"match": ["/^a.*z/i"]}}`, true},
+		{`{"fullDocument": {"code": ["return 1"], "scoped": ["return x"], "symbol": ["sym"]}}`, true},
+		{`{"fullDocument": {"pointer": {"$ref": ["shop.users"], "$id": ["655f2a1b0c9d8e7f60514233"]}}}`, true},
+		{`{"fullDocument": {"low": ["MinKey"], "high": ["MaxKey"]}}`, true},
+		{`{"fullDocument": {"ancient": [-70000000000000]}}`, true},
+		{`{"fullDocument": {"missing": [{"exists": false}], "amount": [{"exists": true}]}}`, true},
+		{`{"updateDescription": [{"exists": true}]}`, false},
+	} {
+		if got := matches(t, insert, test.pattern); got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	update := doc(
+		el(typeString, "operationType", str("update")),
+		el(typeDocument, "updateDescription", doc(
+			el(typeDocument, "updatedFields", doc(el(typeString, "status", str("shipped")))),
+			el(typeArray, "removedFields", doc(el(typeString, "0", str("note")))),
+		)),
+	)
+	if !matches(t, update, `{"updateDescription": {"updatedFields": {"status": ["shipped"]}, "removedFields": ["note"]}}`) {
+		t.Error("update didn't match")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	q, _ := quamina.New(quamina.WithFlattener(NewFlattener()))
+	_ = q.AddPattern("p", `{"fullDocument": {"items": {"sku": ["a"]}, "paid": [true], "match": ["x"], "scoped": ["x"], "pointer": {"$id": ["x"]}}}`)
+
+	for i := 0; i < len(insert); i++ {
+		if _, err := q.MatchesForEvent(insert[:i]); err == nil {
+			t.Errorf("%d bytes accepted", i)
+		}
+	}
+	// corrupting the bytes one after another mustn't make the Flattener panic
+	corrupt := bytes.Clone(insert)
+	for i := 4; i < len(corrupt); i++ {
+		corrupt[i] ^= byte(i)
+		_, _ = q.MatchesForEvent(corrupt)
+	}
+
+	nested := doc()
+	for i := 0; i < maxDepth+1; i++ {
+		nested = doc(el(typeArray, "0", nested))
+	}
+	for _, event := range [][]byte{
+		append(bytes.Clone(insert), 0),
+		doc(el(0x20, "fullDocument")),
+		doc(el(typeDocument, "fullDocument", doc(el(typeBool, "paid", []byte{2})))),
+		doc(el(typeDocument, "fullDocument", doc(el(typeString, "match", i32(2), []byte("xy"))))),
+		doc(el(typeDocument, "fullDocument", doc(el(typeString, "match", i32(-1))))),
+		doc(el(typeDocument, "fullDocument", doc(el(typeBinary, "match", i32(100), []byte{0})))),
+		doc(el(typeDocument, "fullDocument", doc(el(typeRegex, "match", []byte("abc"))))),
+		doc(el(typeDocument, "fullDocument", doc(el(typeArray, "items", nested)))),
+		{5, 0, 0, 0, 1},
+		{6, 0, 0, 0, typeNull, 0},
+	} {
+		if _, err := q.MatchesForEvent(event); err == nil {
+			t.Errorf("%v accepted", event)
+		}
+	}
+}
+
+func TestSkipped(t *testing.T) {
+	// values that no Pattern uses aren't read, so aren't checked
+	event := doc(
+		el(typeDocument, "other", doc(el(typeBool, "paid", []byte{2}))),
+		el(typeString, "operationType", str("insert")),
+	)
+	if !matches(t, event, `{"operationType": ["insert"]}`) {
+		t.Error("didn't match")
+	}
+}
+
+func TestCopy(t *testing.T) {
+	q, _ := quamina.New(quamina.WithFlattener(NewFlattener()))
+	_ = q.AddPattern("p", `{"fullDocument": {"items": {"qty": [5]}}}`)
+	matches, err := q.Copy().MatchesForEvent(insert)
+	if err != nil || len(matches) != 1 {
+		t.Errorf("copy matched %v, %v", matches, err)
+	}
+}
@@ -0,0 +1,113 @@
+package bson
+
+import (
+	"math/bits"
+	"strconv"
+
+	"quamina.net/go/quamina/v2/internal/flatten"
+)
+
+// Decimal128 is IEEE 754-2008's 128-bit decimal floating point, in its binary integer decimal encoding: a sign,
+// a 14-bit exponent, and a coefficient of up to 34 decimal digits
+const (
+	decimalBias          = 6176
+	decimalMaxDigits     = 34
+	decimalExponentShift = 49
+)
+
+// the high and low 64 bits of 10^34 - 1; larger coefficients are non-canonical, and read as 0
+const (
+	decimalMaxHigh = 0x1ed09bead87c0
+	decimalMaxLow  = 0x378d8e63ffffffff
+)
+
+// writeDecimal writes the Decimal128 whose high and low 64 bits are given, as a number in its string form, or
+// as a string if it's NaN or infinite
+func (f *Flattener) writeDecimal(sink flatten.Sink, high, low uint64) {
+	s, isNumber := formatDecimal(high, low, f.buf[:0])
+	f.buf = s
+	if isNumber {
+		sink.Number(string(s))
+	} else {
+		sink.String(string(s))
+	}
+}
+
+// formatDecimal appends the string form of a Decimal128, as the spec for converting it to a string gives,
+// to buf, and reports whether it's a number
+func formatDecimal(high, low uint64, buf []byte) ([]byte, bool) {
+	negative := high>>63 == 1
+	var exponent int
+	var coefficientHigh, coefficientLow uint64
+	switch {
+	case (high>>58)&0x1f == 0x1f:
+		return append(buf, "NaN"...), false
+	case (high>>58)&0x1f == 0x1e:
+		if negative {
+			return append(buf, "-Infinity"...), false
+		}
+		return append(buf, "Infinity"...), false
+	case (high>>61)&3 == 3:
+		// the coefficient has an implicit 100 prefix, which makes it too large to be canonical
+		exponent = int((high>>47)&0x3fff) - decimalBias
+	default:
+		exponent = int((high>>decimalExponentShift)&0x3fff) - decimalBias
+		coefficientHigh, coefficientLow = high&(1<<decimalExponentShift-1), low
+		if coefficientHigh > decimalMaxHigh || (coefficientHigh == decimalMaxHigh && coefficientLow > decimalMaxLow) {
+			coefficientHigh, coefficientLow = 0, 0
+		}
+	}
+
+	if negative {
+		buf = append(buf, '-')
+	}
+	var digitsBuf [decimalMaxDigits]byte
+	digits := appendCoefficient(digitsBuf[:0], coefficientHigh, coefficientLow)
+	adjusted := exponent + len(digits) - 1
+	switch {
+	case exponent <= 0 && adjusted >= -6:
+		if exponent == 0 {
+			return append(buf, digits...), true
+		}
+		point := len(digits) + exponent
+		if point <= 0 {
+			buf = append(buf, "0."...)
+			for ; point < 0; point++ {
+				buf = append(buf, '0')
+			}
+			return append(buf, digits...), true
+		}
+		buf = append(buf, digits[:point]...)
+		buf = append(buf, '.')
+		return append(buf, digits[point:]...), true
+	default:
+		buf = append(buf, digits[0])
+		if len(digits) > 1 {
+			buf = append(buf, '.')
+			buf = append(buf, digits[1:]...)
+		}
+		buf = append(buf, 'E')
+		if adjusted >= 0 {
+			buf = append(buf, '+')
+		}
+		return strconv.AppendInt(buf, int64(adjusted), 10), true
+	}
+}
+
+// appendCoefficient appends the decimal digits of the 128-bit coefficient whose high and low 64 bits are given
+func appendCoefficient(buf []byte, high, low uint64) []byte {
+	start := len(buf)
+	for {
+		var r uint64
+		high, r = high/10, high%10
+		low, r = bits.Div64(r, low, 10)
+		buf = append(buf, byte('0'+r))
+		if high == 0 && low == 0 {
+			break
+		}
+	}
+	for i, j := start, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return buf
+}
@@ -0,0 +1,66 @@
+package bson
+
+import "testing"
+
+// decimal returns the high and low 64 bits of the Decimal128 coefficient × 10^exponent
+func decimal(negative bool, coefficient uint64, exponent int) (uint64, uint64) {
+	high := uint64(exponent+decimalBias) << decimalExponentShift
+	if negative {
+		high |= 1 << 63
+	}
+	return high, coefficient
+}
+
+func TestFormatDecimal(t *testing.T) {
+	for _, test := range []struct {
+		negative    bool
+		coefficient uint64
+		exponent    int
+		want        string
+	}{
+		{false, 0, 0, "0"},
+		{true, 0, 0, "-0"},
+		{false, 0, -1, "0.0"},
+		{false, 0, 3, "0E+3"},
+		{false, 1, 0, "1"},
+		{true, 1, 0, "-1"},
+		{false, 1, -1, "0.1"},
+		{false, 12345, -2, "123.45"},
+		{false, 1234, -6, "0.001234"},
+		{false, 1234, -10, "1.234E-7"},
+		{false, 10, -8, "1.0E-7"},
+		{false, 123, 1, "1.23E+3"},
+		{false, 15, 2, "1.5E+3"},
+		{true, 125050, -2, "-1250.50"},
+		{false, 1, 6111, "1E+6111"},
+		{false, 1, -6176, "1E-6176"},
+	} {
+		high, low := decimal(test.negative, test.coefficient, test.exponent)
+		got, isNumber := formatDecimal(high, low, nil)
+		if string(got) != test.want || !isNumber {
+			t.Errorf("%d E %d: got %s, %t", test.coefficient, test.exponent, got, isNumber)
+		}
+	}
+
+	for _, test := range []struct {
+		high, low uint64
+		want      string
+		isNumber  bool
+	}{
+		{decimalMaxHigh | uint64(decimalBias)<<decimalExponentShift, decimalMaxLow, "9999999999999999999999999999999999", true},
+		// one more than the largest coefficient is non-canonical, so 0
+		{decimalMaxHigh | uint64(decimalBias)<<decimalExponentShift, decimalMaxLow + 1, "0", true},
+		// as are coefficients with the implicit prefix
+		{0x6000000000000000, 0, "0E-6176", true},
+		{0x7c00000000000000, 0, "NaN", false},
+		{0xfc00000000000000, 0, "NaN", false},
+		{0x7e00000000000000, 0, "NaN", false},
+		{0x7800000000000000, 0, "Infinity", false},
+		{0xf800000000000000, 0, "-Infinity", false},
+	} {
+		got, isNumber := formatDecimal(test.high, test.low, nil)
+		if string(got) != test.want || isNumber != test.isNumber {
+			t.Errorf("%x %x: got %s, %t", test.high, test.low, got, isNumber)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Command libquamina is built as a C library, so that programs in C, C++, Rust, Python, and other languages
+// with a C foreign-function interface can embed Quamina rather than running it in a sidecar:
+//
+//	go build -buildmode=c-shared -o libquamina.so ./cmd/libquamina
+//	go build -buildmode=c-archive -o libquamina.a ./cmd/libquamina
+//
+// Either also writes libquamina.h, which declares
+//
+//	uint32_t quamina_abi_version(void);
+//	int quamina_new(uint32_t flags, uint64_t* q, char** message);
+//	int quamina_copy(uint64_t q, uint64_t* copy, char** message);
+//	int quamina_release(uint64_t q, char** message);
+//	int quamina_add_pattern(uint64_t q, char* x, char* pattern, size_t patternLen, char** message);
+//	int quamina_delete_patterns(uint64_t q, char* x, char** message);
+//	int quamina_matches_for_event(uint64_t q, char* event, size_t eventLen, char*** matches, size_t* count, char** message);
+//	void quamina_free_matches(char** matches, size_t count);
+//	void quamina_free(void* p);
+//
+// These are the functions of package ffi, whose comment describes handles, the error codes, and the
+// guarantees about threads, and the header defines its Flags and Codes, as QUAMINA_PATTERN_DELETION,
+// QUAMINA_BAD_PATTERN, and so on. Each function returns QUAMINA_OK or the code of the error. X values are
+// NUL-terminated strings, and Patterns and events are bytes with a length.
+//
+// Ownership: the library reads what it's passed only during the call. What it returns is the caller's: on
+// failure, if message isn't NULL, *message is set to a description of the error, to be freed with
+// quamina_free; on success, it's set to NULL. The matches are an array of strings, freed with
+// quamina_free_matches. Each handle is released with quamina_release once it's no longer needed.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+
+#define QUAMINA_PATTERN_DELETION 1
+#define QUAMINA_EVENTBRIDGE 2
+
+#define QUAMINA_OK 0
+#define QUAMINA_INVALID_HANDLE 1
+#define QUAMINA_INVALID_ARGUMENT 2
+#define QUAMINA_BAD_PATTERN 3
+#define QUAMINA_BAD_EVENT 4
+#define QUAMINA_MEMORY_BUDGET_EXCEEDED 5
+#define QUAMINA_UNSUPPORTED 6
+#define QUAMINA_INTERNAL 7
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"quamina.net/go/quamina/v2/ffi"
+)
+
+// main is required by -buildmode=c-shared and c-archive, but never called
+func main() {}
+
+//export quamina_abi_version
+func quamina_abi_version() C.uint32_t {
+	return ffi.ABIVersion
+}
+
+//export quamina_new
+func quamina_new(flags C.uint32_t, q *C.uint64_t, message **C.char) C.int {
+	if q == nil {
+		return result(errNilPointer, message)
+	}
+	h, err := ffi.New(ffi.Flags(flags))
+	*q = C.uint64_t(h)
+	return result(err, message)
+}
+
+//export quamina_copy
+func quamina_copy(q C.uint64_t, copied *C.uint64_t, message **C.char) C.int {
+	if copied == nil {
+		return result(errNilPointer, message)
+	}
+	h, err := ffi.Copy(ffi.Handle(q))
+	*copied = C.uint64_t(h)
+	return result(err, message)
+}
+
+//export quamina_release
+func quamina_release(q C.uint64_t, message **C.char) C.int {
+	return result(ffi.Release(ffi.Handle(q)), message)
+}
+
+//export quamina_add_pattern
+func quamina_add_pattern(q C.uint64_t, x *C.char, pattern *C.char, patternLen C.size_t, message **C.char) C.int {
+	if x == nil || pattern == nil && patternLen > 0 {
+		return result(errNilPointer, message)
+	}
+	return result(ffi.AddPattern(ffi.Handle(q), C.GoString(x), bytes(pattern, patternLen)), message)
+}
+
+//export quamina_delete_patterns
+func quamina_delete_patterns(q C.uint64_t, x *C.char, message **C.char) C.int {
+	if x == nil {
+		return result(errNilPointer, message)
+	}
+	return result(ffi.DeletePatterns(ffi.Handle(q), C.GoString(x)), message)
+}
+
+//export quamina_matches_for_event
+func quamina_matches_for_event(q C.uint64_t, event *C.char, eventLen C.size_t, matches ***C.char, count *C.size_t, message **C.char) C.int {
+	if matches == nil || count == nil || event == nil && eventLen > 0 {
+		return result(errNilPointer, message)
+	}
+	*matches, *count = nil, 0
+	xs, err := ffi.MatchesForEvent(ffi.Handle(q), bytes(event, eventLen))
+	if err != nil || len(xs) == 0 {
+		return result(err, message)
+	}
+	array := unsafe.Slice((**C.char)(C.malloc(C.size_t(len(xs))*C.size_t(unsafe.Sizeof((*C.char)(nil))))), len(xs))
+	for i, x := range xs {
+		array[i] = C.CString(x)
+	}
+	*matches, *count = &array[0], C.size_t(len(xs))
+	return result(nil, message)
+}
+
+//export quamina_free_matches
+func quamina_free_matches(matches **C.char, count C.size_t) {
+	if matches == nil {
+		return
+	}
+	for _, match := range unsafe.Slice(matches, count) {
+		C.free(unsafe.Pointer(match))
+	}
+	C.free(unsafe.Pointer(matches))
+}
+
+//export quamina_free
+func quamina_free(p unsafe.Pointer) {
+	C.free(p)
+}
+
+var errNilPointer = &ffi.Error{Code: ffi.InvalidArgument, Message: "NULL pointer"}
+
+// bytes returns the C bytes at p as a slice without copying them, which is safe because ffi doesn't keep them
+func bytes(p *C.char, n C.size_t) []byte {
+	if n == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(p)), n)
+}
+
+// result returns err's code, and stores its message in *message if message isn't NULL
+func result(err error, message **C.char) C.int {
+	if message != nil {
+		*message = nil
+		if err != nil {
+			*message = C.CString(err.Error())
+		}
+	}
+	return C.int(ffi.CodeOf(err))
+}
@@ -0,0 +1,105 @@
+//go:build cgo
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2/ffi"
+)
+
+// TestC builds the library and a C program using it, and checks the program's output
+func TestC(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a shared library")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	dir := t.TempDir()
+	build := func(name string, args ...string) {
+		t.Helper()
+		out, err := exec.Command(name, args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s: %v\n%s", name, err, out)
+		}
+	}
+	build("go", "build", "-buildmode=c-shared", "-o", filepath.Join(dir, "libquamina.so"), ".")
+	checkHeader(t, filepath.Join(dir, "libquamina.h"))
+	example := filepath.Join(dir, "example")
+	build(cc, "-o", example, filepath.Join("testdata", "example.c"), "-I", dir, "-L", dir, "-lquamina")
+
+	cmd := exec.Command(example)
+	cmd.Env = append(os.Environ(), "LD_LIBRARY_PATH="+dir, "DYLD_LIBRARY_PATH="+dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("example: %v\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	want := []string{
+		"abi 1",
+		"error 3: ",
+		"1 prefix",
+		"0",
+		"error 4: ",
+		"1 exact",
+		"error 1: ",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("example printed %q", out)
+	}
+	for i, line := range lines {
+		// the messages are Quamina's, so only their codes are checked
+		if !strings.HasPrefix(line, want[i]) || !strings.HasPrefix(want[i], "error") && line != want[i] {
+			t.Errorf("line %d is %q, wanted %q", i+1, line, want[i])
+		}
+	}
+}
+
+// checkHeader checks that the header's codes are ffi's
+func checkHeader(t *testing.T, name string) {
+	t.Helper()
+	codes := map[string]ffi.Code{
+		"QUAMINA_OK":                     ffi.OK,
+		"QUAMINA_INVALID_HANDLE":         ffi.InvalidHandle,
+		"QUAMINA_INVALID_ARGUMENT":       ffi.InvalidArgument,
+		"QUAMINA_BAD_PATTERN":            ffi.BadPattern,
+		"QUAMINA_BAD_EVENT":              ffi.BadEvent,
+		"QUAMINA_MEMORY_BUDGET_EXCEEDED": ffi.MemoryBudgetExceeded,
+		"QUAMINA_UNSUPPORTED":            ffi.Unsupported,
+		"QUAMINA_INTERNAL":               ffi.Internal,
+		"QUAMINA_PATTERN_DELETION":       ffi.Code(ffi.PatternDeletion),
+		"QUAMINA_EVENTBRIDGE":            ffi.Code(ffi.EventBridge),
+	}
+	header, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer header.Close()
+	scanner := bufio.NewScanner(header)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[0] != "#define" || !strings.HasPrefix(fields[1], "QUAMINA_") {
+			continue
+		}
+		want, ok := codes[fields[1]]
+		if !ok {
+			t.Errorf("%s isn't known to the test", fields[1])
+			continue
+		}
+		if got, err := strconv.Atoi(fields[2]); err != nil || ffi.Code(got) != want {
+			t.Errorf("%s is %s, but ffi's is %d", fields[1], fields[2], want)
+		}
+		delete(codes, fields[1])
+	}
+	for name := range codes {
+		t.Errorf("the header doesn't define %s", name)
+	}
+}
@@ -0,0 +1,56 @@
+// Exercises the library and prints the results, as TestC expects.
+#include <stdio.h>
+#include <string.h>
+#include "libquamina.h"
+
+static int check(int code, char *message) {
+	if (code == QUAMINA_OK) {
+		return 0;
+	}
+	printf("error %d: %s\n", code, message);
+	quamina_free(message);
+	return 1;
+}
+
+static void add(uint64_t q, char *x, char *pattern) {
+	char *message;
+	check(quamina_add_pattern(q, x, pattern, strlen(pattern), &message), message);
+}
+
+static void match(uint64_t q, char *event) {
+	char **matches, *message;
+	size_t count;
+	if (check(quamina_matches_for_event(q, event, strlen(event), &matches, &count, &message), message)) {
+		return;
+	}
+	printf("%zu", count);
+	for (size_t i = 0; i < count; i++) {
+		printf(" %s", matches[i]);
+	}
+	printf("\n");
+	quamina_free_matches(matches, count);
+}
+
+int main(void) {
+	char *message;
+	uint64_t q, copy;
+	printf("abi %u\n", quamina_abi_version());
+	if (check(quamina_new(QUAMINA_PATTERN_DELETION, &q, &message), message)) {
+		return 1;
+	}
+	add(q, "exact", "{\"status\": [\"failed\"]}");
+	add(q, "prefix", "{\"status\": [{\"prefix\": \"fail\"}]}");
+	add(q, "bad", "{");
+
+	check(quamina_copy(q, &copy, &message), message);
+	match(copy, "{\"status\": \"failing\"}");
+	match(q, "{\"status\": \"ok\"}");
+	match(q, "not JSON");
+	check(quamina_delete_patterns(q, "prefix", &message), message);
+	check(quamina_release(q, NULL), NULL);
+	match(copy, "{\"status\": \"failed\"}");
+	match(q, "{\"status\": \"failed\"}");
+
+	check(quamina_release(copy, NULL), NULL);
+	return 0;
+}
@@ -0,0 +1,19 @@
+module quamina.net/go/quamina/v2/cmd/quamina-grpc
+
+go 1.23
+
+require (
+	google.golang.org/grpc v1.72.0
+	google.golang.org/protobuf v1.36.9
+	quamina.net/go/quamina/v2 v2.0.0
+)
+
+require (
+	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250218202821-56aae31c358a // indirect
+)
+
+// develop against the Quamina in this repository
+replace quamina.net/go/quamina/v2 => ../..
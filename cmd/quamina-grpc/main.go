@@ -0,0 +1,58 @@
+// Command quamina-grpc serves a Quamina instance over gRPC, with the service defined in quamina.proto, so that
+// services not written in Go can run Quamina as a sidecar:
+//
+//	quamina-grpc [-addr host:port]
+//
+// listens on -addr, by default localhost:50051. Each RPC calls the Quamina method of the same name, with the
+// Patterns' string ids as their X values, and all the callers share one instance, created
+// WithPatternDeletion(true). MatchStream matches a stream of Events, replying to each in order.
+//
+// The code in quaminapb is generated from quamina.proto by protoc, with the protoc-gen-go and
+// protoc-gen-go-grpc plugins; go generate regenerates it.
+package main
+
+//go:generate protoc --go_out=. --go_opt=module=quamina.net/go/quamina/v2/cmd/quamina-grpc --go-grpc_out=. --go-grpc_opt=module=quamina.net/go/quamina/v2/cmd/quamina-grpc quamina.proto
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/cmd/quamina-grpc/quaminapb"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("quamina-grpc", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	addr := flags.String("addr", "localhost:50051", "address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintln(stderr, "quamina-grpc:", err)
+		return 1
+	}
+
+	q, err := quamina.New(quamina.WithPatternDeletion(true))
+	if err != nil {
+		return fail(err)
+	}
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fail(err)
+	}
+	s := grpc.NewServer()
+	quaminapb.RegisterQuaminaServer(s, newServer(q))
+	if err := s.Serve(listener); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,398 @@
+// The Quamina matching service, for running Quamina as a sidecar to services not written in Go. Pattern
+// identifiers are strings, since gRPC can't carry the arbitrary Go values that Quamina's X type allows.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: quamina.proto
+
+package quaminapb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AddPatternRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// The Pattern, as the JSON text AddPattern takes.
+	Pattern       string `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddPatternRequest) Reset() {
+	*x = AddPatternRequest{}
+	mi := &file_quamina_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddPatternRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddPatternRequest) ProtoMessage() {}
+
+func (x *AddPatternRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quamina_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddPatternRequest.ProtoReflect.Descriptor instead.
+func (*AddPatternRequest) Descriptor() ([]byte, []int) {
+	return file_quamina_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AddPatternRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AddPatternRequest) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
+}
+
+type AddPatternResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddPatternResponse) Reset() {
+	*x = AddPatternResponse{}
+	mi := &file_quamina_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddPatternResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddPatternResponse) ProtoMessage() {}
+
+func (x *AddPatternResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_quamina_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddPatternResponse.ProtoReflect.Descriptor instead.
+func (*AddPatternResponse) Descriptor() ([]byte, []int) {
+	return file_quamina_proto_rawDescGZIP(), []int{1}
+}
+
+type DeletePatternsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePatternsRequest) Reset() {
+	*x = DeletePatternsRequest{}
+	mi := &file_quamina_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePatternsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePatternsRequest) ProtoMessage() {}
+
+func (x *DeletePatternsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quamina_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePatternsRequest.ProtoReflect.Descriptor instead.
+func (*DeletePatternsRequest) Descriptor() ([]byte, []int) {
+	return file_quamina_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DeletePatternsRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeletePatternsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeletePatternsResponse) Reset() {
+	*x = DeletePatternsResponse{}
+	mi := &file_quamina_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeletePatternsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeletePatternsResponse) ProtoMessage() {}
+
+func (x *DeletePatternsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_quamina_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeletePatternsResponse.ProtoReflect.Descriptor instead.
+func (*DeletePatternsResponse) Descriptor() ([]byte, []int) {
+	return file_quamina_proto_rawDescGZIP(), []int{3}
+}
+
+type MatchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The Event, as the JSON text MatchesForEvent takes.
+	Event []byte `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	// Echoed in the response, so streaming clients can pair them up.
+	Sequence      uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchRequest) Reset() {
+	*x = MatchRequest{}
+	mi := &file_quamina_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchRequest) ProtoMessage() {}
+
+func (x *MatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quamina_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchRequest.ProtoReflect.Descriptor instead.
+func (*MatchRequest) Descriptor() ([]byte, []int) {
+	return file_quamina_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MatchRequest) GetEvent() []byte {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *MatchRequest) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+type MatchResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Ids      []string               `protobuf:"bytes,1,rep,name=ids,proto3" json:"ids,omitempty"`
+	Sequence uint64                 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// Set, instead of ids, if the Event couldn't be matched, for example because it isn't valid JSON. On a
+	// stream, an error for one Event doesn't end the stream.
+	Error         string `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MatchResponse) Reset() {
+	*x = MatchResponse{}
+	mi := &file_quamina_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatchResponse) ProtoMessage() {}
+
+func (x *MatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_quamina_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatchResponse.ProtoReflect.Descriptor instead.
+func (*MatchResponse) Descriptor() ([]byte, []int) {
+	return file_quamina_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MatchResponse) GetIds() []string {
+	if x != nil {
+		return x.Ids
+	}
+	return nil
+}
+
+func (x *MatchResponse) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *MatchResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_quamina_proto protoreflect.FileDescriptor
+
+const file_quamina_proto_rawDesc = "" +
+	"\n" +
+	"\rquamina.proto\x12\n" +
+	"quamina.v1\"=\n" +
+	"\x11AddPatternRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\apattern\x18\x02 \x01(\tR\apattern\"\x14\n" +
+	"\x12AddPatternResponse\"'\n" +
+	"\x15DeletePatternsRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x18\n" +
+	"\x16DeletePatternsResponse\"@\n" +
+	"\fMatchRequest\x12\x14\n" +
+	"\x05event\x18\x01 \x01(\fR\x05event\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\"S\n" +
+	"\rMatchResponse\x12\x10\n" +
+	"\x03ids\x18\x01 \x03(\tR\x03ids\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error2\xbf\x02\n" +
+	"\aQuamina\x12K\n" +
+	"\n" +
+	"AddPattern\x12\x1d.quamina.v1.AddPatternRequest\x1a\x1e.quamina.v1.AddPatternResponse\x12W\n" +
+	"\x0eDeletePatterns\x12!.quamina.v1.DeletePatternsRequest\x1a\".quamina.v1.DeletePatternsResponse\x12F\n" +
+	"\x0fMatchesForEvent\x12\x18.quamina.v1.MatchRequest\x1a\x19.quamina.v1.MatchResponse\x12F\n" +
+	"\vMatchStream\x12\x18.quamina.v1.MatchRequest\x1a\x19.quamina.v1.MatchResponse(\x010\x01B6Z4quamina.net/go/quamina/v2/cmd/quamina-grpc/quaminapbb\x06proto3"
+
+var (
+	file_quamina_proto_rawDescOnce sync.Once
+	file_quamina_proto_rawDescData []byte
+)
+
+func file_quamina_proto_rawDescGZIP() []byte {
+	file_quamina_proto_rawDescOnce.Do(func() {
+		file_quamina_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_quamina_proto_rawDesc), len(file_quamina_proto_rawDesc)))
+	})
+	return file_quamina_proto_rawDescData
+}
+
+var file_quamina_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_quamina_proto_goTypes = []any{
+	(*AddPatternRequest)(nil),      // 0: quamina.v1.AddPatternRequest
+	(*AddPatternResponse)(nil),     // 1: quamina.v1.AddPatternResponse
+	(*DeletePatternsRequest)(nil),  // 2: quamina.v1.DeletePatternsRequest
+	(*DeletePatternsResponse)(nil), // 3: quamina.v1.DeletePatternsResponse
+	(*MatchRequest)(nil),           // 4: quamina.v1.MatchRequest
+	(*MatchResponse)(nil),          // 5: quamina.v1.MatchResponse
+}
+var file_quamina_proto_depIdxs = []int32{
+	0, // 0: quamina.v1.Quamina.AddPattern:input_type -> quamina.v1.AddPatternRequest
+	2, // 1: quamina.v1.Quamina.DeletePatterns:input_type -> quamina.v1.DeletePatternsRequest
+	4, // 2: quamina.v1.Quamina.MatchesForEvent:input_type -> quamina.v1.MatchRequest
+	4, // 3: quamina.v1.Quamina.MatchStream:input_type -> quamina.v1.MatchRequest
+	1, // 4: quamina.v1.Quamina.AddPattern:output_type -> quamina.v1.AddPatternResponse
+	3, // 5: quamina.v1.Quamina.DeletePatterns:output_type -> quamina.v1.DeletePatternsResponse
+	5, // 6: quamina.v1.Quamina.MatchesForEvent:output_type -> quamina.v1.MatchResponse
+	5, // 7: quamina.v1.Quamina.MatchStream:output_type -> quamina.v1.MatchResponse
+	4, // [4:8] is the sub-list for method output_type
+	0, // [0:4] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_quamina_proto_init() }
+func file_quamina_proto_init() {
+	if File_quamina_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_quamina_proto_rawDesc), len(file_quamina_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_quamina_proto_goTypes,
+		DependencyIndexes: file_quamina_proto_depIdxs,
+		MessageInfos:      file_quamina_proto_msgTypes,
+	}.Build()
+	File_quamina_proto = out.File
+	file_quamina_proto_goTypes = nil
+	file_quamina_proto_depIdxs = nil
+}
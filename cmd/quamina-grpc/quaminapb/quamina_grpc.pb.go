@@ -0,0 +1,243 @@
+// The Quamina matching service, for running Quamina as a sidecar to services not written in Go. Pattern
+// identifiers are strings, since gRPC can't carry the arbitrary Go values that Quamina's X type allows.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: quamina.proto
+
+package quaminapb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Quamina_AddPattern_FullMethodName      = "/quamina.v1.Quamina/AddPattern"
+	Quamina_DeletePatterns_FullMethodName  = "/quamina.v1.Quamina/DeletePatterns"
+	Quamina_MatchesForEvent_FullMethodName = "/quamina.v1.Quamina/MatchesForEvent"
+	Quamina_MatchStream_FullMethodName     = "/quamina.v1.Quamina/MatchStream"
+)
+
+// QuaminaClient is the client API for Quamina service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type QuaminaClient interface {
+	// AddPattern adds a Pattern, identified by id; several Patterns may share an id.
+	AddPattern(ctx context.Context, in *AddPatternRequest, opts ...grpc.CallOption) (*AddPatternResponse, error)
+	// DeletePatterns removes all the Patterns added with the id.
+	DeletePatterns(ctx context.Context, in *DeletePatternsRequest, opts ...grpc.CallOption) (*DeletePatternsResponse, error)
+	// MatchesForEvent returns the ids of the Patterns that match one Event.
+	MatchesForEvent(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error)
+	// MatchStream matches each Event sent and replies to each in order, so that a client with a steady flow
+	// of Events doesn't pay for a call per Event.
+	MatchStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[MatchRequest, MatchResponse], error)
+}
+
+type quaminaClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewQuaminaClient(cc grpc.ClientConnInterface) QuaminaClient {
+	return &quaminaClient{cc}
+}
+
+func (c *quaminaClient) AddPattern(ctx context.Context, in *AddPatternRequest, opts ...grpc.CallOption) (*AddPatternResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddPatternResponse)
+	err := c.cc.Invoke(ctx, Quamina_AddPattern_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quaminaClient) DeletePatterns(ctx context.Context, in *DeletePatternsRequest, opts ...grpc.CallOption) (*DeletePatternsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletePatternsResponse)
+	err := c.cc.Invoke(ctx, Quamina_DeletePatterns_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quaminaClient) MatchesForEvent(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MatchResponse)
+	err := c.cc.Invoke(ctx, Quamina_MatchesForEvent_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *quaminaClient) MatchStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[MatchRequest, MatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Quamina_ServiceDesc.Streams[0], Quamina_MatchStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[MatchRequest, MatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Quamina_MatchStreamClient = grpc.BidiStreamingClient[MatchRequest, MatchResponse]
+
+// QuaminaServer is the server API for Quamina service.
+// All implementations must embed UnimplementedQuaminaServer
+// for forward compatibility.
+type QuaminaServer interface {
+	// AddPattern adds a Pattern, identified by id; several Patterns may share an id.
+	AddPattern(context.Context, *AddPatternRequest) (*AddPatternResponse, error)
+	// DeletePatterns removes all the Patterns added with the id.
+	DeletePatterns(context.Context, *DeletePatternsRequest) (*DeletePatternsResponse, error)
+	// MatchesForEvent returns the ids of the Patterns that match one Event.
+	MatchesForEvent(context.Context, *MatchRequest) (*MatchResponse, error)
+	// MatchStream matches each Event sent and replies to each in order, so that a client with a steady flow
+	// of Events doesn't pay for a call per Event.
+	MatchStream(grpc.BidiStreamingServer[MatchRequest, MatchResponse]) error
+	mustEmbedUnimplementedQuaminaServer()
+}
+
+// UnimplementedQuaminaServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedQuaminaServer struct{}
+
+func (UnimplementedQuaminaServer) AddPattern(context.Context, *AddPatternRequest) (*AddPatternResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPattern not implemented")
+}
+func (UnimplementedQuaminaServer) DeletePatterns(context.Context, *DeletePatternsRequest) (*DeletePatternsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeletePatterns not implemented")
+}
+func (UnimplementedQuaminaServer) MatchesForEvent(context.Context, *MatchRequest) (*MatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MatchesForEvent not implemented")
+}
+func (UnimplementedQuaminaServer) MatchStream(grpc.BidiStreamingServer[MatchRequest, MatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method MatchStream not implemented")
+}
+func (UnimplementedQuaminaServer) mustEmbedUnimplementedQuaminaServer() {}
+func (UnimplementedQuaminaServer) testEmbeddedByValue()                 {}
+
+// UnsafeQuaminaServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to QuaminaServer will
+// result in compilation errors.
+type UnsafeQuaminaServer interface {
+	mustEmbedUnimplementedQuaminaServer()
+}
+
+func RegisterQuaminaServer(s grpc.ServiceRegistrar, srv QuaminaServer) {
+	// If the following call pancis, it indicates UnimplementedQuaminaServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Quamina_ServiceDesc, srv)
+}
+
+func _Quamina_AddPattern_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPatternRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuaminaServer).AddPattern(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Quamina_AddPattern_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuaminaServer).AddPattern(ctx, req.(*AddPatternRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Quamina_DeletePatterns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletePatternsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuaminaServer).DeletePatterns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Quamina_DeletePatterns_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuaminaServer).DeletePatterns(ctx, req.(*DeletePatternsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Quamina_MatchesForEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(QuaminaServer).MatchesForEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Quamina_MatchesForEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(QuaminaServer).MatchesForEvent(ctx, req.(*MatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Quamina_MatchStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(QuaminaServer).MatchStream(&grpc.GenericServerStream[MatchRequest, MatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Quamina_MatchStreamServer = grpc.BidiStreamingServer[MatchRequest, MatchResponse]
+
+// Quamina_ServiceDesc is the grpc.ServiceDesc for Quamina service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Quamina_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "quamina.v1.Quamina",
+	HandlerType: (*QuaminaServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AddPattern",
+			Handler:    _Quamina_AddPattern_Handler,
+		},
+		{
+			MethodName: "DeletePatterns",
+			Handler:    _Quamina_DeletePatterns_Handler,
+		},
+		{
+			MethodName: "MatchesForEvent",
+			Handler:    _Quamina_MatchesForEvent_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "MatchStream",
+			Handler:       _Quamina_MatchStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "quamina.proto",
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/cmd/quamina-grpc/quaminapb"
+)
+
+// server serves a Quamina instance over gRPC. A Quamina instance can't be used by several goroutines at once,
+// so each call, and each stream, uses a copy made with Copy; the copies share the Patterns, so a Pattern added
+// by one call is seen by all those that follow.
+type server struct {
+	quaminapb.UnimplementedQuaminaServer
+
+	copies sync.Pool
+	// AddPattern and DeletePatterns are made one at a time
+	writeLock sync.Mutex
+}
+
+// newServer returns a server for q, which shouldn't be used elsewhere afterward except through copies.
+func newServer(q *quamina.Quamina) *server {
+	s := &server{}
+	s.copies.New = func() any { return q.Copy() }
+	return s
+}
+
+func (s *server) AddPattern(_ context.Context, req *quaminapb.AddPatternRequest) (*quaminapb.AddPatternResponse, error) {
+	q := s.copies.Get().(*quamina.Quamina)
+	defer s.copies.Put(q)
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if err := q.AddPattern(req.GetId(), req.GetPattern()); err != nil {
+		code := codes.InvalidArgument
+		if errors.Is(err, quamina.ErrMemoryBudgetExceeded) {
+			code = codes.ResourceExhausted
+		}
+		return nil, status.Error(code, err.Error())
+	}
+	return &quaminapb.AddPatternResponse{}, nil
+}
+
+func (s *server) DeletePatterns(_ context.Context, req *quaminapb.DeletePatternsRequest) (*quaminapb.DeletePatternsResponse, error) {
+	q := s.copies.Get().(*quamina.Quamina)
+	defer s.copies.Put(q)
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if err := q.DeletePatterns(req.GetId()); err != nil {
+		code := codes.Internal
+		if errors.Is(err, quamina.ErrDeletionNotSupported) {
+			code = codes.Unimplemented
+		}
+		return nil, status.Error(code, err.Error())
+	}
+	return &quaminapb.DeletePatternsResponse{}, nil
+}
+
+func (s *server) MatchesForEvent(_ context.Context, req *quaminapb.MatchRequest) (*quaminapb.MatchResponse, error) {
+	q := s.copies.Get().(*quamina.Quamina)
+	defer s.copies.Put(q)
+	return matchOne(q, req), nil
+}
+
+// MatchStream replies to each Event in the order they arrive, until the client closes its side of the stream.
+func (s *server) MatchStream(stream quaminapb.Quamina_MatchStreamServer) error {
+	q := s.copies.Get().(*quamina.Quamina)
+	defer s.copies.Put(q)
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(matchOne(q, req)); err != nil {
+			return err
+		}
+	}
+}
+
+// matchOne matches an Event. Events that can't be matched are reported in the response rather than as errors,
+// so that one bad Event doesn't end a stream. The server adds Patterns with string X values, but the instance
+// it was given may already have had others, which are reported as fmt.Sprint formats them. Quamina reports
+// matches in no particular order, so they're sorted to make responses repeatable.
+func matchOne(q *quamina.Quamina, req *quaminapb.MatchRequest) *quaminapb.MatchResponse {
+	resp := &quaminapb.MatchResponse{Sequence: req.GetSequence()}
+	matches, err := q.MatchesForEvent(req.GetEvent())
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.Ids = make([]string, len(matches))
+	for i, x := range matches {
+		if s, ok := x.(string); ok {
+			resp.Ids[i] = s
+		} else {
+			resp.Ids[i] = fmt.Sprint(x)
+		}
+	}
+	slices.Sort(resp.Ids)
+	return resp
+}
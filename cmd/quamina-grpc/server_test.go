@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/cmd/quamina-grpc/quaminapb"
+)
+
+// newTestClient serves q over an in-memory connection and returns a client for it
+func newTestClient(t *testing.T, q *quamina.Quamina) quaminapb.QuaminaClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	quaminapb.RegisterQuaminaServer(s, newServer(q))
+	go func() { _ = s.Serve(listener) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return quaminapb.NewQuaminaClient(conn)
+}
+
+func newTestQuamina(t *testing.T, opts ...quamina.Option) *quamina.Quamina {
+	t.Helper()
+	q, err := quamina.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func addPatterns(t *testing.T, client quaminapb.QuaminaClient, patterns map[string]string) {
+	t.Helper()
+	for id, pattern := range patterns {
+		if _, err := client.AddPattern(context.Background(), &quaminapb.AddPatternRequest{Id: id, Pattern: pattern}); err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+	}
+}
+
+func TestAddPatternAndMatch(t *testing.T) {
+	q := newTestQuamina(t)
+	// Patterns already in the instance, whatever their X values, are reported too
+	if err := q.AddPattern(7, `{"a": [1]}`); err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t, q)
+	addPatterns(t, client, map[string]string{
+		"one":   `{"a": [1]}`,
+		"other": `{"b": ["x"]}`,
+		"zed":   `{"a": [{"numeric": [">", 0]}]}`,
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		event string
+		want  []string
+		err   bool
+	}{
+		{`{"a": 1}`, []string{"7", "one", "zed"}, false},
+		{`{"b": "x"}`, []string{"other"}, false},
+		{`{"c": 1}`, []string{}, false},
+		{`{"a": `, nil, true},
+	}
+	for i, test := range tests {
+		resp, err := client.MatchesForEvent(ctx, &quaminapb.MatchRequest{Event: []byte(test.event), Sequence: uint64(i)})
+		if err != nil {
+			t.Fatalf("%s: %v", test.event, err)
+		}
+		if (resp.GetError() != "") != test.err || !slices.Equal(resp.GetIds(), test.want) || resp.GetSequence() != uint64(i) {
+			t.Errorf("%s: %v", test.event, resp)
+		}
+	}
+
+	_, err := client.AddPattern(ctx, &quaminapb.AddPatternRequest{Id: "bad", Pattern: `{"a": 1}`})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("bad pattern: %v", err)
+	}
+}
+
+func TestAddPatternOverBudget(t *testing.T) {
+	budget, _ := quamina.NewMemoryBudget(1)
+	client := newTestClient(t, newTestQuamina(t, quamina.WithMemoryBudget(budget)))
+	// a budget can be exceeded by the last Pattern added, so it's the second that fails
+	addPatterns(t, client, map[string]string{"a": `{"a": ["b"]}`})
+	_, err := client.AddPattern(context.Background(), &quaminapb.AddPatternRequest{Id: "b", Pattern: `{"b": ["c"]}`})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("over budget: %v", err)
+	}
+}
+
+func TestDeletePatterns(t *testing.T) {
+	client := newTestClient(t, newTestQuamina(t, quamina.WithPatternDeletion(true)))
+	addPatterns(t, client, map[string]string{"one": `{"a": [1]}`, "two": `{"a": [1, 2]}`})
+	ctx := context.Background()
+	if _, err := client.DeletePatterns(ctx, &quaminapb.DeletePatternsRequest{Id: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.MatchesForEvent(ctx, &quaminapb.MatchRequest{Event: []byte(`{"a": 1}`)})
+	if err != nil || !slices.Equal(resp.GetIds(), []string{"two"}) {
+		t.Errorf("after delete: %v, %v", resp, err)
+	}
+
+	// an instance without deletion can't
+	client = newTestClient(t, newTestQuamina(t))
+	_, err = client.DeletePatterns(ctx, &quaminapb.DeletePatternsRequest{Id: "one"})
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("delete without deletion: %v", err)
+	}
+}
+
+func TestMatchStream(t *testing.T) {
+	client := newTestClient(t, newTestQuamina(t))
+	addPatterns(t, client, map[string]string{"one": `{"a": [1]}`, "two": `{"a": [2]}`})
+	stream, err := client.MatchStream(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []string{`{"a": 1}`, `not json`, `{"a": 2}`, `{"a": 3}`}
+	wants := [][]string{{"one"}, nil, {"two"}, {}}
+	for i, event := range events {
+		if err := stream.Send(&quaminapb.MatchRequest{Event: []byte(event), Sequence: uint64(100 + i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range wants {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("response %d: %v", i, err)
+		}
+		if resp.GetSequence() != uint64(100+i) || !slices.Equal(resp.GetIds(), want) || (resp.GetError() != "") != (want == nil) {
+			t.Errorf("response %d: %v", i, resp)
+		}
+	}
+	// a bad Event doesn't end the stream, which ends when the client's side does
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("after the last response: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	var stderr strings.Builder
+	if code := run([]string{"-nope"}, &stderr); code != 2 {
+		t.Errorf("bad flag: %d", code)
+	}
+	stderr.Reset()
+	if code := run([]string{"-addr", "not an address"}, &stderr); code != 1 || !strings.HasPrefix(stderr.String(), "quamina-grpc:") {
+		t.Errorf("bad address: %d, %s", code, stderr.String())
+	}
+}
@@ -0,0 +1,97 @@
+//go:build js && wasm
+
+// Command quamina-wasm makes Quamina available to JavaScript, in browsers and in edge runtimes that run
+// WebAssembly. Build it with either of
+//
+//	GOOS=js GOARCH=wasm go build -o quamina.wasm ./cmd/quamina-wasm
+//	tinygo build -o quamina.wasm -target wasm ./cmd/quamina-wasm
+//
+// and load it with the wasm_exec.js that comes with the same compiler, then quamina.mjs, as its README
+// describes. Once it's running, globalThis.quamina.create(options) makes an instance; options may set
+// patternDeletion and eventBridge, which mean WithPatternDeletion(true) and WithEventBridgeCompat. An instance
+// has the methods
+//
+//	addPattern(x, pattern)     x is a string, and pattern is JSON text
+//	deletePatterns(x)
+//	matchesForEvent(event)     event is JSON text or a Uint8Array; returns an array of the matching xs
+//
+// which return an Error, rather than throwing it, if Quamina returns one, since Go functions can't throw;
+// load in quamina.mjs wraps them in functions that throw it.
+package main
+
+import (
+	"syscall/js"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func main() {
+	js.Global().Set("quamina", js.ValueOf(map[string]any{"create": js.FuncOf(create)}))
+	select {} // the functions are called from JavaScript for as long as the page or runtime lasts
+}
+
+// create makes an instance, returning it as a JavaScript object, or an Error
+func create(_ js.Value, args []js.Value) any {
+	var opts []quamina.Option
+	if len(args) > 0 && args[0].Type() == js.TypeObject {
+		if args[0].Get("patternDeletion").Truthy() {
+			opts = append(opts, quamina.WithPatternDeletion(true))
+		}
+		if args[0].Get("eventBridge").Truthy() {
+			opts = append(opts, quamina.WithEventBridgeCompat())
+		}
+	}
+	q, err := quamina.New(opts...)
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return js.ValueOf(map[string]any{
+		"addPattern": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			if len(args) != 2 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeString {
+				return jsError("addPattern takes an x and a pattern, both strings")
+			}
+			if err := q.AddPattern(args[0].String(), args[1].String()); err != nil {
+				return jsError(err.Error())
+			}
+			return js.Undefined()
+		}),
+		"deletePatterns": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			if len(args) != 1 || args[0].Type() != js.TypeString {
+				return jsError("deletePatterns takes an x, which is a string")
+			}
+			if err := q.DeletePatterns(args[0].String()); err != nil {
+				return jsError(err.Error())
+			}
+			return js.Undefined()
+		}),
+		"matchesForEvent": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			if len(args) != 1 {
+				return jsError("matchesForEvent takes an event")
+			}
+			var event []byte
+			switch {
+			case args[0].Type() == js.TypeString:
+				event = []byte(args[0].String())
+			case args[0].InstanceOf(js.Global().Get("Uint8Array")):
+				event = make([]byte, args[0].Length())
+				js.CopyBytesToGo(event, args[0])
+			default:
+				return jsError("an event must be a string or a Uint8Array")
+			}
+			matches, err := q.MatchesForEvent(event)
+			if err != nil {
+				return jsError(err.Error())
+			}
+			xs := make([]any, len(matches))
+			for i, x := range matches {
+				xs[i] = x
+			}
+			return js.ValueOf(xs)
+		}),
+	})
+}
+
+// jsError makes a JavaScript Error, for the functions to return
+func jsError(message string) js.Value {
+	return js.Global().Get("Error").New(message)
+}
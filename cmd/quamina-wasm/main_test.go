@@ -0,0 +1,56 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"slices"
+	"syscall/js"
+	"testing"
+)
+
+func TestBindings(t *testing.T) {
+	options := js.ValueOf(map[string]any{"patternDeletion": true})
+	q := create(js.Undefined(), []js.Value{options}).(js.Value)
+	q.Call("addPattern", "p1", `{"a": ["x"]}`)
+	q.Call("addPattern", "p2", `{"a": [{"prefix": "x"}]}`)
+
+	matches := func(event js.Value) []string {
+		t.Helper()
+		result := q.Call("matchesForEvent", event)
+		xs := make([]string, result.Length())
+		for i := range xs {
+			xs[i] = result.Index(i).String()
+		}
+		slices.Sort(xs)
+		return xs
+	}
+	if got := matches(js.ValueOf(`{"a": "x"}`)); !slices.Equal(got, []string{"p1", "p2"}) {
+		t.Errorf("string event matched %v", got)
+	}
+	bytes := js.Global().Get("Uint8Array").New(len(`{"a": "xy"}`))
+	js.CopyBytesToJS(bytes, []byte(`{"a": "xy"}`))
+	if got := matches(bytes); !slices.Equal(got, []string{"p2"}) {
+		t.Errorf("Uint8Array event matched %v", got)
+	}
+
+	q.Call("deletePatterns", "p2")
+	if got := matches(js.ValueOf(`{"a": "xy"}`)); len(got) != 0 {
+		t.Errorf("matched %v after deletion", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	q := create(js.Undefined(), nil).(js.Value)
+	errorClass := js.Global().Get("Error")
+	for _, call := range []js.Value{
+		q.Call("addPattern", "p1", "{"),
+		q.Call("addPattern", 1, "{}"),
+		q.Call("deletePatterns", "p1"),
+		q.Call("matchesForEvent", "["),
+		q.Call("matchesForEvent", 3),
+	} {
+		if !call.InstanceOf(errorClass) {
+			t.Errorf("returned %v, not an Error", call)
+		}
+	}
+}
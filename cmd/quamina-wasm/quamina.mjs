@@ -0,0 +1,31 @@
+// Loads quamina.wasm and returns an object whose create(options) makes Quamina instances. The wasm_exec.js
+// from the Go or TinyGo that built quamina.wasm must have been loaded first, since it defines the Go class
+// used to run it. source is a Response, or a promise of one, such as fetch("quamina.wasm") returns, or the
+// bytes of the module.
+export async function load(source) {
+  source = await source;
+  const go = new Go();
+  const { instance } = typeof Response !== "undefined" && source instanceof Response
+    ? await WebAssembly.instantiateStreaming(source, go.importObject)
+    : await WebAssembly.instantiate(source, go.importObject);
+  go.run(instance); // runs until the page or runtime exits
+  const quamina = globalThis.quamina;
+  return {
+    create(options) {
+      const q = check(quamina.create(options));
+      return {
+        addPattern: (x, pattern) => check(q.addPattern(x, pattern)),
+        deletePatterns: (x) => check(q.deletePatterns(x)),
+        matchesForEvent: (event) => check(q.matchesForEvent(event)),
+      };
+    },
+  };
+}
+
+// Go functions can't throw, so they return their Errors, which check throws
+function check(result) {
+  if (result instanceof Error) {
+    throw result;
+  }
+  return result;
+}
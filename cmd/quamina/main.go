@@ -0,0 +1,274 @@
+// Command quamina filters newline-delimited JSON events with Quamina Patterns, in the manner of grep:
+//
+//	quamina -p patterns.txt [flags] [file ...]
+//
+// reads one Pattern per line from patterns.txt, then reads events, one JSON object per line, from the files or
+// from standard input, and prints those matching any of the Patterns. Blank lines are skipped, and Patterns
+// are numbered in the order given, starting at 1, for -n. As with grep, the exit status is 0 if any event was selected,
+// 1 if none was, and 2 if there was an error, such as an event that isn't JSON.
+//
+// Flags:
+//
+//	-p file    the Patterns; may be repeated
+//	-e pattern a Pattern given on the command line; may be repeated
+//	-v         print the events that don't match instead
+//	-c         print only the number of events printed
+//	-n         prefix each event with the numbers of the Patterns it matched, as in "3,7:"
+//	-j n       match with n goroutines; the output is in the input's order whatever n is
+//	-eb        accept EventBridge Patterns, as WithEventBridgeCompat does
+//	-stats     print the matcher's statistics and the number of events read and matched to standard error
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// maxLine is the longest event or Pattern line accepted
+const maxLine = 64 << 20
+
+// batchSize is the number of events divided among the goroutines at a time
+const batchSize = 1024
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+type options struct {
+	invert, count, numbers, stats bool
+	workers                       int
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("quamina", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	var patternFiles, inline listFlag
+	var opts options
+	var eventBridge bool
+	flags.Var(&patternFiles, "p", "file of Patterns, one per line")
+	flags.Var(&inline, "e", "a Pattern")
+	flags.BoolVar(&opts.invert, "v", false, "print events that don't match")
+	flags.BoolVar(&opts.count, "c", false, "print only the number of events printed")
+	flags.BoolVar(&opts.numbers, "n", false, "prefix events with the numbers of the Patterns they matched")
+	flags.IntVar(&opts.workers, "j", 1, "number of goroutines matching")
+	flags.BoolVar(&eventBridge, "eb", false, "accept EventBridge Patterns")
+	flags.BoolVar(&opts.stats, "stats", false, "print statistics to standard error")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	fail := func(err error) int {
+		fmt.Fprintln(stderr, "quamina:", err)
+		return 2
+	}
+	if len(patternFiles) == 0 && len(inline) == 0 {
+		return fail(errors.New("no Patterns; use -p or -e"))
+	}
+	if opts.workers < 1 {
+		return fail(errors.New("-j must be at least 1"))
+	}
+
+	var qOpts []quamina.Option
+	if eventBridge {
+		qOpts = append(qOpts, quamina.WithEventBridgeCompat())
+	}
+	q, err := quamina.New(qOpts...)
+	if err != nil {
+		return fail(err)
+	}
+	n := 0
+	add := func(source, pattern string) error {
+		if strings.TrimSpace(pattern) == "" {
+			return nil
+		}
+		n++
+		if err := q.AddPattern(n, pattern); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		return nil
+	}
+	for _, name := range patternFiles {
+		err := eachLine(name, stdin, func(line int, text []byte) error {
+			return add(fmt.Sprintf("%s:%d", name, line), string(text))
+		})
+		if err != nil {
+			return fail(err)
+		}
+	}
+	for _, pattern := range inline {
+		if err := add("-e", pattern); err != nil {
+			return fail(err)
+		}
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+	m := newMatcher(q, opts, out)
+	names := flags.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	for _, name := range names {
+		err := eachLine(name, stdin, func(line int, text []byte) error {
+			return m.add(fmt.Sprintf("%s:%d", name, line), text)
+		})
+		if err == nil {
+			err = m.flush()
+		}
+		if err != nil {
+			out.Flush()
+			return fail(err)
+		}
+	}
+	if opts.count {
+		fmt.Fprintln(out, m.printed)
+	}
+	if opts.stats {
+		fmt.Fprintf(stderr, "events %d, matched %d, printed %d\n", m.read, m.matched, m.printed)
+		stats := q.GetMatcherStats()
+		keys := make([]string, 0, len(stats))
+		for key := range stats {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			fmt.Fprintf(stderr, "%s %g\n", key, stats[key])
+		}
+	}
+	if m.printed == 0 {
+		return 1
+	}
+	return 0
+}
+
+// eachLine calls f with each non-blank line of the named file, or of stdin if name is "-"
+func eachLine(name string, stdin io.Reader, f func(line int, text []byte) error) error {
+	r := stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Bytes()
+		if len(strings.TrimSpace(string(text))) == 0 {
+			continue
+		}
+		if err := f(line, text); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// matcher collects events into batches, matches each batch with opts.workers goroutines, each using its own
+// copy of the Quamina instance, and prints the results in order
+type matcher struct {
+	copies  []*quamina.Quamina
+	opts    options
+	out     io.Writer
+	events  [][]byte
+	sources []string
+	read    int
+	matched int
+	printed int
+}
+
+func newMatcher(q *quamina.Quamina, opts options, out io.Writer) *matcher {
+	m := &matcher{copies: []*quamina.Quamina{q}, opts: opts, out: out}
+	for len(m.copies) < opts.workers {
+		m.copies = append(m.copies, q.Copy())
+	}
+	return m
+}
+
+func (m *matcher) add(source string, event []byte) error {
+	m.events = append(m.events, slices.Clone(event))
+	m.sources = append(m.sources, source)
+	if len(m.events) == batchSize {
+		return m.flush()
+	}
+	return nil
+}
+
+func (m *matcher) flush() error {
+	results := make([][]quamina.X, len(m.events))
+	errs := make([]error, len(m.events))
+	workers := min(len(m.copies), runtime.GOMAXPROCS(0), max(len(m.events), 1))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(q *quamina.Quamina, w int) {
+			defer wg.Done()
+			for i := w; i < len(m.events); i += workers {
+				matches, err := q.MatchesForEvent(m.events[i])
+				// the slice is reused by the next call
+				results[i], errs[i] = slices.Clone(matches), err
+			}
+		}(m.copies[w], w)
+	}
+	wg.Wait()
+
+	for i, event := range m.events {
+		if errs[i] != nil {
+			return fmt.Errorf("%s: %w", m.sources[i], errs[i])
+		}
+		m.read++
+		if len(results[i]) > 0 {
+			m.matched++
+		}
+		if (len(results[i]) > 0) == m.opts.invert {
+			continue
+		}
+		m.printed++
+		if m.opts.count {
+			continue
+		}
+		if m.opts.numbers && len(results[i]) > 0 {
+			numbers := make([]int, len(results[i]))
+			for j, x := range results[i] {
+				numbers[j] = x.(int)
+			}
+			slices.Sort(numbers)
+			text := make([]string, len(numbers))
+			for j, number := range numbers {
+				text[j] = strconv.Itoa(number)
+			}
+			fmt.Fprint(m.out, strings.Join(text, ",")+":")
+		}
+		if _, err := m.out.Write(append(event, '\n')); err != nil {
+			return err
+		}
+	}
+	m.events, m.sources = m.events[:0], m.sources[:0]
+	return nil
+}
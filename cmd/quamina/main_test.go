@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const events = `{"level": "error", "msg": "disk full"}
+{"level": "info", "msg": "started"}
+
+{"level": "warn", "msg": "disk 91% full"}
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	patterns := filepath.Join(dir, "patterns.txt")
+	if err := os.WriteFile(patterns, []byte(`{"level": ["error"]}`+"\n\n"+`{"msg": [{"shellstyle": "disk*"}]}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eventFile := filepath.Join(dir, "events.ndjson")
+	if err := os.WriteFile(eventFile, []byte(events), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args   []string
+		status int
+		want   string
+	}{
+		{[]string{"-p", patterns}, 0, `{"level": "error", "msg": "disk full"}` + "\n" + `{"level": "warn", "msg": "disk 91% full"}` + "\n"},
+		{[]string{"-p", patterns, "-n"}, 0, `1,2:{"level": "error", "msg": "disk full"}` + "\n" + `2:{"level": "warn", "msg": "disk 91% full"}` + "\n"},
+		{[]string{"-p", patterns, "-v"}, 0, `{"level": "info", "msg": "started"}` + "\n"},
+		{[]string{"-p", patterns, "-c", "-j", "4"}, 0, "2\n"},
+		{[]string{"-e", `{"level": ["debug"]}`, "-c"}, 1, "0\n"},
+		{[]string{"-e", `{"level": ["info"]}`, eventFile, eventFile}, 0, `{"level": "info", "msg": "started"}` + "\n" + `{"level": "info", "msg": "started"}` + "\n"},
+		{[]string{"-eb", "-e", `{"$or": [{"level": ["info"]}, {"level": ["warn"]}]}`, "-c"}, 0, "2\n"},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(test.args, strings.NewReader(events), &stdout, &stderr)
+		if status != test.status || stdout.String() != test.want {
+			t.Errorf("%v: status %d, output %q, stderr %q; want %d, %q", test.args, status, stdout.String(), stderr.String(), test.status, test.want)
+		}
+	}
+}
+
+func TestRunOrderWithWorkers(t *testing.T) {
+	var input, want strings.Builder
+	for i := 0; i < 3*batchSize+7; i++ {
+		line := `{"n": ` + strings.Repeat("1", 1+i%5) + `}` + "\n"
+		input.WriteString(line)
+		if i%5 != 1 {
+			want.WriteString(line)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-v", "-e", `{"n": [11]}`, "-j", "8", "-stats"}, strings.NewReader(input.String()), &stdout, &stderr)
+	if status != 0 || stdout.String() != want.String() {
+		t.Errorf("status %d, output differs: %d bytes, want %d", status, stdout.Len(), want.Len())
+	}
+	if !strings.Contains(stderr.String(), "events 3079, matched 616, printed 2463") || !strings.Contains(stderr.String(), "states ") {
+		t.Errorf("stats: %s", stderr.String())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		args  []string
+		input string
+		want  string
+	}{
+		{nil, "", "no Patterns"},
+		{[]string{"-e", `{"a": 1}`}, "", "-e:"},
+		{[]string{"-p", "/no/such/file"}, "", "no such file"},
+		{[]string{"-e", `{"a": [1]}`, "-j", "0"}, "", "-j"},
+		{[]string{"-e", `{"a": [1]}`}, "{\"a\": 1}\nnot json\n", "-:2:"},
+		{[]string{"-bogus"}, "", "flag provided but not defined"},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		status := run(test.args, strings.NewReader(test.input), &stdout, &stderr)
+		if status != 2 || !strings.Contains(stderr.String(), test.want) {
+			t.Errorf("%v: status %d, stderr %q, want 2, %q", test.args, status, stderr.String(), test.want)
+		}
+	}
+}
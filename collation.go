@@ -0,0 +1,69 @@
+package quamina
+
+import (
+	"errors"
+)
+
+// WithCollation makes the strings in Patterns, such as the "Müller" in {"name": ["Müller"]}, match the strings in
+// Events that are equal to them under a collation, such as those that Unicode's collation algorithm defines for
+// human languages, rather than only those with the same bytes. key returns the collation key of a string: the
+// bytes such that two strings are equal under the collation exactly when their keys are. Collators from
+// golang.org/x/text/collate provide these with their Key method; Quamina takes the function rather than depending
+// on that package. For example, this makes Patterns match German names regardless of case and accents:
+//
+//	c := collate.New(language.German, collate.IgnoreCase, collate.IgnoreDiacritics)
+//	quamina.WithCollation(func(s []byte) []byte {
+//		var buf collate.Buffer
+//		return c.Key(&buf, s)
+//	})
+//
+// key is called as Patterns are added and Events matched, perhaps concurrently, so it must be safe for that, and
+// must not reuse the slices it returns. Collators aren't safe for concurrent use; one in a sync.Pool, or guarded by
+// a mutex, is. Strings are only compared with their keys where a Pattern has plain string values; the other
+// Pattern types, and "anything-but", still compare their bytes. The collation applies to instances created with
+// Copy. This option call may not be provided more than once.
+func WithCollation(key func(s []byte) []byte) Option {
+	return func(q *Quamina) error {
+		if q.collation != nil {
+			return errors.New("collation specified more than once")
+		}
+		if key == nil {
+			return errors.New("collation key function must not be nil")
+		}
+		q.collation = key
+		return nil
+	}
+}
+
+func (m *coreMatcher) setCollation(key func(s []byte) []byte) {
+	m.collation = key
+}
+
+// collateStrings replaces the plain string values among a Pattern's with their collation keys, which the
+// rangeMatcher compares with those of Event values
+func collateStrings(fields []*patternField, key func(s []byte) []byte) {
+	for _, field := range fields {
+		for i, val := range field.vals {
+			if val.vType != stringType {
+				continue
+			}
+			field.vals[i] = typedVal{
+				vType:     collatedType,
+				val:       string(key([]byte(val.val[1 : len(val.val)-1]))),
+				collation: key,
+			}
+		}
+	}
+}
+
+// uncollated replaces the Comparisons of strings in Constraints with "other", since strings equal under a
+// collation can be different
+func uncollated(constraints Constraints) {
+	for _, c := range constraints {
+		for i, comparison := range c.Comparisons {
+			if comparison.Op == "=" && len(comparison.Value) > 0 && comparison.Value[0] == '"' {
+				c.Comparisons[i] = Comparison{Op: "other"}
+			}
+		}
+	}
+}
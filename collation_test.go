@@ -0,0 +1,76 @@
+package quamina
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testCollationKey collates strings regardless of case and of the umlauts on a, o, and u
+func testCollationKey(s []byte) []byte {
+	return []byte(strings.NewReplacer("ä", "a", "ö", "o", "ü", "u").Replace(string(bytes.ToLower(s))))
+}
+
+func TestCollation(t *testing.T) {
+	patterns := map[X]string{
+		"müller":  `{"name": ["Müller"]}`,
+		"mixed":   `{"name": ["Schulz", {"prefix": "Sch"}, 3]}`,
+		"but":     `{"city": [{"anything-but": ["Köln"]}]}`,
+		"all":     `{"tags": [{"all": ["Öl"]}]}`,
+		"literal": `{"ok": [true]}`,
+	}
+	for _, deletion := range []bool{false, true} {
+		q, err := New(WithCollation(testCollationKey), WithPatternDeletion(deletion))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+		if deletion {
+			if err := q.matcher.(*prunerMatcher).rebuild(false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for event, want := range map[string][]X{
+			`{"name": "Müller"}`:         {"müller"},
+			`{"name": "MULLER"}`:         {"müller"},
+			`{"name": "mueller"}`:        {},
+			`{"name": "schulz"}`:         {"mixed"},
+			`{"name": "Schmidt"}`:        {"mixed"},
+			`{"name": 3}`:                {"mixed"},
+			`{"name": "3"}`:              {},
+			`{"city": "KOLN"}`:           {"but"},
+			`{"city": "Köln"}`:           {},
+			`{"tags": ["öl", "OL"]}`:     {"all"},
+			`{"tags": ["öl", "oil"]}`:    {},
+			`{"ok": true}`:               {"literal"},
+			`{"ok": "TRUE"}`:             {},
+			`{"name": "Müller", "x": 1}`: {"müller"},
+		} {
+			for _, instance := range []*Quamina{q, q.Copy()} {
+				matches, err := instance.MatchesForEvent([]byte(event))
+				if err != nil || !containsExactly(matches, want) {
+					t.Errorf("deletion=%v, %s: %v, %v", deletion, event, matches, err)
+				}
+			}
+		}
+	}
+
+	// a prefix doesn't cover a string that matches more than the strings with its bytes
+	q, _ := New(WithCollation(testCollationKey), WithPatternText(true))
+	_ = q.AddPattern("x", `{"name": [{"prefix": "M"}]}`)
+	_ = q.AddPattern("x", `{"name": ["Müller"]}`)
+	if shadowed, err := q.ShadowedPatterns(); err != nil || len(shadowed) != 0 {
+		t.Errorf("shadowed: %v, %v", shadowed, err)
+	}
+
+	if _, err := New(WithCollation(nil)); err == nil {
+		t.Error("accepted nil key")
+	}
+	if _, err := New(WithCollation(testCollationKey), WithCollation(testCollationKey)); err == nil {
+		t.Error("accepted option twice")
+	}
+}
@@ -0,0 +1,256 @@
+package quamina
+
+import (
+	"fmt"
+)
+
+// Column is one column of a batch of rows stored column by column, as in Apache Arrow's record batches, for
+// MatchesForColumns. Each row is matched as if it were a JSON object whose members are the batch's columns, so
+// a Column has an entry for each row, or, inside lists, for each element, and is one of three kinds:
+//
+//   - a leaf, whose entries are its Values
+//   - a struct, whose entries are objects whose members are its Fields, each with the same number of entries
+//   - a list, whose entries are arrays of the entries of Elements; entry i is the array of Elements' entries
+//     Offsets[i] up to Offsets[i+1], so there is one more offset than there are entries
+type Column struct {
+	// Name is the member's name; it's not used for the Elements of lists.
+	Name string
+
+	// Values are a leaf's entries, each written as in Field.Val: strings are quoted, as in JSON, numbers are
+	// written as in JSON, and true, false, and null are themselves. A nil value is null.
+	Values [][]byte
+	// IsNumber reports whether a leaf's values are numbers.
+	IsNumber bool
+
+	// Fields are the members of a struct.
+	Fields []Column
+
+	// Offsets and Elements are the arrays of a list.
+	Offsets  []int
+	Elements *Column
+
+	// Nulls, if not nil, reports which of a struct's or a list's entries are null.
+	Nulls []bool
+}
+
+// MatchesForColumns returns, for each row of a batch stored column by column, the X values of the Patterns
+// which match it. Rather than flattening each row in turn, it decides once for the batch which columns
+// Patterns use, so the others are never looked at, and matches rows using the Values of those columns in
+// place, without copying or parsing them. As with MatchesForEvent, rows are matched one after another by this
+// instance; unlike it, the returned slices belong to the caller.
+func (q *Quamina) MatchesForColumns(columns []Column) ([][]X, error) {
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	rows := -1
+	for i := range columns {
+		n, err := columns[i].entries()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case rows < 0:
+			rows = n
+		case n >= 0 && n != rows:
+			return nil, fmt.Errorf("column %s has %d rows, not %d", columns[i].Name, n, rows)
+		}
+	}
+	if rows < 0 {
+		rows = 0
+	}
+	tracker := q.matcher.getSegmentsTreeTracker()
+	var plans []columnPlan
+	for i := range columns {
+		if plan, ok := planColumn(&columns[i], tracker); ok {
+			plans = append(plans, plan)
+		}
+	}
+
+	q.labels.matching()
+	var cr columnsReader
+	results := make([][]X, rows)
+	var matches []X
+	for row := 0; row < rows; row++ {
+		cr.reset()
+		for i := range plans {
+			cr.read(&plans[i], row)
+		}
+		start := len(matches)
+		var err error
+		matches, err = q.matcher.matchesForFieldsInto(cr.fields, q.bufs, matches)
+		q.bufs.release()
+		if err != nil {
+			return nil, err
+		}
+		if q.samples.active() {
+			kept := q.samples.filter(matches[start:], fieldsHash(cr.fields))
+			matches = matches[:start+len(kept)]
+		}
+		results[row] = matches[start:len(matches):len(matches)]
+	}
+	return results, nil
+}
+
+// entries returns the number of entries in the column, or -1 for a struct with neither Fields nor Nulls, and
+// checks that its parts agree with each other
+func (c *Column) entries() (int, error) {
+	kinds := 0
+	if c.Values != nil {
+		kinds++
+	}
+	if c.Fields != nil {
+		kinds++
+	}
+	if c.Elements != nil || c.Offsets != nil {
+		kinds++
+	}
+	if kinds > 1 {
+		return 0, fmt.Errorf("column %s has more than one kind of entries", c.Name)
+	}
+
+	n := -1
+	switch {
+	case c.Values != nil:
+		n = len(c.Values)
+	case c.Elements != nil || c.Offsets != nil:
+		if c.Elements == nil || len(c.Offsets) == 0 {
+			return 0, fmt.Errorf("list column %s needs both Offsets and Elements", c.Name)
+		}
+		elements, err := c.Elements.entries()
+		if err != nil {
+			return 0, err
+		}
+		for i, offset := range c.Offsets {
+			if offset < 0 || (i > 0 && offset < c.Offsets[i-1]) || (elements >= 0 && offset > elements) {
+				return 0, fmt.Errorf("list column %s has bad offset %d", c.Name, offset)
+			}
+		}
+		n = len(c.Offsets) - 1
+	default:
+		for i := range c.Fields {
+			fieldEntries, err := c.Fields[i].entries()
+			if err != nil {
+				return 0, err
+			}
+			switch {
+			case n < 0:
+				n = fieldEntries
+			case fieldEntries >= 0 && fieldEntries != n:
+				return 0, fmt.Errorf("struct column %s has fields of different lengths", c.Name)
+			}
+		}
+	}
+	if c.Nulls != nil {
+		if c.Values != nil {
+			return 0, fmt.Errorf("leaf column %s has Nulls; its nulls are nil Values", c.Name)
+		}
+		if n >= 0 && len(c.Nulls) != n {
+			return 0, fmt.Errorf("column %s has %d nulls for %d entries", c.Name, len(c.Nulls), n)
+		}
+		n = len(c.Nulls)
+	}
+	return n, nil
+}
+
+// columnPlan is the part of a Column that Patterns use: path is the Field.Path of its entries, if Patterns
+// use them as values, and members are the plans of a struct's used Fields or of a list's Elements
+type columnPlan struct {
+	column  *Column
+	path    []byte
+	members []columnPlan
+}
+
+// planColumn returns the plan for the member of the object at node which is the column, or false if Patterns
+// don't use it
+func planColumn(c *Column, node SegmentsTreeTracker) (columnPlan, bool) {
+	name := []byte(c.Name)
+	if !node.IsSegmentUsed(name) {
+		return columnPlan{}, false
+	}
+	child, _ := node.Get(name)
+	plan, ok := planEntries(c, node.PathForSegment(name), child)
+	return plan, ok
+}
+
+// planEntries returns the plan for a column whose entries have the path, if they're used as values, and whose
+// struct members, if it has any, are at child
+func planEntries(c *Column, path []byte, child SegmentsTreeTracker) (columnPlan, bool) {
+	plan := columnPlan{column: c, path: path}
+	switch {
+	case c.Elements != nil:
+		elements, ok := planEntries(c.Elements, path, child)
+		if ok {
+			plan.members = []columnPlan{elements}
+		}
+	case c.Fields != nil && child != nil:
+		for i := range c.Fields {
+			if member, ok := planColumn(&c.Fields[i], child); ok {
+				plan.members = append(plan.members, member)
+			}
+		}
+	}
+	return plan, plan.path != nil || plan.members != nil
+}
+
+// columnsReader produces the Fields of a row, reusing its buffers from row to row
+type columnsReader struct {
+	fields     []Field
+	arrayTrail []ArrayPos
+	arrayCount int32
+	trails     []ArrayPos // the ArrayTrails of the fields share this
+}
+
+func (cr *columnsReader) reset() {
+	cr.fields = cr.fields[:0]
+	cr.arrayTrail = cr.arrayTrail[:0]
+	cr.arrayCount = 0
+	cr.trails = cr.trails[:0]
+}
+
+// read adds the Fields of the entry of the column
+func (cr *columnsReader) read(plan *columnPlan, entry int) {
+	c := plan.column
+	if c.Nulls != nil && c.Nulls[entry] {
+		if plan.path != nil {
+			cr.storeField(plan.path, nullBytes, false)
+		}
+		return
+	}
+	switch {
+	case c.Values != nil:
+		val, isNumber := c.Values[entry], c.IsNumber
+		if val == nil {
+			val, isNumber = nullBytes, false
+		}
+		cr.storeField(plan.path, val, isNumber)
+	case c.Elements != nil:
+		if plan.members == nil {
+			return
+		}
+		cr.arrayCount++
+		cr.arrayTrail = append(cr.arrayTrail, ArrayPos{cr.arrayCount, 0})
+		for element := c.Offsets[entry]; element < c.Offsets[entry+1]; element++ {
+			cr.arrayTrail[len(cr.arrayTrail)-1].Pos++
+			cr.read(&plan.members[0], element)
+		}
+		cr.arrayTrail = cr.arrayTrail[:len(cr.arrayTrail)-1]
+	default:
+		for i := range plan.members {
+			cr.read(&plan.members[i], entry)
+		}
+	}
+}
+
+func (cr *columnsReader) storeField(path, val []byte, isNumber bool) {
+	if path == nil {
+		return
+	}
+	var trail []ArrayPos
+	if len(cr.arrayTrail) > 0 {
+		start := len(cr.trails)
+		cr.trails = append(cr.trails, cr.arrayTrail...)
+		trail = cr.trails[start:len(cr.trails):len(cr.trails)]
+	}
+	cr.fields = append(cr.fields, Field{Path: path, Val: val, ArrayTrail: trail, IsNumber: isNumber})
+}
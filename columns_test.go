@@ -0,0 +1,164 @@
+package quamina
+
+import (
+	"slices"
+	"testing"
+)
+
+// leaf returns a leaf Column whose values are written as in JSON, with "" meaning null
+func leaf(name string, isNumber bool, values ...string) Column {
+	c := Column{Name: name, IsNumber: isNumber, Values: make([][]byte, len(values))}
+	for i, v := range values {
+		if v != "" {
+			c.Values[i] = []byte(v)
+		}
+	}
+	return c
+}
+
+// batchRows are the rows of the batch that batchColumns returns
+var batchRows = []string{
+	`{"id": "a", "n": 1, "ok": true, "user": {"name": "pat", "age": 30}, "tags": ["x", "y"],
+	  "items": [{"sku": "s1", "qty": 2}, {"sku": "s2", "qty": 5}], "matrix": [[1, 2], [3]]}`,
+	`{"id": "b", "n": 2.5, "ok": false, "user": null, "tags": [], "items": null, "matrix": []}`,
+	`{"id": null, "n": 3, "ok": true, "user": {"name": "lee", "age": null}, "tags": null,
+	  "items": [{"sku": "s2", "qty": 2}], "matrix": [[4]]}`,
+}
+
+func batchColumns() []Column {
+	return []Column{
+		leaf("id", false, `"a"`, `"b"`, ""),
+		leaf("n", true, "1", "2.5", "3"),
+		leaf("ok", false, "true", "false", "true"),
+		{Name: "user", Nulls: []bool{false, true, false}, Fields: []Column{
+			leaf("name", false, `"pat"`, "", `"lee"`),
+			leaf("age", true, "30", "", ""),
+		}},
+		{Name: "tags", Nulls: []bool{false, false, true}, Offsets: []int{0, 2, 2, 2},
+			Elements: &Column{Values: [][]byte{[]byte(`"x"`), []byte(`"y"`)}}},
+		{Name: "items", Nulls: []bool{false, true, false}, Offsets: []int{0, 2, 2, 3}, Elements: &Column{Fields: []Column{
+			leaf("sku", false, `"s1"`, `"s2"`, `"s2"`),
+			leaf("qty", true, "2", "5", "2"),
+		}}},
+		{Name: "matrix", Offsets: []int{0, 2, 2, 3}, Elements: &Column{Offsets: []int{0, 2, 3, 4},
+			Elements: &Column{IsNumber: true, Values: [][]byte{[]byte("1"), []byte("2"), []byte("3"), []byte("4")}}}},
+	}
+}
+
+// TestMatchesForColumns checks that each row of the batch matches the Patterns that it does as JSON
+func TestMatchesForColumns(t *testing.T) {
+	patterns := []string{
+		`{"id": ["a"]}`,
+		`{"id": [null]}`,
+		`{"n": [2.5]}`,
+		`{"n": [3.0], "ok": [true]}`,
+		`{"ok": [true], "user": {"name": ["lee"]}}`,
+		`{"user": [null]}`,
+		`{"user": {"age": [null]}}`,
+		`{"user": {"name": [{"exists": false}]}}`,
+		`{"tags": ["y"]}`,
+		`{"tags": [{"exists": false}]}`,
+		`{"tags": [null]}`,
+		`{"items": {"sku": ["s1"], "qty": [2]}}`,
+		`{"items": {"sku": ["s1"], "qty": [5]}}`,
+		`{"items": {"sku": ["s2"], "qty": [2]}}`,
+		`{"items": [null]}`,
+		`{"matrix": [3]}`,
+		`{"matrix": [{"exists": false}]}`,
+		`{"missing": [{"exists": false}], "id": [{"prefix": "a"}]}`,
+	}
+	q, _ := New()
+	want, _ := New()
+	for i, pattern := range patterns {
+		if err := q.AddPattern(i, pattern); err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+		_ = want.AddPattern(i, pattern)
+	}
+	got, err := q.MatchesForColumns(batchColumns())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(batchRows) {
+		t.Fatalf("%d results for %d rows", len(got), len(batchRows))
+	}
+	matched := map[X]bool{}
+	for row, event := range batchRows {
+		wantMatches, err := want.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sameMatches(got[row], wantMatches) {
+			t.Errorf("row %d: got %v, want %v", row, got[row], wantMatches)
+		}
+		for _, x := range wantMatches {
+			matched[x] = true
+		}
+	}
+	// all but {"items": {"sku": ["s1"], "qty": [5]}} match some row
+	if len(matched) != len(patterns)-1 {
+		t.Errorf("only %d Patterns match", len(matched))
+	}
+
+	// the results are the caller's, so another batch doesn't change them
+	first := slices.Clone(got[0])
+	if _, err := q.MatchesForColumns(batchColumns()[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(first, got[0]) {
+		t.Errorf("results changed to %v", got[0])
+	}
+}
+
+func sameMatches(a, b []X) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, x := range a {
+		if !slices.Contains(b, x) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMatchesForColumnsUnused(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("p", `{"n": [1]}`)
+	// columns that no Pattern uses are skipped
+	columns := []Column{leaf("n", true, "1", "2"), leaf("other", false, "oops", "{")}
+	got, err := q.MatchesForColumns(columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || len(got[0]) != 1 || len(got[1]) != 0 {
+		t.Errorf("got %v", got)
+	}
+
+	empty, err := q.MatchesForColumns(nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("got %v, %v", empty, err)
+	}
+}
+
+func TestMatchesForColumnsErrors(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("p", `{"n": [1]}`)
+	for _, columns := range [][]Column{
+		{leaf("n", true, "1", "2"), leaf("m", true, "1")},
+		{{Name: "n", Values: [][]byte{[]byte("1")}, Fields: []Column{leaf("a", false, "1")}}},
+		{{Name: "n", Values: [][]byte{[]byte("1")}, Nulls: []bool{false}}},
+		{{Name: "s", Nulls: []bool{false}, Fields: []Column{leaf("a", false, "1", "2")}}},
+		{{Name: "s", Fields: []Column{leaf("a", false, "1", "2"), leaf("b", false, "1")}}},
+		{{Name: "l", Offsets: []int{0, 1}}},
+		{{Name: "l", Elements: &Column{Values: [][]byte{nil}}}},
+		{{Name: "l", Offsets: []int{0, 2}, Elements: &Column{Values: [][]byte{nil}}}},
+		{{Name: "l", Offsets: []int{1, 0}, Elements: &Column{Values: [][]byte{nil}}}},
+		{{Name: "l", Offsets: []int{-1, 0}, Elements: &Column{Values: [][]byte{nil}}}},
+		{{Name: "l", Offsets: []int{0, 1}, Nulls: []bool{true, false}, Elements: &Column{Values: [][]byte{nil}}}},
+	} {
+		if _, err := q.MatchesForColumns(columns); err == nil {
+			t.Errorf("%+v accepted", columns)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+package quamina
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// compact merges structurally identical faStates throughout the coreMatcher's automata. Each AddPattern call
+// builds its own states and merges them with what's there, so after many calls there are often lots of states
+// with the same smallTable contents leading to the same places; for example, the tails of the automata for
+// regexps or shell-style patterns which end in the same characters. Two states can be merged if their
+// transitions on each byte, fieldTransitions, and isSpinner are the same and their steps and epsilons lead
+// to states which can themselves be merged. compact finds these by hash-consing: it starts with every state
+// in its own class, keys each state on its contents with its targets replaced by their classes, gives states
+// with equal keys the same class, and repeats until no more classes merge. This only ever merges states
+// whose futures are identical, step for step, so it can't change what matches; it does not find looping
+// states that are equivalent only because their loops are.
+//
+// If minimize is true, that is done first by minimizing the deterministic parts of the automata, those made
+// of states without epsilons; see refine.
+//
+// The automata are rebuilt rather than edited in place, since MatchesForEvent calls may be traversing them,
+// and the new start states are swapped into each valueMatcher with update, just as addTransition does.
+// compact returns the number of states before and after.
+func (m *coreMatcher) compact(minimize bool) (int, int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c := newCompactor()
+	c.visitFieldMatcher(m.fields().state)
+	if len(c.states) == 0 {
+		return 0, 0
+	}
+	c.class = make([]int, len(c.states))
+	for i := range c.class {
+		c.class[i] = i
+	}
+	if minimize {
+		c.refine()
+	}
+	c.merge()
+	c.rebuild()
+
+	for vm, start := range c.starts {
+		fields := vm.getFieldsForUpdate()
+		fields.start = c.rebuiltFor(start)
+		fields.automatonBytes = 0
+		before := vm.fields().bytes
+		vm.update(fields)
+		m.charge(fields.bytes - before)
+	}
+	return len(c.states), len(c.rebuilt)
+}
+
+// compactor holds the working state of a compaction pass.
+// states holds every reachable faState, and index maps each to its position there; class[i] is the
+// position of the representative of states[i]'s class. rebuilt holds the new states, one per class,
+// keyed by the representative's position.
+type compactor struct {
+	fmVisited map[*fieldMatcher]bool
+	vmVisited map[*valueMatcher]bool
+	fmIDs     map[*fieldMatcher]uint32
+	starts    map[*valueMatcher]*faState
+	index     map[*faState]int
+	states    []*faState
+	class     []int
+	rebuilt   map[int]*faState
+}
+
+func newCompactor() *compactor {
+	return &compactor{
+		fmVisited: make(map[*fieldMatcher]bool),
+		vmVisited: make(map[*valueMatcher]bool),
+		fmIDs:     make(map[*fieldMatcher]uint32),
+		starts:    make(map[*valueMatcher]*faState),
+		index:     make(map[*faState]int),
+	}
+}
+
+func (c *compactor) visitFieldMatcher(fm *fieldMatcher) {
+	if c.fmVisited[fm] {
+		return
+	}
+	c.fmVisited[fm] = true
+	fields := fm.fields()
+	for _, vm := range fields.transitions {
+		c.visitValueMatcher(vm)
+	}
+	for _, next := range fields.existsTrue {
+		c.visitFieldMatcher(next)
+	}
+	for _, next := range fields.existsFalse {
+		c.visitFieldMatcher(next)
+	}
+}
+
+func (c *compactor) visitValueMatcher(vm *valueMatcher) {
+	if c.vmVisited[vm] {
+		return
+	}
+	c.vmVisited[vm] = true
+	fields := vm.fields()
+	if fields.singletonTransition != nil {
+		c.visitFieldMatcher(fields.singletonTransition)
+	}
+	for _, next := range fields.exacts {
+		c.visitFieldMatcher(next)
+	}
+	if fields.substrings != nil {
+		for _, pattern := range fields.substrings.patterns {
+			c.visitFieldMatcher(pattern.next)
+		}
+	}
+	if fields.prefixes != nil {
+		fields.prefixes.visit(c.visitFieldMatcher)
+	}
+	if fields.ranges != nil {
+		fields.ranges.visit(c.visitFieldMatcher)
+	}
+	if fields.start != nil {
+		c.starts[vm] = fields.start
+		c.visitState(fields.start)
+	}
+}
+
+// visitState collects the states reachable from state, and the fieldMatchers they transition to. It's
+// iterative because the chains of states for long values can be deep.
+func (c *compactor) visitState(state *faState) {
+	if _, ok := c.index[state]; ok {
+		return
+	}
+	var fms []*fieldMatcher
+	c.index[state] = len(c.states)
+	c.states = append(c.states, state)
+	pending := []*faState{state}
+	for len(pending) > 0 {
+		s := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		fms = append(fms, s.fieldTransitions...)
+		for _, next := range s.table.steps {
+			if next == nil {
+				continue
+			}
+			if _, ok := c.index[next]; !ok {
+				c.index[next] = len(c.states)
+				c.states = append(c.states, next)
+				pending = append(pending, next)
+			}
+		}
+		for _, next := range s.table.epsilons {
+			if _, ok := c.index[next]; !ok {
+				c.index[next] = len(c.states)
+				c.states = append(c.states, next)
+				pending = append(pending, next)
+			}
+		}
+	}
+	for _, fm := range fms {
+		c.visitFieldMatcher(fm)
+	}
+}
+
+// merge merges classes. Each round, the states whose keys are equal given the previous round's classes
+// are put in the same class, represented by the earliest of them. Since the states in each of the classes
+// it starts with have equal keys, classes only ever merge, so the number of classes falls until a round
+// merges nothing.
+func (c *compactor) merge() {
+	classes := 0
+	for i, class := range c.class {
+		if class == i {
+			classes++
+		}
+	}
+	next := make([]int, len(c.states))
+	keys := make(map[string]int, len(c.states))
+	var buf []byte
+	for {
+		clear(keys)
+		for i, s := range c.states {
+			buf = c.key(buf[:0], s)
+			rep, ok := keys[string(buf)]
+			if !ok {
+				rep = i
+				keys[string(buf)] = i
+			}
+			next[i] = rep
+		}
+		c.class, next = next, c.class
+		if len(keys) == classes {
+			return
+		}
+		classes = len(keys)
+	}
+}
+
+// refine minimizes the deterministic parts of the automata by partition refinement, as in Moore's and
+// Hopcroft's algorithms: it starts with all the deterministic states in one class and splits classes until
+// the states in each agree on their keys, which are computed using the classes the states lead to. Unlike
+// merge, which starts from the finest partition and finds the coarsest it can reach by merging, this starts
+// from the coarsest and so finds the minimal deterministic automaton, including merging loops; for example,
+// the spinners of several shell-style patterns that end the same way. This is Moore's form, which refines
+// every class each round, rather than Hopcroft's, which keeps a worklist of the classes that have just split
+// so as to touch only their predecessors; that needs the inverse of every transition, which for byte-ranged
+// smallTables is more memory than it's worth. The number of rounds is bounded by the length of the longest
+// chain of distinguishable states, which for literal-heavy automata is about the length of the longest
+// pattern value.
+// States with epsilons, or in the closures of other states, stay in classes of their own here; merge then
+// gives them the same treatment as in compact.
+func (c *compactor) refine() {
+	first := -1
+	classes := 0
+	for i, s := range c.states {
+		switch {
+		case !deterministic(s):
+			classes++
+		case first < 0:
+			first = i
+			classes++
+			c.class[i] = i
+		default:
+			c.class[i] = first
+		}
+	}
+	next := make([]int, len(c.states))
+	keys := make(map[string]int, len(c.states))
+	var buf []byte
+	for {
+		clear(keys)
+		nondeterministic := 0
+		for i, s := range c.states {
+			if !deterministic(s) {
+				next[i] = i
+				nondeterministic++
+				continue
+			}
+			buf = binary.LittleEndian.AppendUint32(buf[:0], uint32(c.class[i])) //nolint:gosec // bounded by len(states)
+			buf = c.key(buf, s)
+			rep, ok := keys[string(buf)]
+			if !ok {
+				rep = i
+				keys[string(buf)] = i
+			}
+			next[i] = rep
+		}
+		c.class, next = next, c.class
+		if len(keys)+nondeterministic == classes {
+			return
+		}
+		classes = len(keys) + nondeterministic
+	}
+}
+
+// deterministic reports whether a state has neither epsilons nor a closure including any other state
+func deterministic(s *faState) bool {
+	return len(s.table.epsilons) == 0 && len(s.epsilonClosure) == 0
+}
+
+// key appends to buf an encoding of everything about the state that must be equal for it to be merged
+// with another, with the states it leads to represented by their classes
+func (c *compactor) key(buf []byte, s *faState) []byte {
+	if s.isSpinner {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+	// adjacent ranges leading to the same class are keyed as one, so that tables which differ only in how
+	// they split their ranges are seen to be the same; the last ceiling is always byteCeiling, which marks
+	// the end
+	steps := s.table.steps
+	for j := range steps {
+		target := c.target(steps[j])
+		if j+1 < len(steps) && c.target(steps[j+1]) == target {
+			continue
+		}
+		buf = append(buf, s.table.ceilings[j])
+		buf = binary.LittleEndian.AppendUint32(buf, target)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s.table.epsilons))) //nolint:gosec // table sizes are tiny
+	for _, epsilon := range s.table.epsilons {
+		buf = binary.LittleEndian.AppendUint32(buf, c.target(epsilon))
+	}
+	for _, fm := range s.fieldTransitions {
+		id, ok := c.fmIDs[fm]
+		if !ok {
+			id = uint32(len(c.fmIDs)) //nolint:gosec // bounded by the number of fieldMatchers
+			c.fmIDs[fm] = id
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, id)
+	}
+	return buf
+}
+
+// target returns the class of the state a transition leads to, or one more than the largest class for nil
+func (c *compactor) target(s *faState) uint32 {
+	if s == nil {
+		return uint32(len(c.states)) //nolint:gosec // bounded by len(states)
+	}
+	return uint32(c.class[c.index[s]]) //nolint:gosec // bounded by len(states)
+}
+
+// rebuild makes a new state for each class, copying the representative with its targets replaced by the
+// new states for their classes; adjacent ranges which now lead to the same state are combined
+func (c *compactor) rebuild() {
+	c.rebuilt = make(map[int]*faState)
+	for i, s := range c.states {
+		if c.class[i] == i {
+			c.rebuilt[i] = &faState{isSpinner: s.isSpinner, fieldTransitions: s.fieldTransitions}
+		}
+	}
+	closures := make(map[closureKey][]*faState)
+	for i, fresh := range c.rebuilt {
+		s := c.states[i]
+		for j, step := range s.table.steps {
+			var target *faState
+			if step != nil {
+				target = c.rebuiltFor(step)
+			}
+			last := len(fresh.table.steps) - 1
+			if last >= 0 && fresh.table.steps[last] == target {
+				fresh.table.ceilings[last] = s.table.ceilings[j]
+				continue
+			}
+			fresh.table.ceilings = append(fresh.table.ceilings, s.table.ceilings[j])
+			fresh.table.steps = append(fresh.table.steps, target)
+		}
+		if len(s.table.epsilons) > 0 {
+			fresh.table.epsilons = make([]*faState, len(s.table.epsilons))
+			for j, epsilon := range s.table.epsilons {
+				fresh.table.epsilons[j] = c.rebuiltFor(epsilon)
+			}
+		}
+		fresh.epsilonClosure = c.rebuildClosure(s, fresh, closures)
+	}
+}
+
+// closureKey identifies a closure slice, so that closures shared among the original states can be
+// shared among the new ones
+type closureKey struct {
+	first  **faState
+	length int
+}
+
+func (c *compactor) rebuiltFor(s *faState) *faState {
+	return c.rebuilt[c.class[c.index[s]]]
+}
+
+// rebuildClosure translates s's epsilon closure into the new states. Members which have been merged appear
+// only once, and a closure which collapses to just the state itself becomes the selfOnlyClosure sentinel.
+// Closures are often shared among states, and so are their translations.
+func (c *compactor) rebuildClosure(s, fresh *faState, closures map[closureKey][]*faState) []*faState {
+	if len(s.epsilonClosure) == 0 {
+		return s.epsilonClosure
+	}
+	key := closureKey{first: &s.epsilonClosure[0], length: len(s.epsilonClosure)}
+	closure, ok := closures[key]
+	if !ok {
+		for _, member := range s.epsilonClosure {
+			translated := c.rebuiltFor(member)
+			if !slices.Contains(closure, translated) {
+				closure = append(closure, translated)
+			}
+		}
+		closures[key] = closure
+	}
+	if len(closure) == 1 && closure[0] == fresh && slices.Contains(s.epsilonClosure, s) {
+		return selfOnlyClosure
+	}
+	return closure
+}
@@ -0,0 +1,280 @@
+package quamina
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var compactPatterns = []string{
+	`{"a":[{"regexp":"(abc|xbc|ybc)~.jpg"}]}`,
+	`{"a":[{"regexp":"[a-z]+~.jpg"}]}`,
+	`{"a":[{"wildcard":"*.jpg"},{"wildcard":"*.jpeg"}, 3]}`,
+	`{"a":[{"wildcard":"x*.jpg"},{"wildcard":"y*.jpg"}]}`,
+	`{"b":[{"anything-but":["foo","bar"]}]}`,
+	`{"b":[{"anything-but":["foo","baz"]}], "c":[{"equals-ignore-case":"Hello"}]}`,
+	`{"c":[{"prefix":"he"}, "x"]}`,
+}
+
+var compactEvents = []string{
+	`{"a":"abc.jpg"}`, `{"a":"ybc.jpg"}`, `{"a":"zbc.jpg"}`, `{"a":"q.jpeg"}`, `{"a":"x.jpg"}`, `{"a":3}`,
+	`{"a":"xyz.png"}`, `{"a":"ABC.jpg"}`, `{"b":"foo"}`, `{"b":"bar"}`, `{"b":"baz"}`, `{"b":"qux"}`,
+	`{"b":"qux","c":"HELLO"}`, `{"b":"bar","c":"hello"}`, `{"c":"help"}`, `{"c":"x"}`,
+}
+
+func compactMatches(t *testing.T, m *coreMatcher) [][]X {
+	t.Helper()
+	var all [][]X
+	for _, event := range compactEvents {
+		matches, err := m.matchesForJSONEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches = slices.Clone(matches)
+		slices.SortFunc(matches, func(a, b X) int { return a.(int) - b.(int) })
+		all = append(all, matches)
+	}
+	return all
+}
+
+func TestCompact(t *testing.T) {
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		t.Run(fmt.Sprintf("mode-%d", mode), func(t *testing.T) {
+			m := newCoreMatcher()
+			for i, pattern := range compactPatterns {
+				if err := m.addPattern(i, pattern, mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			wanted := compactMatches(t, m)
+			before, after := m.compact(false)
+			if after >= before {
+				t.Errorf("compaction didn't merge anything: %d states before, %d after", before, after)
+			}
+			if got := compactMatches(t, m); !slices.EqualFunc(got, wanted, slices.Equal) {
+				t.Errorf("matches changed by compaction: got %v wanted %v", got, wanted)
+			}
+
+			// compacting again finds nothing more to do
+			again, afterAgain := m.compact(false)
+			if again != after || afterAgain != after {
+				t.Errorf("second compaction went from %d to %d, wanted %d", again, afterAgain, after)
+			}
+
+			// patterns can still be added, and match the same as they would have uncompacted
+			fresh := newCoreMatcher()
+			extra := `{"a":[{"shellstyle":"*b*.jpg"}, "zbc.png"]}`
+			for i, pattern := range append(slices.Clone(compactPatterns), extra) {
+				if err := fresh.addPattern(i, pattern, mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.addPattern(len(compactPatterns), extra, mode); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := compactMatches(t, m), compactMatches(t, fresh); !slices.EqualFunc(got, want, slices.Equal) {
+				t.Errorf("matches after adding to compacted matcher: got %v wanted %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCompactAPI(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, err := New(WithPatternDeletion(deletion))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, pattern := range compactPatterns {
+			if err := q.AddPattern(i, pattern); err != nil {
+				t.Fatal(err)
+			}
+		}
+		before := q.GetMatcherStats()
+		q.Compact()
+		after := q.GetMatcherStats()
+		if after["states"] >= before["states"] || after["bytes"] >= before["bytes"] {
+			t.Errorf("deletion=%v: stats before %v, after %v", deletion, before, after)
+		}
+		matches, err := q.MatchesForEvent([]byte(`{"a":"abc.jpg"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(matches, []X{0, 1, 2}) {
+			t.Errorf("deletion=%v: got %v", deletion, matches)
+		}
+	}
+}
+
+func TestCompactConcurrently(t *testing.T) {
+	q, _ := New()
+	for i, pattern := range compactPatterns {
+		if err := q.AddPattern(i, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(q *Quamina) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				matches, err := q.MatchesForEvent([]byte(`{"a":"ybc.jpg"}`))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !containsExactly(matches, []X{0, 1, 2, 3}) {
+					t.Errorf("got %v", matches)
+					return
+				}
+			}
+		}(q.Copy())
+	}
+	for i := 0; i < 20; i++ {
+		q.Compact()
+	}
+	wg.Wait()
+}
+
+func TestMinimize(t *testing.T) {
+	// (aa)*|a(aa)* is a*, whose minimal automaton loops on a single state, but the DFA built from it
+	// alternates between two
+	loop := `{"d":[{"regexp":"(aa)*|a(aa)*"}]}`
+	loopEvents := []string{`{"d":""}`, `{"d":"a"}`, `{"d":"aa"}`, `{"d":"aaaaaaa"}`, `{"d":"ab"}`, `{"d":"b"}`}
+
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		t.Run(fmt.Sprintf("mode-%d", mode), func(t *testing.T) {
+			compacted := newCoreMatcher()
+			minimized := newCoreMatcher()
+			for i, pattern := range append(slices.Clone(compactPatterns), loop) {
+				if err := compacted.addPattern(i, pattern, mode); err != nil {
+					t.Fatal(err)
+				}
+				if err := minimized.addPattern(i, pattern, mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			wanted := compactMatches(t, minimized)
+			var wantedLoop [][]X
+			for _, event := range loopEvents {
+				matches, _ := minimized.matchesForJSONEvent([]byte(event))
+				wantedLoop = append(wantedLoop, slices.Clone(matches))
+			}
+
+			_, compactStates := compacted.compact(false)
+			_, minimalStates := minimized.compact(true)
+			if minimalStates > compactStates {
+				t.Errorf("minimized to %d states, but compaction got to %d", minimalStates, compactStates)
+			}
+			if mode == BuiltForSpeed && minimalStates == compactStates {
+				t.Errorf("minimization found nothing beyond compaction's %d states", compactStates)
+			}
+
+			if got := compactMatches(t, minimized); !slices.EqualFunc(got, wanted, slices.Equal) {
+				t.Errorf("matches changed by minimization: got %v wanted %v", got, wanted)
+			}
+			for i, event := range loopEvents {
+				matches, err := minimized.matchesForJSONEvent([]byte(event))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(matches, wantedLoop[i]) {
+					t.Errorf("%s: got %v wanted %v", event, matches, wantedLoop[i])
+				}
+			}
+			again, afterAgain := minimized.compact(true)
+			if again != minimalStates || afterAgain != minimalStates {
+				t.Errorf("second minimization went from %d to %d, wanted %d", again, afterAgain, minimalStates)
+			}
+		})
+	}
+}
+
+func TestMinimizeAPI(t *testing.T) {
+	q, _ := New()
+	_ = q.SetMatcherBuildMode(BuiltForSpeed)
+	for i, pattern := range compactPatterns {
+		if err := q.AddPattern(i, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := q.GetMatcherStats()
+	q.Minimize()
+	after := q.GetMatcherStats()
+	if after["states"] >= before["states"] {
+		t.Errorf("stats before %v, after %v", before, after)
+	}
+	matches, err := q.MatchesForEvent([]byte(`{"b":"qux","c":"HELLO"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsExactly(matches, []X{4, 5}) {
+		t.Errorf("got %v", matches)
+	}
+}
+
+func TestMinimizeRegexpSamples(t *testing.T) {
+	results := func(m *coreMatcher, events [][]byte) [][]X {
+		var all [][]X
+		for _, event := range events {
+			matches, err := m.matchesForJSONEvent(event)
+			if err != nil {
+				continue // some samples aren't valid JSON strings
+			}
+			all = append(all, slices.Clone(matches))
+		}
+		return all
+	}
+
+	for _, sample := range regexpSamples {
+		// Unicode categories and negated classes make automata big enough that they take a while to build
+		if !sample.valid || strings.Contains(sample.regex, "~p") || strings.Contains(sample.regex, "~P") ||
+			strings.Contains(sample.regex, "[^") {
+			continue
+		}
+		pattern := fmt.Sprintf(`{"a": [{"regexp": "%s"}]}`, sample.regex)
+		var events [][]byte
+		for _, s := range append(slices.Clone(sample.matches), sample.nomatches...) {
+			events = append(events, []byte(fmt.Sprintf(`{"a": "%s"}`, s)))
+		}
+		for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+			m := newCoreMatcher()
+			if m.addPattern(sample.regex, pattern, mode) != nil {
+				continue // unimplemented features
+			}
+			wanted := results(m, events)
+			m.compact(true)
+			if got := results(m, events); !slices.EqualFunc(got, wanted, slices.Equal) {
+				t.Errorf("/%s/ mode %d: got %v wanted %v", sample.regex, mode, got, wanted)
+			}
+		}
+	}
+}
+
+func TestMinimizeNegatedClass(t *testing.T) {
+	// a negated class is built as the union of the UTF-8 encodings of every other code point, which has
+	// lots of states that are distinct but equivalent
+	m := newCoreMatcher()
+	if err := m.addPattern("x", `{"a": [{"regexp": "[^a]"}]}`, BuiltForComfort); err != nil {
+		t.Fatal(err)
+	}
+	before, after := m.compact(true)
+	if after > before/100 {
+		t.Errorf("minimized from %d states to %d", before, after)
+	}
+	for _, value := range []string{"b", "é", "中", "😀"} {
+		matches, _ := m.matchesForJSONEvent([]byte(`{"a": "` + value + `"}`))
+		if !containsExactly(matches, []X{"x"}) {
+			t.Errorf("%s: got %v", value, matches)
+		}
+	}
+	for _, value := range []string{"a", "bb", ""} {
+		matches, _ := m.matchesForJSONEvent([]byte(`{"a": "` + value + `"}`))
+		if len(matches) != 0 {
+			t.Errorf("%s: got %v", value, matches)
+		}
+	}
+}
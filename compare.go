@@ -0,0 +1,196 @@
+package quamina
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// fieldComparison is what a "$compare" member of a Pattern requires: that the values of two fields of an Event
+// are related by op, as in {"$compare": {"left": "bytesSent", "op": ">", "right": "bytesReceived"}}
+type fieldComparison struct {
+	left, op, right string
+}
+
+// compareOps are the operators that "$compare" accepts
+var compareOps = []string{"=", "!=", "<", "<=", ">", ">="}
+
+// readCompareMember reads the value of a "$compare" member of the object at pb's path. "left" and "right" are
+// the names of members of that same object, so the comparison is added as a field at the path of "left", with
+// no values, for comparedFields to take out.
+func readCompareMember(pb *patternBuild) error {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return errors.New("value for '$compare' must be an object")
+	}
+	var c fieldComparison
+	for {
+		t, err = pb.jd.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := t.(json.Delim); ok && delim == '}' {
+			break
+		}
+		member := t
+		if t, err = pb.jd.Token(); err != nil {
+			return err
+		}
+		val, ok := t.(string)
+		if !ok || val == "" {
+			return fmt.Errorf("%v in '$compare' must be a non-empty string", member)
+		}
+		switch member {
+		case "left":
+			c.left = JoinPath(append(slices.Clone(pb.path), val)...)
+		case "right":
+			c.right = JoinPath(append(slices.Clone(pb.path), val)...)
+		case "op":
+			if !slices.Contains(compareOps, val) {
+				return fmt.Errorf("unknown op %q in '$compare'", val)
+			}
+			c.op = val
+		default:
+			return fmt.Errorf("unknown member %v in '$compare'", member)
+		}
+	}
+	if c.left == "" || c.op == "" || c.right == "" {
+		return errors.New("'$compare' must have a 'left', an 'op', and a 'right'")
+	}
+	if c.left == c.right {
+		return errors.New("'$compare' must compare two different fields")
+	}
+	pb.results = append(pb.results, &patternField{path: c.left, compare: &c})
+	return nil
+}
+
+// comparedPattern is what a Pattern with "$compare" members is added to the automaton with, in place of its X.
+// The automaton only requires that the compared fields exist; each match is then checked by checkCompared.
+type comparedPattern struct {
+	x           X
+	comparisons []fieldComparison
+}
+
+// comparedFields takes the comparisons out of a Pattern's fields, adding "exists": true fields for the
+// compared paths that the Pattern doesn't otherwise mention, so that the Events it may match have them. It
+// returns the fields to add, and the comparisons, if any, that their matches have to be checked for.
+func (m *coreMatcher) comparedFields(fields []*patternField) ([]*patternField, []fieldComparison) {
+	var comparisons []fieldComparison
+	kept := fields[:0]
+	for _, field := range fields {
+		if field.compare == nil {
+			kept = append(kept, field)
+			continue
+		}
+		c := *field.compare
+		if m.foldFieldNames {
+			c.left, c.right = m.foldPath(c.left), m.foldPath(c.right)
+		}
+		comparisons = append(comparisons, c)
+	}
+	if comparisons == nil {
+		return fields, nil
+	}
+	for _, c := range comparisons {
+		for _, path := range []string{c.left, c.right} {
+			if !slices.ContainsFunc(kept, func(field *patternField) bool { return field.path == path }) {
+				kept = append(kept, &patternField{path: path, vals: []typedVal{{vType: existsTrueType}}})
+			}
+		}
+	}
+	m.hasCompared.Store(true)
+	return kept, comparisons
+}
+
+// checkCompared replaces the comparedPatterns among the matches with their Xs, if the fields, which are sorted
+// by path, meet all of their comparisons
+func checkCompared(matches *matchSet, fields []Field) {
+	var passed []X
+	for x := range matches.set {
+		cp, ok := x.(*comparedPattern)
+		if !ok {
+			continue
+		}
+		delete(matches.set, x)
+		if cp.matches(fields) {
+			passed = append(passed, cp.x)
+		}
+	}
+	matches.addXSingleThreaded(passed...)
+}
+
+// matches reports whether, for each of the comparisons, a pair of the fields with its paths, from the same
+// elements of any arrays they're in, is related by its op
+func (cp *comparedPattern) matches(fields []Field) bool {
+	for _, c := range cp.comparisons {
+		if !c.matches(fieldsWithPath(fields, c.left), fieldsWithPath(fields, c.right)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c fieldComparison) matches(lefts, rights []Field) bool {
+	for _, l := range lefts {
+		for _, r := range rights {
+			if noArrayTrailConflict(l.ArrayTrail, r.ArrayTrail) && compareValues(l.Val, c.op, r.Val) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldsWithPath returns the fields, which are sorted by path, that have the path
+func fieldsWithPath(fields []Field, path string) []Field {
+	i, _ := slices.BinarySearchFunc(fields, path, func(f Field, path string) int { return bytes.Compare(f.Path, []byte(path)) })
+	j := i
+	for j < len(fields) && string(fields[j].Path) == path {
+		j++
+	}
+	return fields[i:j]
+}
+
+// compareValues reports whether two values, written as in Field.Val, are related by op. Numbers are compared
+// as numbers, so 1 equals 1.0, and strings by their bytes; otherwise values of different types, or of the same
+// type but neither numbers nor strings, are only ever equal or not.
+func compareValues(left []byte, op string, right []byte) bool {
+	var order int
+	ln, lErr := parseNumber(left)
+	rn, rErr := parseNumber(right)
+	lString := len(left) > 0 && left[0] == '"'
+	rString := len(right) > 0 && right[0] == '"'
+	switch {
+	case lErr == nil && rErr == nil:
+		switch {
+		case ln < rn:
+			order = -1
+		case ln > rn:
+			order = 1
+		}
+	case lString && rString:
+		order = bytes.Compare(left[1:len(left)-1], right[1:len(right)-1])
+	default:
+		equal := bytes.Equal(left, right)
+		return (op == "=" && equal) || (op == "!=" && !equal)
+	}
+	switch op {
+	case "=":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
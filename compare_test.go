@@ -0,0 +1,150 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	patterns := map[X]string{
+		"uploads":   `{"$compare": {"left": "bytesSent", "op": ">", "right": "bytesReceived"}}`,
+		"balanced":  `{"$compare": {"left": "bytesSent", "op": "=", "right": "bytesReceived"}}`,
+		"in-order":  `{"window": {"$compare": {"left": "start", "op": "<=", "right": "end"}}}`,
+		"big-trip":  `{"kind": ["trip"], "$compare": {"left": "to", "op": "!=", "right": "from"}}`,
+		"small-a":   `{"a": [1, 2], "$compare": {"left": "a", "op": "<", "right": "b"}}`,
+		"same-item": `{"items": {"$compare": {"left": "sent", "op": ">", "right": "received"}}}`,
+	}
+	tests := map[string][]X{
+		`{"bytesSent": 100, "bytesReceived": 20.5}`:                                  {"uploads"},
+		`{"bytesSent": 1e3, "bytesReceived": 999}`:                                   {"uploads"},
+		`{"bytesSent": 1.0, "bytesReceived": 1}`:                                     {"balanced"},
+		`{"bytesSent": 10, "bytesReceived": 20}`:                                     {},
+		`{"bytesSent": 100}`:                                                         {},
+		`{"bytesSent": "100", "bytesReceived": 20}`:                                  {},
+		`{"window": {"start": "2024-01-01", "end": "2024-02-01"}}`:                   {"in-order"},
+		`{"window": {"start": "2024-03-01", "end": "2024-02-01"}}`:                   {},
+		`{"start": "2024-01-01", "end": "2024-02-01"}`:                               {},
+		`{"kind": "trip", "to": "Oslo", "from": "Bergen"}`:                           {"big-trip"},
+		`{"kind": "trip", "to": "Oslo", "from": "Oslo"}`:                             {},
+		`{"kind": "trip", "to": "1", "from": 1}`:                                     {"big-trip"},
+		`{"kind": "walk", "to": "Oslo", "from": "Bergen"}`:                           {},
+		`{"a": 2, "b": 3}`:                                                           {"small-a"},
+		`{"a": 3, "b": 4}`:                                                           {},
+		`{"a": 2, "b": 2}`:                                                           {},
+		`{"items": [{"sent": 1, "received": 2}, {"sent": 5, "received": 4}]}`:        {"same-item"},
+		`{"items": [{"sent": 1, "received": 2}, {"sent": 3, "received": 4}]}`:        {},
+		`{"bytesSent": [1, 50], "bytesReceived": 20}`:                                {"uploads"},
+		`{"bytesSent": true, "bytesReceived": true, "items": {"sent": 0.1}}`:         {"balanced"},
+		`{"bytesSent": null, "bytesReceived": false, "window": {"end": "", "x": 1}}`: {},
+	}
+	for _, deletion := range []bool{false, true} {
+		q, err := New(WithPatternDeletion(deletion))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+		for event, want := range tests {
+			for _, instance := range []*Quamina{q, q.Copy()} {
+				matches, err := instance.MatchesForEvent([]byte(event))
+				if err != nil || !containsExactly(matches, want) {
+					t.Errorf("deletion=%v, %s: %v, %v", deletion, event, matches, err)
+				}
+			}
+		}
+		if deletion {
+			_ = q.DeletePatterns("uploads")
+			matches, _ := q.MatchesForEvent([]byte(`{"bytesSent": 100, "bytesReceived": 20.5}`))
+			if len(matches) != 0 {
+				t.Errorf("matched deleted pattern: %v", matches)
+			}
+		}
+	}
+}
+
+func TestCompareWithOtherOptions(t *testing.T) {
+	q, err := New(WithRepeatedFields(RepeatedAll), WithCaseInsensitiveFieldNames(), WithEventBridgeCompat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("p", `{"Tags": ["a"], "$compare": {"left": "Sent", "op": ">=", "right": "Received"}}`); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		`{"tags": ["a", "a"], "SENT": 5, "received": 5}`: true,
+		`{"tags": ["a", "b"], "sent": 5, "received": 5}`: false,
+		`{"tags": "a", "sent": 4, "received": 5}`:        false,
+	}
+	for event, want := range tests {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || (len(matches) == 1) != want {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		left, op, right string
+		want            bool
+	}{
+		{`1`, "=", `1.0`, true},
+		{`-2`, "<", `1e-3`, true},
+		{`"abc"`, ">", `"ab"`, true},
+		{`"ab"`, ">", `"ab "`, false},
+		{`"b"`, ">=", `"b"`, true},
+		{`"1"`, "=", `1`, false},
+		{`"1"`, "!=", `1`, true},
+		{`"1"`, "<", `2`, false},
+		{`true`, "=", `true`, true},
+		{`true`, "!=", `false`, true},
+		{`null`, "<=", `null`, false},
+	}
+	for _, test := range tests {
+		if got := compareValues([]byte(test.left), test.op, []byte(test.right)); got != test.want {
+			t.Errorf("%s %s %s: %v", test.left, test.op, test.right, got)
+		}
+	}
+}
+
+func TestCompareConstraints(t *testing.T) {
+	constraints, err := PatternConstraints(`{"x": {"$compare": {"left": "a", "op": ">", "right": "b"}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(constraints) != 2 || constraints[0].Path != "x\na" || constraints[1].Path != "x\nb" ||
+		constraints[0].Comparisons[0].Op != "other" {
+		t.Errorf("constraints: %v", constraints)
+	}
+
+	q, _ := New(WithPatternText(true))
+	_ = q.AddPattern("x", `{"a": [1], "$compare": {"left": "a", "op": "<", "right": "b"}}`)
+	_ = q.AddPattern("x", `{"a": [1], "b": [2]}`)
+	shadowed, err := q.ShadowedPatterns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the comparison may rule out Events that the other Pattern matches, so neither covers the other
+	if len(shadowed) != 0 {
+		t.Errorf("shadowed: %v", shadowed)
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"$compare": ["a", ">", "b"]}`,
+		`{"$compare": {"left": "a", "op": ">"}}`,
+		`{"$compare": {"left": "a", "op": "~", "right": "b"}}`,
+		`{"$compare": {"left": "a", "op": ">", "right": "a"}}`,
+		`{"$compare": {"left": "a", "op": ">", "right": 3}}`,
+		`{"$compare": {"left": "", "op": ">", "right": "b"}}`,
+		`{"$compare": {"left": "a", "op": ">", "right": "b", "by": "c"}}`,
+	} {
+		q, _ := New()
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package quamina
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Constraint is what a Pattern requires of one field of the Events it matches: that the field's value
+// satisfies at least one of the Comparisons, which are the values in the Pattern's array for the field. A
+// Pattern matches only Events which meet all of its Constraints.
+type Constraint struct {
+	// Path is the field's path, with the member names separated by SegmentSeparator, as in Field.Path.
+	Path        string
+	Comparisons []Comparison
+	// Every reports whether, when an Event has several fields with the Path, all of them must satisfy one of
+	// the Comparisons, rather than any one of them; see WithRepeatedFields.
+	Every bool
+}
+
+// Comparison is one test that a Pattern makes of a field's value. Op is one of
+//
+//   - "=", for a value which the field must equal; Value is written as in Field.Val, so strings are quoted,
+//     and IsNumber reports whether it is a number, which matches the numbers equal to it however they're written
+//   - "prefix", for a string that Value, which is unquoted, begins
+//   - "numeric", for a number between Lo and Hi; LoOpen and HiOpen report whether the bounds themselves are
+//     excluded, and a missing bound is infinite
+//   - "exists", for a field which is present, whatever its value, and "absent" for one which isn't
+//   - "other", for the Pattern types, such as "wildcard", "anything-but", and "regexp", whose values can't
+//     be bounded above and below, and for the fields that "$compare" relates to each other
+type Comparison struct {
+	Op             string
+	Value          string
+	IsNumber       bool
+	Lo, Hi         float64
+	LoOpen, HiOpen bool
+}
+
+// Constraints are the Constraints of a Pattern.
+type Constraints []Constraint
+
+// PatternConstraints returns the Constraints of a Pattern, in the order its fields appear. Patterns of all the
+// types that any Quamina instance accepts, including the forms which need WithEventBridgeCompat, are understood.
+// This allows stores of Events, such as Parquet files, to skip the groups of rows whose statistics show that
+// no Event among them could match; see Constraints.MayMatch.
+func PatternConstraints(patternJSON string) (Constraints, error) {
+	return patternConstraints(patternJSON, RepeatedAny)
+}
+
+// patternConstraints is PatternConstraints for an instance whose fields are matched as policy says, unless the
+// Pattern says otherwise
+func patternConstraints(patternJSON string, policy RepeatedFields) (Constraints, error) {
+	fields, err := patternFromJSONWith([]byte(patternJSON), true)
+	if err != nil {
+		return nil, err
+	}
+	constraints := make(Constraints, 0, len(fields))
+	for _, field := range fields {
+		if c := field.compare; c != nil {
+			for _, path := range []string{c.left, c.right} {
+				constraints = append(constraints, Constraint{Path: path, Comparisons: []Comparison{{Op: "other"}}})
+			}
+			continue
+		}
+		constraint := Constraint{Path: field.path, Comparisons: make([]Comparison, 0, len(field.vals))}
+		constraint.Every = field.matchesEvery(policy)
+		for _, val := range field.vals {
+			constraint.Comparisons = append(constraint.Comparisons, comparisonFor(val))
+		}
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
+
+func comparisonFor(val typedVal) Comparison {
+	switch val.vType {
+	case stringType, literalType:
+		return Comparison{Op: "=", Value: val.val}
+	case numberType:
+		return Comparison{Op: "=", Value: val.val, IsNumber: true}
+	case prefixType:
+		return Comparison{Op: "prefix", Value: val.val[1 : len(val.val)-1]}
+	case numericType:
+		r := val.numeric
+		return Comparison{Op: "numeric", Lo: r.lo, Hi: r.hi, LoOpen: r.loOpen, HiOpen: r.hiOpen}
+	case existsTrueType:
+		return Comparison{Op: "exists"}
+	case existsFalseType:
+		return Comparison{Op: "absent"}
+	default:
+		return Comparison{Op: "other"}
+	}
+}
+
+// ColumnStats are what's known of the values of a field in a group of Events, such as a Parquet row group's
+// statistics for the column holding the field. The zero value knows nothing, so rules nothing out.
+type ColumnStats struct {
+	// Numbers reports that the values are all numbers, from MinNumber to MaxNumber, or nulls.
+	Numbers              bool
+	MinNumber, MaxNumber float64
+	// Strings reports that the values are all strings, from MinString to MaxString in byte order, or nulls.
+	Strings              bool
+	MinString, MaxString string
+	// NoNulls reports that none of the values are null.
+	NoNulls bool
+}
+
+// MayMatch reports whether a group of Events may include some that a Pattern with these Constraints matches,
+// given the statistics of the group's fields. stats returns those of the field at a path, or false if there
+// are none. MayMatch is conservative: if it returns false, none of the Events match, but if it returns true,
+// it's possible that none do.
+func (cs Constraints) MayMatch(stats func(path string) (ColumnStats, bool)) bool {
+	for _, c := range cs {
+		if s, ok := stats(c.Path); ok && !c.MayMatch(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// MayMatch reports whether a field whose values have the statistics may satisfy the Constraint.
+func (c Constraint) MayMatch(s ColumnStats) bool {
+	for _, comparison := range c.Comparisons {
+		if comparison.mayMatch(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Comparison) mayMatch(s ColumnStats) bool {
+	switch c.Op {
+	case "=":
+		switch {
+		case c.IsNumber:
+			if s.Strings {
+				return false
+			}
+			n, err := strconv.ParseFloat(c.Value, 64)
+			return err != nil || !s.Numbers || (s.MinNumber <= n && n <= s.MaxNumber)
+		case strings.HasPrefix(c.Value, `"`):
+			if s.Numbers {
+				return false
+			}
+			str := c.Value[1 : len(c.Value)-1]
+			return !s.Strings || (s.MinString <= str && str <= s.MaxString)
+		case c.Value == "null":
+			return !s.NoNulls
+		default:
+			// true or false
+			return !s.Numbers && !s.Strings
+		}
+	case "prefix":
+		if s.Numbers {
+			return false
+		}
+		// the least string with the prefix is the prefix itself, and the greatest begins with it
+		minPrefix := s.MinString
+		if len(minPrefix) > len(c.Value) {
+			minPrefix = minPrefix[:len(c.Value)]
+		}
+		return !s.Strings || (c.Value <= s.MaxString && minPrefix <= c.Value)
+	case "numeric":
+		if s.Strings {
+			return false
+		}
+		if !s.Numbers {
+			return true
+		}
+		aboveLo := c.Lo < s.MaxNumber || (c.Lo == s.MaxNumber && !c.LoOpen)
+		belowHi := s.MinNumber < c.Hi || (s.MinNumber == c.Hi && !c.HiOpen)
+		return aboveLo && belowHi
+	default:
+		return true
+	}
+}
@@ -0,0 +1,100 @@
+package quamina
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestPatternConstraints(t *testing.T) {
+	constraints, err := PatternConstraints(`{"status": ["paid", "shipped", null], "amount": [{"numeric": [">", 100, "<=", 500]}, 1000],
+		"customer": {"name": [{"prefix": "pat"}], "email": [{"wildcard": "*@example.com"}]},
+		"note": [{"exists": false}], "id": [{"exists": true}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Constraints{
+		{Path: "status", Comparisons: []Comparison{{Op: "=", Value: `"paid"`}, {Op: "=", Value: `"shipped"`}, {Op: "=", Value: "null"}}},
+		{Path: "amount", Comparisons: []Comparison{{Op: "numeric", Lo: 100, Hi: 500, LoOpen: true}, {Op: "=", Value: "1000", IsNumber: true}}},
+		{Path: "customer\nname", Comparisons: []Comparison{{Op: "prefix", Value: "pat"}}},
+		{Path: "customer\nemail", Comparisons: []Comparison{{Op: "other"}}},
+		{Path: "note", Comparisons: []Comparison{{Op: "absent"}}},
+		{Path: "id", Comparisons: []Comparison{{Op: "exists"}}},
+	}
+	if !reflect.DeepEqual(constraints, want) {
+		t.Errorf("got %+v", constraints)
+	}
+
+	constraints, _ = PatternConstraints(`{"n": [{"numeric": ["<", 0]}]}`)
+	if c := constraints[0].Comparisons[0]; !math.IsInf(c.Lo, -1) || c.Hi != 0 || !c.HiOpen {
+		t.Errorf("got %+v", c)
+	}
+
+	for _, bad := range []string{`{"a": "b"}`, `{"a": [{"numeric": ["<"]}]}`, ``} {
+		if _, err := PatternConstraints(bad); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestMayMatch(t *testing.T) {
+	numbers := ColumnStats{Numbers: true, MinNumber: 10, MaxNumber: 20, NoNulls: true}
+	strs := ColumnStats{Strings: true, MinString: "bob", MaxString: "kim"}
+	for _, test := range []struct {
+		pattern string
+		stats   ColumnStats
+		want    bool
+	}{
+		{`{"f": [15]}`, numbers, true},
+		{`{"f": [10, 30]}`, numbers, true},
+		{`{"f": [30]}`, numbers, false},
+		{`{"f": [30]}`, strs, false},
+		{`{"f": [30]}`, ColumnStats{}, true},
+		{`{"f": ["cat"]}`, strs, true},
+		{`{"f": ["al"]}`, strs, false},
+		{`{"f": ["kim"]}`, strs, true},
+		{`{"f": ["cat"]}`, numbers, false},
+		{`{"f": [null]}`, numbers, false},
+		{`{"f": [null]}`, strs, true},
+		{`{"f": [true]}`, strs, false},
+		{`{"f": [true]}`, ColumnStats{NoNulls: true}, true},
+		{`{"f": [{"prefix": "bo"}]}`, strs, true},
+		{`{"f": [{"prefix": "ki"}]}`, strs, true},
+		{`{"f": [{"prefix": "kz"}]}`, strs, false},
+		{`{"f": [{"prefix": "a"}]}`, strs, false},
+		{`{"f": [{"prefix": "bobby"}]}`, strs, true},
+		{`{"f": [{"prefix": "1"}]}`, numbers, false},
+		{`{"f": [{"numeric": [">", 20]}]}`, numbers, false},
+		{`{"f": [{"numeric": [">=", 20]}]}`, numbers, true},
+		{`{"f": [{"numeric": ["<", 10]}]}`, numbers, false},
+		{`{"f": [{"numeric": ["<=", 10]}]}`, numbers, true},
+		{`{"f": [{"numeric": [">", 0, "<", 100]}]}`, numbers, true},
+		{`{"f": [{"numeric": ["=", 5]}]}`, numbers, false},
+		{`{"f": [{"numeric": [">", 0]}]}`, strs, false},
+		{`{"f": [{"wildcard": "z*"}]}`, strs, true},
+		{`{"f": [{"exists": false}]}`, numbers, true},
+		{`{"f": [{"anything-but": [15]}]}`, numbers, true},
+	} {
+		constraints, err := PatternConstraints(test.pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", test.pattern, err)
+		}
+		got := constraints.MayMatch(func(path string) (ColumnStats, bool) {
+			return test.stats, path == "f"
+		})
+		if got != test.want {
+			t.Errorf("%s with %+v: got %t", test.pattern, test.stats, got)
+		}
+	}
+
+	// every Constraint must be satisfiable, and fields without statistics rule nothing out
+	constraints, _ := PatternConstraints(`{"f": [15], "g": ["zed"], "h": ["x"]}`)
+	stats := map[string]ColumnStats{"f": numbers, "g": strs}
+	if constraints.MayMatch(func(path string) (ColumnStats, bool) { s, ok := stats[path]; return s, ok }) {
+		t.Error("g's Constraint wasn't applied")
+	}
+	delete(stats, "g")
+	if !constraints.MayMatch(func(path string) (ColumnStats, bool) { s, ok := stats[path]; return s, ok }) {
+		t.Error("fields without statistics ruled the group out")
+	}
+}
@@ -10,7 +10,6 @@ package quamina
 import (
 	"bytes"
 	"cmp"
-	"errors"
 	"slices"
 	"sync"
 	"sync/atomic"
@@ -30,6 +29,40 @@ type coreMatcher struct {
 	// never accessed concurrently. Lives here (not a sync.Pool) so the maps are
 	// never evicted mid-build; see epsilonClosureInto.
 	closureBufs *closureBuffers
+	// paths interns the path strings of pattern fields, which are used as keys in the maps of every
+	// fieldMatcher they pass through; without it, each AddPattern call would store its own copies.
+	// Only touched by addPattern, under lock.
+	paths map[string]string
+	// usage is the memory consumed by the valueMatchers, as reported by MemoryUsage, which addPattern and
+	// compact keep up to date; budgets are charged with each change. budgets is only set before any Patterns
+	// are added, or by a pruner rebuild under the pruner's lock.
+	usage   atomic.Int64
+	budgets []*MemoryBudget
+	// eventBridge means Patterns are read with the EventBridge pattern types; see WithEventBridgeCompat. Like
+	// budgets, it's only set before any Patterns are added.
+	eventBridge bool
+	// numericTolerance, if not zero, widens numbers in Patterns into ranges; see WithNumericTolerance. It's
+	// set like eventBridge.
+	numericTolerance float64
+	// foldFieldNames means the paths in Patterns are folded to lower case; see WithCaseInsensitiveFieldNames.
+	// It's set like eventBridge.
+	foldFieldNames bool
+	// repeatedFields is how the fields of Patterns that don't say otherwise are matched when an Event has
+	// several fields with their path; see WithRepeatedFields. It's set like eventBridge.
+	repeatedFields RepeatedFields
+	// hmacKey is the key for "hmac-sha256" Patterns; see WithHMACKey. It's set like eventBridge.
+	hmacKey []byte
+	// collation, if not nil, gives the collation keys with which Patterns' strings are compared; see
+	// WithCollation. It's set like eventBridge.
+	collation func(s []byte) []byte
+	// lookups are the Lookups that "lookup" Patterns name; see WithLookups. It's set like eventBridge.
+	lookups map[string]Lookup
+	// hasRepeated is set once a Pattern has been added that needs all of an Event's fields with some path to
+	// match, so matchSetForFields has to check its matches.
+	hasRepeated atomic.Bool
+	// hasCompared is set once a Pattern with "$compare" has been added, so matchSetForFields has to check its
+	// matches.
+	hasCompared atomic.Bool
 }
 
 // coreFields groups the updateable fields in coreMatcher.
@@ -43,7 +76,7 @@ type coreFields struct {
 }
 
 func newCoreMatcher() *coreMatcher {
-	m := coreMatcher{closureBufs: newClosureBuffers()}
+	m := coreMatcher{closureBufs: newClosureBuffers(), paths: make(map[string]string)}
 	m.updateable.Store(&coreFields{
 		state:        newFieldMatcher(),
 		segmentsTree: newSegmentsIndex(),
@@ -64,11 +97,42 @@ func (m *coreMatcher) addPattern(x X, patternJSON string, buildMode MatcherBuild
 // addPatternWithPrinter can be called from debugging and under-development code to allow viewing pretty-printed
 // NFAs
 func (m *coreMatcher) addPatternWithPrinter(x X, patternJSON string, printer printer, buildMode MatcherBuildMode) error {
-	patternFields, err := patternFromJSON([]byte(patternJSON))
+	patternFields, err := patternFromJSONWith([]byte(patternJSON), m.eventBridge)
 	if err != nil {
 		return err
 	}
+	if m.numericTolerance > 0 {
+		widenNumbers(patternFields, m.numericTolerance)
+	}
+	if m.foldFieldNames {
+		for _, field := range patternFields {
+			field.path = m.foldPath(field.path)
+		}
+	}
+	if err := m.keyDigests(patternFields); err != nil {
+		return err
+	}
+	if err := m.bindLookups(patternFields); err != nil {
+		return err
+	}
+	if m.collation != nil {
+		collateStrings(patternFields, m.collation)
+	}
+	patternFields, comparisons := m.comparedFields(patternFields)
+	if rp, err := m.repeatedPatternFor(x, patternFields, printer); err != nil {
+		return err
+	} else if rp != nil {
+		x = rp
+	}
+	if comparisons != nil {
+		// the comparisons are checked first, so they wrap any repeatedPattern
+		x = &comparedPattern{x: x, comparisons: comparisons}
+	}
+	return m.addPatternFields(x, patternFields, printer, buildMode)
+}
 
+// addPatternFields adds the fields of a Pattern that has been parsed already
+func (m *coreMatcher) addPatternFields(x X, patternFields []*patternField, printer printer, buildMode MatcherBuildMode) error {
 	// sort the pattern fields lexically
 	slices.SortFunc(patternFields, func(a, b *patternField) int { return cmp.Compare(a.path, b.path) })
 
@@ -76,6 +140,10 @@ func (m *coreMatcher) addPatternWithPrinter(x X, patternJSON string, printer pri
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
+	if err := checkBudgets(m.budgets); err != nil {
+		return err
+	}
+
 	// Reuse the closure scratch but empty it each build so its maps hold only
 	// this pattern's working set, not every state in the matcher.
 	m.closureBufs.reset()
@@ -89,6 +157,7 @@ func (m *coreMatcher) addPatternWithPrinter(x X, patternJSON string, printer pri
 
 	// Add paths to the segments tree index.
 	for _, field := range patternFields {
+		field.path = m.internPath(field.path)
 		freshStart.segmentsTree.add(field.path)
 	}
 
@@ -114,7 +183,9 @@ func (m *coreMatcher) addPatternWithPrinter(x X, patternJSON string, printer pri
 			case existsFalseType:
 				ns = state.addExists(false, field)
 			default:
+				before := state.valueBytes(field.path)
 				ns = state.addTransition(field, printer, m.closureBufs, buildMode)
+				m.charge(state.valueBytes(field.path) - before)
 			}
 			nextStates = append(nextStates, ns...)
 		}
@@ -131,9 +202,32 @@ func (m *coreMatcher) addPatternWithPrinter(x X, patternJSON string, printer pri
 	return nil
 }
 
+// addFieldPaths adds paths to the segmentsTree, so that Flatteners will extract the fields they name whether
+// or not any pattern uses them
+func (m *coreMatcher) addFieldPaths(paths []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	currentFields := m.fields()
+	freshFields := &coreFields{state: currentFields.state, segmentsTree: currentFields.segmentsTree.copy()}
+	for _, path := range paths {
+		freshFields.segmentsTree.add(m.internPath(m.foldPath(path)))
+	}
+	m.updateable.Store(freshFields)
+}
+
+// internPath returns the canonical copy of a pattern field's path
+func (m *coreMatcher) internPath(path string) string {
+	if interned, ok := m.paths[path]; ok {
+		return interned
+	}
+	m.paths[path] = path
+	return path
+}
+
 // deletePattern not implemented by coreMatcher
 func (m *coreMatcher) deletePatterns(_ X) error {
-	return errors.New("operation not supported")
+	return ErrDeletionNotSupported
 }
 
 // matchesForJSONEvent calls the flattener to pull the fields out of the event and
@@ -183,8 +277,62 @@ func emptyFields() []Field {
 
 // matchesForFields takes a list of Field structures, sorts them by pathname, and launches the field-matching
 // process. The fields in a pattern to match are similarly sorted; thus running an automaton over them works.
-// No error can be returned but the matcher interface requires one, and it is used by the pruner implementation
+// An error is returned if matching is stopped by the work limit, and the pruner implementation has others.
 func (m *coreMatcher) matchesForFields(fields []Field, bufs *nfaBuffers) ([]X, error) {
+	// the result is built in bufs.resultBuf, and if that had to grow, the bigger one is kept
+	result, err := m.matchesForFieldsInto(fields, bufs, bufs.getResultBuf())
+	bufs.resultBuf = result[:0]
+	return result, err
+}
+
+// matchesForFieldsInto appends the matches to dst and returns it
+func (m *coreMatcher) matchesForFieldsInto(fields []Field, bufs *nfaBuffers, dst []X) ([]X, error) {
+	matches := m.matchSetForFields(fields, bufs)
+	if err := bufs.limitError(); err != nil {
+		return dst, err
+	}
+	var result []X
+	if bufs.counts != nil {
+		// the matches are patternKeys, one for each matching Pattern, so they're turned back into Xs
+		bufs.countPatterns(matches)
+		result = dst
+		for x := range bufs.counts {
+			result = append(result, x)
+		}
+	} else {
+		result = matches.matchesInto(dst)
+	}
+	if bufs.matchOrder != nil {
+		bufs.matchOrder.sort(result[len(dst):])
+	}
+	return result, nil
+}
+
+// countForFields returns the number of matches, without making a slice of them
+func (m *coreMatcher) countForFields(fields []Field, bufs *nfaBuffers) (int, error) {
+	matches := m.matchSetForFields(fields, bufs)
+	if err := bufs.limitError(); err != nil {
+		return 0, err
+	}
+	if bufs.counts != nil {
+		bufs.countPatterns(matches)
+		return len(bufs.counts), nil
+	}
+	return len(matches.set), nil
+}
+
+// matchSetForFields returns the matches in bufs' matchSet, which is only good until the next call
+func (m *coreMatcher) matchSetForFields(fields []Field, bufs *nfaBuffers) *matchSet {
+	bufs.resetWork()
+	if bufs.stats != nil {
+		bufs.stats.Fields = len(fields)
+	}
+	if bufs.maxFields > 0 && len(fields) > bufs.maxFields {
+		bufs.tooManyFields = len(fields)
+		matches := bufs.getMatches()
+		matches.reset()
+		return matches
+	}
 	if len(fields) == 0 {
 		fields = emptyFields()
 	} else {
@@ -202,10 +350,21 @@ func (m *coreMatcher) matchesForFields(fields []Field, bufs *nfaBuffers) ([]X, e
 	// for each of the fields, we'll try to match the automaton start state to that field - the tryToMatch
 	// routine will, in the case that there's a match, call itself to see if subsequent fields after the
 	// first matched will transition through the machine and eventually achieve a match
-	for i := 0; i < len(fields); i++ {
-		tryToMatch(fields, i, cmFields.state, matches, bufs)
+	if bufs.parallel != nil && len(fields) >= bufs.parallel.minFields {
+		tryToMatchInParallel(fields, cmFields.state, matches, bufs)
+	} else {
+		startFields := cmFields.state.fields()
+		for i := 0; i < len(fields) && !startFields.exhaustedBy(fields[i].Path); i++ {
+			tryToMatch(fields, i, cmFields.state, matches, bufs)
+		}
+	}
+	if m.hasCompared.Load() {
+		checkCompared(matches, fields)
+	}
+	if m.hasRepeated.Load() {
+		checkRepeated(matches, fields)
 	}
-	return matches.matchesInto(bufs.resultBuf[:0]), nil
+	return matches
 }
 
 // tryToMatch tries to match the field at fields[index] to the provided state. If it does match and generate
@@ -217,12 +376,15 @@ func tryToMatch(fields []Field, index int, state *fieldMatcher, matches *matchSe
 	// transition on exists:true?
 	existsTrans, ok := stateFields.existsTrue[string(fields[index].Path)]
 	if ok {
-		matches = matches.addXSingleThreaded(existsTrans.fields().matches...)
-		for nextIndex := index + 1; nextIndex < len(fields); nextIndex++ {
+		existsFields := existsTrans.fields()
+		matches = matches.addXSingleThreaded(existsFields.matches...)
+		for nextIndex := index + 1; nextIndex < len(fields) && !existsFields.exhaustedBy(fields[nextIndex].Path); nextIndex++ {
 			if noArrayTrailConflict(fields[index].ArrayTrail, fields[nextIndex].ArrayTrail) {
 				tryToMatch(fields, nextIndex, existsTrans, matches, bufs)
 			}
 		}
+		// as below, an exists:false on a lexically larger path must be checked even if no fields follow
+		checkExistsFalse(existsFields, fields, index, matches, bufs)
 	}
 
 	// an exists:false transition is possible if there is no matching field in the event
@@ -240,8 +402,8 @@ func tryToMatch(fields []Field, index int, state *fieldMatcher, matches *matchSe
 
 		// for each state we've transitioned to, give each subsequent field a chance to
 		//  transition on it, assuming it's not in an object that's in a different element
-		//  of the same array
-		for nextIndex := index + 1; nextIndex < len(fields); nextIndex++ {
+		//  of the same array. Once the fields are past any this state has transitions for, the rest can be skipped
+		for nextIndex := index + 1; nextIndex < len(fields) && !nextStateFields.exhaustedBy(fields[nextIndex].Path); nextIndex++ {
 			if noArrayTrailConflict(fields[index].ArrayTrail, fields[nextIndex].ArrayTrail) {
 				tryToMatch(fields, nextIndex, nextState, matches, bufs)
 			}
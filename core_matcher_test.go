@@ -3,6 +3,7 @@ package quamina
 import (
 	"fmt"
 	"testing"
+	"unsafe"
 )
 
 func TestBasicMatching(t *testing.T) {
@@ -103,6 +104,38 @@ func TestExistsFalseOrder(t *testing.T) {
 	}
 }
 
+// an exists:false after an exists:true, when no later field is in the event, must still be checked
+func TestExistsFalseAfterExistsTrue(t *testing.T) {
+	patterns := []string{
+		`{"app": [{"exists": true}], "tier": [{"exists": false}]}`,
+		`{"app": [{"exists": true}], "tier": [{"exists": false}], "zone": [{"exists": true}]}`,
+	}
+	for _, test := range []struct {
+		event string
+		want  int
+	}{
+		{`{"app": "web"}`, 1},
+		{`{"app": "web", "other": 1}`, 1},
+		{`{"app": "web", "tier": "db"}`, 0},
+		{`{"app": "web", "zone": "a"}`, 2},
+		{`{"tier": "db"}`, 0},
+	} {
+		m := newCoreMatcher()
+		for _, pattern := range patterns {
+			if err := m.addPattern(pattern, pattern, BuiltForComfort); err != nil {
+				t.Fatal(err)
+			}
+		}
+		matches, err := m.matchesForJSONEvent([]byte(test.event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != test.want {
+			t.Errorf("%s matched %v", test.event, matches)
+		}
+	}
+}
+
 func TestFieldNameOrdering(t *testing.T) {
 	j := `{
 		"b": 1
@@ -218,6 +251,7 @@ func TestExerciseMatching(t *testing.T) {
 		`{"Image": { "Title": [ {"anything-but":  ["Pikachu", "Eevee"] } ]  } }`,
 		`{"Image": { "Thumbnail": { "Url": [ "a", { "prefix": "https:" } ] } } }`,
 		`{"Image": { "Title": [ { "equals-ignore-case": "VIEW FROM 15th FLOOR" } ] } }`,
+		`{"Image": { "Title": [ { "contains-ignore-case": "15TH floor" } ] } }`,
 		`{"Image": { "Title": [ { "regexp": "View .... [0-9][0-9][rtn][dh] Floor" } ]  } }`,
 		`{"Image": { "Title": [ { "regexp": "(View)?( down)? from 15th (Floor|Storey)" } ]  } }`,
 		`{"Image": { "Thumbnail": { "Url": [ { "regexp": "https://www.example.com/[^0-9/]+/[1-9]+" } ] } } }`,
@@ -335,3 +369,81 @@ func fetchFAForPath(t *testing.T, cm *coreMatcher, path string) *faState {
 	vm := cm.fields().state.fields().transitions[path]
 	return vm.fields().start
 }
+
+func TestPathInterning(t *testing.T) {
+	m := newCoreMatcher()
+	if err := m.addPattern("p1", `{"a": {"b": [1]}, "xylophone": [1]}`, BuiltForComfort); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.addPattern("p2", `{"a": {"b": [2]}, "xylophone": [2]}`, BuiltForComfort); err != nil {
+		t.Fatal(err)
+	}
+
+	// each value of a.b leads to its own fieldMatcher with a transition on "xylophone"; their keys should share storage
+	ab := m.fields().state.fields().transitions["a\nb"]
+	var keys []string
+	for _, val := range []string{"1", "2"} {
+		next := testTransitionOn(ab, []byte(val), newNfaBuffers())
+		if len(next) != 1 {
+			t.Fatalf("%d transitions on %s", len(next), val)
+		}
+		for key := range next[0].fields().transitions {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) != 2 || keys[0] != "xylophone" || unsafe.StringData(keys[0]) != unsafe.StringData(keys[1]) {
+		t.Errorf("paths not interned: %v", keys)
+	}
+}
+
+func TestExhaustedBy(t *testing.T) {
+	m := newCoreMatcher()
+	start := m.fields().state.fields()
+	if !start.exhaustedBy([]byte("a")) {
+		t.Error("empty matcher not exhausted")
+	}
+	patterns := []string{
+		`{"b": [1], "d": [{"exists": true}]}`,
+		`{"c": ["x"], "e": [{"exists": false}]}`,
+		`{"a": {"b": [2]}}`,
+	}
+	for i, pattern := range patterns {
+		if err := m.addPattern(i, pattern, BuiltForComfort); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start = m.fields().state.fields()
+	if start.lastPath != "c" || !start.hasPaths {
+		t.Errorf("start lastPath %q", start.lastPath)
+	}
+	for path, wanted := range map[string]bool{"a": false, "a\nb": false, "c": false, "c\nd": true, "z": true} {
+		if start.exhaustedBy([]byte(path)) != wanted {
+			t.Errorf("exhaustedBy(%q) should be %v", path, wanted)
+		}
+	}
+	b := testTransitionOn(start.transitions["b"], []byte("1"), newNfaBuffers())[0].fields()
+	if b.lastPath != "d" || b.exhaustedBy([]byte("d")) || !b.exhaustedBy([]byte("e")) {
+		t.Errorf("after b, lastPath %q", b.lastPath)
+	}
+	// after c, the exists:false means nothing is exhausted
+	c := testTransitionOn(start.transitions["c"], []byte(`"x"`), newNfaBuffers())[0].fields()
+	if c.hasPaths || c.exhaustedBy([]byte("zzz")) {
+		t.Errorf("after c, hasPaths %v lastPath %q", c.hasPaths, c.lastPath)
+	}
+
+	events := map[string][]X{
+		`{"a": {"b": 2}, "b": 1, "d": 0, "q": 1, "r": 2}`: {0, 2},
+		`{"b": 1, "c": "x", "q": 1, "r": 2}`:              {1},
+		`{"c": "x", "e": 1, "f": 1}`:                      {},
+		`{"b": 1, "c": "x", "d": [1, 2], "zz": 1}`:        {0, 1},
+	}
+	for event, wanted := range events {
+		matches, err := m.matchesForJSONEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(matches, wanted) {
+			t.Errorf("%s: got %v wanted %v", event, matches, wanted)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package quamina
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// derivedMember is the member name under which the fields computed by WithDerivedFields are found
+const derivedMember = "$derived"
+
+// DerivedField is a field that Quamina computes from each Event, as though the Event had it, so that Patterns
+// can match values that the Event's producer didn't provide, such as the length of a string. Patterns find it
+// under the "$derived" member, so {"$derived": {"len(message)": [0]}} matches Events with an empty message.
+type DerivedField struct {
+	// Name is the member name of the field under "$derived".
+	Name string
+	// Source is the path of the field that the value is computed from, as made by JoinPath. If it's empty, the
+	// value is computed from the whole Event.
+	Source string
+	// Compute returns the value computed from one of the Source's values, or from the Event, both written as in
+	// Field.Val, so that strings are quoted. If ok is false, there's no value. It's called concurrently by
+	// instances created with Copy.
+	Compute func(val []byte) (derived []byte, ok bool)
+}
+
+// WithDerivedFields arranges that the instance's Flattener adds the fields, which Patterns use "$derived" to
+// match, to the fields of each Event. A field is computed once for each of the Source's values in the Event,
+// from the same elements of any arrays, and only if a Pattern uses it. Members of Events named "$derived" are
+// ignored. DerivedLength, DerivedLower, and DerivedJSONDepth make commonly needed fields. The fields aren't
+// added by MatchesForStruct or MatchesForTypedFields, which don't use the Flattener. This option call may not
+// be provided more than once.
+func WithDerivedFields(fields ...DerivedField) Option {
+	return func(q *Quamina) error {
+		if q.derivedFields != nil {
+			return errors.New("derived fields specified more than once")
+		}
+		names := make(map[string]bool, len(fields))
+		for _, field := range fields {
+			if field.Name == "" {
+				return errors.New("derived field without a name")
+			}
+			if names[field.Name] {
+				return fmt.Errorf("derived field %q specified more than once", field.Name)
+			}
+			if field.Compute == nil {
+				return fmt.Errorf("derived field %q has no Compute function", field.Name)
+			}
+			names[field.Name] = true
+		}
+		q.derivedFields = append([]DerivedField{}, fields...)
+		return nil
+	}
+}
+
+// DerivedLength is the field named "len(" followed by the path's member names joined by "." and then ")",
+// whose value is the number of characters in each of the string values of the field with the path
+func DerivedLength(segments ...string) DerivedField {
+	return DerivedField{
+		Name:   "len(" + strings.Join(segments, ".") + ")",
+		Source: JoinPath(segments...),
+		Compute: func(val []byte) ([]byte, bool) {
+			if len(val) < 2 || val[0] != '"' {
+				return nil, false
+			}
+			return strconv.AppendInt(nil, int64(utf8.RuneCount(val[1:len(val)-1])), 10), true
+		},
+	}
+}
+
+// DerivedLower is the field named "lower(" followed by the path's member names joined by "." and then ")",
+// whose value is each of the string values of the field with the path, in lower case
+func DerivedLower(segments ...string) DerivedField {
+	return DerivedField{
+		Name:   "lower(" + strings.Join(segments, ".") + ")",
+		Source: JoinPath(segments...),
+		Compute: func(val []byte) ([]byte, bool) {
+			if len(val) < 2 || val[0] != '"' {
+				return nil, false
+			}
+			return bytes.ToLower(val), true
+		},
+	}
+}
+
+// DerivedJSONDepth is the field named "json_depth", whose value is how deeply the objects and arrays of a
+// JSON Event are nested, counting the Event itself, so that {"a": [1]} has a depth of 2
+func DerivedJSONDepth() DerivedField {
+	return DerivedField{
+		Name: "json_depth",
+		Compute: func(event []byte) ([]byte, bool) {
+			depth, deepest := 0, 0
+			inString := false
+			for i := 0; i < len(event); i++ {
+				switch b := event[i]; {
+				case inString:
+					if b == '\\' {
+						i++
+					} else if b == '"' {
+						inString = false
+					}
+				case b == '"':
+					inString = true
+				case b == '{' || b == '[':
+					depth++
+					deepest = max(deepest, depth)
+				case b == '}' || b == ']':
+					depth--
+				}
+			}
+			return strconv.AppendInt(nil, int64(deepest), 10), true
+		},
+	}
+}
+
+// derivedFlattener adds the fields that WithDerivedFields provides to those its Flattener extracts
+type derivedFlattener struct {
+	flattener Flattener
+	derived   []DerivedField
+}
+
+// derivedSources are the paths the Flattener has to extract for the fields to be computed
+func derivedSources(derived []DerivedField) []string {
+	var sources []string
+	for _, field := range derived {
+		if field.Source != "" {
+			sources = append(sources, field.Source)
+		}
+	}
+	return sources
+}
+
+func (f *derivedFlattener) Copy() Flattener {
+	return &derivedFlattener{flattener: f.flattener.Copy(), derived: f.derived}
+}
+
+func (f *derivedFlattener) Flatten(event []byte, tracker SegmentsTreeTracker) ([]Field, error) {
+	fields, err := f.flattener.Flatten(event, tracker)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := tracker.Get([]byte(derivedMember))
+	if !ok {
+		return fields, nil
+	}
+
+	// the Event's own "$derived" fields would be taken for the computed ones
+	prefix := []byte(derivedMember + SegmentSeparator)
+	kept := fields[:0]
+	for _, field := range fields {
+		if !bytes.HasPrefix(field.Path, prefix) {
+			kept = append(kept, field)
+		}
+	}
+	fields = kept
+
+	for _, d := range f.derived {
+		name := []byte(d.Name)
+		if !node.IsSegmentUsed(name) {
+			continue
+		}
+		path := node.PathForSegment(name)
+		if d.Source == "" {
+			if val, ok := d.Compute(event); ok {
+				fields = append(fields, derivedField(path, val, nil))
+			}
+			continue
+		}
+		for _, field := range fields {
+			if string(field.Path) != d.Source {
+				continue
+			}
+			if val, ok := d.Compute(field.Val); ok {
+				fields = append(fields, derivedField(path, val, field.ArrayTrail))
+			}
+		}
+	}
+	return fields, nil
+}
+
+func derivedField(path, val []byte, trail []ArrayPos) Field {
+	_, err := parseNumber(val)
+	return Field{Path: path, Val: val, ArrayTrail: trail, IsNumber: err == nil}
+}
@@ -0,0 +1,89 @@
+package quamina
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDerivedFields(t *testing.T) {
+	trimmed := DerivedField{
+		Name:   "trimmed",
+		Source: JoinPath("user", "name"),
+		Compute: func(val []byte) ([]byte, bool) {
+			return append([]byte{'"'}, append(bytes.TrimSpace(val[1:len(val)-1]), '"')...), len(val) > 1
+		},
+	}
+	q, err := New(WithDerivedFields(DerivedLength("message"), DerivedLower("host"), DerivedJSONDepth(), trimmed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := map[X]string{
+		"empty":   `{"$derived": {"len(message)": [0]}}`,
+		"short":   `{"$derived": {"len(message)": [3]}, "level": ["error"]}`,
+		"example": `{"$derived": {"lower(host)": ["www.example.com"]}}`,
+		"deep":    `{"$derived": {"json_depth": [4]}}`,
+		"alice":   `{"$derived": {"trimmed": ["alice"]}}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	tests := map[string][]X{
+		`{"message": ""}`:                                    {"empty"},
+		`{"message": "héé", "level": "error"}`:               {"short"},
+		`{"message": "abc", "level": "info"}`:                {},
+		`{"message": ["abcd", "abc"], "level": "error"}`:     {"short"},
+		`{"message": 3, "level": "error"}`:                   {},
+		`{"host": "WWW.Example.COM"}`:                        {"example"},
+		`{"a": [{"b": {"c": "]]]\"{"}}]}`:                    {"deep"},
+		`{"a": [{"b": {"c": [1]}}]}`:                         {},
+		`{"user": {"name": "  alice "}}`:                     {"alice"},
+		`{"$derived": {"len(message)": 0}, "message": "ab"}`: {},
+	}
+	for event, want := range tests {
+		for _, instance := range []*Quamina{q, q.Copy()} {
+			matches, err := instance.MatchesForEvent([]byte(event))
+			if err != nil || !containsExactly(matches, want) {
+				t.Errorf("%s: %v, %v", event, matches, err)
+			}
+		}
+	}
+}
+
+func TestDerivedFieldsWithOtherOptions(t *testing.T) {
+	q, err := New(WithDerivedFields(DerivedLength("Message")), WithCaseInsensitiveFieldNames(), WithPatternDeletion(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = q.AddPattern("p", `{"$derived": {"LEN(Message)": [2]}}`); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := q.MatchesForEvent([]byte(`{"MESSAGE": "ab"}`))
+	if err != nil || !containsExactly(matches, []X{"p"}) {
+		t.Errorf("matches %v, %v", matches, err)
+	}
+	if err = q.matcher.(*prunerMatcher).rebuild(false); err != nil {
+		t.Fatal(err)
+	}
+	matches, err = q.MatchesForEvent([]byte(`{"message": "ab"}`))
+	if err != nil || !containsExactly(matches, []X{"p"}) {
+		t.Errorf("after rebuild: %v, %v", matches, err)
+	}
+}
+
+func TestDerivedFieldsErrors(t *testing.T) {
+	compute := func(val []byte) ([]byte, bool) { return val, true }
+	for _, fields := range [][]DerivedField{
+		{{Source: "a", Compute: compute}},
+		{{Name: "a"}},
+		{DerivedLength("a"), DerivedLength("a")},
+	} {
+		if _, err := New(WithDerivedFields(fields...)); err == nil {
+			t.Errorf("accepted %v", fields)
+		}
+	}
+	if _, err := New(WithDerivedFields(DerivedJSONDepth()), WithDerivedFields()); err == nil {
+		t.Error("accepted option twice")
+	}
+}
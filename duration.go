@@ -0,0 +1,209 @@
+package quamina
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// durationRange is the parsed form of a "duration" pattern such as {"duration": [">", "5s", "<=", "PT1M"]}. The
+// bounds are in nanoseconds; a missing bound is the smallest or largest time.Duration.
+type durationRange struct {
+	lo, hi         time.Duration
+	loOpen, hiOpen bool
+}
+
+func (r durationRange) contains(d time.Duration) bool {
+	return (r.lo < d || (r.lo == d && !r.loOpen)) && (d < r.hi || (d == r.hi && !r.hiOpen))
+}
+
+// readDurationSpecial reads the array of comparisons in a "duration" pattern, each an operator followed by a
+// duration. As with "numeric", "=" must appear alone; otherwise there may be one of ">" and ">=" and one of "<"
+// and "<=".
+func readDurationSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("value for 'duration' must be an array")
+	}
+	r := durationRange{lo: math.MinInt64, hi: math.MaxInt64}
+	var ops []string
+	for {
+		t, err = pb.jd.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := t.(json.Delim); ok && delim == ']' {
+			break
+		}
+		op, ok := t.(string)
+		if !ok {
+			return nil, errors.New("'duration' comparisons must be an operator followed by a string")
+		}
+		t, err = pb.jd.Token()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("'duration' operator %s must be followed by a string", op)
+		}
+		d, ok := parseDuration(s)
+		if !ok {
+			return nil, fmt.Errorf("'duration' can't use %q", s)
+		}
+		switch op {
+		case "=":
+			r.lo, r.hi = d, d
+		case ">", ">=":
+			r.lo, r.loOpen = d, op == ">"
+		case "<", "<=":
+			r.hi, r.hiOpen = d, op == "<"
+		default:
+			return nil, fmt.Errorf("unknown 'duration' operator %q", op)
+		}
+		ops = append(ops, op)
+	}
+	switch {
+	case len(ops) == 0:
+		return nil, errors.New("empty 'duration' comparison list")
+	case len(ops) > 2 || (len(ops) == 2 && (ops[0] == "=" || ops[1] == "=" || ops[0][0] == ops[1][0])):
+		return nil, errors.New(`'duration' allows "=" alone, or at most one lower and one upper bound`)
+	case r.lo > r.hi || (r.lo == r.hi && (r.loOpen || r.hiOpen)):
+		return nil, errors.New("'duration' range contains no durations")
+	}
+
+	// has to be } or tokenizer will throw error
+	if _, err = pb.jd.Token(); err != nil {
+		return nil, err
+	}
+	return append(valsIn, typedVal{vType: durationType, duration: r}), nil
+}
+
+type durationTest struct {
+	r    durationRange
+	next *fieldMatcher
+}
+
+// durationFromEvent parses an Event's value as a duration, if it's a string holding one
+func durationFromEvent(val []byte) (time.Duration, bool) {
+	if len(val) < 3 || val[0] != '"' {
+		return 0, false
+	}
+	// neither parser keeps the string it's given, so the value needn't be copied
+	inner := val[1 : len(val)-1]
+	return parseDuration(unsafe.String(&inner[0], len(inner)))
+}
+
+// parseDuration parses a duration written as Go writes them, such as "1h30m", or in the ISO 8601 form, such as
+// "PT1H30M". ISO 8601 years and months aren't allowed, since their lengths vary.
+func parseDuration(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if s[0] == 'P' {
+		return parseISODuration(s[1:])
+	}
+	// checked first because ParseDuration makes an error, which is costly, for anything that isn't a duration
+	switch c := s[0]; {
+	case c >= '0' && c <= '9', c == '-', c == '+', c == '.':
+		d, err := time.ParseDuration(s)
+		return d, err == nil
+	}
+	return 0, false
+}
+
+// isoUnits are the lengths of the units of an ISO 8601 duration, before and after the T that separates the
+// date from the time
+var isoUnits = [2]map[byte]time.Duration{
+	{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour},
+	{'H': time.Hour, 'M': time.Minute, 'S': time.Second},
+}
+
+// parseISODuration parses what follows the P of an ISO 8601 duration. Each unit may appear once, in order, and
+// only the seconds, which come last, may have a fraction, which is written with "." or ",".
+func parseISODuration(s string) (time.Duration, bool) {
+	var total time.Duration
+	inTime, sawUnit := false, false
+	var lastUnit byte
+	for len(s) > 0 {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, false
+			}
+			inTime, lastUnit = true, 0
+			s = s[1:]
+			continue
+		}
+		// the digits, and any fraction, of a count
+		i := 0
+		var whole, frac time.Duration
+		fracScale := time.Duration(1)
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			if whole > (math.MaxInt64-9)/10 {
+				return 0, false
+			}
+			whole = whole*10 + time.Duration(s[i]-'0')
+			i++
+		}
+		if i == 0 {
+			return 0, false
+		}
+		hasFraction := i < len(s) && (s[i] == '.' || s[i] == ',')
+		if hasFraction {
+			i++
+			start := i
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				// digits beyond nanoseconds are dropped
+				if fracScale < time.Second {
+					frac = frac*10 + time.Duration(s[i]-'0')
+					fracScale *= 10
+				}
+				i++
+			}
+			if i == start {
+				return 0, false
+			}
+		}
+		if i == len(s) {
+			return 0, false
+		}
+		units := isoUnits[0]
+		if inTime {
+			units = isoUnits[1]
+		}
+		unit, ok := units[s[i]]
+		if !ok || (hasFraction && unit != time.Second) {
+			return 0, false
+		}
+		// units must come in decreasing order of size, so that each appears at most once
+		if lastUnit != 0 && !isoBefore(lastUnit, s[i]) {
+			return 0, false
+		}
+		lastUnit = s[i]
+		// the fraction of a second adds less than a second
+		if whole > (math.MaxInt64-time.Second)/unit {
+			return 0, false
+		}
+		amount := whole*unit + frac*(time.Second/fracScale)
+		if total > math.MaxInt64-amount {
+			return 0, false
+		}
+		total += amount
+		sawUnit = true
+		s = s[i+1:]
+	}
+	return total, sawUnit
+}
+
+// isoBefore reports whether the ISO 8601 unit a comes before b, within the date or the time part
+func isoBefore(a, b byte) bool {
+	const order = "WDHMS"
+	return strings.IndexByte(order, a) < strings.IndexByte(order, b)
+}
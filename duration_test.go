@@ -0,0 +1,91 @@
+package quamina
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"5s":                5 * time.Second,
+		"-1.5h":             -90 * time.Minute,
+		"1h30m":             90 * time.Minute,
+		"PT5S":              5 * time.Second,
+		"PT1H30M":           90 * time.Minute,
+		"P1DT12H":           36 * time.Hour,
+		"P2W":               14 * 24 * time.Hour,
+		"PT0.25S":           250 * time.Millisecond,
+		"PT1,5S":            1500 * time.Millisecond,
+		"PT1.123456789123S": 1123456789 * time.Nanosecond,
+	} {
+		if got, ok := parseDuration(s); !ok || got != want {
+			t.Errorf("%s: %v, %v", s, got, ok)
+		}
+	}
+	for _, bad := range []string{
+		"", "5", "five seconds", "P", "PT", "P1Y", "P1M", "PT1D", "P1H", "PT1S1M", "PT1H1H", "PT1.5M", "P1.5D",
+		"PT1.S", "PT.5S", "P1DT", "PT5", "P99999999999999999999D", "P106751DT23H47M16.854775808S",
+	} {
+		if d, ok := parseDuration(bad); ok {
+			t.Errorf("%q accepted as %v", bad, d)
+		}
+	}
+}
+
+func TestDurationPatterns(t *testing.T) {
+	q, _ := New()
+	patterns := map[X]string{
+		"slow":    `{"elapsed": [{"duration": [">", "5s"]}]}`,
+		"minute":  `{"elapsed": [{"duration": [">=", "PT1M", "<", "2m"]}]}`,
+		"instant": `{"elapsed": [{"duration": ["=", "0s"]}]}`,
+		"either":  `{"elapsed": [{"duration": ["<", "-1ns"]}, "unknown"]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", x, err)
+		}
+	}
+	for event, want := range map[string][]X{
+		"5s":      {},
+		"5.001s":  {"slow"},
+		"PT1M":    {"slow", "minute"},
+		"1m59.9s": {"slow", "minute"},
+		"PT2M":    {"slow"},
+		"0s":      {"instant"},
+		"PT0S":    {"instant"},
+		"-3ms":    {"either"},
+		"unknown": {"either"},
+		"a while": {},
+		"P1Y":     {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(fmt.Sprintf(`{"elapsed": %q}`, event)))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+	if matches, _ := q.MatchesForEvent([]byte(`{"elapsed": 10}`)); len(matches) != 0 {
+		t.Errorf("number matched %v", matches)
+	}
+
+	for _, bad := range []string{
+		`{"elapsed": [{"duration": ">5s"}]}`,
+		`{"elapsed": [{"duration": []}]}`,
+		`{"elapsed": [{"duration": {">": "5s"}}]}`,
+		`{"elapsed": [{"duration": [">", 5]}]}`,
+		`{"elapsed": [{"duration": [">"]}]}`,
+		`{"elapsed": [{"duration": [5, "5s"]}]}`,
+		`{"elapsed": [{"duration": [">", "5 seconds"]}]}`,
+		`{"elapsed": [{"duration": ["~", "5s"]}]}`,
+		`{"elapsed": [{"duration": ["=", "5s", "<", "6s"]}]}`,
+		`{"elapsed": [{"duration": ["=", "5s", "=", "5s"]}]}`,
+		`{"elapsed": [{"duration": [">", "5s", ">", "6s"]}]}`,
+		`{"elapsed": [{"duration": [">", "5s", ">=", "6s"]}]}`,
+		`{"elapsed": [{"duration": ["<", "9s", ">", "5s", "<", "8s"]}]}`,
+		`{"elapsed": [{"duration": [">", "5s", "<", "5s"]}]}`,
+	} {
+		if err := q.AddPattern("bad", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
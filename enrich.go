@@ -0,0 +1,127 @@
+package quamina
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Enricher makes annotations for a match, such as the queue a router should send the Event to, from the
+// fields of the Event whose paths are used by the Patterns added with the matching X. The fields, which are
+// in no particular order, and their Val slices, must not be modified or kept after the Enricher returns.
+// Enrichers are called concurrently by instances created with Copy.
+type Enricher func(fields []Field) map[string]string
+
+// WithEnrichers provides Enrichers by X, whose annotations MatchesForEventWithAnnotations reports along
+// with the matches of the Patterns added with those X values, so that routing metadata can be attached in
+// the same pass as matching. The Enrichers apply to instances created with Copy. This option call may not be
+// provided more than once.
+func WithEnrichers(enrichers map[X]Enricher) Option {
+	return func(q *Quamina) error {
+		if q.enrichers != nil {
+			return errors.New("enrichers specified more than once")
+		}
+		q.enrichers = &enrichments{funcs: make(map[X]Enricher, len(enrichers)), paths: make(map[X][]string)}
+		for x, enricher := range enrichers {
+			if enricher == nil {
+				return errors.New("nil enricher")
+			}
+			q.enrichers.funcs[x] = enricher
+		}
+		return nil
+	}
+}
+
+// MatchesForEventWithAnnotations is like MatchesForEvent, but reports the annotations that the Enricher of
+// each matching X, if it has one, makes for the Event, and, for an instance created WithPatternText(true),
+// the text of its Patterns, as MatchesForEventWithPatterns does. It needs an instance created WithEnrichers.
+// The returned slice belongs to the caller.
+func (q *Quamina) MatchesForEventWithAnnotations(event []byte) ([]Match, error) {
+	if q.enrichers == nil {
+		return nil, errors.New("annotations are only made by instances created WithEnrichers")
+	}
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	fields, err := q.flattener.Flatten(event, q.matcher.getSegmentsTreeTracker())
+	if err != nil {
+		return nil, err
+	}
+	q.labels.matching()
+	xs, err := q.matcher.matchesForFields(fields, q.bufs)
+	q.bufs.release()
+	if err != nil {
+		return nil, err
+	}
+	if q.samples.active() {
+		xs = q.samples.filter(xs, eventHash(event))
+	}
+	matches := make([]Match, len(xs))
+	for i, x := range xs {
+		matches[i] = Match{X: x, Annotations: q.enrichers.annotate(x, fields)}
+	}
+	if q.patternTexts != nil {
+		q.patternTexts.lock.RLock()
+		for i := range matches {
+			matches[i].Patterns = q.patternTexts.texts[matches[i].X]
+		}
+		q.patternTexts.lock.RUnlock()
+	}
+	return matches, nil
+}
+
+// enrichments are the Enrichers of an instance, and of its copies, with the paths of the fields used by the
+// Patterns added with their X values, sorted
+type enrichments struct {
+	funcs map[X]Enricher
+	lock  sync.RWMutex
+	paths map[X][]string
+}
+
+// add records the paths used by a Pattern added with x, if x has an Enricher
+func (e *enrichments) add(x X, patternJSON string, foldFieldNames bool) {
+	if _, ok := e.funcs[x]; !ok {
+		return
+	}
+	// the Pattern has been added, so it parses
+	constraints, _ := patternConstraints(patternJSON, RepeatedAny)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	paths := slices.Clone(e.paths[x])
+	for _, constraint := range constraints {
+		path := constraint.Path
+		if foldFieldNames {
+			path = strings.ToLower(path)
+		}
+		if i, found := slices.BinarySearch(paths, path); !found {
+			paths = slices.Insert(paths, i, path)
+		}
+	}
+	e.paths[x] = paths
+}
+
+func (e *enrichments) delete(x X) {
+	e.lock.Lock()
+	delete(e.paths, x)
+	e.lock.Unlock()
+}
+
+// annotate calls x's Enricher, if it has one, with the fields whose paths its Patterns use
+func (e *enrichments) annotate(x X, fields []Field) map[string]string {
+	enricher, ok := e.funcs[x]
+	if !ok {
+		return nil
+	}
+	e.lock.RLock()
+	paths := e.paths[x]
+	e.lock.RUnlock()
+	var used []Field
+	for _, field := range fields {
+		if _, found := slices.BinarySearch(paths, string(field.Path)); found {
+			used = append(used, field)
+		}
+	}
+	return enricher(used)
+}
@@ -0,0 +1,128 @@
+package quamina
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+// fieldsEnricher annotates a match with the paths and values of the fields it's given
+func fieldsEnricher(fields []Field) map[string]string {
+	annotations := make(map[string]string)
+	for _, field := range fields {
+		path := strings.ReplaceAll(string(field.Path), "\n", ".")
+		annotations[path] += string(field.Val)
+	}
+	return annotations
+}
+
+func TestMatchesForEventWithAnnotations(t *testing.T) {
+	route := func(fields []Field) map[string]string {
+		for _, field := range fields {
+			if string(field.Path) == "region" {
+				return map[string]string{"queue": "orders-" + strings.Trim(string(field.Val), `"`)}
+			}
+		}
+		return nil
+	}
+	q, err := New(WithEnrichers(map[X]Enricher{"orders": route, "audit": fieldsEnricher}),
+		WithPatternText(true), WithPatternDeletion(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := []struct {
+		x       X
+		pattern string
+	}{
+		{"orders", `{"region": [{"exists": true}], "type": ["order"]}`},
+		{"audit", `{"user": {"id": [{"exists": true}]}}`},
+		{"audit", `{"type": ["refund"], "user": {"id": [{"exists": true}]}}`},
+		{"plain", `{"type": ["order"]}`},
+	}
+	for _, p := range patterns {
+		if err := q.AddPattern(p.x, p.pattern); err != nil {
+			t.Fatalf("%s: %v", p.pattern, err)
+		}
+	}
+	event := `{"type": "order", "region": "eu", "amount": 5, "user": {"id": 7, "name": "ann"}, "tags": [1, 2]}`
+	got, err := q.Copy().MatchesForEventWithAnnotations([]byte(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[X]map[string]string{
+		"orders": {"queue": "orders-eu"},
+		"audit":  {"type": `"order"`, "user.id": "7"},
+		"plain":  nil,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %+v", got)
+	}
+	for _, match := range got {
+		if !maps.Equal(match.Annotations, want[match.X]) {
+			t.Errorf("%v: %v", match.X, match.Annotations)
+		}
+		if len(match.Patterns) == 0 {
+			t.Errorf("%v: no Patterns", match.X)
+		}
+	}
+
+	// the fields are those used by any of the X's Patterns, and all the values of each
+	q, _ = New(WithEnrichers(map[X]Enricher{"x": fieldsEnricher}), WithPatternDeletion(true))
+	_ = q.AddPattern("x", `{"b": [1], "a": {"c": [2]}}`)
+	_ = q.AddPattern("x", `{"d": [{"exists": true}]}`)
+	got, _ = q.MatchesForEventWithAnnotations([]byte(`{"a": {"c": 2, "e": 3}, "b": 1, "d": ["p", "q"]}`))
+	if len(got) != 1 || !maps.Equal(got[0].Annotations, map[string]string{"a.c": "2", "b": "1", "d": `"p""q"`}) {
+		t.Errorf("got %+v", got)
+	}
+	if got[0].Patterns != nil {
+		t.Errorf("Patterns without WithPatternText: %v", got[0].Patterns)
+	}
+	if err = q.DeletePatterns("x"); err != nil {
+		t.Fatal(err)
+	}
+	if len(q.enrichers.paths) != 0 {
+		t.Errorf("paths kept: %v", q.enrichers.paths)
+	}
+	_ = q.AddPattern("x", `{"d": [{"exists": true}]}`)
+	got, _ = q.MatchesForEventWithAnnotations([]byte(`{"a": {"c": 2}, "b": 1, "d": "p"}`))
+	if len(got) != 1 || !maps.Equal(got[0].Annotations, map[string]string{"d": `"p"`}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestEnrichersFoldedAndCompared(t *testing.T) {
+	q, err := New(WithEnrichers(map[X]Enricher{"x": fieldsEnricher}), WithCaseInsensitiveFieldNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = q.AddPattern("x", `{"User": {"$compare": {"left": "Id", "op": "<", "right": "Limit"}}}`); err != nil {
+		t.Fatal(err)
+	}
+	got, err := q.MatchesForEventWithAnnotations([]byte(`{"user": {"ID": 3, "limit": 4, "name": "x"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !maps.Equal(got[0].Annotations, map[string]string{"user.id": "3", "user.limit": "4"}) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestEnrichersErrors(t *testing.T) {
+	if _, err := New(WithEnrichers(map[X]Enricher{"x": nil})); err == nil {
+		t.Error("accepted nil Enricher")
+	}
+	if _, err := New(WithEnrichers(nil), WithEnrichers(nil)); err == nil {
+		t.Error("WithEnrichers accepted twice")
+	}
+	q, _ := New()
+	if _, err := q.MatchesForEventWithAnnotations([]byte(`{}`)); err == nil {
+		t.Error("annotated without WithEnrichers")
+	}
+	q, _ = New(WithEnrichers(nil))
+	if _, err := q.MatchesForEventWithAnnotations([]byte(`{`)); err == nil {
+		t.Error("accepted bad JSON")
+	}
+	if got, err := q.MatchesForEventWithAnnotations([]byte(`{}`)); err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v", got, err)
+	}
+}
@@ -1,5 +1,10 @@
 package quamina
 
+import (
+	"cmp"
+	"slices"
+)
+
 // Epsilon-closure construction
 // =============================
 //
@@ -42,6 +47,11 @@ package quamina
 //     dedup and reintroduces exponential blowup on adversarial regexps), which
 //     is why only the self-only case is specialized, not "drop self everywhere".
 //
+//  4. Closure interning (internClosure). Spinner merges and splices leave many
+//     states in one build with exactly the same closure; each multi-member
+//     closure is put in canonical (stateID) order and looked up in a per-build
+//     table so that equal closures share one backing array.
+//
 // Generation counters avoid clearing the scratch maps between the many closures
 // a single build computes. Every "visited in this pass?" check compares a
 // stored generation against the current one; bumping the current generation
@@ -95,6 +105,7 @@ type closureBuffers struct {
 	tables        map[tableShareKey]tableMark // share-group scratch for the post-pass (closureGen, closureRep)
 	states        map[*faState]uint64         // per-faState last-visited gen, used by traverseEpsilons
 	walkVisited   map[*faState]uint64         // per-faState last-walked gen, used by closureForNfa
+	closures      map[uint64][][]*faState     // this build's multi-member closures, keyed by hashStates
 	// nfaWalkCount counts states actually processed by closureForNfa (past the
 	// prune + dedup guards). Reset to zero at the start of each epsilonClosure
 	// call (per-walk count, not a reused-buffer lifetime total). Used by tests to
@@ -108,6 +119,7 @@ func newClosureBuffers() *closureBuffers {
 		tables:      make(map[tableShareKey]tableMark),
 		states:      make(map[*faState]uint64),
 		walkVisited: make(map[*faState]uint64),
+		closures:    make(map[uint64][][]*faState),
 	}
 }
 
@@ -119,6 +131,7 @@ func (b *closureBuffers) reset() {
 	clear(b.tables)
 	clear(b.states)
 	clear(b.walkVisited)
+	clear(b.closures)
 }
 
 // epsilonClosure walks the automaton from start and precomputes the epsilon
@@ -205,10 +218,10 @@ func closureForState(state *faState, bufs *closureBuffers) {
 	}
 
 	// selfWasCollected reports whether `state` itself was added to closureList
-	// below. Only non-epsilon-only states are collected, so when self is
-	// epsilon-only a closureList of length 1 holds some *other* state, not self
-	// — the self-only checks must not fire on it.
-	selfWasCollected := !state.table.isEpsilonOnly()
+	// below. Only closureMembers are collected, so when self isn't one, a
+	// closureList of length 1 holds some *other* state, not self — the
+	// self-only checks must not fire on it.
+	selfWasCollected := closureMember(state)
 
 	// Generation-based visited tracking: bufs.states records which gen last
 	// visited each state, so we never clear the map between traversals.
@@ -242,7 +255,25 @@ func closureForState(state *faState, bufs *closureBuffers) {
 		state.epsilonClosure = selfOnlyClosure
 		return
 	}
-	state.epsilonClosure = closure
+	state.epsilonClosure = bufs.internClosure(closure)
+}
+
+// internClosure returns a previously-computed closure with the same members as the
+// argument if there is one, otherwise the argument, sorted into canonical order.
+// Consumers treat closures as sets, so the reordering is invisible to them.
+func (b *closureBuffers) internClosure(closure []*faState) []*faState {
+	slices.SortFunc(closure, func(s1, s2 *faState) int {
+		return cmp.Compare(s1.stateID(), s2.stateID())
+	})
+	key := hashStates(closure)
+	bucket := b.closures[key]
+	for _, existing := range bucket {
+		if sameStates(existing, closure) {
+			return existing
+		}
+	}
+	b.closures[key] = append(bucket, closure)
+	return closure
 }
 
 // dedupByTableShare collapses states in bufs.closureList that share a smallTable
@@ -284,13 +315,21 @@ func traverseEpsilons(start *faState, epsilons []*faState, bufs *closureBuffers)
 			continue
 		}
 		bufs.states[eps] = bufs.closureSetGen
-		if !eps.table.isEpsilonOnly() {
+		if closureMember(eps) {
 			bufs.closureList = append(bufs.closureList, eps)
 		}
 		traverseEpsilons(start, eps.table.epsilons, bufs)
 	}
 }
 
+// closureMember reports whether a state belongs in the closures it's part of. One with nothing but
+// epsilons can be left out, since traversal follows them when the closure is built, unless it has
+// fieldTransitions of its own: merging a prefix match into a spinner leaves the prefix's last state
+// with no byte steps, an epsilon back to the spinner, and the prefix's fieldTransitions.
+func closureMember(state *faState) bool {
+	return !state.table.isEpsilonOnly() || len(state.fieldTransitions) != 0
+}
+
 // sameFieldTransitions reports whether two states have identical fieldTransitions.
 // This does an order-dependent comparison. If the same field matchers appear in
 // different order, we'll miss the dedup — but that just keeps an extra state in
@@ -193,3 +193,35 @@ func TestTablePointerDedupPreservesFieldTransitions(t *testing.T) {
 		t.Error("DFA traversal missing fmB")
 	}
 }
+
+func TestClosureInterning(t *testing.T) {
+	// two epsilon-only splices leading to the same pair of targets, listed in different
+	// orders, should end up sharing one closure
+	target1 := &faState{table: newSmallTable()}
+	target1.table.addByteStep('a', target1)
+	target2 := &faState{table: newSmallTable()}
+	target2.table.addByteStep('b', target2)
+	splice1 := &faState{table: newSmallTable()}
+	splice1.table.epsilons = []*faState{target1, target2}
+	splice2 := &faState{table: newSmallTable()}
+	splice2.table.epsilons = []*faState{target2, target1}
+
+	bufs := newClosureBuffers()
+	closureForState(splice1, bufs)
+	closureForState(splice2, bufs)
+	if len(splice1.epsilonClosure) != 2 || len(splice2.epsilonClosure) != 2 {
+		t.Fatalf("closure sizes %d/%d", len(splice1.epsilonClosure), len(splice2.epsilonClosure))
+	}
+	if &splice1.epsilonClosure[0] != &splice2.epsilonClosure[0] {
+		t.Error("equal closures not shared")
+	}
+
+	// a fresh build doesn't see the previous build's closures
+	bufs.reset()
+	splice3 := &faState{table: newSmallTable()}
+	splice3.table.epsilons = []*faState{target1, target2}
+	closureForState(splice3, bufs)
+	if &splice3.epsilonClosure[0] == &splice1.epsilonClosure[0] {
+		t.Error("closure shared across reset")
+	}
+}
@@ -0,0 +1,186 @@
+package quamina
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// maxEventBridgePatterns limits the number of Quamina Patterns one EventBridge Pattern may expand into; each
+// "$or" and each combination of values that Quamina requires to be alone multiplies them.
+const maxEventBridgePatterns = 256
+
+func (m *coreMatcher) setEventBridgeCompat() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.eventBridge = true
+}
+
+// addEventBridgePattern is AddPattern for instances created WithEventBridgeCompat. EventBridge Patterns can
+// say things Quamina Patterns can't, with "$or" and with arrays combining "exists" or "anything-but" with
+// other values, but they always mean the same as some set of Quamina Patterns, which are added with the same
+// X. They're all checked first, so that an error in one doesn't leave the others added.
+func (q *Quamina) addEventBridgePattern(x X, patternJSON string) error {
+	patterns, err := eventBridgePatterns(patternJSON)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range patterns {
+		if _, err := patternFromJSONWith([]byte(pattern), true); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range patterns {
+		if err := q.matcher.addPattern(x, pattern, q.buildMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventBridgePatterns rewrites an EventBridge Pattern into the equivalent Quamina Patterns. Anything that
+// isn't a JSON object is returned unchanged, for patternFromJSON to report the error.
+func eventBridgePatterns(patternJSON string) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(patternJSON)))
+	decoder.UseNumber()
+	var pattern map[string]any
+	if err := decoder.Decode(&pattern); err != nil || pattern == nil {
+		return []string{patternJSON}, nil
+	}
+	expanded, err := ebExpand(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns := make([]string, 0, len(expanded))
+	for _, p := range expanded {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(p); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, string(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))))
+	}
+	return patterns, nil
+}
+
+// ebExpand returns the Patterns, without "$or" and with every "exists" and "anything-but" alone in its array,
+// which together match what the object does
+func ebExpand(object map[string]any) ([]map[string]any, error) {
+	alternatives := []map[string]any{{}}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		// the ways of meeting this member's conditions, each as a Pattern to merge with the others'
+		var choices []map[string]any
+		if _, isArray := object[key].([]any); key == "$or" && !isArray {
+			return nil, errors.New("$or must be an array of objects")
+		}
+		switch value := object[key].(type) {
+		case map[string]any:
+			subs, err := ebExpand(value)
+			if err != nil {
+				return nil, err
+			}
+			for _, sub := range subs {
+				choices = append(choices, map[string]any{key: sub})
+			}
+		case []any:
+			if key == "$or" {
+				for _, element := range value {
+					alternative, ok := element.(map[string]any)
+					if !ok {
+						return nil, errors.New("$or must be an array of objects")
+					}
+					subs, err := ebExpand(alternative)
+					if err != nil {
+						return nil, err
+					}
+					choices = append(choices, subs...)
+				}
+				break
+			}
+			for _, values := range ebSplitValues(value) {
+				choices = append(choices, map[string]any{key: values})
+			}
+		default:
+			choices = []map[string]any{{key: value}}
+		}
+		if key == "$or" && len(choices) == 0 {
+			return nil, errors.New("$or must be an array of objects")
+		}
+
+		var combined []map[string]any
+		for _, alternative := range alternatives {
+			for _, choice := range choices {
+				merged, err := ebMerge(alternative, choice)
+				if err != nil {
+					return nil, err
+				}
+				combined = append(combined, merged)
+			}
+		}
+		if len(combined) > maxEventBridgePatterns {
+			return nil, fmt.Errorf("pattern expands into more than %d Quamina Patterns", maxEventBridgePatterns)
+		}
+		alternatives = combined
+	}
+	return alternatives, nil
+}
+
+// ebSplitValues divides the values in a Pattern's array into the arrays that Quamina accepts: one for each
+// "exists" and "anything-but", which must be alone, and one for everything else
+func ebSplitValues(values []any) [][]any {
+	var plain []any
+	var split [][]any
+	for _, value := range values {
+		if special, ok := value.(map[string]any); ok {
+			_, exists := special["exists"]
+			_, anythingBut := special["anything-but"]
+			if exists || anythingBut {
+				split = append(split, []any{value})
+				continue
+			}
+		}
+		plain = append(plain, value)
+	}
+	if len(split) == 0 {
+		return [][]any{values}
+	}
+	if len(plain) > 0 {
+		split = append([][]any{plain}, split...)
+	}
+	return split
+}
+
+// ebMerge returns a Pattern with the members of both a and b, whose objects are merged member by member.
+// Neither a nor b is changed.
+func ebMerge(a, b map[string]any) (map[string]any, error) {
+	merged := make(map[string]any, len(a)+len(b))
+	for key, value := range a {
+		merged[key] = value
+	}
+	for key, value := range b {
+		existing, ok := merged[key]
+		if !ok {
+			merged[key] = value
+			continue
+		}
+		existingObject, ok1 := existing.(map[string]any)
+		object, ok2 := value.(map[string]any)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("field %q is given more than once, inside and outside $or", key)
+		}
+		m, err := ebMerge(existingObject, object)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = m
+	}
+	return merged, nil
+}
@@ -0,0 +1,229 @@
+package quamina
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// ebMatches adds the patterns to a new instance created WithEventBridgeCompat, plus any other options, and
+// checks which of them match each event
+func ebMatches(t *testing.T, patterns map[string]string, events map[string][]string, opts ...Option) {
+	t.Helper()
+	q, err := New(append([]Option{WithEventBridgeCompat()}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, pattern := range patterns {
+		if err := q.AddPattern(x, pattern); err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+	}
+	for event, want := range events {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatalf("%s: %v", event, err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.(string))
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: matched %v, want %v", event, got, want)
+		}
+	}
+}
+
+func TestEventBridgePatterns(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{`{"a": [1]}`, []string{`{"a":[1]}`}},
+		{`{"$or": [{"a": [1]}, {"b": {"c": ["x"]}}]}`, []string{`{"a":[1]}`, `{"b":{"c":["x"]}}`}},
+		{`{"s": ["<&>"], "$or": [{"a": [1]}, {"$or": [{"b": [2]}, {"c": [3]}]}]}`,
+			[]string{`{"a":[1],"s":["<&>"]}`, `{"b":[2],"s":["<&>"]}`, `{"c":[3],"s":["<&>"]}`}},
+		{`{"a": {"b": [1], "$or": [{"c": [2]}, {"d": [3]}]}}`,
+			[]string{`{"a":{"b":[1],"c":[2]}}`, `{"a":{"b":[1],"d":[3]}}`}},
+		{`{"a": {"b": [1]}, "$or": [{"a": {"c": [2]}}, {"d": [3]}]}`,
+			[]string{`{"a":{"b":[1],"c":[2]}}`, `{"a":{"b":[1]},"d":[3]}`}},
+		{`{"a": ["x", {"exists": false}, 1.50, {"anything-but": "y"}]}`,
+			[]string{`{"a":["x",1.50]}`, `{"a":[{"exists":false}]}`, `{"a":[{"anything-but":"y"}]}`}},
+		{`[1]`, []string{`[1]`}},
+	}
+	for _, test := range tests {
+		got, err := eventBridgePatterns(test.pattern)
+		if err != nil {
+			t.Errorf("%s: %v", test.pattern, err)
+		} else if !slices.Equal(got, test.want) {
+			t.Errorf("%s:\n got %s\nwant %s", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestEventBridgeMatching(t *testing.T) {
+	patterns := map[string]string{
+		"or":       `{"source": ["aws.ec2"], "$or": [{"detail": {"state": ["stopped"]}}, {"detail": {"code": [{"numeric": [">=", 500]}]}}]}`,
+		"mixed":    `{"env": ["prod", {"exists": false}]}`,
+		"suffix":   `{"file": [{"suffix": ".png"}, {"suffix": {"equals-ignore-case": ".JPG"}}]}`,
+		"prefix":   `{"file": [{"prefix": {"equals-ignore-case": "IMG_"}}]}`,
+		"range":    `{"price": [{"numeric": [">", 0, "<=", 5]}]}`,
+		"equal":    `{"price": [{"numeric": ["=", 10]}]}`,
+		"cidr":     `{"ip": [{"cidr": "10.0.0.0/24"}, {"cidr": "2001:db8::/32"}]}`,
+		"wildcard": `{"file": [{"wildcard": "a*b.txt"}]}`,
+		"ignore":   `{"file": [{"equals-ignore-case": "README"}]}`,
+		"regexp":   `{"file": [{"regexp": "[rR]ead[mM]e"}]}`,
+	}
+	events := map[string][]string{
+		`{"source": "aws.ec2", "detail": {"state": "stopped"}}`: {"or", "mixed"},
+		`{"source": "aws.ec2", "detail": {"code": 503}}`:        {"or", "mixed"},
+		`{"source": "aws.ec2", "detail": {"code": 404}}`:        {"mixed"},
+		`{"env": "prod", "file": "cat.png"}`:                    {"mixed", "suffix"},
+		`{"env": "test", "file": "IMG_1.jpg"}`:                  {"suffix", "prefix"},
+		`{"file": "img_2.gif"}`:                                 {"mixed", "prefix"},
+		`{"file": "apple-b.txt"}`:                               {"mixed", "wildcard"},
+		`{"file": "ReadMe"}`:                                    {"mixed", "ignore", "regexp"},
+		`{"price": 5.0}`:                                        {"mixed", "range"},
+		`{"price": 0}`:                                          {"mixed"},
+		`{"price": 1e1}`:                                        {"mixed", "equal"},
+		`{"price": "3"}`:                                        {"mixed"},
+		`{"ip": "10.0.0.255"}`:                                  {"mixed", "cidr"},
+		`{"ip": "10.0.1.0"}`:                                    {"mixed"},
+		`{"ip": "2001:db8:1::5"}`:                               {"mixed", "cidr"},
+		`{"ip": "fe80::1%eth0"}`:                                {"mixed"},
+		`{"ip": 10}`:                                            {"mixed"},
+	}
+	ebMatches(t, patterns, events)
+	ebMatches(t, patterns, events, WithPatternDeletion(true))
+}
+
+func TestEventBridgeAnythingBut(t *testing.T) {
+	patterns := map[string]string{
+		"string":   `{"a": [{"anything-but": "x"}]}`,
+		"number":   `{"b": [{"anything-but": 5}]}`,
+		"numbers":  `{"b": [{"anything-but": [1, 2, "two"]}]}`,
+		"prefix":   `{"c": [{"anything-but": {"prefix": "init"}}]}`,
+		"suffix":   `{"c": [{"anything-but": {"suffix": ".txt"}}]}`,
+		"wildcard": `{"d": [{"anything-but": {"wildcard": ["*/lib/*", "*.so"]}}]}`,
+		"ignore":   `{"e": [{"anything-but": {"equals-ignore-case": ["Alpha", "beta"]}}]}`,
+	}
+	events := map[string][]string{
+		`{"a": "x"}`:                  nil,
+		`{"a": "xy"}`:                 {"string"},
+		`{"b": 5.00}`:                 {"numbers"},
+		`{"b": 2}`:                    {"number"},
+		`{"b": "two"}`:                {"number"},
+		`{"b": 3}`:                    {"number", "numbers"},
+		`{"c": "initial.txt"}`:        nil,
+		`{"c": "ini"}`:                {"prefix", "suffix"},
+		`{"c": "init.md"}`:            {"suffix"},
+		`{"c": "a.txt"}`:              {"prefix"},
+		`{"c": "txt"}`:                {"prefix", "suffix"},
+		`{"d": "/usr/lib/x"}`:         nil,
+		`{"d": "x.so"}`:               nil,
+		`{"d": "/usr/bin/x"}`:         {"wildcard"},
+		`{"e": "ALPHA"}`:              nil,
+		`{"e": "BetA"}`:               nil,
+		`{"e": "alphabet"}`:           {"ignore"},
+		`{"e": "alph"}`:               {"ignore"},
+		`{"a": "y", "c": "intro.md"}`: {"string", "prefix", "suffix"},
+	}
+	ebMatches(t, patterns, events)
+}
+
+func TestEventBridgeErrors(t *testing.T) {
+	q, _ := New(WithEventBridgeCompat())
+	bad := map[string]string{
+		`{"a": [{"numeric": [">", "x"]}]}`:               "must be followed by a number",
+		`{"a": [{"numeric": ["~", 1]}]}`:                 "unknown 'numeric' operator",
+		`{"a": [{"numeric": ["=", 1, "<", 2]}]}`:         `"=" alone`,
+		`{"a": [{"numeric": [">", 1, ">", 2]}]}`:         `"=" alone`,
+		`{"a": [{"numeric": [">", 5, "<", 5]}]}`:         "contains no numbers",
+		`{"a": [{"numeric": []}]}`:                       "empty",
+		`{"a": [{"numeric": 5}]}`:                        "must be an array",
+		`{"a": [{"cidr": "10.0.0.1"}]}`:                  "invalid 'cidr'",
+		`{"a": [{"cidr": 10}]}`:                          "must be a string",
+		`{"a": [{"suffix": 1}]}`:                         "must be a string",
+		`{"a": [{"prefix": {"wildcard": "x"}}]}`:         "only be qualified by 'equals-ignore-case'",
+		`{"a": [{"anything-but": {"exists": true}}]}`:    "can't be combined with exists",
+		`{"a": [{"anything-but": {"prefix": []}}]}`:      "non-empty array",
+		`{"a": [{"anything-but": {"wildcard": "a**"}}]}`: "adjacent",
+		`{"a": [{"anything-but": [true]}]}`:              "malformed",
+		`{"$or": {"a": [1]}}`:                            "$or must be an array",
+		`{"$or": []}`:                                    "$or must be an array",
+		`{"$or": [1, 2]}`:                                "$or must be an array",
+		`{"a": [1], "$or": [{"a": [2]}, {"b": [3]}]}`:    `field "a" is given more than once`,
+	}
+	for pattern, want := range bad {
+		err := q.AddPattern("x", pattern)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", pattern, err, want)
+		}
+	}
+
+	// an error in any of the Patterns something expands into means none of them are added
+	if err := q.AddPattern("partial", `{"$or": [{"a": ["ok"]}, {"b": [{"regexp": "x("}]}]}`); err == nil {
+		t.Error("accepted a bad regexp inside $or")
+	}
+	if matches, _ := q.MatchesForEvent([]byte(`{"a": "ok"}`)); len(matches) != 0 {
+		t.Errorf("matched %v after error", matches)
+	}
+
+	// each level doubles the number of Patterns
+	deep := `{"x": [1]}`
+	for i := 0; i < 9; i++ {
+		deep = `{"n": ` + deep + `, "$or": [{"a": [1]}, {"b": [2]}]}`
+	}
+	if err := q.AddPattern("x", deep); err == nil || !strings.Contains(err.Error(), "more than 256") {
+		t.Errorf("expansion limit: %v", err)
+	}
+
+	if _, err := New(WithEventBridgeCompat(), WithEventBridgeCompat()); err == nil {
+		t.Error("accepted WithEventBridgeCompat twice")
+	}
+
+	// without the option, the suffix, numeric, and cidr types are accepted but EventBridge's other forms aren't
+	plain, _ := New()
+	for _, pattern := range []string{
+		`{"a": [{"suffix": "x"}]}`,
+		`{"a": [{"numeric": ["=", 1]}]}`,
+		`{"a": [{"cidr": "10.0.0.0/8"}]}`,
+	} {
+		if err := plain.AddPattern("x", pattern); err != nil {
+			t.Errorf("%s rejected without WithEventBridgeCompat: %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{
+		`{"a": [{"anything-but": "x"}]}`,
+		`{"a": [{"anything-but": [1]}]}`,
+		`{"a": [{"anything-but": {"prefix": "x"}}]}`,
+		`{"a": [{"prefix": {"equals-ignore-case": "x"}}]}`,
+		`{"$or": [{"a": [1]}, {"b": [2]}]}`,
+	} {
+		if err := plain.AddPattern("x", pattern); err == nil {
+			t.Errorf("%s accepted without WithEventBridgeCompat", pattern)
+		}
+	}
+}
+
+func TestEventBridgeCopyAndRebuild(t *testing.T) {
+	q, _ := New(WithEventBridgeCompat(), WithPatternDeletion(true))
+	q2 := q.Copy()
+	if err := q2.AddPattern("cidr", `{"$or": [{"ip": [{"cidr": "192.168.0.0/16"}]}, {"port": [{"numeric": ["<", 1024]}]}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.matcher.(*prunerMatcher).rebuild(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []string{`{"ip": "192.168.7.7"}`, `{"port": 80}`} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != 1 || matches[0] != "cidr" {
+			t.Errorf("%s: matched %v after rebuild", event, matches)
+		}
+	}
+}
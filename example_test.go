@@ -3,6 +3,7 @@ package quamina_test
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"quamina.net/go/quamina/v2"
 )
@@ -52,3 +53,23 @@ func ExampleNew() {
 
 	// Output: pattern matched for event: "premium user"
 }
+
+func ExampleQuamina_FieldPaths() {
+	q, err := quamina.New()
+	if err != nil {
+		log.Fatalf("could not create quamina instance: %v", err)
+	}
+	err = q.AddPattern("premium user", premiumUserPattern)
+	if err != nil {
+		log.Fatalf("could not add pattern: %v", err)
+	}
+
+	// a producer need only send these fields; the "id" and the user's name never affect matching
+	for _, path := range q.FieldPaths() {
+		fmt.Println(strings.Split(path, quamina.SegmentSeparator))
+	}
+
+	// Output:
+	// [type]
+	// [user premiumAccount]
+}
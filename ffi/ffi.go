@@ -0,0 +1,245 @@
+// Package ffi is the surface on which bindings for other languages are built, through the C library in
+// cmd/libquamina or any other foreign-function layer. It's designed to be easy to bind mechanically, so every
+// function reports failure as an *Error with a Code, values cross the boundary as handles, strings, and
+// bytes, and the rules for sharing are stated per handle rather than left to the binding.
+//
+// Handles: New and Copy return a Handle to a Quamina instance, which stays valid until it's passed to
+// Release. Handles are never reused, so a Handle used after its release, or one that was never issued,
+// gets InvalidHandle instead of reaching another instance. Zero is never a valid Handle.
+//
+// Threads: any function may be called from any thread. Calls using the same Handle are serialized, so a
+// Handle can be shared safely, but it matches only one event at a time; to match in parallel, give each
+// thread its own Copy, which shares the original's Patterns. Patterns added or deleted through any of the
+// copies are seen by all of them.
+//
+// Memory: the functions don't keep the bytes they're passed after they return, and what they return
+// belongs to the caller.
+//
+// Panics: a panic in Quamina is recovered and reported as Internal, rather than ending the process that's
+// embedding it.
+package ffi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// ABIVersion is incremented whenever a change to this package would break an existing binding, so that
+// bindings can check they were generated for the library they've loaded.
+const ABIVersion = 1
+
+// Handle identifies a Quamina instance.
+type Handle uint64
+
+// Flags choose the Options of a new instance.
+type Flags uint32
+
+const (
+	// PatternDeletion means WithPatternDeletion(true).
+	PatternDeletion Flags = 1 << iota
+	// EventBridge means WithEventBridgeCompat.
+	EventBridge
+
+	allFlags = PatternDeletion | EventBridge
+)
+
+// Code classifies an Error. The values are part of the ABI, so they'll never be renumbered.
+type Code int32
+
+const (
+	OK                   Code = 0
+	InvalidHandle        Code = 1 // the Handle was never issued, or has been released
+	InvalidArgument      Code = 2 // for example, unknown Flags
+	BadPattern           Code = 3 // AddPattern was given a Pattern Quamina rejects
+	BadEvent             Code = 4 // MatchesForEvent was given an event that isn't a JSON object
+	MemoryBudgetExceeded Code = 5 // see WithMemoryBudget
+	Unsupported          Code = 6 // DeletePatterns on an instance made without PatternDeletion
+	Internal             Code = 7 // a bug in Quamina
+)
+
+var codeNames = map[Code]string{
+	OK:                   "OK",
+	InvalidHandle:        "invalid handle",
+	InvalidArgument:      "invalid argument",
+	BadPattern:           "bad pattern",
+	BadEvent:             "bad event",
+	MemoryBudgetExceeded: "memory budget exceeded",
+	Unsupported:          "unsupported",
+	Internal:             "internal error",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("code %d", int32(c))
+}
+
+// Error is the error returned by the package's functions.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code.String() + ": " + e.Message
+}
+
+// CodeOf returns the Code of err, which is OK if err is nil and Internal if it isn't an *Error.
+func CodeOf(err error) Code {
+	if err == nil {
+		return OK
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return Internal
+}
+
+type instance struct {
+	sync.Mutex
+	q        *quamina.Quamina
+	flags    Flags
+	released bool
+}
+
+var (
+	handlesLock sync.Mutex
+	handles     = map[Handle]*instance{}
+	lastHandle  Handle
+)
+
+// New makes a Quamina instance and returns a Handle to it.
+func New(flags Flags) (h Handle, err error) {
+	defer recoverInternal(&err)
+	if flags&^allFlags != 0 {
+		return 0, &Error{InvalidArgument, fmt.Sprintf("unknown flags %#x", uint32(flags&^allFlags))}
+	}
+	var opts []quamina.Option
+	if flags&PatternDeletion != 0 {
+		opts = append(opts, quamina.WithPatternDeletion(true))
+	}
+	if flags&EventBridge != 0 {
+		opts = append(opts, quamina.WithEventBridgeCompat())
+	}
+	q, err := quamina.New(opts...)
+	if err != nil {
+		return 0, &Error{InvalidArgument, err.Error()}
+	}
+	return register(&instance{q: q, flags: flags}), nil
+}
+
+// Copy returns a Handle to a copy of h's instance, which shares its Patterns and can be used in parallel
+// with it.
+func Copy(h Handle) (copied Handle, err error) {
+	defer recoverInternal(&err)
+	in, err := acquire(h)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Unlock()
+	return register(&instance{q: in.q.Copy(), flags: in.flags}), nil
+}
+
+// Release invalidates h. The Patterns stay with any copies still held.
+func Release(h Handle) (err error) {
+	defer recoverInternal(&err)
+	in, err := acquire(h)
+	if err != nil {
+		return err
+	}
+	defer in.Unlock()
+	in.released = true
+	handlesLock.Lock()
+	delete(handles, h)
+	handlesLock.Unlock()
+	return nil
+}
+
+// AddPattern adds pattern, identified by x, as Quamina's AddPattern does.
+func AddPattern(h Handle, x string, pattern []byte) (err error) {
+	defer recoverInternal(&err)
+	in, err := acquire(h)
+	if err != nil {
+		return err
+	}
+	defer in.Unlock()
+	if err := in.q.AddPattern(x, string(pattern)); err != nil {
+		if errors.Is(err, quamina.ErrMemoryBudgetExceeded) {
+			return &Error{MemoryBudgetExceeded, err.Error()}
+		}
+		return &Error{BadPattern, err.Error()}
+	}
+	return nil
+}
+
+// DeletePatterns deletes the Patterns added with x, which requires an instance made with PatternDeletion.
+func DeletePatterns(h Handle, x string) (err error) {
+	defer recoverInternal(&err)
+	in, err := acquire(h)
+	if err != nil {
+		return err
+	}
+	defer in.Unlock()
+	if in.flags&PatternDeletion == 0 {
+		return &Error{Unsupported, "the instance was made without PatternDeletion"}
+	}
+	if err := in.q.DeletePatterns(x); err != nil {
+		return &Error{Internal, err.Error()}
+	}
+	return nil
+}
+
+// MatchesForEvent returns the X values of the Patterns matching event. Unlike Quamina's MatchesForEvent,
+// the slice returned is the caller's to keep.
+func MatchesForEvent(h Handle, event []byte) (matches []string, err error) {
+	defer recoverInternal(&err)
+	in, err := acquire(h)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Unlock()
+	xs, err := in.q.MatchesForEvent(event)
+	if err != nil {
+		return nil, &Error{BadEvent, err.Error()}
+	}
+	matches = make([]string, len(xs))
+	for i, x := range xs {
+		matches[i] = x.(string)
+	}
+	return matches, nil
+}
+
+func register(in *instance) Handle {
+	handlesLock.Lock()
+	defer handlesLock.Unlock()
+	lastHandle++
+	handles[lastHandle] = in
+	return lastHandle
+}
+
+// acquire returns h's instance, locked, or InvalidHandle
+func acquire(h Handle) (*instance, error) {
+	handlesLock.Lock()
+	in, ok := handles[h]
+	handlesLock.Unlock()
+	if ok {
+		in.Lock()
+		// it may have been released while we waited for the lock
+		if !in.released {
+			return in, nil
+		}
+		in.Unlock()
+	}
+	return nil, &Error{InvalidHandle, fmt.Sprintf("no instance has handle %d", uint64(h))}
+}
+
+func recoverInternal(err *error) {
+	if r := recover(); r != nil {
+		*err = &Error{Internal, fmt.Sprint(r)}
+	}
+}
@@ -0,0 +1,121 @@
+package ffi
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestLifecycle(t *testing.T) {
+	h, err := New(PatternDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddPattern(h, "exact", []byte(`{"a": ["x"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := Copy(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Patterns added through one handle are seen by its copies
+	if err := AddPattern(copied, "prefix", []byte(`{"a": [{"prefix": "x"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := MatchesForEvent(h, []byte(`{"a": "x"}`))
+	slices.Sort(matches)
+	if err != nil || !slices.Equal(matches, []string{"exact", "prefix"}) {
+		t.Errorf("matched %v, %v", matches, err)
+	}
+
+	if err := DeletePatterns(h, "prefix"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Release(h); err != nil {
+		t.Fatal(err)
+	}
+	matches, err = MatchesForEvent(copied, []byte(`{"a": "x"}`))
+	if err != nil || !slices.Equal(matches, []string{"exact"}) {
+		t.Errorf("copy matched %v, %v", matches, err)
+	}
+	if err := Release(copied); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodes(t *testing.T) {
+	plain, err := New(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Release(plain)
+	released, _ := New(0)
+	_ = Release(released)
+	_, unknownFlags := New(1 << 20)
+	_, badEvent := MatchesForEvent(plain, []byte(`[`))
+	_, copyReleased := Copy(released)
+
+	for _, test := range []struct {
+		err  error
+		code Code
+	}{
+		{nil, OK},
+		{unknownFlags, InvalidArgument},
+		{AddPattern(plain, "x", []byte(`{"a": 1`)), BadPattern},
+		{badEvent, BadEvent},
+		{DeletePatterns(plain, "x"), Unsupported},
+		{AddPattern(released, "x", []byte(`{"a": [1]}`)), InvalidHandle},
+		{copyReleased, InvalidHandle},
+		{Release(released), InvalidHandle},
+		{Release(0), InvalidHandle},
+		{errors.New("not from ffi"), Internal},
+	} {
+		if code := CodeOf(test.err); code != test.code {
+			t.Errorf("%v has code %v, wanted %v", test.err, code, test.code)
+		}
+	}
+	if s := Code(99).String(); s != "code 99" {
+		t.Errorf("unknown code is %q", s)
+	}
+}
+
+// TestSharedHandle uses one handle, and copies of it, from many goroutines; run it with -race
+func TestSharedHandle(t *testing.T) {
+	h, err := New(PatternDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Release(h)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			use := h
+			if g%2 == 0 {
+				copied, err := Copy(h)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer Release(copied)
+				use = copied
+			}
+			x := fmt.Sprint(g)
+			if err := AddPattern(use, x, []byte(fmt.Sprintf(`{"g": [%d]}`, g))); err != nil {
+				t.Error(err)
+				return
+			}
+			for i := 0; i < 100; i++ {
+				matches, err := MatchesForEvent(use, []byte(fmt.Sprintf(`{"g": %d}`, g)))
+				if err != nil || !slices.Contains(matches, x) {
+					t.Errorf("goroutine %d matched %v, %v", g, matches, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
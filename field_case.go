@@ -0,0 +1,57 @@
+package quamina
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// WithCaseInsensitiveFieldNames arranges that the field names in Patterns match those in Events regardless of
+// case, so that {"UserName": ["alice"]} matches both {"username": "alice"} and {"USERNAME": "alice"}. This is
+// for Events gathered from producers that can't agree on how to capitalize their field names. Names are
+// compared as Go's strings.ToLower leaves them, segment by segment, so a Pattern's nested fields are matched
+// the same way. Values are still compared with their case; "equals-ignore-case" does that. The option applies
+// to every way of matching, including MatchesForStruct and MatchesForTypedFields, and to instances created
+// with Copy. This option call may not be provided more than once.
+func WithCaseInsensitiveFieldNames() Option {
+	return func(q *Quamina) error {
+		if q.foldFieldNames {
+			return errors.New("case-insensitive field names specified more than once")
+		}
+		q.foldFieldNames = true
+		return nil
+	}
+}
+
+// setCaseInsensitiveFieldNames makes the matcher fold the paths in Patterns, and those Flatteners look up in its
+// segmentsTree, to lower case. Like setEventBridgeCompat, it's only called before any Patterns are added.
+func (m *coreMatcher) setCaseInsensitiveFieldNames() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.foldFieldNames = true
+	currentFields := m.fields()
+	tree := currentFields.segmentsTree.copy()
+	tree.foldCase = true
+	m.updateable.Store(&coreFields{state: currentFields.state, segmentsTree: tree})
+}
+
+// foldPath is the form in which the matcher stores a path; it's folded to lower case if field names are
+// matched without regard to case
+func (m *coreMatcher) foldPath(path string) string {
+	if m.foldFieldNames {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// foldName returns name folded to lower case as strings.ToLower would fold it. The common case, a name with
+// no upper-case letters, is returned as it is, without allocating.
+func foldName(name []byte) []byte {
+	for _, b := range name {
+		if b >= utf8.RuneSelf || (b >= 'A' && b <= 'Z') {
+			return bytes.ToLower(name)
+		}
+	}
+	return name
+}
@@ -0,0 +1,101 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestCaseInsensitiveFieldNames(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, err := New(WithCaseInsensitiveFieldNames(), WithPatternDeletion(deletion))
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns := map[X]string{
+			"user":    `{"UserName": ["alice"]}`,
+			"lower":   `{"username": ["bob"]}`,
+			"nested":  `{"Detail": {"StatusCode": [500]}}`,
+			"missing": `{"Region": [{"exists": false}]}`,
+			"ünicode": `{"ÜBER": ["x"]}`,
+			"order":   `{"Zone": ["z"], "alpha": ["a"]}`,
+		}
+		for x, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for event, want := range map[string][]X{
+			`{"username": "alice", "region": "eu"}`:           {"user"},
+			`{"USERNAME": "alice", "REGION": "eu"}`:           {"user"},
+			`{"UserName": "bob", "Region": "eu"}`:             {"lower"},
+			`{"userName": "Alice", "region": "eu"}`:           {},
+			`{"detail": {"statuscode": 500}, "region": "eu"}`: {"nested"},
+			`{"DETAIL": [{"statusCode": 500}]}`:               {"nested", "missing"},
+			`{"über": "x", "region": "eu"}`:                   {"ünicode"},
+			`{"ALPHA": "a", "zone": "z", "region": "eu"}`:     {"order"},
+		} {
+			for _, instance := range []*Quamina{q, q.Copy()} {
+				matches, err := instance.MatchesForEvent([]byte(event))
+				if err != nil || !containsExactly(matches, want) {
+					t.Errorf("deletion=%v, %s: %v, %v", deletion, event, matches, err)
+				}
+			}
+		}
+		if deletion {
+			// a rebuild keeps folding
+			_ = q.DeletePatterns("lower")
+			if err := q.matcher.(*prunerMatcher).rebuild(false); err != nil {
+				t.Fatal(err)
+			}
+			if matches, _ := q.MatchesForEvent([]byte(`{"USERNAME": "alice", "Region": "eu"}`)); !containsExactly(matches, []X{"user"}) {
+				t.Errorf("after rebuild: %v", matches)
+			}
+		}
+
+		type detail struct {
+			StatusCode int
+		}
+		matches, err := q.MatchesForStruct(struct {
+			DETAIL detail
+			Region string
+		}{detail{500}, "eu"})
+		if err != nil || !containsExactly(matches, []X{"nested"}) {
+			t.Errorf("struct: %v, %v", matches, err)
+		}
+
+		matches, err = q.MatchesForTypedFields([]TypedField{
+			{Path: []byte("USERNAME"), Value: "alice"},
+			{Path: []byte("REGION"), Value: "eu"},
+		})
+		if err != nil || !containsExactly(matches, []X{"user"}) {
+			t.Errorf("typed fields: %v, %v", matches, err)
+		}
+	}
+
+	exact, _ := New()
+	_ = exact.AddPattern("p", `{"UserName": ["alice"]}`)
+	if matches, _ := exact.MatchesForEvent([]byte(`{"username": "alice"}`)); len(matches) != 0 {
+		t.Errorf("matched without folding: %v", matches)
+	}
+
+	if _, err := New(WithCaseInsensitiveFieldNames(), WithCaseInsensitiveFieldNames()); err == nil {
+		t.Error("accepted option twice")
+	}
+}
+
+func TestFoldName(t *testing.T) {
+	for in, want := range map[string]string{
+		"":         "",
+		"abc":      "abc",
+		"AbC":      "abc",
+		"ÜBER":     "über",
+		"a-b_c.1x": "a-b_c.1x",
+	} {
+		if got := string(foldName([]byte(in))); got != want {
+			t.Errorf("%q: got %q", in, got)
+		}
+	}
+	name := []byte("already")
+	if allocs := testing.AllocsPerRun(100, func() { _ = foldName(name) }); allocs != 0 {
+		t.Errorf("%v allocations", allocs)
+	}
+}
@@ -18,11 +18,16 @@ type fieldMatcher struct {
 // fieldMatcher.
 // matches contains the X values that arrival at this state implies have matched.
 // existsTrue and existsFalse record those types of patterns; traversal doesn't require looking at a valueMatcher
+// lastPath is the lexically greatest of the paths in transitions and existsTrue, and hasPaths says whether there
+// are any. The fields of an event are matched in order of their paths, so once one past lastPath is reached,
+// none of the rest can transition from this state; see exhaustedBy. These are set by update.
 type fmFields struct {
 	transitions map[string]*valueMatcher
 	matches     []X
 	existsTrue  map[string]*fieldMatcher
 	existsFalse map[string]*fieldMatcher
+	lastPath    string
+	hasPaths    bool
 }
 
 // fields / update / addExistsFalseFailure / addMatch exist to insulate callers from dealing with
@@ -32,9 +37,29 @@ func (m *fieldMatcher) fields() *fmFields {
 }
 
 func (m *fieldMatcher) update(fields *fmFields) {
+	fields.lastPath, fields.hasPaths = "", false
+	for path := range fields.transitions {
+		fields.notePath(path)
+	}
+	for path := range fields.existsTrue {
+		fields.notePath(path)
+	}
 	m.updateable.Store(fields)
 }
 
+func (f *fmFields) notePath(path string) {
+	if !f.hasPaths || path > f.lastPath {
+		f.lastPath, f.hasPaths = path, true
+	}
+}
+
+// exhaustedBy reports whether neither a field with the given path nor any field after it can lead to a
+// transition from this state, so that there's no need to try them. exists:false transitions depend on
+// which fields are absent rather than present, so a state with any is never exhausted.
+func (f *fmFields) exhaustedBy(path []byte) bool {
+	return len(f.existsFalse) == 0 && (!f.hasPaths || string(path) > f.lastPath)
+}
+
 func (m *fieldMatcher) addMatch(x X) {
 	current := m.fields()
 	newFields := &fmFields{
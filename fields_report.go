@@ -0,0 +1,123 @@
+package quamina
+
+// FieldReport describes the matchers attached to one field; see Quamina.FieldsReport
+type FieldReport struct {
+	// ValueMatchers is the number of matchers for the field's values. Fields are matched in the order of their
+	// paths, and a field reached after different values of the fields before it has a matcher for each, so a
+	// high number here means a field whose Patterns are spread across many combinations of other fields.
+	ValueMatchers int
+	// Exacts is the number of values, in all the ValueMatchers, matched with a single lookup
+	Exacts int
+	// Automata is the number of ValueMatchers with automata, for values that aren't exact or whose field has
+	// other kinds of Pattern too, and States is the number of states in them
+	Automata int
+	States   int
+	// Prefixes is the number of ValueMatchers whose values are all prefixes, which are matched with a radix tree
+	Prefixes int
+	// Ranges is the number of ValueMatchers with numeric ranges
+	Ranges int
+	// Substrings is the number of ValueMatchers matching Patterns, such as "*needle*", that look for substrings
+	Substrings int
+	// Exists is the number of "exists" tests of the field, true or false
+	Exists int
+}
+
+// FieldsReport returns a FieldReport for each field that Patterns use, by the field's path, showing how many
+// matchers of which kinds are attached to it. Fields with many matchers, or with big automata, are the ones
+// that most affect performance; they are candidates for indexing upstream of Quamina, or for Patterns
+// restructured to share more. Like GetMatcherStats, it should not be run in parallel with AddPattern calls.
+func (q *Quamina) FieldsReport() map[string]FieldReport {
+	return q.matcher.fieldsReport()
+}
+
+func (m *coreMatcher) fieldsReport() map[string]FieldReport {
+	w := fieldsWalk{
+		reports:   make(map[string]*FieldReport),
+		fmVisited: make(map[*fieldMatcher]bool),
+		seen:      make(map[*faState]bool),
+	}
+	w.fieldMatcher(m.fields().state)
+	reports := make(map[string]FieldReport, len(w.reports))
+	for path, report := range w.reports {
+		reports[path] = *report
+	}
+	return reports
+}
+
+type fieldsWalk struct {
+	reports   map[string]*FieldReport
+	fmVisited map[*fieldMatcher]bool
+	seen      map[*faState]bool
+}
+
+func (w *fieldsWalk) report(path string) *FieldReport {
+	report, ok := w.reports[path]
+	if !ok {
+		report = &FieldReport{}
+		w.reports[path] = report
+	}
+	return report
+}
+
+func (w *fieldsWalk) fieldMatcher(fm *fieldMatcher) {
+	if w.fmVisited[fm] {
+		return
+	}
+	w.fmVisited[fm] = true
+	fields := fm.fields()
+	for path, vm := range fields.transitions {
+		report := w.report(path)
+		report.ValueMatchers++
+		w.valueMatcher(vm.fields(), report)
+	}
+	for _, exists := range []map[string]*fieldMatcher{fields.existsTrue, fields.existsFalse} {
+		for path, next := range exists {
+			w.report(path).Exists++
+			w.fieldMatcher(next)
+		}
+	}
+}
+
+func (w *fieldsWalk) valueMatcher(fields *vmFields, report *FieldReport) {
+	if fields.singletonMatch != nil {
+		report.Exacts++
+		w.fieldMatcher(fields.singletonTransition)
+	}
+	report.Exacts += len(fields.exacts)
+	for _, next := range fields.exacts {
+		w.fieldMatcher(next)
+	}
+	if fields.substrings != nil {
+		report.Substrings++
+		for _, pattern := range fields.substrings.patterns {
+			w.fieldMatcher(pattern.next)
+		}
+	}
+	if fields.prefixes != nil {
+		report.Prefixes++
+		fields.prefixes.visit(w.fieldMatcher)
+	}
+	if fields.ranges != nil {
+		report.Ranges++
+		fields.ranges.visit(w.fieldMatcher)
+	}
+	if fields.start == nil {
+		return
+	}
+	report.Automata++
+	todo := []*faState{fields.start}
+	for len(todo) > 0 {
+		state := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if state == nil || w.seen[state] {
+			continue
+		}
+		w.seen[state] = true
+		report.States++
+		todo = append(todo, state.table.steps...)
+		todo = append(todo, state.table.epsilons...)
+		for _, next := range state.fieldTransitions {
+			w.fieldMatcher(next)
+		}
+	}
+}
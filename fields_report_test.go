@@ -0,0 +1,44 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestFieldsReport(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, _ := New(WithPatternDeletion(deletion), WithEventBridgeCompat())
+		patterns := []string{
+			`{"kind": ["order"], "amount": [{"numeric": [">", 100]}]}`,
+			`{"kind": ["refund"], "amount": [{"numeric": ["<", 0]}]}`,
+			`{"kind": ["audit"]}`,
+			`{"name": [{"prefix": "abc"}, {"prefix": "xyz"}]}`,
+			`{"note": [{"shellstyle": "*urgent*"}]}`,
+			`{"note": [{"regexp": "a(b|c)*d"}], "user": [{"exists": false}]}`,
+		}
+		for _, pattern := range patterns {
+			if err := q.AddPattern(pattern, pattern); err != nil {
+				t.Fatal(err)
+			}
+		}
+		report := q.FieldsReport()
+		if len(report) != 5 {
+			t.Errorf("deletion=%v: %+v", deletion, report)
+		}
+		// fields are matched in order of their paths, so kind follows each of the amount ranges, and the start
+		if r := report["amount"]; r.ValueMatchers != 1 || r.Ranges != 1 || r.Exacts != 0 {
+			t.Errorf("deletion=%v: amount %+v", deletion, r)
+		}
+		if r := report["kind"]; r.ValueMatchers != 3 || r.Exacts != 3 || r.Automata != 0 {
+			t.Errorf("deletion=%v: kind %+v", deletion, r)
+		}
+		if r := report["name"]; r.Prefixes != 1 || r.Automata != 0 {
+			t.Errorf("deletion=%v: name %+v", deletion, r)
+		}
+		if r := report["note"]; r.ValueMatchers != 1 || r.Automata != 1 || r.States == 0 {
+			t.Errorf("deletion=%v: note %+v", deletion, r)
+		}
+		if r := report["user"]; r.Exists != 1 || r.ValueMatchers != 0 {
+			t.Errorf("deletion=%v: user %+v", deletion, r)
+		}
+	}
+}
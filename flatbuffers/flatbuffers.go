@@ -0,0 +1,291 @@
+// Package flatbuffers provides a Flattener for FlatBuffers, so that telemetry systems standardized on them can
+// match their messages against Quamina Patterns directly. The Flattener reads the fields of a FlatBuffer in
+// place, guided by a reflection schema, the .bfbs file that flatc --binary --schema writes, so the program
+// needn't include code generated for the schema, and it reads only the fields that Patterns use.
+//
+// A FlatBuffer is flattened as if it were the JSON that flatc would write for it: fields are named as in the
+// schema, tables and structs are objects, vectors and fixed-length arrays are arrays, and enum values are
+// their names, with the values of bit_flags enums the names of their flags, separated by spaces, as in
+// "Red Blue". A union is an object, beside the field whose name ends with _type that names its type, and a
+// [ubyte] field with the nested_flatbuffer attribute is the table it holds. As in flatc's JSON, fields that
+// aren't present, including scalars with their default values, and deprecated fields are left out; so are
+// fields with the flexbuffer attribute, and fields with 64-bit offsets, which aren't supported. Use the
+// Flattener like this:
+//
+//	schema, err := flatbuffers.ParseSchema(bfbs)
+//	f, err := flatbuffers.NewFlattener(schema, "")
+//	q, err := quamina.New(quamina.WithFlattener(f))
+package flatbuffers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/internal/flatten"
+)
+
+// maxDepth limits the nesting of tables, as the FlatBuffers verifier does, since a FlatBuffer whose offsets
+// form a cycle would otherwise be endless
+const maxDepth = 64
+
+// Flattener flattens FlatBuffers whose root is one table of a Schema. Like Quamina instances, a Flattener is
+// not safe for concurrent use; Copy makes one for another goroutine.
+type Flattener struct {
+	schema *Schema
+	root   int
+	r      reader
+	w      flatten.Writer
+	depth  int
+	flags  []string
+}
+
+// NewFlattener returns a Flattener for FlatBuffers whose root is the table with the fully qualified name, such
+// as "MyGame.Example.Monster", or, if name is "", the schema's root_type.
+func NewFlattener(schema *Schema, name string) (*Flattener, error) {
+	root := schema.root
+	if name != "" {
+		root = -1
+		for i, o := range schema.objects {
+			if o.name == name {
+				root = i
+			}
+		}
+		if root < 0 {
+			return nil, fmt.Errorf("flatbuffers: no table %s in the schema", name)
+		}
+	}
+	if root < 0 {
+		return nil, fmt.Errorf("flatbuffers: the schema has no root_type")
+	}
+	if schema.objects[root].isStruct {
+		return nil, fmt.Errorf("flatbuffers: %s is a struct, not a table", schema.objects[root].name)
+	}
+	return &Flattener{schema: schema, root: root}, nil
+}
+
+// Copy returns a new Flattener for the same table.
+func (f *Flattener) Copy() quamina.Flattener {
+	return &Flattener{schema: f.schema, root: f.root}
+}
+
+// Flatten reads the FlatBuffer in event, returning an error if any offset it follows lies outside it. The
+// Fields returned are valid until the next call.
+func (f *Flattener) Flatten(event []byte, tracker quamina.SegmentsTreeTracker) ([]quamina.Field, error) {
+	f.w.Reset()
+	f.r = reader{buf: event}
+	f.depth = 0
+	f.writeTable(f.schema.objects[f.root], f.r.root(), tracker)
+	if f.r.err != nil {
+		return nil, f.r.err
+	}
+	return f.w.Fields(), nil
+}
+
+// writeTable writes the fields of the table at pos as the members of the object at node
+func (f *Flattener) writeTable(o *object, pos uint32, node quamina.SegmentsTreeTracker) {
+	if f.depth++; f.depth > maxDepth {
+		f.r.err = fmt.Errorf("flatbuffers: tables nested more than %d deep", maxDepth)
+	}
+	defer func() { f.depth-- }()
+	for _, fd := range o.fields {
+		if f.r.err != nil {
+			return
+		}
+		if fd.deprecated || fd.flex || fd.typ.base == typeVector64 || !flatten.Used(node, fd.name) {
+			continue
+		}
+		loc := f.r.field(pos, fd.offset)
+		if loc == 0 {
+			continue
+		}
+		sink := f.w.Member(node, fd.name)
+		switch fd.typ.base {
+		case typeVector:
+			f.writeVector(sink, fd, pos, loc)
+		case typeUnion:
+			if object := f.unionObject(fd, f.r.scalar(f.r.field(pos, fd.unionType.offset), typeUType)); object != nil {
+				f.writeObject(sink, object, f.r.indirect(loc), false)
+			}
+		default:
+			f.writeValue(sink, fd.typ.base, fd.typ.index, loc)
+		}
+	}
+}
+
+// writeStruct writes the fields of the struct at pos as the members of the object at node
+func (f *Flattener) writeStruct(o *object, pos uint32, node quamina.SegmentsTreeTracker) {
+	for _, fd := range o.fields {
+		if !flatten.Used(node, fd.name) {
+			continue
+		}
+		sink := f.w.Member(node, fd.name)
+		if fd.typ.base == typeArray {
+			array, ok := sink.Array()
+			if !ok {
+				continue
+			}
+			size := f.elementSize(fd.typ.element, fd.typ.index)
+			for i := 0; i < fd.typ.fixedLength; i++ {
+				array.Next()
+				f.writeValue(array, fd.typ.element, fd.typ.index, pos+fd.offset+uint32(i)*size)
+			}
+			array.End()
+			continue
+		}
+		f.writeValue(sink, fd.typ.base, fd.typ.index, pos+fd.offset)
+	}
+}
+
+// writeVector writes the vector that the field fd, at loc in the table at pos, refers to
+func (f *Flattener) writeVector(sink flatten.Sink, fd *field, pos, loc uint32) {
+	element := fd.typ.element
+	if fd.nested >= 0 {
+		// the bytes are a FlatBuffer, whose offsets are relative, so it can be read in place
+		bytes := f.r.vector(loc, 1)
+		if bytes.count > 0 {
+			f.writeObject(sink, f.schema.objects[fd.nested], f.r.indirect(bytes.start), false)
+		}
+		return
+	}
+	size := f.elementSize(element, fd.typ.index)
+	v := f.r.vector(loc, size)
+	var types vector
+	if element == typeUnion {
+		types = f.r.vector(f.r.field(pos, fd.unionType.offset), 1)
+		if types.count != v.count {
+			f.r.err = fmt.Errorf("flatbuffers: %s has %d types for %d values", fd.name, types.count, v.count)
+			return
+		}
+	}
+	array, ok := sink.Array()
+	if !ok {
+		return
+	}
+	defer array.End()
+	for i := uint32(0); i < v.count && f.r.err == nil; i++ {
+		array.Next()
+		elementLoc := v.start + i*size
+		if element == typeUnion {
+			if object := f.unionObject(fd, f.r.scalar(types.start+i, typeUType)); object != nil {
+				f.writeObject(array, object, f.r.indirect(elementLoc), false)
+			}
+			continue
+		}
+		f.writeValue(array, element, fd.typ.index, elementLoc)
+	}
+}
+
+// elementSize returns the size of a value of type t in a vector or array
+func (f *Flattener) elementSize(t baseType, index int) uint32 {
+	switch {
+	case t.isScalar():
+		return scalarSizes[t]
+	case t == typeObj && f.schema.objects[index].isStruct:
+		return f.schema.objects[index].byteSize
+	}
+	return 4 // an offset
+}
+
+// writeValue writes the value of type t at loc; index is the object or enum of the type, if it has one
+func (f *Flattener) writeValue(sink flatten.Sink, t baseType, index int, loc uint32) {
+	switch t {
+	case typeBool:
+		sink.Literal(strconv.FormatBool(f.r.scalar(loc, t) != 0))
+	case typeFloat, typeDouble:
+		writeFloat(sink, f.r.float(loc, t), int(scalarSizes[t])*8)
+	case typeString:
+		sink.String(f.r.string(loc))
+	case typeObj:
+		o := f.schema.objects[index]
+		if o.isStruct {
+			f.writeObject(sink, o, loc, true)
+		} else {
+			f.writeObject(sink, o, f.r.indirect(loc), false)
+		}
+	default:
+		if !t.isScalar() {
+			f.r.err = fmt.Errorf("flatbuffers: unexpected type %d", t)
+			return
+		}
+		n := f.r.scalar(loc, t)
+		if index >= 0 {
+			if name, ok := f.enumName(f.schema.enums[index], n); ok {
+				sink.String(name)
+				return
+			}
+		}
+		if t == typeULong {
+			sink.Number(strconv.FormatUint(uint64(n), 10))
+		} else {
+			sink.Number(strconv.FormatInt(n, 10))
+		}
+	}
+}
+
+func (f *Flattener) writeObject(sink flatten.Sink, o *object, pos uint32, isStruct bool) {
+	node, ok := sink.Object()
+	if !ok || f.r.err != nil {
+		return
+	}
+	if isStruct {
+		f.writeStruct(o, pos, node)
+	} else {
+		f.writeTable(o, pos, node)
+	}
+}
+
+// unionObject returns the table that a union with the type value holds, or nil if it's NONE or unknown
+func (f *Flattener) unionObject(fd *field, value int64) *object {
+	for _, v := range f.schema.enums[fd.typ.index].values {
+		if v.value == value && v.object >= 0 {
+			return f.schema.objects[v.object]
+		}
+	}
+	return nil
+}
+
+// enumName returns the name of the enum value n or, for bit_flags enums, the names of its flags, or false if
+// they aren't all named
+func (f *Flattener) enumName(e *enum, n int64) (string, bool) {
+	for _, v := range e.values {
+		if v.value == n {
+			return v.name, true
+		}
+	}
+	if !e.bitFlags || n == 0 {
+		return "", false
+	}
+	f.flags = f.flags[:0]
+	remaining := uint64(n)
+	for _, v := range e.values {
+		if flag := uint64(v.value); flag != 0 && remaining&flag == flag {
+			f.flags = append(f.flags, v.name)
+			remaining &^= flag
+		}
+	}
+	if remaining != 0 {
+		return "", false
+	}
+	return strings.Join(f.flags, " "), true
+}
+
+// writeFloat writes a float as flatc does, with NaN and the infinities as strings
+func writeFloat(sink flatten.Sink, n float64, bits int) {
+	switch {
+	case math.IsNaN(n):
+		sink.String("nan")
+	case math.IsInf(n, 1):
+		sink.String("inf")
+	case math.IsInf(n, -1):
+		sink.String("-inf")
+	default:
+		format := byte('f')
+		if abs := math.Abs(n); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+		sink.Number(strconv.FormatFloat(n, format, -1, bits))
+	}
+}
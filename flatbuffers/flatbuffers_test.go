@@ -0,0 +1,244 @@
+package flatbuffers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func monster(t *testing.T) (*Schema, []byte) {
+	t.Helper()
+	bfbs, err := os.ReadFile("testdata/monster_test.bfbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := ParseSchema(bfbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon, err := os.ReadFile("testdata/monsterdata_test.mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema, mon
+}
+
+func matches(t *testing.T, f quamina.Flattener, event []byte, pattern string) bool {
+	t.Helper()
+	q, err := quamina.New(quamina.WithFlattener(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("p", pattern); err != nil {
+		t.Fatalf("%s: %v", pattern, err)
+	}
+	matches, err := q.MatchesForEvent(event)
+	if err != nil {
+		t.Fatalf("%s: %v", pattern, err)
+	}
+	return len(matches) == 1
+}
+
+// leafPatterns returns a Pattern for each leaf value in the JSON value v, which is the member at path
+func leafPatterns(path []string, v any) []string {
+	switch v := v.(type) {
+	case map[string]any:
+		var patterns []string
+		for name, member := range v {
+			patterns = append(patterns, leafPatterns(append(path[:len(path):len(path)], name), member)...)
+		}
+		return patterns
+	case []any:
+		var patterns []string
+		for _, element := range v {
+			patterns = append(patterns, leafPatterns(path, element)...)
+		}
+		return patterns
+	}
+	var pattern any = []any{v}
+	for i := len(path) - 1; i >= 0; i-- {
+		pattern = map[string]any{path[i]: pattern}
+	}
+	b, _ := json.Marshal(pattern)
+	return []string{string(b)}
+}
+
+// monsterJSON is monsterdata_test.mon as flatc writes it in JSON
+const monsterJSON = `{
+  "pos": {"x": 1.0, "y": 2.0, "z": 3.0, "test1": 3.0, "test2": "Green", "test3": {"a": 5, "b": 6}},
+  "hp": 80,
+  "name": "MyMonster",
+  "inventory": [0, 1, 2, 3, 4],
+  "test_type": "Monster",
+  "test": {"name": "Fred"},
+  "test4": [{"a": 10, "b": 20}, {"a": 30, "b": 40}],
+  "testarrayofstring": ["test1", "test2"],
+  "enemy": {"name": "Fred"},
+  "testbool": true,
+  "testhashs32_fnv1": -579221183,
+  "testhashu32_fnv1": 3715746113,
+  "testhashs64_fnv1": 7930699090847568257,
+  "testhashu64_fnv1": 7930699090847568257,
+  "testhashs32_fnv1a": -1904106383,
+  "testhashu32_fnv1a": 2390860913,
+  "testhashs64_fnv1a": 4898026182817603057,
+  "testhashu64_fnv1a": 4898026182817603057,
+  "testarrayofbools": [true, false, true],
+  "testarrayofsortedstruct": [{"id": 0, "distance": 45}, {"id": 1, "distance": 21}, {"id": 5, "distance": 12}],
+  "test5": [{"a": 10, "b": 20}, {"a": 30, "b": 40}],
+  "vector_of_longs": [1, 100, 10000, 1000000, 100000000],
+  "vector_of_doubles": [-1.7976931348623157e308, 0, 1.7976931348623157e308],
+  "scalar_key_sorted_tables": [{"id": "miss"}, {"id": "hit", "val": 10, "count": 1}],
+  "native_inline": {"a": 1, "b": 2}
+}`
+
+// TestMonster checks that every value in the JSON for the Monster is in the same place in its Fields
+func TestMonster(t *testing.T) {
+	schema, mon := monster(t)
+	f, err := NewFlattener(schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder := json.NewDecoder(strings.NewReader(monsterJSON))
+	decoder.UseNumber()
+	var monster map[string]any
+	if err := decoder.Decode(&monster); err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range leafPatterns(nil, monster) {
+		if !matches(t, f, mon, pattern) {
+			t.Errorf("%s didn't match", pattern)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	schema, mon := monster(t)
+	f, err := NewFlattener(schema, "MyGame.Example.Monster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"scalar_key_sorted_tables": {"id": ["hit"], "val": [10]}}`, true},
+		{`{"scalar_key_sorted_tables": {"id": ["miss"], "val": [10]}}`, false},
+		{`{"test4": {"a": [10], "b": [40]}}`, false},
+		{`{"pos": {"test3": {"a": [5], "b": [6]}}, "test": {"name": ["Fred"]}}`, true},
+		{`{"test": {"name": ["Barney"]}}`, false},
+		{`{"name": [{"prefix": "My"}], "hp": [{"exists": true}]}`, true},
+		{`{"mana": [{"exists": true}]}`, false},
+		{`{"color": [{"exists": true}]}`, false},
+		{`{"friendly": [{"exists": true}]}`, false},
+		{`{"testf": [{"exists": true}]}`, false},
+		{`{"testarrayofbools": [false]}`, true},
+		{`{"testhashu32_fnv1": [3715746113], "testhashs32_fnv1": [-579221183]}`, true},
+		{`{"vector_of_longs": [100000000], "vector_of_doubles": [0]}`, true},
+		{`{"pos": {"test2": ["Green"]}}`, true},
+	} {
+		if got := matches(t, f, mon, test.pattern); got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+}
+
+// TestNested checks a Monster whose testnestedflatbuffer holds another, built by hand because
+// monsterdata_test.mon has none
+func TestNested(t *testing.T) {
+	schema, _ := monster(t)
+	f, _ := NewFlattener(schema, "")
+	// a Monster named N: the root offset, a vtable with name at 10, the table, and the string
+	inner := le(uint32(16), uint16(12), uint16(8), uint16(0), uint16(0), uint16(0), uint16(4),
+		int32(12), uint32(4), uint32(1), "N\x00\x00\x00")
+	// a Monster named O, with inner at testnestedflatbuffer, 30 in the vtable
+	outer := le(uint32(36), uint16(32), uint16(12), uint16(0), uint16(0), uint16(0), uint16(4),
+		uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(0), uint16(8), int32(32), uint32(8), uint32(12), uint32(1), "O\x00\x00\x00", uint32(len(inner)), string(inner))
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"name": ["O"], "testnestedflatbuffer": {"name": ["N"]}}`, true},
+		{`{"testnestedflatbuffer": {"name": ["O"]}}`, false},
+		{`{"testnestedflatbuffer": {"hp": [{"exists": false}]}}`, true},
+	} {
+		if got := matches(t, f, outer, test.pattern); got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+	// the nested Monster's root mustn't lead outside the buffer
+	binary.LittleEndian.PutUint32(outer[60:], 1000)
+	q, _ := quamina.New(quamina.WithFlattener(f))
+	_ = q.AddPattern("p", `{"testnestedflatbuffer": {"name": ["N"]}}`)
+	if _, err := q.MatchesForEvent(outer); err == nil {
+		t.Error("bad nested root accepted")
+	}
+}
+
+// le returns the values, written little-endian
+func le(values ...any) []byte {
+	var b bytes.Buffer
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			b.WriteString(s)
+		} else {
+			_ = binary.Write(&b, binary.LittleEndian, v)
+		}
+	}
+	return b.Bytes()
+}
+
+func TestNewFlattener(t *testing.T) {
+	schema, _ := monster(t)
+	for _, name := range []string{"MyGame.Example.Nope", "MyGame.Example.Vec3"} {
+		if _, err := NewFlattener(schema, name); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+	f, err := NewFlattener(schema, "MyGame.Example.Stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matches(t, f, le(uint32(12), uint16(6), uint16(8), uint16(4), uint16(0), int32(8), uint32(4), uint32(3), "hit\x00"),
+		`{"id": ["hit"]}`) {
+		t.Error("Stat didn't match")
+	}
+}
+
+func TestBadBuffers(t *testing.T) {
+	schema, mon := monster(t)
+	f, _ := NewFlattener(schema, "")
+	q, _ := quamina.New(quamina.WithFlattener(f))
+	_ = q.AddPattern("p", `{"testarrayoftables": {"name": ["Wilma"]}, "testnestedflatbuffer": {"name": ["x"]}}`)
+	for i := 0; i < len(mon); i++ {
+		// truncated buffers either fail or, if what's cut off isn't read, succeed
+		_, _ = q.MatchesForEvent(mon[:i])
+	}
+	for _, event := range [][]byte{nil, {1, 2}, {0xff, 0xff, 0xff, 0xff}, {4, 0, 0, 0, 0xf0, 0xff, 0xff, 0x7f}} {
+		if _, err := q.MatchesForEvent(event); err == nil {
+			t.Errorf("%v accepted", event)
+		}
+	}
+	// corrupting the bytes one after another mustn't make the Flattener panic or loop
+	corrupt := bytes.Clone(mon)
+	for i := 4; i < len(corrupt); i++ {
+		corrupt[i] ^= byte(i)
+		_, _ = q.MatchesForEvent(corrupt)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	schema, mon := monster(t)
+	f, _ := NewFlattener(schema, "")
+	q, _ := quamina.New(quamina.WithFlattener(f))
+	_ = q.AddPattern("p", `{"test_type": ["Monster"], "test": {"name": ["Fred"]}}`)
+	matches, err := q.Copy().MatchesForEvent(mon)
+	if err != nil || len(matches) != 1 {
+		t.Errorf("copy matched %v, %v", matches, err)
+	}
+}
@@ -0,0 +1,165 @@
+package flatbuffers
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// reader reads the parts of a FlatBuffer, checking that each lies within it. A reader doesn't return errors
+// from each read; instead, the first bad offset sets err, after which reads return zero values, so callers
+// check err after a sequence of reads, and in loops. Location 0, which is always the root offset, is never a
+// field's, so reads return 0 for fields that are absent.
+type reader struct {
+	buf []byte
+	err error
+}
+
+var errBounds = errors.New("flatbuffers: offset out of bounds")
+
+// fits reports whether size bytes at pos lie within the buffer, and sets err if they don't
+func (r *reader) fits(pos uint32, size uint64) bool {
+	if r.err != nil {
+		return false
+	}
+	if uint64(pos)+size > uint64(len(r.buf)) {
+		r.err = errBounds
+		return false
+	}
+	return true
+}
+
+func (r *reader) u16(pos uint32) uint16 {
+	if !r.fits(pos, 2) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(r.buf[pos:])
+}
+
+func (r *reader) u32(pos uint32) uint32 {
+	if !r.fits(pos, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(r.buf[pos:])
+}
+
+func (r *reader) u64(pos uint32) uint64 {
+	if !r.fits(pos, 8) {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(r.buf[pos:])
+}
+
+// root returns the position of the root table
+func (r *reader) root() uint32 {
+	return r.indirect(0)
+}
+
+// indirect returns the position that the offset at loc refers to
+func (r *reader) indirect(loc uint32) uint32 {
+	offset := r.u32(loc)
+	if r.err != nil {
+		return 0
+	}
+	pos := uint64(loc) + uint64(offset)
+	if pos >= uint64(len(r.buf)) {
+		r.err = errBounds
+		return 0
+	}
+	return uint32(pos)
+}
+
+// field returns the location of the field of the table at pos whose offset in the vtable is vtOffset, or 0 if
+// the field is absent
+func (r *reader) field(pos, vtOffset uint32) uint32 {
+	if !r.fits(pos, 4) {
+		return 0
+	}
+	vtable := int64(pos) - int64(int32(r.u32(pos)))
+	if vtable < 0 || vtable > math.MaxUint32 {
+		r.err = errBounds
+		return 0
+	}
+	vtSize := uint32(r.u16(uint32(vtable)))
+	if vtOffset+2 > vtSize {
+		return 0
+	}
+	offset := uint32(r.u16(uint32(vtable) + vtOffset))
+	if offset == 0 {
+		return 0
+	}
+	return pos + offset
+}
+
+// table returns the location of the field of the table at pos in the vtable slot
+func (r *reader) table(pos uint32, slot int) uint32 {
+	return r.field(pos, 4+2*uint32(slot))
+}
+
+// scalar reads an integer, bool, or enum of the type at loc
+func (r *reader) scalar(loc uint32, t baseType) int64 {
+	if loc == 0 {
+		return 0
+	}
+	switch t {
+	case typeBool, typeUType, typeUByte:
+		if r.fits(loc, 1) {
+			return int64(r.buf[loc])
+		}
+	case typeByte:
+		if r.fits(loc, 1) {
+			return int64(int8(r.buf[loc]))
+		}
+	case typeShort:
+		return int64(int16(r.u16(loc)))
+	case typeUShort:
+		return int64(r.u16(loc))
+	case typeInt:
+		return int64(int32(r.u32(loc)))
+	case typeUInt:
+		return int64(r.u32(loc))
+	case typeLong, typeULong:
+		return int64(r.u64(loc))
+	}
+	return 0
+}
+
+// float reads a float or a double at loc
+func (r *reader) float(loc uint32, t baseType) float64 {
+	if t == typeFloat {
+		return float64(math.Float32frombits(r.u32(loc)))
+	}
+	return math.Float64frombits(r.u64(loc))
+}
+
+// string reads the string that the offset at loc refers to
+func (r *reader) string(loc uint32) string {
+	if loc == 0 {
+		return ""
+	}
+	pos := r.indirect(loc)
+	length := r.u32(pos)
+	if !r.fits(pos+4, uint64(length)) {
+		return ""
+	}
+	return string(r.buf[pos+4 : pos+4+length])
+}
+
+// vector is the position of a vector's first element, and its length
+type vector struct {
+	start, count uint32
+}
+
+// vector reads the vector that the offset at loc refers to, whose elements are elementSize bytes, checking
+// that they all lie within the buffer, so that a bad length can't make a caller loop for long
+func (r *reader) vector(loc, elementSize uint32) vector {
+	if loc == 0 {
+		return vector{}
+	}
+	pos := r.indirect(loc)
+	count := r.u32(pos)
+	if !r.fits(pos+4, uint64(count)*uint64(elementSize)) {
+		return vector{}
+	}
+	return vector{start: pos + 4, count: count}
+}
@@ -0,0 +1,51 @@
+package flatbuffers
+
+import "testing"
+
+func TestReader(t *testing.T) {
+	// the root offset, a vtable with fields at 4 and 8, and a table whose second field is a string
+	buf := le(uint32(12), uint16(8), uint16(12), uint16(4), uint16(8), int32(8), int32(-2), uint32(4),
+		uint32(2), "hi\x00\x00")
+	r := reader{buf: buf}
+	root := r.root()
+	if root != 12 {
+		t.Fatalf("root at %d", root)
+	}
+	if n := r.scalar(r.table(root, 0), typeInt); n != -2 {
+		t.Errorf("got %d", n)
+	}
+	if s := r.string(r.table(root, 1)); s != "hi" {
+		t.Errorf("got %q", s)
+	}
+	if loc := r.table(root, 2); loc != 0 {
+		t.Errorf("absent field at %d", loc)
+	}
+	if v := r.vector(r.table(root, 1), 1); v.count != 2 || v.start != 28 {
+		t.Errorf("got %+v", v)
+	}
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	// a string read as a vector of longs doesn't fit
+	if v := r.vector(r.table(root, 1), 8); v.count != 0 || r.err != errBounds {
+		t.Errorf("got %+v, %v", v, r.err)
+	}
+	// after which every read returns zero
+	if n := r.scalar(r.table(root, 0), typeInt); n != 0 {
+		t.Errorf("got %d after an error", n)
+	}
+
+	for _, buf := range [][]byte{
+		{1, 2, 3},
+		le(uint32(100)),
+		le(uint32(4), int32(-4)),
+		le(uint32(4), int32(-2), uint16(0xffff)),
+	} {
+		r := reader{buf: buf}
+		r.field(r.root(), 4)
+		if r.err == nil {
+			t.Errorf("%v read", buf)
+		}
+	}
+}
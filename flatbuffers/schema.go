@@ -0,0 +1,327 @@
+package flatbuffers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// baseType is reflection.BaseType, the kind of a value
+type baseType uint8
+
+const (
+	typeNone baseType = iota
+	typeUType
+	typeBool
+	typeByte
+	typeUByte
+	typeShort
+	typeUShort
+	typeInt
+	typeUInt
+	typeLong
+	typeULong
+	typeFloat
+	typeDouble
+	typeString
+	typeVector
+	typeObj
+	typeUnion
+	typeArray
+	typeVector64
+)
+
+// scalarSizes are the sizes of the scalar types, in bytes
+var scalarSizes = [...]uint32{
+	typeUType: 1, typeBool: 1, typeByte: 1, typeUByte: 1, typeShort: 2, typeUShort: 2,
+	typeInt: 4, typeUInt: 4, typeLong: 8, typeULong: 8, typeFloat: 4, typeDouble: 8,
+}
+
+func (t baseType) isScalar() bool {
+	return t >= typeUType && t <= typeDouble
+}
+
+// Schema is a FlatBuffers schema, read from its binary form, a .bfbs file.
+type Schema struct {
+	objects []*object
+	enums   []*enum
+	root    int
+}
+
+// typ is reflection.Type, the type of a field
+type typ struct {
+	base        baseType
+	element     baseType
+	index       int // of an object, for Obj; of an enum, for scalars that have one and for unions
+	fixedLength int
+	elementSize uint32
+}
+
+// object is reflection.Object, a table or a struct
+type object struct {
+	name     string
+	fields   []*field
+	isStruct bool
+	byteSize uint32
+}
+
+// field is reflection.Field
+type field struct {
+	name       string
+	typ        typ
+	offset     uint32 // into the vtable of a table, or into a struct
+	deprecated bool
+	// for [ubyte] fields: nested is the index of the object at the root of the FlatBuffer they hold, or -1,
+	// and flex reports whether they hold a FlexBuffer
+	nested int
+	flex   bool
+	// for unions, and vectors of unions, unionType is the field holding the type of the value
+	unionType *field
+}
+
+// enum is reflection.Enum, an enum or a union
+type enum struct {
+	name     string
+	values   []enumValue
+	bitFlags bool
+}
+
+// enumValue is reflection.EnumVal
+type enumValue struct {
+	name  string
+	value int64
+	// for unions, the index of the object
+	object int
+}
+
+// the vtable slots of the reflection schema's tables, in the order their fields are declared
+const (
+	schemaObjects = iota
+	schemaEnums
+	schemaFileIdent
+	schemaFileExt
+	schemaRootTable
+)
+
+const (
+	objectName = iota
+	objectFields
+	objectIsStruct
+	objectMinAlign
+	objectByteSize
+	objectAttributes
+)
+
+const (
+	fieldName = iota
+	fieldType
+	fieldID
+	fieldOffset
+	fieldDefaultInteger
+	fieldDefaultReal
+	fieldDeprecated
+	fieldRequired
+	fieldKey
+	fieldAttributes
+	fieldDocumentation
+	fieldOptional
+	fieldPadding
+	fieldOffset64
+)
+
+const (
+	typeBaseType = iota
+	typeElement
+	typeIndex
+	typeFixedLength
+	typeBaseSize
+	typeElementSize
+)
+
+const (
+	enumName = iota
+	enumValues
+	enumIsUnion
+	enumUnderlyingType
+	enumAttributes
+)
+
+const (
+	enumValName = iota
+	enumValValue
+	enumValObject
+	enumValUnionType
+)
+
+const (
+	keyValueKey = iota
+	keyValueValue
+)
+
+// ParseSchema reads a schema in the binary form written by flatc --binary --schema. Any returned Schema is
+// safe for concurrent use.
+func ParseSchema(bfbs []byte) (*Schema, error) {
+	r := &reader{buf: bfbs}
+	root := r.root()
+	if r.err == nil && len(bfbs) >= 8 && string(bfbs[4:8]) != "BFBS" {
+		return nil, errors.New("flatbuffers: not a binary schema")
+	}
+	s := &Schema{root: -1}
+
+	objects := r.vector(r.table(root, schemaObjects), 4)
+	objectsByPos := map[uint32]int{}
+	for i := uint32(0); i < objects.count && r.err == nil; i++ {
+		pos := r.indirect(objects.start + 4*i)
+		objectsByPos[pos] = int(i)
+		s.objects = append(s.objects, &object{
+			name:     r.string(r.table(pos, objectName)),
+			isStruct: r.scalar(r.table(pos, objectIsStruct), typeBool) != 0,
+			byteSize: uint32(r.scalar(r.table(pos, objectByteSize), typeInt)),
+		})
+	}
+	enums := r.vector(r.table(root, schemaEnums), 4)
+	for i := uint32(0); i < enums.count && r.err == nil; i++ {
+		pos := r.indirect(enums.start + 4*i)
+		e := &enum{name: r.string(r.table(pos, enumName))}
+		_, e.bitFlags = r.attributes(r.table(pos, enumAttributes))["bit_flags"]
+		values := r.vector(r.table(pos, enumValues), 4)
+		for j := uint32(0); j < values.count && r.err == nil; j++ {
+			valuePos := r.indirect(values.start + 4*j)
+			value := enumValue{
+				name:   r.string(r.table(valuePos, enumValName)),
+				value:  r.scalar(r.table(valuePos, enumValValue), typeLong),
+				object: -1,
+			}
+			if unionType := r.table(valuePos, enumValUnionType); unionType != 0 {
+				value.object = r.typ(r.indirect(unionType)).index
+			}
+			e.values = append(e.values, value)
+		}
+		s.enums = append(s.enums, e)
+	}
+	if rootTable := r.table(root, schemaRootTable); rootTable != 0 {
+		s.root = objectsByPos[r.indirect(rootTable)]
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	for i, o := range s.objects {
+		pos := r.indirect(objects.start + 4*uint32(i))
+		fields := r.vector(r.table(pos, objectFields), 4)
+		for j := uint32(0); j < fields.count && r.err == nil; j++ {
+			fieldPos := r.indirect(fields.start + 4*j)
+			f := &field{
+				name:       r.string(r.table(fieldPos, fieldName)),
+				typ:        r.typ(r.indirect(r.table(fieldPos, fieldType))),
+				offset:     uint32(r.scalar(r.table(fieldPos, fieldOffset), typeUShort)),
+				deprecated: r.scalar(r.table(fieldPos, fieldDeprecated), typeBool) != 0,
+				nested:     -1,
+			}
+			if r.scalar(r.table(fieldPos, fieldOffset64), typeBool) != 0 {
+				f.typ.base = typeVector64
+			}
+			attributes := r.attributes(r.table(fieldPos, fieldAttributes))
+			if name, ok := attributes["nested_flatbuffer"]; ok {
+				if f.nested = s.objectNamed(name, o.name); f.nested < 0 {
+					return nil, fmt.Errorf("flatbuffers: %s.%s holds unknown table %s", o.name, f.name, name)
+				}
+			}
+			_, f.flex = attributes["flexbuffer"]
+			o.fields = append(o.fields, f)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return s, s.check()
+}
+
+// check makes sure the indexes in the schema refer to objects and enums that exist, and links unions to the
+// fields holding their types
+func (s *Schema) check() error {
+	for _, o := range s.objects {
+		for _, f := range o.fields {
+			t := f.typ
+			element := t.base
+			if t.base == typeVector || t.base == typeArray || t.base == typeVector64 {
+				element = t.element
+			}
+			switch {
+			case element == typeObj && (t.index < 0 || t.index >= len(s.objects)):
+				return fmt.Errorf("flatbuffers: %s.%s has an unknown type", o.name, f.name)
+			case (element == typeUnion || element.isScalar()) && t.index >= len(s.enums):
+				return fmt.Errorf("flatbuffers: %s.%s has an unknown enum", o.name, f.name)
+			case element == typeUnion:
+				for _, other := range o.fields {
+					if other.name == f.name+"_type" {
+						f.unionType = other
+					}
+				}
+				if f.unionType == nil || t.index < 0 {
+					return fmt.Errorf("flatbuffers: union %s.%s has no type", o.name, f.name)
+				}
+			}
+		}
+	}
+	for _, e := range s.enums {
+		for _, v := range e.values {
+			if v.object >= len(s.objects) {
+				return fmt.Errorf("flatbuffers: %s.%s has an unknown type", e.name, v.name)
+			}
+		}
+	}
+	return nil
+}
+
+// objectNamed returns the index of the object with the name, which may be qualified or, as in attributes,
+// relative to the namespace of the object called from, or -1
+func (s *Schema) objectNamed(name, from string) int {
+	namespace := from
+	for {
+		dot := strings.LastIndexByte(namespace, '.')
+		if dot < 0 {
+			namespace = ""
+		} else {
+			namespace = namespace[:dot]
+		}
+		qualified := name
+		if namespace != "" {
+			qualified = namespace + "." + name
+		}
+		for i, o := range s.objects {
+			if o.name == qualified {
+				return i
+			}
+		}
+		if namespace == "" {
+			return -1
+		}
+	}
+}
+
+// typ reads a reflection.Type table
+func (r *reader) typ(pos uint32) typ {
+	t := typ{
+		base:        baseType(r.scalar(r.table(pos, typeBaseType), typeByte)),
+		element:     baseType(r.scalar(r.table(pos, typeElement), typeByte)),
+		index:       -1,
+		fixedLength: int(r.scalar(r.table(pos, typeFixedLength), typeUShort)),
+		elementSize: uint32(r.scalar(r.table(pos, typeElementSize), typeUInt)),
+	}
+	if loc := r.table(pos, typeIndex); loc != 0 {
+		t.index = int(r.scalar(loc, typeInt))
+	}
+	return t
+}
+
+// attributes reads a vector of reflection.KeyValue tables
+func (r *reader) attributes(loc uint32) map[string]string {
+	attributes := map[string]string{}
+	kvs := r.vector(loc, 4)
+	for i := uint32(0); i < kvs.count && r.err == nil; i++ {
+		pos := r.indirect(kvs.start + 4*i)
+		attributes[r.string(r.table(pos, keyValueKey))] = r.string(r.table(pos, keyValueValue))
+	}
+	return attributes
+}
@@ -0,0 +1,86 @@
+package flatbuffers
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseSchema(t *testing.T) {
+	schema, _ := monster(t)
+	monster := schema.objects[schema.root]
+	if monster.name != "MyGame.Example.Monster" || monster.isStruct {
+		t.Fatalf("root is %s", monster.name)
+	}
+	fields := map[string]*field{}
+	for _, f := range monster.fields {
+		fields[f.name] = f
+	}
+	if f := fields["test"]; f.typ.base != typeUnion || f.unionType != fields["test_type"] {
+		t.Errorf("test is %+v", f)
+	}
+	if f := fields["testnestedflatbuffer"]; f.nested != schema.root {
+		t.Errorf("testnestedflatbuffer holds %d", f.nested)
+	}
+	if !fields["flex"].flex || fields["inventory"].flex || !fields["friendly"].deprecated {
+		t.Error("attributes wrong")
+	}
+	if f := fields["pos"]; f.typ.base != typeObj || !schema.objects[f.typ.index].isStruct || schema.objects[f.typ.index].byteSize != 32 {
+		t.Errorf("pos is %+v", f)
+	}
+
+	enums := map[string]*enum{}
+	for _, e := range schema.enums {
+		enums[e.name] = e
+	}
+	for _, v := range enums["MyGame.Example.Any"].values {
+		if (v.object < 0) != (v.name == "NONE") {
+			t.Errorf("Any.%s holds %d", v.name, v.object)
+		}
+	}
+	f := &Flattener{schema: schema}
+	for _, test := range []struct {
+		enum  string
+		value int64
+		want  string
+	}{
+		{"MyGame.Example.Color", 2, "Green"},
+		{"MyGame.Example.Color", 3, "Red Green"},
+		{"MyGame.Example.Color", 11, "Red Green Blue"},
+		{"MyGame.Example.Color", 4, ""},
+		{"MyGame.Example.Color", 0, ""},
+		{"MyGame.Example.LongEnum", 1<<40 | 2, "LongOne LongBig"},
+		{"MyGame.Example.Race", 1, "Dwarf"},
+		{"MyGame.Example.Race", 5, ""},
+	} {
+		got, ok := f.enumName(enums[test.enum], test.value)
+		if got != test.want || ok != (test.want != "") {
+			t.Errorf("%s %d: got %q, %t", test.enum, test.value, got, ok)
+		}
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	bfbs, err := os.ReadFile("testdata/monster_test.bfbs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon, err := os.ReadFile("testdata/monsterdata_test.mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range [][]byte{nil, []byte("BFBS"), mon, bfbs[:len(bfbs)/2]} {
+		if _, err := ParseSchema(b); err == nil {
+			t.Errorf("%d bytes accepted", len(b))
+		}
+	}
+	// truncated or corrupted schemas either fail or parse, but mustn't make ParseSchema panic
+	for i := 0; i < len(bfbs); i += 7 {
+		_, _ = ParseSchema(bfbs[:i])
+	}
+	corrupt := make([]byte, len(bfbs))
+	copy(corrupt, bfbs)
+	for i := 8; i < len(corrupt); i += 3 {
+		corrupt[i] ^= 0x5a
+		_, _ = ParseSchema(corrupt)
+	}
+}
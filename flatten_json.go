@@ -25,6 +25,9 @@ type flattenJSON struct {
 	arrayPosBuffer []ArrayPos // batch allocation buffer for ArrayTrail slices
 	cleanSheet     bool       // initially true, don't have to call Reset()
 	isSpace        [256]bool
+	arrayLimit     int              // the most elements of an array that are read, if positive; see WithArrayLimit
+	arrayPolicy    ArrayLimitPolicy // what happens to an array with more
+	nonFinite      NonFiniteNumbers // what happens to NaN, Infinity, and -Infinity; see WithNonFiniteNumbers
 }
 
 // Reset a flattenJSON struct so  that it can be re-used and won't need to be reconstructed for each event
@@ -80,7 +83,11 @@ func newJSONFlattener() Flattener {
 }
 
 func (fj *flattenJSON) Copy() Flattener {
-	return newJSONFlattener()
+	f := newJSONFlattener().(*flattenJSON)
+	f.arrayLimit = fj.arrayLimit
+	f.arrayPolicy = fj.arrayPolicy
+	f.nonFinite = fj.nonFinite
+	return f
 }
 
 // Flatten implements the Flattener interface. It assumes that the event is immutable - if you modify the event
@@ -157,8 +164,7 @@ func (fj *flattenJSON) readObject(pathNode SegmentsTreeTracker) error {
 	//  the course of reading an object
 	var arrayTrail []ArrayPos
 	if fj.skipping == 0 {
-		arrayTrail = make([]ArrayPos, len(fj.arrayTrail))
-		copy(arrayTrail, fj.arrayTrail)
+		arrayTrail = fj.snapshotArrayTrail()
 	}
 
 	// memberName contains the field-name we're processing
@@ -246,11 +252,13 @@ func (fj *flattenJSON) readObject(pathNode SegmentsTreeTracker) error {
 			case 'n':
 				val, err = fj.readLiteral(nullBytes)
 				isLeaf = true
-			case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-				val, err = fj.readNumber()
-				if err == nil {
-					isNumber = true
+			case 'N', 'I':
+				if fj.nonFinite == NonFiniteError {
+					return fj.error(fmt.Sprintf("illegal character %c after field name", ch))
 				}
+				fallthrough
+			case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				val, isNumber, err = fj.readNumberValue()
 				isLeaf = true
 			case '[':
 				if !segmentIsUsed {
@@ -354,6 +362,7 @@ func (fj *flattenJSON) readArray(pathName []byte, pathNode SegmentsTreeTracker)
 
 	state := fjInArrayState
 	isLeaf := false
+	elements := 0
 	for {
 		ch := fj.ch()
 		var val []byte // resets on each loop
@@ -368,6 +377,15 @@ func (fj *flattenJSON) readArray(pathName []byte, pathNode SegmentsTreeTracker)
 				ch = fj.ch()
 			}
 
+			elements++
+			if fj.arrayLimit > 0 && elements > fj.arrayLimit && ch != ']' {
+				if fj.arrayPolicy == ArrayLimitTruncate {
+					// we're inside the array, so the block to skip ends at its ]
+					return fj.skipBlockFrom(1, '[', ']')
+				}
+				return fmt.Errorf("%w: %w", ErrArrayTooLong, fj.error(fmt.Sprintf("more than %d elements in array", fj.arrayLimit)))
+			}
+
 			switch ch {
 			case '"':
 				val, err = fj.readStringValue()
@@ -381,11 +399,13 @@ func (fj *flattenJSON) readArray(pathName []byte, pathNode SegmentsTreeTracker)
 			case 'n':
 				val, err = fj.readLiteral(nullBytes)
 				isLeaf = true
-			case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-				val, err = fj.readNumber()
-				if err == nil {
-					isNumber = true
+			case 'N', 'I':
+				if fj.nonFinite == NonFiniteError {
+					return fj.error(fmt.Sprintf("illegal character %c in array", ch))
 				}
+				fallthrough
+			case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				val, isNumber, err = fj.readNumberValue()
 				isLeaf = true
 			case '{':
 				if fj.skipping == 0 {
@@ -561,8 +581,11 @@ func (fj *flattenJSON) leaveObject() error {
 // used to bypass object members and array elements which are not significant to any Pattern more quickly
 // than running the whole state machine.
 func (fj *flattenJSON) skipBlock(openSymbol byte, closeSymbol byte) error {
-	level := 0
+	return fj.skipBlockFrom(0, openSymbol, closeSymbol)
+}
 
+// skipBlockFrom is skipBlock for a block that has already been entered level deep
+func (fj *flattenJSON) skipBlockFrom(level int, openSymbol byte, closeSymbol byte) error {
 	for fj.eventIndex < len(fj.event) {
 		ch := fj.event[fj.eventIndex]
 
@@ -840,19 +863,25 @@ func (fj *flattenJSON) readHexUTF16(from int) ([]byte, int, error) {
 
 // storeArrayElementField adds a field to be returned to the Flatten caller, straightforward except for the field needs
 // its own snapshot of the array-trail data, because it'll be different for each array element.
-// Uses batch allocation via arrayPosBuffer to avoid per-element allocations.
 func (fj *flattenJSON) storeArrayElementField(path []byte, val []byte, isNumber bool) {
-	start := len(fj.arrayPosBuffer)
-	fj.arrayPosBuffer = append(fj.arrayPosBuffer, fj.arrayTrail...)
 	f := Field{
 		Path:       path,
-		ArrayTrail: fj.arrayPosBuffer[start:len(fj.arrayPosBuffer):len(fj.arrayPosBuffer)],
+		ArrayTrail: fj.snapshotArrayTrail(),
 		Val:        val,
 		IsNumber:   isNumber,
 	}
 	fj.fields = append(fj.fields, f)
 }
 
+// snapshotArrayTrail copies the current array trail. Uses batch allocation via arrayPosBuffer to avoid
+// per-snapshot allocations; once the buffer has grown to fit the events being flattened, there are none.
+// A snapshot made before the buffer grows remains valid, since it keeps the old backing array.
+func (fj *flattenJSON) snapshotArrayTrail() []ArrayPos {
+	start := len(fj.arrayPosBuffer)
+	fj.arrayPosBuffer = append(fj.arrayPosBuffer, fj.arrayTrail...)
+	return fj.arrayPosBuffer[start:len(fj.arrayPosBuffer):len(fj.arrayPosBuffer)]
+}
+
 func (fj *flattenJSON) storeObjectMemberField(path []byte, arrayTrail []ArrayPos, val []byte, isNumber bool) {
 	fj.fields = append(fj.fields, Field{Path: path, ArrayTrail: arrayTrail, Val: val, IsNumber: isNumber})
 }
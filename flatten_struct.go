@@ -0,0 +1,544 @@
+package quamina
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// structFlattener produces, directly from a Go value, the Fields that the JSON Flattener would produce from
+// the value's encoding by json.Marshal. It follows the same rules: exported struct fields are named by their
+// json tags if they have them, fields tagged "-" are left out, as are those tagged omitempty whose values are
+// empty, the fields of embedded structs are promoted, []byte values are base64 strings, and values that
+// implement json.Marshaler or encoding.TextMarshaler are encoded by their methods. As in the JSON Flattener,
+// members no Pattern uses are skipped without being examined.
+type structFlattener struct {
+	fields     []Field
+	arrayTrail []ArrayPos
+	arrayCount int32
+	depth      int
+	json       Flattener // for the output of MarshalJSON methods
+	wrapped    []byte
+}
+
+// maxStructDepth limits the nesting of values, which might otherwise be cyclic
+const maxStructDepth = 1000
+
+var (
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func newStructFlattener() *structFlattener {
+	return &structFlattener{}
+}
+
+// flatten returns the Fields of v, which must be, or point to, a struct or a map, or a value whose MarshalJSON
+// method returns a JSON object.
+func (sf *structFlattener) flatten(v any, tracker SegmentsTreeTracker) ([]Field, error) {
+	sf.fields = sf.fields[:0]
+	sf.arrayTrail = sf.arrayTrail[:0]
+	sf.arrayCount = 0
+	sf.depth = 0
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, errors.New("MatchesForStruct: nil value")
+		}
+		if jm, _ := marshalers(rv); jm != nil {
+			break
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, errors.New("MatchesForStruct: nil value")
+	}
+	if jm, _ := marshalers(rv); jm != nil {
+		raw, err := jm.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		return sf.jsonFlattener().Flatten(raw, tracker)
+	}
+	switch rv.Kind() {
+	case reflect.Struct:
+		return sf.fields, sf.readStruct(rv, tracker)
+	case reflect.Map:
+		return sf.fields, sf.readMap(rv, tracker)
+	}
+	return nil, fmt.Errorf("MatchesForStruct: %s is not a struct or a map", rv.Type())
+}
+
+func (sf *structFlattener) jsonFlattener() Flattener {
+	if sf.json == nil {
+		sf.json = newJSONFlattener()
+	}
+	return sf.json
+}
+
+func (sf *structFlattener) readStruct(v reflect.Value, node SegmentsTreeTracker) error {
+	if err := sf.enter(); err != nil {
+		return err
+	}
+	defer sf.leave()
+	for _, field := range cachedStructFields(v.Type()) {
+		if !node.IsSegmentUsed(field.name) {
+			continue
+		}
+		fv, ok := fieldByIndex(v, field.index)
+		if !ok || (field.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		if field.quoted {
+			if err := sf.readQuoted(fv, node, field.name); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := sf.readValue(fv, node, field.name, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (sf *structFlattener) readMap(v reflect.Value, node SegmentsTreeTracker) error {
+	if err := sf.enter(); err != nil {
+		return err
+	}
+	defer sf.leave()
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := mapKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		if !node.IsSegmentUsed(name) {
+			continue
+		}
+		if err := sf.readValue(iter.Value(), node, name, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readValue reads v, which is the member name of the object at node or, if inArray, an element of the array
+// that is that member's value
+func (sf *structFlattener) readValue(v reflect.Value, node SegmentsTreeTracker, name []byte, inArray bool) error {
+	if inArray {
+		sf.stepOneArrayElement()
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			sf.storeField(node.PathForSegment(name), nullBytes, false)
+			return nil
+		}
+		if jm, tm := marshalers(v); jm != nil || tm != nil {
+			break
+		}
+		v = v.Elem()
+	}
+	jm, tm := marshalers(v)
+	switch {
+	case jm != nil:
+		raw, err := jm.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		return sf.readJSON(raw, node, name)
+	case tm != nil:
+		text, err := tm.MarshalText()
+		if err != nil {
+			return err
+		}
+		sf.storeField(node.PathForSegment(name), quoteString(string(text)), false)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		sf.storeField(node.PathForSegment(name), quoteString(v.String()), false)
+	case reflect.Bool:
+		sf.storeField(node.PathForSegment(name), []byte(strconv.FormatBool(v.Bool())), false)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		sf.storeField(node.PathForSegment(name), strconv.AppendInt(nil, v.Int(), 10), true)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		sf.storeField(node.PathForSegment(name), strconv.AppendUint(nil, v.Uint(), 10), true)
+	case reflect.Float32, reflect.Float64:
+		number, err := formatFloat(v.Float(), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		sf.storeField(node.PathForSegment(name), number, true)
+	case reflect.Struct:
+		if objectNode, ok := node.Get(name); ok {
+			return sf.readStruct(v, objectNode)
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			sf.storeField(node.PathForSegment(name), nullBytes, false)
+			return nil
+		}
+		if objectNode, ok := node.Get(name); ok {
+			return sf.readMap(v, objectNode)
+		}
+	case reflect.Slice, reflect.Array:
+		return sf.readArray(v, node, name)
+	default:
+		return fmt.Errorf("MatchesForStruct: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// readArray reads a slice or array which, like any other value, is the member name of the object at node or
+// an element of the array that is its value
+func (sf *structFlattener) readArray(v reflect.Value, node SegmentsTreeTracker, name []byte) error {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		sf.storeField(node.PathForSegment(name), nullBytes, false)
+		return nil
+	}
+	if elem := v.Type().Elem(); v.Kind() == reflect.Slice && elem.Kind() == reflect.Uint8 &&
+		!reflect.PointerTo(elem).Implements(jsonMarshalerType) && !reflect.PointerTo(elem).Implements(textMarshalerType) {
+		sf.storeField(node.PathForSegment(name), quoteString(base64.StdEncoding.EncodeToString(v.Bytes())), false)
+		return nil
+	}
+	if err := sf.enter(); err != nil {
+		return err
+	}
+	defer sf.leave()
+	sf.enterArray()
+	defer sf.leaveArray()
+	for i := 0; i < v.Len(); i++ {
+		if err := sf.readValue(v.Index(i), node, name, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readQuoted reads a value whose field is tagged with the "string" option, which json.Marshal encodes in a
+// string
+func (sf *structFlattener) readQuoted(v reflect.Value, node SegmentsTreeTracker, name []byte) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			sf.storeField(node.PathForSegment(name), nullBytes, false)
+			return nil
+		}
+		v = v.Elem()
+	}
+	var s string
+	switch v.Kind() {
+	case reflect.String:
+		quoted, _ := json.Marshal(v.String())
+		s = string(quoted)
+	case reflect.Float32, reflect.Float64:
+		number, err := formatFloat(v.Float(), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		s = string(number)
+	case reflect.Bool:
+		s = strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	default:
+		s = strconv.FormatUint(v.Uint(), 10)
+	}
+	sf.storeField(node.PathForSegment(name), quoteString(s), false)
+	return nil
+}
+
+// readJSON reads the output of a MarshalJSON method, by presenting the JSON Flattener with {"name": raw}, and
+// renumbering the arrays in the Fields it returns to follow those already seen
+func (sf *structFlattener) readJSON(raw []byte, node SegmentsTreeTracker, name []byte) error {
+	quotedName, _ := json.Marshal(string(name))
+	sf.wrapped = append(sf.wrapped[:0], '{')
+	sf.wrapped = append(sf.wrapped, quotedName...)
+	sf.wrapped = append(sf.wrapped, ':')
+	sf.wrapped = append(sf.wrapped, raw...)
+	sf.wrapped = append(sf.wrapped, '}')
+	fields, err := sf.jsonFlattener().Flatten(sf.wrapped, node)
+	if err != nil {
+		return fmt.Errorf("MatchesForStruct: MarshalJSON output: %w", err)
+	}
+	var arrays int32
+	for _, field := range fields {
+		trail := make([]ArrayPos, 0, len(sf.arrayTrail)+len(field.ArrayTrail))
+		trail = append(trail, sf.arrayTrail...)
+		for _, pos := range field.ArrayTrail {
+			trail = append(trail, ArrayPos{Array: pos.Array + sf.arrayCount, Pos: pos.Pos})
+			arrays = max(arrays, pos.Array)
+		}
+		// the JSON Flattener's values may refer to its buffers, which are reused
+		sf.fields = append(sf.fields, Field{Path: field.Path, Val: slices.Clone(field.Val), ArrayTrail: trail, IsNumber: field.IsNumber})
+	}
+	sf.arrayCount += arrays
+	return nil
+}
+
+func (sf *structFlattener) storeField(path []byte, val []byte, isNumber bool) {
+	var trail []ArrayPos
+	if len(sf.arrayTrail) > 0 {
+		trail = slices.Clone(sf.arrayTrail)
+	}
+	sf.fields = append(sf.fields, Field{Path: path, Val: val, ArrayTrail: trail, IsNumber: isNumber})
+}
+
+func (sf *structFlattener) enterArray() {
+	sf.arrayCount++
+	sf.arrayTrail = append(sf.arrayTrail, ArrayPos{sf.arrayCount, 0})
+}
+
+func (sf *structFlattener) leaveArray() {
+	sf.arrayTrail = sf.arrayTrail[:len(sf.arrayTrail)-1]
+}
+
+func (sf *structFlattener) stepOneArrayElement() {
+	sf.arrayTrail[len(sf.arrayTrail)-1].Pos++
+}
+
+func (sf *structFlattener) enter() error {
+	sf.depth++
+	if sf.depth > maxStructDepth {
+		return fmt.Errorf("MatchesForStruct: values nested more than %d deep, perhaps cyclically", maxStructDepth)
+	}
+	return nil
+}
+
+func (sf *structFlattener) leave() {
+	sf.depth--
+}
+
+// marshalers returns the MarshalJSON and MarshalText methods, if any, which json.Marshal would use to encode v
+func marshalers(v reflect.Value) (json.Marshaler, encoding.TextMarshaler) {
+	if !v.CanInterface() {
+		// a field promoted from an unexported embedded struct; json.Marshal can't call its methods either
+		return nil, nil
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() {
+		if jm, ok := v.Addr().Interface().(json.Marshaler); ok {
+			return jm, nil
+		}
+		if tm, ok := v.Addr().Interface().(encoding.TextMarshaler); ok {
+			return nil, tm
+		}
+	}
+	if jm, ok := v.Interface().(json.Marshaler); ok {
+		return jm, nil
+	}
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		return nil, tm
+	}
+	return nil, nil
+}
+
+// quoteString returns s as Fields represent strings: in quotation marks, but not escaped
+func quoteString(s string) []byte {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	val := make([]byte, 0, len(s)+2)
+	val = append(val, '"')
+	val = append(val, s...)
+	return append(val, '"')
+}
+
+// formatFloat formats f as json.Marshal does
+func formatFloat(f float64, bits int) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("MatchesForStruct: unsupported value %v", f)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b, nil
+}
+
+// mapKey returns the member name json.Marshal would use for a map key
+func mapKey(k reflect.Value) ([]byte, error) {
+	if k.Kind() == reflect.String {
+		return []byte(k.String()), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return nil, nil
+		}
+		return tm.MarshalText()
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(nil, k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(nil, k.Uint(), 10), nil
+	}
+	return nil, fmt.Errorf("MatchesForStruct: unsupported map key type %s", k.Type())
+}
+
+// isEmptyValue reports whether omitempty leaves v out
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// fieldByIndex returns the field of v at index, which may be in an embedded struct; it returns false if
+// reaching the field requires following a nil pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// structField is a struct field which json.Marshal encodes
+type structField struct {
+	name      []byte
+	index     []int
+	tagged    bool
+	omitEmpty bool
+	quoted    bool
+}
+
+var structFieldCache sync.Map // reflect.Type -> []structField
+
+func cachedStructFields(t reflect.Type) []structField {
+	if fields, ok := structFieldCache.Load(t); ok {
+		return fields.([]structField)
+	}
+	fields, _ := structFieldCache.LoadOrStore(t, typeFields(t))
+	return fields.([]structField)
+}
+
+// typeFields returns the fields of t which json.Marshal encodes, including those promoted from embedded
+// structs, following encoding/json's rules: of the fields with the same name, the least deeply embedded one is
+// used, and if there are several of those, the one with a json tag, and if there isn't exactly one of those,
+// none of them.
+func typeFields(t reflect.Type) []structField {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+	var fields []structField
+	visited := map[reflect.Type]bool{}
+	for next := []embedded{{typ: t}}; len(next) > 0; {
+		current := next
+		next = nil
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				if sf.Anonymous {
+					ft := sf.Type
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if !sf.IsExported() && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !sf.IsExported() {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(slices.Clone(e.index), i)
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+				field := structField{name: []byte(name), index: index, tagged: name != ""}
+				if name == "" {
+					field.name = []byte(sf.Name)
+				}
+				for _, opt := range strings.Split(opts, ",") {
+					switch opt {
+					case "omitempty":
+						field.omitEmpty = true
+					case "string":
+						switch ft.Kind() {
+						case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64, reflect.String:
+							field.quoted = true
+						}
+					}
+				}
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	// keep the dominant field of each name
+	slices.SortStableFunc(fields, func(a, b structField) int {
+		if c := strings.Compare(string(a.name), string(b.name)); c != 0 {
+			return c
+		}
+		if len(a.index) != len(b.index) {
+			return len(a.index) - len(b.index)
+		}
+		if a.tagged != b.tagged {
+			if a.tagged {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	})
+	var dominant []structField
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && string(fields[j].name) == string(fields[i].name) {
+			j++
+		}
+		same := fields[i:j]
+		if len(same) == 1 || len(same[1].index) > len(same[0].index) || (same[0].tagged && !same[1].tagged) {
+			dominant = append(dominant, same[0])
+		}
+		i = j
+	}
+	return dominant
+}
@@ -0,0 +1,257 @@
+package quamina
+
+import (
+	"encoding/json"
+	"math"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type structTestBase struct {
+	ID      string `json:"id"`
+	Tenant  string
+	Dropped string `json:"tenant"`
+}
+
+type structTestItem struct {
+	SKU string  `json:"sku"`
+	Qty int     `json:"qty"`
+	Tag *string `json:"tag,omitempty"`
+}
+
+type structTestCustom struct {
+	values []int
+}
+
+func (c structTestCustom) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"values": c.values, "nested": [][]int{c.values, {0}}})
+}
+
+type structTestEvent struct {
+	structTestBase
+	*structTestItem `json:"primary"`
+	Kind            string            `json:"kind"`
+	Items           []structTestItem  `json:"items"`
+	Matrix          [][]float64       `json:"matrix"`
+	Fixed           [2]byte           `json:"fixed"`
+	Blob            []byte            `json:"blob"`
+	Labels          map[string]string `json:"labels"`
+	Counts          map[int]uint8     `json:"counts"`
+	When            time.Time         `json:"when"`
+	Addr            netip.Addr        `json:"addr"`
+	Raw             json.RawMessage   `json:"raw"`
+	Custom          structTestCustom  `json:"custom"`
+	Any             any               `json:"any"`
+	Nothing         *int              `json:"nothing"`
+	Empty           string            `json:"empty,omitempty"`
+	Quoted          int               `json:"quoted,string"`
+	QuotedString    string            `json:"quotedString,string"`
+	Hidden          string            `json:"-"`
+	Dash            string            `json:"-,"`
+	Small           float32           `json:"small"`
+	Big             float64           `json:"big"`
+	Flag            bool              `json:"flag"`
+	unexported      string
+}
+
+func structTestValue() *structTestEvent {
+	tag := "sale"
+	return &structTestEvent{
+		structTestBase: structTestBase{ID: "e-1", Tenant: "acme", Dropped: "ignored"},
+		structTestItem: &structTestItem{SKU: "primary-sku", Qty: 1},
+		Kind:           "order",
+		Items:          []structTestItem{{SKU: "a", Qty: 2}, {SKU: "b", Qty: 5, Tag: &tag}},
+		Matrix:         [][]float64{{1.5, 2}, {3}},
+		Fixed:          [2]byte{7, 8},
+		Blob:           []byte("hello"),
+		Labels:         map[string]string{"env": "prod", "tier": "web"},
+		Counts:         map[int]uint8{3: 9},
+		When:           time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		Addr:           netip.MustParseAddr("10.1.2.3"),
+		Raw:            json.RawMessage(`{"deep": [{"x": 1}, {"x": 2, "y": true}]}`),
+		Custom:         structTestCustom{values: []int{4, 5}},
+		Any:            map[string]any{"inner": []any{"p", 3.25, nil}},
+		Quoted:         42,
+		QuotedString:   "q",
+		Hidden:         "secret",
+		Dash:           "dash",
+		Small:          0.1,
+		Big:            1e-7,
+		Flag:           true,
+		unexported:     "no",
+	}
+}
+
+// TestStructMatchesJSON checks that MatchesForStruct(v) matches the same Patterns as MatchesForEvent on
+// json.Marshal(v)
+func TestStructMatchesJSON(t *testing.T) {
+	patterns := []string{
+		`{"id": ["e-1"]}`,
+		`{"Tenant": ["acme"]}`,
+		`{"tenant": ["ignored"]}`,
+		`{"sku": ["primary-sku"]}`,
+		`{"primary": {"sku": ["primary-sku"]}}`,
+		`{"kind": ["order"], "items": {"sku": ["a"], "qty": [2]}}`,
+		`{"items": {"sku": ["a"], "qty": [5]}}`,
+		`{"items": {"sku": ["b"], "tag": ["sale"]}}`,
+		`{"items": {"tag": [{"exists": false}], "sku": ["b"]}}`,
+		`{"matrix": [1.5, 3]}`,
+		`{"matrix": [2.0]}`,
+		`{"fixed": [8]}`,
+		`{"blob": ["aGVsbG8="]}`,
+		`{"labels": {"env": ["prod"], "tier": [{"prefix": "w"}]}}`,
+		`{"counts": {"3": [9]}}`,
+		`{"when": ["2024-02-29T12:00:00Z"]}`,
+		`{"addr": [{"prefix": "10."}]}`,
+		`{"raw": {"deep": {"x": [2], "y": [true]}}}`,
+		`{"raw": {"deep": {"x": [1], "y": [true]}}}`,
+		`{"custom": {"values": [5], "nested": [0]}}`,
+		`{"any": {"inner": ["p", 3.25, null]}}`,
+		`{"any": {"inner": [null]}}`,
+		`{"nothing": [null]}`,
+		`{"empty": [{"exists": false}]}`,
+		`{"quoted": ["42"]}`,
+		`{"quoted": [42]}`,
+		`{"quotedString": ["\"q\""]}`,
+		`{"Hidden": [{"exists": true}]}`,
+		`{"-": ["dash"]}`,
+		`{"small": [0.1]}`,
+		`{"big": [1e-7]}`,
+		`{"flag": [true]}`,
+		`{"unexported": [{"exists": true}]}`,
+	}
+	value := structTestValue()
+	event, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range patterns {
+		q, _ := New()
+		if err := q.AddPattern(pattern, pattern); err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+		fromJSON, err := q.MatchesForEvent(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantMatch := len(fromJSON) == 1
+		fromStruct, err := q.MatchesForStruct(value)
+		if err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+		if (len(fromStruct) == 1) != wantMatch {
+			t.Errorf("%s: MatchesForStruct %v, MatchesForEvent %v", pattern, fromStruct, fromJSON)
+		}
+	}
+
+	// and all at once, through a non-pointer value
+	q, _ := New()
+	for _, pattern := range patterns {
+		_ = q.AddPattern(pattern, pattern)
+	}
+	fromJSON, _ := q.MatchesForEvent(event)
+	fromJSON = slices.Clone(fromJSON)
+	fromStruct, err := q.MatchesForStruct(*value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortX := func(a, b X) int { return strings.Compare(a.(string), b.(string)) }
+	slices.SortFunc(fromJSON, sortX)
+	slices.SortFunc(fromStruct, sortX)
+	if !slices.Equal(fromJSON, fromStruct) {
+		t.Errorf("MatchesForStruct %v, MatchesForEvent %v", fromStruct, fromJSON)
+	}
+}
+
+func TestStructMaps(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("p", `{"a": {"b": [1]}}`)
+	for _, test := range []struct {
+		v    any
+		want bool
+	}{
+		{map[string]any{"a": map[string]int{"b": 1}}, true},
+		{&map[string]any{"a": struct {
+			B uint `json:"b"`
+		}{1}}, true},
+		{map[string]any{"a": struct{ B int8 }{1}}, false},
+	} {
+		matches, err := q.MatchesForStruct(test.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(matches) == 1) != test.want {
+			t.Errorf("%#v matched %v", test.v, matches)
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("p", `{"a": [1], "b": {"c": [1]}}`)
+	// Patterns are finitely deep, so only an array can contain itself endlessly
+	loop := []any{nil}
+	loop[0] = loop
+	var nilStruct *structTestEvent
+	for _, v := range []any{
+		nil,
+		nilStruct,
+		"a string",
+		[]int{1},
+		map[string]any{"a": make(chan int)},
+		map[string]any{"a": math.NaN()},
+		map[[2]int]int{{1, 2}: 1},
+		map[string]any{"a": loop},
+	} {
+		if _, err := q.MatchesForStruct(v); err == nil {
+			t.Errorf("%T: no error", v)
+		}
+	}
+	// members no Pattern uses aren't examined, so can't cause errors
+	if _, err := q.MatchesForStruct(map[string]any{"a": 1, "x": make(chan int)}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStructCopy(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("p", `{"kind": ["order"]}`)
+	c := q.Copy()
+	matches, err := c.MatchesForStruct(structTestValue())
+	if err != nil || len(matches) != 1 {
+		t.Errorf("copy matched %v, %v", matches, err)
+	}
+}
+
+func TestTypeFieldsDominance(t *testing.T) {
+	type A struct {
+		X int
+		Y int `json:"y"`
+	}
+	type B struct {
+		X int
+		Y int
+	}
+	type C struct {
+		A
+		B
+		Z int `json:"X"`
+	}
+	var names []string
+	for _, f := range typeFields(reflect.TypeFor[C]()) {
+		names = append(names, string(f.name))
+	}
+	slices.Sort(names)
+	// X is C's own field; A.y and B.Y differ in name; A.X and B.X would conflict but are beneath C's X
+	if !slices.Equal(names, []string{"X", "Y", "y"}) {
+		t.Errorf("fields %v", names)
+	}
+	b, _ := json.Marshal(C{A{1, 2}, B{3, 4}, 5})
+	if string(b) != `{"y":2,"Y":4,"X":5}` {
+		t.Errorf("json.Marshal disagrees: %s", b)
+	}
+}
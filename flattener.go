@@ -51,7 +51,7 @@ type ArrayPos struct {
 
 // Field represents a pathname/value combination, one of the data items which is matched
 // against Patterns by the MatchesForEvent API.
-// Path is the \n-separated path from the event root to this field value.
+// Path is the \n-separated path from the event root to this field value, as made by JoinPath.
 // Val is the value, a []byte forming a textual representation of the type
 // ArrayTrail, for each array in the Path, identifies the array and the index in it.
 type Field struct {
@@ -59,4 +59,9 @@ type Field struct {
 	Val        []byte
 	ArrayTrail []ArrayPos
 	IsNumber   bool
+
+	// number is the value of a numeric field that MatchesForTypedFields was given as a Go number, so that
+	// it needn't be parsed from Val; hasNumber reports whether it's present.
+	number    float64
+	hasNumber bool
 }
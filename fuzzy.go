@@ -0,0 +1,111 @@
+package quamina
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// maxFuzzyDistance is the largest edit distance a "fuzzy" pattern may allow. Each increase multiplies the
+// number of states in the automaton, and the number of them active at once as a value is matched.
+const maxFuzzyDistance = 2
+
+// readFuzzySpecial reads a "fuzzy" pattern, whose value is an object such as {"value": "ABC123", "distance": 1}
+// giving a string and the largest edit distance from it that values may be and still match
+func readFuzzySpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '{' {
+		return nil, errors.New("value for 'fuzzy' must be an object")
+	}
+	var value string
+	hasValue, distance := false, -1
+	for {
+		t, err = pb.jd.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := t.(json.Delim); ok && delim == '}' {
+			break
+		}
+		member := t
+		if t, err = pb.jd.Token(); err != nil {
+			return nil, err
+		}
+		switch member {
+		case "value":
+			if value, hasValue = t.(string); !hasValue {
+				return nil, errors.New("'value' in 'fuzzy' must be a string")
+			}
+		case "distance":
+			num, ok := t.(json.Number)
+			if ok {
+				distance, err = strconv.Atoi(string(num))
+			}
+			if !ok || err != nil || distance < 0 || distance > maxFuzzyDistance {
+				return nil, fmt.Errorf("'distance' in 'fuzzy' must be a whole number from 0 to %d", maxFuzzyDistance)
+			}
+		default:
+			return nil, fmt.Errorf("unknown member %v in 'fuzzy'", member)
+		}
+	}
+	if !hasValue || distance < 0 {
+		return nil, errors.New("'fuzzy' must have a 'value' and a 'distance'")
+	}
+
+	// has to be } or tokenizer will throw error
+	if _, err = pb.jd.Token(); err != nil {
+		return nil, err
+	}
+	return append(valsIn, typedVal{vType: fuzzyType, val: `"` + value + `"`, distance: distance}), nil
+}
+
+// makeFuzzyFA builds a NFA to match "fuzzy" patterns, a Levenshtein automaton which matches the strings which
+// can be made from the value by inserting, deleting, or replacing at most distance characters. Its states are
+// those reached having matched some number of the value's characters and made some number of edits. From each,
+// the value's next character leads on with no more edits; and while edits remain, any character leads on as
+// a replacement or stays put as an insertion, and an epsilon skips the next character as a deletion.
+func makeFuzzyFA(val []byte, distance int, pp printer) (start *faState, nextField *fieldMatcher) {
+	nextField = newFieldMatcher()
+	chars := []rune(string(val[1 : len(val)-1]))
+	trailer := makeNFATrailer(nextField)
+
+	// states[i][e] is reached having matched i characters with e edits
+	states := make([][]*faState, len(chars)+1)
+	for i := range states {
+		states[i] = make([]*faState, distance+1)
+		for e := range states[i] {
+			states[i][e] = &faState{table: newSmallTable()}
+			pp.labelTable(&states[i][e].table, fmt.Sprintf("fuzzy %d/%d", i, e))
+		}
+	}
+	for i, row := range states {
+		for e, state := range row {
+			if i < len(chars) {
+				char := []byte(string(chars[i]))
+				state.table.addByteStep(char[0], makeFAFragment(char, states[i+1][e], pp))
+			} else {
+				state.table.addByteStep('"', trailer)
+			}
+			if e == distance {
+				continue
+			}
+			edited := &faState{table: newSmallTable()}
+			edited.table.epsilons = []*faState{states[i][e+1]}
+			if i < len(chars) {
+				edited.table.epsilons = append(edited.table.epsilons, states[i+1][e+1])
+				state.table.epsilons = append(state.table.epsilons, states[i+1][e+1])
+			}
+			anyChar := &faState{table: makeDotFA(edited)}
+			state.table.epsilons = append(state.table.epsilons, anyChar)
+		}
+	}
+
+	start = &faState{table: newSmallTable()}
+	pp.labelTable(&start.table, "FUZZY")
+	start.table.addByteStep('"', states[0][0])
+	return start, nextField
+}
@@ -0,0 +1,75 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestFuzzyPattern(t *testing.T) {
+	patterns := map[X]string{
+		"sku":     `{"sku": [{"fuzzy": {"value": "ABC123", "distance": 1}}]}`,
+		"two":     `{"sku": [{"fuzzy": {"distance": 2, "value": "XY-9"}}]}`,
+		"exact":   `{"sku": [{"fuzzy": {"value": "Q7", "distance": 0}}]}`,
+		"mixed":   `{"sku": [{"fuzzy": {"value": "café", "distance": 1}}, "ZZZ", {"prefix": "P-"}]}`,
+		"nothing": `{"code": [{"fuzzy": {"value": "", "distance": 1}}]}`,
+	}
+	events := map[string][]X{
+		`{"sku": "ABC123"}`:   {"sku"},
+		`{"sku": "ABD123"}`:   {"sku"},
+		`{"sku": "ABC1234"}`:  {"sku"},
+		`{"sku": "BC123"}`:    {"sku"},
+		`{"sku": "AB123"}`:    {"sku"},
+		`{"sku": "AC123x"}`:   {},
+		`{"sku": "ABC12345"}`: {},
+		`{"sku": "xABC12"}`:   {},
+		`{"sku": "abc123"}`:   {},
+		`{"sku": "XY-9"}`:     {"two"},
+		`{"sku": "X9"}`:       {"two"},
+		`{"sku": "xy-9"}`:     {"two"},
+		`{"sku": "Y-"}`:       {"two"},
+		`{"sku": "xy-"}`:      {},
+		`{"sku": "Q7"}`:       {"exact"},
+		`{"sku": "Q8"}`:       {},
+		`{"sku": "cafe"}`:     {"mixed"},
+		`{"sku": "cafés"}`:    {"mixed"},
+		`{"sku": "caf"}`:      {"mixed"},
+		`{"sku": "cafê"}`:     {"mixed"},
+		`{"sku": "cfe"}`:      {},
+		`{"sku": "ZZZ"}`:      {"mixed"},
+		`{"sku": "P-1"}`:      {"mixed"},
+		`{"sku": 123}`:        {},
+		`{"code": ""}`:        {"nothing"},
+		`{"code": "é"}`:       {"nothing"},
+		`{"code": "ab"}`:      {},
+	}
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		cm := newCoreMatcher()
+		for x, p := range patterns {
+			if err := cm.addPattern(x, p, mode); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+		for event, want := range events {
+			matches, err := cm.matchesForJSONEvent([]byte(event))
+			if err != nil || !containsExactly(matches, want) {
+				t.Errorf("mode %d, %s: %v, %v", mode, event, matches, err)
+			}
+		}
+	}
+
+	for _, bad := range []string{
+		`{"x": [{"fuzzy": "abc"}]}`,
+		`{"x": [{"fuzzy": {"value": "abc"}}]}`,
+		`{"x": [{"fuzzy": {"distance": 1}}]}`,
+		`{"x": [{"fuzzy": {"value": 3, "distance": 1}}]}`,
+		`{"x": [{"fuzzy": {"value": "abc", "distance": 3}}]}`,
+		`{"x": [{"fuzzy": {"value": "abc", "distance": -1}}]}`,
+		`{"x": [{"fuzzy": {"value": "abc", "distance": 1.5}}]}`,
+		`{"x": [{"fuzzy": {"value": "abc", "distance": 1, "case": true}}]}`,
+		`{"x": [{"fuzzy": {"value": "abc", "distance": 1}, "b": 1}]}`,
+	} {
+		q, _ := New()
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
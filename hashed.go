@@ -0,0 +1,88 @@
+package quamina
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// WithHMACKey provides the key for "hmac-sha256" Patterns, whose values are the HMAC-SHA256 digests, with this
+// key, of the strings they match. They are like "sha256" Patterns, but without the key, the strings can't be
+// recovered from the digests by hashing guesses, as they can be for the small sets of values, such as email
+// addresses, that allowlists are made of. Patterns can then carry hashed personal data that is safe to
+// distribute to wherever they're matched. The key applies to instances created with Copy. It must not be empty.
+// This option call may not be provided more than once.
+func WithHMACKey(key []byte) Option {
+	return func(q *Quamina) error {
+		if q.hmacKey != nil {
+			return errors.New("HMAC key specified more than once")
+		}
+		if len(key) == 0 {
+			return errors.New("HMAC key must not be empty")
+		}
+		q.hmacKey = slices.Clone(key)
+		return nil
+	}
+}
+
+func (m *coreMatcher) setHMACKey(key []byte) {
+	m.hmacKey = key
+}
+
+// keyDigests attaches the matcher's key to each of the "hmac-sha256" values in a Pattern, since the rangeMatcher
+// needs it to make the digests of Event values
+func (m *coreMatcher) keyDigests(fields []*patternField) error {
+	for _, field := range fields {
+		for i, val := range field.vals {
+			if val.vType != hmacSHA256Type {
+				continue
+			}
+			if m.hmacKey == nil {
+				return errors.New("hmac-sha256 patterns are only supported by WithHMACKey")
+			}
+			field.vals[i].key = m.hmacKey
+		}
+	}
+	return nil
+}
+
+// hmacDigest is the HMAC-SHA256 digest of val with key
+func hmacDigest(key, val []byte) (digest [sha256.Size]byte) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(val)
+	mac.Sum(digest[:0])
+	return
+}
+
+// readDigestSpecial reads a "sha256" or "hmac-sha256" pattern, whose value is the hex-encoded digest of the
+// string it matches, so that Patterns can carry allowlists of sensitive values without revealing them
+func readDigestSpecial(pb *patternBuild, valsIn []typedVal, patternType string, vType valType) (pathVals []typedVal, err error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	pathVals = valsIn
+
+	hexDigest, ok := t.(string)
+	if !ok {
+		err = fmt.Errorf("value for '%s' must be a string", patternType)
+		return
+	}
+	val := typedVal{vType: vType, val: `"` + hexDigest + `"`}
+	if len(hexDigest) != hex.EncodedLen(sha256.Size) {
+		err = fmt.Errorf("value for '%s' must be 64 hex digits", patternType)
+		return
+	}
+	if _, err = hex.Decode(val.digest[:], []byte(hexDigest)); err != nil {
+		err = fmt.Errorf("value for '%s' must be 64 hex digits", patternType)
+		return
+	}
+	pathVals = append(pathVals, val)
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
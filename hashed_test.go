@@ -0,0 +1,128 @@
+package quamina
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSHA256Pattern(t *testing.T) {
+	digest := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	q, _ := New()
+	patterns := map[X]string{
+		"allowed": fmt.Sprintf(`{"email": [{"sha256": %q}, {"sha256": %q}]}`, digest("alice@example.com"), strings.ToUpper(digest("bob@example.com"))),
+		"empty":   fmt.Sprintf(`{"email": [{"sha256": %q}]}`, digest("")),
+		"mixed":   fmt.Sprintf(`{"email": [{"sha256": %q}, "carol@example.com", {"prefix": "x"}]}`, digest("dave@example.com")),
+		"number":  fmt.Sprintf(`{"id": [{"sha256": %q}]}`, digest("42")),
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	// the same digest again shares its transition
+	if err := q.AddPattern("again", fmt.Sprintf(`{"email": [{"sha256": %q}]}`, digest("alice@example.com"))); err != nil {
+		t.Fatal(err)
+	}
+	for event, want := range map[string][]X{
+		`{"email": "alice@example.com"}`:      {"allowed", "again"},
+		`{"email": "alice\u0040example.com"}`: {"allowed", "again"},
+		`{"email": "bob@example.com"}`:        {"allowed"},
+		`{"email": "Bob@example.com"}`:        {},
+		`{"email": ""}`:                       {"empty"},
+		`{"email": "carol@example.com"}`:      {"mixed"},
+		`{"email": "dave@example.com"}`:       {"mixed"},
+		`{"email": "xyz"}`:                    {"mixed"},
+		`{"id": 42}`:                          {},
+		`{"id": "42"}`:                        {"number"},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"x": [{"sha256": 3}]}`,
+		`{"x": [{"sha256": "abc"}]}`,
+		`{"x": [{"sha256": "` + strings.Repeat("g", 64) + `"}]}`,
+		`{"x": [{"sha256": "` + digest("a") + `", "b": 1}]}`,
+	} {
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestHMACPattern(t *testing.T) {
+	key := []byte("not very secret")
+	digest := func(s string) string {
+		sum := hmacDigest(key, []byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	plain := sha256.Sum256([]byte("alice@example.com"))
+	patterns := map[X]string{
+		"allowed": fmt.Sprintf(`{"email": [{"hmac-sha256": %q}, {"hmac-sha256": %q}]}`, digest("alice@example.com"), digest("bob@example.com")),
+		"plain":   fmt.Sprintf(`{"email": [{"sha256": %q}]}`, hex.EncodeToString(plain[:])),
+		"all":     fmt.Sprintf(`{"tags": [{"all": [{"hmac-sha256": %q}]}]}`, digest("ok")),
+	}
+	for _, deletion := range []bool{false, true} {
+		q, err := New(WithHMACKey(key), WithPatternDeletion(deletion))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+		if _, err := q.PreviewAddPattern("preview", patterns["allowed"]); err != nil {
+			t.Errorf("preview: %v", err)
+		}
+		if deletion {
+			if err := q.matcher.(*prunerMatcher).rebuild(false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for event, want := range map[string][]X{
+			`{"email": "alice@example.com"}`: {"allowed", "plain"},
+			`{"email": "bob@example.com"}`:   {"allowed"},
+			`{"email": "carol@example.com"}`: {},
+			`{"tags": ["ok", "ok"]}`:         {"all"},
+			`{"tags": ["ok", "no"]}`:         {},
+		} {
+			for _, instance := range []*Quamina{q, q.Copy()} {
+				matches, err := instance.MatchesForEvent([]byte(event))
+				if err != nil || !containsExactly(matches, want) {
+					t.Errorf("deletion=%v, %s: %v, %v", deletion, event, matches, err)
+				}
+			}
+		}
+	}
+
+	// the key is copied
+	changing := []byte("key")
+	q, _ := New(WithHMACKey(changing))
+	changing[0] = 'K'
+	sum := hmacDigest([]byte("key"), []byte("x"))
+	_ = q.AddPattern("x", fmt.Sprintf(`{"a": [{"hmac-sha256": %q}]}`, hex.EncodeToString(sum[:])))
+	if matches, _ := q.MatchesForEvent([]byte(`{"a": "x"}`)); len(matches) != 1 {
+		t.Errorf("key changed: %v", matches)
+	}
+
+	unkeyed, _ := New()
+	if err := unkeyed.AddPattern("x", patterns["allowed"]); err == nil {
+		t.Error("accepted hmac-sha256 without a key")
+	}
+	if _, err := New(WithHMACKey(nil)); err == nil {
+		t.Error("accepted empty key")
+	}
+	if _, err := New(WithHMACKey(key), WithHMACKey(key)); err == nil {
+		t.Error("accepted option twice")
+	}
+}
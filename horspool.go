@@ -0,0 +1,71 @@
+package quamina
+
+import "bytes"
+
+// horspool implements Boyer-Moore-Horspool search for one literal. It's used for long literals, for which
+// it can skip ahead by up to the literal's length on a mismatch. bytes.Index is hard to beat for short
+// needles, where it uses a vectorized brute-force comparison, but for long ones it falls back to
+// schemes whose cost on multi-kilobyte values full of near-miss prefixes is much higher. Horspool's shifts
+// depend on the bytes of the value, though, and in periodic ones, such as long runs of one byte, they can be a
+// byte or two, each costing a comparison, so index hands the search over to bytes.Index, whose cost is linear
+// in the length of the value, once its own work outruns its progress.
+type horspool struct {
+	literal []byte
+	skip    [256]int32
+}
+
+// horspoolMinLength is the literal length from which horspool outperforms bytes.Index. The standard
+// library's vectorized brute-force search handles needles up to 63 bytes on amd64 and arm64.
+const horspoolMinLength = 64
+
+func newHorspool(literal []byte) *horspool {
+	h := &horspool{literal: literal}
+	//nolint:gosec // literals are pattern strings, far shorter than MaxInt32
+	n := int32(len(literal))
+	for i := range h.skip {
+		h.skip[i] = n
+	}
+	last := len(literal) - 1
+	for i := 0; i < last; i++ {
+		//nolint:gosec // as above
+		h.skip[literal[i]] = int32(last - i)
+	}
+	return h
+}
+
+// the costs of a horspool shift and of a comparison with the literal, in units of about a byte of the work
+// bytes.Index does on long literals; a comparison also costs a unit for each 16 bytes of the literal
+const (
+	horspoolShiftCost   = 3
+	horspoolCompareCost = 4
+)
+
+// index returns the position of the first occurrence of the literal in val, or -1
+func (h *horspool) index(val []byte) int {
+	n := len(h.literal)
+	if n == 0 {
+		return 0
+	}
+	last := n - 1
+	lastByte := h.literal[last]
+	compareCost := horspoolCompareCost + n/16
+	work := 0
+	for pos := 0; pos+n <= len(val); {
+		b := val[pos+last]
+		if b == lastByte {
+			if string(val[pos:pos+last]) == string(h.literal[:last]) {
+				return pos
+			}
+			work += compareCost
+		}
+		pos += int(h.skip[b])
+		work += horspoolShiftCost
+		if work > pos+n {
+			if i := bytes.Index(val[pos:], h.literal); i >= 0 {
+				return pos + i
+			}
+			return -1
+		}
+	}
+	return -1
+}
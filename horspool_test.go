@@ -0,0 +1,86 @@
+package quamina
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestHorspool(t *testing.T) {
+	alphabet := []byte("ab")
+	randBytes := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rand.Intn(len(alphabet))]
+		}
+		return b
+	}
+	for trial := 0; trial < 2000; trial++ {
+		literal := randBytes(1 + rand.Intn(8))
+		val := randBytes(rand.Intn(60))
+		h := newHorspool(literal)
+		if got, want := h.index(val), bytes.Index(val, literal); got != want {
+			t.Errorf("%s in %s: got %d want %d", literal, val, got, want)
+		}
+	}
+	// long periodic literals, in which index gives up on Horspool's short shifts, found where they're planted
+	for trial := 0; trial < 200; trial++ {
+		literal := bytes.Repeat(randBytes(1+rand.Intn(3)), 20+rand.Intn(20))
+		val := bytes.Repeat(literal[:len(literal)-1], 1+rand.Intn(50))
+		if trial%2 == 0 {
+			at := rand.Intn(len(val))
+			val = append(val[:at:at], append(literal, val[at:]...)...)
+		}
+		h := newHorspool(literal)
+		if got, want := h.index(val), bytes.Index(val, literal); got != want {
+			t.Errorf("%s in %s: got %d want %d", literal, val, got, want)
+		}
+	}
+	if newHorspool(nil).index([]byte("x")) != 0 {
+		t.Error("empty literal")
+	}
+}
+
+func TestLongLiteralSubstring(t *testing.T) {
+	long := strings.Repeat("abcdefgh", 10) + "!"
+	q, _ := New()
+	if err := q.AddPattern("long", `{"body": [{"shellstyle": "*`+long+`*"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	nearMisses := strings.Repeat(strings.Repeat("abcdefgh", 10)+"?", 100)
+	matches, _ := q.MatchesForEvent([]byte(`{"body": "` + nearMisses + `"}`))
+	if len(matches) != 0 {
+		t.Error("near misses matched")
+	}
+	matches, _ = q.MatchesForEvent([]byte(`{"body": "` + nearMisses + long + `"}`))
+	if !containsExactly(matches, []X{"long"}) {
+		t.Errorf("got %v", matches)
+	}
+}
+
+func BenchmarkLongLiteralNearMiss(b *testing.B) {
+	cases := []struct {
+		name         string
+		literal, val []byte
+	}{
+		{"rich", []byte(strings.Repeat("abcdefgh", 12) + "!"), bytes.Repeat([]byte(strings.Repeat("abcdefgh", 12)+"?"), 100)},
+		// periodic literals and values, in which Horspool's shifts are a byte or two
+		{"mismatch-first", []byte("b" + strings.Repeat("a", 99)), bytes.Repeat([]byte("a"), 8192)},
+		{"mismatch-last", []byte(strings.Repeat("ab", 50) + "c"), bytes.Repeat([]byte("ab"), 4096)},
+		{"mismatch-middle", []byte(strings.Repeat("a", 50) + "b" + strings.Repeat("a", 49)), bytes.Repeat([]byte("a"), 8192)},
+	}
+	for _, c := range cases {
+		h := newHorspool(c.literal)
+		b.Run(c.name+"/horspool", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				h.index(c.val)
+			}
+		})
+		b.Run(c.name+"/bytes.Index", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bytes.Index(c.val, c.literal)
+			}
+		})
+	}
+}
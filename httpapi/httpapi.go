@@ -0,0 +1,218 @@
+// Package httpapi serves a Quamina instance over HTTP, so that a filtering microservice takes a few lines:
+//
+//	q, _ := quamina.New(quamina.WithPatternDeletion(true))
+//	http.ListenAndServe(":8080", httpapi.New(q))
+//
+// The endpoints exchange JSON, and identify Patterns with strings, which are their X values:
+//
+//	POST   /patterns        {"x": "id", "pattern": {...}}                   adds a Pattern
+//	POST   /patterns/bulk   [{"x": "id", "pattern": {...}}, ...]            adds several
+//	DELETE /patterns/{x}                                                    deletes the Patterns added with x
+//	POST   /match           an Event                                        returns {"matches": ["id", ...]}
+//	POST   /match/bulk      [Event, ...]                                    returns {"results": [{"matches": [...]}, ...]}
+//	GET    /stats                                                           returns {"matcher": {...}, "memory": {...}}
+//
+// Success with nothing to return is 204 No Content. Failures are reported as {"error": "..."}, with 400 for
+// bad requests, including Patterns that Quamina rejects and Events that aren't valid JSON, 413 for bodies over
+// the limit, and 507 if a MemoryBudget is used up. DELETE needs an instance created WithPatternDeletion(true),
+// and fails with 501 Not Implemented without one.
+// In the results of /match/bulk, an Event that can't be matched has an "error" member and null "matches", so
+// that one bad Event doesn't fail the others.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// DefaultMaxBodyBytes is the largest request body a Handler accepts unless told otherwise.
+const DefaultMaxBodyBytes = 10 << 20
+
+// Handler is an http.Handler serving a Quamina instance. A Quamina instance can't be used by several
+// goroutines at once, so each request uses a copy made with Copy; the copies share the Patterns, so a Pattern
+// added by one request is seen by all those that follow.
+type Handler struct {
+	// MaxBodyBytes limits the size of request bodies; zero means DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	mux    *http.ServeMux
+	copies sync.Pool
+	// GetMatcherStats mustn't run alongside AddPattern or DeletePatterns
+	writeLock sync.Mutex
+}
+
+// New returns a Handler serving q, which shouldn't be used elsewhere afterward except through copies.
+func New(q *quamina.Quamina) *Handler {
+	h := &Handler{mux: http.NewServeMux()}
+	h.copies.New = func() any { return q.Copy() }
+	h.mux.HandleFunc("POST /patterns", h.addPattern)
+	h.mux.HandleFunc("POST /patterns/bulk", h.addPatterns)
+	h.mux.HandleFunc("DELETE /patterns/{x}", h.deletePatterns)
+	h.mux.HandleFunc("POST /match", h.match)
+	h.mux.HandleFunc("POST /match/bulk", h.matchBulk)
+	h.mux.HandleFunc("GET /stats", h.stats)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+type patternRequest struct {
+	X       string          `json:"x"`
+	Pattern json.RawMessage `json:"pattern"`
+}
+
+type matchResult struct {
+	Matches []string `json:"matches"`
+	Error   string   `json:"error,omitempty"`
+}
+
+func (h *Handler) addPattern(w http.ResponseWriter, r *http.Request) {
+	var req patternRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+	h.add(w, []patternRequest{req})
+}
+
+func (h *Handler) addPatterns(w http.ResponseWriter, r *http.Request) {
+	var reqs []patternRequest
+	if !h.decode(w, r, &reqs) {
+		return
+	}
+	h.add(w, reqs)
+}
+
+// add adds the Patterns in order, stopping at the first that fails, so that those before it stay added
+func (h *Handler) add(w http.ResponseWriter, reqs []patternRequest) {
+	q := h.copies.Get().(*quamina.Quamina)
+	defer h.copies.Put(q)
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+	for i, req := range reqs {
+		if len(req.Pattern) == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("pattern %d: no pattern", i))
+			return
+		}
+		if err := q.AddPattern(req.X, string(req.Pattern)); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, quamina.ErrMemoryBudgetExceeded) {
+				status = http.StatusInsufficientStorage
+			}
+			writeError(w, status, fmt.Errorf("pattern %d: %w", i, err))
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) deletePatterns(w http.ResponseWriter, r *http.Request) {
+	q := h.copies.Get().(*quamina.Quamina)
+	defer h.copies.Put(q)
+	h.writeLock.Lock()
+	defer h.writeLock.Unlock()
+	if err := q.DeletePatterns(r.PathValue("x")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, quamina.ErrDeletionNotSupported) {
+			status = http.StatusNotImplemented
+		}
+		writeError(w, status, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) match(w http.ResponseWriter, r *http.Request) {
+	var event json.RawMessage
+	if !h.decode(w, r, &event) {
+		return
+	}
+	q := h.copies.Get().(*quamina.Quamina)
+	defer h.copies.Put(q)
+	result := matchOne(q, event)
+	if result.Error != "" {
+		writeError(w, http.StatusBadRequest, errors.New(result.Error))
+		return
+	}
+	writeJSON(w, result)
+}
+
+func (h *Handler) matchBulk(w http.ResponseWriter, r *http.Request) {
+	var events []json.RawMessage
+	if !h.decode(w, r, &events) {
+		return
+	}
+	q := h.copies.Get().(*quamina.Quamina)
+	defer h.copies.Put(q)
+	results := make([]matchResult, len(events))
+	for i, event := range events {
+		results[i] = matchOne(q, event)
+	}
+	writeJSON(w, map[string][]matchResult{"results": results})
+}
+
+func (h *Handler) stats(w http.ResponseWriter, _ *http.Request) {
+	q := h.copies.Get().(*quamina.Quamina)
+	defer h.copies.Put(q)
+	h.writeLock.Lock()
+	matcher := q.GetMatcherStats()
+	h.writeLock.Unlock()
+	writeJSON(w, map[string]any{"matcher": matcher, "memory": q.MemoryUsage()})
+}
+
+// matchOne matches an Event. The Handler adds Patterns with string X values, but the instance it was given
+// may already have had others, which are reported as fmt.Sprint formats them. Quamina reports matches in no
+// particular order, so they're sorted to make responses repeatable.
+func matchOne(q *quamina.Quamina, event []byte) matchResult {
+	matches, err := q.MatchesForEvent(event)
+	if err != nil {
+		return matchResult{Error: err.Error()}
+	}
+	result := matchResult{Matches: make([]string, len(matches))}
+	for i, x := range matches {
+		if s, ok := x.(string); ok {
+			result.Matches[i] = s
+		} else {
+			result.Matches[i] = fmt.Sprint(x)
+		}
+	}
+	slices.Sort(result.Matches)
+	return result
+}
+
+// decode reads the request body into v, or reports why it can't and returns false
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	limit := h.MaxBodyBytes
+	if limit == 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	if err := decoder.Decode(v); err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+		} else {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("bad request body: %w", err))
+		}
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v) // nothing to be done if the client has gone
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
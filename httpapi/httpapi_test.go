@@ -0,0 +1,103 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// call makes a request of h and returns the status and body
+func call(t *testing.T, h http.Handler, method, path, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	got, _ := io.ReadAll(rec.Result().Body)
+	return rec.Code, strings.TrimSpace(string(got))
+}
+
+func TestHandler(t *testing.T) {
+	q, _ := quamina.New(quamina.WithPatternDeletion(true))
+	h := New(q)
+	steps := []struct {
+		method, path, body string
+		status             int
+		want               string
+	}{
+		{"POST", "/patterns", `{"x": "a", "pattern": {"kind": ["order"]}}`, 204, ""},
+		{"POST", "/patterns/bulk", `[{"x": "b", "pattern": {"kind": ["order"], "n": [1]}}, {"x": "c", "pattern": {"n": [2]}}]`, 204, ""},
+		{"POST", "/match", `{"kind": "order", "n": 1}`, 200, `{"matches":["a","b"]}`},
+		{"POST", "/match", `{"kind": "refund"}`, 200, `{"matches":[]}`},
+		{"POST", "/match/bulk", `[{"n": 2}, "x", {"kind": "order"}]`, 200,
+			`{"results":[{"matches":["c"]},{"matches":null,"error":"at line 1 col 0: not a JSON object"},{"matches":["a"]}]}`},
+		{"DELETE", "/patterns/a", "", 204, ""},
+		{"POST", "/match", `{"kind": "order", "n": 1}`, 200, `{"matches":["b"]}`},
+		{"POST", "/patterns/bulk", `[{"x": "d", "pattern": {"n": [3]}}, {"x": "e", "pattern": {"n": [{"bogus": 1}]}}]`, 400, "pattern 1:"},
+		{"POST", "/match", `{"n": 3}`, 200, `{"matches":["d"]}`},
+		{"POST", "/patterns", `{"x": "f"}`, 400, "no pattern"},
+		{"POST", "/patterns", `{"x": `, 400, "bad request body"},
+		{"POST", "/match", `{"n": `, 400, "bad request body"},
+		{"GET", "/stats", "", 200, `"matcher":{`},
+		{"GET", "/match", "", 405, ""},
+	}
+	for _, step := range steps {
+		status, body := call(t, h, step.method, step.path, step.body)
+		if status != step.status || !strings.Contains(body, step.want) {
+			t.Errorf("%s %s %s: %d %s, want %d %s", step.method, step.path, step.body, status, body, step.status, step.want)
+		}
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	q, _ := quamina.New()
+	h := New(q)
+	h.MaxBodyBytes = 16
+	if status, body := call(t, h, "POST", "/match", `{"field": "a long value"}`); status != 413 {
+		t.Errorf("oversized body: %d %s", status, body)
+	}
+	if status, body := call(t, h, "DELETE", "/patterns/x", ""); status != 501 || !strings.Contains(body, "not supported") {
+		t.Errorf("delete without WithPatternDeletion: %d %s", status, body)
+	}
+
+	// Patterns the instance already had may have X values which aren't strings
+	numbered, _ := quamina.New()
+	_ = numbered.AddPattern(7, `{"a": ["b"]}`)
+	if status, body := call(t, New(numbered), "POST", "/match", `{"a": "b"}`); status != 200 || body != `{"matches":["7"]}` {
+		t.Errorf("non-string X: %d %s", status, body)
+	}
+
+	budget, _ := quamina.NewMemoryBudget(1)
+	small, _ := quamina.New(quamina.WithMemoryBudget(budget))
+	// a budget can be exceeded by the last Pattern added, so it's the second that fails
+	limited := New(small)
+	call(t, limited, "POST", "/patterns", `{"x": "a", "pattern": {"a": ["b"]}}`)
+	if status, body := call(t, limited, "POST", "/patterns", `{"x": "b", "pattern": {"b": ["c"]}}`); status != 507 {
+		t.Errorf("memory budget: %d %s", status, body)
+	}
+}
+
+func TestHandlerConcurrency(t *testing.T) {
+	q, _ := quamina.New()
+	h := New(q)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				call(t, h, "POST", "/patterns", `{"x": "a", "pattern": {"a": ["b"]}}`)
+				if status, body := call(t, h, "POST", "/match", `{"a": "b"}`); status != 200 || !strings.Contains(body, `"a"`) {
+					t.Errorf("match: %d %s", status, body)
+					return
+				}
+				call(t, h, "GET", "/stats", "")
+			}
+		}()
+	}
+	wg.Wait()
+}
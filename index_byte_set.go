@@ -0,0 +1,16 @@
+package quamina
+
+// indexByteSet3 returns the index of the first byte in s which is equal to any of b0, b1, or b2, or -1
+// if there is none. To look for fewer than three distinct bytes, repeat one. It is used to skip quickly
+// over stretches of a value that can't contain the start of any interesting literal, and so is worth
+// vectorizing: there's an SSE2 version for amd64 in index_byte_set_amd64.s; other architectures, and
+// builds with the "purego" tag, use indexByteSet3Generic.
+
+func indexByteSet3Generic(s []byte, b0, b1, b2 byte) int {
+	for i, b := range s {
+		if b == b0 || b == b1 || b == b2 {
+			return i
+		}
+	}
+	return -1
+}
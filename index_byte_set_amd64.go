@@ -0,0 +1,6 @@
+//go:build !purego && !tinygo
+
+package quamina
+
+//go:noescape
+func indexByteSet3(s []byte, b0, b1, b2 byte) int
@@ -0,0 +1,78 @@
+//go:build !purego && !tinygo
+
+#include "textflag.h"
+
+// func indexByteSet3(s []byte, b0, b1, b2 byte) int
+// Compares 16 bytes at a time against each of the three bytes, broadcast into X0-X2, using
+// only SSE2, which every amd64 processor has. The tail is done a byte at a time.
+TEXT ·indexByteSet3(SB), NOSPLIT, $0-40
+	MOVQ    s_base+0(FP), SI
+	MOVQ    s_len+8(FP), BX
+	MOVBLZX b0+24(FP), AX
+	MOVBLZX b1+25(FP), CX
+	MOVBLZX b2+26(FP), R9
+	MOVQ    SI, DI
+
+	// broadcast each byte across a whole X register
+	MOVD       AX, X0
+	PUNPCKLBW  X0, X0
+	PSHUFLW    $0, X0, X0
+	PUNPCKLQDQ X0, X0
+	MOVD       CX, X1
+	PUNPCKLBW  X1, X1
+	PSHUFLW    $0, X1, X1
+	PUNPCKLQDQ X1, X1
+	MOVD       R9, X2
+	PUNPCKLBW  X2, X2
+	PSHUFLW    $0, X2, X2
+	PUNPCKLQDQ X2, X2
+
+loop16:
+	CMPQ     BX, $16
+	JB       tail
+	MOVOU    (SI), X3
+	MOVOU    X3, X4
+	PCMPEQB  X0, X4
+	MOVOU    X3, X5
+	PCMPEQB  X1, X5
+	POR      X5, X4
+	PCMPEQB  X2, X3
+	POR      X3, X4
+	PMOVMSKB X4, DX
+	TESTL    DX, DX
+	JNZ      found16
+	ADDQ     $16, SI
+	SUBQ     $16, BX
+	JMP      loop16
+
+found16:
+	BSFL DX, DX
+	SUBQ DI, SI
+	ADDQ DX, SI
+	MOVQ SI, ret+32(FP)
+	RET
+
+tail:
+	TESTQ BX, BX
+	JZ    notfound
+
+tailloop:
+	MOVBLZX (SI), DX
+	CMPB    DX, AX
+	JEQ     found1
+	CMPB    DX, CX
+	JEQ     found1
+	CMPB    DX, R9
+	JEQ     found1
+	INCQ    SI
+	DECQ    BX
+	JNZ     tailloop
+
+notfound:
+	MOVQ $-1, ret+32(FP)
+	RET
+
+found1:
+	SUBQ DI, SI
+	MOVQ SI, ret+32(FP)
+	RET
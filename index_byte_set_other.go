@@ -0,0 +1,7 @@
+//go:build !amd64 || purego || tinygo
+
+package quamina
+
+func indexByteSet3(s []byte, b0, b1, b2 byte) int {
+	return indexByteSet3Generic(s, b0, b1, b2)
+}
@@ -0,0 +1,56 @@
+package quamina
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestIndexByteSet3(t *testing.T) {
+	for trial := 0; trial < 5000; trial++ {
+		s := make([]byte, rand.Intn(100))
+		for i := range s {
+			s[i] = byte(rand.Intn(256))
+		}
+		// usually pick targets that appear in s, sometimes not
+		var targets [3]byte
+		for i := range targets {
+			if len(s) > 0 && rand.Intn(4) != 0 {
+				targets[i] = s[rand.Intn(len(s))]
+			} else {
+				targets[i] = byte(rand.Intn(256))
+			}
+		}
+		// don't always start at an aligned address
+		offset := 0
+		if len(s) > 0 {
+			offset = rand.Intn(len(s))
+		}
+		s = s[offset:]
+		want := indexByteSet3Generic(s, targets[0], targets[1], targets[2])
+		got := indexByteSet3(s, targets[0], targets[1], targets[2])
+		if got != want {
+			t.Fatalf("%v in %v: got %d want %d", targets, s, got, want)
+		}
+	}
+	if indexByteSet3(nil, 1, 2, 3) != -1 {
+		t.Error("empty input")
+	}
+}
+
+func BenchmarkIndexByteSet3(b *testing.B) {
+	s := make([]byte, 4096)
+	for i := range s {
+		s[i] = 'a' + byte(i%20)
+	}
+	s[len(s)-1] = 'z'
+	b.Run("asm", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			indexByteSet3(s, 'x', 'y', 'z')
+		}
+	})
+	b.Run("generic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			indexByteSet3Generic(s, 'x', 'y', 'z')
+		}
+	})
+}
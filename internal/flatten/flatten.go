@@ -0,0 +1,194 @@
+// Package flatten helps Flatteners for formats other than JSON produce the Fields that Quamina's JSON
+// Flattener would for the same data, so that Patterns work the same way whatever the format of the Events.
+// A Flattener walks its Event as if it were a JSON object, reporting each member to a Writer, which consults
+// the SegmentsTreeTracker to keep only the Fields that Patterns use, and keeps track of the positions in
+// arrays that stop a Pattern from matching fields in different elements of an array.
+package flatten
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Writer accumulates the Fields of an Event. Its zero value is ready to use, and Reset readies it for the
+// next Event, keeping its buffers.
+type Writer struct {
+	fields []quamina.Field
+	trail  []quamina.ArrayPos
+	arrays int32
+}
+
+// Reset forgets the Fields of the last Event.
+func (w *Writer) Reset() {
+	w.fields = w.fields[:0]
+	w.trail = w.trail[:0]
+	w.arrays = 0
+}
+
+// Fields returns the Fields written since the last Reset. They're valid until the next Reset.
+func (w *Writer) Fields() []quamina.Field {
+	return w.fields
+}
+
+// Used reports whether any Pattern uses the member name of the object at node.
+func Used(node quamina.SegmentsTreeTracker, name string) bool {
+	return node.IsSegmentUsed([]byte(name))
+}
+
+// String writes the member name of the object at node, whose value is the string s. Bytes in s that aren't
+// UTF-8 are replaced with U+FFFD.
+func (w *Writer) String(node quamina.SegmentsTreeTracker, name, s string) {
+	if Used(node, name) {
+		w.add(node.PathForSegment([]byte(name)), Quote(s), false)
+	}
+}
+
+// Number writes the member name of the object at node, whose value is a number, written as in JSON.
+func (w *Writer) Number(node quamina.SegmentsTreeTracker, name, number string) {
+	if Used(node, name) {
+		w.add(node.PathForSegment([]byte(name)), []byte(number), true)
+	}
+}
+
+// Literal writes the member name of the object at node, whose value is one of the JSON literals true,
+// false, and null.
+func (w *Writer) Literal(node quamina.SegmentsTreeTracker, name, literal string) {
+	if Used(node, name) {
+		w.add(node.PathForSegment([]byte(name)), []byte(literal), false)
+	}
+}
+
+// Object returns the node for the object that is the value of the member name of the object at node, for
+// writing its members, and false if no Pattern uses any of them, so that it can be skipped.
+func (w *Writer) Object(node quamina.SegmentsTreeTracker, name string) (quamina.SegmentsTreeTracker, bool) {
+	if !Used(node, name) {
+		return nil, false
+	}
+	return node.Get([]byte(name))
+}
+
+// Array starts the array that is the value of the member name of the object at node, returning false if no
+// Pattern uses it, so that it can be skipped. Otherwise, each element is started with Array.Next, and the
+// array is ended with Array.End.
+func (w *Writer) Array(node quamina.SegmentsTreeTracker, name string) (*Array, bool) {
+	if !Used(node, name) {
+		return nil, false
+	}
+	elementNode, ok := node.Get([]byte(name))
+	if !ok {
+		// an array of values, rather than of objects
+		elementNode = node
+	}
+	return w.startArray(node.PathForSegment([]byte(name)), elementNode), true
+}
+
+func (w *Writer) startArray(path []byte, node quamina.SegmentsTreeTracker) *Array {
+	w.arrays++
+	w.trail = append(w.trail, quamina.ArrayPos{Array: w.arrays})
+	return &Array{w: w, path: path, node: node}
+}
+
+// Sink is where a value is written: a member of an object, or an element of an array. Flatteners for formats
+// whose values can appear in either place can write them through a Sink with the same code.
+type Sink interface {
+	String(s string)
+	Number(number string)
+	Literal(literal string)
+	// Object returns the node for writing the members of the value, which is an object, and false if no
+	// Pattern uses any of them.
+	Object() (quamina.SegmentsTreeTracker, bool)
+	// Array starts the value, which is an array, and returns false if no Pattern uses it.
+	Array() (*Array, bool)
+}
+
+var (
+	_ Sink = Member{}
+	_ Sink = (*Array)(nil)
+)
+
+// Member returns the Sink for the member name of the object at node.
+func (w *Writer) Member(node quamina.SegmentsTreeTracker, name string) Member {
+	return Member{w: w, node: node, name: name}
+}
+
+// Member is a Sink for a member of an object.
+type Member struct {
+	w    *Writer
+	node quamina.SegmentsTreeTracker
+	name string
+}
+
+func (m Member) String(s string)        { m.w.String(m.node, m.name, s) }
+func (m Member) Number(number string)   { m.w.Number(m.node, m.name, number) }
+func (m Member) Literal(literal string) { m.w.Literal(m.node, m.name, literal) }
+
+func (m Member) Object() (quamina.SegmentsTreeTracker, bool) {
+	return m.w.Object(m.node, m.name)
+}
+
+func (m Member) Array() (*Array, bool) {
+	return m.w.Array(m.node, m.name)
+}
+
+// Array writes the elements of an array; it is the Sink for each in turn.
+type Array struct {
+	w    *Writer
+	path []byte
+	node quamina.SegmentsTreeTracker
+}
+
+// Next starts the next element.
+func (a *Array) Next() {
+	a.w.trail[len(a.w.trail)-1].Pos++
+}
+
+// End ends the array.
+func (a *Array) End() {
+	a.w.trail = a.w.trail[:len(a.w.trail)-1]
+}
+
+// String writes an element that is the string s.
+func (a *Array) String(s string) {
+	a.w.add(a.path, Quote(s), false)
+}
+
+// Number writes an element that is a number, written as in JSON.
+func (a *Array) Number(number string) {
+	a.w.add(a.path, []byte(number), true)
+}
+
+// Literal writes an element that is true, false, or null.
+func (a *Array) Literal(literal string) {
+	a.w.add(a.path, []byte(literal), false)
+}
+
+// Object returns the node for writing the members of an element that is an object.
+func (a *Array) Object() (quamina.SegmentsTreeTracker, bool) {
+	return a.node, true
+}
+
+// Array starts an element that is itself an array.
+func (a *Array) Array() (*Array, bool) {
+	return a.w.startArray(a.path, a.node), true
+}
+
+func (w *Writer) add(path, val []byte, isNumber bool) {
+	var trail []quamina.ArrayPos
+	if len(w.trail) > 0 {
+		trail = append([]quamina.ArrayPos(nil), w.trail...)
+	}
+	w.fields = append(w.fields, quamina.Field{Path: path, Val: val, ArrayTrail: trail, IsNumber: isNumber})
+}
+
+// Quote returns s as Quamina represents a string value, in quotation marks but otherwise unescaped.
+func Quote(s string) []byte {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	val := make([]byte, 0, len(s)+2)
+	val = append(val, '"')
+	val = append(val, s...)
+	return append(val, '"')
+}
@@ -0,0 +1,92 @@
+package flatten
+
+import (
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// fixed flattens every event as
+//
+//	{"name": "café", "n": 2.5, "ok": true, "a": [{"b": 1, "c": 2}, {"b": 3, "c": 4}], "tags": ["x", ["y"]]}
+type fixed struct {
+	w Writer
+}
+
+func (f *fixed) Flatten(_ []byte, tracker quamina.SegmentsTreeTracker) ([]quamina.Field, error) {
+	f.w.Reset()
+	f.w.String(tracker, "name", "caf\xc3\xa9")
+	f.w.Number(tracker, "n", "2.5")
+	f.w.Literal(tracker, "ok", "true")
+	if a, ok := f.w.Array(tracker, "a"); ok {
+		for _, bc := range [][2]string{{"1", "2"}, {"3", "4"}} {
+			a.Next()
+			element, _ := a.Object()
+			f.w.Number(element, "b", bc[0])
+			f.w.Member(element, "c").Number(bc[1])
+		}
+		a.End()
+	}
+	if tags, ok := f.w.Array(tracker, "tags"); ok {
+		tags.Next()
+		tags.String("x")
+		tags.Next()
+		inner, _ := tags.Array()
+		inner.Next()
+		inner.String("y")
+		inner.End()
+		tags.End()
+	}
+	return f.w.Fields(), nil
+}
+
+func (f *fixed) Copy() quamina.Flattener {
+	return &fixed{}
+}
+
+func TestWriter(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"name": ["café"], "n": [2.50], "ok": [true]}`, true},
+		{`{"a": {"b": [1], "c": [2]}}`, true},
+		{`{"a": {"b": [3], "c": [4]}}`, true},
+		{`{"a": {"b": [1], "c": [4]}}`, false},
+		{`{"tags": ["y"]}`, true},
+		{`{"missing": [{"exists": false}], "ok": [true]}`, true},
+	} {
+		q, err := quamina.New(quamina.WithFlattener(&fixed{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.AddPattern("p", test.pattern); err != nil {
+			t.Fatal(err)
+		}
+		matches, err := q.MatchesForEvent([]byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(matches) == 1; got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+}
+
+func TestUnusedFieldsAreSkipped(t *testing.T) {
+	f := &fixed{}
+	q, _ := quamina.New(quamina.WithFlattener(f))
+	_ = q.AddPattern("p", `{"ok": [true]}`)
+	if _, err := q.MatchesForEvent([]byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if fields := f.w.Fields(); len(fields) != 1 || string(fields[0].Path) != "ok" {
+		t.Errorf("flattened to %v", fields)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got := string(Quote("a\xffb")); got != "\"a�b\"" {
+		t.Errorf("quoted to %q", got)
+	}
+}
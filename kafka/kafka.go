@@ -0,0 +1,105 @@
+// Package kafka matches Kafka records against Quamina Patterns, so that filtering sidecars don't each have to
+// merge a record's parts into one Event. The record's headers, key, and value become an Event of the form
+//
+//	{"headers": {"ce_type": "order"}, "key": "customer-17", "value": {"amount": 250}}
+//
+// which Patterns select from in the usual way; this one matches orders over 100:
+//
+//	{"headers": {"ce_type": ["order"]}, "value": {"amount": [{"numeric": [">", 100]}]}}
+//
+// The package has no Kafka client dependency: the caller copies the fields of whichever client's record type
+// it uses into a Record.
+package kafka
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Header is a Kafka record header. Kafka allows a key to appear more than once.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Record is the part of a Kafka record that is matched.
+type Record struct {
+	Headers []Header
+	// Key, if not nil, is matched as a string.
+	Key []byte
+	// Value, if not nil, must be JSON, such as a JSON-encoded message body; a nil Value, as in a tombstone,
+	// is left out of the Event.
+	Value []byte
+}
+
+// Event returns the Event that Matches presents to Quamina for the record. Header values and the key are
+// strings, with any bytes that aren't UTF-8 replaced by U+FFFD; a header whose key appears more than once is
+// an array of its values, in order, so that a Pattern matches if any of them does.
+func Event(r Record) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"headers":{`)
+	written := make(map[string]bool, len(r.Headers))
+	for _, h := range r.Headers {
+		if written[h.Key] {
+			continue
+		}
+		written[h.Key] = true
+		if len(written) > 1 {
+			buf.WriteByte(',')
+		}
+		writeString(&buf, []byte(h.Key))
+		buf.WriteByte(':')
+		var values [][]byte
+		for _, other := range r.Headers {
+			if other.Key == h.Key {
+				values = append(values, other.Value)
+			}
+		}
+		if len(values) == 1 {
+			writeString(&buf, values[0])
+			continue
+		}
+		buf.WriteByte('[')
+		for i, value := range values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(&buf, value)
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+	if r.Key != nil {
+		buf.WriteString(`,"key":`)
+		writeString(&buf, r.Key)
+	}
+	if r.Value != nil {
+		if !json.Valid(r.Value) {
+			return nil, errors.New("record value is not JSON")
+		}
+		buf.WriteString(`,"value":`)
+		buf.Write(r.Value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Matches returns the X values of the Patterns in q which match the record.
+func Matches(q *quamina.Quamina, r Record) ([]quamina.X, error) {
+	event, err := Event(r)
+	if err != nil {
+		return nil, err
+	}
+	return q.MatchesForEvent(event)
+}
+
+// writeString writes b to buf as a JSON string
+func writeString(buf *bytes.Buffer, b []byte) {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(string(b)) // can't fail for a string
+	buf.Truncate(buf.Len() - 1)   // Encode adds a newline
+}
@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"slices"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func TestEvent(t *testing.T) {
+	tests := []struct {
+		record Record
+		want   string
+	}{
+		{Record{}, `{"headers":{}}`},
+		{Record{
+			Headers: []Header{{"ce_type", []byte("order")}, {"trace", []byte("a")}, {"ce_type", []byte("<&>")}},
+			Key:     []byte("customer-17"),
+			Value:   []byte(`{"amount": 250}`),
+		}, `{"headers":{"ce_type":["order","<&>"],"trace":"a"},"key":"customer-17","value":{"amount": 250}}`},
+		{Record{Headers: []Header{{"bin", []byte{0xff, 'x'}}}, Value: []byte(`"text"`)},
+			`{"headers":{"bin":"�x"},"value":"text"}`},
+	}
+	for _, test := range tests {
+		got, err := Event(test.record)
+		if err != nil {
+			t.Errorf("%v: %v", test.record, err)
+		} else if string(got) != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+	if _, err := Event(Record{Value: []byte("not json")}); err == nil {
+		t.Error("accepted a value that isn't JSON")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	q, _ := quamina.New(quamina.WithEventBridgeCompat())
+	patterns := map[string]string{
+		"big orders": `{"headers": {"ce_type": ["order"]}, "value": {"amount": [{"numeric": [">", 100]}]}}`,
+		"vip":        `{"key": [{"prefix": "vip-"}]}`,
+		"deletes":    `{"headers": {"op": ["delete"]}, "value": [{"exists": false}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records := []struct {
+		record Record
+		want   []string
+	}{
+		{Record{Headers: []Header{{"ce_type", []byte("order")}}, Key: []byte("vip-1"), Value: []byte(`{"amount": 250}`)},
+			[]string{"big orders", "vip"}},
+		{Record{Headers: []Header{{"ce_type", []byte("order")}}, Value: []byte(`{"amount": 50}`)}, nil},
+		{Record{Headers: []Header{{"x", []byte("y")}, {"ce_type", []byte("order")}}, Value: []byte(`{"amount": 101}`)},
+			[]string{"big orders"}},
+		{Record{Headers: []Header{{"op", []byte("delete")}}, Key: []byte("c-2")}, []string{"deletes"}},
+	}
+	for _, test := range records {
+		matches, err := Matches(q, test.record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.(string))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%v: matched %v, want %v", test.record, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package kubernetes matches Kubernetes objects against Quamina Patterns, for controllers and admission hooks
+// that react only to some of the objects they see. Matches presents an object to Quamina after removing the
+// bookkeeping that the API server adds to every object, which is large and never worth matching:
+//
+//	matches, err := kubernetes.Matches(q, pod)
+//
+// The object may be JSON text, an unstructured object's map[string]any, or a typed object, which is
+// converted to JSON. Patterns select from objects in the usual way, with helpers for the fields that filters
+// most often use; labels and annotations, whose keys may contain dots and slashes, are reached with Label and
+// Annotation, and LabelSelector compiles the selectors that kubectl and the API accept, such as
+// "app=web,tier notin (cache)". The package has presets too, such as PodCrashLooping and
+// DeploymentUnavailable, which can be narrowed with more conditions:
+//
+//	p, err := kubernetes.PodCrashLooping().And(kubernetes.Namespace("prod")).JSON()
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"maps"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// LastAppliedAnnotation is the annotation in which kubectl apply keeps a copy of the object as applied.
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// Event returns the Event that Matches presents to Quamina for object, which is the object without
+// metadata.managedFields and the LastAppliedAnnotation. object may be JSON text, as []byte or
+// json.RawMessage; a map[string]any, as in an unstructured object, which isn't modified; or anything else
+// that encoding/json can marshal into a JSON object.
+func Event(object any) ([]byte, error) {
+	var obj map[string]any
+	switch o := object.(type) {
+	case map[string]any:
+		obj = o
+	case []byte:
+		if err := decode(o, &obj); err != nil {
+			return nil, err
+		}
+	case json.RawMessage:
+		if err := decode(o, &obj); err != nil {
+			return nil, err
+		}
+	default:
+		data, err := json.Marshal(object)
+		if err != nil {
+			return nil, err
+		}
+		if err := decode(data, &obj); err != nil {
+			return nil, err
+		}
+	}
+	if obj == nil {
+		return nil, errors.New("object is not a JSON object")
+	}
+
+	if metadata, ok := obj["metadata"].(map[string]any); ok {
+		metadata = maps.Clone(metadata)
+		delete(metadata, "managedFields")
+		if annotations, ok := metadata["annotations"].(map[string]any); ok {
+			if _, ok := annotations[LastAppliedAnnotation]; ok {
+				annotations = maps.Clone(annotations)
+				delete(annotations, LastAppliedAnnotation)
+				metadata["annotations"] = annotations
+			}
+		}
+		obj = maps.Clone(obj)
+		obj["metadata"] = metadata
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(obj); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Matches returns the X values of the Patterns in q which match object, as presented by Event.
+func Matches(q *quamina.Quamina, object any) ([]quamina.X, error) {
+	event, err := Event(object)
+	if err != nil {
+		return nil, err
+	}
+	return q.MatchesForEvent(event)
+}
+
+// decode decodes a JSON object, keeping numbers' text as it was
+func decode(data []byte, obj *map[string]any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(obj); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("data after the object")
+	}
+	return nil
+}
+
+// Label starts a Condition on the label with the key.
+func Label(key string) *pattern.Condition {
+	return pattern.Field("metadata", "labels", key)
+}
+
+// Annotation starts a Condition on the annotation with the key.
+func Annotation(key string) *pattern.Condition {
+	return pattern.Field("metadata", "annotations", key)
+}
+
+// Namespace is the Condition that an object is in one of the namespaces.
+func Namespace(namespaces ...string) *pattern.Condition {
+	return pattern.Field("metadata", "namespace").Equals(namespaces...)
+}
+
+// Name is the Condition that an object has one of the names.
+func Name(names ...string) *pattern.Condition {
+	return pattern.Field("metadata", "name").Equals(names...)
+}
@@ -0,0 +1,136 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// samples returns the objects in testdata, by name
+func samples(t *testing.T) map[string][]byte {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+	objects := make(map[string][]byte)
+	for _, file := range files {
+		object, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objects[strings.TrimSuffix(filepath.Base(file), ".json")] = object
+	}
+	return objects
+}
+
+// matching returns the names of the samples that any of the patterns match, in order
+func matching(t *testing.T, patterns ...*pattern.Pattern) []string {
+	t.Helper()
+	q, _ := quamina.New()
+	for _, p := range patterns {
+		text, err := p.JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.AddPattern(1, text); err != nil {
+			t.Fatalf("%s: %v", text, err)
+		}
+	}
+	var names []string
+	for name, object := range samples(t) {
+		matches, err := Matches(q, object)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(matches) > 0 {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestEvent(t *testing.T) {
+	object := samples(t)["pod-crash-looping"]
+	event, err := Event(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{"managedFields", "last-applied-configuration", "kube-controller-manager"} {
+		if strings.Contains(string(event), gone) {
+			t.Errorf("event still has %s: %s", gone, event)
+		}
+	}
+	for _, kept := range []string{`"prometheus.io/scrape":"true"`, `"restartCount":7`, `"app.kubernetes.io/part-of":"shop"`} {
+		if !strings.Contains(string(event), kept) {
+			t.Errorf("event lacks %s: %s", kept, event)
+		}
+	}
+
+	// an unstructured object isn't changed
+	var unstructured map[string]any
+	if err := json.Unmarshal(object, &unstructured); err != nil {
+		t.Fatal(err)
+	}
+	fromMap, err := Event(unstructured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(fromMap), "managedFields") {
+		t.Errorf("event from a map has managedFields: %s", fromMap)
+	}
+	metadata := unstructured["metadata"].(map[string]any)
+	if _, ok := metadata["managedFields"]; !ok {
+		t.Error("Event changed its argument's metadata")
+	}
+	if _, ok := metadata["annotations"].(map[string]any)[LastAppliedAnnotation]; !ok {
+		t.Error("Event changed its argument's annotations")
+	}
+
+	// typed objects are marshaled
+	type meta struct {
+		Name   string            `json:"name"`
+		Labels map[string]string `json:"labels"`
+	}
+	typed := struct {
+		Kind     string `json:"kind"`
+		Metadata meta   `json:"metadata"`
+	}{"Pod", meta{"p", map[string]string{"a/b": "<c>"}}}
+	fromStruct, err := Event(typed)
+	if err != nil || string(fromStruct) != `{"kind":"Pod","metadata":{"labels":{"a/b":"<c>"},"name":"p"}}` {
+		t.Errorf("event from a struct is %s, %v", fromStruct, err)
+	}
+
+	for _, bad := range []any{[]byte(`[1]`), []byte(`{"a": 1} {}`), json.RawMessage(`{`), (*struct{})(nil), func() {}} {
+		if event, err := Event(bad); err == nil {
+			t.Errorf("Event(%v) gave %s", bad, event)
+		}
+	}
+}
+
+func TestFieldHelpers(t *testing.T) {
+	for _, test := range []struct {
+		p    *pattern.Pattern
+		want []string
+	}{
+		{pattern.And(Label("app.kubernetes.io/part-of").Equals("shop")), []string{"pod-crash-looping"}},
+		{pattern.And(Label("node-role.kubernetes.io/worker").Exists(true)), []string{"node-not-ready"}},
+		{pattern.And(Annotation("prometheus.io/scrape").Equals("true")), []string{"pod-crash-looping"}},
+		{pattern.And(Annotation(LastAppliedAnnotation).Exists(true)), nil},
+		{pattern.And(Namespace("prod"), Label("app").Equals("web")),
+			[]string{"configmap-deleting", "deployment-unavailable", "pod-crash-looping"}},
+		{pattern.And(Name("worker-0", "node-3")), []string{"node-not-ready", "pod-oom-killed"}},
+	} {
+		text, _ := test.p.JSON()
+		if got := matching(t, test.p); !slices.Equal(got, test.want) {
+			t.Errorf("%s matched %v, wanted %v", text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package kubernetes
+
+import (
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// Kind matches objects of the kind, such as "Deployment", and of the apiVersion, such as "apps/v1", unless
+// it's empty.
+func Kind(apiVersion, kind string) *pattern.Pattern {
+	p := pattern.And(pattern.Field("kind").Equals(kind))
+	if apiVersion != "" {
+		p = p.And(pattern.Field("apiVersion").Equals(apiVersion))
+	}
+	return p
+}
+
+// ofKind is Kind if kind isn't empty, and otherwise just the conditions
+func ofKind(kind string, conditions ...*pattern.Condition) *pattern.Pattern {
+	if kind == "" {
+		return pattern.And(conditions...)
+	}
+	return Kind("", kind).And(conditions...)
+}
+
+// Deleting matches objects of the kind, or of any kind if it's empty, whose deletion has been requested
+// but is waiting on finalizers.
+func Deleting(kind string) *pattern.Pattern {
+	return ofKind(kind, pattern.Field("metadata", "deletionTimestamp").Exists(true))
+}
+
+// HasFinalizer matches objects of the kind, or of any kind if it's empty, which have the finalizer.
+func HasFinalizer(kind, finalizer string) *pattern.Pattern {
+	return ofKind(kind, pattern.Field("metadata", "finalizers").Equals(finalizer))
+}
+
+// OwnedBy matches objects of the kind, or of any kind if it's empty, with an owner of ownerKind, as the
+// Pods of a ReplicaSet are.
+func OwnedBy(kind, ownerKind string) *pattern.Pattern {
+	return ofKind(kind, pattern.Field("metadata", "ownerReferences", "kind").Equals(ownerKind))
+}
+
+// StatusCondition matches objects of the kind, or of any kind if it's empty, which report a condition of
+// the type, such as "Ready", with one of the statuses, "True", "False", or "Unknown".
+func StatusCondition(kind, conditionType string, statuses ...string) *pattern.Pattern {
+	return ofKind(kind,
+		pattern.Field("status", "conditions", "type").Equals(conditionType),
+		pattern.Field("status", "conditions", "status").Equals(statuses...))
+}
+
+// PodPhase matches Pods in any of the phases, such as "Pending" and "Failed".
+func PodPhase(phases ...string) *pattern.Pattern {
+	return Kind("v1", "Pod").And(pattern.Field("status", "phase").Equals(phases...))
+}
+
+// PodContainerWaiting matches Pods with a container waiting for any of the reasons, such as
+// "CreateContainerConfigError".
+func PodContainerWaiting(reasons ...string) *pattern.Pattern {
+	return Kind("v1", "Pod").And(
+		pattern.Field("status", "containerStatuses", "state", "waiting", "reason").Equals(reasons...))
+}
+
+// PodCrashLooping matches Pods with a container that keeps failing, which the kubelet is waiting to restart.
+func PodCrashLooping() *pattern.Pattern {
+	return PodContainerWaiting("CrashLoopBackOff")
+}
+
+// PodImagePullFailing matches Pods with a container whose image can't be pulled.
+func PodImagePullFailing() *pattern.Pattern {
+	return PodContainerWaiting("ErrImagePull", "ImagePullBackOff", "InvalidImageName")
+}
+
+// PodOOMKilled matches Pods with a container whose last run ended with it being killed for using too much
+// memory.
+func PodOOMKilled() *pattern.Pattern {
+	return Kind("v1", "Pod").And(
+		pattern.Field("status", "containerStatuses", "lastState", "terminated", "reason").Equals("OOMKilled"))
+}
+
+// PodUnschedulable matches Pods that the scheduler can't find a Node for.
+func PodUnschedulable() *pattern.Pattern {
+	return Kind("v1", "Pod").And(
+		pattern.Field("status", "conditions", "type").Equals("PodScheduled"),
+		pattern.Field("status", "conditions", "reason").Equals("Unschedulable"))
+}
+
+// DeploymentUnavailable matches Deployments without the minimum number of Pods available.
+func DeploymentUnavailable() *pattern.Pattern {
+	return StatusCondition("Deployment", "Available", "False")
+}
+
+// NodeNotReady matches Nodes that aren't ready for Pods, including those the control plane has lost
+// touch with.
+func NodeNotReady() *pattern.Pattern {
+	return StatusCondition("Node", "Ready", "False", "Unknown")
+}
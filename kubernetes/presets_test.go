@@ -0,0 +1,41 @@
+package kubernetes
+
+import (
+	"slices"
+	"testing"
+
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+func TestPresets(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		p    *pattern.Pattern
+		want []string
+	}{
+		{"Kind", Kind("apps/v1", "Deployment"), []string{"deployment-unavailable"}},
+		{"Kind wrong version", Kind("apps/v1beta1", "Deployment"), nil},
+		{"Kind any version", Kind("", "Pod"), []string{"pod-crash-looping", "pod-oom-killed", "pod-unschedulable"}},
+		{"Deleting", Deleting(""), []string{"configmap-deleting"}},
+		{"Deleting Deployments", Deleting("Deployment"), nil},
+		{"HasFinalizer", HasFinalizer("", "example.com/cleanup"), []string{"configmap-deleting", "deployment-unavailable"}},
+		{"OwnedBy", OwnedBy("Pod", "ReplicaSet"), []string{"pod-crash-looping"}},
+		{"StatusCondition", StatusCondition("Pod", "Ready", "False"), []string{"pod-crash-looping"}},
+		{"StatusCondition any kind", StatusCondition("", "Ready", "True"), []string{"pod-oom-killed"}},
+		{"PodPhase", PodPhase("Pending", "Failed"), []string{"pod-unschedulable"}},
+		{"PodCrashLooping", PodCrashLooping(), []string{"pod-crash-looping"}},
+		{"PodCrashLooping in prod", PodCrashLooping().And(Namespace("prod")), []string{"pod-crash-looping"}},
+		{"PodCrashLooping in batch", PodCrashLooping().And(Namespace("batch")), nil},
+		{"PodImagePullFailing", PodImagePullFailing(), nil},
+		{"PodOOMKilled", PodOOMKilled(), []string{"pod-oom-killed"}},
+		// the crash-looping Pod has a PodScheduled condition, and one with a reason, but they're different
+		// conditions
+		{"PodUnschedulable", PodUnschedulable(), []string{"pod-unschedulable"}},
+		{"DeploymentUnavailable", DeploymentUnavailable(), []string{"deployment-unavailable"}},
+		{"NodeNotReady", NodeNotReady(), []string{"node-not-ready"}},
+	} {
+		if got := matching(t, test.p); !slices.Equal(got, test.want) {
+			t.Errorf("%s matched %v, wanted %v", test.name, got, test.want)
+		}
+	}
+}
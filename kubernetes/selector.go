@@ -0,0 +1,162 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// LabelSelector compiles a Kubernetes label selector, in the syntax of kubectl's --selector, into Patterns
+// matching the objects it selects. The selector is a comma-separated list of requirements, all of which an
+// object must meet:
+//
+//	key              the object has the label
+//	!key             it doesn't
+//	key=value        the label has the value; == is the same
+//	key!=value       the label has another value, or the object doesn't have it
+//	key in (a,b)     the label has one of the values
+//	key notin (a,b)  the label has none of them, or the object doesn't have it
+//
+// Each Pattern is base, if it isn't nil, with the selector's conditions added. As the selections
+// of != and notin include the objects without the label, a selector may need more than one Pattern, all of
+// which should be added with the same X value. An empty selector selects everything, so it returns just base,
+// or an error if base is nil, since a Pattern needs a condition.
+func LabelSelector(selector string, base *pattern.Pattern) ([]*pattern.Pattern, error) {
+	alternatives := [][]*pattern.Condition{nil}
+	requirements, err := splitRequirements(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, requirement := range requirements {
+		choices, err := labelRequirement(requirement)
+		if err != nil {
+			return nil, fmt.Errorf("label selector requirement %q: %w", requirement, err)
+		}
+		var next [][]*pattern.Condition
+		for _, alternative := range alternatives {
+			for _, choice := range choices {
+				next = append(next, append(alternative[:len(alternative):len(alternative)], choice))
+			}
+		}
+		alternatives = next
+	}
+
+	if len(requirements) == 0 {
+		if base == nil {
+			return nil, errors.New("empty label selector with no base Pattern")
+		}
+		return []*pattern.Pattern{base}, nil
+	}
+	patterns := make([]*pattern.Pattern, len(alternatives))
+	for i, conditions := range alternatives {
+		if base == nil {
+			patterns[i] = pattern.And(conditions...)
+		} else {
+			patterns[i] = base.And(conditions...)
+		}
+	}
+	return patterns, nil
+}
+
+// splitRequirements splits a selector at the commas that aren't in parentheses
+func splitRequirements(selector string) ([]string, error) {
+	var requirements []string
+	depth, start := 0, 0
+	for i, ch := range selector + "," {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced ')' in label selector at %d", i)
+			}
+		case ',':
+			if depth > 0 {
+				continue
+			}
+			requirement := strings.TrimSpace(selector[start:min(i, len(selector))])
+			start = i + 1
+			if requirement == "" {
+				if strings.TrimSpace(selector) == "" {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("empty requirement in label selector at %d", i)
+			}
+			requirements = append(requirements, requirement)
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced '(' in label selector")
+	}
+	return requirements, nil
+}
+
+// labelRequirement compiles one requirement into the Conditions of which an object must meet one
+func labelRequirement(requirement string) ([]*pattern.Condition, error) {
+	if key, ok := strings.CutPrefix(requirement, "!"); ok {
+		key = strings.TrimSpace(key)
+		if err := checkKey(key); err != nil {
+			return nil, err
+		}
+		return []*pattern.Condition{Label(key).Exists(false)}, nil
+	}
+
+	if open := strings.IndexByte(requirement, '('); open >= 0 {
+		words := strings.Fields(requirement[:open])
+		if len(words) != 2 || (words[1] != "in" && words[1] != "notin") || !strings.HasSuffix(requirement, ")") {
+			return nil, errors.New("expected key in (values) or key notin (values)")
+		}
+		if err := checkKey(words[0]); err != nil {
+			return nil, err
+		}
+		var values []string
+		for _, value := range strings.Split(requirement[open+1:len(requirement)-1], ",") {
+			values = append(values, strings.TrimSpace(value))
+		}
+		if words[1] == "in" {
+			return []*pattern.Condition{Label(words[0]).Equals(values...)}, nil
+		}
+		return notEqual(words[0], values), nil
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		key, value, ok := strings.Cut(requirement, op)
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if err := checkKey(key); err != nil {
+			return nil, err
+		}
+		if op == "!=" {
+			return notEqual(key, []string{value}), nil
+		}
+		return []*pattern.Condition{Label(key).Equals(value)}, nil
+	}
+
+	if err := checkKey(requirement); err != nil {
+		return nil, err
+	}
+	return []*pattern.Condition{Label(requirement).Exists(true)}, nil
+}
+
+// notEqual is met by objects whose label with the key has none of the values, including those without it
+func notEqual(key string, values []string) []*pattern.Condition {
+	return []*pattern.Condition{Label(key).AnythingBut(values...), Label(key).Exists(false)}
+}
+
+// checkKey rejects keys that can't be label keys, which are usually mistakes in the selector's syntax
+func checkKey(key string) error {
+	if key == "" {
+		return errors.New("missing key")
+	}
+	if strings.ContainsFunc(key, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./", r))
+	}) {
+		return fmt.Errorf("%q is not a label key", key)
+	}
+	return nil
+}
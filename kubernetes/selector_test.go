@@ -0,0 +1,84 @@
+package kubernetes
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLabelSelector(t *testing.T) {
+	for _, test := range []struct {
+		selector string
+		want     []string
+	}{
+		{"app=web", []string{"configmap-deleting", "deployment-unavailable", "pod-crash-looping"}},
+		{"app==web, tier=frontend", []string{"pod-crash-looping"}},
+		{"tier", []string{"configmap-deleting", "pod-crash-looping", "pod-oom-killed"}},
+		{"!tier", []string{"deployment-unavailable", "node-not-ready", "pod-unschedulable"}},
+		{"app in (worker, trainer)", []string{"pod-oom-killed", "pod-unschedulable"}},
+		// objects without the label meet != and notin
+		{"tier!=frontend", []string{"configmap-deleting", "deployment-unavailable", "node-not-ready", "pod-oom-killed",
+			"pod-unschedulable"}},
+		{"tier notin (frontend,config),app", []string{"deployment-unavailable", "pod-oom-killed", "pod-unschedulable"}},
+		{"tier!=frontend,app!=web", []string{"node-not-ready", "pod-oom-killed", "pod-unschedulable"}},
+		{"node-role.kubernetes.io/worker=", []string{"node-not-ready"}},
+		{"kubernetes.io/hostname in (node-1,node-2)", nil},
+	} {
+		patterns, err := LabelSelector(test.selector, nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.selector, err)
+			continue
+		}
+		if got := matching(t, patterns...); !slices.Equal(got, test.want) {
+			t.Errorf("%s matched %v, wanted %v", test.selector, got, test.want)
+		}
+	}
+}
+
+func TestLabelSelectorBase(t *testing.T) {
+	patterns, err := LabelSelector("tier!=frontend", Kind("v1", "Pod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 2 {
+		t.Errorf("%d patterns", len(patterns))
+	}
+	if got := matching(t, patterns...); !slices.Equal(got, []string{"pod-oom-killed", "pod-unschedulable"}) {
+		t.Errorf("matched %v", got)
+	}
+
+	patterns, err = LabelSelector(" ", Kind("", "Node"))
+	if err != nil || len(patterns) != 1 {
+		t.Fatalf("empty selector gave %d patterns, %v", len(patterns), err)
+	}
+	if got := matching(t, patterns...); !slices.Equal(got, []string{"node-not-ready"}) {
+		t.Errorf("empty selector matched %v", got)
+	}
+}
+
+func TestLabelSelectorErrors(t *testing.T) {
+	for _, selector := range []string{
+		"",
+		"app=web,",
+		"app=web,,tier",
+		"app in (web",
+		"app in web)",
+		"app within (web)",
+		"in (web)",
+		"=web",
+		"!",
+		"a b=c",
+		"app in (web) x",
+	} {
+		if patterns, err := LabelSelector(selector, nil); err == nil {
+			t.Errorf("%q gave %d patterns", selector, len(patterns))
+		}
+	}
+	// a selector with two requirements on one label fails when the Pattern is rendered
+	patterns, err := LabelSelector("app,app=web", nil)
+	if err == nil {
+		_, err = patterns[0].JSON()
+	}
+	if err == nil {
+		t.Error("two requirements on one label rendered")
+	}
+}
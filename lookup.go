@@ -0,0 +1,89 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+)
+
+// Lookup is a set of values kept outside of Quamina, such as a threat-intelligence feed's list of addresses,
+// which "lookup" Patterns match the values in. Rather than the values being added in Patterns, possibly
+// thousands of them, the set is asked, as each Event is matched, whether it contains the Event's value, so it
+// can be refreshed without touching the Patterns. The value is a string's contents, without the quotes, or
+// the text of a number, true, false, or null. Contains is called concurrently by instances created with Copy,
+// and must not modify or keep val.
+type Lookup interface {
+	Contains(val []byte) bool
+}
+
+// WithLookups provides the Lookups that "lookup" Patterns name, as in {"source-ip": [{"lookup": "blocklist"}]},
+// which matches Events whose source-ip the Lookup named "blocklist" contains. The Lookups apply to instances
+// created with Copy. This option call may not be provided more than once.
+func WithLookups(lookups map[string]Lookup) Option {
+	return func(q *Quamina) error {
+		if q.lookups != nil {
+			return errors.New("lookups specified more than once")
+		}
+		for name, lookup := range lookups {
+			if lookup == nil {
+				return fmt.Errorf("lookup %q is nil", name)
+			}
+		}
+		q.lookups = maps.Clone(lookups)
+		if q.lookups == nil {
+			q.lookups = map[string]Lookup{}
+		}
+		return nil
+	}
+}
+
+func (m *coreMatcher) setLookups(lookups map[string]Lookup) {
+	m.lookups = lookups
+}
+
+type lookupTest struct {
+	name   string
+	lookup Lookup
+	next   *fieldMatcher
+}
+
+// readLookupSpecial reads a "lookup" pattern, whose value is the name of one of the instance's Lookups
+func readLookupSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	pathVals = valsIn
+
+	name, ok := t.(string)
+	if !ok {
+		err = errors.New("value for 'lookup' must be a string")
+		return
+	}
+	pathVals = append(pathVals, typedVal{vType: lookupType, val: name})
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
+
+// bindLookups attaches the Lookups that the "lookup" values in a Pattern name to them, since the rangeMatcher
+// consults them
+func (m *coreMatcher) bindLookups(fields []*patternField) error {
+	for _, field := range fields {
+		for i, val := range field.vals {
+			if val.vType != lookupType {
+				continue
+			}
+			if m.lookups == nil {
+				return errors.New("lookup patterns are only supported by WithLookups")
+			}
+			lookup, ok := m.lookups[val.val]
+			if !ok {
+				return fmt.Errorf("unknown lookup %q", val.val)
+			}
+			field.vals[i].lookup = lookup
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package quamina
+
+import (
+	"sync"
+	"testing"
+)
+
+// liveSet is a Lookup that can be refreshed while it's in use
+type liveSet struct {
+	lock   sync.RWMutex
+	values map[string]bool
+}
+
+func newLiveSet(values ...string) *liveSet {
+	s := &liveSet{}
+	s.replace(values...)
+	return s
+}
+
+func (s *liveSet) replace(values ...string) {
+	fresh := make(map[string]bool, len(values))
+	for _, v := range values {
+		fresh[v] = true
+	}
+	s.lock.Lock()
+	s.values = fresh
+	s.lock.Unlock()
+}
+
+func (s *liveSet) Contains(val []byte) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.values[string(val)]
+}
+
+func TestLookup(t *testing.T) {
+	blocklist := newLiveSet("10.0.0.1", "192.168.1.66")
+	ports := newLiveSet("22", "3389")
+	for _, deletion := range []bool{false, true} {
+		q, err := New(WithLookups(map[string]Lookup{"blocklist": blocklist, "ports": ports}), WithPatternDeletion(deletion))
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns := map[X]string{
+			"blocked":   `{"source-ip": [{"lookup": "blocklist"}]}`,
+			"admin":     `{"source-ip": [{"lookup": "blocklist"}], "port": [{"lookup": "ports"}]}`,
+			"localhost": `{"source-ip": ["127.0.0.1", {"lookup": "blocklist"}]}`,
+		}
+		for x, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+		tests := map[string][]X{
+			`{"source-ip": "10.0.0.1"}`:                         {"blocked", "localhost"},
+			`{"source-ip": "10.0.0.1", "port": 22}`:             {"blocked", "admin", "localhost"},
+			`{"source-ip": "127.0.0.1", "port": 3389}`:          {"localhost"},
+			`{"source-ip": ["8.8.8.8", "192.168.1.66"]}`:        {"blocked", "localhost"},
+			`{"source-ip": "8.8.8.8", "port": "22"}`:            {},
+			`{"source-ip": "192.168.1.66", "port": [80, 3389]}`: {"blocked", "admin", "localhost"},
+		}
+		for event, want := range tests {
+			for _, instance := range []*Quamina{q, q.Copy()} {
+				matches, err := instance.MatchesForEvent([]byte(event))
+				if err != nil || !containsExactly(matches, want) {
+					t.Errorf("deletion=%v, %s: %v, %v", deletion, event, matches, err)
+				}
+			}
+		}
+
+		// a refreshed set is used without the Patterns changing
+		blocklist.replace("8.8.8.8")
+		matches, err := q.MatchesForEvent([]byte(`{"source-ip": "8.8.8.8"}`))
+		if err != nil || !containsExactly(matches, []X{"blocked", "localhost"}) {
+			t.Errorf("after refresh: %v, %v", matches, err)
+		}
+		matches, _ = q.MatchesForEvent([]byte(`{"source-ip": "10.0.0.1"}`))
+		if len(matches) != 0 {
+			t.Errorf("after refresh: %v", matches)
+		}
+		blocklist.replace("10.0.0.1", "192.168.1.66")
+
+		if deletion {
+			_ = q.DeletePatterns("localhost")
+			if err := q.matcher.(*prunerMatcher).rebuild(false); err != nil {
+				t.Fatal(err)
+			}
+			matches, _ := q.MatchesForEvent([]byte(`{"source-ip": "10.0.0.1"}`))
+			if !containsExactly(matches, []X{"blocked"}) {
+				t.Errorf("after rebuild: %v", matches)
+			}
+		}
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	q, _ := New(WithLookups(map[string]Lookup{"set": newLiveSet()}))
+	for _, bad := range []string{
+		`{"a": [{"lookup": "other"}]}`,
+		`{"a": [{"lookup": 3}]}`,
+		`{"a": [{"lookup": ["set"]}]}`,
+	} {
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+	plain, _ := New()
+	if err := plain.AddPattern("x", `{"a": [{"lookup": "set"}]}`); err == nil {
+		t.Error("accepted lookup without WithLookups")
+	}
+	if _, err := New(WithLookups(map[string]Lookup{"set": nil})); err == nil {
+		t.Error("accepted nil Lookup")
+	}
+	if _, err := New(WithLookups(nil), WithLookups(nil)); err == nil {
+		t.Error("accepted option twice")
+	}
+}
@@ -0,0 +1,98 @@
+package quamina
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// WithMatchMultiplicity arranges, if the argument is true, that the instance keeps track of which of the
+// Patterns added with each X match an Event, so that MatchCountsForEvent can report how many do; this matters
+// for scoring and weighted routing, where an X matched by three Patterns counts for more than one matched by
+// one. Patterns are told apart by their text, so adding the same Pattern twice with an X counts once, as does
+// an EventBridge Pattern whose "$or" makes it match several ways. The other matching APIs are unaffected, but
+// every Pattern is matched separately, so Patterns with the same X share less of the automaton than they
+// otherwise would. It isn't supported by instances created WithPatternDeletion(true). The option applies to
+// instances created with Copy, and may not be provided more than once.
+func WithMatchMultiplicity(b bool) Option {
+	return func(q *Quamina) error {
+		if q.multiplicitySpecified {
+			return errors.New("match multiplicity specified more than once")
+		}
+		q.multiplicitySpecified = true
+		if b {
+			q.multiplicity = &patternKeys{keys: make(map[X][]string)}
+		}
+		return nil
+	}
+}
+
+// MatchCountsForEvent returns, for each X whose Patterns match the Event, the number of them that do. It needs
+// an instance created WithMatchMultiplicity(true). The map belongs to the caller.
+func (q *Quamina) MatchCountsForEvent(event []byte) (map[X]int, error) {
+	if q.multiplicity == nil {
+		return nil, errors.New("match counts are only kept by instances created WithMatchMultiplicity(true)")
+	}
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	fields, err := q.flattener.Flatten(event, q.matcher.getSegmentsTreeTracker())
+	if err != nil {
+		return nil, err
+	}
+	q.labels.matching()
+	matches := q.matcher.(*coreMatcher).matchSetForFields(fields, q.bufs)
+	q.bufs.release()
+	if err = q.bufs.limitError(); err != nil {
+		return nil, err
+	}
+	q.bufs.countPatterns(matches)
+	xs := make([]X, 0, len(q.bufs.counts))
+	for x := range q.bufs.counts {
+		xs = append(xs, x)
+	}
+	if q.samples.active() {
+		xs = q.samples.filter(xs, eventHash(event))
+	}
+	counts := make(map[X]int, len(xs))
+	for _, x := range xs {
+		counts[x] = q.bufs.counts[x]
+	}
+	return counts, nil
+}
+
+// patternKey is what an instance created WithMatchMultiplicity(true) adds a Pattern to its matcher with, in
+// place of the Pattern's X, so that each Pattern's matches are kept apart from those of the others with that X
+type patternKey struct {
+	x X
+	n int // the Pattern's index among those added with x
+}
+
+// patternKeys are the texts of the Patterns added with each X, whose indexes make their patternKeys
+type patternKeys struct {
+	lock sync.Mutex
+	keys map[X][]string
+}
+
+// key returns the patternKey for the Pattern, which is a new one unless it was added before
+func (pk *patternKeys) key(x X, pattern string) patternKey {
+	pk.lock.Lock()
+	defer pk.lock.Unlock()
+	texts := pk.keys[x]
+	n := slices.Index(texts, pattern)
+	if n < 0 {
+		n = len(texts)
+		pk.keys[x] = append(texts, pattern)
+	}
+	return patternKey{x: x, n: n}
+}
+
+// countPatterns fills nb.counts with the number of Patterns, as told apart by their patternKeys, matched for
+// each X
+func (nb *nfaBuffers) countPatterns(matches *matchSet) {
+	clear(nb.counts)
+	for key := range matches.set {
+		nb.counts[key.(patternKey).x]++
+	}
+}
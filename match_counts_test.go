@@ -0,0 +1,66 @@
+package quamina
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestMatchMultiplicity(t *testing.T) {
+	q, _ := New(WithMatchMultiplicity(true), WithOrderedMatches(true))
+	patterns := []struct {
+		x       X
+		pattern string
+	}{
+		{"both", `{"a": ["x"]}`},
+		{"both", `{"a": ["x", "y"]}`},
+		{"both", `{"a": ["x"]}`}, // the same Pattern again
+		{"both", `{"b": [{"prefix": "z"}]}`},
+		{"both", `{"b": ["nope"]}`},
+		{"one", `{"a": ["x"]}`},
+		{"none", `{"a": ["w"]}`},
+	}
+	for _, p := range patterns {
+		if err := q.AddPattern(p.x, p.pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := []byte(`{"a": "x", "b": "zz"}`)
+	want := map[X]int{"both": 3, "one": 1}
+	for _, instance := range []*Quamina{q, q.Copy()} {
+		counts, err := instance.MatchCountsForEvent(event)
+		if err != nil || !maps.Equal(counts, want) {
+			t.Errorf("counts %v, %v", counts, err)
+		}
+		// the other APIs still report each X once
+		matches, err := instance.MatchesForEvent(event)
+		if err != nil || !slices.Equal(matches, []X{"both", "one"}) {
+			t.Errorf("matches %v, %v", matches, err)
+		}
+		if n, err := instance.CountMatchesForEvent(event); err != nil || n != 2 {
+			t.Errorf("count %d, %v", n, err)
+		}
+	}
+
+	// an "$or" is one Pattern, however many of its alternatives match
+	eb, _ := New(WithMatchMultiplicity(true), WithEventBridgeCompat())
+	_ = eb.AddPattern("or", `{"$or": [{"a": ["x"]}, {"b": ["zz"]}]}`)
+	_ = eb.AddPattern("or", `{"a": [{"exists": true}]}`)
+	if counts, err := eb.MatchCountsForEvent(event); err != nil || counts["or"] != 2 {
+		t.Errorf("$or: %v, %v", counts, err)
+	}
+
+	plain, _ := New()
+	if _, err := plain.MatchCountsForEvent(event); err == nil {
+		t.Error("counts without multiplicity")
+	}
+	if _, err := New(WithMatchMultiplicity(true), WithPatternDeletion(true)); err == nil {
+		t.Error("multiplicity with deletion")
+	}
+	if _, err := New(WithMatchMultiplicity(true), WithPatternDeletion(false)); err != nil {
+		t.Error(err)
+	}
+	if _, err := New(WithMatchMultiplicity(true), WithMatchMultiplicity(false)); err == nil {
+		t.Error("multiplicity specified twice")
+	}
+}
@@ -0,0 +1,47 @@
+package quamina
+
+import (
+	"strings"
+)
+
+// MatchesForEventWithFields is like MatchesForEvent, but also returns the fields of the Event with the provided
+// paths, in no particular order, so that a caller can read values from Events in whatever format the Flattener
+// understands. Only fields the Flattener extracts are found, so each path has to be used by a Pattern or
+// provided to AddFieldPaths. The Path of each field returned is the path it was found with, even if the
+// instance was created WithCaseInsensitiveFieldNames. The fields, and their Val slices, must not be modified
+// or kept after the next call.
+func (q *Quamina) MatchesForEventWithFields(event []byte, paths ...string) ([]X, []Field, error) {
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	fields, err := q.flattener.Flatten(event, q.matcher.getSegmentsTreeTracker())
+	if err != nil {
+		return nil, nil, err
+	}
+	var found []Field
+	for _, field := range fields {
+		for _, path := range paths {
+			if q.foldFieldNames {
+				if string(field.Path) != strings.ToLower(path) {
+					continue
+				}
+			} else if string(field.Path) != path {
+				continue
+			}
+			field.Path = []byte(path)
+			found = append(found, field)
+			break
+		}
+	}
+	q.labels.matching()
+	matches, err := q.matcher.matchesForFields(fields, q.bufs)
+	q.bufs.release()
+	if err != nil {
+		return nil, nil, err
+	}
+	if q.samples.active() {
+		matches = q.samples.filter(matches, eventHash(event))
+	}
+	return matches, found, nil
+}
@@ -0,0 +1,43 @@
+package quamina
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatchesForEventWithFields(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("x", `{"a": ["x"]}`)
+	_ = q.AddFieldPaths(JoinPath("user", "name"), "tags")
+	event := []byte(`{"a": "x", "user": {"name": "alice", "id": 17}, "tags": ["p", "q"], "other": 1}`)
+
+	matches, fields, err := q.MatchesForEventWithFields(event, "a", JoinPath("user", "name"), "tags", "other")
+	if err != nil || !slices.Equal(matches, []X{"x"}) {
+		t.Fatalf("matches %v, %v", matches, err)
+	}
+	var got []string
+	for _, field := range fields {
+		got = append(got, string(field.Path)+"="+string(field.Val))
+	}
+	slices.Sort(got)
+	// "other" isn't extracted, as no Pattern uses it
+	want := []string{`a="x"`, `tags="p"`, `tags="q"`, "user\nname=\"alice\""}
+	if !slices.Equal(got, want) {
+		t.Errorf("fields %q", got)
+	}
+
+	if _, fields, _ := q.MatchesForEventWithFields(event); fields != nil {
+		t.Errorf("fields without paths: %v", fields)
+	}
+	if _, _, err := q.MatchesForEventWithFields([]byte(`{"a": `)); err == nil {
+		t.Error("accepted bad JSON")
+	}
+
+	// the paths are those asked for, whatever the case of the field names
+	folded, _ := New(WithCaseInsensitiveFieldNames())
+	_ = folded.AddFieldPaths("Host")
+	_, fields, err = folded.MatchesForEventWithFields([]byte(`{"HOST": "h1"}`), "Host")
+	if err != nil || len(fields) != 1 || string(fields[0].Path) != "Host" || string(fields[0].Val) != `"h1"` {
+		t.Errorf("folded fields %v, %v", fields, err)
+	}
+}
@@ -0,0 +1,90 @@
+package quamina
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// WithOrderedMatches arranges, if the argument is true, that MatchesForEvent and the other matching APIs return
+// the X values in the order in which they were first added with AddPattern, rather than in no particular order,
+// so that downstream systems which act on the first match behave the same from run to run. An X deleted with
+// DeletePatterns and added again goes to the end. The order is shared with instances created with Copy. Sorting
+// the matches takes a little time, which grows with their number. This option may not be combined with
+// WithMatchOrder, and may not be provided more than once.
+func WithOrderedMatches(b bool) Option {
+	return func(q *Quamina) error {
+		if q.matchOrderSpecified {
+			return errors.New("match order specified more than once")
+		}
+		q.matchOrderSpecified = true
+		if b {
+			q.matchOrder = &matchOrder{added: make(map[X]uint64)}
+		}
+		return nil
+	}
+}
+
+// WithMatchOrder arranges that MatchesForEvent and the other matching APIs return the X values sorted by
+// compare, which returns a negative number, zero, or a positive number as a sorts before, the same as, or
+// after b, like the functions slices.SortFunc takes. X values which compare the same come out in no particular
+// order. compare is called during matching, on instances created with Copy too, so it must be safe to call
+// concurrently. This option may not be combined with WithOrderedMatches, and may not be provided more than
+// once.
+func WithMatchOrder(compare func(a, b X) int) Option {
+	return func(q *Quamina) error {
+		if q.matchOrderSpecified {
+			return errors.New("match order specified more than once")
+		}
+		if compare == nil {
+			return errors.New("nil match order function")
+		}
+		q.matchOrderSpecified = true
+		q.matchOrder = &matchOrder{compare: compare}
+		return nil
+	}
+}
+
+// matchOrder sorts matches, by a caller's function if there is one, or else by when their X was added
+type matchOrder struct {
+	compare func(a, b X) int
+	lock    sync.RWMutex
+	added   map[X]uint64
+	next    uint64
+}
+
+// add records when x was added, unless it's already known
+func (mo *matchOrder) add(x X) {
+	if mo.compare != nil {
+		return
+	}
+	mo.lock.Lock()
+	if _, ok := mo.added[x]; !ok {
+		mo.added[x] = mo.next
+		mo.next++
+	}
+	mo.lock.Unlock()
+}
+
+func (mo *matchOrder) delete(x X) {
+	if mo.compare != nil {
+		return
+	}
+	mo.lock.Lock()
+	delete(mo.added, x)
+	mo.lock.Unlock()
+}
+
+func (mo *matchOrder) sort(matches []X) {
+	if len(matches) < 2 {
+		return
+	}
+	if mo.compare != nil {
+		slices.SortFunc(matches, mo.compare)
+		return
+	}
+	mo.lock.RLock()
+	slices.SortFunc(matches, func(a, b X) int { return cmp.Compare(mo.added[a], mo.added[b]) })
+	mo.lock.RUnlock()
+}
@@ -0,0 +1,79 @@
+package quamina
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOrderedMatches(t *testing.T) {
+	event := []byte(`{"a": "x", "b": "y"}`)
+	for _, deletion := range []bool{false, true} {
+		q, _ := New(WithOrderedMatches(true), WithPatternDeletion(deletion))
+		added := []X{"p5", "p1", "p9", "p3", "p7", "p2"}
+		for i, x := range added {
+			pattern := `{"a": ["x"]}`
+			if i%2 == 1 {
+				pattern = `{"b": [{"prefix": "y"}]}`
+			}
+			if err := q.AddPattern(x, pattern); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// adding another Pattern doesn't move p1
+		_ = q.AddPattern("p1", `{"a": ["x"]}`)
+
+		for _, instance := range []*Quamina{q, q.Copy()} {
+			for i := 0; i < 20; i++ {
+				matches, err := instance.MatchesForEvent(event)
+				if err != nil || !slices.Equal(matches, added) {
+					t.Fatalf("deletion=%v: %v, %v", deletion, matches, err)
+				}
+			}
+		}
+		results, err := q.MatchesForEvents([][]byte{event, event})
+		if err != nil || !slices.Equal(results[0], added) || !slices.Equal(results[1], added) {
+			t.Errorf("deletion=%v: events %v, %v", deletion, results, err)
+		}
+
+		if deletion {
+			_ = q.DeletePatterns("p1")
+			_ = q.AddPattern("p1", `{"a": ["x"]}`)
+			matches, _ := q.MatchesForEvent(event)
+			if want := []X{"p5", "p9", "p3", "p7", "p2", "p1"}; !slices.Equal(matches, want) {
+				t.Errorf("re-added: %v", matches)
+			}
+		}
+	}
+
+	if _, err := New(WithOrderedMatches(true), WithOrderedMatches(true)); err == nil {
+		t.Error("order specified twice")
+	}
+	if _, err := New(WithOrderedMatches(false), WithMatchOrder(compareStrings)); err == nil {
+		t.Error("both orders specified")
+	}
+	if _, err := New(WithMatchOrder(nil)); err == nil {
+		t.Error("nil order accepted")
+	}
+}
+
+func compareStrings(a, b X) int {
+	return strings.Compare(a.(string), b.(string))
+}
+
+func TestMatchOrder(t *testing.T) {
+	q, _ := New(WithMatchOrder(compareStrings), WithParallelFieldMatching(2, 1))
+	for _, x := range []string{"d", "b", "e", "a", "c"} {
+		_ = q.AddPattern(x, `{"a": ["x"]}`)
+	}
+	_ = q.AddPattern("f", `{"b": ["y"]}`)
+	want := []X{"a", "b", "c", "d", "e", "f"}
+	matches, err := q.MatchesForEvent([]byte(`{"a": "x", "b": "y"}`))
+	if err != nil || !slices.Equal(matches, want) {
+		t.Errorf("%v, %v", matches, err)
+	}
+	matches, err = q.MatchesForEventInto([]byte(`{"a": "x"}`), []X{"z"})
+	if err != nil || !slices.Equal(matches, []X{"z", "a", "b", "c", "d", "e"}) {
+		t.Errorf("into: %v, %v", matches, err)
+	}
+}
@@ -0,0 +1,58 @@
+package quamina
+
+import (
+	"runtime"
+	"time"
+)
+
+// MatchStats describes the work done by one MatchesForEventWithStats call, so that an Event which is slow to
+// match can be investigated, and reported, precisely.
+type MatchStats struct {
+	// Fields is the number of fields the Flattener extracted from the Event, which are those Patterns use
+	Fields int
+	// Values is the number of times a field's value was matched against the values in Patterns; fields are
+	// matched in combination, so this can be much larger than Fields
+	Values int
+	// DFATraversals and NFATraversals are the numbers of values run through automata. Running a value through
+	// a deterministic automaton visits one state for each byte, but a nondeterministic one may visit many, so
+	// NFATraversals are the usual cause of slow matching; they are avoided in BuiltForSpeed mode.
+	DFATraversals int
+	NFATraversals int
+	// States is the number of states the NFATraversals visited, as WithWorkLimit counts them
+	States int
+	// Allocations and BytesAllocated are the heap allocations made during the call; they include any made by
+	// other goroutines meanwhile
+	Allocations    uint64
+	BytesAllocated uint64
+	// Duration is how long the call took
+	Duration time.Duration
+}
+
+// MatchesForEventWithStats is like MatchesForEvent, but also reports the work it did. It's for debugging: it
+// reads the memory statistics of the Go runtime before and after matching, which briefly stops every
+// goroutine, so it's much slower than MatchesForEvent.
+func (q *Quamina) MatchesForEventWithStats(event []byte) ([]X, MatchStats, error) {
+	var stats MatchStats
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	q.bufs.resetWork()
+	q.bufs.stats = &stats
+	matches, err := q.MatchesForEvent(event)
+	q.bufs.stats = nil
+
+	stats.Duration = time.Since(start)
+	runtime.ReadMemStats(&after)
+	stats.States = q.bufs.work
+	stats.Allocations = after.Mallocs - before.Mallocs
+	stats.BytesAllocated = after.TotalAlloc - before.TotalAlloc
+	return matches, stats, err
+}
+
+// add adds the counts of the matching that helper goroutines did to s
+func (s *MatchStats) add(helper *MatchStats) {
+	s.Values += helper.Values
+	s.DFATraversals += helper.DFATraversals
+	s.NFATraversals += helper.NFATraversals
+}
@@ -0,0 +1,57 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatchesForEventWithStats(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		var opts []Option
+		if parallel {
+			opts = append(opts, WithParallelFieldMatching(2, 1))
+		}
+		q, _ := New(opts...)
+		_ = q.AddPattern("exact", `{"a": ["x"], "b": ["y"]}`)
+		_ = q.AddPattern("regexp", `{"c": [{"regexp": "(a|b)*b"}]}`)
+
+		matches, stats, err := q.MatchesForEventWithStats([]byte(`{"a": "x", "b": "y", "c": "abab", "d": "z"}`))
+		if err != nil || !containsExactly(matches, []X{"exact", "regexp"}) {
+			t.Fatalf("parallel=%v: %v, %v", parallel, matches, err)
+		}
+		if stats.Fields != 3 {
+			t.Errorf("parallel=%v: Fields %d", parallel, stats.Fields)
+		}
+		if stats.Values != 3 || stats.NFATraversals != 1 {
+			t.Errorf("parallel=%v: Values %d NFATraversals %d", parallel, stats.Values, stats.NFATraversals)
+		}
+		if stats.States == 0 || stats.Duration <= 0 {
+			t.Errorf("parallel=%v: %+v", parallel, stats)
+		}
+
+		// a long value means more work
+		long := []byte(fmt.Sprintf(`{"c": "%s"}`, strings.Repeat("ab", 100)))
+		_, longStats, _ := q.MatchesForEventWithStats(long)
+		if longStats.States <= stats.States {
+			t.Errorf("parallel=%v: long value visited %d states, short %d", parallel, longStats.States, stats.States)
+		}
+
+		// counting stops with the call
+		_, _ = q.MatchesForEvent(long)
+		if q.bufs.stats != nil {
+			t.Errorf("parallel=%v: stats still being counted", parallel)
+		}
+	}
+
+	q, _ := New(WithWorkLimit(10))
+	_ = q.AddPattern("regexp", `{"c": [{"regexp": "(a|b)*b"}]}`)
+	_, stats, err := q.MatchesForEventWithStats([]byte(`{"c": "abababababab"}`))
+	if !errors.Is(err, ErrWorkLimitExceeded) || stats.States <= 10 {
+		t.Errorf("work limit: %+v, %v", stats, err)
+	}
+	if _, stats, err = q.MatchesForEventWithStats([]byte(`{"c": `)); err == nil || stats.States != 0 {
+		t.Errorf("bad Event: %+v, %v", stats, err)
+	}
+}
@@ -3,15 +3,34 @@ package quamina
 type matcher interface {
 	addPattern(x X, pat string, mode MatcherBuildMode) error
 	matchesForFields(fields []Field, bufs *nfaBuffers) ([]X, error)
+	matchesForFieldsInto(fields []Field, bufs *nfaBuffers, dst []X) ([]X, error)
+	countForFields(fields []Field, bufs *nfaBuffers) (int, error)
 	deletePatterns(x X) error
 	getSegmentsTreeTracker() SegmentsTreeTracker
+	addFieldPaths(paths []string)
 	getStats() *matcherStats
+	automatonStats() AutomatonStats
+	fieldsReport() map[string]FieldReport
+	compact(minimize bool) (int, int)
+	memoryUsage() int64
+	setMemoryBudgets(budgets []*MemoryBudget)
+	setEventBridgeCompat()
+	setNumericTolerance(relative float64)
+	setCaseInsensitiveFieldNames()
+	setRepeatedFields(policy RepeatedFields)
+	setHMACKey(key []byte)
+	setCollation(key func(s []byte) []byte)
+	setLookups(lookups map[string]Lookup)
 }
 
 type matcherStats struct {
-	states     int64
-	bytes      int64
-	fanouts    int64
-	maxFanout  int64
-	seenStates map[*faState]bool
+	states    int64
+	bytes     int64
+	fanouts   int64
+	maxFanout int64
+	// valueMatchers is the number of fields with values to match, one for each fieldMatcher they're reached from
+	valueMatchers int64
+	// valueMatchers using the prefixMatcher rather than an automaton
+	prefixFastPaths int64
+	seenStates      map[*faState]bool
 }
@@ -0,0 +1,132 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrMemoryBudgetExceeded is returned, wrapped, by AddPattern when a MemoryBudget the instance was created with
+// has been used up.
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+// MemoryUsage reports the memory a Quamina instance is using, in bytes. Matcher is the memory consumed by the
+// automata and other structures built for the Patterns, which is shared by instances created with Copy;
+// Buffers is the memory held by this instance's matching buffers, which grow to fit the Events matched.
+// Both are counted with the same cost model as the "bytes" value of GetMatcherStats, which sizes each
+// structure from its contents rather than asking the Go runtime, so the numbers are the same on every run.
+type MemoryUsage struct {
+	Matcher int64
+	Buffers int64
+}
+
+// MemoryUsage returns the memory the instance is using. The Matcher figure is kept up to date as Patterns are
+// added, so unlike GetMatcherStats this doesn't have to traverse the automata, and is safe to call at any time.
+// Adding a Pattern to a field with an automaton builds new states to replace some of the existing ones, which
+// MatchesForEvent calls in progress may still be using, so the replaced states are still counted; Compact,
+// Minimize, and the rebuilds that follow DeletePatterns count afresh, after which the Matcher figure agrees
+// with GetMatcherStats.
+func (q *Quamina) MemoryUsage() MemoryUsage {
+	return MemoryUsage{Matcher: q.matcher.memoryUsage(), Buffers: mcNfaBuffers(q.bufs)}
+}
+
+// MemoryBudget limits the memory that the matchers of the Quamina instances using it may consume, as
+// measured by MemoryUsage. A budget may be shared by any number of instances, so that a service can give each
+// tenant a budget and also have one for all of them together; see WithMemoryBudget.
+type MemoryBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// NewMemoryBudget returns a MemoryBudget of limit bytes, which must be positive.
+func NewMemoryBudget(limit int64) (*MemoryBudget, error) {
+	if limit <= 0 {
+		return nil, errors.New("memory budget limit must be positive")
+	}
+	return &MemoryBudget{limit: limit}, nil
+}
+
+// Limit returns the number of bytes the budget allows.
+func (b *MemoryBudget) Limit() int64 {
+	return b.limit
+}
+
+// Used returns the number of bytes charged to the budget by the instances using it.
+func (b *MemoryBudget) Used() int64 {
+	return b.used.Load()
+}
+
+// WithMemoryBudget charges the memory consumed by the instance's matcher to each of the budgets, and arranges
+// for AddPattern to fail with ErrMemoryBudgetExceeded once any of them has been used up. Budgets are checked
+// before each Pattern is added, and charged with exactly what it consumed afterward, so a budget may be
+// exceeded by at most the last Pattern added. Memory released by Compact, Minimize, and the rebuilds that
+// follow DeletePatterns is given back to the budgets. Instances created with Copy share the matcher, and so the
+// budgets. This option call may not be provided more than once.
+func WithMemoryBudget(budgets ...*MemoryBudget) Option {
+	return func(q *Quamina) error {
+		if q.budgets != nil {
+			return errors.New("memory budget specified more than once")
+		}
+		if len(budgets) == 0 {
+			return errors.New("no memory budgets")
+		}
+		for _, budget := range budgets {
+			if budget == nil {
+				return errors.New("nil MemoryBudget")
+			}
+		}
+		q.budgets = budgets
+		return nil
+	}
+}
+
+// checkBudgets returns an error if any of the budgets is used up
+func checkBudgets(budgets []*MemoryBudget) error {
+	for _, budget := range budgets {
+		if used := budget.Used(); used >= budget.limit {
+			return fmt.Errorf("%w: %d of %d bytes used", ErrMemoryBudgetExceeded, used, budget.limit)
+		}
+	}
+	return nil
+}
+
+// chargeBudgets adds bytes, which is negative for memory given back, to each of the budgets
+func chargeBudgets(budgets []*MemoryBudget, bytes int64) {
+	for _, budget := range budgets {
+		budget.used.Add(bytes)
+	}
+}
+
+// charge adds bytes to the matcher's usage and its budgets
+func (m *coreMatcher) charge(bytes int64) {
+	if bytes != 0 {
+		m.usage.Add(bytes)
+		chargeBudgets(m.budgets, bytes)
+	}
+}
+
+func (m *coreMatcher) memoryUsage() int64 {
+	return m.usage.Load()
+}
+
+func (m *coreMatcher) setMemoryBudgets(budgets []*MemoryBudget) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.budgets = budgets
+	chargeBudgets(budgets, m.usage.Load())
+}
+
+// takeBudgets gives m, which is replacing old, old's budgets, which get back what old was charged and are
+// charged with what m uses
+func (m *coreMatcher) takeBudgets(old *coreMatcher) {
+	m.setMemoryBudgets(old.budgets)
+	chargeBudgets(old.budgets, -old.usage.Load())
+}
+
+// valueBytes returns the memory consumed by the valueMatcher for path, if there is one
+func (m *fieldMatcher) valueBytes(path string) int64 {
+	if vm, ok := m.fields().transitions[path]; ok {
+		return vm.fields().bytes
+	}
+	return 0
+}
@@ -0,0 +1,157 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestMemoryUsage checks the running total against what getStats finds by traversing the automata, for each
+// of the structures a valueMatcher can use. The total includes states that merging has replaced, until
+// Compact recounts.
+func TestMemoryUsage(t *testing.T) {
+	patterns := []string{
+		`{"a": ["x"]}`,
+		`{"a": ["y", "z"]}`,
+		`{"b": [{"prefix": "/api/"}]}`,
+		`{"b": [{"prefix": "/apx/"}, {"prefix": "/web"}]}`,
+		`{"c": [{"shellstyle": "*foo*"}]}`,
+		`{"c": [{"wildcard": "ba*r"}], "d": [1, 2.5]}`,
+		`{"a": [{"regexp": "x[a-c]+y"}]}`,
+		`{"b": ["/static"], "e": [{"anything-but": ["q"]}]}`,
+		`{"f": [{"equals-ignore-case": "Hello"}], "g": [{"exists": false}]}`,
+		`{"h": [{"shellstyle": "x*y*z"}]}`,
+	}
+	// without automata, nothing is replaced, so the two always agree
+	simple, _ := New()
+	for _, pattern := range []string{`{"a": ["x"]}`, `{"a": ["y"]}`, `{"b": [{"prefix": "z"}]}`, `{"c": [{"shellstyle": "*z*"}]}`} {
+		_ = simple.AddPattern(pattern, pattern)
+		if usage, stats := simple.MemoryUsage().Matcher, int64(simple.GetMatcherStats()["bytes"]); usage != stats {
+			t.Errorf("after %s, usage %d but stats %d", pattern, usage, stats)
+		}
+	}
+
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		q, _ := New()
+		_ = q.SetMatcherBuildMode(mode)
+		if usage := q.MemoryUsage(); usage.Matcher != 0 {
+			t.Errorf("empty matcher uses %d", usage.Matcher)
+		}
+		last := int64(0)
+		for _, pattern := range patterns {
+			if err := q.AddPattern(pattern, pattern); err != nil {
+				t.Fatal(err)
+			}
+			usage := q.MemoryUsage()
+			if stats := int64(q.GetMatcherStats()["bytes"]); usage.Matcher < stats {
+				t.Errorf("after %s, usage %d but stats %d", pattern, usage.Matcher, stats)
+			}
+			if usage.Matcher <= last {
+				t.Errorf("%s didn't add anything", pattern)
+			}
+			last = usage.Matcher
+		}
+		q.Compact()
+		if usage, stats := q.MemoryUsage().Matcher, int64(q.GetMatcherStats()["bytes"]); usage != stats || usage >= last {
+			t.Errorf("after Compact, usage %d, stats %d, was %d", usage, stats, last)
+		}
+
+		before := q.MemoryUsage().Buffers
+		if _, err := q.MatchesForEvent([]byte(`{"a": "xaby", "c": "afoo", "d": 2.5}`)); err != nil {
+			t.Fatal(err)
+		}
+		if after := q.MemoryUsage().Buffers; after <= before {
+			t.Errorf("buffers %d before matching, %d after", before, after)
+		}
+	}
+}
+
+func TestMemoryBudget(t *testing.T) {
+	tenant1, _ := NewMemoryBudget(2000)
+	tenant2, _ := NewMemoryBudget(1 << 30)
+	global, _ := NewMemoryBudget(1 << 30)
+	q1, err := New(WithMemoryBudget(tenant1, global))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q2, _ := New(WithMemoryBudget(tenant2, global))
+
+	var err1 error
+	added := 0
+	for i := 0; i < 1000 && err1 == nil; i++ {
+		pattern := fmt.Sprintf(`{"a": [{"regexp": "x%dy"}]}`, i)
+		if err1 = q1.AddPattern(i, pattern); err1 == nil {
+			added++
+		}
+		if err := q2.AddPattern(i, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !errors.Is(err1, ErrMemoryBudgetExceeded) {
+		t.Fatalf("tenant budget never ran out: %v", err1)
+	}
+	used1, used2 := q1.MemoryUsage().Matcher, q2.MemoryUsage().Matcher
+	if tenant1.Used() != used1 || tenant2.Used() != used2 || global.Used() != used1+used2 {
+		t.Errorf("budgets %d %d %d, usage %d %d", tenant1.Used(), tenant2.Used(), global.Used(), used1, used2)
+	}
+	if used1 < tenant1.Limit() || used1 > tenant1.Limit()+used1/int64(added) {
+		t.Errorf("%d used of %d after %d patterns", used1, tenant1.Limit(), added)
+	}
+
+	// the failed pattern wasn't added, Copy shares the budget, and compaction gives memory back
+	if matches, _ := q1.MatchesForEvent([]byte(fmt.Sprintf(`{"a": "x%dy"}`, added))); len(matches) != 0 {
+		t.Errorf("rejected pattern matched: %v", matches)
+	}
+	if err := q1.Copy().AddPattern("more", `{"b": ["c"]}`); !errors.Is(err, ErrMemoryBudgetExceeded) {
+		t.Errorf("Copy got around the budget: %v", err)
+	}
+	q2.Minimize()
+	if q2.MemoryUsage().Matcher >= used2 || tenant2.Used() != q2.MemoryUsage().Matcher {
+		t.Errorf("after Minimize, usage %d budget %d, was %d", q2.MemoryUsage().Matcher, tenant2.Used(), used2)
+	}
+	if global.Used() != used1+tenant2.Used() {
+		t.Errorf("global %d", global.Used())
+	}
+}
+
+func TestMemoryBudgetPruner(t *testing.T) {
+	budget, _ := NewMemoryBudget(1 << 30)
+	q, _ := New(WithPatternDeletion(true), WithMemoryBudget(budget))
+	for i := 0; i < 20; i++ {
+		if err := q.AddPattern(i, fmt.Sprintf(`{"a": [{"shellstyle": "*x%d*"}], "b": ["%d"]}`, i, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	full := q.MemoryUsage().Matcher
+	if budget.Used() != full {
+		t.Errorf("budget %d, usage %d", budget.Used(), full)
+	}
+	for i := 0; i < 15; i++ {
+		if err := q.DeletePatterns(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.matcher.(*prunerMatcher).rebuild(true); err != nil {
+		t.Fatal(err)
+	}
+	if pruned := q.MemoryUsage().Matcher; pruned >= full || budget.Used() != pruned {
+		t.Errorf("after rebuild, usage %d budget %d, was %d", pruned, budget.Used(), full)
+	}
+}
+
+func TestMemoryBudgetOptions(t *testing.T) {
+	if _, err := NewMemoryBudget(0); err == nil {
+		t.Error("zero limit accepted")
+	}
+	budget, _ := NewMemoryBudget(100)
+	bad := [][]Option{
+		{WithMemoryBudget()},
+		{WithMemoryBudget(nil)},
+		{WithMemoryBudget(budget), WithMemoryBudget(budget)},
+	}
+	for i, opts := range bad {
+		if _, err := New(opts...); err == nil {
+			t.Errorf("options %d accepted", i)
+		}
+	}
+}
@@ -1,12 +1,30 @@
 package quamina
 
 import (
+	"crypto/sha256"
 	"unsafe"
 )
 
 var mcPointer = int64(unsafe.Sizeof(&faState{}))
 var mcSmallTableBase = int64(unsafe.Sizeof(smallTable{})) // should include 3* slice descriptor
 var mcFaStateBase = int64(unsafe.Sizeof(faState{}))
+var mcAcNodeBase = int64(unsafe.Sizeof(acNode{}))
+var mcHorspool = int64(unsafe.Sizeof(horspool{}))
+var mcSubstringPattern = int64(unsafe.Sizeof(substringPattern{}))
+var mcPrefixNode = int64(unsafe.Sizeof(prefixNode{}))
+var mcNumericTest = int64(unsafe.Sizeof(numericTest{}))
+var mcCIDRTest = int64(unsafe.Sizeof(cidrTest{}))
+var mcTimestampTest = int64(unsafe.Sizeof(timestampTest{}))
+var mcDurationTest = int64(unsafe.Sizeof(durationTest{}))
+var mcPhoneticTest = int64(unsafe.Sizeof(phoneticTest{}))
+var mcLookupTest = int64(unsafe.Sizeof(lookupTest{}))
+
+// mcMapEntry is a rough figure for a map[string]*fieldMatcher entry: the key's string header and the
+// value pointer, plus a share of the bucket overhead
+var mcMapEntry = int64(unsafe.Sizeof("")) + 2*mcPointer
+
+// mcDigestEntry is the same figure for a rangeMatcher's digests
+var mcDigestEntry = int64(sha256.Size) + 2*mcPointer
 
 func (m *coreMatcher) getStats() *matcherStats {
 	stats := &matcherStats{
@@ -19,10 +37,34 @@ func (m *coreMatcher) getStats() *matcherStats {
 func cmFieldMatcherStats(fm *fieldMatcher, stats *matcherStats, pp printer) {
 	fmTrans := fm.fields().transitions
 	for _, vm := range fmTrans {
+		stats.valueMatchers++
 		singleton := vm.fields().singletonMatch
 		if singleton != nil {
 			stats.bytes += int64(cap(singleton))
 		}
+		if substrings := vm.fields().substrings; substrings != nil {
+			stats.bytes += substrings.size()
+			for _, pattern := range substrings.patterns {
+				cmFieldMatcherStats(pattern.next, stats, pp)
+			}
+		}
+		for exact, next := range vm.fields().exacts {
+			stats.bytes += mcMapEntry + int64(len(exact))
+			cmFieldMatcherStats(next, stats, pp)
+		}
+		if prefixes := vm.fields().prefixes; prefixes != nil {
+			stats.bytes += prefixes.size()
+			stats.prefixFastPaths++
+			prefixes.visit(func(next *fieldMatcher) {
+				cmFieldMatcherStats(next, stats, pp)
+			})
+		}
+		if ranges := vm.fields().ranges; ranges != nil {
+			stats.bytes += ranges.size()
+			ranges.visit(func(next *fieldMatcher) {
+				cmFieldMatcherStats(next, stats, pp)
+			})
+		}
 		start := vm.fields().start
 		if start == nil {
 			continue
@@ -77,3 +119,82 @@ func mcFaState(state *faState) int64 {
 	cost += mcPointer * int64(cap(state.epsilonClosure))
 	return cost
 }
+
+// account works out how much memory the vmFields' structures consume, by the same measure as getStats but
+// not including the fieldMatchers they lead to, so that coreMatcher can keep a running total as Patterns are
+// added. Walking the whole automaton each time would make adding Patterns quadratic, so automatonBytes
+// accumulates the states as they're built: each time start changes, the states that haven't been counted yet
+// are added, which, since merging builds new states along the paths it changes and reuses the rest, are the
+// ones it built. The states those replaced are still counted, since MatchesForEvent calls may be using them,
+// until compact rebuilds the automaton and starts the count over.
+func (f *vmFields) account() {
+	if f.start != nil && !f.start.counted {
+		f.automatonBytes += mcUncountedStates(f.start)
+	}
+	f.bytes = f.automatonBytes + int64(cap(f.singletonMatch))
+	if f.substrings != nil {
+		f.bytes += f.substrings.size()
+	}
+	for exact := range f.exacts {
+		f.bytes += mcMapEntry + int64(len(exact))
+	}
+	if f.prefixes != nil {
+		f.bytes += f.prefixes.size()
+	}
+	if f.ranges != nil {
+		f.bytes += f.ranges.size()
+	}
+}
+
+// mcUncountedStates adds up, and marks as counted, the states reachable from start that haven't been
+// counted, without leaving the automaton through a field transition
+func mcUncountedStates(start *faState) int64 {
+	var cost int64
+	start.counted = true
+	todo := []*faState{start}
+	visit := func(state *faState) {
+		if state != nil && !state.counted {
+			state.counted = true
+			todo = append(todo, state)
+		}
+	}
+	for len(todo) > 0 {
+		state := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		cost += mcFaState(state)
+		for _, step := range state.table.steps {
+			visit(step)
+		}
+		for _, eps := range state.table.epsilons {
+			visit(eps)
+		}
+	}
+	return cost
+}
+
+// mcNfaBuffers is the memory held by a set of matching buffers, including those of any parallel-matching
+// helpers. The maps are counted at mcMapEntry per entry.
+func mcNfaBuffers(nb *nfaBuffers) int64 {
+	cost := int64(unsafe.Sizeof(*nb))
+	cost += mcPointer * int64(cap(nb.buf1)+cap(nb.buf2))
+	cost += int64(unsafe.Sizeof(X(nil))) * int64(cap(nb.resultBuf))
+	cost += 4 * int64(cap(nb.literalMarks))
+	if nb.matches != nil {
+		cost += mcMapEntry * int64(len(nb.matches.set))
+	}
+	cost += mcMapEntry * int64(len(nb.fieldSet))
+	if nb.transmap != nil {
+		for _, level := range nb.transmap.levels {
+			cost += int64(unsafe.Sizeof(level)) + mcPointer*int64(cap(level))
+		}
+	}
+	if nb.qNums != nil {
+		cost += int64(unsafe.Sizeof(*nb.qNums))
+	}
+	if nb.parallel != nil {
+		for _, helper := range nb.parallel.helpers {
+			cost += mcNfaBuffers(helper)
+		}
+	}
+	return cost
+}
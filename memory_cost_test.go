@@ -32,8 +32,9 @@ func TestQuaminaMemoryCost(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	// "*z" is kept by the substringMatcher rather than in an automaton
 	bytes := q.GetMatcherStats()["bytes"]
-	if bytes != 1257 {
+	if bytes != 112 {
 		t.Error("WRONG NUMBERS")
 	}
 	err = q.AddPattern("x", `{"y":[{"wildcard": "*y"}]}}`)
@@ -41,7 +42,7 @@ func TestQuaminaMemoryCost(t *testing.T) {
 		t.Error(err)
 	}
 	bytes = q.GetMatcherStats()["bytes"]
-	if bytes != 2*1257 {
+	if bytes != 2*112 {
 		t.Error("WRONG NUMBERS")
 	}
 }
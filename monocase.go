@@ -1,12 +1,13 @@
 package quamina
 
 import (
-	"errors"
 	"fmt"
 	"unicode/utf8"
 )
 
-func readMonocaseSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+// readMonocaseSpecial reads an "equals-ignore-case", "prefix-equals-ignore-case", "suffix-equals-ignore-case", or
+// "contains-ignore-case" pattern, whose value is a string, into a value of the given type
+func readMonocaseSpecial(pb *patternBuild, valsIn []typedVal, patternType string, vType valType) (pathVals []typedVal, err error) {
 	t, err := pb.jd.Token()
 	if err != nil {
 		return
@@ -15,11 +16,11 @@ func readMonocaseSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedV
 
 	monocaseString, ok := t.(string)
 	if !ok {
-		err = errors.New("value for 'prefix' must be a string")
+		err = fmt.Errorf("value for '%s' must be a string", patternType)
 		return
 	}
 	val := typedVal{
-		vType: monocaseType,
+		vType: vType,
 		val:   `"` + monocaseString + `"`,
 	}
 	pathVals = append(pathVals, val)
@@ -41,6 +42,24 @@ func readMonocaseSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedV
 // through the bytes of each form and then rejoin to arrive at a state. Also note
 // that in many cases the upper/lower case versions of a rune have leading bytes in common
 func makeMonocaseFA(val []byte, pp printer) (*faState, *fieldMatcher) {
+	startState, nextStep, fm := makeMonocaseSteps(val, pp)
+	lastState := &faState{table: newSmallTable(), fieldTransitions: []*fieldMatcher{fm}}
+	nextStep.table.addByteStep(valueTerminator, lastState)
+	return startState, fm
+}
+
+// makeMonocasePrefixFA builds a FA to match "prefix-equals-ignore-case" patterns. As with makePrefixFA, the
+// closing quote isn't matched, and the state reached after the last character of the prefix is the one which
+// transitions to the next field, whatever follows.
+func makeMonocasePrefixFA(val []byte, pp printer) (*faState, *fieldMatcher) {
+	startState, nextStep, fm := makeMonocaseSteps(val[:len(val)-1], pp)
+	nextStep.fieldTransitions = []*fieldMatcher{fm}
+	return startState, fm
+}
+
+// makeMonocaseSteps builds the states which match val regardless of case, for makeMonocaseFA and
+// makeMonocasePrefixFA, returning the first and last of them, and the fieldMatcher they lead to
+func makeMonocaseSteps(val []byte, pp printer) (*faState, *faState, *fieldMatcher) {
 	fm := newFieldMatcher()
 	index := 0
 	startState := &faState{table: newSmallTable()} // start state
@@ -81,7 +100,88 @@ func makeMonocaseFA(val []byte, pp printer) (*faState, *fieldMatcher) {
 		currentTable = &nextStep.table
 		index += width
 	}
-	lastState := &faState{table: newSmallTable(), fieldTransitions: []*fieldMatcher{fm}}
-	nextStep.table.addByteStep(valueTerminator, lastState)
-	return startState, fm
+	return startState, nextStep, fm
+}
+
+// hasFoldedSuffix reports whether val ends with suffix, with the same case folding as makeMonocaseFA. This is
+// how "suffix-equals-ignore-case" patterns are matched; see substringMatcher.
+func hasFoldedSuffix(val, suffix []byte) bool {
+	for len(suffix) > 0 {
+		if len(val) == 0 {
+			return false
+		}
+		s, sWidth := utf8.DecodeLastRune(suffix)
+		r, rWidth := utf8.DecodeLastRune(val)
+		if r != s && caseFoldingPairs[s] != r {
+			return false
+		}
+		suffix, val = suffix[:len(suffix)-sWidth], val[:len(val)-rWidth]
+	}
+	return true
+}
+
+// lowerASCII returns b with its ASCII letters in lower case, and its other bytes as they are
+func lowerASCII(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		lower[i] = foldASCII(c)
+	}
+	return lower
 }
+
+// primeRK is the prime base of the Rabin-Karp hash that indexFoldedASCII uses, as in the standard library
+const primeRK = 16777619
+
+// indexFoldedASCII returns the position of the first occurrence in val of literal, whose ASCII letters are in
+// lower case, with the ASCII letters of val compared regardless of case, or -1. This is how "contains-ignore-case"
+// patterns are matched; see substringMatcher. Like bytes.Index on long literals, it uses a Rabin-Karp search, with
+// the hash computed over bytes folded to lower case, so its cost is linear in the length of val.
+func indexFoldedASCII(val, literal []byte) int {
+	n := len(literal)
+	if n > len(val) {
+		return -1
+	}
+	var want, pow uint32 = 0, 1
+	for _, c := range literal {
+		want = want*primeRK + uint32(c)
+		pow *= primeRK
+	}
+	var hash uint32
+	for i := 0; i < n; i++ {
+		hash = hash*primeRK + uint32(foldASCII(val[i]))
+	}
+	for i := n; ; i++ {
+		if hash == want && equalFoldedASCII(val[i-n:i], literal) {
+			return i - n
+		}
+		if i == len(val) {
+			return -1
+		}
+		hash = hash*primeRK + uint32(foldASCII(val[i])) - pow*uint32(foldASCII(val[i-n]))
+	}
+}
+
+// equalFoldedASCII reports whether b, with its ASCII letters folded to lower case, is equal to lower
+func equalFoldedASCII(b, lower []byte) bool {
+	for i, c := range b {
+		if foldASCII(c) != lower[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func foldASCII(c byte) byte {
+	return asciiFolds[c]
+}
+
+// asciiFolds maps each byte to itself, except for ASCII upper-case letters, which it maps to lower case
+var asciiFolds = func() (folds [256]byte) {
+	for i := range folds {
+		folds[i] = byte(i)
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		folds[c] = byte(c) + 'a' - 'A'
+	}
+	return
+}()
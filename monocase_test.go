@@ -110,3 +110,90 @@ func TestEqualsIgnoreCaseMatching(t *testing.T) {
 		t.Error("wrong on ABCXYZ")
 	}
 }
+
+func TestMonocaseAffixes(t *testing.T) {
+	patterns := map[X]string{
+		"host":  `{"host": [{"prefix-equals-ignore-case": "API."}]}`,
+		"über":  `{"host": [{"prefix-equals-ignore-case": "über"}]}`,
+		"jpg":   `{"path": [{"suffix-equals-ignore-case": ".jpg"}]}`,
+		"mixed": `{"path": [{"suffix-equals-ignore-case": "/Index.HTML"}, "/home", {"prefix": "/x"}]}`,
+		"long":  `{"path": [{"suffix-equals-ignore-case": "boſ"}]}`,
+		"empty": `{"path": [{"prefix-equals-ignore-case": ""}]}`,
+	}
+	events := map[string][]X{
+		`{"host": "api.example.com"}`:    {"host"},
+		`{"host": "Api.Example.com"}`:    {"host"},
+		`{"host": "xapi.example.com"}`:   {},
+		`{"host": "API"}`:                {},
+		`{"host": "ÜBERHOST"}`:           {"über"},
+		`{"host": 17}`:                   {},
+		`{"path": "/a/B.JPG"}`:           {"empty", "jpg"},
+		`{"path": "/a/b.jpgx"}`:          {"empty"},
+		`{"path": "/docs/index.html"}`:   {"empty", "mixed"},
+		`{"path": "/home"}`:              {"empty", "mixed"},
+		`{"path": "/xyz"}`:               {"empty", "mixed"},
+		`{"path": "BOs"}`:                {"empty", "long"},
+		`{"path": "os"}`:                 {"empty"},
+		`{"path": "jpg"}`:                {"empty"},
+		`{"path": ["b.Jpg", "/HOME"]}`:   {"empty", "jpg"},
+		`{"host": "api.", "path": "/x"}`: {"host", "empty", "mixed"},
+	}
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		cm := newCoreMatcher()
+		for x, p := range patterns {
+			if err := cm.addPattern(x, p, mode); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+		for event, want := range events {
+			matches, err := cm.matchesForJSONEvent([]byte(event))
+			if err != nil || !containsExactly(matches, want) {
+				t.Errorf("%s: %v, %v", event, matches, err)
+			}
+		}
+	}
+
+	// neither needs an NFA, and suffixes don't need an automaton at all
+	for _, val := range []typedVal{
+		{vType: monocasePrefixType, val: `"Foo"`},
+		{vType: monocaseSuffixType, val: `"Foo"`},
+	} {
+		vm := newValueMatcher()
+		_ = vm.addTransition(val, sharedNullPrinter, newClosureBuffers(), BuiltForComfort)
+		fields := vm.fields()
+		if fields.isNondeterministic || (val.vType == monocaseSuffixType && fields.start != nil) {
+			t.Errorf("%v %s built an NFA", val.vType, val.val)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"x": [{"prefix-equals-ignore-case": 3}]}`,
+		`{"x": [{"suffix-equals-ignore-case": ["a"]}]}`,
+		`{"x": [{"suffix-equals-ignore-case": "a", "b": 1}]}`,
+	} {
+		q, _ := New()
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestHasFoldedSuffix(t *testing.T) {
+	for _, test := range []struct {
+		val, suffix string
+		want        bool
+	}{
+		{"abc", "", true},
+		{"", "", true},
+		{"", "c", false},
+		{"ABC", "bc", true},
+		{"ABC", "xbc", false},
+		{"bc", "abc", false},
+		{"ÜBER", "über", true},
+		{"s", "ſ", true},
+	} {
+		if got := hasFoldedSuffix([]byte(test.val), []byte(test.suffix)); got != test.want {
+			t.Errorf("%q, %q: got %v", test.val, test.suffix, got)
+		}
+	}
+}
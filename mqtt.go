@@ -0,0 +1,83 @@
+package quamina
+
+import (
+	"errors"
+	"strings"
+)
+
+// readMQTTSpecial reads an "mqtt" pattern, whose value is an MQTT topic filter such as "sensors/+/temperature".
+// The filter is translated into a regexp, so it's compiled into the same automaton as the rest of the
+// Pattern's values.
+func readMQTTSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+	pathVals = valsIn
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	filter, ok := t.(string)
+	if !ok {
+		err = errors.New("value for 'mqtt' must be a string")
+		return
+	}
+	val := typedVal{vType: regexpType}
+	if val.parsedRegexp, err = mqttRegexp(filter); err != nil {
+		return
+	}
+	pathVals = append(pathVals, val)
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
+
+// mqttRegexp translates an MQTT topic filter into a regexp matching the topics it does. Topic levels are
+// separated by "/"; "+" matches any one level, and "#", which must be the last level, matches any number of
+// them, including none, so "a/#" matches "a". As the MQTT specification requires, a filter starting with a
+// wildcard doesn't match topics starting with "$", which brokers reserve for their own use.
+func mqttRegexp(filter string) (regexpRoot, error) {
+	if filter == "" {
+		return nil, errors.New("empty MQTT topic filter")
+	}
+	var re strings.Builder
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		switch {
+		case level == "#" && i != len(levels)-1:
+			return nil, errors.New("'#' must be the last level of an MQTT topic filter")
+		case level == "#" && i == 0:
+			re.WriteString("([^$].*)?")
+		case level == "#":
+			re.WriteString("(/.*)?")
+		case strings.ContainsAny(level, "+#") && level != "+":
+			return nil, errors.New("'+' and '#' must occupy a whole level of an MQTT topic filter")
+		default:
+			if i > 0 {
+				re.WriteByte('/')
+			}
+			if level == "+" && i == 0 {
+				re.WriteString("([^$/][^/]*)?")
+				continue
+			}
+			if level == "+" {
+				re.WriteString("[^/]*")
+				continue
+			}
+			writeRegexpLiteral(&re, level)
+		}
+	}
+	parse, err := readRegexp(re.String())
+	if err != nil {
+		return nil, err
+	}
+	return parse.tree, nil
+}
+
+// writeRegexpLiteral writes s to re as a regexp matching only s
+func writeRegexpLiteral(re *strings.Builder, s string) {
+	for _, r := range s {
+		if strings.ContainsRune(`()*+.?[\]{}|~`, r) {
+			re.WriteRune('~')
+		}
+		re.WriteRune(r)
+	}
+}
@@ -0,0 +1,72 @@
+package quamina
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMQTTMatching(t *testing.T) {
+	q, _ := New()
+	patterns := map[string]string{
+		"plus":     `{"topic": [{"mqtt": "sensors/+/temperature"}]}`,
+		"hash":     `{"topic": [{"mqtt": "devices/#"}]}`,
+		"all":      `{"topic": [{"mqtt": "#"}]}`,
+		"first":    `{"topic": [{"mqtt": "+/status"}]}`,
+		"literal":  `{"topic": [{"mqtt": "a.b/(c)"}, "x/y"]}`,
+		"combined": `{"topic": [{"mqtt": "sensors/+/temperature"}], "payload": {"unit": ["C"]}}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	events := map[string][]string{
+		`{"topic": "sensors/kitchen/temperature"}`:                           {"plus", "all"},
+		`{"topic": "sensors/kitchen/temperature", "payload": {"unit": "C"}}`: {"plus", "all", "combined"},
+		`{"topic": "sensors//temperature"}`:                                  {"plus", "all"},
+		`{"topic": "sensors/a/b/temperature"}`:                               {"all"},
+		`{"topic": "devices"}`:                                               {"hash", "all"},
+		`{"topic": "devices/"}`:                                              {"hash", "all"},
+		`{"topic": "devices/x/y"}`:                                           {"hash", "all"},
+		`{"topic": "devicesx"}`:                                              {"all"},
+		`{"topic": "pump/status"}`:                                           {"all", "first"},
+		`{"topic": "/status"}`:                                               {"all", "first"},
+		`{"topic": "$SYS/status"}`:                                           nil,
+		`{"topic": "a.b/(c)"}`:                                               {"all", "literal"},
+		`{"topic": "aXb/(c)"}`:                                               {"all"},
+		`{"topic": "x/y"}`:                                                   {"all", "literal"},
+	}
+	for event, want := range events {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.(string))
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: matched %v, want %v", event, got, want)
+		}
+	}
+}
+
+func TestMQTTErrors(t *testing.T) {
+	bad := map[string]string{
+		`{"topic": [{"mqtt": ""}]}`:          "empty",
+		`{"topic": [{"mqtt": "a/#/b"}]}`:     "last level",
+		`{"topic": [{"mqtt": "a/b#"}]}`:      "whole level",
+		`{"topic": [{"mqtt": "a+/b"}]}`:      "whole level",
+		`{"topic": [{"mqtt": ["a"]}]}`:       "must be a string",
+		`{"topic": [{"mqtt": "a", "x": 1}]}`: "",
+	}
+	for pattern, want := range bad {
+		_, err := patternFromJSON([]byte(pattern))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", pattern, err, want)
+		}
+	}
+}
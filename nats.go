@@ -0,0 +1,65 @@
+package quamina
+
+import (
+	"errors"
+	"strings"
+)
+
+// readNATSSpecial reads a "nats" pattern, whose value is a NATS subject with wildcards such as "orders.*.created".
+// Like an MQTT topic filter, it's translated into a regexp.
+func readNATSSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+	pathVals = valsIn
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	subject, ok := t.(string)
+	if !ok {
+		err = errors.New("value for 'nats' must be a string")
+		return
+	}
+	val := typedVal{vType: regexpType}
+	if val.parsedRegexp, err = natsRegexp(subject); err != nil {
+		return
+	}
+	pathVals = append(pathVals, val)
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
+
+// natsRegexp translates a NATS subject into a regexp matching the subjects it does. Tokens are separated by
+// "."; "*" matches any one token, and ">", which must be the last token, matches one or more of them, so
+// "a.>" matches "a.b" and "a.b.c" but not "a". Tokens may not be empty.
+func natsRegexp(subject string) (regexpRoot, error) {
+	if subject == "" {
+		return nil, errors.New("empty NATS subject")
+	}
+	var re strings.Builder
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		if i > 0 {
+			re.WriteString("~.")
+		}
+		switch {
+		case token == "":
+			return nil, errors.New("empty token in NATS subject")
+		case token == ">" && i != len(tokens)-1:
+			return nil, errors.New("'>' must be the last token of a NATS subject")
+		case token == ">":
+			re.WriteString(".+")
+		case token == "*":
+			re.WriteString("[^.]+")
+		case strings.ContainsAny(token, "*>"):
+			return nil, errors.New("'*' and '>' must be whole tokens of a NATS subject")
+		default:
+			writeRegexpLiteral(&re, token)
+		}
+	}
+	parse, err := readRegexp(re.String())
+	if err != nil {
+		return nil, err
+	}
+	return parse.tree, nil
+}
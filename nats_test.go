@@ -0,0 +1,65 @@
+package quamina
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNATSMatching(t *testing.T) {
+	q, _ := New()
+	patterns := map[string]string{
+		"star":     `{"subject": [{"nats": "orders.*.created"}]}`,
+		"tail":     `{"subject": [{"nats": "orders.>"}]}`,
+		"all":      `{"subject": [{"nats": ">"}]}`,
+		"literal":  `{"subject": [{"nats": "a+b.(c)"}]}`,
+		"combined": `{"subject": [{"nats": "orders.*.created"}], "data": {"total": [100]}}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	events := map[string][]string{
+		`{"subject": "orders.eu.created"}`:                         {"star", "tail", "all"},
+		`{"subject": "orders.eu.created", "data": {"total": 100}}`: {"star", "tail", "all", "combined"},
+		`{"subject": "orders..created"}`:                           {"tail", "all"},
+		`{"subject": "orders.eu.west.created"}`:                    {"tail", "all"},
+		`{"subject": "orders"}`:                                    {"all"},
+		`{"subject": "orders."}`:                                   {"all"},
+		`{"subject": "a+b.(c)"}`:                                   {"all", "literal"},
+		`{"subject": "aab.(c)"}`:                                   {"all"},
+		`{"subject": ""}`:                                          nil,
+	}
+	for event, want := range events {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.(string))
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: matched %v, want %v", event, got, want)
+		}
+	}
+}
+
+func TestNATSErrors(t *testing.T) {
+	bad := map[string]string{
+		`{"subject": [{"nats": ""}]}`:      "empty NATS subject",
+		`{"subject": [{"nats": "a..b"}]}`:  "empty token",
+		`{"subject": [{"nats": "a.>.b"}]}`: "last token",
+		`{"subject": [{"nats": "a.b*"}]}`:  "whole tokens",
+		`{"subject": [{"nats": 1}]}`:       "must be a string",
+	}
+	for pattern, want := range bad {
+		_, err := patternFromJSON([]byte(pattern))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", pattern, err, want)
+		}
+	}
+}
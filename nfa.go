@@ -2,6 +2,7 @@ package quamina
 
 import (
 	"fmt"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -9,7 +10,9 @@ import (
 
 // faState is used by the valueMatcher automaton - every step through the
 // automaton requires a smallTable and for some of them, taking the step means you've matched a value and can
-// transition to a new fieldMatcher, in which case the fieldTransitions slice will be non-nil
+// transition to a new fieldMatcher, in which case the fieldTransitions slice will be non-nil.
+// Matching only ever reads faStates; nothing learned while matching is cached in them, and all the
+// per-Event state lives in nfaBuffers, so one automaton can be shared by any number of goroutines.
 type faState struct {
 	table            smallTable
 	fieldTransitions []*fieldMatcher
@@ -21,6 +24,34 @@ type faState struct {
 	// len 1 is never stored: a {self} result collapses to the sentinel.
 	epsilonClosure []*faState
 	isSpinner      bool
+	// counted is set once the state's memory has been added to its valueMatcher's; see vmFields.account
+	counted bool
+	// id is a compact integer identity, see stateID. It sits after isSpinner and counted so that
+	// it occupies what would otherwise be padding and costs no steady-state memory.
+	id uint32
+}
+
+// lastStateID is the source of faState ids.
+var lastStateID atomic.Uint32
+
+// stateID returns the state's id, assigning the next one if this is the first time it's
+// been asked for. faStates are constructed all over the place with composite literals,
+// so the id can't be handed out in a constructor; instead it's assigned on first use,
+// which always happens in the build path, under the coreMatcher lock, and so the relative
+// order of a matcher's ids depends only on the sequence of AddPattern calls, not on where
+// the allocator happened to put things. That's what makes orderings based on ids, unlike those based on
+// pointers, repeatable from run to run. Matching never reads id, so assigning it doesn't
+// race with MatchesForEvent.
+// The ids are process-wide and, in a very long-lived process, could wrap around; that
+// would produce duplicate ids, which costs some DFA state sharing but not correctness,
+// because state identity is always checked by pointer. For the same reason, the seen-sets
+// used in building epsilon closures and walking automata stay keyed by pointer: a duplicate
+// id there would make a state look already visited, and lose it.
+func (s *faState) stateID() uint32 {
+	if s.id == 0 {
+		s.id = lastStateID.Add(1)
+	}
+	return s.id
 }
 
 /*
@@ -57,14 +88,16 @@ huge numbers of states and splices. So, we want to optimize merging.
 // Usage: push() in tryToMatch before calling transitionOn, pop() after
 // iterating the results. traverseNFA writes into levels[depth] directly.
 type transmap struct {
-	levels [][]*fieldMatcher
-	depth  int // -1 = idle
+	levels   [][]*fieldMatcher
+	depth    int // -1 = idle
+	capacity int // initial capacity of each level
 }
 
-func newTransMap() *transmap {
+func newTransMap(capacity int) *transmap {
 	return &transmap{
-		levels: [][]*fieldMatcher{make([]*fieldMatcher, 0, 16)},
-		depth:  -1,
+		levels:   [][]*fieldMatcher{make([]*fieldMatcher, 0, capacity)},
+		depth:    -1,
+		capacity: capacity,
 	}
 }
 
@@ -72,7 +105,7 @@ func newTransMap() *transmap {
 func (tm *transmap) push() {
 	tm.depth++
 	for tm.depth >= len(tm.levels) {
-		tm.levels = append(tm.levels, make([]*fieldMatcher, 0, 16))
+		tm.levels = append(tm.levels, make([]*fieldMatcher, 0, tm.capacity))
 	}
 	tm.levels[tm.depth] = tm.levels[tm.depth][:0]
 }
@@ -91,38 +124,85 @@ func (tm *transmap) resetDepth() {
 // nfaBuffers contains the buffers that are used to traverse NFAs. Go doesn't have thread-local variables
 // but Quamina does, because of the required quamina.Copy() function.  These will grow to accommodate
 // the incoming event patterns and matcher structures and eventually the amount of event-matching memory
-// allocation will be reduced to nearly zero.
+// allocation will be reduced to nearly zero. How big they start, how big they may stay, and whether
+// they're cleared between uses are set by BufferOptions.
 type nfaBuffers struct {
-	buf1, buf2     []*faState
-	matches        *matchSet
-	transitionsBuf []*fieldMatcher
-	resultBuf      []X
-	transmap       *transmap
-	fieldSet       map[*fieldMatcher]bool
-	qNumBuf        [MaxBytesInEncoding]byte
+	buf1, buf2   []*faState
+	matches      *matchSet
+	resultBuf    []X
+	transmap     *transmap
+	fieldSet     map[*fieldMatcher]bool
+	qNumBuf      [MaxBytesInEncoding]byte
+	qNums        *qNumCache
+	literalMarks []uint32
+	literalGen   uint32
+	opts         BufferOptions
+	parallel     *parallelMatching // nil unless WithParallelFieldMatching was used
+	// workLimit is the number of automaton states matching an Event may visit, from WithWorkLimit, and work
+	// the number it has; see addWork
+	workLimit    int
+	work         int
+	workExceeded bool
+	// maxFields is the number of fields an Event may have, from WithMaxFields, and tooManyFields the number
+	// the Event being matched has, if that's more
+	maxFields     int
+	tooManyFields int
+	// stats, if not nil, counts the work done for MatchesForEventWithStats
+	stats *MatchStats
+	// matchOrder, if not nil, is the order the matches are returned in
+	matchOrder *matchOrder
+	// counts, if not nil, is where the Patterns matched for each X are counted, for instances created
+	// WithMatchMultiplicity(true)
+	counts map[X]int
 }
 
+// defaultBufferOptions are used unless the WithBufferOptions option says otherwise
+var defaultBufferOptions = BufferOptions{ScratchCapacity: 16, ResultCapacity: 16}
+
 func newNfaBuffers() *nfaBuffers {
+	return newNfaBuffersWith(defaultBufferOptions)
+}
+
+func newNfaBuffersWith(opts BufferOptions) *nfaBuffers {
 	return &nfaBuffers{
-		transitionsBuf: make([]*fieldMatcher, 0, 16),
-		resultBuf:      make([]X, 0, 16),
+		resultBuf: make([]X, 0, opts.ResultCapacity),
+		opts:      opts,
 	}
 }
 
 func (nb *nfaBuffers) getBuf1() []*faState {
 	if nb.buf1 == nil {
-		nb.buf1 = make([]*faState, 0, 16)
+		nb.buf1 = make([]*faState, 0, nb.opts.ScratchCapacity)
 	}
 	return nb.buf1
 }
 
 func (nb *nfaBuffers) getBuf2() []*faState {
 	if nb.buf2 == nil {
-		nb.buf2 = make([]*faState, 0, 16)
+		nb.buf2 = make([]*faState, 0, nb.opts.ScratchCapacity)
 	}
 	return nb.buf2
 }
 
+// qNumFor makes a Q number from a numeric event value, consulting and updating the cache of recent ones
+func (nb *nfaBuffers) qNumFor(val []byte) (qNumber, error) {
+	if nb.qNums == nil {
+		nb.qNums = &qNumCache{}
+	}
+	return nb.qNums.qNumFor(val, &nb.qNumBuf)
+}
+
+// getResultBuf returns the buffer the previous call's matches were returned in, emptied
+func (nb *nfaBuffers) getResultBuf() []X {
+	if nb.resultBuf == nil {
+		nb.resultBuf = make([]X, 0, nb.opts.ResultCapacity)
+	}
+	if nb.opts.ClearOnReturn {
+		clear(nb.resultBuf[:cap(nb.resultBuf)])
+	}
+	return nb.resultBuf[:0]
+}
+
 func (nb *nfaBuffers) getMatches() *matchSet {
 	if nb.matches == nil {
 		nb.matches = newMatchSet()
@@ -132,11 +212,25 @@ func (nb *nfaBuffers) getMatches() *matchSet {
 
 func (nb *nfaBuffers) getTransmap() *transmap {
 	if nb.transmap == nil {
-		nb.transmap = newTransMap()
+		nb.transmap = newTransMap(nb.opts.ScratchCapacity)
 	}
 	return nb.transmap
 }
 
+// getLiteralMarks returns a slice with at least n entries and a generation number; an entry equal to
+// the generation means "already seen in this scan". Bumping the generation clears the marks in O(1).
+func (nb *nfaBuffers) getLiteralMarks(n int) ([]uint32, uint32) {
+	if len(nb.literalMarks) < n {
+		nb.literalMarks = make([]uint32, n)
+	}
+	nb.literalGen++
+	if nb.literalGen == 0 {
+		clear(nb.literalMarks)
+		nb.literalGen = 1
+	}
+	return nb.literalMarks, nb.literalGen
+}
+
 func (nb *nfaBuffers) getFieldSet() map[*fieldMatcher]bool {
 	if nb.fieldSet == nil {
 		nb.fieldSet = make(map[*fieldMatcher]bool)
@@ -144,6 +238,59 @@ func (nb *nfaBuffers) getFieldSet() map[*fieldMatcher]bool {
 	return nb.fieldSet
 }
 
+// release is called when a MatchesForEvent call is finished with the buffers; it applies the
+// MaxRetainedCapacity and ClearOnReturn options. resultBuf isn't cleared until the next call,
+// by getResultBuf, because the caller may still be using the matches in it.
+func (nb *nfaBuffers) release() {
+	if nb.parallel != nil {
+		for _, helper := range nb.parallel.helpers {
+			helper.release()
+		}
+	}
+	if limit := nb.opts.MaxRetainedCapacity; limit > 0 {
+		if cap(nb.buf1) > limit {
+			nb.buf1 = nil
+		}
+		if cap(nb.buf2) > limit {
+			nb.buf2 = nil
+		}
+		if cap(nb.resultBuf) > limit {
+			nb.resultBuf = nil
+		}
+		if cap(nb.literalMarks) > limit {
+			nb.literalMarks = nil
+		}
+		if nb.transmap != nil {
+			for i, level := range nb.transmap.levels {
+				if cap(level) > limit {
+					nb.transmap.levels[i] = make([]*fieldMatcher, 0, nb.opts.ScratchCapacity)
+				}
+			}
+		}
+		// maps never shrink, so one that has held more than the limit is replaced
+		if nb.matches != nil && len(nb.matches.set) > limit {
+			nb.matches = nil
+		}
+		if len(nb.fieldSet) > limit {
+			nb.fieldSet = nil
+		}
+	}
+
+	if nb.opts.ClearOnReturn {
+		clear(nb.buf1[:cap(nb.buf1)])
+		clear(nb.buf2[:cap(nb.buf2)])
+		if nb.transmap != nil {
+			for _, level := range nb.transmap.levels {
+				clear(level[:cap(level)])
+			}
+		}
+		if nb.matches != nil {
+			nb.matches.reset()
+		}
+		clear(nb.fieldSet)
+	}
+}
+
 // nfa2Dfa does what the name says. It relies upon epsilonClosure having been run on the start state
 func nfa2Dfa(nfaStart *faState) *faState {
 	// The start state always has a trivial epsilon closure (just itself), so we
@@ -263,6 +410,10 @@ func traverseDFA(start *faState, val []byte, transitions []*fieldMatcher) []*fie
 // currentStates, nextStates, and the epsilon closure of one particular state. These are re-used
 // and should grow with use and minimize the need for memory allocation.
 func traverseNFA(start *faState, val []byte, transitions []*fieldMatcher, bufs *nfaBuffers) []*fieldMatcher {
+	// once the work limit is exceeded, the Event's matching is abandoned
+	if bufs.workExceeded {
+		return nil
+	}
 	currentStates := bufs.getBuf1()
 	// The start state always has a trivial epsilon closure (just itself), so we
 	// can seed currentStates with it directly. Epsilon transitions (spinner
@@ -290,9 +441,11 @@ func traverseNFA(start *faState, val []byte, transitions []*fieldMatcher, bufs *
 		} else {
 			utf8Byte = valueTerminator
 		}
+		visited := 0
 		for _, state := range currentStates {
 			if len(state.epsilonClosure) == 0 {
 				// self-only closure: process the state itself
+				visited++
 				for _, fm := range state.fieldTransitions {
 					fieldSet[fm] = true
 				}
@@ -301,6 +454,7 @@ func traverseNFA(start *faState, val []byte, transitions []*fieldMatcher, bufs *
 				}
 				continue
 			}
+			visited += len(state.epsilonClosure)
 			for _, ecState := range state.epsilonClosure {
 				for _, fm := range ecState.fieldTransitions {
 					fieldSet[fm] = true
@@ -315,6 +469,10 @@ func traverseNFA(start *faState, val []byte, transitions []*fieldMatcher, bufs *
 		swapStates := currentStates
 		currentStates = nextStates
 		nextStates = swapStates[:0]
+		if !bufs.addWork(visited) {
+			currentStates = currentStates[:0]
+			break
+		}
 	}
 
 	// we've run out of input bytes so we need to check the current states and their
@@ -536,13 +694,15 @@ func asymmetricSpinnerMerge(spinner, nonSpinner *faState, keyMemo map[faStepKey]
 		case spinnerNext == spinner:
 			// nonspinner has a branch here
 			// if the current spinner value is a loopback, we need to make a new state whose value
-			// is the nonspinner with the addition of the epsilon link back to the spinner
+			// is the nonspinner with the addition of the epsilon link back to the spinner. The nonspinner
+			// keeps its own field transitions, which is where a prefix match ends; the spinner's are
+			// reached through the epsilon
 			mergedTable := smallTable{
 				steps:    nonSpinnernext.table.steps,
 				ceilings: nonSpinnernext.table.ceilings,
 				epsilons: append(nonSpinnernext.table.epsilons, spinner),
 			}
-			mergedState = &faState{table: mergedTable, fieldTransitions: spinner.fieldTransitions}
+			mergedState = &faState{table: mergedTable, fieldTransitions: nonSpinnernext.fieldTransitions}
 
 		default:
 			// if spinner's branch isn't a loopback, we need to merge its target with the nonspinner
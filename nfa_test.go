@@ -2,6 +2,7 @@ package quamina
 
 import (
 	"fmt"
+	"maps"
 	"strings"
 	"testing"
 	"unsafe"
@@ -413,7 +414,7 @@ func TestTransmapBufferReuse(t *testing.T) {
 	fm2 := &fieldMatcher{}
 	fm3 := &fieldMatcher{}
 
-	tm := newTransMap()
+	tm := newTransMap(16)
 	tm.resetDepth()
 
 	// Simulate outer tryToMatch: push, then traverseNFA writes into levels[depth]
@@ -509,7 +510,7 @@ func collectClosureStats(start *faState) (stateCount, totalEntries, maxClosure i
 // dedupWorkload defines a set of patterns for testing table-pointer dedup.
 type dedupWorkload struct {
 	name         string
-	patterns     []string // shellstyle patterns
+	patterns     []string // shellstyle patterns, merged into the NFA; see buildDedupMatcher
 	regexps      []string // regexp patterns
 	stateCount   int      // expected NFA state count
 	totalEntries int      // total explicit closure entries; self-only states contribute 0 (sentinel)
@@ -611,12 +612,36 @@ func buildDedupMatcher(tb testing.TB, wl dedupWorkload) *Quamina {
 	tb.Helper()
 	q, _ := New()
 	i := 0
-	for _, ss := range wl.patterns {
-		pattern := fmt.Sprintf(`{"val": [{"shellstyle": "%s"}]}`, ss)
-		if err := q.AddPattern(fmt.Sprintf("s%d", i), pattern); err != nil {
+	if len(wl.patterns) > 0 {
+		// AddPattern would give shellstyle patterns like these to the substringMatcher, so to exercise the
+		// dedup of the closures their spinners produce, their automata are merged into the field's NFA here,
+		// as valueMatcher.addTransition does for values it can't match any other way
+		if err := q.AddFieldPaths("val"); err != nil {
 			tb.Fatal(err)
 		}
-		i++
+		root := q.matcher.(*coreMatcher).fields().state
+		rootFields := root.fields()
+		vm := newValueMatcher()
+		transitions := maps.Clone(rootFields.transitions)
+		transitions["val"] = vm
+		root.update(&fmFields{transitions: transitions, existsTrue: rootFields.existsTrue, existsFalse: rootFields.existsFalse})
+
+		bufs := newClosureBuffers()
+		for _, ss := range wl.patterns {
+			fields := vm.getFieldsForUpdate()
+			fa, next := makeShellStyleFA([]byte(`"`+ss+`"`), sharedNullPrinter)
+			if fields.start == nil {
+				fields.start = fa
+			} else {
+				fields.start = mergeStartStates(fields.start, fa, sharedNullPrinter)
+			}
+			fields.isNondeterministic = true
+			bufs.reset()
+			epsilonClosureInto(fields.start, bufs)
+			vm.update(fields)
+			next.addMatch(fmt.Sprintf("s%d", i))
+			i++
+		}
 	}
 	for _, re := range wl.regexps {
 		pattern := fmt.Sprintf(`{"val": [{"regexp": "%s"}]}`, re)
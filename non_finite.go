@@ -0,0 +1,82 @@
+package quamina
+
+import (
+	"errors"
+)
+
+// NonFiniteNumbers says what the JSON Flattener does with the tokens NaN, Infinity, and -Infinity, which
+// aren't JSON, but which some producers, such as Python's json module, emit for numbers that aren't finite.
+type NonFiniteNumbers int
+
+const (
+	// NonFiniteError makes flattening an Event containing one of the tokens fail, as for any other JSON error.
+	NonFiniteError NonFiniteNumbers = iota
+	// NonFiniteAsStrings matches the tokens as though they were the strings "NaN", "Infinity", and
+	// "-Infinity", so that Patterns such as {"x": ["NaN"]} can pick them out.
+	NonFiniteAsStrings
+	// NonFiniteAsNumbers matches the tokens as numbers: Infinity and -Infinity fall in the numeric ranges
+	// that are unbounded above and below respectively, NaN falls in none, and none of them is equal to any
+	// number in a Pattern.
+	NonFiniteAsNumbers
+)
+
+// WithNonFiniteNumbers sets what the JSON Flattener does with the NaN, Infinity, and -Infinity tokens in an
+// Event; by default, NonFiniteError, they're errors. The policy applies to instances created with Copy. It needs
+// the JSON Flattener, so New fails if WithFlattener provides another. This option call may not be provided more
+// than once.
+func WithNonFiniteNumbers(policy NonFiniteNumbers) Option {
+	return func(q *Quamina) error {
+		if q.nonFiniteSpecified {
+			return errors.New("non-finite number policy specified more than once")
+		}
+		if policy != NonFiniteError && policy != NonFiniteAsStrings && policy != NonFiniteAsNumbers {
+			return errors.New("unknown non-finite number policy")
+		}
+		q.nonFiniteSpecified = true
+		q.nonFinite = policy
+		return nil
+	}
+}
+
+// the non-finite tokens, and the strings they're matched as with NonFiniteAsStrings
+var (
+	nanBytes               = []byte("NaN")
+	infinityBytes          = []byte("Infinity")
+	negInfinityBytes       = []byte("-Infinity")
+	nanStringBytes         = []byte(`"NaN"`)
+	infinityStringBytes    = []byte(`"Infinity"`)
+	negInfinityStringBytes = []byte(`"-Infinity"`)
+)
+
+// readNumberValue reads a number or, if the Flattener accepts them, a non-finite token, reporting whether
+// the value is to be matched as a number
+func (fj *flattenJSON) readNumberValue() ([]byte, bool, error) {
+	if fj.nonFinite != NonFiniteError {
+		token, asString := fj.nonFiniteToken()
+		if token != nil {
+			val, err := fj.readLiteral(token)
+			if err != nil || fj.nonFinite == NonFiniteAsNumbers {
+				return val, err == nil, err
+			}
+			return asString, false, nil
+		}
+	}
+	val, err := fj.readNumber()
+	return val, err == nil, err
+}
+
+// nonFiniteToken returns the non-finite token the Event has at the current position, if it has one, and
+// the string it's matched as with NonFiniteAsStrings
+func (fj *flattenJSON) nonFiniteToken() ([]byte, []byte) {
+	switch fj.ch() {
+	case 'N':
+		return nanBytes, nanStringBytes
+	case 'I':
+		return infinityBytes, infinityStringBytes
+	case '-':
+		if fj.eventIndex+1 < len(fj.event) && fj.event[fj.eventIndex+1] == 'I' {
+			return negInfinityBytes, negInfinityStringBytes
+		}
+	}
+	return nil, nil
+}
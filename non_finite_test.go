@@ -0,0 +1,77 @@
+package quamina
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNonFiniteNumbers(t *testing.T) {
+	patterns := map[X]string{
+		"nan-string": `{"x": ["NaN"]}`,
+		"inf-string": `{"x": ["Infinity"]}`,
+		"big":        `{"x": [{"numeric": [">", 1e300]}]}`,
+		"small":      `{"x": [{"numeric": ["<", -1e300]}]}`,
+		"any":        `{"x": [{"numeric": [">", -1e308, "<", 1e308]}]}`,
+		"not-five":   `{"x": [{"anything-but": [5]}]}`,
+	}
+	events := []string{"NaN", "Infinity", "-Infinity"}
+	wants := map[NonFiniteNumbers][][]X{
+		NonFiniteAsStrings: {{"nan-string", "not-five"}, {"inf-string", "not-five"}, {"not-five"}},
+		NonFiniteAsNumbers: {{"not-five"}, {"big", "not-five"}, {"small", "not-five"}},
+	}
+	for policy, want := range wants {
+		q, err := New(WithNonFiniteNumbers(policy), WithEventBridgeCompat())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for x, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, instance := range []*Quamina{q, q.Copy()} {
+			for i, event := range events {
+				for _, e := range []string{`{"x": %s}`, `{"x": [%s]}`} {
+					matches, err := instance.MatchesForEvent([]byte(fmt.Sprintf(e, event)))
+					if err != nil || !containsExactly(matches, want[i]) {
+						t.Errorf("policy %d, %s: %v, %v", policy, fmt.Sprintf(e, event), matches, err)
+					}
+				}
+			}
+		}
+		// the tokens aren't prefixes of anything else
+		if _, err := q.MatchesForEvent([]byte(`{"x": Inf}`)); err == nil {
+			t.Errorf("policy %d: Inf accepted", policy)
+		}
+	}
+
+	q, _ := New()
+	_ = q.AddPattern("x", `{"x": [{"exists": true}]}`)
+	for _, event := range events {
+		if _, err := q.MatchesForEvent([]byte(fmt.Sprintf(`{"x": %s}`, event))); err == nil {
+			t.Errorf("%s accepted by default", event)
+		}
+	}
+
+	if _, err := New(WithNonFiniteNumbers(NonFiniteAsNumbers), WithFlattener(&labelRecordingFlattener{})); err == nil {
+		t.Error("non-JSON Flattener accepted")
+	}
+	if _, err := New(WithNonFiniteNumbers(NonFiniteError), WithNonFiniteNumbers(NonFiniteError)); err == nil {
+		t.Error("policy specified twice")
+	}
+	if _, err := New(WithNonFiniteNumbers(NonFiniteNumbers(7))); err == nil {
+		t.Error("unknown policy accepted")
+	}
+}
+
+func TestNegativeZero(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("zero", `{"x": [0]}`)
+	_ = q.AddPattern("negative-zero", `{"y": [-0.0]}`)
+	for _, event := range []string{`{"x": -0}`, `{"x": -0.0e5}`, `{"x": 0.0}`, `{"y": 0}`, `{"y": -0}`} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || len(matches) != 1 {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+}
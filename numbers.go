@@ -24,13 +24,48 @@ import (
 
 type qNumber []byte
 
+// errNumberOutOfRange is reported for numbers like 1e400, too big in magnitude to be a float64, and like
+// 1e-400, too small to be told apart from zero.
+var errNumberOutOfRange = errors.New("number out of range")
+
+// parseNumber parses a number whose form has already been validated, e.g. by flattenJSON(). strconv.ParseFloat
+// reports numbers too big for a float64 but quietly rounds those too small to zero; both are reported here,
+// with the value ParseFloat gives them, so that they can't be mistaken for the infinities or zero.
+func parseNumber(bytes []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(bytes), 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return f, errNumberOutOfRange
+		}
+		return f, err
+	}
+	if f == 0 && hasNonzeroDigit(bytes) {
+		return f, errNumberOutOfRange
+	}
+	return f, nil
+}
+
+// hasNonzeroDigit reports whether the significand of a number, the part before any exponent, isn't zero
+func hasNonzeroDigit(bytes []byte) bool {
+	for _, b := range bytes {
+		switch {
+		case b == 'e' || b == 'E':
+			return false
+		case b >= '1' && b <= '9':
+			return true
+		}
+	}
+	return false
+}
+
 // qNumFromBytes works out whether a string representing a number falls within the
 // limits imposed for Q numbers. It is heavily optimized and relies on  the form
-// of the number already having been validated, e.g. by flattenJSON().
+// of the number already having been validated, e.g. by flattenJSON(). Numbers out of
+// the float64 range can't be Q numbers, so they're compared as strings.
 func qNumFromBytes(bytes []byte) (qNumber, error) {
-	numeric, err := strconv.ParseFloat(string(bytes), 64)
+	numeric, err := parseNumber(bytes)
 	if err != nil {
-		return nil, errors.New("not a float") // should never happen, json parser upstream
+		return nil, err
 	}
 	return qNumFromFloat(numeric), nil
 }
@@ -42,13 +77,59 @@ func qNumFromFloat(f float64) qNumber {
 
 // qNumFromBytesBuf is like qNumFromBytes but writes to the provided buffer to avoid allocation.
 func qNumFromBytesBuf(bytes []byte, buf *[MaxBytesInEncoding]byte) (qNumber, error) {
-	numeric, err := strconv.ParseFloat(string(bytes), 64)
+	numeric, err := parseNumber(bytes)
 	if err != nil {
-		return nil, errors.New("not a float")
+		return nil, err
 	}
 	return numbitsFromFloat64(numeric).toQNumberBuf(buf), nil
 }
 
+// qNumCache remembers the Q numbers for recently-seen numeric values. Event streams tend to repeat the
+// same numbers over and over (status codes, ports, small counts), and a lookup here is several times
+// cheaper than parsing and re-encoding. It's direct-mapped: each value has one possible slot, and a new
+// value simply evicts whatever was there. One lives in each nfaBuffers, so there's no sharing between
+// goroutines to worry about.
+type qNumCache struct {
+	entries [qNumCacheSize]qNumCacheEntry
+}
+
+type qNumCacheEntry struct {
+	raw    [qNumCacheMaxRaw]byte
+	rawLen uint8 // 0 means the slot is empty, since numbers can't be
+	qLen   uint8
+	qNum   [MaxBytesInEncoding]byte
+}
+
+const (
+	qNumCacheSize   = 64 // must be a power of 2
+	qNumCacheMaxRaw = 22 // longer numbers are rare enough not to be worth caching
+)
+
+// qNumFor returns the Q number for the textual number in raw. The result is only good until the next call.
+func (c *qNumCache) qNumFor(raw []byte, buf *[MaxBytesInEncoding]byte) (qNumber, error) {
+	if len(raw) == 0 || len(raw) > qNumCacheMaxRaw {
+		return qNumFromBytesBuf(raw, buf)
+	}
+	h := fnvOffset64
+	for _, b := range raw {
+		h ^= uint64(b)
+		h *= fnvPrime64
+	}
+	entry := &c.entries[h&(qNumCacheSize-1)]
+	if int(entry.rawLen) == len(raw) && string(entry.raw[:len(raw)]) == string(raw) {
+		return entry.qNum[:entry.qLen], nil
+	}
+
+	qNum, err := qNumFromBytesBuf(raw, buf)
+	if err != nil {
+		return nil, err
+	}
+	copy(entry.raw[:], raw)
+	entry.rawLen = uint8(len(raw))                //nolint:gosec // at most qNumCacheMaxRaw
+	entry.qLen = uint8(copy(entry.qNum[:], qNum)) //nolint:gosec // at most MaxBytesInEncoding
+	return entry.qNum[:entry.qLen], nil
+}
+
 /*
 // for debugging
 func (q qNumber) String() string {
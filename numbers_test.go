@@ -2,6 +2,7 @@ package quamina
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -207,3 +208,90 @@ func TestMatcherNumerics(t *testing.T) {
 		}
 	}
 }
+
+func TestQNumCache(t *testing.T) {
+	var cache qNumCache
+	var buf [MaxBytesInEncoding]byte
+	rng := rand.New(rand.NewSource(133))
+	values := []string{"0", "-0", "200", "404", "8080", "1e3", "1000", "3.14159", "-2.5e-7", "123456789012345678901234567890"}
+	for i := 0; i < 200; i++ {
+		values = append(values, strconv.Itoa(rng.Intn(300)))
+	}
+	// twice through, so that there are hits, misses, and evictions
+	for round := 0; round < 2; round++ {
+		for _, value := range values {
+			wanted, _ := qNumFromBytes([]byte(value))
+			got, err := cache.qNumFor([]byte(value), &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, wanted) {
+				t.Errorf("%s: got %v wanted %v", value, got, wanted)
+			}
+		}
+	}
+	if _, err := cache.qNumFor([]byte("x"), &buf); err == nil {
+		t.Error("accepted non-number")
+	}
+}
+
+func BenchmarkQNumCache(b *testing.B) {
+	values := [][]byte{[]byte("200"), []byte("404"), []byte("443"), []byte("8080"), []byte("0.5")}
+	var buf [MaxBytesInEncoding]byte
+	b.Run("parse", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = qNumFromBytesBuf(values[i%len(values)], &buf)
+		}
+	})
+	b.Run("cached", func(b *testing.B) {
+		var cache qNumCache
+		for i := 0; i < b.N; i++ {
+			_, _ = cache.qNumFor(values[i%len(values)], &buf)
+		}
+	})
+}
+
+func TestOutOfRangeNumbers(t *testing.T) {
+	for _, s := range []string{"1e400", "-1e400", "1e-400", "-1.5e-400", "0.0000e-400", "0", "-0.0", "1e308", "5e-324"} {
+		_, err := parseNumber([]byte(s))
+		out := s == "1e400" || s == "-1e400" || s == "1e-400" || s == "-1.5e-400"
+		if out != errors.Is(err, errNumberOutOfRange) {
+			t.Errorf("%s: %v", s, err)
+		}
+	}
+
+	q, _ := New(WithEventBridgeCompat())
+	patterns := map[X]string{
+		"big":      `{"x": [{"numeric": [">", 1e300]}]}`,
+		"positive": `{"x": [{"numeric": [">", 0]}]}`,
+		"negative": `{"x": [{"numeric": ["<", 0]}]}`,
+		"tiny":     `{"x": [{"numeric": [">", -1e-300, "<", 1e-300]}]}`,
+		"zero":     `{"x": [0]}`,
+		"exact":    `{"x": [1e400]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for event, want := range map[string][]X{
+		"1e400":   {"big", "positive", "exact"},
+		"10e399":  {"big", "positive"},
+		"-1e400":  {"negative"},
+		"1e-400":  {"positive", "tiny"},
+		"-1e-400": {"negative", "tiny"},
+		"0e-400":  {"tiny", "zero"},
+		"1e-300":  {"positive"},
+	} {
+		matches, err := q.MatchesForEvent([]byte(fmt.Sprintf(`{"x": %s}`, event)))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+
+	for _, bound := range []string{"1e400", "1e-400"} {
+		if err := q.AddPattern("bad", fmt.Sprintf(`{"x": [{"numeric": [">", %s]}]}`, bound)); err == nil {
+			t.Errorf("numeric bound %s accepted", bound)
+		}
+	}
+}
@@ -13,14 +13,17 @@ import (
 // numbits were implemented by Arne Hormann for Quamina; he later discovered
 // that an equivalent representation was used long ago in the disk format of DB2.
 //
-// Arne's implementation carefully handled NaN, -0, and infinities, but
-// Quamina ignores those issues because a combination of JSON rules and
-// Quamina's parsers prevent those values from occurring.
+// Arne's implementation carefully handled NaN, -0, and infinities. Quamina
+// makes -0 equal to 0, and otherwise ignores those issues because JSON rules
+// and Quamina's parsers prevent those values from occurring, unless an instance
+// is created WithNonFiniteNumbers(NonFiniteAsNumbers), and then they're only
+// compared with numbers that are finite.
 type numbits uint64
 
 // numbitsFromFloat64 converts a float64 value to its numbits representation.
 func numbitsFromFloat64(f float64) numbits {
-	u := math.Float64bits(f)
+	// adding zero turns -0 into 0, so that they're equal, as numeric comparisons find them
+	u := math.Float64bits(f + 0)
 	//nolint:gosec // disable G115
 	// transform without branching:
 	// if high bit is 0, xor with sign bit 1 << 63, else negate (xor with ^0).
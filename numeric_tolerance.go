@@ -0,0 +1,55 @@
+package quamina
+
+import (
+	"errors"
+	"math"
+)
+
+// WithNumericTolerance arranges that numbers in Patterns, such as the 0.3 in {"x": [0.3]}, and the numbers in
+// EventBridge "numeric" Patterns' "=" comparisons, match the numbers in Events that differ from them by no more
+// than relative times their size. This is for Events from producers whose floating-point arithmetic leaves
+// values like 0.30000000000000004 where 0.3 was meant; a tolerance of 1e-9 is ample for those. Zero is still
+// matched exactly, and numbers in "anything-but" Patterns are still compared exactly. The tolerance applies to
+// instances created with Copy. relative must be between 0 and 1. This option call may not be provided more than
+// once.
+func WithNumericTolerance(relative float64) Option {
+	return func(q *Quamina) error {
+		if q.numericTolerance != 0 {
+			return errors.New("numeric tolerance specified more than once")
+		}
+		if !(relative > 0 && relative < 1) {
+			return errors.New("numeric tolerance must be between 0 and 1")
+		}
+		q.numericTolerance = relative
+		return nil
+	}
+}
+
+func (m *coreMatcher) setNumericTolerance(relative float64) {
+	m.numericTolerance = relative
+}
+
+// widenNumbers replaces the numbers, and the numeric "=" comparisons, among a Pattern's values with ranges
+// around them, so that numbers within the relative tolerance of them match
+func widenNumbers(fields []*patternField, relative float64) {
+	for _, field := range fields {
+		for i, val := range field.vals {
+			switch {
+			case val.vType == numberType:
+				f, err := parseNumber([]byte(val.val))
+				if err != nil {
+					// out of the float64 range, so left to match exactly
+					continue
+				}
+				field.vals[i] = typedVal{vType: numericType, numeric: toleranceRange(f, relative)}
+			case val.vType == numericType && val.numeric.lo == val.numeric.hi:
+				field.vals[i].numeric = toleranceRange(val.numeric.lo, relative)
+			}
+		}
+	}
+}
+
+func toleranceRange(f, relative float64) numericRange {
+	delta := math.Abs(f) * relative
+	return numericRange{lo: f - delta, hi: f + delta}
+}
@@ -0,0 +1,70 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestNumericTolerance(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, err := New(WithNumericTolerance(1e-9), WithEventBridgeCompat(), WithPatternDeletion(deletion))
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns := map[X]string{
+			"point-three": `{"x": [0.3]}`,
+			"million":     `{"x": [{"numeric": ["=", 1e6]}]}`,
+			"zero":        `{"x": [0]}`,
+			"negative":    `{"x": [-2.5]}`,
+			"not-three":   `{"y": [{"anything-but": [0.3]}]}`,
+		}
+		for x, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for event, want := range map[string][]X{
+			`{"x": 0.30000000000000004}`: {"point-three"},
+			`{"x": 0.29999999999999998}`: {"point-three"},
+			`{"x": 0.3000001}`:           {},
+			`{"x": "0.3"}`:               {},
+			`{"x": 1000000.0000001}`:     {"million"},
+			`{"x": 1000001}`:             {},
+			`{"x": 1e-300}`:              {},
+			`{"x": -0.0}`:                {"zero"},
+			`{"x": -2.5000000000001}`:    {"negative"},
+			`{"y": 0.30000000000000004}`: {"not-three"},
+		} {
+			for _, instance := range []*Quamina{q, q.Copy()} {
+				matches, err := instance.MatchesForEvent([]byte(event))
+				if err != nil || !containsExactly(matches, want) {
+					t.Errorf("deletion=%v, %s: %v, %v", deletion, event, matches, err)
+				}
+			}
+		}
+		if deletion {
+			// a rebuild keeps the tolerance
+			_ = q.DeletePatterns("zero")
+			if err := q.matcher.(*prunerMatcher).rebuild(false); err != nil {
+				t.Fatal(err)
+			}
+			if matches, _ := q.MatchesForEvent([]byte(`{"x": 0.30000000000000004}`)); !containsExactly(matches, []X{"point-three"}) {
+				t.Errorf("after rebuild: %v", matches)
+			}
+		}
+	}
+
+	exact, _ := New()
+	_ = exact.AddPattern("p", `{"x": [0.3]}`)
+	if matches, _ := exact.MatchesForEvent([]byte(`{"x": 0.30000000000000004}`)); len(matches) != 0 {
+		t.Errorf("matched without tolerance: %v", matches)
+	}
+
+	for _, bad := range []float64{0, -1, 1, 2} {
+		if _, err := New(WithNumericTolerance(bad)); err == nil {
+			t.Errorf("tolerance %g accepted", bad)
+		}
+	}
+	if _, err := New(WithNumericTolerance(0.1), WithNumericTolerance(0.1)); err == nil {
+		t.Error("tolerance specified twice")
+	}
+}
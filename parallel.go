@@ -0,0 +1,86 @@
+package quamina
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// defaultParallelMinFields is the number of fields an event must have before WithParallelFieldMatching
+// spreads its matching across goroutines, if the option doesn't say otherwise. Below a few hundred fields,
+// starting the goroutines and merging their results costs more than it saves.
+const defaultParallelMinFields = 500
+
+// parallelMatching holds the configuration for WithParallelFieldMatching, along with a set of nfaBuffers
+// for each of the extra goroutines, which are kept from call to call just like the instance's own.
+type parallelMatching struct {
+	workers   int
+	minFields int
+	helpers   []*nfaBuffers
+}
+
+func newParallelMatching(workers, minFields int) *parallelMatching {
+	return &parallelMatching{workers: workers, minFields: minFields}
+}
+
+// helperBufs returns the buffers for the extra goroutines, creating any that are missing
+func (p *parallelMatching) helperBufs(n int, opts BufferOptions) []*nfaBuffers {
+	for len(p.helpers) < n {
+		p.helpers = append(p.helpers, newNfaBuffersWith(opts))
+	}
+	return p.helpers[:n]
+}
+
+// tryToMatchInParallel does what matchesForFieldsInto's loop over the fields does, but with the fields
+// handed out to a number of goroutines, each with its own buffers and matchSet. Each tryToMatch call
+// starting from the start state only reads the automaton and the fields, so they are independent of each
+// other; the calls for earlier fields do more work, since they go on to try all the fields after theirs,
+// so rather than giving each goroutine a fixed share, each takes the next field as soon as it's ready.
+// The calling goroutine is one of the workers, and the others' matches are merged into its matchSet.
+func tryToMatchInParallel(fields []Field, state *fieldMatcher, matches *matchSet, bufs *nfaBuffers) {
+	p := bufs.parallel
+	workers := min(p.workers, len(fields))
+	helpers := p.helperBufs(workers-1, bufs.opts)
+
+	var next atomic.Int64
+	stateFields := state.fields()
+	work := func(matches *matchSet, bufs *nfaBuffers) {
+		for {
+			i := int(next.Add(1) - 1)
+			if i >= len(fields) || stateFields.exhaustedBy(fields[i].Path) {
+				return
+			}
+			tryToMatch(fields, i, state, matches, bufs)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, helper := range helpers {
+		helperMatches := helper.getMatches()
+		helperMatches.reset()
+		helper.getTransmap().resetDepth()
+		helper.workLimit = bufs.workLimit
+		helper.resetWork()
+		helper.stats = nil
+		if bufs.stats != nil {
+			helper.stats = &MatchStats{}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(helperMatches, helper)
+		}()
+	}
+	work(matches, bufs)
+	wg.Wait()
+
+	for _, helper := range helpers {
+		for x := range helper.matches.set {
+			matches.addXSingleThreaded(x)
+		}
+		bufs.addWork(helper.work)
+		bufs.workExceeded = bufs.workExceeded || helper.workExceeded
+		if bufs.stats != nil {
+			bufs.stats.add(helper.stats)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package quamina
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// wideEvent makes an event with n top-level fields, plus an array of objects so that array-trail
+// conflicts come into play
+func wideEvent(r *rand.Rand, n int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{"list": [{"k": "a", "v": 1}, {"k": "b", "v": 2}]`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `, "f%04d": "v%d"`, i, r.Intn(4))
+	}
+	sb.WriteString("}")
+	return []byte(sb.String())
+}
+
+var widePatterns = []string{
+	`{"f0001": ["v0", "v1"]}`,
+	`{"f0002": ["v2"], "f0400": [{"prefix": "v"}]}`,
+	`{"f0010": [{"shellstyle": "*3"}], "f0999": ["v3"]}`,
+	`{"f0500": ["v1"], "missing": [{"exists": false}]}`,
+	`{"list": {"k": ["a"], "v": [1]}}`,
+	`{"list": {"k": ["a"], "v": [2]}}`,
+	`{"f0003": [{"exists": true}], "f0700": [{"anything-but": ["v0"]}]}`,
+}
+
+func TestParallelFieldMatching(t *testing.T) {
+	serial, _ := New()
+	parallel, err := New(WithParallelFieldMatching(4, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, pattern := range widePatterns {
+		if err := serial.AddPattern(i, pattern); err != nil {
+			t.Fatal(err)
+		}
+		if err := parallel.AddPattern(i, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	copied := parallel.Copy()
+	if copied.bufs.parallel == nil || copied.bufs.parallel == parallel.bufs.parallel {
+		t.Error("Copy didn't get its own parallel matching setup")
+	}
+
+	r := rand.New(rand.NewSource(99))
+	for i := 0; i < 50; i++ {
+		event := wideEvent(r, 1000)
+		wanted, err := serial.MatchesForEvent(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wanted = slices.Clone(wanted)
+		slices.SortFunc(wanted, func(a, b X) int { return a.(int) - b.(int) })
+		for _, q := range []*Quamina{parallel, copied} {
+			got, err := q.MatchesForEvent(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.SortFunc(got, func(a, b X) int { return a.(int) - b.(int) })
+			if !slices.Equal(got, wanted) {
+				t.Errorf("got %v wanted %v", got, wanted)
+			}
+		}
+	}
+	if len(parallel.bufs.parallel.helpers) != 3 {
+		t.Errorf("%d helpers, wanted 3", len(parallel.bufs.parallel.helpers))
+	}
+
+	// an event with fewer fields than workers, and one below the threshold
+	few, _ := New(WithParallelFieldMatching(8, 3))
+	_ = few.AddPattern("x", `{"a": ["1"]}`)
+	for _, event := range []string{`{"a": "1", "b": "2"}`, `{"a": "1", "b": "2", "c": "3"}`} {
+		matches, err := few.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(matches, []X{"x"}) {
+			t.Errorf("%s: got %v", event, matches)
+		}
+	}
+}
+
+func TestParallelFieldMatchingOptions(t *testing.T) {
+	bad := [][]Option{
+		{WithParallelFieldMatching(0, 10)},
+		{WithParallelFieldMatching(2, -1)},
+		{WithParallelFieldMatching(2, 10), WithParallelFieldMatching(2, 10)},
+	}
+	for i, opts := range bad {
+		if _, err := New(opts...); err == nil {
+			t.Errorf("options %d accepted", i)
+		}
+	}
+	q, err := New(WithParallelFieldMatching(2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.bufs.parallel.minFields != defaultParallelMinFields {
+		t.Errorf("minFields %d", q.bufs.parallel.minFields)
+	}
+	q, _ = New(WithParallelFieldMatching(1, 10))
+	if q.bufs.parallel != nil {
+		t.Error("parallel matching set up for one worker")
+	}
+}
+
+func BenchmarkParallelFieldMatching(b *testing.B) {
+	event := wideEvent(rand.New(rand.NewSource(1)), 2000)
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			q, _ := New(WithParallelFieldMatching(workers, 1))
+			// the flattener skips fields that no pattern uses, so the patterns have to use lots of them
+			for i := 0; i < 2000; i++ {
+				_ = q.AddPattern(i, fmt.Sprintf(`{"f%04d": ["v1", {"shellstyle": "*3"}], "f%04d": [{"prefix": "v"}]}`, i, (i+7)%2000))
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := q.MatchesForEvent(event); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
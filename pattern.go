@@ -2,10 +2,12 @@ package quamina
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/netip"
 	"strings"
 )
 
@@ -23,16 +25,47 @@ const (
 	monocaseType
 	wildcardType
 	regexpType
+	numericType
+	cidrType
+	timestampType
+	durationType
+	monocasePrefixType
+	monocaseSuffixType
+	containsType
+	monocaseContainsType
+	wordType
+	soundsLikeType
+	fuzzyType
+	sha256Type
+	hmacSHA256Type
+	collatedType
+	lookupType
 )
 
 // typedVal represents the value of a field in a pattern, giving the value and the type of pattern.
 // - list is used to handle anything-but matches with multiple values.
+// - excluded is used instead of list for the anything-but matches whose values aren't all strings.
 // - parsedRegexp only used for vType == regexpType
+// - numeric, cidr, timestamp, and duration only used for the vTypes of the same names
+// - distance only used for vType == fuzzyType
+// - digest only used for vType == sha256Type or hmacSHA256Type, and key only for hmacSHA256Type
+// - collation only used for vType == collatedType, whose val is the collation key of a string; see WithCollation
+// - lookup only used for vType == lookupType, whose val is the Lookup's name; see WithLookups
 type typedVal struct {
 	vType        valType
 	val          string
 	list         [][]byte
+	excluded     []typedVal
 	parsedRegexp regexpRoot
+	numeric      numericRange
+	cidr         netip.Prefix
+	timestamp    timestampRange
+	duration     durationRange
+	distance     int
+	digest       [sha256.Size]byte
+	key          []byte
+	collation    func(s []byte) []byte
+	lookup       Lookup
 }
 
 // patternField represents a field in a pattern.
@@ -40,22 +73,34 @@ type typedVal struct {
 type patternField struct {
 	path string
 	vals []typedVal
+	// repeated is "any" or "all" if the Pattern wrapped the values in one of them; see WithRepeatedFields
+	repeated string
+	// compare is set, in place of vals, for a "$compare" member of the Pattern
+	compare *fieldComparison
 }
 
 // patternBuild tracks the progress of patternFromJSON through a pattern-compilation project.
+// eventBridge is set to accept the forms of pattern only available with WithEventBridgeCompat.
 type patternBuild struct {
-	jd      *json.Decoder
-	path    []string
-	results []*patternField
+	jd          *json.Decoder
+	path        []string
+	results     []*patternField
+	eventBridge bool
+	repeated    string // set by an "any" or "all" pattern for the field being read
 }
 
 // patternFromJSON compiles a JSON text provided in jsonBytes into a list of patternField structures.
+func patternFromJSON(jsonBytes []byte) ([]*patternField, error) {
+	return patternFromJSONWith(jsonBytes, false)
+}
+
+// patternFromJSONWith is patternFromJSON with the EventBridge forms of pattern, if eventBridge is true.
 // I love naked returns and I cannot lie
-func patternFromJSON(jsonBytes []byte) (fields []*patternField, err error) {
+func patternFromJSONWith(jsonBytes []byte, eventBridge bool) (fields []*patternField, err error) {
 	// we can't use json.Unmarshal because it round-trips numbers through float64 and %f, so they won't end up matching
 	// what the caller actually wrote in the patternField. json.Decoder is kind of slow due to excessive
 	// memory allocation, but I haven't got around to prematurely optimizing the patternFromJSON code path
-	var pb patternBuild
+	pb := patternBuild{eventBridge: eventBridge}
 	pb.jd = json.NewDecoder(bytes.NewReader(jsonBytes))
 	pb.jd.UseNumber()
 
@@ -95,6 +140,12 @@ func readPatternObject(pb *patternBuild) error {
 
 		switch tt := t.(type) {
 		case string:
+			if tt == "$compare" {
+				if err = readCompareMember(pb); err != nil {
+					return err
+				}
+				continue
+			}
 			pb.path = append(pb.path, tt)
 			err = readPatternMember(pb)
 			if err != nil {
@@ -133,17 +184,28 @@ func readPatternMember(pb *patternBuild) error {
 }
 
 func readPatternArray(pb *patternBuild) error {
-	pathName := strings.Join(pb.path, SegmentSeparator)
+	pathName := JoinPath(pb.path...)
+	pathVals, err := readPatternValues(pb)
+	if err != nil {
+		return err
+	}
+	pb.results = append(pb.results, &patternField{path: pathName, vals: pathVals, repeated: pb.repeated})
+	pb.repeated = ""
+	return nil
+}
+
+// readPatternValues reads the values in the array for a field, after the [ has been read
+func readPatternValues(pb *patternBuild) ([]typedVal, error) {
 	var containsExclusive string
 	elementCount := 0
 	var pathVals []typedVal
 	for {
 		t, err := pb.jd.Token()
 		if errors.Is(err, io.EOF) {
-			return errors.New("patternField atEnd mid-field")
+			return nil, errors.New("patternField atEnd mid-field")
 		} else if err != nil {
 			// can't happen
-			return errors.New("pattern malformed: " + err.Error())
+			return nil, errors.New("pattern malformed: " + err.Error())
 		}
 
 		switch tt := t.(type) {
@@ -151,10 +213,9 @@ func readPatternArray(pb *patternBuild) error {
 			switch tt {
 			case ']':
 				if (containsExclusive != "") && (elementCount > 1) {
-					return fmt.Errorf(`%s cannot be combined with other values in pattern`, containsExclusive)
+					return nil, fmt.Errorf(`%s cannot be combined with other values in pattern`, containsExclusive)
 				}
-				pb.results = append(pb.results, &patternField{path: pathName, vals: pathVals})
-				return nil
+				return pathVals, nil
 			case '{':
 				var ce string
 				pathVals, ce, err = readSpecialPattern(pb, pathVals)
@@ -162,10 +223,10 @@ func readPatternArray(pb *patternBuild) error {
 					containsExclusive = ce
 				}
 				if err != nil {
-					return err
+					return nil, err
 				}
 			default:
-				return fmt.Errorf("pattern malformed, illegal %v", tt)
+				return nil, fmt.Errorf("pattern malformed, illegal %v", tt)
 			}
 		case string:
 			pathVals = append(pathVals, typedVal{vType: stringType, val: `"` + tt + `"`})
@@ -192,8 +253,12 @@ func readSpecialPattern(pb *patternBuild, valsIn []typedVal) (pathVals []typedVa
 		return
 	}
 
-	// tokenizer will throw an error if it's not a string
-	tt := t.(string)
+	// tokenizer will throw an error if it's not a string or the end of an empty object
+	tt, ok := t.(string)
+	if !ok {
+		err = errors.New("special pattern must not be empty")
+		return
+	}
 	switch tt {
 	case "anything-but":
 		containsExclusive = tt
@@ -207,11 +272,48 @@ func readSpecialPattern(pb *patternBuild, valsIn []typedVal) (pathVals []typedVa
 		pathVals, err = readWildcardSpecial(pb, pathVals)
 	case "prefix":
 		pathVals, err = readPrefixSpecial(pb, pathVals)
+	case "suffix":
+		pathVals, err = readSuffixSpecial(pb, pathVals)
+	case "contains":
+		pathVals, err = readContainsSpecial(pb, pathVals)
+	case "word":
+		pathVals, err = readWordSpecial(pb, pathVals)
+	case "soundslike":
+		pathVals, err = readSoundsLikeSpecial(pb, pathVals)
+	case "fuzzy":
+		pathVals, err = readFuzzySpecial(pb, pathVals)
+	case "sha256":
+		pathVals, err = readDigestSpecial(pb, pathVals, tt, sha256Type)
+	case "hmac-sha256":
+		pathVals, err = readDigestSpecial(pb, pathVals, tt, hmacSHA256Type)
+	case "lookup":
+		pathVals, err = readLookupSpecial(pb, pathVals)
+	case "numeric":
+		pathVals, err = readNumericSpecial(pb, pathVals)
+	case "cidr":
+		pathVals, err = readCIDRSpecial(pb, pathVals)
 	case "equals-ignore-case":
-		pathVals, err = readMonocaseSpecial(pb, pathVals)
+		pathVals, err = readMonocaseSpecial(pb, pathVals, tt, monocaseType)
+	case "prefix-equals-ignore-case":
+		pathVals, err = readMonocaseSpecial(pb, pathVals, tt, monocasePrefixType)
+	case "suffix-equals-ignore-case":
+		pathVals, err = readMonocaseSpecial(pb, pathVals, tt, monocaseSuffixType)
+	case "contains-ignore-case":
+		pathVals, err = readMonocaseSpecial(pb, pathVals, tt, monocaseContainsType)
+	case "mqtt":
+		pathVals, err = readMQTTSpecial(pb, pathVals)
+	case "nats":
+		pathVals, err = readNATSSpecial(pb, pathVals)
+	case "timestamp":
+		pathVals, err = readTimestampSpecial(pb, pathVals)
+	case "duration":
+		pathVals, err = readDurationSpecial(pb, pathVals)
 	case "regexp":
 		containsExclusive = tt
 		pathVals, err = readRegexpSpecial(pb, pathVals)
+	case "any", "all":
+		containsExclusive = tt
+		pathVals, err = readRepeatedSpecial(pb, pathVals, tt)
 	default:
 		err = errors.New("unrecognized in special pattern: " + tt)
 	}
@@ -219,20 +321,39 @@ func readSpecialPattern(pb *patternBuild, valsIn []typedVal) (pathVals []typedVa
 }
 
 func readPrefixSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
-	t, err := pb.jd.Token()
+	pathVals = valsIn
+	prefixString, ignoreCase, err := readAffix(pb, "prefix")
 	if err != nil {
 		return
 	}
-	pathVals = valsIn
+	val := typedVal{
+		vType: prefixType,
+		val:   `"` + prefixString + `"`,
+	}
+	if ignoreCase {
+		val.vType = monocasePrefixType
+	}
+	pathVals = append(pathVals, val)
 
-	prefixString, ok := t.(string)
-	if !ok {
-		err = errors.New("value for 'prefix' must be a string")
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
+
+// readSuffixSpecial reads a "suffix" pattern, which is matched as a wildcard pattern with a leading "*", or
+// if qualified by "equals-ignore-case", as a "suffix-equals-ignore-case" pattern
+func readSuffixSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+	pathVals = valsIn
+	suffixString, ignoreCase, err := readAffix(pb, "suffix")
+	if err != nil {
 		return
 	}
 	val := typedVal{
-		vType: prefixType,
-		val:   `"` + prefixString + `"`,
+		vType: wildcardType,
+		val:   `"*` + escapeWildcard(suffixString) + `"`,
+	}
+	if ignoreCase {
+		val = typedVal{vType: monocaseSuffixType, val: `"` + suffixString + `"`}
 	}
 	pathVals = append(pathVals, val)
 
@@ -241,6 +362,66 @@ func readPrefixSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal
 	return
 }
 
+// readContainsSpecial reads a "contains" pattern, which matches strings with its value anywhere in them, as the
+// wildcard pattern "*value*" does
+func readContainsSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	pathVals = valsIn
+
+	containsString, ok := t.(string)
+	if !ok {
+		err = errors.New("value for 'contains' must be a string")
+		return
+	}
+	pathVals = append(pathVals, typedVal{vType: containsType, val: `"` + containsString + `"`})
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
+
+// readAffix reads the value of a "prefix" or "suffix" pattern, which is a string or, with
+// WithEventBridgeCompat, may be an object such as {"equals-ignore-case": "abc"}
+func readAffix(pb *patternBuild, patternType string) (affix string, ignoreCase bool, err error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	if delim, ok := t.(json.Delim); ok && delim == '{' && pb.eventBridge {
+		if t, err = pb.jd.Token(); err != nil {
+			return
+		}
+		if t != "equals-ignore-case" {
+			err = fmt.Errorf("'%s' may only be qualified by 'equals-ignore-case', not %v", patternType, t)
+			return
+		}
+		if t, err = pb.jd.Token(); err != nil {
+			return
+		}
+		affix, ignoreCase = t.(string)
+		if !ignoreCase {
+			err = fmt.Errorf("value for 'equals-ignore-case' in '%s' must be a string", patternType)
+			return
+		}
+		// has to be } or tokenizer will throw error
+		_, err = pb.jd.Token()
+		return
+	}
+	affix, ok := t.(string)
+	if !ok {
+		err = fmt.Errorf("value for '%s' must be a string", patternType)
+	}
+	return
+}
+
+// escapeWildcard escapes the characters which are special in wildcard patterns
+func escapeWildcard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`).Replace(s)
+}
+
 func readExistsSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
 	t, err := pb.jd.Token()
 	if err != nil {
@@ -0,0 +1,46 @@
+// Package aws has ready-made Patterns, and the paths of the fields they use, for common AWS events, so that
+// they needn't be transcribed by hand from the AWS documentation. The Patterns are *pattern.Pattern values,
+// which can be narrowed with more conditions before they're rendered:
+//
+//	p, err := aws.S3ObjectCreated("my-bucket").And(aws.Field(aws.S3ObjectKey).Prefix("uploads/")).JSON()
+//
+// Paths are written with dots, as in the AWS documentation, and Field turns them into conditions. Most of
+// the events are those that EventBridge delivers, in which the service's own record is the member named
+// "detail". S3's notifications to SQS, SNS, and Lambda have a shape of their own, so S3's constants and
+// Patterns come in two sets, with those for EventBridge's events named S3Detail. Arguments that are empty
+// strings leave the corresponding field unconstrained, so S3ObjectCreated("") matches objects created in any
+// bucket.
+package aws
+
+import (
+	"strings"
+
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// The fields of the EventBridge envelope, which all the services' events share.
+const (
+	Source     = "source"
+	DetailType = "detail-type"
+	Account    = "account"
+	Region     = "region"
+	Resources  = "resources"
+)
+
+// Field starts a Condition on the field at path, whose member names are separated by dots.
+func Field(path string) *pattern.Condition {
+	return pattern.Field(strings.Split(path, ".")...)
+}
+
+// event returns a Pattern for EventBridge events from source with any of the detail types
+func event(source string, detailTypes ...string) *pattern.Pattern {
+	return Field(Source).Equals(source).And(Field(DetailType).Equals(detailTypes...))
+}
+
+// optional adds the condition that the field at path equals value, unless value is empty
+func optional(p *pattern.Pattern, path, value string) *pattern.Pattern {
+	if value == "" {
+		return p
+	}
+	return p.And(Field(path).Equals(value))
+}
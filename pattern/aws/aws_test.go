@@ -0,0 +1,93 @@
+package aws
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// samples returns the events in testdata, by name
+func samples(t *testing.T) map[string][]byte {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no samples: %v", err)
+	}
+	events := make(map[string][]byte)
+	for _, file := range files {
+		event, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events[strings.TrimSuffix(filepath.Base(file), ".json")] = event
+	}
+	return events
+}
+
+// matching returns the names of the samples p matches, in order
+func matching(t *testing.T, p *pattern.Pattern) []string {
+	t.Helper()
+	text, err := p.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, _ := quamina.New()
+	if err := q.AddPattern(1, text); err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	var names []string
+	for name, event := range samples(t) {
+		matches, err := q.MatchesForEvent(event)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(matches) > 0 {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+type presetTest struct {
+	name string
+	p    *pattern.Pattern
+	want []string
+}
+
+func testPresets(t *testing.T, tests []presetTest) {
+	t.Helper()
+	for _, test := range tests {
+		if got := matching(t, test.p); !slices.Equal(got, test.want) {
+			t.Errorf("%s matched %v, wanted %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestPaths checks that each path leads to a field in at least one of the samples
+func TestPaths(t *testing.T) {
+	paths := []string{
+		Source, DetailType, Account, Region, Resources,
+		S3EventName, S3EventSource, S3Region, S3BucketName, S3BucketARN, S3ObjectKey, S3ObjectSize,
+		S3ObjectETag, S3Requester, S3SourceIP, S3ConfigurationID,
+		S3DetailBucketName, S3DetailObjectKey, S3DetailObjectSize, S3DetailReason, S3DetailRequester,
+		S3DetailSourceIP,
+		CloudTrailEventSource, CloudTrailEventName, CloudTrailEventType, CloudTrailRegion, CloudTrailSourceIP,
+		CloudTrailUserAgent, CloudTrailErrorCode, CloudTrailErrorMessage, CloudTrailReadOnly, CloudTrailUserType,
+		CloudTrailUserARN, CloudTrailUserAccount, CloudTrailUserName, CloudTrailSessionIssuerARN,
+		CloudTrailMFAAuthenticated, CloudTrailConsoleLoginResult,
+		ECSClusterARN, ECSTaskARN, ECSTaskDefinitionARN, ECSGroup, ECSLaunchType, ECSLastStatus,
+		ECSDesiredStatus, ECSStopCode, ECSStoppedReason, ECSContainerName, ECSContainerStatus,
+		ECSContainerExitCode, ECSContainerReason,
+	}
+	for _, path := range paths {
+		if len(matching(t, pattern.And(Field(path).Exists(true)))) == 0 {
+			t.Errorf("no sample has %s", path)
+		}
+	}
+}
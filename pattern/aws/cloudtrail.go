@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// Paths in the CloudTrail records delivered by EventBridge. In the log files CloudTrail writes to S3, the
+// records are in the array "Records" instead of "detail", so "detail." is replaced by "Records.".
+const (
+	CloudTrailEventSource        = "detail.eventSource"
+	CloudTrailEventName          = "detail.eventName"
+	CloudTrailEventType          = "detail.eventType"
+	CloudTrailRegion             = "detail.awsRegion"
+	CloudTrailSourceIP           = "detail.sourceIPAddress"
+	CloudTrailUserAgent          = "detail.userAgent"
+	CloudTrailErrorCode          = "detail.errorCode"
+	CloudTrailErrorMessage       = "detail.errorMessage"
+	CloudTrailReadOnly           = "detail.readOnly"
+	CloudTrailUserType           = "detail.userIdentity.type"
+	CloudTrailUserARN            = "detail.userIdentity.arn"
+	CloudTrailUserAccount        = "detail.userIdentity.accountId"
+	CloudTrailUserName           = "detail.userIdentity.userName"
+	CloudTrailSessionIssuerARN   = "detail.userIdentity.sessionContext.sessionIssuer.arn"
+	CloudTrailMFAAuthenticated   = "detail.userIdentity.sessionContext.attributes.mfaAuthenticated"
+	CloudTrailConsoleLoginResult = "detail.responseElements.ConsoleLogin"
+)
+
+// CloudTrail's detail types in EventBridge.
+const (
+	CloudTrailAPICallType       = "AWS API Call via CloudTrail"
+	CloudTrailConsoleSignInType = "AWS Console Sign In via CloudTrail"
+)
+
+// CloudTrailAPICall matches calls to eventSource, such as "s3.amazonaws.com", whose names are among
+// eventNames; with no eventNames, it matches every call.
+func CloudTrailAPICall(eventSource string, eventNames ...string) *pattern.Pattern {
+	p := optional(pattern.And(Field(DetailType).Equals(CloudTrailAPICallType)), CloudTrailEventSource, eventSource)
+	if len(eventNames) > 0 {
+		p = p.And(Field(CloudTrailEventName).Equals(eventNames...))
+	}
+	return p
+}
+
+// CloudTrailConsoleLogin matches sign-ins to the AWS console.
+func CloudTrailConsoleLogin() *pattern.Pattern {
+	return Field(DetailType).Equals(CloudTrailConsoleSignInType).And(Field(CloudTrailEventName).Equals("ConsoleLogin"))
+}
+
+// CloudTrailConsoleLoginFailure matches failed sign-ins to the AWS console.
+func CloudTrailConsoleLoginFailure() *pattern.Pattern {
+	return CloudTrailConsoleLogin().And(Field(CloudTrailConsoleLoginResult).Equals("Failure"))
+}
+
+// CloudTrailRootActivity matches anything recorded as done by an account's root user.
+func CloudTrailRootActivity() *pattern.Pattern {
+	return Field(DetailType).Equals(CloudTrailAPICallType, CloudTrailConsoleSignInType).And(
+		Field(CloudTrailUserType).Equals("Root"))
+}
+
+// CloudTrailAccessDenied matches calls refused for lack of permission, under the names the services use
+// for that.
+func CloudTrailAccessDenied() *pattern.Pattern {
+	return Field(DetailType).Equals(CloudTrailAPICallType).And(Field(CloudTrailErrorCode).Equals(
+		"AccessDenied", "AccessDeniedException", "UnauthorizedOperation", "Client.UnauthorizedOperation"))
+}
+
+// CloudTrailSecurityGroupChange matches changes to EC2 security groups and their rules.
+func CloudTrailSecurityGroupChange() *pattern.Pattern {
+	return CloudTrailAPICall("ec2.amazonaws.com",
+		"AuthorizeSecurityGroupIngress", "AuthorizeSecurityGroupEgress",
+		"RevokeSecurityGroupIngress", "RevokeSecurityGroupEgress",
+		"ModifySecurityGroupRules", "CreateSecurityGroup", "DeleteSecurityGroup")
+}
+
+// CloudTrailIAMPolicyChange matches changes to IAM policies and to which users, groups, and roles they're
+// attached to.
+func CloudTrailIAMPolicyChange() *pattern.Pattern {
+	return CloudTrailAPICall("iam.amazonaws.com",
+		"CreatePolicy", "DeletePolicy", "CreatePolicyVersion", "DeletePolicyVersion", "SetDefaultPolicyVersion",
+		"AttachUserPolicy", "DetachUserPolicy", "PutUserPolicy", "DeleteUserPolicy",
+		"AttachGroupPolicy", "DetachGroupPolicy", "PutGroupPolicy", "DeleteGroupPolicy",
+		"AttachRolePolicy", "DetachRolePolicy", "PutRolePolicy", "DeleteRolePolicy")
+}
+
+// CloudTrailS3BucketPolicyChange matches changes to S3 buckets' policies, ACLs, and public access blocks.
+func CloudTrailS3BucketPolicyChange() *pattern.Pattern {
+	return CloudTrailAPICall("s3.amazonaws.com",
+		"PutBucketPolicy", "DeleteBucketPolicy", "PutBucketAcl",
+		"PutBucketPublicAccessBlock", "DeleteBucketPublicAccessBlock")
+}
@@ -0,0 +1,23 @@
+package aws
+
+import (
+	"testing"
+)
+
+func TestCloudTrail(t *testing.T) {
+	testPresets(t, []presetTest{
+		{"CloudTrailAPICall any", CloudTrailAPICall(""), []string{"cloudtrail-access-denied", "cloudtrail-security-group"}},
+		{"CloudTrailAPICall source", CloudTrailAPICall("s3.amazonaws.com"), []string{"cloudtrail-access-denied"}},
+		{"CloudTrailAPICall name", CloudTrailAPICall("s3.amazonaws.com", "GetObject"), nil},
+		{"CloudTrailAPICall MFA", CloudTrailAPICall("").And(Field(CloudTrailMFAAuthenticated).Equals("true")),
+			[]string{"cloudtrail-security-group"}},
+		{"CloudTrailConsoleLogin", CloudTrailConsoleLogin(),
+			[]string{"cloudtrail-console-login-failure", "cloudtrail-root-login"}},
+		{"CloudTrailConsoleLoginFailure", CloudTrailConsoleLoginFailure(), []string{"cloudtrail-console-login-failure"}},
+		{"CloudTrailRootActivity", CloudTrailRootActivity(), []string{"cloudtrail-root-login"}},
+		{"CloudTrailAccessDenied", CloudTrailAccessDenied(), []string{"cloudtrail-access-denied"}},
+		{"CloudTrailSecurityGroupChange", CloudTrailSecurityGroupChange(), []string{"cloudtrail-security-group"}},
+		{"CloudTrailIAMPolicyChange", CloudTrailIAMPolicyChange(), nil},
+		{"CloudTrailS3BucketPolicyChange", CloudTrailS3BucketPolicyChange(), []string{"cloudtrail-access-denied"}},
+	})
+}
@@ -0,0 +1,52 @@
+package aws
+
+import (
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// Paths in the ECS task state changes delivered by EventBridge.
+const (
+	ECSClusterARN        = "detail.clusterArn"
+	ECSTaskARN           = "detail.taskArn"
+	ECSTaskDefinitionARN = "detail.taskDefinitionArn"
+	ECSGroup             = "detail.group"
+	ECSLaunchType        = "detail.launchType"
+	ECSLastStatus        = "detail.lastStatus"
+	ECSDesiredStatus     = "detail.desiredStatus"
+	ECSStopCode          = "detail.stopCode"
+	ECSStoppedReason     = "detail.stoppedReason"
+	ECSContainerName     = "detail.containers.name"
+	ECSContainerStatus   = "detail.containers.lastStatus"
+	ECSContainerExitCode = "detail.containers.exitCode"
+	ECSContainerReason   = "detail.containers.reason"
+)
+
+// ECSTaskStateChangeType is the detail type of ECS task state changes in EventBridge.
+const ECSTaskStateChangeType = "ECS Task State Change"
+
+// ECSTaskStateChange matches every change in the state of the tasks in the cluster with the ARN clusterARN.
+func ECSTaskStateChange(clusterARN string) *pattern.Pattern {
+	return optional(event("aws.ecs", ECSTaskStateChangeType), ECSClusterARN, clusterARN)
+}
+
+// ECSServiceTasks matches changes in the state of the tasks run by the named service in the cluster.
+func ECSServiceTasks(clusterARN, service string) *pattern.Pattern {
+	return ECSTaskStateChange(clusterARN).And(Field(ECSGroup).Equals("service:" + service))
+}
+
+// ECSTaskStopped matches the reports of tasks having stopped, for whatever reason.
+func ECSTaskStopped(clusterARN string) *pattern.Pattern {
+	return ECSTaskStateChange(clusterARN).And(Field(ECSLastStatus).Equals("STOPPED"))
+}
+
+// ECSTaskFailedToStart matches the reports of tasks which stopped without ever running, as when an image
+// can't be pulled.
+func ECSTaskFailedToStart(clusterARN string) *pattern.Pattern {
+	return ECSTaskStopped(clusterARN).And(Field(ECSStopCode).Equals("TaskFailedToStart"))
+}
+
+// ECSContainerOutOfMemory matches the reports of tasks stopped because a container was killed for using
+// too much memory.
+func ECSContainerOutOfMemory(clusterARN string) *pattern.Pattern {
+	return ECSTaskStopped(clusterARN).And(Field(ECSContainerReason).Prefix("OutOfMemoryError"))
+}
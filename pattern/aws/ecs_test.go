@@ -0,0 +1,19 @@
+package aws
+
+import (
+	"testing"
+)
+
+func TestECS(t *testing.T) {
+	const prod = "arn:aws:ecs:us-west-2:111122223333:cluster/prod"
+	both := []string{"ecs-task-failed-to-start", "ecs-task-out-of-memory"}
+	testPresets(t, []presetTest{
+		{"ECSTaskStateChange any", ECSTaskStateChange(""), both},
+		{"ECSTaskStateChange cluster", ECSTaskStateChange(prod), []string{"ecs-task-out-of-memory"}},
+		{"ECSServiceTasks", ECSServiceTasks("", "worker"), []string{"ecs-task-failed-to-start"}},
+		{"ECSServiceTasks cluster", ECSServiceTasks(prod, "worker"), nil},
+		{"ECSTaskStopped", ECSTaskStopped(""), both},
+		{"ECSTaskFailedToStart", ECSTaskFailedToStart(""), []string{"ecs-task-failed-to-start"}},
+		{"ECSContainerOutOfMemory", ECSContainerOutOfMemory(prod), []string{"ecs-task-out-of-memory"}},
+	})
+}
@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// Paths in the S3 event notifications sent to SQS, SNS, and Lambda, in which each notification is in the
+// array "Records".
+const (
+	S3EventName       = "Records.eventName"
+	S3EventSource     = "Records.eventSource"
+	S3Region          = "Records.awsRegion"
+	S3BucketName      = "Records.s3.bucket.name"
+	S3BucketARN       = "Records.s3.bucket.arn"
+	S3ObjectKey       = "Records.s3.object.key"
+	S3ObjectSize      = "Records.s3.object.size"
+	S3ObjectETag      = "Records.s3.object.eTag"
+	S3Requester       = "Records.userIdentity.principalId"
+	S3SourceIP        = "Records.requestParameters.sourceIPAddress"
+	S3ConfigurationID = "Records.s3.configurationId"
+)
+
+// Paths in the S3 events delivered by EventBridge.
+const (
+	S3DetailBucketName = "detail.bucket.name"
+	S3DetailObjectKey  = "detail.object.key"
+	S3DetailObjectSize = "detail.object.size"
+	S3DetailReason     = "detail.reason"
+	S3DetailRequester  = "detail.requester"
+	S3DetailSourceIP   = "detail.source-ip-address"
+)
+
+// S3ObjectCreated matches S3 notifications of objects created in bucket, however they were created.
+func S3ObjectCreated(bucket string) *pattern.Pattern {
+	return s3Notification("ObjectCreated:", bucket)
+}
+
+// S3ObjectRemoved matches S3 notifications of objects removed from bucket, whether they were deleted or
+// replaced by delete markers.
+func S3ObjectRemoved(bucket string) *pattern.Pattern {
+	return s3Notification("ObjectRemoved:", bucket)
+}
+
+// S3ObjectRestored matches S3 notifications about restoring objects from archive storage in bucket, both
+// of the restore starting and of it finishing.
+func S3ObjectRestored(bucket string) *pattern.Pattern {
+	return s3Notification("ObjectRestore:", bucket)
+}
+
+func s3Notification(eventPrefix, bucket string) *pattern.Pattern {
+	p := Field(S3EventSource).Equals("aws:s3").And(Field(S3EventName).Prefix(eventPrefix))
+	return optional(p, S3BucketName, bucket)
+}
+
+// S3DetailObjectCreated matches EventBridge's events for objects created in bucket.
+func S3DetailObjectCreated(bucket string) *pattern.Pattern {
+	return optional(event("aws.s3", "Object Created"), S3DetailBucketName, bucket)
+}
+
+// S3DetailObjectDeleted matches EventBridge's events for objects deleted from bucket.
+func S3DetailObjectDeleted(bucket string) *pattern.Pattern {
+	return optional(event("aws.s3", "Object Deleted"), S3DetailBucketName, bucket)
+}
@@ -0,0 +1,20 @@
+package aws
+
+import (
+	"testing"
+)
+
+func TestS3(t *testing.T) {
+	testPresets(t, []presetTest{
+		{"S3ObjectCreated any", S3ObjectCreated(""), []string{"s3-object-created"}},
+		{"S3ObjectCreated bucket", S3ObjectCreated("example-bucket"), []string{"s3-object-created"}},
+		{"S3ObjectCreated other bucket", S3ObjectCreated("other-bucket"), nil},
+		{"S3ObjectCreated key", S3ObjectCreated("").And(Field(S3ObjectKey).Prefix("uploads/")), []string{"s3-object-created"}},
+		{"S3ObjectCreated other key", S3ObjectCreated("").And(Field(S3ObjectKey).Prefix("reports/")), nil},
+		{"S3ObjectRemoved", S3ObjectRemoved("other-bucket"), []string{"s3-object-removed"}},
+		{"S3ObjectRestored", S3ObjectRestored(""), nil},
+		{"S3DetailObjectCreated", S3DetailObjectCreated("example-bucket"), []string{"s3-detail-object-created"}},
+		{"S3DetailObjectCreated other bucket", S3DetailObjectCreated("other-bucket"), nil},
+		{"S3DetailObjectDeleted", S3DetailObjectDeleted(""), nil},
+	})
+}
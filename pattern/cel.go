@@ -0,0 +1,205 @@
+package pattern
+
+import (
+	"fmt"
+)
+
+// CompileCEL compiles an expression in a subset of the Common Expression Language (CEL) into Quamina Patterns,
+// so that platforms which have standardized on CEL for filtering can use Quamina to evaluate the filters.
+// For example,
+//
+//	event.type == "order" && (has(event.refund) || event.path.startsWith("/api/"))
+//
+// Comparisons are combined with && and ||, and grouped with parentheses. The comparisons supported are:
+//
+//	field == value             value is a string, number, true, false, or null
+//	field != "x"               anything-but
+//	field < 5                  numeric, as are <=, >, and >=
+//	field in [v1, v2, ...]
+//	!(field in ["x", ...])     anything-but, with several strings
+//	field.startsWith("x")      prefix
+//	field.endsWith("x")
+//	field.contains("x")
+//	field.matches("re")
+//	has(field)                 the field is present
+//	!has(field)                the field is absent
+//
+// Fields are selected with dots, or with brackets for member names that aren't identifiers, as in
+// event["content-type"]. Strings are in single or double quotes, with backslash escapes.
+//
+// CEL's matches uses RE2 syntax, and finds a match anywhere in the string unless the expression is anchored
+// with ^ and $; Quamina's regexps are I-Regexps, which match the whole string and use ~ rather than \ as the
+// escape character. The expression is translated accordingly, but RE2 features which I-Regexp lacks, such as
+// \d and (?i), are not, and cause AddPattern to fail.
+//
+// Everything else in CEL, including comparisons of strings by order, arithmetic, other functions and macros,
+// and ! other than in the forms above, is reported as an error. As with CompileRule, an expression may compile to more than one
+// Pattern, all of which should be added with the same X value.
+func CompileCEL(expression string) ([]string, error) {
+	tokens, err := lexer{quotes: `"'`}.tokens(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, and: "&&", or: "||", comparison: celComparison}
+	return p.compile()
+}
+
+func celComparison(p *parser) (*expr, error) {
+	if not := p.peek(); p.accept("!") {
+		if p.at("has") {
+			return celHas(p, false)
+		}
+		if !p.accept("(") {
+			return nil, fmt.Errorf("at %d: ! is only supported before has() and (field in [...])", not.pos)
+		}
+		path, err := celPath(p)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("in"); err != nil {
+			return nil, err
+		}
+		values, err := celList(p)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		c, err := anythingBut(Field(path...), values)
+		if err != nil {
+			return nil, err
+		}
+		return &expr{condition: c}, nil
+	}
+	if p.at("has") && p.tokens[p.next+1].text == "(" {
+		return celHas(p, true)
+	}
+
+	path, err := celPath(p)
+	if err != nil {
+		return nil, err
+	}
+	if p.at("(") {
+		// the last selection was a method name
+		return celMethod(p, path)
+	}
+	c := Field(path...)
+	op := p.take()
+	switch {
+	case op.kind == tokenPunct && op.text == "==":
+		value := p.take()
+		switch {
+		case value.kind == tokenString:
+			c.Equals(value.text)
+		case value.kind == tokenNumber:
+			c.number(value.text)
+		case value.kind == tokenIdent && (value.text == "true" || value.text == "false"):
+			c.Bool(value.text == "true")
+		case value.kind == tokenIdent && value.text == "null":
+			c.Null()
+		default:
+			return nil, fmt.Errorf("at %d: expected a literal value, found %s", value.pos, describe(value))
+		}
+	case op.kind == tokenPunct && op.text == "!=":
+		value := p.take()
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("at %d: Quamina can only exclude strings", value.pos)
+		}
+		c.AnythingBut(value.text)
+	case isOrdering(op):
+		if err := compareNumber(p, c, op); err != nil {
+			return nil, err
+		}
+	case op.kind == tokenIdent && op.text == "in":
+		values, err := celList(p)
+		if err != nil {
+			return nil, err
+		}
+		equalsAny(c, values)
+	default:
+		return nil, fmt.Errorf("at %d: expected a comparison, found %s", op.pos, describe(op))
+	}
+	return &expr{condition: c}, nil
+}
+
+// celHas reads has(field), which is present, or if exists is false, absent
+func celHas(p *parser, exists bool) (*expr, error) {
+	p.take()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	path, err := celPath(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &expr{condition: Field(path...).Exists(exists)}, nil
+}
+
+// celMethod reads the argument of the string method named by the last element of path, called on the field
+// named by the rest
+func celMethod(p *parser, path []string) (*expr, error) {
+	open := p.take()
+	method := path[len(path)-1]
+	if len(path) == 1 {
+		return nil, fmt.Errorf("at %d: function %s is not supported", open.pos, method)
+	}
+	arg := p.take()
+	if arg.kind != tokenString {
+		return nil, fmt.Errorf("at %d: %s needs a string, found %s", arg.pos, method, describe(arg))
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	c := Field(path[:len(path)-1]...)
+	switch method {
+	case "startsWith":
+		c.Prefix(arg.text)
+	case "endsWith":
+		c.Wildcard("*" + escapeWildcard(arg.text))
+	case "contains":
+		c.Wildcard("*" + escapeWildcard(arg.text) + "*")
+	case "matches":
+		c.Search(arg.text)
+	default:
+		return nil, fmt.Errorf("at %d: method %s is not supported", open.pos, method)
+	}
+	return &expr{condition: c}, nil
+}
+
+// celPath reads a field selection: identifiers separated by dots, each of which may be followed by
+// bracketed string indexes
+func celPath(p *parser) ([]string, error) {
+	var path []string
+	for {
+		t := p.take()
+		if t.kind != tokenIdent {
+			return nil, fmt.Errorf("at %d: expected a field, found %s", t.pos, describe(t))
+		}
+		path = append(path, t.text)
+		for p.accept("[") {
+			index := p.take()
+			if index.kind != tokenString {
+				return nil, fmt.Errorf("at %d: only string indexes are supported", index.pos)
+			}
+			path = append(path, index.text)
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+		if !p.accept(".") {
+			return path, nil
+		}
+	}
+}
+
+// celList reads a list literal
+func celList(p *parser) ([]token, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	return p.literals("]")
+}
@@ -0,0 +1,56 @@
+package pattern
+
+import (
+	"testing"
+)
+
+func TestCompileCEL(t *testing.T) {
+	testCompile(t, CompileCEL, []compileTest{
+		{rule: `event.type == "order" && (has(event.refund) || event.path.startsWith('/api/'))`,
+			want: []string{
+				`{"event":{"refund":[{"exists":true}],"type":["order"]}}`,
+				`{"event":{"path":[{"prefix":"/api/"}],"type":["order"]}}`,
+			}},
+		{rule: `a in ["x", 2] && !has(b) && c != "z" && !(d in ['p', 'q'])`,
+			want: []string{`{"a":["x",2],"b":[{"exists":false}],"c":[{"anything-but":["z"]}],"d":[{"anything-but":["p","q"]}]}`}},
+		{rule: `a["content-type"].b == true || c == null`,
+			want: []string{`{"a":{"content-type":{"b":[true]}}}`, `{"c":[null]}`}},
+		{rule: `name.endsWith(".jpg") && path.contains("*x\\")`,
+			want: []string{`{"name":[{"wildcard":"*.jpg"}],"path":[{"wildcard":"*\\*x\\\\*"}]}`}},
+		{rule: `a.matches("^ab+\\.c$") && b.matches("x|y")`,
+			want: []string{`{"a":[{"regexp":"ab+~.c"}],"b":[{"regexp":".*(x|y).*"}]}`}},
+		{rule: `event.size > 3 && event.ratio <= 0.5`,
+			want: []string{`{"event":{"ratio":[{"numeric":["<=",0.5]}],"size":[{"numeric":[">",3]}]}}`},
+			events: map[string]bool{
+				`{"event": {"size": 4, "ratio": 0.5}}`: true,
+				`{"event": {"size": 3, "ratio": 0.1}}`: false,
+				`{"event": {"size": 9, "ratio": 0.6}}`: false,
+			}},
+		{rule: `kind == "img" && (name.endsWith(".jpg") || name.matches("^thumb~?[0-9]+$"))`,
+			events: map[string]bool{
+				`{"kind": "img", "name": "cat.jpg"}`:  true,
+				`{"kind": "img", "name": "thumb~12"}`: true,
+				`{"kind": "img", "name": "thumb12"}`:  true,
+				`{"kind": "img", "name": "thumb12x"}`: false,
+				`{"kind": "doc", "name": "cat.jpg"}`:  false,
+				`{"kind": "img", "name": "cat.jpeg"}`: false,
+			}},
+		// unanchored, as CEL's matches is
+		{rule: `name.matches("a.c")`, events: map[string]bool{`{"name": "xxabcxx"}`: true}},
+
+		{rule: `a < "b"`, err: "< needs a number"},
+		{rule: `size(a) == 1`, err: "function size is not supported"},
+		{rule: `a.lowerAscii() == "x"`, err: "needs a string"},
+		{rule: `a.exists("x")`, err: "method exists is not supported"},
+		{rule: `!(a == "x")`, err: `expected in`},
+		{rule: `!a`, err: "! is only supported"},
+		{rule: `a == b`, err: "expected a literal value"},
+		{rule: `a != 1`, err: "only exclude strings"},
+		{rule: `a[0] == 1`, err: "only string indexes"},
+		{rule: `a == "x" && a == "y"`, err: "more than one condition"},
+		{rule: `a == 1 + 2`, err: `unexpected '+'`},
+		{rule: `a == "x`, err: "unterminated"},
+		{rule: `(a == "x" || b == "y"`, err: "expected )"},
+		{rule: `a == "x" ? b == 1 : c == 2`, err: `unexpected '?'`},
+	})
+}
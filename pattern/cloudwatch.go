@@ -0,0 +1,252 @@
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CompileCloudWatch compiles a filter pattern in the syntax of Amazon CloudWatch Logs' metric and
+// subscription filters into Quamina Patterns, to ease moving log processing to Quamina. Filters come in two
+// forms. JSON filters select fields of events which are JSON, as in
+//
+//	{ $.eventType = "UpdateTrail" && ($.errorCode IS NULL || $.sourceIPAddress != 123.123.*) }
+//
+// Selectors combined with && and || and grouped with parentheses may be:
+//
+//	$.path = value           value is a string, which may be unquoted and may use * as a wildcard, a number,
+//	                         or a %regular expression% which matches anywhere in the string
+//	$.path != "x"            anything-but
+//	$.path > 5               numeric, as are <, <=, and >=
+//	$.path IS NULL           also IS TRUE and IS FALSE
+//	$.path NOT EXISTS
+//
+// Term filters search the text of a log message, which is in the field whose path is given by message. They
+// are a single term, such as ERROR or "Failed to process", which the message must contain, or several
+// terms each prefixed by ?, of which it must contain any. A term may also be a %regular expression%.
+//
+// Quamina can't negate terms or require one field to contain several terms, so terms prefixed by - and
+// several terms without ? are reported as errors, as are array indexes in selectors and space-delimited
+// filters, written in [ ].
+func CompileCloudWatch(filter string, message ...string) ([]string, error) {
+	trimmed := strings.TrimSpace(filter)
+	switch {
+	case trimmed == "" || trimmed == `""`:
+		return nil, errors.New("an empty filter matches every event")
+	case trimmed[0] == '{':
+		return cloudWatchJSON(filter)
+	case trimmed[0] == '[':
+		return nil, errors.New("space-delimited filters are not supported")
+	}
+	if len(message) == 0 {
+		return nil, errors.New("term filters need the path of the message field")
+	}
+	return cloudWatchTerms(filter, message)
+}
+
+func cloudWatchJSON(filter string) ([]string, error) {
+	tokens, err := lexer{quotes: `"`, identDash: true, bareValues: true}.tokens(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, and: "&&", or: "||", comparison: cloudWatchComparison}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return e.patterns()
+}
+
+func cloudWatchComparison(p *parser) (*expr, error) {
+	path, err := cloudWatchSelector(p)
+	if err != nil {
+		return nil, err
+	}
+	c := Field(path...)
+	op := p.take()
+	switch {
+	case op.kind == tokenPunct && op.text == "=":
+		value := p.take()
+		switch value.kind {
+		case tokenString:
+			cloudWatchString(c, value)
+		case tokenNumber:
+			c.number(value.text)
+		default:
+			return nil, fmt.Errorf("at %d: expected a value, found %s", value.pos, describe(value))
+		}
+	case op.kind == tokenPunct && op.text == "!=":
+		value := p.take()
+		if value.kind != tokenString || strings.Contains(value.text, "*") || isRegexp(value) {
+			return nil, fmt.Errorf("at %d: Quamina can only exclude strings without wildcards", value.pos)
+		}
+		c.AnythingBut(value.text)
+	case isOrdering(op):
+		if err := compareNumber(p, c, op); err != nil {
+			return nil, err
+		}
+	case p.isKeyword(op, "IS"):
+		switch value := p.take(); {
+		case p.isKeyword(value, "NULL"):
+			c.Null()
+		case p.isKeyword(value, "TRUE"), p.isKeyword(value, "FALSE"):
+			c.Bool(strings.EqualFold(value.text, "TRUE"))
+		default:
+			return nil, fmt.Errorf("at %d: expected NULL, TRUE, or FALSE, found %s", value.pos, describe(value))
+		}
+	case p.isKeyword(op, "NOT"):
+		if err := p.expect("EXISTS"); err != nil {
+			return nil, err
+		}
+		c.Exists(false)
+	default:
+		return nil, fmt.Errorf("at %d: expected a comparison, found %s", op.pos, describe(op))
+	}
+	return &expr{condition: c}, nil
+}
+
+// cloudWatchSelector reads a selector such as $.a.b
+func cloudWatchSelector(p *parser) ([]string, error) {
+	if t := p.take(); t.kind != tokenIdent || t.text != "$" {
+		return nil, fmt.Errorf("at %d: expected a selector starting with $, found %s", t.pos, describe(t))
+	}
+	var path []string
+	for p.accept(".") {
+		t := p.take()
+		if t.kind != tokenIdent {
+			return nil, fmt.Errorf("at %d: expected a field name, found %s", t.pos, describe(t))
+		}
+		path = append(path, t.text)
+	}
+	if t := p.peek(); p.at("[") {
+		return nil, fmt.Errorf("at %d: array indexes are not supported", t.pos)
+	}
+	if len(path) == 0 {
+		t := p.peek()
+		return nil, fmt.Errorf("at %d: expected a field name, found %s", t.pos, describe(t))
+	}
+	return path, nil
+}
+
+// isRegexp reports whether t is an unquoted %regular expression%
+func isRegexp(t token) bool {
+	return t.bare && len(t.text) >= 2 && t.text[0] == '%' && t.text[len(t.text)-1] == '%'
+}
+
+// cloudWatchString adds the string value t to c: a regexp, a prefix if its only * is at the end, a
+// wildcard if it has other *s, and otherwise an exact match
+func cloudWatchString(c *Condition, t token) {
+	stars := strings.Count(t.text, "*")
+	switch {
+	case isRegexp(t):
+		c.Search(t.text[1 : len(t.text)-1])
+	case stars == 0:
+		c.Equals(t.text)
+	case stars == 1 && len(t.text) > 1 && strings.HasSuffix(t.text, "*"):
+		c.Prefix(strings.TrimSuffix(t.text, "*"))
+	default:
+		glob := strings.ReplaceAll(t.text, `\`, `\\`)
+		for strings.Contains(glob, "**") {
+			glob = strings.ReplaceAll(glob, "**", "*")
+		}
+		c.Wildcard(glob)
+	}
+}
+
+type term struct {
+	text     string
+	pos      int
+	quoted   bool
+	regexp   bool // written between % characters
+	optional bool // prefixed by ?
+}
+
+// cloudWatchTerms compiles a term filter, searching the field at message
+func cloudWatchTerms(filter string, message []string) ([]string, error) {
+	terms, err := splitTerms(filter)
+	if err != nil {
+		return nil, err
+	}
+	optional := 0
+	for _, t := range terms {
+		if t.optional {
+			optional++
+		}
+	}
+	if optional != 0 && optional != len(terms) {
+		return nil, errors.New("either all terms or none must be prefixed by ?")
+	}
+	if optional == 0 && len(terms) > 1 {
+		return nil, errors.New("Quamina can't require a field to contain several terms; prefix them with ? to match any")
+	}
+
+	// the plain terms share one Condition, but each regexp needs its own Pattern
+	contains := Field(message...)
+	var alternatives []*expr
+	for _, t := range terms {
+		switch {
+		case !t.quoted && !t.regexp && strings.HasPrefix(t.text, "-"):
+			return nil, fmt.Errorf("at %d: Quamina can't exclude terms", t.pos)
+		case t.regexp:
+			alternatives = append(alternatives, &expr{condition: Field(message...).Search(t.text)})
+		case t.text == "":
+			return nil, fmt.Errorf("at %d: empty term", t.pos)
+		default:
+			if len(alternatives) == 0 || alternatives[0].condition != contains {
+				alternatives = append([]*expr{{condition: contains}}, alternatives...)
+			}
+			contains.Wildcard("*" + escapeWildcard(t.text) + "*")
+		}
+	}
+	return (&expr{or: alternatives}).patterns()
+}
+
+// splitTerms splits a term filter at spaces outside double quotes and % characters
+func splitTerms(filter string) ([]term, error) {
+	var terms []term
+	i := 0
+	for {
+		for i < len(filter) && strings.IndexByte(" \t\r\n", filter[i]) >= 0 {
+			i++
+		}
+		if i == len(filter) {
+			return terms, nil
+		}
+		t := term{pos: i, optional: filter[i] == '?'}
+		if t.optional {
+			i++
+		}
+		switch {
+		case i < len(filter) && filter[i] == '"':
+			text, end, err := escapedString(filter, i)
+			if err != nil {
+				return nil, err
+			}
+			t.text, t.quoted = text, true
+			i = end
+		case i < len(filter) && filter[i] == '%':
+			end := strings.IndexByte(filter[i+1:], '%')
+			if end < 0 {
+				return nil, fmt.Errorf("at %d: unterminated regular expression", i)
+			}
+			t.text, t.regexp = filter[i+1:i+1+end], true
+			i += end + 2
+		default:
+			start := i
+			for i < len(filter) && strings.IndexByte(" \t\r\n", filter[i]) < 0 {
+				i++
+			}
+			t.text = filter[start:i]
+		}
+		terms = append(terms, t)
+	}
+}
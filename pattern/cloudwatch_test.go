@@ -0,0 +1,76 @@
+package pattern
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompileCloudWatch(t *testing.T) {
+	compile := func(filter string) ([]string, error) {
+		return CompileCloudWatch(filter, "log", "message")
+	}
+	testCompile(t, compile, []compileTest{
+		{rule: `{ $.eventType = "UpdateTrail" && ($.errorCode IS NULL || $.sourceIPAddress != 123.123.1.1) }`,
+			want: []string{
+				`{"errorCode":[null],"eventType":["UpdateTrail"]}`,
+				`{"eventType":["UpdateTrail"],"sourceIPAddress":[{"anything-but":["123.123.1.1"]}]}`,
+			}},
+		{rule: `{$.a.b = 75 && $.c = -1.5 && $.d IS TRUE && $.e is false && $.f NOT EXISTS}`,
+			want: []string{`{"a":{"b":[75]},"c":[-1.5],"d":[true],"e":[false],"f":[{"exists":false}]}`}},
+		{rule: `{ $.a = Describe* && $.b = *Trail && $.c = "x*y\\**" && $.user-agent = curl/8.0 }`,
+			want: []string{`{"a":[{"prefix":"Describe"}],"b":[{"wildcard":"*Trail"}],"c":[{"wildcard":"x*y\\\\*"}],"user-agent":["curl/8.0"]}`}},
+		{rule: `{ $.a = %Err(or)?% && $.b = "%x%" }`,
+			want: []string{`{"a":[{"regexp":".*(Err(or)?).*"}],"b":["%x%"]}`}},
+		{rule: `{ $.bandwidth > 75 && $.latency <= 0.25 }`,
+			want: []string{`{"bandwidth":[{"numeric":[">",75]}],"latency":[{"numeric":["<=",0.25]}]}`},
+			events: map[string]bool{
+				`{"bandwidth": 76, "latency": 0.25}`: true,
+				`{"bandwidth": 75, "latency": 0.1}`:  false,
+				`{"bandwidth": 80, "latency": 1}`:    false,
+			}},
+		{rule: `ERROR`, want: []string{`{"log":{"message":[{"wildcard":"*ERROR*"}]}}`}},
+		{rule: `"Failed to *process"`, want: []string{`{"log":{"message":[{"wildcard":"*Failed to \\*process*"}]}}`}},
+		{rule: `?ERROR ?"bad thing" ?%time ?out%`,
+			want: []string{
+				`{"log":{"message":[{"wildcard":"*ERROR*"},{"wildcard":"*bad thing*"}]}}`,
+				`{"log":{"message":[{"regexp":".*(time ?out).*"}]}}`,
+			}},
+		{rule: `{ $.eventType = "UpdateTrail" && ($.errorCode IS NULL || $.source != internal) }`,
+			events: map[string]bool{
+				`{"eventType": "UpdateTrail", "errorCode": null}`:                    true,
+				`{"eventType": "UpdateTrail", "errorCode": 3, "source": "outside"}`:  true,
+				`{"eventType": "UpdateTrail", "errorCode": 3, "source": "internal"}`: false,
+			}},
+		{rule: `?ERROR ?%fail(ed|ure)%`,
+			events: map[string]bool{
+				`{"log": {"message": "an ERROR happened"}}`:    true,
+				`{"log": {"message": "the job failed again"}}`: true,
+				`{"log": {"message": "all is well"}}`:          false,
+			}},
+
+		{rule: `{ $.bandwidth > fast }`, err: "> needs a number"},
+		{rule: `{ $.a != 1 }`, err: "without wildcards"},
+		{rule: `{ $.a != x* }`, err: "without wildcards"},
+		{rule: `{ $.a[0] = 1 }`, err: "array indexes"},
+		{rule: `{ a = 1 }`, err: "starting with $"},
+		{rule: `{ $ = 1 }`, err: "expected a field name"},
+		{rule: `{ $.a IS MAYBE }`, err: "expected NULL, TRUE, or FALSE"},
+		{rule: `{ $.a NOT 1 }`, err: "expected EXISTS"},
+		{rule: `{ $.a = 1 && $.a = 2 }`, err: "more than one condition"},
+		{rule: `{ $.a = 1 `, err: "expected }"},
+		{rule: `{ $.a = 1 } x`, err: `unexpected "x"`},
+		{rule: `{ $.a = "x }`, err: "unterminated"},
+		{rule: `[ip, user, status_code=4*]`, err: "space-delimited"},
+		{rule: `ERROR ARGUMENTS`, err: "several terms"},
+		{rule: `?ERROR WARN`, err: "all terms or none"},
+		{rule: `-DEBUG`, err: "can't exclude"},
+		{rule: `  `, err: "empty filter"},
+		{rule: `"bad`, err: "unterminated"},
+		{rule: `?%bad`, err: "unterminated regular expression"},
+		{rule: `{ $.a = 1 || ($.b = 2 && $.c }`, err: "expected a comparison"},
+	})
+
+	if _, err := CompileCloudWatch("ERROR"); err == nil || !strings.Contains(err.Error(), "message field") {
+		t.Errorf("no message field: %v", err)
+	}
+}
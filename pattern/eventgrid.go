@@ -0,0 +1,332 @@
+package pattern
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CompileEventGrid compiles the filter of an Azure Event Grid event subscription into Quamina Patterns, so
+// that a router serving several clouds can apply the filters its Event Grid subscriptions use. filter is the
+// JSON of the subscription's filter object,
+//
+//	{
+//	  "includedEventTypes": ["Microsoft.Storage.BlobCreated"],
+//	  "subjectBeginsWith": "/blobServices/default/containers/images/",
+//	  "advancedFilters": [
+//	    {"operatorType": "StringContains", "key": "data.api", "values": ["PutBlob", "CopyBlob"]},
+//	    {"operatorType": "NumberInRange", "key": "data.contentLength", "values": [[1024, 1048576]]}
+//	  ]
+//	}
+//
+// or of just its advancedFilters array. An event must pass every filter, and passes one with several values
+// if it passes for any of them. Keys such as "data.api" are paths of fields, and includedEventTypes applies to
+// the field eventType; events in the CloudEvents schema call it type, so filter them with StringIn on "type"
+// instead. As in Event Grid, strings are compared without regard to case, except by subject filters with
+// isSubjectCaseSensitive, the Not operators also pass events which lack the key, and IsNullOrUndefined passes
+// those whose value is null or which lack the key.
+//
+// StringNotIn filters compile to EventBridge's form of anything-but with equals-ignore-case, so Patterns using
+// them must be added to a Quamina instance created WithEventBridgeCompat; the others work with any. Quamina can't exclude strings by prefix, suffix, or substring without regard
+// to case, so StringNotBeginsWith, StringNotEndsWith, and StringNotContains are reported as errors, as are
+// two filters on the same key; use NumberInRange rather than NumberGreaterThan and NumberLessThan together.
+func CompileEventGrid(filter string) ([]string, error) {
+	var f eventGridFilter
+	decoder := json.NewDecoder(strings.NewReader(filter))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	var err error
+	if trimmed := strings.TrimSpace(filter); trimmed != "" && trimmed[0] == '[' {
+		err = decoder.Decode(&f.AdvancedFilters)
+	} else {
+		err = decoder.Decode(&f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bad Event Grid filter: %w", err)
+	}
+
+	var all []*expr
+	if len(f.IncludedEventTypes) > 0 {
+		c := Field("eventType")
+		for _, eventType := range f.IncludedEventTypes {
+			c.EqualsIgnoreCase(eventType)
+		}
+		all = append(all, &expr{condition: c})
+	}
+	if f.SubjectBeginsWith != "" || f.SubjectEndsWith != "" {
+		all = append(all, &expr{condition: eventGridSubject(f.SubjectBeginsWith, f.SubjectEndsWith, f.IsSubjectCaseSensitive)})
+	}
+	for i, advanced := range f.AdvancedFilters {
+		e, err := advanced.compile()
+		if err != nil {
+			return nil, fmt.Errorf("advanced filter %d: %w", i, err)
+		}
+		all = append(all, e)
+	}
+	if len(all) == 0 {
+		return nil, errors.New("an empty filter matches every event")
+	}
+	return (&expr{and: all}).patterns()
+}
+
+type eventGridFilter struct {
+	IncludedEventTypes              []string            `json:"includedEventTypes"`
+	SubjectBeginsWith               string              `json:"subjectBeginsWith"`
+	SubjectEndsWith                 string              `json:"subjectEndsWith"`
+	IsSubjectCaseSensitive          bool                `json:"isSubjectCaseSensitive"`
+	EnableAdvancedFilteringOnArrays bool                `json:"enableAdvancedFilteringOnArrays"` // Quamina always does
+	AdvancedFilters                 []eventGridAdvanced `json:"advancedFilters"`
+}
+
+type eventGridAdvanced struct {
+	OperatorType string `json:"operatorType"`
+	Key          string `json:"key"`
+	Value        any    `json:"value"`
+	Values       []any  `json:"values"`
+}
+
+func (a eventGridAdvanced) compile() (*expr, error) {
+	if a.Key == "" {
+		return nil, errors.New("no key")
+	}
+	path := strings.Split(a.Key, ".")
+	c := Field(path...)
+	orMissing := func(c *Condition) *expr {
+		return &expr{or: []*expr{{condition: c}, {condition: Field(path...).Exists(false)}}}
+	}
+
+	switch a.OperatorType {
+	case "NumberLessThan", "NumberLessThanOrEquals", "NumberGreaterThan", "NumberGreaterThanOrEquals":
+		n, err := eventGridNumber(a.Value)
+		if err != nil {
+			return nil, err
+		}
+		op := map[string]string{
+			"NumberLessThan": "<", "NumberLessThanOrEquals": "<=",
+			"NumberGreaterThan": ">", "NumberGreaterThanOrEquals": ">=",
+		}[a.OperatorType]
+		c.add("", numeric(op, n))
+		return &expr{condition: c}, nil
+	case "BoolEquals":
+		b, ok := a.Value.(bool)
+		if !ok {
+			return nil, errors.New("BoolEquals needs a true or false value")
+		}
+		return &expr{condition: c.Bool(b)}, nil
+	case "IsNullOrUndefined":
+		return orMissing(c.Null()), nil
+	case "IsNotNull":
+		// every value but null: any string, any number, true, and false
+		c.Prefix("")
+		c.add("", numeric(">=", -math.MaxFloat64))
+		return &expr{condition: c.Bool(true).Bool(false)}, nil
+	}
+
+	if len(a.Values) == 0 {
+		return nil, fmt.Errorf("%s needs a non-empty values array", a.OperatorType)
+	}
+	switch a.OperatorType {
+	case "NumberIn", "NumberNotIn":
+		ranges := make([][2]float64, len(a.Values))
+		for i, value := range a.Values {
+			n, err := eventGridNumber(value)
+			if err != nil {
+				return nil, err
+			}
+			ranges[i] = [2]float64{n, n}
+			c.Number(n)
+		}
+		if a.OperatorType == "NumberIn" {
+			return &expr{condition: c}, nil
+		}
+		return orMissing(outsideRanges(Field(path...), ranges)), nil
+	case "NumberInRange", "NumberNotInRange":
+		ranges := make([][2]float64, len(a.Values))
+		for i, value := range a.Values {
+			bounds, ok := value.([]any)
+			if !ok || len(bounds) != 2 {
+				return nil, fmt.Errorf("%s values must be [low, high] pairs", a.OperatorType)
+			}
+			for j, bound := range bounds {
+				n, err := eventGridNumber(bound)
+				if err != nil {
+					return nil, err
+				}
+				ranges[i][j] = n
+			}
+			if ranges[i][0] > ranges[i][1] {
+				return nil, fmt.Errorf("range [%g, %g] is empty", ranges[i][0], ranges[i][1])
+			}
+			c.add("", numeric(">=", ranges[i][0], "<=", ranges[i][1]))
+		}
+		if a.OperatorType == "NumberInRange" {
+			return &expr{condition: c}, nil
+		}
+		return orMissing(outsideRanges(Field(path...), ranges)), nil
+	}
+
+	strs := make([]string, len(a.Values))
+	for i, value := range a.Values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s values must be strings", a.OperatorType)
+		}
+		strs[i] = s
+	}
+	switch a.OperatorType {
+	case "StringIn":
+		for _, s := range strs {
+			c.EqualsIgnoreCase(s)
+		}
+		return &expr{condition: c}, nil
+	case "StringNotIn":
+		c.add("anything-but", special("anything-but", special("equals-ignore-case", quoteAll(strs))))
+		return orMissing(c), nil
+	case "StringBeginsWith", "StringEndsWith":
+		kind := "prefix"
+		if a.OperatorType == "StringEndsWith" {
+			kind = "suffix"
+		}
+		for _, s := range strs {
+			c.add("", special(kind, special("equals-ignore-case", quote(s))))
+		}
+		return &expr{condition: c}, nil
+	case "StringContains":
+		// a regexp can't be combined with other values, so each needs its own Pattern
+		contains := &expr{}
+		for _, s := range strs {
+			contains.or = append(contains.or, &expr{condition: Field(path...).Regexp(".*" + foldRegexp(s, true) + ".*")})
+		}
+		return contains, nil
+	case "StringNotBeginsWith", "StringNotEndsWith", "StringNotContains":
+		return nil, fmt.Errorf("Quamina can't exclude strings by prefix, suffix, or substring without regard to case, so %s is not supported", a.OperatorType)
+	}
+	return nil, fmt.Errorf("unknown operatorType %q", a.OperatorType)
+}
+
+// eventGridSubject makes the Condition for subjectBeginsWith and subjectEndsWith, either of which may be empty.
+// When both are given, a subject shorter than the two together can still pass if they overlap: "ab" and
+// "bc" pass "abc". Regexps are used for that, and for matching without regard to case.
+func eventGridSubject(prefix, suffix string, caseSensitive bool) *Condition {
+	c := Field("subject")
+	switch {
+	case suffix == "" && caseSensitive:
+		return c.Prefix(prefix)
+	case prefix == "" && caseSensitive:
+		c.add("", special("suffix", quote(suffix)))
+		return c
+	case suffix == "":
+		c.add("", special("prefix", special("equals-ignore-case", quote(prefix))))
+		return c
+	case prefix == "":
+		c.add("", special("suffix", special("equals-ignore-case", quote(suffix))))
+		return c
+	}
+	fold := !caseSensitive
+	alternatives := []string{foldRegexp(prefix, fold) + ".*" + foldRegexp(suffix, fold)}
+	for k := 1; k <= len(prefix) && k <= len(suffix); k++ {
+		overlap := prefix[len(prefix)-k:]
+		if !utf8.RuneStart(overlap[0]) || k < len(suffix) && !utf8.RuneStart(suffix[k]) {
+			continue
+		}
+		if overlap == suffix[:k] || fold && strings.EqualFold(overlap, suffix[:k]) {
+			alternatives = append(alternatives, foldRegexp(prefix+suffix[k:], fold))
+		}
+	}
+	return c.Regexp("(" + strings.Join(alternatives, ")|(") + ")")
+}
+
+// outsideRanges adds to c the numeric comparisons matching the numbers outside all the ranges, each of
+// which includes both its ends
+func outsideRanges(c *Condition, ranges [][2]float64) *Condition {
+	slices.SortFunc(ranges, func(a, b [2]float64) int {
+		switch {
+		case a[0] < b[0]:
+			return -1
+		case a[0] > b[0]:
+			return 1
+		}
+		return 0
+	})
+	c.add("", numeric("<", ranges[0][0]))
+	high := ranges[0][1]
+	for _, r := range ranges[1:] {
+		if r[0] > high {
+			c.add("", numeric(">", high, "<", r[0]))
+		}
+		high = max(high, r[1])
+	}
+	c.add("", numeric(">", high))
+	return c
+}
+
+// numeric renders an EventBridge numeric comparison such as {"numeric":[">=",1,"<=",5]} from operators
+// alternating with numbers
+func numeric(comparisons ...any) string {
+	parts := make([]string, len(comparisons))
+	for i, part := range comparisons {
+		switch part := part.(type) {
+		case string:
+			parts[i] = quote(part)
+		case float64:
+			parts[i] = strconv.FormatFloat(part, 'g', -1, 64)
+		}
+	}
+	return special("numeric", "["+strings.Join(parts, ",")+"]")
+}
+
+// eventGridNumber returns value, decoded from JSON, as a number
+func eventGridNumber(value any) (float64, error) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, found %v", value)
+	}
+	n, err := number.Float64()
+	if err != nil {
+		return 0, fmt.Errorf("bad number %s", number)
+	}
+	return n, nil
+}
+
+// quoteAll renders strs as a JSON array
+func quoteAll(strs []string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, s := range strs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(quote(s))
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
+// foldRegexp renders s as an I-Regexp matching it literally and, if fold is true, without regard to case,
+// each letter becoming a class of its case variants such as [aA]
+func foldRegexp(s string, fold bool) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if fold && unicode.SimpleFold(r) != r {
+			sb.WriteByte('[')
+			sb.WriteRune(r)
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				sb.WriteRune(f)
+			}
+			sb.WriteByte(']')
+			continue
+		}
+		if strings.ContainsRune(`()*+.?[]{}|~-^\`, r) {
+			sb.WriteByte('~')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
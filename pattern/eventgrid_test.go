@@ -0,0 +1,124 @@
+package pattern
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func TestCompileEventGrid(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`{"includedEventTypes": ["A.B", "A.C"], "subjectBeginsWith": "/x/"}`,
+			[]string{`{"eventType":[{"equals-ignore-case":"A.B"},{"equals-ignore-case":"A.C"}],"subject":[{"prefix":{"equals-ignore-case":"/x/"}}]}`}},
+		{`{"subjectBeginsWith": "/x/", "isSubjectCaseSensitive": true}`, []string{`{"subject":[{"prefix":"/x/"}]}`}},
+		{`{"subjectEndsWith": ".jpg"}`, []string{`{"subject":[{"suffix":{"equals-ignore-case":".jpg"}}]}`}},
+		{`{"subjectBeginsWith": "a.b", "subjectEndsWith": "bc", "isSubjectCaseSensitive": true}`,
+			[]string{`{"subject":[{"regexp":"(a~.b.*bc)|(a~.bc)"}]}`}},
+		{`[{"operatorType": "NumberInRange", "key": "data.n", "values": [[1, 5], [10, 20]]},
+		   {"operatorType": "NumberGreaterThan", "key": "data.m", "value": 2.5},
+		   {"operatorType": "BoolEquals", "key": "data.ok", "value": true}]`,
+			[]string{`{"data":{"m":[{"numeric":[">",2.5]}],"n":[{"numeric":[">=",1,"<=",5]},{"numeric":[">=",10,"<=",20]}],"ok":[true]}}`}},
+		{`[{"operatorType": "NumberNotIn", "key": "n", "values": [3, 1]}]`,
+			[]string{
+				`{"n":[{"numeric":["<",1]},{"numeric":[">",1,"<",3]},{"numeric":[">",3]}]}`,
+				`{"n":[{"exists":false}]}`,
+			}},
+		{`[{"operatorType": "NumberNotInRange", "key": "n", "values": [[5, 8], [0, 6]]}]`,
+			[]string{`{"n":[{"numeric":["<",0]},{"numeric":[">",8]}]}`, `{"n":[{"exists":false}]}`}},
+		{`[{"operatorType": "StringContains", "key": "data.api", "values": ["Put", "k"]}]`,
+			[]string{`{"data":{"api":[{"regexp":".*[Pp][uU][tT].*"}]}}`, `{"data":{"api":[{"regexp":".*[k` + "\u212a" + `K].*"}]}}`}},
+		{`[{"operatorType": "StringNotIn", "key": "s", "values": ["a", "b"]},
+		   {"operatorType": "IsNullOrUndefined", "key": "t"}]`,
+			[]string{
+				`{"s":[{"anything-but":{"equals-ignore-case":["a","b"]}}],"t":[null]}`,
+				`{"s":[{"anything-but":{"equals-ignore-case":["a","b"]}}],"t":[{"exists":false}]}`,
+				`{"s":[{"exists":false}],"t":[null]}`,
+				`{"s":[{"exists":false}],"t":[{"exists":false}]}`,
+			}},
+		{`[{"operatorType": "IsNotNull", "key": "u"}]`,
+			[]string{`{"u":[{"prefix":""},{"numeric":[">=",-1.7976931348623157e+308]},true,false]}`}},
+	}
+	for _, test := range tests {
+		got, err := CompileEventGrid(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+		} else if !slices.Equal(got, test.want) {
+			t.Errorf("%s:\n got %s\nwant %s", test.filter, got, test.want)
+		}
+	}
+}
+
+func TestCompileEventGridErrors(t *testing.T) {
+	bad := map[string]string{
+		`{}`:                     "empty filter",
+		`[]`:                     "empty filter",
+		`{"x":`:                  "bad Event Grid filter",
+		`{"advancedFilter": []}`: "unknown field",
+		`[{"operatorType": "StringIn", "values": ["a"]}]`:                      "no key",
+		`[{"operatorType": "StringLike", "key": "a", "values": ["a"]}]`:        "unknown operatorType",
+		`[{"operatorType": "StringIn", "key": "a", "values": []}]`:             "non-empty values",
+		`[{"operatorType": "StringIn", "key": "a", "values": [1]}]`:            "must be strings",
+		`[{"operatorType": "NumberIn", "key": "a", "values": ["1"]}]`:          "expected a number",
+		`[{"operatorType": "NumberLessThan", "key": "a", "value": 1e999}]`:     "bad number",
+		`[{"operatorType": "NumberInRange", "key": "a", "values": [[1]]}]`:     "[low, high] pairs",
+		`[{"operatorType": "NumberInRange", "key": "a", "values": [[2, 1]]}]`:  "is empty",
+		`[{"operatorType": "BoolEquals", "key": "a", "value": "true"}]`:        "true or false",
+		`[{"operatorType": "StringNotContains", "key": "a", "values": ["x"]}]`: "can't exclude",
+		`[{"operatorType": "NumberLessThan", "key": "a", "value": 1},
+		  {"operatorType": "NumberGreaterThan", "key": "a", "value": 0}]`: "more than one condition",
+	}
+	for filter, want := range bad {
+		_, err := CompileEventGrid(filter)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", filter, err, want)
+		}
+	}
+}
+
+func TestEventGridWithQuamina(t *testing.T) {
+	q, _ := quamina.New(quamina.WithEventBridgeCompat())
+	for x, filter := range map[string]string{
+		"blobs": `{"includedEventTypes": ["Microsoft.Storage.BlobCreated"], "subjectBeginsWith": "/images/",
+			"advancedFilters": [
+				{"operatorType": "StringContains", "key": "data.api", "values": ["PutBlob", "CopyBlob"]},
+				{"operatorType": "NumberInRange", "key": "data.size", "values": [[1024, 2048]]}
+			]}`,
+		"unsized": `[{"operatorType": "NumberNotInRange", "key": "data.size", "values": [[1, 4096]]},
+			{"operatorType": "IsNotNull", "key": "data.api"}]`,
+		"subject": `{"subjectBeginsWith": "ab", "subjectEndsWith": "BC"}`,
+	} {
+		patterns, err := CompileEventGrid(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range patterns {
+			if err := q.AddPattern(x, p); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+	}
+	events := map[string]string{
+		`{"eventType": "microsoft.storage.blobcreated", "subject": "/Images/a.png", "data": {"api": "xputblob", "size": 1500}}`: "blobs",
+		`{"eventType": "Microsoft.Storage.BlobCreated", "subject": "/images/a.png", "data": {"api": "PutBlob", "size": 4096}}`:  "",
+		`{"data": {"api": "PutBlob", "size": 5000}}`: "unsized",
+		`{"data": {"api": 7}}`:                       "unsized",
+		`{"data": {"api": null}}`:                    "",
+		`{"subject": "aBc"}`:                         "subject",
+		`{"subject": "abxbc"}`:                       "subject",
+		`{"subject": "abxb"}`:                        "",
+	}
+	for event, want := range events {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == "" && len(matches) != 0 || want != "" && (len(matches) != 1 || matches[0] != want) {
+			t.Errorf("%s: matches %v, want %s", event, matches, want)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package pattern
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The rule languages compile to a boolean expression over Conditions. A Quamina Pattern can only express an
+// AND of Conditions on different fields, so the expression is rewritten as an OR of ANDs, each of which
+// becomes a Pattern; adding all of them with the same X value gives the OR, since an Event matching any of
+// them yields that X.
+
+// maxPatterns limits the number of Patterns an expression may become; rewriting an AND of ORs as an OR of
+// ANDs multiplies them out, which can get out of hand.
+const maxPatterns = 256
+
+// expr is a Condition, or an AND or OR of other exprs
+type expr struct {
+	condition *Condition
+	and, or   []*expr
+}
+
+// patterns renders the Patterns equivalent to e
+func (e *expr) patterns() ([]string, error) {
+	conjunctions, err := e.dnf()
+	if err != nil {
+		return nil, err
+	}
+	patterns := make([]string, 0, len(conjunctions))
+	for _, conditions := range conjunctions {
+		p, err := And(conditions...).JSON()
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// dnf returns e in disjunctive normal form, as a list of the lists of Conditions to AND together
+func (e *expr) dnf() ([][]*Condition, error) {
+	switch {
+	case e.condition != nil:
+		return [][]*Condition{{e.condition}}, nil
+	case e.or != nil:
+		var result [][]*Condition
+		for _, sub := range e.or {
+			conjunctions, err := sub.dnf()
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, conjunctions...)
+			if len(result) > maxPatterns {
+				return nil, fmt.Errorf("rule would need more than %d patterns", maxPatterns)
+			}
+		}
+		return result, nil
+	default:
+		result := [][]*Condition{nil}
+		for _, sub := range e.and {
+			conjunctions, err := sub.dnf()
+			if err != nil {
+				return nil, err
+			}
+			if len(result)*len(conjunctions) > maxPatterns {
+				return nil, fmt.Errorf("rule would need more than %d patterns", maxPatterns)
+			}
+			var product [][]*Condition
+			for _, left := range result {
+				for _, right := range conjunctions {
+					product = append(product, append(append([]*Condition{}, left...), right...))
+				}
+			}
+			result = product
+		}
+		return result, nil
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // for strings, with the quotes removed and escapes processed
+	pos    int    // byte offset in the rule, for error messages
+	quoted bool   // for identifiers, which SQL allows to be quoted so that they aren't taken as keywords
+	bare   bool   // for strings, which lexer.bareValues allows to be unquoted
+}
+
+// punctuation lists the operators and delimiters the rule languages use, longest first so that, for example,
+// "<=" isn't read as "<" followed by "="
+var punctuation = []string{
+	"==", "!=", "<>", "<=", ">=", "^=", "=~", "~=", "&&", "||",
+	"(", ")", "[", "]", "{", "}", ",", ".", ":", "=", "<", ">", "~", "!", "|",
+}
+
+// lexer splits a rule into tokens. The languages differ in how they quote strings: sqlQuotes means strings
+// are in single quotes and identifiers may be in double quotes, with a quote inside either doubled;
+// otherwise strings are in the quote characters listed in quotes, with backslash escapes as in Go and JSON.
+// identDash allows "-" in identifiers after the first character. bareValues allows the value after "=" or "!="
+// to be unquoted; it runs to the next space, ")", "}", "&&", or "||", and is a number if it looks like one.
+type lexer struct {
+	quotes     string
+	sqlQuotes  bool
+	identDash  bool
+	bareValues bool
+}
+
+func (l lexer) tokens(rule string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for {
+		for i < len(rule) && strings.IndexByte(" \t\r\n", rule[i]) >= 0 {
+			i++
+		}
+		if i == len(rule) {
+			return append(tokens, token{kind: tokenEOF, pos: i}), nil
+		}
+		start := i
+		c, size := utf8.DecodeRuneInString(rule[i:])
+		if l.bareValues && !strings.ContainsRune(l.quotes, c) && afterEquals(tokens) {
+			if end := bareValueEnd(rule, i); end > i {
+				i = end
+				if (c >= '0' && c <= '9' || c == '-' && i > start+1) && scanNumber(rule, start) == i {
+					tokens = append(tokens, token{kind: tokenNumber, text: rule[start:i], pos: start})
+				} else {
+					tokens = append(tokens, token{kind: tokenString, text: rule[start:i], pos: start, bare: true})
+				}
+				continue
+			}
+		}
+		switch {
+		case l.sqlQuotes && (c == '\'' || c == '"'):
+			text, end, err := sqlString(rule, i)
+			if err != nil {
+				return nil, err
+			}
+			if c == '"' {
+				tokens = append(tokens, token{kind: tokenIdent, text: text, pos: start, quoted: true})
+			} else {
+				tokens = append(tokens, token{kind: tokenString, text: text, pos: start})
+			}
+			i = end
+		case !l.sqlQuotes && strings.ContainsRune(l.quotes, c):
+			text, end, err := escapedString(rule, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: start})
+			i = end
+		case c == '-' || (c >= '0' && c <= '9'):
+			i = scanNumber(rule, i)
+			if i == start+1 && c == '-' {
+				return nil, fmt.Errorf("at %d: '-' not followed by a number", start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: rule[start:i], pos: start})
+		case c == '_' || c == '$' || unicode.IsLetter(c):
+			i += size
+			for i < len(rule) {
+				c, size = utf8.DecodeRuneInString(rule[i:])
+				if !(c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c) || (l.identDash && c == '-')) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: rule[start:i], pos: start})
+		default:
+			found := false
+			for _, punct := range punctuation {
+				if strings.HasPrefix(rule[i:], punct) {
+					tokens = append(tokens, token{kind: tokenPunct, text: punct, pos: start})
+					i += len(punct)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("at %d: unexpected %q", start, c)
+			}
+		}
+	}
+}
+
+func afterEquals(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == tokenPunct && (last.text == "=" || last.text == "!=")
+}
+
+// bareValueEnd returns the end of the unquoted value starting at rule[i]. A value starting with "%" runs to
+// the next "%", so that CloudWatch's %regular expressions% may contain spaces and parentheses.
+func bareValueEnd(rule string, i int) int {
+	if rule[i] == '%' {
+		if end := strings.IndexByte(rule[i+1:], '%'); end >= 0 {
+			return i + end + 2
+		}
+	}
+	for i < len(rule) && strings.IndexByte(" \t\r\n)}", rule[i]) < 0 &&
+		!strings.HasPrefix(rule[i:], "&&") && !strings.HasPrefix(rule[i:], "||") {
+		i++
+	}
+	return i
+}
+
+// scanNumber returns the end of the number starting at rule[i], which is a digit or '-'
+func scanNumber(rule string, i int) int {
+	if rule[i] == '-' {
+		i++
+	}
+	digits := func() {
+		for i < len(rule) && rule[i] >= '0' && rule[i] <= '9' {
+			i++
+		}
+	}
+	digits()
+	if i < len(rule) && rule[i] == '.' && i+1 < len(rule) && rule[i+1] >= '0' && rule[i+1] <= '9' {
+		i++
+		digits()
+	}
+	if i < len(rule) && (rule[i] == 'e' || rule[i] == 'E') {
+		j := i + 1
+		if j < len(rule) && (rule[j] == '+' || rule[j] == '-') {
+			j++
+		}
+		if j < len(rule) && rule[j] >= '0' && rule[j] <= '9' {
+			i = j
+			digits()
+		}
+	}
+	return i
+}
+
+// escapedString reads the string starting with the quote at rule[start], returning its text and the
+// position after the closing quote
+func escapedString(rule string, start int) (string, int, error) {
+	quote := rule[start]
+	var sb strings.Builder
+	for i := start + 1; i < len(rule); i++ {
+		c := rule[i]
+		switch {
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c != '\\':
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		if i == len(rule) {
+			break
+		}
+		switch rule[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'u':
+			if i+4 >= len(rule) {
+				return "", 0, fmt.Errorf("at %d: bad \\u escape", i-1)
+			}
+			r, err := strconv.ParseUint(rule[i+1:i+5], 16, 32)
+			if err != nil {
+				return "", 0, fmt.Errorf("at %d: bad \\u escape", i-1)
+			}
+			sb.WriteRune(rune(r)) //nolint:gosec // four hex digits fit in a rune
+			i += 4
+		case '\\', '"', '\'', '/':
+			sb.WriteByte(rule[i])
+		default:
+			return "", 0, fmt.Errorf("at %d: unknown escape \\%c", i-1, rule[i])
+		}
+	}
+	return "", 0, fmt.Errorf("at %d: unterminated string", start)
+}
+
+// sqlString reads the string starting with the quote at rule[start], in which the quote is written as two
+func sqlString(rule string, start int) (string, int, error) {
+	quote := rule[start]
+	var sb strings.Builder
+	for i := start + 1; i < len(rule); i++ {
+		if rule[i] != quote {
+			sb.WriteByte(rule[i])
+			continue
+		}
+		if i+1 < len(rule) && rule[i+1] == quote {
+			sb.WriteByte(quote)
+			i++
+			continue
+		}
+		return sb.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("at %d: unterminated string", start)
+}
+
+// parser has what the rule languages' recursive-descent parsers share: the tokens, and the handling of
+// AND, OR, and parentheses. and and or are the operators; keywords, as in SQL, are matched regardless of
+// case. comparison parses whatever the language allows between the boolean operators.
+type parser struct {
+	tokens     []token
+	next       int
+	and, or    string
+	comparison func(p *parser) (*expr, error)
+}
+
+// compile parses the whole rule and renders its Patterns
+func (p *parser) compile() ([]string, error) {
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return e.patterns()
+}
+
+func (p *parser) parseOr() (*expr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if !p.at(p.or) {
+		return e, nil
+	}
+	or := &expr{or: []*expr{e}}
+	for p.accept(p.or) {
+		if e, err = p.parseAnd(); err != nil {
+			return nil, err
+		}
+		or.or = append(or.or, e)
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd() (*expr, error) {
+	e, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.at(p.and) {
+		return e, nil
+	}
+	and := &expr{and: []*expr{e}}
+	for p.accept(p.and) {
+		if e, err = p.parsePrimary(); err != nil {
+			return nil, err
+		}
+		and.and = append(and.and, e)
+	}
+	return and, nil
+}
+
+func (p *parser) parsePrimary() (*expr, error) {
+	if !p.accept("(") {
+		return p.comparison(p)
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// at reports whether the next token is the punctuation or keyword s
+func (p *parser) at(s string) bool {
+	t := p.peek()
+	switch t.kind {
+	case tokenPunct:
+		return t.text == s
+	case tokenIdent:
+		return p.isKeyword(t, s)
+	}
+	return false
+}
+
+// isKeyword reports whether t is the keyword
+func (p *parser) isKeyword(t token, keyword string) bool {
+	return t.kind == tokenIdent && !t.quoted && strings.EqualFold(t.text, keyword)
+}
+
+// accept consumes the next token if it is s
+func (p *parser) accept(s string) bool {
+	if p.at(s) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return fmt.Errorf("at %d: expected %s, found %s", p.peek().pos, s, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	return fmt.Errorf("at %d: unexpected %s", t.pos, describe(t))
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of rule"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// literals parses a comma-separated list of strings and numbers ending with close, the opening
+// delimiter having been consumed
+func (p *parser) literals(close string) ([]token, error) {
+	var values []token
+	for {
+		t := p.take()
+		if t.kind != tokenString && t.kind != tokenNumber {
+			return nil, fmt.Errorf("at %d: expected a string or number, found %s", t.pos, describe(t))
+		}
+		values = append(values, t)
+		if p.accept(close) {
+			return values, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// equalsAny adds the literal values to c
+func equalsAny(c *Condition, values []token) *Condition {
+	for _, value := range values {
+		if value.kind == tokenString {
+			c.Equals(value.text)
+		} else {
+			c.number(value.text)
+		}
+	}
+	return c
+}
+
+// anythingBut makes c allow anything but the string values
+func anythingBut(c *Condition, values []token) (*Condition, error) {
+	var excluded []string
+	for _, value := range values {
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("at %d: Quamina can only exclude strings", value.pos)
+		}
+		excluded = append(excluded, value.text)
+	}
+	return c.AnythingBut(excluded...), nil
+}
+
+// isOrdering reports whether t is one of the operators which compare numbers by size
+func isOrdering(t token) bool {
+	return t.kind == tokenPunct && (t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">=")
+}
+
+// compareNumber adds to c the numbers related by op, one of <, <=, >, and >=, to the number which follows
+func compareNumber(p *parser, c *Condition, op token) error {
+	value, err := p.number(op.text)
+	if err != nil {
+		return err
+	}
+	c.Numeric(op.text, value)
+	return nil
+}
+
+// number reads the number that the operator what needs
+func (p *parser) number(what string) (float64, error) {
+	t := p.take()
+	if t.kind != tokenNumber {
+		return 0, fmt.Errorf("at %d: %s needs a number, found %s", t.pos, what, describe(t))
+	}
+	value, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("at %d: bad number %s", t.pos, t.text)
+	}
+	return value, nil
+}
+
+// escapeWildcard escapes the characters special in wildcard patterns, so that s matches only itself
+func escapeWildcard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`).Replace(s)
+}
+
+// searchToIRegexp translates a regular expression in the Perl-like syntax that CEL's matches and jq's test use,
+// which finds a match anywhere unless anchored, into an I-Regexp, which must match the whole string and
+// escapes with ~
+func searchToIRegexp(re string) string {
+	var sb strings.Builder
+	anchoredStart := strings.HasPrefix(re, "^")
+	if anchoredStart {
+		re = re[1:]
+	}
+	anchoredEnd := strings.HasSuffix(re, "$") && !strings.HasSuffix(re, `\$`)
+	if anchoredEnd {
+		re = re[:len(re)-1]
+	}
+	for i := 0; i < len(re); i++ {
+		switch re[i] {
+		case '\\':
+			if i+1 < len(re) {
+				i++
+				sb.WriteByte('~')
+				sb.WriteByte(re[i])
+			}
+		case '~':
+			sb.WriteString("~~")
+		default:
+			sb.WriteByte(re[i])
+		}
+	}
+	translated := sb.String()
+	if anchoredStart && anchoredEnd {
+		return translated
+	}
+	translated = "(" + translated + ")"
+	if !anchoredStart {
+		translated = ".*" + translated
+	}
+	if !anchoredEnd {
+		translated += ".*"
+	}
+	return translated
+}
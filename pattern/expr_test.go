@@ -0,0 +1,58 @@
+package pattern
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// compileTest is a rule in one of the languages, with either part of the error it should be reported with, or
+// the Patterns it should compile to. If events is set, the Patterns are added to a Quamina instance with the
+// same X value, and each Event should match them, exactly once, or not, as events says.
+type compileTest struct {
+	rule   string
+	err    string
+	want   []string
+	events map[string]bool
+}
+
+// testCompile runs the compileTests for a language through compile
+func testCompile(t *testing.T, compile func(string) ([]string, error), tests []compileTest) {
+	t.Helper()
+	for _, test := range tests {
+		got, err := compile(test.rule)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v, want %q", test.rule, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.rule, err)
+			continue
+		}
+		if test.want != nil && !slices.Equal(got, test.want) {
+			t.Errorf("%s:\n got %s\nwant %s", test.rule, got, test.want)
+		}
+		if test.events == nil {
+			continue
+		}
+		q, _ := quamina.New()
+		for _, p := range got {
+			if err := q.AddPattern("rule", p); err != nil {
+				t.Fatalf("%s: %v", test.rule, err)
+			}
+		}
+		for event, want := range test.events {
+			matches, err := q.MatchesForEvent([]byte(event))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(matches) == 1) != want || len(matches) > 1 {
+				t.Errorf("%s, %s: matches %v, want %t", test.rule, event, matches, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package pattern
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompileJQ compiles a jq selection filter, such as
+//
+//	select(.a.b == "x" and (.c | startswith("y")))
+//
+// into Quamina Patterns, so that filters written for jq can be used in rule configurations. The select( )
+// around the condition is optional. Conditions are combined with and and or, and grouped with parentheses.
+// The conditions supported are:
+//
+//	.path == value              value is a string, number, true, or false
+//	.path == null               the field is null or absent, as in jq
+//	.path != "x"                anything-but
+//	.path < 5                   numeric, as are <=, >, and >=
+//	.path | IN("x", "y", ...)
+//	.path | startswith("x")
+//	.path | endswith("x")
+//	.path | contains("x")       for strings
+//	.path | test("re")
+//	.path | ascii_downcase == "x"  equals-ignore-case
+//	.path | has("name")         the field .path.name is present; has("name") alone means .name
+//
+// Paths are made of .name, ."name", and .["name"] steps. jq's test uses Oniguruma regular expressions, which
+// find a match anywhere unless anchored; they are translated to I-Regexps as for CompileCEL. Everything else in
+// jq, including comparisons of strings by order, != null, not, array indexes and iteration, and arithmetic, is
+// reported as an error. As with CompileRule, a filter may compile to more than one Pattern, all of which should
+// be added with the same X value.
+func CompileJQ(filter string) ([]string, error) {
+	tokens, err := lexer{quotes: `"`}.tokens(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, and: "and", or: "or", comparison: jqComparison}
+	if !p.accept("select") {
+		return p.compile()
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return e.patterns()
+}
+
+func jqComparison(p *parser) (*expr, error) {
+	if p.accept("has") {
+		name, err := jqHas(p)
+		if err != nil {
+			return nil, err
+		}
+		return &expr{condition: Field(name).Exists(true)}, nil
+	}
+	path, err := jqPath(p)
+	if err != nil {
+		return nil, err
+	}
+	c := Field(path...)
+	op := p.take()
+	switch {
+	case op.kind == tokenPunct && op.text == "==":
+		value := p.take()
+		switch {
+		case value.kind == tokenString:
+			c.Equals(value.text)
+		case value.kind == tokenNumber:
+			c.number(value.text)
+		case value.kind == tokenIdent && (value.text == "true" || value.text == "false"):
+			c.Bool(value.text == "true")
+		case value.kind == tokenIdent && value.text == "null":
+			// jq's missing fields are null
+			return &expr{or: []*expr{{condition: c.Null()}, {condition: Field(path...).Exists(false)}}}, nil
+		default:
+			return nil, fmt.Errorf("at %d: expected a literal value, found %s", value.pos, describe(value))
+		}
+	case op.kind == tokenPunct && op.text == "!=":
+		value := p.take()
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("at %d: Quamina can only exclude strings", value.pos)
+		}
+		c.AnythingBut(value.text)
+	case isOrdering(op):
+		if err := compareNumber(p, c, op); err != nil {
+			return nil, err
+		}
+	case op.kind == tokenPunct && op.text == "|":
+		return jqFunction(p, path)
+	default:
+		return nil, fmt.Errorf("at %d: expected a comparison, found %s", op.pos, describe(op))
+	}
+	return &expr{condition: c}, nil
+}
+
+// jqFunction reads the function the field at path is piped into
+func jqFunction(p *parser, path []string) (*expr, error) {
+	c := Field(path...)
+	function := p.take()
+	if function.kind != tokenIdent {
+		return nil, fmt.Errorf("at %d: expected a function, found %s", function.pos, describe(function))
+	}
+	switch function.text {
+	case "has":
+		name, err := jqHas(p)
+		if err != nil {
+			return nil, err
+		}
+		return &expr{condition: Field(append(path, name)...).Exists(true)}, nil
+	case "ascii_downcase":
+		if err := p.expect("=="); err != nil {
+			return nil, err
+		}
+		value := p.take()
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("at %d: ascii_downcase needs a string, found %s", value.pos, describe(value))
+		}
+		if value.text != strings.ToLower(value.text) {
+			return nil, fmt.Errorf("at %d: %q has upper-case letters, so it never equals a downcased value", value.pos, value.text)
+		}
+		return &expr{condition: c.EqualsIgnoreCase(value.text)}, nil
+	case "IN":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		values, err := p.literals(")")
+		if err != nil {
+			return nil, err
+		}
+		return &expr{condition: equalsAny(c, values)}, nil
+	case "startswith", "endswith", "contains", "test":
+	default:
+		return nil, fmt.Errorf("at %d: function %s is not supported", function.pos, function.text)
+	}
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	arg := p.take()
+	if arg.kind != tokenString {
+		return nil, fmt.Errorf("at %d: %s needs a string, found %s", arg.pos, function.text, describe(arg))
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	switch function.text {
+	case "startswith":
+		c.Prefix(arg.text)
+	case "endswith":
+		c.Wildcard("*" + escapeWildcard(arg.text))
+	case "contains":
+		c.Wildcard("*" + escapeWildcard(arg.text) + "*")
+	case "test":
+		c.Search(arg.text)
+	}
+	return &expr{condition: c}, nil
+}
+
+// jqHas reads the ("name") following has
+func jqHas(p *parser) (string, error) {
+	if err := p.expect("("); err != nil {
+		return "", err
+	}
+	name := p.take()
+	if name.kind != tokenString {
+		return "", fmt.Errorf("at %d: has needs a string, found %s", name.pos, describe(name))
+	}
+	if err := p.expect(")"); err != nil {
+		return "", err
+	}
+	return name.text, nil
+}
+
+// jqPath reads a path made of .name, ."name", and .["name"] steps; brackets may also follow a name directly,
+// as in .a["b"]
+func jqPath(p *parser) ([]string, error) {
+	var path []string
+	for p.accept(".") {
+		if !p.at("[") {
+			t := p.take()
+			if t.kind != tokenIdent && t.kind != tokenString {
+				return nil, fmt.Errorf("at %d: expected a field name, found %s", t.pos, describe(t))
+			}
+			path = append(path, t.text)
+		}
+		for p.accept("[") {
+			index := p.take()
+			if index.kind != tokenString {
+				return nil, fmt.Errorf("at %d: only string indexes are supported", index.pos)
+			}
+			path = append(path, index.text)
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(path) == 0 {
+		t := p.peek()
+		return nil, fmt.Errorf("at %d: expected a path, found %s", t.pos, describe(t))
+	}
+	return path, nil
+}
@@ -0,0 +1,55 @@
+package pattern
+
+import (
+	"testing"
+)
+
+func TestCompileJQ(t *testing.T) {
+	testCompile(t, CompileJQ, []compileTest{
+		{rule: `select(.a.b == "x" and (.c | startswith("y")))`,
+			want: []string{`{"a":{"b":["x"]},"c":[{"prefix":"y"}]}`}},
+		{rule: `.a == 1 or .b == true`,
+			want: []string{`{"a":[1]}`, `{"b":[true]}`}},
+		{rule: `.a == null`,
+			want: []string{`{"a":[null]}`, `{"a":[{"exists":false}]}`}},
+		{rule: `.["content-type"] != "text/plain" and ."x-y"["z"] | IN("p", 2)`,
+			want: []string{`{"content-type":[{"anything-but":["text/plain"]}],"x-y":{"z":["p",2]}}`}},
+		{rule: `has("a") and (.b | has("c"))`,
+			want: []string{`{"a":[{"exists":true}],"b":{"c":[{"exists":true}]}}`}},
+		{rule: `(.name | endswith(".jpg")) and (.path | contains("*x\\"))`,
+			want: []string{`{"name":[{"wildcard":"*.jpg"}],"path":[{"wildcard":"*\\*x\\\\*"}]}`}},
+		{rule: `(.a | test("^ab+\\.c$")) and (.b | ascii_downcase == "hello")`,
+			want: []string{`{"a":[{"regexp":"ab+~.c"}],"b":[{"equals-ignore-case":"hello"}]}`}},
+		{rule: `select(.price > 10 and .qty < 3)`,
+			want: []string{`{"price":[{"numeric":[">",10]}],"qty":[{"numeric":["<",3]}]}`},
+			events: map[string]bool{
+				`{"price": 11, "qty": 2}`: true,
+				`{"price": 10, "qty": 2}`: false,
+				`{"price": 11, "qty": 3}`: false,
+			}},
+		{rule: `select(.kind == "img" and ((.name | endswith(".jpg")) or .owner == null))`,
+			events: map[string]bool{
+				`{"kind": "img", "name": "cat.jpg", "owner": "bob"}`: true,
+				`{"kind": "img", "name": "cat.png"}`:                 true,
+				`{"kind": "img", "name": "cat.png", "owner": null}`:  true,
+				`{"kind": "img", "name": "cat.png", "owner": "bob"}`: false,
+				`{"kind": "doc", "name": "cat.jpg"}`:                 false,
+			}},
+
+		{rule: `.name >= "m"`, err: ">= needs a number"},
+		{rule: `.a != null`, err: "only exclude strings"},
+		{rule: `.a | not`, err: "function not is not supported"},
+		{rule: `.a | test("x"; "i")`, err: "unexpected ';'"},
+		{rule: `.a | ascii_downcase == "Hello"`, err: "upper-case"},
+		{rule: `.a[0] == 1`, err: "only string indexes"},
+		{rule: `.a == .b`, err: "expected a literal value"},
+		{rule: `a == 1`, err: "expected a path"},
+		{rule: `.a == "x" and .a == "y"`, err: "more than one condition"},
+		{rule: `select(.a == "x"`, err: "expected )"},
+		{rule: `select(.a == "x") | .b`, err: `unexpected "|"`},
+		{rule: `.a == "x`, err: "unterminated"},
+		{rule: `.a | startswith(1)`, err: "startswith needs a string"},
+		{rule: `.a | 1`, err: "expected a function"},
+		{rule: `.a + 1 == 2`, err: `unexpected '+'`},
+	})
+}
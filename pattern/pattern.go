@@ -0,0 +1,309 @@
+// Package pattern builds Quamina Patterns in Go code, so that applications don't have to assemble JSON text
+// by hand and get the quoting and escaping of the values right. A Pattern is made of conditions on fields,
+// each of which allows one or more values:
+//
+//	p, err := pattern.Field("status").Equals("failed").And(
+//		pattern.Field("request", "path").Shellstyle("/api/*"),
+//		pattern.Field("retries").Number(0, 1),
+//	).JSON()
+//
+// gives {"request":{"path":[{"shellstyle":"/api/*"}]},"retries":[0,1],"status":["failed"]}, ready to pass to
+// AddPattern. The builder checks the things it can, such as that a field isn't given two conditions and that
+// values which may not be combined with others aren't, and reports the first problem from JSON; Quamina's
+// own checks of the values themselves, for example of regexp syntax, happen when the Pattern is added.
+package pattern
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Condition is the set of values allowed for one field of an Event. It is built by Field and the methods
+// which add values, each of which returns the Condition so that calls can be chained; an Event matches the
+// Condition if the field has any of the values.
+type Condition struct {
+	path      []string
+	values    []string // rendered as JSON
+	exclusive string   // the kind of value, if any, that can't be combined with others
+	err       error
+}
+
+// Field starts a Condition on the field reached by the member names in path; Field("a", "b") is the field
+// "b" in the object that is the value of the top-level field "a".
+func Field(path ...string) *Condition {
+	c := &Condition{path: path}
+	if len(path) == 0 {
+		c.err = errors.New("field with no path")
+	}
+	return c
+}
+
+// Equals allows each of the strings.
+func (c *Condition) Equals(values ...string) *Condition {
+	for _, value := range values {
+		c.add("", quote(value))
+	}
+	return c
+}
+
+// Number allows each of the numbers. Quamina compares numbers by value, so 1 also matches 1.0 and 1e0.
+func (c *Condition) Number(values ...float64) *Condition {
+	for _, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			c.fail(fmt.Errorf("%v can't be represented in JSON", value))
+			return c
+		}
+		c.add("", strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	return c
+}
+
+// number allows the number written as text, as it is in a rule
+func (c *Condition) number(text string) *Condition {
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		c.fail(fmt.Errorf("bad number %s", text))
+		return c
+	}
+	return c.Number(value)
+}
+
+// Numeric allows the numbers related to value by op, which is one of "=", "<", "<=", ">", and ">=", as a
+// "numeric" Pattern does; "=" is equivalent to Number. Numeric Patterns never match strings.
+func (c *Condition) Numeric(op string, value float64) *Condition {
+	switch op {
+	case "=":
+		return c.Number(value)
+	case "<", "<=", ">", ">=":
+	default:
+		c.fail(fmt.Errorf("unknown numeric comparison %q", op))
+		return c
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		c.fail(fmt.Errorf("%v can't be represented in JSON", value))
+		return c
+	}
+	c.add("", numeric(op, value))
+	return c
+}
+
+// Bool allows the value.
+func (c *Condition) Bool(value bool) *Condition {
+	c.add("", strconv.FormatBool(value))
+	return c
+}
+
+// Null allows null.
+func (c *Condition) Null() *Condition {
+	c.add("", "null")
+	return c
+}
+
+// Exists, if true, matches Events which have the field, whatever its value, and if false, those which
+// don't. It may not be combined with other values.
+func (c *Condition) Exists(exists bool) *Condition {
+	c.add("exists", special("exists", strconv.FormatBool(exists)))
+	return c
+}
+
+// Prefix allows strings that begin with prefix.
+func (c *Condition) Prefix(prefix string) *Condition {
+	c.add("", special("prefix", quote(prefix)))
+	return c
+}
+
+// EqualsIgnoreCase allows strings that are equal to value apart from case.
+func (c *Condition) EqualsIgnoreCase(value string) *Condition {
+	c.add("", special("equals-ignore-case", quote(value)))
+	return c
+}
+
+// ContainsIgnoreCase allows strings that contain value anywhere, with ASCII letters compared regardless of case.
+func (c *Condition) ContainsIgnoreCase(value string) *Condition {
+	c.add("", special("contains-ignore-case", quote(value)))
+	return c
+}
+
+// Shellstyle allows strings matching glob, in which "*" matches any string.
+func (c *Condition) Shellstyle(glob string) *Condition {
+	c.add("", special("shellstyle", quote(glob)))
+	return c
+}
+
+// Wildcard is like Shellstyle, but "*" may be escaped as "\*" to match itself, and "\\" matches "\".
+func (c *Condition) Wildcard(glob string) *Condition {
+	c.add("", special("wildcard", quote(glob)))
+	return c
+}
+
+// AnythingBut allows all strings other than values. It may not be combined with other values.
+func (c *Condition) AnythingBut(values ...string) *Condition {
+	if len(values) == 0 {
+		c.fail(errors.New("anything-but needs at least one value"))
+		return c
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quote(value)
+	}
+	c.add("anything-but", special("anything-but", "["+strings.Join(quoted, ",")+"]"))
+	return c
+}
+
+// Regexp allows strings matching the I-Regexp re, in which "~" is the escape character. It may not be
+// combined with other values.
+func (c *Condition) Regexp(re string) *Condition {
+	c.add("regexp", special("regexp", quote(re)))
+	return c
+}
+
+// Search allows strings containing a match for re, a regular expression in the syntax of RE2 and most
+// scripting languages, which matches anywhere in the string unless anchored with ^ and $. It is translated to
+// an I-Regexp, but features which I-Regexp lacks, such as \d and (?i), are not, and cause AddPattern to fail.
+// It may not be combined with other values.
+func (c *Condition) Search(re string) *Condition {
+	return c.Regexp(searchToIRegexp(re))
+}
+
+// And combines c with other Conditions into a Pattern, which matches Events meeting all of them.
+func (c *Condition) And(others ...*Condition) *Pattern {
+	return And(append([]*Condition{c}, others...)...)
+}
+
+// JSON renders the Pattern made of just c.
+func (c *Condition) JSON() (string, error) {
+	return And(c).JSON()
+}
+
+// add appends a rendered value, exclusive being the kind of value it is if it may not be combined with
+// others
+func (c *Condition) add(exclusive, value string) {
+	if c.exclusive != "" || (exclusive != "" && len(c.values) > 0) {
+		kind := c.exclusive
+		if kind == "" {
+			kind = exclusive
+		}
+		c.fail(fmt.Errorf("%s cannot be combined with other values", kind))
+		return
+	}
+	c.exclusive = exclusive
+	c.values = append(c.values, value)
+}
+
+// fail records err, unless there's already an error
+func (c *Condition) fail(err error) {
+	if c.err == nil {
+		c.err = fmt.Errorf("field %s: %w", strings.Join(c.path, "."), err)
+	}
+}
+
+// Pattern is a set of Conditions, all of which an Event must meet to match.
+type Pattern struct {
+	conditions []*Condition
+}
+
+// And returns a Pattern matching Events which meet all the conditions.
+func And(conditions ...*Condition) *Pattern {
+	return &Pattern{conditions: conditions}
+}
+
+// And returns a Pattern which also requires the conditions.
+func (p *Pattern) And(conditions ...*Condition) *Pattern {
+	return &Pattern{conditions: append(slices.Clip(p.conditions), conditions...)}
+}
+
+// JSON renders the Pattern as the JSON text AddPattern expects, with the members of each object in order
+// of name, or returns the first problem found in building it.
+func (p *Pattern) JSON() (string, error) {
+	if len(p.conditions) == 0 {
+		return "", errors.New("empty pattern")
+	}
+	root := &node{}
+	for _, c := range p.conditions {
+		if c.err != nil {
+			return "", c.err
+		}
+		if len(c.values) == 0 {
+			return "", fmt.Errorf("field %s: no values", strings.Join(c.path, "."))
+		}
+		if err := root.add(c); err != nil {
+			return "", err
+		}
+	}
+	var buf bytes.Buffer
+	root.render(&buf)
+	return buf.String(), nil
+}
+
+// node is an object in the Pattern being rendered; each member is either another object or a field's values
+type node struct {
+	members map[string]*node
+	values  []string
+}
+
+func (n *node) add(c *Condition) error {
+	for i, name := range c.path {
+		if n.values != nil {
+			return fmt.Errorf("field %s is both a value and an object", strings.Join(c.path[:i], "."))
+		}
+		if n.members == nil {
+			n.members = make(map[string]*node)
+		}
+		next, ok := n.members[name]
+		if !ok {
+			next = &node{}
+			n.members[name] = next
+		}
+		n = next
+	}
+	if n.values != nil {
+		return fmt.Errorf("field %s has more than one condition", strings.Join(c.path, "."))
+	}
+	if n.members != nil {
+		return fmt.Errorf("field %s is both a value and an object", strings.Join(c.path, "."))
+	}
+	n.values = c.values
+	return nil
+}
+
+func (n *node) render(buf *bytes.Buffer) {
+	if n.values != nil {
+		buf.WriteString("[" + strings.Join(n.values, ",") + "]")
+		return
+	}
+	names := make([]string, 0, len(n.members))
+	for name := range n.members {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(quote(name) + ":")
+		n.members[name].render(buf)
+	}
+	buf.WriteByte('}')
+}
+
+// special renders an extended value, such as {"prefix":"a"}
+func special(kind, value string) string {
+	return `{"` + kind + `":` + value + "}"
+}
+
+// quote renders s as a JSON string. Unlike json.Marshal, it leaves <, >, and & alone, since the result is
+// for Quamina rather than a web page.
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s) // can't fail for a string
+	return strings.TrimSuffix(buf.String(), "\n")
+}
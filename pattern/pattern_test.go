@@ -0,0 +1,103 @@
+package pattern
+
+import (
+	"math"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		pattern *Pattern
+		want    string
+	}{
+		{Field("status").Equals("failed").And(
+			Field("request", "path").Shellstyle("/api/*"),
+			Field("retries").Number(0, 1.5),
+		), `{"request":{"path":[{"shellstyle":"/api/*"}]},"retries":[0,1.5],"status":["failed"]}`},
+		{And(Field("a").Equals(`say "hi" <&> \ ` + "\n")), `{"a":["say \"hi\" <&> \\ \n"]}`},
+		{And(Field("a", "b").Prefix("x"), Field("a", "c").Bool(true).Null()), `{"a":{"b":[{"prefix":"x"}],"c":[true,null]}}`},
+		{And(Field("a").Numeric("=", 500), Field("b").Number(1e21, -0.25)), `{"a":[500],"b":[1e+21,-0.25]}`},
+		{And(Field("a").Numeric(">=", 500).Numeric("<", 0.5)), `{"a":[{"numeric":[">=",500]},{"numeric":["<",0.5]}]}`},
+		{And(Field("a").Exists(false)), `{"a":[{"exists":false}]}`},
+		{And(Field("a").AnythingBut("x", "y")), `{"a":[{"anything-but":["x","y"]}]}`},
+		{And(Field("a").Wildcard(`*\**`).EqualsIgnoreCase("Ab")), `{"a":[{"wildcard":"*\\**"},{"equals-ignore-case":"Ab"}]}`},
+		{And(Field("a").ContainsIgnoreCase("Ab")), `{"a":[{"contains-ignore-case":"Ab"}]}`},
+		{And(Field("a").Regexp("a~.b")).And(Field("b").Equals("c")), `{"a":[{"regexp":"a~.b"}],"b":["c"]}`},
+		{And(Field("a").Search(`^a\.b`)), `{"a":[{"regexp":"(a~.b).*"}]}`},
+	}
+	for _, test := range tests {
+		got, err := test.pattern.JSON()
+		if err != nil {
+			t.Errorf("%s: %v", test.want, err)
+		} else if got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	bad := []*Pattern{
+		And(),
+		And(Field()),
+		And(Field("a")),
+		And(Field("a").Numeric("~", 500)),
+		And(Field("a").Numeric(">", math.Inf(-1))),
+		And(Field("a").Number(math.NaN())),
+		And(Field("a").Number(math.Inf(1))),
+		And(Field("a").AnythingBut()),
+		And(Field("a").Exists(true).Equals("x")),
+		And(Field("a").Equals("x").Regexp("y")),
+		And(Field("a").AnythingBut("x").AnythingBut("y")),
+		And(Field("a").Equals("x"), Field("a").Equals("y")),
+		And(Field("a").Equals("x"), Field("a", "b").Equals("y")),
+		And(Field("a", "b").Equals("y"), Field("a").Equals("x")),
+	}
+	for i, p := range bad {
+		if got, err := p.JSON(); err == nil {
+			t.Errorf("pattern %d rendered as %s", i, got)
+		}
+	}
+}
+
+// TestWithQuamina checks that the rendered Patterns are accepted by Quamina and match what they should
+func TestWithQuamina(t *testing.T) {
+	q, _ := quamina.New()
+	p, err := Field("status").Number(500, 503).And(
+		Field("request", "path").Shellstyle("/api/*"),
+		Field("user").Exists(false),
+	).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("errors", p); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = Field("name").Equals(`O"Brien`).JSON()
+	if err := q.AddPattern("quote", p); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = Field("code").Numeric(">=", 500).JSON()
+	if err := q.AddPattern("server", p); err != nil {
+		t.Fatal(err)
+	}
+
+	events := map[string]int{
+		`{"status": 503, "request": {"path": "/api/v1"}}`:                 1,
+		`{"status": 503.0, "request": {"path": "/api/v1"}, "user": "me"}`: 0,
+		`{"status": 200, "request": {"path": "/api/v1"}}`:                 0,
+		`{"name": "O\"Brien"}`:                                            1,
+		`{"code": 502}`:                                                   1,
+		`{"code": 404}`:                                                   0,
+	}
+	for event, want := range events {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(matches) != want {
+			t.Errorf("%s: matches %v", event, matches)
+		}
+	}
+}
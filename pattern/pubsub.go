@@ -0,0 +1,112 @@
+package pattern
+
+import (
+	"fmt"
+)
+
+// CompilePubSub compiles a Google Cloud Pub/Sub subscription filter into Quamina Patterns, so that
+// subscribers can evaluate the same filters client-side, for example to check messages that arrived before
+// a filter was added. For example,
+//
+//	attributes.type = "order" AND (attributes:priority OR hasPrefix(attributes.region, "eu-"))
+//
+// Conditions are combined with AND and OR, and grouped with parentheses. The conditions supported are:
+//
+//	attributes:key                   the message has the attribute
+//	attributes.key = "x"
+//	attributes.key != "x"            the attribute is absent or has another value
+//	hasPrefix(attributes.key, "x")
+//	NOT attributes:key               the message lacks the attribute
+//	NOT attributes.key = "x"         the same as !=
+//
+// Keys which aren't identifiers may be quoted, as in attributes."content-type". The Patterns match the
+// message in Pub/Sub's JSON form, with its attributes in the member named attributes, so attributes.type is
+// the field at the path "attributes", "type". Attribute values are always strings, and Pub/Sub has no
+// comparisons by order, so unlike the other languages there are no numeric ones. Quamina can't exclude
+// prefixes, so NOT hasPrefix is reported as an error, as is NOT before parentheses. As with CompileRule, a
+// filter may compile to more than one Pattern, all of which should be added with the same X value.
+func CompilePubSub(filter string) ([]string, error) {
+	tokens, err := lexer{quotes: `"`, identDash: true}.tokens(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, and: "AND", or: "OR", comparison: pubSubCondition}
+	return p.compile()
+}
+
+func pubSubCondition(p *parser) (*expr, error) {
+	not := p.peek()
+	negated := p.accept("NOT")
+	if negated && p.at("(") {
+		return nil, fmt.Errorf("at %d: NOT is only supported before a single condition", not.pos)
+	}
+
+	if t := p.peek(); p.isKeyword(t, "hasPrefix") {
+		p.take()
+		if negated {
+			return nil, fmt.Errorf("at %d: Quamina can't exclude prefixes, so NOT hasPrefix is not supported", t.pos)
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		key, err := pubSubAttribute(p, ".")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		prefix := p.take()
+		if prefix.kind != tokenString {
+			return nil, fmt.Errorf("at %d: expected a string, found %s", prefix.pos, describe(prefix))
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return &expr{condition: Field("attributes", key).Prefix(prefix.text)}, nil
+	}
+
+	if next := p.tokens[min(p.next+1, len(p.tokens)-1)]; next.kind == tokenPunct && next.text == ":" {
+		key, err := pubSubAttribute(p, ":")
+		if err != nil {
+			return nil, err
+		}
+		return &expr{condition: Field("attributes", key).Exists(!negated)}, nil
+	}
+
+	key, err := pubSubAttribute(p, ".")
+	if err != nil {
+		return nil, err
+	}
+	op := p.take()
+	if op.kind != tokenPunct || op.text != "=" && op.text != "!=" {
+		return nil, fmt.Errorf("at %d: expected = or !=, found %s", op.pos, describe(op))
+	}
+	value := p.take()
+	if value.kind != tokenString {
+		return nil, fmt.Errorf("at %d: expected a string, found %s", value.pos, describe(value))
+	}
+	if negated == (op.text == "=") {
+		// Pub/Sub's inequality also holds for messages without the attribute
+		return &expr{or: []*expr{
+			{condition: Field("attributes", key).AnythingBut(value.text)},
+			{condition: Field("attributes", key).Exists(false)},
+		}}, nil
+	}
+	return &expr{condition: Field("attributes", key).Equals(value.text)}, nil
+}
+
+// pubSubAttribute reads attributes followed by sep and a key, and returns the key
+func pubSubAttribute(p *parser, sep string) (string, error) {
+	if t := p.take(); t.kind != tokenIdent || t.text != "attributes" {
+		return "", fmt.Errorf("at %d: expected attributes, found %s", t.pos, describe(t))
+	}
+	if err := p.expect(sep); err != nil {
+		return "", err
+	}
+	key := p.take()
+	if key.kind != tokenIdent && key.kind != tokenString {
+		return "", fmt.Errorf("at %d: expected an attribute key, found %s", key.pos, describe(key))
+	}
+	return key.text, nil
+}
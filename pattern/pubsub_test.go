@@ -0,0 +1,42 @@
+package pattern
+
+import (
+	"testing"
+)
+
+func TestCompilePubSub(t *testing.T) {
+	testCompile(t, CompilePubSub, []compileTest{
+		{rule: `attributes.type = "order" AND (attributes:priority OR hasPrefix(attributes.region, "eu-"))`,
+			want: []string{
+				`{"attributes":{"priority":[{"exists":true}],"type":["order"]}}`,
+				`{"attributes":{"region":[{"prefix":"eu-"}],"type":["order"]}}`,
+			}},
+		{rule: `NOT attributes:debug AND attributes."content-type" = "json"`,
+			want: []string{`{"attributes":{"content-type":["json"],"debug":[{"exists":false}]}}`}},
+		{rule: `attributes.env != "test"`,
+			want: []string{`{"attributes":{"env":[{"anything-but":["test"]}]}}`, `{"attributes":{"env":[{"exists":false}]}}`}},
+		{rule: `NOT attributes.env = "test"`,
+			want: []string{`{"attributes":{"env":[{"anything-but":["test"]}]}}`, `{"attributes":{"env":[{"exists":false}]}}`}},
+		{rule: `NOT attributes.env != "prod"`, want: []string{`{"attributes":{"env":["prod"]}}`}},
+		{rule: `attributes.type = "order" AND (attributes.region != "us" OR hasPrefix(attributes.id, "x-"))`,
+			events: map[string]bool{
+				`{"data": "e30=", "attributes": {"type": "order", "region": "eu"}}`:              true,
+				`{"data": "e30=", "attributes": {"type": "order"}}`:                              true,
+				`{"data": "e30=", "attributes": {"type": "order", "region": "us", "id": "x-1"}}`: true,
+				`{"data": "e30=", "attributes": {"type": "order", "region": "us", "id": "y-1"}}`: false,
+				`{"data": "e30=", "attributes": {"type": "refund"}}`:                             false,
+			}},
+
+		{rule: `NOT (attributes:a OR attributes:b)`, err: "single condition"},
+		{rule: `NOT hasPrefix(attributes.a, "x")`, err: "can't exclude prefixes"},
+		{rule: `hasPrefix(attributes.a "x")`, err: "expected ,"},
+		{rule: `hasPrefix(attributes.a, 1)`, err: "expected a string"},
+		{rule: `attributes.a = 1`, err: "expected a string"},
+		{rule: `attributes.a > "x"`, err: "expected = or !="},
+		{rule: `data.a = "x"`, err: "expected attributes"},
+		{rule: `attributes:`, err: "expected an attribute key"},
+		{rule: `attributes`, err: "expected ."},
+		{rule: `attributes.a = "x" AND attributes.a = "y"`, err: "more than one condition"},
+		{rule: `attributes:a attributes:b`, err: `unexpected "attributes"`},
+	})
+}
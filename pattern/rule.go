@@ -0,0 +1,134 @@
+package pattern
+
+import (
+	"fmt"
+)
+
+// CompileRule compiles a rule written in a compact syntax meant for people writing rules in configuration
+// files, such as
+//
+//	status IN (500, 503) AND request.path ~ "/api/*" AND user NOT EXISTS
+//
+// into Quamina Patterns. A rule is made of comparisons combined with AND and OR, AND binding more tightly,
+// and grouped with parentheses. Each comparison names a field, by its member names separated by dots, and
+// is one of:
+//
+//	field = value                  the field has the value: a string, number, true, false, or null
+//	field != "s"                   anything-but: the field is a string other than "s"
+//	field IN (v1, v2, ...)         the field has any of the values
+//	field NOT IN ("s1", "s2", ...) anything-but, with several strings
+//	field < 5                      numeric, as are <=, >, and >=: the field is a number less than 5
+//	field ~ "glob"                 shellstyle: "*" matches any string
+//	field ^= "s"                   prefix: the field is a string starting with "s"
+//	field ~= "s"                   equals-ignore-case
+//	field =~ "re"                  regexp, in I-Regexp syntax with "~" as the escape character
+//	field EXISTS                   the field is present, with any value
+//	field NOT EXISTS               the field is absent
+//
+// Strings are in double quotes, with JSON's backslash escapes. A member name which isn't a simple
+// identifier, made of letters, digits, "_", "$", and "-", may be quoted. Keywords are matched regardless of
+// case.
+//
+// A Pattern can't express OR across different fields, so a rule may compile to more than one Pattern; the
+// rule matches an Event if any of them does, so all of them should be added with the same X value.
+func CompileRule(rule string) ([]string, error) {
+	tokens, err := lexer{quotes: `"`, identDash: true}.tokens(rule)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, and: "AND", or: "OR", comparison: ruleComparison}
+	return p.compile()
+}
+
+func ruleComparison(p *parser) (*expr, error) {
+	path, err := rulePath(p)
+	if err != nil {
+		return nil, err
+	}
+	c := Field(path...)
+	op := p.take()
+	switch {
+	case op.kind == tokenPunct && op.text == "=":
+		value := p.take()
+		switch {
+		case value.kind == tokenString:
+			c.Equals(value.text)
+		case value.kind == tokenNumber:
+			c.number(value.text)
+		case value.kind == tokenIdent && (value.text == "true" || value.text == "false"):
+			c.Bool(value.text == "true")
+		case value.kind == tokenIdent && value.text == "null":
+			c.Null()
+		default:
+			return nil, fmt.Errorf("at %d: expected a value, found %s", value.pos, describe(value))
+		}
+	case op.kind == tokenPunct && (op.text == "!=" || op.text == "~" || op.text == "^=" || op.text == "~=" || op.text == "=~"):
+		value := p.take()
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("at %d: %s needs a string, found %s", value.pos, op.text, describe(value))
+		}
+		switch op.text {
+		case "!=":
+			c.AnythingBut(value.text)
+		case "~":
+			c.Shellstyle(value.text)
+		case "^=":
+			c.Prefix(value.text)
+		case "~=":
+			c.EqualsIgnoreCase(value.text)
+		case "=~":
+			c.Regexp(value.text)
+		}
+	case isOrdering(op):
+		if err := compareNumber(p, c, op); err != nil {
+			return nil, err
+		}
+	case p.isKeyword(op, "EXISTS"):
+		c.Exists(true)
+	case p.isKeyword(op, "IN"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		values, err := p.literals(")")
+		if err != nil {
+			return nil, err
+		}
+		equalsAny(c, values)
+	case p.isKeyword(op, "NOT"):
+		if p.accept("EXISTS") {
+			c.Exists(false)
+			break
+		}
+		if err := p.expect("IN"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		values, err := p.literals(")")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := anythingBut(c, values); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("at %d: expected a comparison, found %s", op.pos, describe(op))
+	}
+	return &expr{condition: c}, nil
+}
+
+// rulePath reads the member names, identifiers or strings, separated by dots
+func rulePath(p *parser) ([]string, error) {
+	var path []string
+	for {
+		t := p.take()
+		if t.kind != tokenIdent && t.kind != tokenString {
+			return nil, fmt.Errorf("at %d: expected a field name, found %s", t.pos, describe(t))
+		}
+		path = append(path, t.text)
+		if !p.accept(".") {
+			return path, nil
+		}
+	}
+}
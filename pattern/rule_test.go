@@ -0,0 +1,63 @@
+package pattern
+
+import (
+	"testing"
+)
+
+func TestCompileRule(t *testing.T) {
+	testCompile(t, CompileRule, []compileTest{
+		{rule: `status = 500 AND request.path ~ "/api/*"`,
+			want: []string{`{"request":{"path":[{"shellstyle":"/api/*"}]},"status":[500]}`}},
+		{rule: `status IN (500, "x") and user not exists`,
+			want: []string{`{"status":[500,"x"],"user":[{"exists":false}]}`}},
+		{rule: `a = "x" OR b = true AND c = null`,
+			want: []string{`{"a":["x"]}`, `{"b":[true],"c":[null]}`}},
+		{rule: `(a = "x" OR b = "y") AND (c EXISTS OR d != "z")`,
+			want: []string{
+				`{"a":["x"],"c":[{"exists":true}]}`,
+				`{"a":["x"],"d":[{"anything-but":["z"]}]}`,
+				`{"b":["y"],"c":[{"exists":true}]}`,
+				`{"b":["y"],"d":[{"anything-but":["z"]}]}`,
+			}},
+		{rule: `"content-type" ^= "text/" AND name ~= "bob" AND id =~ "[0-9]+" AND x-y NOT IN ("a", "b\"")`,
+			want: []string{`{"content-type":[{"prefix":"text/"}],"id":[{"regexp":"[0-9]+"}],"name":[{"equals-ignore-case":"bob"}],"x-y":[{"anything-but":["a","b\""]}]}`}},
+		{rule: `a.b."c.d" = -1.5e3`, want: []string{`{"a":{"b":{"c.d":[-1500]}}}`}},
+		{rule: `status >= 500 AND path ~ "/api/*"`,
+			want: []string{`{"path":[{"shellstyle":"/api/*"}],"status":[{"numeric":[">=",500]}]}`},
+			events: map[string]bool{
+				`{"status": 503, "path": "/api/x"}`: true,
+				`{"status": 500, "path": "/api/"}`:  true,
+				`{"status": 499, "path": "/api/x"}`: false,
+				`{"status": 503, "path": "/web"}`:   false,
+			}},
+		{rule: `a < 1 AND b <= -2.5 AND c > 1e3`,
+			want: []string{`{"a":[{"numeric":["<",1]}],"b":[{"numeric":["<=",-2.5]}],"c":[{"numeric":[">",1000]}]}`}},
+		{rule: `status IN (500, 503) AND (path ~ "/api/*" OR retry = true)`,
+			events: map[string]bool{
+				`{"status": 503, "path": "/api/x"}`:               true,
+				`{"status": 500, "path": "/web", "retry": true}`:  true,
+				`{"status": 500, "path": "/api/", "retry": true}`: true,
+				`{"status": 200, "path": "/api/x"}`:               false,
+			}},
+
+		{rule: `status >= "500"`, err: ">= needs a number"},
+		{rule: `status > 1e400`, err: "bad number"},
+		{rule: `a = "x" AND a = "y"`, err: "more than one condition"},
+		{rule: `a = "x" AND`, err: "end of rule"},
+		{rule: `a = "x" b = "y"`, err: `unexpected "b"`},
+		{rule: `(a = "x"`, err: "expected )"},
+		{rule: `a = "x`, err: "unterminated"},
+		{rule: `a = x`, err: "expected a value"},
+		{rule: `a ~ 3`, err: "needs a string"},
+		{rule: `a NOT IN (1)`, err: "only exclude strings"},
+		{rule: `a IN ()`, err: "expected a string or number"},
+		{rule: `a # "x"`, err: "unexpected '#'"},
+		{rule: `a = "\q"`, err: "unknown escape"},
+		{rule: `a EXISTS AND a.b = 1`, err: "both a value and an object"},
+		{rule: `a NOT LIKE "x"`, err: "expected IN"},
+		{rule: `= 1`, err: "expected a field name"},
+		{rule: `a = 1 AND (b = 1 OR c = 1) AND (d = 1 OR e = 1) AND (f = 1 OR g = 1) AND (h = 1 OR i = 1) AND ` +
+			`(j = 1 OR k = 1) AND (l = 1 OR m = 1) AND (n = 1 OR o = 1) AND (p = 1 OR q = 1) AND (r = 1 OR s = 1)`,
+			err: "more than 256"},
+	})
+}
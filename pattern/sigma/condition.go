@@ -0,0 +1,146 @@
+package sigma
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// condition compiles a rule's condition, such as "selection and (1 of filter_*)", into the alternatives
+// which meet it
+func (c *compiler) condition(condition string) (alternatives, error) {
+	spaced := strings.NewReplacer("(", " ( ", ")", " ) ", "|", " | ").Replace(condition)
+	p := &conditionParser{c: c, words: strings.Fields(spaced)}
+	if len(p.words) == 0 {
+		return nil, errors.New("empty condition")
+	}
+	alts, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.words) {
+		return nil, p.unexpected()
+	}
+	return alts, nil
+}
+
+type conditionParser struct {
+	c     *compiler
+	words []string
+	next  int
+}
+
+func (p *conditionParser) peek() string {
+	if p.next == len(p.words) {
+		return ""
+	}
+	return p.words[p.next]
+}
+
+func (p *conditionParser) unexpected() error {
+	word := p.peek()
+	switch {
+	case word == "":
+		return errors.New("condition ends too soon")
+	case word == "|":
+		return errors.New("aggregations are not supported")
+	case strings.EqualFold(word, "not"):
+		return errors.New("Quamina can't negate a search, so not is not supported")
+	}
+	return fmt.Errorf("unexpected %q in condition", word)
+}
+
+func (p *conditionParser) accept(word string) bool {
+	if strings.EqualFold(p.peek(), word) {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) parseOr() (alternatives, error) {
+	alts, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		more, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, more...)
+		if len(alts) > maxPatterns {
+			return nil, fmt.Errorf("the condition needs more than %d Patterns", maxPatterns)
+		}
+	}
+	return alts, nil
+}
+
+func (p *conditionParser) parseAnd() (alternatives, error) {
+	alts, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		more, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if alts, err = and(alts, more); err != nil {
+			return nil, err
+		}
+	}
+	return alts, nil
+}
+
+func (p *conditionParser) parsePrimary() (alternatives, error) {
+	word := p.peek()
+	switch {
+	case p.accept("("):
+		alts, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.unexpected()
+		}
+		return alts, nil
+	case word == "1" || strings.EqualFold(word, "all"):
+		p.next++
+		if !p.accept("of") {
+			return nil, p.unexpected()
+		}
+		glob := p.peek()
+		if glob == "" || glob == "(" || glob == ")" || glob == "|" {
+			return nil, p.unexpected()
+		}
+		p.next++
+		names, err := p.c.matching(glob)
+		if err != nil {
+			return nil, err
+		}
+		var alts alternatives
+		for i, name := range names {
+			search, err := p.c.search(name)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case i == 0:
+				alts = search
+			case word == "1":
+				alts = append(alts, search...)
+			default:
+				if alts, err = and(alts, search); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return alts, nil
+	case word == "" || word == "(" || word == ")" || word == "|" || strings.EqualFold(word, "not") ||
+		strings.EqualFold(word, "and") || strings.EqualFold(word, "or"):
+		return nil, p.unexpected()
+	}
+	p.next++
+	return p.c.search(word)
+}
@@ -0,0 +1,583 @@
+// Package sigma converts Sigma detection rules (https://sigmahq.io) into Quamina Patterns, so that a security
+// pipeline can match each event against thousands of detections at once, with one Quamina instance.
+//
+// Sigma rules are YAML documents. Parse reads one or more of them and compiles the detection section of each
+// into a Rule, whose Patterns can be added to Quamina with AddTo:
+//
+//	rules, err := sigma.Parse(yamlText)
+//	...
+//	for _, rule := range rules {
+//		if err := rule.AddTo(q); err != nil {
+//			...
+//		}
+//	}
+//	matches, err := q.MatchesForEvent(event) // matches holds the *sigma.Rule values the event matched
+//
+// Only the string-matching subset of Sigma is supported, which covers most rules:
+//
+//   - Searches are maps, whose fields must all match, or lists of maps, any of which must. When a field is
+//     given a list of values, any of them may match.
+//   - Values are strings, in which * matches any string and ? any one character, and \ escapes those and
+//     itself; numbers, which match numerically; true and false; and null, which matches Events without the
+//     field.
+//   - The field modifiers contains, startswith, endswith, all (with contains), re, cased, and exists.
+//   - Conditions combining searches with and, or, and parentheses, including "1 of" and "all of" a search
+//     name pattern or "them".
+//
+// As Sigma requires, strings match regardless of case unless the cased modifier is given; where that can't be
+// done with an equals-ignore-case Pattern, or, for contains with only ASCII letters, a contains-ignore-case
+// Pattern, an equivalent regexp is built. The re modifier's regular
+// expressions find matches anywhere in the string, as with pattern.Condition.Search. Field names containing
+// dots are paths into nested objects.
+//
+// Quamina can't negate a search, so conditions using not are reported as errors, as are keyword searches
+// (lists of strings not tied to a field), aggregations, the other modifiers, and correlation rules. Before
+// conversion, a rule's field names usually need mapping to those of the Events, as for any Sigma backend.
+package sigma
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/pattern"
+)
+
+// Rule is a Sigma rule compiled into Quamina Patterns.
+type Rule struct {
+	ID        string
+	Title     string
+	Level     string
+	Tags      []string
+	Logsource Logsource
+	// Patterns are the Patterns for the rule's detection; an Event matches the rule if it matches any of them.
+	Patterns []string
+}
+
+// Logsource says what kind of log a Rule applies to. Quamina doesn't check it; an application could use it to
+// keep a Quamina instance for each source.
+type Logsource struct {
+	Category string
+	Product  string
+	Service  string
+}
+
+// AddTo adds the Rule's Patterns to q, with the Rule itself as their X value, so that MatchesForEvent
+// returns the Rules an Event matches.
+func (r *Rule) AddTo(q *quamina.Quamina) error {
+	for _, p := range r.Patterns {
+		if err := q.AddPattern(r, p); err != nil {
+			return fmt.Errorf("rule %s: %w", r.name(), err)
+		}
+	}
+	return nil
+}
+
+func (r *Rule) name() string {
+	if r.Title != "" {
+		return strconv.Quote(r.Title)
+	}
+	return r.ID
+}
+
+// maxPatterns limits how many Patterns a rule's condition may expand into
+const maxPatterns = 256
+
+// Parse reads the Sigma rules in text, YAML documents separated by "---" lines, and compiles them.
+func Parse(text []byte) ([]*Rule, error) {
+	docs, err := parseYAML(string(text))
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*Rule, 0, len(docs))
+	for _, doc := range docs {
+		rule := &Rule{ID: doc.get("id").str(), Title: doc.get("title").str(), Level: doc.get("level").str()}
+		if tags := doc.get("tags"); tags != nil {
+			for _, tag := range tags.items {
+				rule.Tags = append(rule.Tags, tag.str())
+			}
+		}
+		if ls := doc.get("logsource"); ls != nil {
+			rule.Logsource = Logsource{Category: ls.get("category").str(), Product: ls.get("product").str(), Service: ls.get("service").str()}
+		}
+		if rule.Patterns, err = compile(doc); err != nil {
+			if rule.Title == "" && rule.ID == "" {
+				return nil, fmt.Errorf("rule at line %d: %w", doc.line, err)
+			}
+			return nil, fmt.Errorf("rule %s: %w", rule.name(), err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// alternatives is an OR of ANDs of Conditions
+type alternatives [][]*pattern.Condition
+
+// and returns the alternatives which meet both a and b
+func and(a, b alternatives) (alternatives, error) {
+	if len(a)*len(b) > maxPatterns {
+		return nil, fmt.Errorf("the condition needs more than %d Patterns", maxPatterns)
+	}
+	var both alternatives
+	for _, x := range a {
+		for _, y := range b {
+			both = append(both, append(append([]*pattern.Condition{}, x...), y...))
+		}
+	}
+	return both, nil
+}
+
+// compile compiles the detection section of the rule doc
+func compile(doc *node) ([]string, error) {
+	if doc.get("action") != nil {
+		return nil, errors.New("rule collections are not supported")
+	}
+	if doc.get("correlation") != nil {
+		return nil, errors.New("correlation rules are not supported")
+	}
+	detection := doc.get("detection")
+	if detection == nil || detection.kind != mapNode {
+		return nil, errors.New("no detection")
+	}
+	c := &compiler{searches: map[string]*node{}, compiled: map[string]alternatives{}}
+	var conditions []string
+	for i, name := range detection.keys {
+		value := detection.values[i]
+		switch name {
+		case "condition":
+			if value.kind == listNode {
+				for _, item := range value.items {
+					conditions = append(conditions, item.str())
+				}
+			} else {
+				conditions = append(conditions, value.str())
+			}
+		case "timeframe":
+			return nil, errors.New("timeframe is not supported")
+		default:
+			c.names = append(c.names, name)
+			c.searches[name] = value
+		}
+	}
+	if len(conditions) == 0 {
+		return nil, errors.New("no condition")
+	}
+
+	// a list of conditions means any of them
+	var alts alternatives
+	for _, condition := range conditions {
+		a, err := c.condition(condition)
+		if err != nil {
+			return nil, err
+		}
+		alts = append(alts, a...)
+		if len(alts) > maxPatterns {
+			return nil, fmt.Errorf("the condition needs more than %d Patterns", maxPatterns)
+		}
+	}
+
+	var patterns []string
+	seen := map[string]bool{}
+	for _, conditions := range alts {
+		p, err := pattern.And(conditions...).JSON()
+		if err != nil {
+			return nil, err
+		}
+		if !seen[p] {
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, nil
+}
+
+type compiler struct {
+	names    []string // of the searches, in the order written
+	searches map[string]*node
+	compiled map[string]alternatives
+}
+
+// search compiles the named search
+func (c *compiler) search(name string) (alternatives, error) {
+	if alts, ok := c.compiled[name]; ok {
+		return alts, nil
+	}
+	n, ok := c.searches[name]
+	if !ok {
+		return nil, fmt.Errorf("no search named %q", name)
+	}
+	var alts alternatives
+	var err error
+	switch {
+	case n.kind == mapNode:
+		alts, err = searchMap(n)
+	case n.kind == listNode && len(n.items) > 0 && n.items[0].kind == mapNode:
+		for _, item := range n.items {
+			if item.kind != mapNode {
+				return nil, fmt.Errorf("search %s: line %d: expected a map of fields", name, item.line)
+			}
+			a, err := searchMap(item)
+			if err != nil {
+				return nil, fmt.Errorf("search %s: %w", name, err)
+			}
+			alts = append(alts, a...)
+		}
+	default:
+		return nil, fmt.Errorf("search %s: keyword searches are not supported; name the field to search", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search %s: %w", name, err)
+	}
+	c.compiled[name] = alts
+	return alts, nil
+}
+
+// matching returns the names of the searches matching glob, or all but those starting with "_" for "them"
+func (c *compiler) matching(glob string) ([]string, error) {
+	var names []string
+	for _, name := range c.names {
+		if glob == "them" {
+			if !strings.HasPrefix(name, "_") {
+				names = append(names, name)
+			}
+			continue
+		}
+		matched, err := path.Match(glob, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search name pattern %q", glob)
+		}
+		if matched {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no search matches %q", glob)
+	}
+	return names, nil
+}
+
+// searchMap compiles a map of fields, all of which must match
+func searchMap(m *node) (alternatives, error) {
+	if len(m.keys) == 0 {
+		return nil, fmt.Errorf("line %d: empty search", m.line)
+	}
+	alts := alternatives{{}}
+	for i, key := range m.keys {
+		field, err := searchField(key, m.values[i])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", m.values[i].line, err)
+		}
+		if alts, err = and(alts, field); err != nil {
+			return nil, err
+		}
+	}
+	return alts, nil
+}
+
+// modifiers are those given after a field name, as in CommandLine|contains|all
+type modifiers struct {
+	position string // contains, startswith, or endswith
+	all      bool
+	cased    bool
+	re       bool
+	exists   bool
+}
+
+func parseModifiers(names []string) (modifiers, error) {
+	var m modifiers
+	for _, name := range names {
+		switch name {
+		case "contains", "startswith", "endswith":
+			if m.position != "" {
+				return m, fmt.Errorf("modifiers %s and %s can't be combined", m.position, name)
+			}
+			m.position = name
+		case "all":
+			m.all = true
+		case "cased":
+			m.cased = true
+		case "re":
+			m.re = true
+		case "exists":
+			m.exists = true
+		default:
+			return m, fmt.Errorf("modifier %s is not supported", name)
+		}
+	}
+	if m.re && (m.position != "" || m.exists) || m.exists && (m.position != "" || m.all) {
+		return m, fmt.Errorf("modifiers %s can't be combined", strings.Join(names, "|"))
+	}
+	return m, nil
+}
+
+// searchField compiles the values given for a field, written with its modifiers as key
+func searchField(key string, value *node) (alternatives, error) {
+	names := strings.Split(key, "|")
+	if names[0] == "" {
+		return nil, errors.New("keyword searches are not supported; name the field to search")
+	}
+	mods, err := parseModifiers(names[1:])
+	if err != nil {
+		return nil, err
+	}
+	fieldPath := strings.Split(names[0], ".")
+	values := []*node{value}
+	if value.kind == listNode {
+		values = value.items
+	}
+	for _, v := range values {
+		if v.kind != scalarNode {
+			return nil, fmt.Errorf("field %s: expected a value", names[0])
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("field %s: no values", names[0])
+	}
+
+	switch {
+	case mods.exists:
+		if len(values) != 1 || values[0].quoted || (values[0].value != "true" && values[0].value != "false") {
+			return nil, fmt.Errorf("field %s: exists needs true or false", names[0])
+		}
+		return alternatives{{pattern.Field(fieldPath...).Exists(values[0].value == "true")}}, nil
+	case mods.re:
+		if mods.all && len(values) > 1 {
+			return nil, fmt.Errorf("field %s: all is only supported with contains", names[0])
+		}
+		// each regexp is its own alternative, since a field can have only one
+		var alts alternatives
+		for _, v := range values {
+			alts = append(alts, []*pattern.Condition{pattern.Field(fieldPath...).Search(v.value)})
+		}
+		return alts, nil
+	case mods.all && len(values) > 1:
+		if mods.position != "contains" {
+			return nil, fmt.Errorf("field %s: all is only supported with contains", names[0])
+		}
+		return containsAll(fieldPath, values, mods.cased)
+	}
+
+	// values which need a regexp are combined into one, and the rest into another Condition
+	plain := pattern.Field(fieldPath...)
+	plainCount := 0
+	var regexps []string
+	for _, v := range values {
+		switch {
+		case v.isNull():
+			plain.Exists(false)
+		case !v.quoted && mods.position == "" && (v.value == "true" || v.value == "false"):
+			plain.Bool(v.value == "true")
+		case !v.quoted && mods.position == "" && isNumber(v.value):
+			f, _ := strconv.ParseFloat(v.value, 64)
+			plain.Number(f)
+		default:
+			g := parseGlob(v.value)
+			switch mods.position {
+			case "contains":
+				g = g.star(true, true)
+			case "startswith":
+				g = g.star(false, true)
+			case "endswith":
+				g = g.star(true, false)
+			}
+			if !g.add(plain, mods.cased) {
+				regexps = append(regexps, g.regexp(!mods.cased))
+				continue
+			}
+		}
+		plainCount++
+	}
+
+	var alts alternatives
+	if plainCount > 0 {
+		alts = append(alts, []*pattern.Condition{plain})
+	}
+	if len(regexps) == 1 {
+		alts = append(alts, []*pattern.Condition{pattern.Field(fieldPath...).Regexp(regexps[0])})
+	} else if len(regexps) > 1 {
+		alts = append(alts, []*pattern.Condition{pattern.Field(fieldPath...).Regexp("(" + strings.Join(regexps, ")|(") + ")")})
+	}
+	return alts, nil
+}
+
+// maxContainsAll limits the values of contains|all, which are matched by a regexp with an alternative for
+// each order they might appear in
+const maxContainsAll = 4
+
+func containsAll(fieldPath []string, values []*node, cased bool) (alternatives, error) {
+	if len(values) > maxContainsAll {
+		return nil, fmt.Errorf("field %s: contains|all is limited to %d values", strings.Join(fieldPath, "."), maxContainsAll)
+	}
+	fold := false
+	fragments := make([]string, len(values))
+	for i, v := range values {
+		g := parseGlob(v.value)
+		fold = fold || (!cased && g.hasCase())
+		fragments[i] = g.regexp(false)
+	}
+	if fold {
+		for i, v := range values {
+			fragments[i] = parseGlob(v.value).regexp(true)
+		}
+	}
+	var orders []string
+	permute(fragments, 0, func(p []string) {
+		orders = append(orders, strings.Join(p, ".*"))
+	})
+	re := ".*(" + strings.Join(orders, "|") + ").*"
+	return alternatives{{pattern.Field(fieldPath...).Regexp(re)}}, nil
+}
+
+// permute calls f with each ordering of s[i:] after s[:i]
+func permute(s []string, i int, f func([]string)) {
+	if i == len(s) {
+		f(s)
+		return
+	}
+	for j := i; j < len(s); j++ {
+		s[i], s[j] = s[j], s[i]
+		permute(s, i+1, f)
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// isNumber reports whether s is a YAML decimal number
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil && strings.Trim(s, "0123456789+-.eE") == ""
+}
+
+// glob is a Sigma string value, in which * and ? are wildcards unless escaped
+type glob []globRune
+
+type globRune struct {
+	r    rune
+	wild bool // r is * or ?, as a wildcard
+}
+
+func parseGlob(s string) glob {
+	var g glob
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\' && i+1 < len(runes) && (runes[i+1] == '*' || runes[i+1] == '?' || runes[i+1] == '\\'):
+			i++
+			g = append(g, globRune{r: runes[i]})
+		case r == '*':
+			if len(g) == 0 || g[len(g)-1] != (globRune{'*', true}) {
+				g = append(g, globRune{r: r, wild: true})
+			}
+		case r == '?':
+			g = append(g, globRune{r: r, wild: true})
+		default:
+			g = append(g, globRune{r: r})
+		}
+	}
+	return g
+}
+
+// star adds * wildcards to the start and end of g, unless already there
+func (g glob) star(start, end bool) glob {
+	wild := globRune{'*', true}
+	if start && (len(g) == 0 || g[0] != wild) {
+		g = append(glob{wild}, g...)
+	}
+	if end && (len(g) == 0 || g[len(g)-1] != wild) {
+		g = append(g, wild)
+	}
+	return g
+}
+
+func (g glob) hasCase() bool {
+	for _, gr := range g {
+		if !gr.wild && unicode.SimpleFold(gr.r) != gr.r {
+			return true
+		}
+	}
+	return false
+}
+
+// hasOnlyASCIICase reports whether the only letters in g with other cases are ASCII letters, which are all that
+// contains-ignore-case Patterns fold
+func (g glob) hasOnlyASCIICase() bool {
+	for _, gr := range g {
+		if !gr.wild && gr.r >= utf8.RuneSelf && unicode.SimpleFold(gr.r) != gr.r {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds g to c if it can be matched without a regexp, which is when it has no ? wildcards and either
+// cased is true, case makes no difference, or it's a contains with no other wildcards and only ASCII letters
+// to fold
+func (g glob) add(c *pattern.Condition, cased bool) bool {
+	var literal, wildcard strings.Builder
+	stars := 0
+	for _, gr := range g {
+		if gr.wild {
+			if gr.r == '?' {
+				return false
+			}
+			stars++
+			wildcard.WriteRune('*')
+			continue
+		}
+		literal.WriteRune(gr.r)
+		if gr.r == '*' || gr.r == '\\' {
+			wildcard.WriteRune('\\')
+		}
+		wildcard.WriteRune(gr.r)
+	}
+	fold := !cased && g.hasCase()
+	switch {
+	case stars == 0 && fold:
+		c.EqualsIgnoreCase(literal.String())
+	case fold && stars == 2 && len(g) > 2 && g[0].wild && g[len(g)-1].wild && g.hasOnlyASCIICase():
+		c.ContainsIgnoreCase(literal.String())
+	case fold:
+		return false
+	case stars == 0:
+		c.Equals(literal.String())
+	case stars == 1 && g[len(g)-1].wild && len(g) > 1:
+		c.Prefix(literal.String())
+	default:
+		c.Wildcard(wildcard.String())
+	}
+	return true
+}
+
+// regexp returns an I-Regexp matching g, regardless of case if fold is true
+func (g glob) regexp(fold bool) string {
+	var sb strings.Builder
+	for _, gr := range g {
+		switch {
+		case gr.wild && gr.r == '*':
+			sb.WriteString(".*")
+		case gr.wild:
+			sb.WriteByte('.')
+		case fold && unicode.SimpleFold(gr.r) != gr.r:
+			sb.WriteByte('[')
+			for r := gr.r; ; {
+				sb.WriteRune(r)
+				if r = unicode.SimpleFold(r); r == gr.r {
+					break
+				}
+			}
+			sb.WriteByte(']')
+		default:
+			if strings.ContainsRune(".*+?()[]{}|~", gr.r) {
+				sb.WriteByte('~')
+			}
+			sb.WriteRune(gr.r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,214 @@
+package sigma
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+const whoami = `
+title: Whoami Execution
+id: e28a5a99-da44-436d-b7a0-2afc20a5f413
+status: test
+description: Detects the execution of whoami, which is often used by attackers
+    after exploitation to establish their privileges
+logsource:
+    category: process_creation
+    product: windows
+detection:
+    selection_img:
+        - Image|endswith: '\whoami.exe'
+        - OriginalFileName: 'whoami.exe'
+    filter_main:
+        User|cased: SYSTEM
+    condition: selection_img
+level: low
+tags:
+    - attack.discovery
+    - attack.t1033
+`
+
+func TestParse(t *testing.T) {
+	rules, err := Parse([]byte(whoami + "---\ntitle: second\ndetection:\n  s:\n    a: 1\n  condition: s\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("%d rules", len(rules))
+	}
+	r := rules[0]
+	if r.ID != "e28a5a99-da44-436d-b7a0-2afc20a5f413" || r.Title != "Whoami Execution" || r.Level != "low" ||
+		!slices.Equal(r.Tags, []string{"attack.discovery", "attack.t1033"}) ||
+		r.Logsource != (Logsource{Category: "process_creation", Product: "windows"}) {
+		t.Errorf("rule %+v", r)
+	}
+	want := []string{
+		`{"Image":[{"regexp":".*\\[wW][hH][oO][aA][mM][iI]~.[eE][xX][eE]"}]}`,
+		`{"OriginalFileName":[{"equals-ignore-case":"whoami.exe"}]}`,
+	}
+	if !slices.Equal(r.Patterns, want) {
+		t.Errorf("got %s", r.Patterns)
+	}
+}
+
+func TestDetections(t *testing.T) {
+	tests := []struct {
+		detection string
+		want      []string
+	}{
+		{`
+  sel:
+    EventID: [4688, 1]
+    Enabled: true
+    Parent.Name: null
+  condition: sel`,
+			[]string{`{"Enabled":[true],"EventID":[4688,1],"Parent":{"Name":[{"exists":false}]}}`}},
+		{`
+  sel:
+    Path|cased|startswith: 'C:\Temp\'
+    Name|cased: ['a*b\*c', '?x']
+    Code|contains: '12'
+    Key|exists: true
+  condition: sel`,
+			[]string{`{"Code":[{"wildcard":"*12*"}],"Key":[{"exists":true}],"Name":[{"wildcard":"a*b\\*c"}],"Path":[{"prefix":"C:\\Temp\\"}]}`,
+				`{"Code":[{"wildcard":"*12*"}],"Key":[{"exists":true}],"Name":[{"regexp":".x"}],"Path":[{"prefix":"C:\\Temp\\"}]}`}},
+		{`
+  sel:
+    CommandLine|contains:
+      - ' -enc '
+      - '(x)'
+      - 'Über'
+      - 'a?c'
+    Name: 'Ab'
+  condition: sel`,
+			[]string{`{"CommandLine":[{"contains-ignore-case":" -enc "},{"contains-ignore-case":"(x)"}],"Name":[{"equals-ignore-case":"Ab"}]}`,
+				`{"CommandLine":[{"regexp":"(.*[Üü][bB][eE][rR].*)|(.*[aA].[cC].*)"}],"Name":[{"equals-ignore-case":"Ab"}]}`}},
+		{`
+  sel:
+    CommandLine|contains|all: [a, '1']
+  condition: sel`,
+			[]string{`{"CommandLine":[{"regexp":".*([aA].*1|1.*[aA]).*"}]}`}},
+		{`
+  sel:
+    Url|re: '^https?://'
+  condition: sel`,
+			[]string{`{"Url":[{"regexp":"(https?://).*"}]}`}},
+		{`
+  sel_a:
+    a: 1
+  sel_b:
+    b: 2
+  _hidden:
+    c: 3
+  filter:
+    d: 4
+  condition: (1 of sel_*) and all of filter*`,
+			[]string{`{"a":[1],"d":[4]}`, `{"b":[2],"d":[4]}`}},
+		{`
+  sel_a:
+    a: 1
+  sel_b:
+    b: 2
+  _hidden:
+    c: 3
+  condition: all of them or _hidden`,
+			[]string{`{"a":[1],"b":[2]}`, `{"c":[3]}`}},
+		{`
+  a:
+    x: 1
+  b:
+    y: 2
+  condition:
+    - a
+    - b
+    - a`,
+			[]string{`{"x":[1]}`, `{"y":[2]}`}},
+	}
+	for _, test := range tests {
+		rules, err := Parse([]byte("title: t\ndetection:" + test.detection))
+		if err != nil {
+			t.Errorf("%s: %v", test.detection, err)
+		} else if !slices.Equal(rules[0].Patterns, test.want) {
+			t.Errorf("%s:\n got %s\nwant %s", test.detection, rules[0].Patterns, test.want)
+		}
+	}
+}
+
+func TestDetectionErrors(t *testing.T) {
+	bad := map[string]string{
+		"sel:\n    a: 1\n  filter:\n    b: 2\n  condition: sel and not filter": "not is not supported",
+		"sel:\n    a: 1\n  condition: sel | count() > 5":                       "aggregations",
+		"sel:\n    - foo\n    - bar\n  condition: sel":                         "keyword searches",
+		"sel:\n    '|contains': foo\n  condition: sel":                         "keyword searches",
+		"sel:\n    a|base64: foo\n  condition: sel":                            "modifier base64 is not supported",
+		"sel:\n    a|contains|endswith: foo\n  condition: sel":                 "can't be combined",
+		"sel:\n    a|startswith|all: [x, y]\n  condition: sel":                 "only supported with contains",
+		"sel:\n    a|contains|all: [p, q, r, s, t]\n  condition: sel":          "limited to 4",
+		"sel:\n    a|exists: yes\n  condition: sel":                            "exists needs true or false",
+		"sel:\n    a: 1\n  condition: sel and other":                           `no search named "other"`,
+		"sel:\n    a: 1\n  condition: 1 of x*":                                 `no search matches "x*"`,
+		"sel:\n    a: 1\n  condition: (sel":                                    "ends too soon",
+		"sel:\n    a: 1\n  condition: sel sel":                                 `unexpected "sel"`,
+		"sel:\n    a: 1\n  timeframe: 5m\n  condition: sel":                    "timeframe",
+		"sel:\n    a: [1, null]\n  condition: sel":                             "exists",
+		"sel:\n    a: 1\n": "no condition",
+	}
+	for detection, want := range bad {
+		_, err := Parse([]byte("title: t\ndetection:\n  " + detection))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", detection, err, want)
+		} else if !strings.HasPrefix(err.Error(), `rule "t": `) {
+			t.Errorf("%s: error %v doesn't name the rule", detection, err)
+		}
+	}
+	if _, err := Parse([]byte("title: t\n")); err == nil || !strings.Contains(err.Error(), "no detection") {
+		t.Errorf("no detection: %v", err)
+	}
+	if _, err := Parse([]byte("action: global\ntitle: t\n")); err == nil || !strings.Contains(err.Error(), "collections") {
+		t.Errorf("collection: %v", err)
+	}
+}
+
+func TestWithQuamina(t *testing.T) {
+	rules, err := Parse([]byte(whoami + `---
+title: Encoded PowerShell
+detection:
+    selection:
+        Image|endswith: '\powershell.exe'
+        CommandLine|contains|all:
+            - ' -enc'
+            - 'bypass'
+    condition: selection
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, _ := quamina.New()
+	for _, rule := range rules {
+		if err := rule.AddTo(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events := map[string][]string{
+		`{"Image": "C:\\Windows\\System32\\WHOAMI.EXE"}`:                                          {"Whoami Execution"},
+		`{"Image": "C:\\tools\\x.exe", "OriginalFileName": "WhoAmI.exe"}`:                         {"Whoami Execution"},
+		`{"Image": "C:\\Windows\\System32\\whoami.exe.bak"}`:                                      nil,
+		`{"Image": "C:\\Windows\\PowerShell.exe", "CommandLine": "powershell -EP Bypass -Enc x"}`: {"Encoded PowerShell"},
+		`{"Image": "C:\\Windows\\PowerShell.exe", "CommandLine": "powershell -ep bypass"}`:        nil,
+	}
+	for event, want := range events {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.(*Rule).Title)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: matched %v, want %v", event, got, want)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package sigma
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Quamina has no dependencies, so rather than import a YAML library, this file reads the subset of YAML that
+// Sigma rules are written in: block mappings and sequences, plain and quoted scalars, flow sequences of
+// scalars, and literal and folded block scalars. Anchors, aliases, tags, flow mappings, and quoted scalars
+// which span lines are reported as errors.
+
+type nodeKind int
+
+const (
+	scalarNode nodeKind = iota
+	mapNode
+	listNode
+)
+
+type node struct {
+	kind   nodeKind
+	line   int
+	value  string   // scalarNode
+	quoted bool     // scalarNode: the value was quoted, so it's a string whatever it looks like
+	keys   []string // mapNode, in the order written
+	values []*node  // mapNode, parallel to keys
+	items  []*node  // listNode
+}
+
+// get returns the value of key in a mapping, or nil
+func (n *node) get(key string) *node {
+	if n.kind != mapNode {
+		return nil
+	}
+	for i, k := range n.keys {
+		if k == key {
+			return n.values[i]
+		}
+	}
+	return nil
+}
+
+// str returns the value of a scalar, or "" for any other node
+func (n *node) str() string {
+	if n == nil || n.kind != scalarNode {
+		return ""
+	}
+	return n.value
+}
+
+func (n *node) isNull() bool {
+	if n.kind != scalarNode || n.quoted {
+		return false
+	}
+	switch n.value {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	}
+	return false
+}
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string // after the indentation
+}
+
+type yamlReader struct {
+	lines []yamlLine
+	next  int
+}
+
+// parseYAML reads the documents in src, which are separated by "---" lines
+func parseYAML(src string) ([]*node, error) {
+	var docs []*node
+	var lines []yamlLine
+	finish := func() error {
+		r := &yamlReader{lines: lines}
+		lines = nil
+		if _, ok := r.peek(); !ok {
+			return nil
+		}
+		doc, err := r.parseBlock(0)
+		if err != nil {
+			return err
+		}
+		if l, ok := r.peek(); ok {
+			return fmt.Errorf("line %d: unexpected indentation", l.number)
+		}
+		if doc.kind != mapNode {
+			return fmt.Errorf("line %d: a document must be a mapping", doc.line)
+		}
+		docs = append(docs, doc)
+		return nil
+	}
+	for i, text := range strings.Split(src, "\n") {
+		text = strings.TrimRight(text, " \r")
+		if text == "---" || strings.HasPrefix(text, "--- ") || text == "..." {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs can't be used for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// peek returns the next line which isn't blank or a comment
+func (r *yamlReader) peek() (yamlLine, bool) {
+	for r.next < len(r.lines) {
+		l := r.lines[r.next]
+		if l.text != "" && l.text[0] != '#' {
+			return l, true
+		}
+		r.next++
+	}
+	return yamlLine{}, false
+}
+
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseBlock reads the node starting at the next line, which is indented by indent
+func (r *yamlReader) parseBlock(indent int) (*node, error) {
+	l, _ := r.peek()
+	if isItem(l.text) {
+		return r.parseList(indent)
+	}
+	if _, _, ok, err := splitKey(l.text); err != nil {
+		return nil, fmt.Errorf("line %d: %w", l.number, err)
+	} else if ok {
+		return r.parseMap(indent)
+	}
+	r.next++
+	return scalar(l.text, l.number)
+}
+
+func (r *yamlReader) parseList(indent int) (*node, error) {
+	l, _ := r.peek()
+	n := &node{kind: listNode, line: l.number}
+	for {
+		l, ok := r.peek()
+		if !ok || l.indent < indent {
+			return n, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.number)
+		}
+		if !isItem(l.text) {
+			// a mapping's sequence value may be indented as much as its key, which this is the next of
+			return n, nil
+		}
+		var item *node
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			r.next++
+			next, ok := r.peek()
+			if !ok || next.indent <= indent {
+				item = &node{kind: scalarNode, line: l.number}
+			} else {
+				var err error
+				if item, err = r.parseBlock(next.indent); err != nil {
+					return nil, err
+				}
+			}
+		} else {
+			// the item starts on this line, at the column where rest begins
+			r.lines[r.next] = yamlLine{number: l.number, indent: indent + len(l.text) - len(rest), text: rest}
+			var err error
+			if item, err = r.parseBlock(r.lines[r.next].indent); err != nil {
+				return nil, err
+			}
+		}
+		n.items = append(n.items, item)
+	}
+}
+
+func (r *yamlReader) parseMap(indent int) (*node, error) {
+	l, _ := r.peek()
+	n := &node{kind: mapNode, line: l.number}
+	for {
+		l, ok := r.peek()
+		if !ok || l.indent < indent {
+			return n, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", l.number)
+		}
+		key, rest, ok, err := splitKey(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.number, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a key", l.number)
+		}
+		if n.get(key) != nil {
+			return nil, fmt.Errorf("line %d: duplicate key %q", l.number, key)
+		}
+		r.next++
+		value, err := r.parseValue(l, indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = append(n.keys, key)
+		n.values = append(n.values, value)
+	}
+}
+
+// parseValue reads the value of the key on line l, rest being what follows the key's colon
+func (r *yamlReader) parseValue(l yamlLine, indent int, rest string) (*node, error) {
+	if rest == "" {
+		next, ok := r.peek()
+		switch {
+		case ok && next.indent > indent:
+			return r.parseBlock(next.indent)
+		case ok && next.indent == indent && isItem(next.text):
+			return r.parseList(indent)
+		}
+		return &node{kind: scalarNode, line: l.number}, nil
+	}
+	if rest[0] == '|' || rest[0] == '>' {
+		return r.blockScalar(l, indent, rest), nil
+	}
+	n, err := scalar(rest, l.number)
+	if err != nil || n.kind != scalarNode || n.quoted {
+		return n, err
+	}
+	// a plain scalar continues on more-indented lines
+	for {
+		next, ok := r.peek()
+		if !ok || next.indent <= indent {
+			return n, nil
+		}
+		if _, _, isKey, _ := splitKey(next.text); isKey || isItem(next.text) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", next.number)
+		}
+		n.value += " " + plain(next.text)
+		r.next++
+	}
+}
+
+// blockScalar reads the lines of a literal (|) or folded (>) scalar
+func (r *yamlReader) blockScalar(l yamlLine, indent int, header string) *node {
+	var lines []string
+	contentIndent := -1
+	for ; r.next < len(r.lines); r.next++ {
+		next := r.lines[r.next]
+		if next.text == "" {
+			lines = append(lines, "")
+			continue
+		}
+		if next.indent <= indent {
+			break
+		}
+		if contentIndent < 0 {
+			contentIndent = next.indent
+		}
+		lines = append(lines, strings.Repeat(" ", max(next.indent-contentIndent, 0))+next.text)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	separator := "\n"
+	if header[0] == '>' {
+		separator = " "
+	}
+	value := strings.Join(lines, separator)
+	if !strings.Contains(header, "-") && value != "" {
+		value += "\n"
+	}
+	return &node{kind: scalarNode, line: l.number, value: value, quoted: true}
+}
+
+// splitKey splits a "key: value" line, reporting whether text starts with a key
+func splitKey(text string) (key, rest string, ok bool, err error) {
+	if text[0] == '\'' || text[0] == '"' {
+		end, err := quotedEnd(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		after := strings.TrimLeft(text[end:], " ")
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		k, err := scalar(text[:end], 0)
+		if err != nil {
+			return "", "", false, err
+		}
+		return k.value, strings.TrimSpace(stripComment(after[1:])), true, nil
+	}
+	if text[0] == '[' || text[0] == '{' || isItem(text) {
+		return "", "", false, nil
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			return "", "", false, nil
+		}
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimRight(text[:i], " "), strings.TrimSpace(stripComment(text[i+1:])), true, nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// quotedEnd returns the offset just past the quoted scalar at the start of text
+func quotedEnd(text string) (int, error) {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string %s; strings can't span lines", text)
+}
+
+// stripComment removes a trailing comment, unless text is quoted, in which case the quotes are trusted to
+// have been checked by scalar
+func stripComment(text string) string {
+	text = strings.TrimLeft(text, " ")
+	if text == "" || text[0] == '\'' || text[0] == '"' || text[0] == '[' {
+		return text
+	}
+	return plain(text)
+}
+
+// plain returns the plain scalar text, without any trailing comment
+func plain(text string) string {
+	if text[0] == '#' {
+		return ""
+	}
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimRight(text, " ")
+}
+
+// scalar reads text as a scalar or a flow sequence of scalars
+func scalar(text string, line int) (*node, error) {
+	n := &node{kind: scalarNode, line: line}
+	switch text[0] {
+	case '\'', '"':
+		end, err := quotedEnd(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if rest := strings.TrimLeft(text[end:], " "); rest != "" && rest[0] != '#' {
+			return nil, fmt.Errorf("line %d: unexpected %s after string", line, rest)
+		}
+		n.quoted = true
+		if text[0] == '\'' {
+			n.value = strings.ReplaceAll(text[1:end-1], "''", "'")
+		} else if n.value, err = strconv.Unquote(text[:end]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid string %s", line, text[:end])
+		}
+	case '[':
+		return flowSequence(text, line)
+	case '{', '&', '*', '!', '|', '>', '@', '`':
+		return nil, fmt.Errorf("line %d: YAML syntax %q is not supported", line, text[0])
+	default:
+		n.value = plain(text)
+	}
+	return n, nil
+}
+
+// flowSequence reads a one-line sequence of scalars such as [a, 'b', 3]
+func flowSequence(text string, line int) (*node, error) {
+	n := &node{kind: listNode, line: line}
+	rest := strings.TrimLeft(text[1:], " ")
+	for {
+		if rest == "" {
+			return nil, fmt.Errorf("line %d: unterminated list; lists in brackets can't span lines", line)
+		}
+		if rest[0] == ']' {
+			if after := strings.TrimLeft(rest[1:], " "); after != "" && after[0] != '#' {
+				return nil, fmt.Errorf("line %d: unexpected %s after list", line, after)
+			}
+			return n, nil
+		}
+		end := strings.IndexAny(rest, ",]")
+		if rest[0] == '\'' || rest[0] == '"' {
+			quoted, err := quotedEnd(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			end = quoted + strings.IndexAny(rest[quoted:], ",]")
+			if end < quoted {
+				end = -1
+			}
+		} else if rest[0] == '[' {
+			return nil, fmt.Errorf("line %d: nested lists are not supported", line)
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unterminated list; lists in brackets can't span lines", line)
+		}
+		itemText := strings.TrimSpace(rest[:end])
+		if itemText == "" {
+			return nil, fmt.Errorf("line %d: empty list item", line)
+		}
+		item, err := scalar(itemText, line)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+		if rest[end] == ',' {
+			end++
+		}
+		rest = strings.TrimLeft(rest[end:], " ")
+	}
+}
@@ -0,0 +1,109 @@
+package sigma
+
+import (
+	"strings"
+	"testing"
+)
+
+// render writes n in a compact form for comparison
+func render(n *node) string {
+	switch n.kind {
+	case mapNode:
+		parts := make([]string, len(n.keys))
+		for i, k := range n.keys {
+			parts[i] = k + ":" + render(n.values[i])
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	case listNode:
+		parts := make([]string, len(n.items))
+		for i, item := range n.items {
+			parts[i] = render(item)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	if n.quoted {
+		return "'" + n.value + "'"
+	}
+	return n.value
+}
+
+func TestParseYAML(t *testing.T) {
+	src := `# a comment
+title: Whoami: Execution   # trailing comment
+description: a plain scalar
+    which continues
+notes: |
+    line one
+      indented
+    line three
+folded: >-
+    one
+    two
+empty:
+detection:
+    selection:
+        Image|endswith: '\whoami.exe'
+        'Quoted|key': "tab\there"
+        EventID:
+            - 4688
+            - '4689'
+    other:
+      - a: 1
+        b: 'it''s'
+      -   c: [x, 'y, z', "w"]
+    condition: selection or other
+tags:
+- attack.t1033
+- - nested
+---
+title: second # not a key: here
+`
+	docs, err := parseYAML(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("%d documents", len(docs))
+	}
+	want := `{title:Whoami: Execution,description:a plain scalar which continues,` +
+		"notes:'line one\n  indented\nline three\n',folded:'one two',empty:," +
+		`detection:{selection:{Image|endswith:'\whoami.exe',Quoted|key:'tab` + "\t" + `here',EventID:[4688,'4689']},` +
+		`other:[{a:1,b:'it's'},{c:[x,'y, z','w']}],condition:selection or other},` +
+		`tags:[attack.t1033,[nested]]}`
+	if got := render(docs[0]); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := render(docs[1]); got != `{title:second}` {
+		t.Errorf("second document %s", got)
+	}
+	if !docs[0].get("empty").isNull() || docs[0].get("detection").get("selection").get("EventID").items[1].isNull() {
+		t.Error("isNull")
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	bad := map[string]string{
+		"a: 1\n  b: 2":          "line 2: unexpected indentation",
+		"a: 1\na: 2":            "duplicate key",
+		"a: 'x":                 "unterminated string",
+		"a: [x, y":              "unterminated list",
+		"a: [[x]]":              "nested lists",
+		"a: {b: 1}":             "not supported",
+		"a: &anchor x":          "not supported",
+		"a:\n\t- x":             "tabs",
+		"- x\n- y":              "must be a mapping",
+		"a:\n  - x\n  b: 1":     "line 3: unexpected indentation",
+		"a: 'x' y":              "unexpected y",
+		"a: \"\\q\"":            "invalid string",
+		"a:\n  b: 1\n c: 2":     "line 3: unexpected indentation",
+		"a: [x, , y]":           "empty list item",
+		"a: 1\nplain scalar\n":  "line 2: expected a key",
+		"a: 1\n---\n- x\n- y\n": "must be a mapping",
+	}
+	for src, want := range bad {
+		_, err := parseYAML(src)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error %v, want %q", src, err, want)
+		}
+	}
+}
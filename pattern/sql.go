@@ -0,0 +1,210 @@
+package pattern
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompileSQL compiles a filter written as a restricted SQL WHERE clause, in the style of the message selectors
+// and subscription filters that several message brokers offer, into Quamina Patterns, to ease moving such
+// filters into Quamina. For example,
+//
+//	eventType IN ('order', 'refund') AND region <> 'test' AND customer.tier IS NOT NULL
+//
+// Comparisons are combined with AND and OR and grouped with parentheses. The comparisons supported are:
+//
+//	field = value              value is a string in single quotes, a number, TRUE, or FALSE
+//	field <> 'x'               also written !=; anything-but
+//	field < 5                  numeric, as are <=, >, and >=
+//	field BETWEEN 1 AND 5      numeric, including both ends
+//	field IN (v1, v2, ...)
+//	field NOT IN ('x', ...)    anything-but, with several strings
+//	field LIKE 'pattern'       % matches any string; ESCAPE 'c' may follow, making c escape % in the pattern
+//	field IS NULL              the field is absent, as in message selectors
+//	field IS NOT NULL          the field is present
+//
+// Fields are named by their member names separated by dots; a name may be quoted with double quotes. Keywords
+// are matched regardless of case. Quamina can't express the rest of SQL, so NOT BETWEEN, NOT LIKE, LIKE
+// patterns using _, arithmetic, comparisons of strings by order, and NOT other than in the forms above are
+// reported as errors, as is comparison with NULL by = or <>. Note that a field whose value is JSON null is
+// present, so IS NULL doesn't match it.
+//
+// As with CompileRule, a filter may compile to more than one Pattern, all of which should be added with the
+// same X value.
+func CompileSQL(where string) ([]string, error) {
+	tokens, err := lexer{sqlQuotes: true}.tokens(where)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, and: "AND", or: "OR", comparison: sqlComparison}
+	return p.compile()
+}
+
+func sqlComparison(p *parser) (*expr, error) {
+	if t := p.peek(); p.isKeyword(t, "NOT") {
+		return nil, fmt.Errorf("at %d: NOT is only supported as NOT IN and IS NOT NULL; use <> for inequality", t.pos)
+	}
+	path, err := sqlPath(p)
+	if err != nil {
+		return nil, err
+	}
+	c := Field(path...)
+	op := p.take()
+	switch {
+	case op.kind == tokenPunct && op.text == "=":
+		value := p.take()
+		switch {
+		case value.kind == tokenString:
+			c.Equals(value.text)
+		case value.kind == tokenNumber:
+			c.number(value.text)
+		case p.isKeyword(value, "TRUE") || p.isKeyword(value, "FALSE"):
+			c.Bool(strings.EqualFold(value.text, "TRUE"))
+		case p.isKeyword(value, "NULL"):
+			return nil, fmt.Errorf("at %d: use IS NULL rather than = NULL", value.pos)
+		default:
+			return nil, fmt.Errorf("at %d: expected a value, found %s", value.pos, describe(value))
+		}
+	case op.kind == tokenPunct && (op.text == "<>" || op.text == "!="):
+		value := p.take()
+		if value.kind != tokenString {
+			return nil, fmt.Errorf("at %d: Quamina can only exclude strings", value.pos)
+		}
+		c.AnythingBut(value.text)
+	case isOrdering(op):
+		if err := compareNumber(p, c, op); err != nil {
+			return nil, err
+		}
+	case p.isKeyword(op, "BETWEEN"):
+		low, err := p.number("BETWEEN")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("AND"); err != nil {
+			return nil, err
+		}
+		high, err := p.number("BETWEEN")
+		if err != nil {
+			return nil, err
+		}
+		if low > high {
+			return nil, fmt.Errorf("at %d: BETWEEN %v AND %v contains no numbers", op.pos, low, high)
+		}
+		c.add("", numeric(">=", low, "<=", high))
+	case p.isKeyword(op, "IN"):
+		values, err := sqlList(p)
+		if err != nil {
+			return nil, err
+		}
+		equalsAny(c, values)
+	case p.isKeyword(op, "LIKE"):
+		if err := sqlLike(p, c); err != nil {
+			return nil, err
+		}
+	case p.isKeyword(op, "IS"):
+		exists := p.accept("NOT")
+		if err := p.expect("NULL"); err != nil {
+			return nil, err
+		}
+		c.Exists(exists)
+	case p.isKeyword(op, "NOT"):
+		if p.at("LIKE") {
+			return nil, fmt.Errorf("at %d: NOT LIKE is not supported", op.pos)
+		}
+		if err := p.expect("IN"); err != nil {
+			return nil, err
+		}
+		values, err := sqlList(p)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := anythingBut(c, values); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("at %d: expected a comparison, found %s", op.pos, describe(op))
+	}
+	return &expr{condition: c}, nil
+}
+
+// sqlPath reads the member names, identifiers which aren't keywords or quoted identifiers, separated by dots
+func sqlPath(p *parser) ([]string, error) {
+	var path []string
+	for {
+		t := p.take()
+		if t.kind != tokenIdent || p.isKeyword(t, "AND") || p.isKeyword(t, "OR") || p.isKeyword(t, "NOT") {
+			return nil, fmt.Errorf("at %d: expected a field name, found %s", t.pos, describe(t))
+		}
+		path = append(path, t.text)
+		if !p.accept(".") {
+			return path, nil
+		}
+	}
+}
+
+// sqlList reads a parenthesized list of literals
+func sqlList(p *parser) ([]token, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	return p.literals(")")
+}
+
+// sqlLike adds the LIKE pattern which follows to c: an exact match if it has no %, a prefix if its only %
+// is at the end, and otherwise a wildcard, with * and \ escaped and each run of % becoming one *
+func sqlLike(p *parser, c *Condition) error {
+	t := p.take()
+	if t.kind != tokenString {
+		return fmt.Errorf("at %d: LIKE needs a string, found %s", t.pos, describe(t))
+	}
+	escape := -1
+	if p.accept("ESCAPE") {
+		e := p.take()
+		if e.kind != tokenString || len(e.text) != 1 {
+			return fmt.Errorf("at %d: ESCAPE needs a one-character string", e.pos)
+		}
+		escape = int(e.text[0])
+	}
+
+	var literal, wildcard strings.Builder
+	stars := 0
+	trailingStar := false
+	for i := 0; i < len(t.text); i++ {
+		ch := t.text[i]
+		switch {
+		case int(ch) == escape:
+			i++
+			if i == len(t.text) {
+				return fmt.Errorf("at %d: LIKE pattern ends with its escape character", t.pos)
+			}
+			ch = t.text[i]
+		case ch == '_':
+			return fmt.Errorf("at %d: LIKE's _ is not supported", t.pos)
+		case ch == '%':
+			if !trailingStar {
+				wildcard.WriteByte('*')
+				stars++
+			}
+			trailingStar = true
+			continue
+		}
+		trailingStar = false
+		literal.WriteByte(ch)
+		if ch == '*' || ch == '\\' {
+			wildcard.WriteByte('\\')
+		}
+		wildcard.WriteByte(ch)
+	}
+
+	switch {
+	case stars == 0:
+		c.Equals(literal.String())
+	case stars == 1 && trailingStar && literal.Len() > 0:
+		c.Prefix(literal.String())
+	case literal.Len() == 0:
+		return fmt.Errorf("at %d: LIKE '%%' matches any string; use IS NOT NULL", t.pos)
+	default:
+		c.Wildcard(wildcard.String())
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package pattern
+
+import (
+	"testing"
+)
+
+func TestCompileSQL(t *testing.T) {
+	testCompile(t, CompileSQL, []compileTest{
+		{rule: `eventType IN ('order', 'refund') AND region <> 'test' AND customer.tier IS NOT NULL`,
+			want: []string{`{"customer":{"tier":[{"exists":true}]},"eventType":["order","refund"],"region":[{"anything-but":["test"]}]}`}},
+		{rule: `a = 'it''s' or b = TRUE and "select" is null`,
+			want: []string{`{"a":["it's"]}`, `{"b":[true],"select":[{"exists":false}]}`}},
+		{rule: `a NOT IN ('x', 'y') AND b = -2 AND c != 'z'`,
+			want: []string{`{"a":[{"anything-but":["x","y"]}],"b":[-2],"c":[{"anything-but":["z"]}]}`}},
+		{rule: `a LIKE 'abc'`, want: []string{`{"a":["abc"]}`}},
+		{rule: `a LIKE '/api/%%'`, want: []string{`{"a":[{"prefix":"/api/"}]}`}},
+		{rule: `a LIKE '%.jpg'`, want: []string{`{"a":[{"wildcard":"*.jpg"}]}`}},
+		{rule: `a LIKE '%*\%' `, want: []string{`{"a":[{"wildcard":"*\\*\\\\*"}]}`}},
+		{rule: `a LIKE '100!%%' ESCAPE '!'`, want: []string{`{"a":[{"prefix":"100%"}]}`}},
+		{rule: `(a = 1 OR a = 2) AND b = FALSE`, want: []string{`{"a":[1],"b":[false]}`, `{"a":[2],"b":[false]}`}},
+		{rule: `price > 10 AND qty BETWEEN 1 AND 5`,
+			want: []string{`{"price":[{"numeric":[">",10]}],"qty":[{"numeric":[">=",1,"<=",5]}]}`},
+			events: map[string]bool{
+				`{"price": 10.5, "qty": 1}`: true,
+				`{"price": 99, "qty": 5}`:   true,
+				`{"price": 10, "qty": 3}`:   false,
+				`{"price": 11, "qty": 6}`:   false,
+				`{"price": 11, "qty": "3"}`: false,
+			}},
+		{rule: `kind = 'img' AND (name LIKE '%.jpg' OR name LIKE 'thumb%') AND owner IS NULL`,
+			events: map[string]bool{
+				`{"kind": "img", "name": "cat.jpg"}`:                 true,
+				`{"kind": "img", "name": "thumb-cat.jpg"}`:           true,
+				`{"kind": "img", "name": "thumb.png"}`:               true,
+				`{"kind": "img", "name": "cat.png"}`:                 false,
+				`{"kind": "img", "name": "cat.jpg", "owner": "bob"}`: false,
+			}},
+
+		{rule: `price > '10'`, err: "> needs a number"},
+		{rule: `price BETWEEN 1 OR 2`, err: "expected AND"},
+		{rule: `price BETWEEN 5 AND 1`, err: "contains no numbers"},
+		{rule: `price NOT BETWEEN 1 AND 2`, err: "expected IN"},
+		{rule: `a = NULL`, err: "IS NULL"},
+		{rule: `NOT a = 'x'`, err: "use <>"},
+		{rule: `a NOT LIKE 'x%'`, err: "NOT LIKE"},
+		{rule: `a LIKE 'x_y'`, err: "_ is not supported"},
+		{rule: `a LIKE '%'`, err: "IS NOT NULL"},
+		{rule: `a LIKE 'x!' ESCAPE '!'`, err: "ends with its escape"},
+		{rule: `a LIKE 'x' ESCAPE '!!'`, err: "one-character"},
+		{rule: `a <> 1`, err: "only exclude strings"},
+		{rule: `a IS 'x'`, err: "expected NULL"},
+		{rule: `a = 'x' AND AND b = 'y'`, err: "expected a field name"},
+		{rule: `a = 'x`, err: "unterminated"},
+		{rule: `a = "x"`, err: "expected a value"},
+		{rule: `a IN ('x', b)`, err: "expected a string or number"},
+		{rule: `a + 1 = 2`, err: `unexpected '+'`},
+		{rule: `a = 'x' AND b = 'y' AND a = 'z'`, err: "more than one condition"},
+	})
+}
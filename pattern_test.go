@@ -55,6 +55,7 @@ func TestPatternFromJSON(t *testing.T) {
 		`{"xxx": [ [ 22 ] }`,
 		`{"xxx": [ {"x": 1} ]`,
 		`{"xxx": [ { [`,
+		`{"xxx": [ { } ] }`,
 		`{"xxx": [ { "exists": 23 } ] }`,
 		`{"xxx": [ { "exists": true }, 15 ] }`,
 		`{"xxx": [ { "exists": true, "a": 3 }] }`,
@@ -104,20 +105,20 @@ func TestPatternFromJSON(t *testing.T) {
 	}
 	w1 := []*patternField{{path: "x", vals: []typedVal{{vType: numberType, val: "2"}}}}
 	w2 := []*patternField{{path: "x", vals: []typedVal{
-		{literalType, "null", nil, nil},
-		{literalType, "true", nil, nil},
-		{literalType, "false", nil, nil},
-		{stringType, `"hopp"`, nil, nil},
-		{numberType, "3.072e-11", nil, nil},
+		{vType: literalType, val: "null"},
+		{vType: literalType, val: "true"},
+		{vType: literalType, val: "false"},
+		{vType: stringType, val: `"hopp"`},
+		{vType: numberType, val: "3.072e-11"},
 	}}}
 	w3 := []*patternField{
 		{path: "x\na", vals: []typedVal{
-			{numberType, "27", nil, nil},
-			{numberType, "28", nil, nil},
+			{vType: numberType, val: "27"},
+			{vType: numberType, val: "28"},
 		}},
 		{path: "x\nb\nm", vals: []typedVal{
-			{stringType, `"a"`, nil, nil},
-			{stringType, `"b"`, nil, nil},
+			{vType: stringType, val: `"a"`},
+			{vType: stringType, val: `"b"`},
 		}},
 	}
 	w4 := []*patternField{
@@ -0,0 +1,77 @@
+package quamina
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// Match is one of the matches MatchesForEventWithPatterns returns: the X of the Patterns that match, with the
+// text of each Pattern that was added with that X, as it was given to AddPattern. Since matches are found by
+// X, if several Patterns were added with the same X, all of them are reported, not just those that match.
+// MatchesForEventWithAnnotations also reports the annotations made by the X's Enricher.
+type Match struct {
+	X           X
+	Patterns    []string
+	Annotations map[string]string
+}
+
+// WithPatternText arranges, if the argument is true, that the instance keeps the text of the Patterns added
+// to it, so that MatchesForEventWithPatterns can report them; downstream systems can then log exactly which
+// rule fired without looking it up. The text is kept until the Patterns are deleted with DeletePatterns, and
+// it's shared with instances created with Copy. This option call may not be provided more than once.
+func WithPatternText(b bool) Option {
+	return func(q *Quamina) error {
+		if q.patternTextSpecified {
+			return errors.New("pattern text already specified")
+		}
+		q.patternTextSpecified = true
+		if b {
+			q.patternTexts = &patternTexts{texts: make(map[X][]string)}
+		}
+		return nil
+	}
+}
+
+// MatchesForEventWithPatterns is like MatchesForEvent, but reports the text of the matching Patterns along
+// with their X values. It needs an instance created WithPatternText(true). Unlike MatchesForEvent's, the
+// returned slice belongs to the caller, though the Patterns slices in it are shared and mustn't be changed.
+func (q *Quamina) MatchesForEventWithPatterns(event []byte) ([]Match, error) {
+	if q.patternTexts == nil {
+		return nil, errors.New("pattern text is only kept by instances created WithPatternText(true)")
+	}
+	xs, err := q.MatchesForEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Match, len(xs))
+	q.patternTexts.lock.RLock()
+	for i, x := range xs {
+		matches[i] = Match{X: x, Patterns: q.patternTexts.texts[x]}
+	}
+	q.patternTexts.lock.RUnlock()
+	return matches, nil
+}
+
+// patternTexts are the texts of the Patterns added to an instance, and to its copies, by X
+type patternTexts struct {
+	lock  sync.RWMutex
+	texts map[X][]string
+}
+
+func (pt *patternTexts) add(x X, pattern string) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	texts := pt.texts[x]
+	if slices.Contains(texts, pattern) {
+		return
+	}
+	// the slices are handed out by MatchesForEventWithPatterns, so they're replaced rather than appended to
+	pt.texts[x] = append(slices.Clip(texts), pattern)
+}
+
+func (pt *patternTexts) delete(x X) {
+	pt.lock.Lock()
+	delete(pt.texts, x)
+	pt.lock.Unlock()
+}
@@ -0,0 +1,81 @@
+package quamina
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMatchesForEventWithPatterns(t *testing.T) {
+	q, err := New(WithPatternText(true), WithPatternDeletion(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paid := `{"status": ["paid"]}`
+	shipped := `{"status": ["shipped"]}`
+	for _, p := range []struct {
+		x       X
+		pattern string
+	}{{"billing", paid}, {"billing", paid}, {"shipping", shipped}} {
+		if err := q.AddPattern(p.x, p.pattern); err != nil {
+			t.Fatalf("%s: %v", p.pattern, err)
+		}
+	}
+	// Patterns which can't be added aren't kept
+	if err := q.AddPattern("billing", `{"status": "paid"}`); err == nil {
+		t.Error("bad Pattern accepted")
+	}
+	// the copy shares the instance's texts
+	copied := q.Copy()
+	got, err := copied.MatchesForEventWithPatterns([]byte(`{"status": "paid", "amount": 5}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].X != "billing" || !slices.Equal(got[0].Patterns, []string{paid}) {
+		t.Errorf("got %+v", got)
+	}
+
+	if err := q.DeletePatterns("billing"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = q.MatchesForEventWithPatterns([]byte(`{"status": "paid"}`))
+	if len(got) != 0 {
+		t.Errorf("deleted, got %+v", got)
+	}
+	if len(q.patternTexts.texts) != 1 {
+		t.Errorf("texts %v", q.patternTexts.texts)
+	}
+	got, _ = q.MatchesForEventWithPatterns([]byte(`{"status": "shipped"}`))
+	if len(got) != 1 || !slices.Equal(got[0].Patterns, []string{shipped}) {
+		t.Errorf("got %+v", got)
+	}
+
+	if _, err := q.MatchesForEventWithPatterns([]byte(`{`)); err == nil {
+		t.Error("accepted bad JSON")
+	}
+	plain, _ := New()
+	if _, err := plain.MatchesForEventWithPatterns([]byte(`{}`)); err == nil {
+		t.Error("matched without WithPatternText")
+	}
+	if _, err := New(WithPatternText(true), WithPatternText(false)); err == nil {
+		t.Error("WithPatternText accepted twice")
+	}
+}
+
+func TestMatchesForEventWithPatternsKeepsResults(t *testing.T) {
+	q, _ := New(WithPatternText(true), WithEventBridgeCompat())
+	first := `{"a": ["x"]}`
+	_ = q.AddPattern("p", first)
+	got, _ := q.MatchesForEventWithPatterns([]byte(`{"a": "x"}`))
+	// adding another Pattern with the same X doesn't change what was reported already
+	second := `{"a": [{"numeric": [">", 1]}]}`
+	if err := q.AddPattern("p", second); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got[0].Patterns, []string{first}) {
+		t.Errorf("got %v", got[0].Patterns)
+	}
+	got, _ = q.MatchesForEventWithPatterns([]byte(`{"a": 2}`))
+	if len(got) != 1 || !slices.Equal(got[0].Patterns, []string{first, second}) {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -0,0 +1,80 @@
+package quamina
+
+import (
+	"errors"
+)
+
+// soundexCode is the four-character American Soundex code of a name, such as "S530" for "Smith" and "Smyth"
+type soundexCode [4]byte
+
+type phoneticTest struct {
+	code soundexCode
+	next *fieldMatcher
+}
+
+// soundexDigits gives the Soundex digit for each of the letters A to Z. Vowels, and Y, are '0', which keeps
+// letters on either side with the same digit from being coded as one; H and W are '-', which doesn't.
+const soundexDigits = "0123012-02245501262301-202"
+
+// readSoundsLikeSpecial reads a "soundslike" pattern, whose value is a name. It's matched by comparing its Soundex
+// code with those of Event values, so the name has to have a letter for there to be a code.
+func readSoundsLikeSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	pathVals = valsIn
+
+	name, ok := t.(string)
+	if !ok {
+		err = errors.New("value for 'soundslike' must be a string")
+		return
+	}
+	if _, ok = soundex([]byte(name)); !ok {
+		err = errors.New("value for 'soundslike' must contain a letter")
+		return
+	}
+	pathVals = append(pathVals, typedVal{vType: soundsLikeType, val: `"` + name + `"`})
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
+
+// soundex computes the American Soundex code of name: its first letter, then the digits for the letters
+// after it, skipping repeats, up to three of them and padded with '0'. Only the ASCII letters count; everything
+// else is skipped. If there are no letters, there's no code.
+func soundex(name []byte) (code soundexCode, ok bool) {
+	n := 0
+	var last byte
+	for _, b := range name {
+		if b >= 'a' && b <= 'z' {
+			b -= 'a' - 'A'
+		}
+		if b < 'A' || b > 'Z' {
+			continue
+		}
+		digit := soundexDigits[b-'A']
+		switch {
+		case n == 0:
+			code[0] = b
+			n = 1
+		case digit == '-':
+			continue
+		case digit != '0' && digit != last:
+			code[n] = digit
+			n++
+			if n == len(code) {
+				return code, true
+			}
+		}
+		last = digit
+	}
+	if n == 0 {
+		return code, false
+	}
+	for ; n < len(code); n++ {
+		code[n] = '0'
+	}
+	return code, true
+}
@@ -0,0 +1,75 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestSoundex(t *testing.T) {
+	for name, want := range map[string]string{
+		"Robert":    "R163",
+		"Rupert":    "R163",
+		"Rubin":     "R150",
+		"Ashcraft":  "A261",
+		"Ashcroft":  "A261",
+		"Tymczak":   "T522",
+		"Pfister":   "P236",
+		"Honeyman":  "H555",
+		"Smith":     "S530",
+		"smyth":     "S530",
+		"Lee":       "L000",
+		"O'Brien":   "O165",
+		"A":         "A000",
+		"  Jackson": "J250",
+		"Müller":    "M460",
+	} {
+		code, ok := soundex([]byte(name))
+		if !ok || string(code[:]) != want {
+			t.Errorf("%s: got %s, %v", name, code[:], ok)
+		}
+	}
+	for _, name := range []string{"", "123", "ü-ß"} {
+		if _, ok := soundex([]byte(name)); ok {
+			t.Errorf("%q has a code", name)
+		}
+	}
+}
+
+func TestSoundsLikePattern(t *testing.T) {
+	q, _ := New()
+	patterns := map[X]string{
+		"smith":  `{"lastName": [{"soundslike": "Smith"}]}`,
+		"robert": `{"firstName": [{"soundslike": "Robert"}, "Bob"]}`,
+		"both":   `{"firstName": [{"soundslike": "Rupert"}], "lastName": [{"soundslike": "Smyth"}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	for event, want := range map[string][]X{
+		`{"lastName": "Smythe"}`:                         {"smith"},
+		`{"lastName": "SCHMIDT"}`:                        {"smith"},
+		`{"lastName": "Snow"}`:                           {},
+		`{"lastName": "Smith", "firstName": "Rupert"}`:   {"smith", "robert", "both"},
+		`{"firstName": "Bob"}`:                           {"robert"},
+		`{"firstName": ["Al", "Robbert"]}`:               {"robert"},
+		`{"firstName": "123", "lastName": 12}`:           {},
+		`{"firstName": "R", "lastName": "Snead, Smith"}`: {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"x": [{"soundslike": ""}]}`,
+		`{"x": [{"soundslike": "42"}]}`,
+		`{"x": [{"soundslike": 3}]}`,
+		`{"x": [{"soundslike": "a", "b": 1}]}`,
+	} {
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package quamina
+
+import (
+	"bytes"
+	"slices"
+)
+
+// prefixMatcher handles the "prefix" patterns for a valueMatcher, for as long as they are the only patterns
+// there that would otherwise go into the automaton. Fields that carry nothing but prefix patterns are common
+// (think URL paths or hostnames), and for them a radix tree is much smaller than the equivalent automaton,
+// which needs an faState and a smallTable for every byte of every prefix, and is at least as fast to
+// traverse, since runs of bytes that aren't shared with any other prefix are compared with bytes.HasPrefix
+// rather than stepped through one at a time. Once some other kind of pattern arrives, the prefixes are moved
+// into the automaton; see valueMatcher.addTransition.
+//
+// Like the substringMatcher, a prefixMatcher is never updated once built; with copies the nodes along the
+// path to the new prefix, and addTransition swaps the new root into the valueMatcher's vmFields.
+type prefixMatcher struct {
+	root  *prefixNode
+	bytes int64 // see size; kept up to date by with, so that it needn't walk the tree
+}
+
+// prefixNode is a radix-tree node. The string it represents is the concatenation of the labels on the path
+// from the root; the root's label is empty. The prefixes as stored include the leading quote of string
+// values, so that numbers and other unquoted values never match.
+type prefixNode struct {
+	label    []byte
+	next     *fieldMatcher // non-nil if a prefix ends at this node
+	edges    []byte        // edges[i] is the first byte of children[i].label; sorted
+	children []*prefixNode
+}
+
+// with returns a prefixMatcher which adds the provided prefix to those in pm, along with the fieldMatcher
+// to transition to when a value starts with it. The prefix is a pattern value with its closing quote
+// removed. As with singleton string matches, a prefix already present gets its existing transition.
+// pm may be nil.
+func (pm *prefixMatcher) with(prefix []byte) (*prefixMatcher, *fieldMatcher) {
+	root := &prefixNode{}
+	bytes := root.size()
+	if pm != nil {
+		root = pm.root
+		bytes = pm.bytes
+	}
+	freshRoot, nextField, grew := root.with(prefix)
+	if freshRoot == root {
+		return pm, nextField
+	}
+	return &prefixMatcher{root: freshRoot, bytes: bytes + grew}, nextField
+}
+
+// with returns a copy of n with key added below it, where key is what remains of the prefix once the
+// labels down to and including n's have been matched, and the difference in size between the copy and n.
+// If the prefix is already present, n itself is returned, along with the existing transition.
+func (n *prefixNode) with(key []byte) (*prefixNode, *fieldMatcher, int64) {
+	if len(key) == 0 {
+		if n.next != nil {
+			return n, n.next, 0
+		}
+		fresh := *n
+		fresh.next = newFieldMatcher()
+		return &fresh, fresh.next, 0
+	}
+
+	i, found := slices.BinarySearch(n.edges, key[0])
+	if !found {
+		leaf := &prefixNode{label: key, next: newFieldMatcher()}
+		fresh := *n
+		fresh.edges = slices.Insert(slices.Clone(n.edges), i, key[0])
+		fresh.children = slices.Insert(slices.Clone(n.children), i, leaf)
+		return &fresh, leaf.next, fresh.size() - n.size() + leaf.size()
+	}
+
+	child := n.children[i]
+	common := 0
+	for common < len(child.label) && common < len(key) && child.label[common] == key[common] {
+		common++
+	}
+	var freshChild *prefixNode
+	var nextField *fieldMatcher
+	var grew int64
+	if common == len(child.label) {
+		freshChild, nextField, grew = child.with(key[common:])
+		if freshChild == child {
+			return n, nextField, 0
+		}
+	} else {
+		// the key diverges partway along the child's label, so split it
+		tail := *child
+		tail.label = child.label[common:]
+		split := &prefixNode{label: child.label[:common], edges: []byte{tail.label[0]}, children: []*prefixNode{&tail}}
+		freshChild, nextField, grew = split.with(key[common:])
+		grew += split.size() + tail.size() - child.size()
+	}
+	fresh := *n
+	fresh.children = slices.Clone(n.children)
+	fresh.children[i] = freshChild
+	return &fresh, nextField, grew + fresh.size() - n.size()
+}
+
+// transitionOn appends to transitions the fieldMatchers for each prefix the value starts with
+func (pm *prefixMatcher) transitionOn(val []byte, transitions []*fieldMatcher) []*fieldMatcher {
+	node := pm.root
+	for {
+		if node.next != nil {
+			transitions = append(transitions, node.next)
+		}
+		if len(val) == 0 {
+			return transitions
+		}
+		i, found := slices.BinarySearch(node.edges, val[0])
+		if !found {
+			return transitions
+		}
+		node = node.children[i]
+		if !bytes.HasPrefix(val, node.label) {
+			return transitions
+		}
+		val = val[len(node.label):]
+	}
+}
+
+// automaton builds the equivalent prefix automaton, using the same fieldMatchers, for when the prefixes
+// have to share the valueMatcher with other kinds of pattern.
+func (pm *prefixMatcher) automaton() *faState {
+	var start *faState
+	var prefix []byte
+	var walk func(node *prefixNode)
+	walk = func(node *prefixNode) {
+		prefix = append(prefix, node.label...)
+		if node.next != nil {
+			// makeOnePrefixFAStep expects the closing quote
+			table := makeOnePrefixFAStep(append(slices.Clone(prefix), '"'), 0, node.next)
+			if start == nil {
+				start = &faState{table: table}
+			} else {
+				start = mergeStartStates(start, &faState{table: table}, sharedNullPrinter)
+			}
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+		prefix = prefix[:len(prefix)-len(node.label)]
+	}
+	walk(pm.root)
+	return start
+}
+
+// visit calls f with each node's transition; used in gathering statistics
+func (pm *prefixMatcher) visit(f func(next *fieldMatcher)) {
+	var walk func(node *prefixNode)
+	walk = func(node *prefixNode) {
+		if node.next != nil {
+			f(node.next)
+		}
+		for _, child := range node.children {
+			walk(child)
+		}
+	}
+	walk(pm.root)
+}
+
+// size estimates the memory consumed by the prefixMatcher, not including the fieldMatchers
+func (pm *prefixMatcher) size() int64 {
+	return pm.bytes
+}
+
+// size is the memory consumed by the node itself, not including its children
+func (n *prefixNode) size() int64 {
+	return mcPrefixNode + int64(len(n.label)+cap(n.edges)) + mcPointer*int64(cap(n.children))
+}
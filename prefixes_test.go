@@ -0,0 +1,134 @@
+package quamina
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestPrefixMatcherAgreesWithAutomaton checks the prefixMatcher, and the automaton it builds when it has
+// to give way, against the automaton that would have been built from the start, on random prefixes and
+// values over a small alphabet so that there's lots of label splitting.
+func TestPrefixMatcherAgreesWithAutomaton(t *testing.T) {
+	rng := rand.New(rand.NewSource(126))
+	randomString := func(maxLen int) string {
+		b := make([]byte, rng.Intn(maxLen+1))
+		for i := range b {
+			b[i] = "abc"[rng.Intn(3)]
+		}
+		return string(b)
+	}
+	var pm *prefixMatcher
+	var wanted *faState
+	prefixes := make(map[string]*fieldMatcher)
+	for i := 0; i < 60; i++ {
+		val := []byte(`"` + randomString(6) + `"`)
+		var next *fieldMatcher
+		pm, next = pm.with(val[:len(val)-1])
+		if walked := walkedPrefixSize(pm.root); pm.size() != walked {
+			t.Errorf("after %s, size %d but the nodes add up to %d", val, pm.size(), walked)
+		}
+		if existing, ok := prefixes[string(val)]; ok {
+			if existing != next {
+				t.Errorf("%s added twice got a new transition", val)
+			}
+			continue
+		}
+		prefixes[string(val)] = next
+		table := makeOnePrefixFAStep(val, 0, next)
+		if wanted == nil {
+			wanted = &faState{table: table}
+		} else {
+			wanted = mergeStartStates(wanted, &faState{table: table}, sharedNullPrinter)
+		}
+	}
+	built := pm.automaton()
+	for i := 0; i < 500; i++ {
+		val := []byte(`"` + randomString(8) + `"`)
+		if i%50 == 0 {
+			val = []byte(randomString(4))
+		}
+		want := traverseDFA(wanted, val, nil)
+		if got := pm.transitionOn(val, nil); !sameTransitions(got, want) {
+			t.Errorf("%s: prefixMatcher got %d, automaton %d", val, len(got), len(want))
+		}
+		if got := traverseDFA(built, val, nil); !sameTransitions(got, want) {
+			t.Errorf("%s: built automaton got %d, automaton %d", val, len(got), len(want))
+		}
+	}
+}
+
+// walkedPrefixSize adds up the sizes of the nodes, which prefixMatcher.with keeps track of as it goes
+func walkedPrefixSize(node *prefixNode) int64 {
+	size := node.size()
+	for _, child := range node.children {
+		size += walkedPrefixSize(child)
+	}
+	return size
+}
+
+func sameTransitions(a, b []*fieldMatcher) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	in := make(map[*fieldMatcher]bool)
+	for _, fm := range a {
+		in[fm] = true
+	}
+	for _, fm := range b {
+		if !in[fm] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPrefixFastPath(t *testing.T) {
+	q, _ := New()
+	patterns := map[X]string{
+		"api":     `{"path": [{"prefix": "/api/"}]}`,
+		"apiV2":   `{"path": [{"prefix": "/api/v2/"}]}`,
+		"apps":    `{"path": [{"prefix": "/apps"}]}`,
+		"all":     `{"path": [{"prefix": ""}]}`,
+		"static":  `{"path": [{"prefix": "/static/"}], "method": [{"prefix": "G"}]}`,
+		"another": `{"path": [{"prefix": "/api/"}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// "path" at the top level, plus "method" and then "path" for the "static" pattern
+	if got := q.GetMatcherStats()["prefixFastPaths"]; got != 3 {
+		t.Errorf("prefixFastPaths %v", got)
+	}
+	cases := map[string][]X{
+		`{"path": "/api/v2/users"}`:                  {"api", "apiV2", "all", "another"},
+		`{"path": "/api/v1/users"}`:                  {"api", "all", "another"},
+		`{"path": "/apples"}`:                        {"all"},
+		`{"path": "/static/x.css", "method": "GET"}`: {"static", "all"},
+		`{"path": 12}`:                               {},
+	}
+	check := func() {
+		t.Helper()
+		for event, wanted := range cases {
+			matches, err := q.MatchesForEvent([]byte(event))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !containsExactly(matches, wanted) {
+				t.Errorf("%s: wanted %v got %v", event, wanted, matches)
+			}
+		}
+	}
+	check()
+
+	// anything else on the field moves the prefixes into the automaton
+	if err := q.AddPattern("exact", `{"path": ["/apples"]}`); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.GetMatcherStats()["prefixFastPaths"]; got != 2 {
+		t.Errorf("prefixFastPaths %v after exact match added", got)
+	}
+	cases[`{"path": "/apples"}`] = []X{"all", "exact"}
+	check()
+}
@@ -0,0 +1,64 @@
+package quamina
+
+// PatternPreview is what PreviewAddPattern predicts adding a Pattern will cost
+type PatternPreview struct {
+	// States is the number of automaton states built for the Pattern's values
+	States int
+	// ValueMatchers is the number of matchers built for the values of the Pattern's fields
+	ValueMatchers int
+	// Bytes is the memory used, by the measure MemoryUsage reports and memory budgets are charged with
+	Bytes int64
+	// NewFieldPaths are the paths, sorted, of the fields the Pattern uses that the Flattener doesn't
+	// already extract, as FieldPaths reports them
+	NewFieldPaths []string
+}
+
+// PreviewAddPattern checks the Pattern as AddPattern would, and compiles it into a scratch matcher, from
+// which it predicts the cost of adding it, without changing the instance. It can be used to turn away a
+// Pattern that would cost more than an app is willing to pay before anything is added. The figures are those
+// for the Pattern on its own. Adding it to an instance shares what it has in common with the Patterns already
+// there, so usually costs less; but in BuiltForSpeed mode, merging its automata with existing ones can cost
+// more. Nothing is added with x.
+func (q *Quamina) PreviewAddPattern(x X, patternJSON string) (PatternPreview, error) {
+	scratch := &Quamina{matcher: newCoreMatcher(), options: options{eventBridge: q.eventBridge, buildMode: q.buildMode}}
+	if q.eventBridge {
+		scratch.matcher.setEventBridgeCompat()
+	}
+	if q.numericTolerance > 0 {
+		scratch.matcher.setNumericTolerance(q.numericTolerance)
+	}
+	if q.foldFieldNames {
+		scratch.matcher.setCaseInsensitiveFieldNames()
+	}
+	scratch.matcher.setRepeatedFields(q.repeatedFields)
+	if q.hmacKey != nil {
+		scratch.matcher.setHMACKey(q.hmacKey)
+	}
+	if q.collation != nil {
+		scratch.matcher.setCollation(q.collation)
+	}
+	if q.lookups != nil {
+		scratch.matcher.setLookups(q.lookups)
+	}
+	if err := scratch.addPattern(x, patternJSON); err != nil {
+		return PatternPreview{}, err
+	}
+
+	stats := scratch.matcher.getStats()
+	preview := PatternPreview{
+		States:        int(stats.states),
+		ValueMatchers: int(stats.valueMatchers),
+		Bytes:         scratch.matcher.memoryUsage(),
+	}
+
+	existing := make(map[string]bool)
+	for _, path := range q.FieldPaths() {
+		existing[path] = true
+	}
+	for _, path := range scratch.FieldPaths() {
+		if !existing[path] {
+			preview.NewFieldPaths = append(preview.NewFieldPaths, path)
+		}
+	}
+	return preview, nil
+}
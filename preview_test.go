@@ -0,0 +1,60 @@
+package quamina
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPreviewAddPattern(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("existing", `{"kind": ["order"]}`)
+	before := q.MemoryUsage().Matcher
+	statesBefore := q.GetMatcherStats()["states"]
+
+	pattern := `{"kind": ["order"], "detail": {"sku": [{"regexp": "a[b-d]+c?"}]}}`
+	preview, err := q.PreviewAddPattern("new", pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.States == 0 || preview.ValueMatchers != 2 || preview.Bytes == 0 {
+		t.Errorf("preview %+v", preview)
+	}
+	if !slices.Equal(preview.NewFieldPaths, []string{"detail\nsku"}) {
+		t.Errorf("new paths %q", preview.NewFieldPaths)
+	}
+
+	// nothing has changed
+	if q.MemoryUsage().Matcher != before || q.GetMatcherStats()["states"] != statesBefore {
+		t.Error("preview changed the matcher")
+	}
+	if slices.Contains(q.FieldPaths(), "detail\nsku") {
+		t.Error("preview added a field path")
+	}
+	matches, _ := q.MatchesForEvent([]byte(`{"kind": "order", "detail": {"sku": "abbc"}}`))
+	if !slices.Equal(matches, []X{"existing"}) {
+		t.Errorf("matches %v", matches)
+	}
+
+	// on its own, the preview is what adding costs
+	empty, _ := New()
+	_ = empty.AddPattern("new", pattern)
+	if got := empty.MemoryUsage().Matcher; got != preview.Bytes {
+		t.Errorf("added %d bytes, previewed %d", got, preview.Bytes)
+	}
+	if got := empty.GetMatcherStats()["states"]; int(got) != preview.States {
+		t.Errorf("added %v states, previewed %d", got, preview.States)
+	}
+
+	if _, err := q.PreviewAddPattern("bad", `{"kind": "order"}`); err == nil {
+		t.Error("bad Pattern previewed")
+	}
+
+	// EventBridge Patterns are previewed as EventBridge Patterns
+	eb, _ := New(WithEventBridgeCompat())
+	if _, err := eb.PreviewAddPattern("or", `{"$or": [{"a": ["x"]}, {"b": ["y"]}]}`); err != nil {
+		t.Error(err)
+	}
+	if _, err := q.PreviewAddPattern("or", `{"$or": [{"a": ["x"]}, {"b": ["y"]}]}`); err == nil {
+		t.Error("$or previewed without EventBridge compatibility")
+	}
+}
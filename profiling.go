@@ -0,0 +1,56 @@
+//go:build !tinygo
+
+package quamina
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// profilerLabelKey is the pprof label WithProfilerLabels applies, with the values below naming the phase
+// of MatchesForEvent in progress
+const (
+	profilerLabelKey     = "quamina"
+	profilerPhaseFlatten = "flatten"
+	profilerPhaseMatch   = "match"
+)
+
+// profilerLabels holds contexts carrying the label for each phase, added to those of base, which the
+// goroutine's labels are set back to afterward. Making them with pprof.WithLabels allocates, so for the
+// usual case, where base is context.Background(), it's done once and shared by all the instances using
+// WithProfilerLabels; switching between them with pprof.SetGoroutineLabels doesn't allocate.
+type profilerLabels struct {
+	base    context.Context
+	flatten context.Context
+	match   context.Context
+}
+
+var sharedProfilerLabels = newProfilerLabels(context.Background())
+
+func newProfilerLabels(base context.Context) *profilerLabels {
+	return &profilerLabels{
+		base:    base,
+		flatten: pprof.WithLabels(base, pprof.Labels(profilerLabelKey, profilerPhaseFlatten)),
+		match:   pprof.WithLabels(base, pprof.Labels(profilerLabelKey, profilerPhaseMatch)),
+	}
+}
+
+// flattening, matching, and done switch the goroutine's labels as MatchesForEvent moves from phase to
+// phase; they do nothing if p is nil, which it is unless WithProfilerLabels was used.
+func (p *profilerLabels) flattening() {
+	if p != nil {
+		pprof.SetGoroutineLabels(p.flatten)
+	}
+}
+
+func (p *profilerLabels) matching() {
+	if p != nil {
+		pprof.SetGoroutineLabels(p.match)
+	}
+}
+
+func (p *profilerLabels) done() {
+	if p != nil {
+		pprof.SetGoroutineLabels(p.base)
+	}
+}
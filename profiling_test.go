@@ -0,0 +1,139 @@
+//go:build !tinygo
+
+package quamina
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"testing"
+)
+
+// goroutineLabels returns the labels on all goroutines, as listed in the goroutine profile; only the
+// test's goroutine ever has any
+func goroutineLabels(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	var labels []byte
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("# labels:")) {
+			labels = append(labels, line...)
+		}
+	}
+	return string(labels)
+}
+
+// labelRecordingFlattener and labelRecordingMatcher record the labels in effect when they're called
+type labelRecordingFlattener struct {
+	Flattener
+	t      *testing.T
+	labels *string
+}
+
+func (f *labelRecordingFlattener) Flatten(event []byte, tracker SegmentsTreeTracker) ([]Field, error) {
+	*f.labels = goroutineLabels(f.t)
+	return f.Flattener.Flatten(event, tracker)
+}
+
+type labelRecordingMatcher struct {
+	matcher
+	t      *testing.T
+	labels *string
+}
+
+func (m *labelRecordingMatcher) matchesForFields(fields []Field, bufs *nfaBuffers) ([]X, error) {
+	*m.labels = goroutineLabels(m.t)
+	return m.matcher.matchesForFields(fields, bufs)
+}
+
+func (m *labelRecordingMatcher) matchesForFieldsInto(fields []Field, bufs *nfaBuffers, dst []X) ([]X, error) {
+	*m.labels = goroutineLabels(m.t)
+	return m.matcher.matchesForFieldsInto(fields, bufs, dst)
+}
+
+func TestProfilerLabels(t *testing.T) {
+	var flattenLabels, matchLabels string
+	q, err := New(
+		WithFlattener(&labelRecordingFlattener{Flattener: newJSONFlattener(), t: t, labels: &flattenLabels}),
+		WithProfilerLabels(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.matcher = &labelRecordingMatcher{matcher: q.matcher, t: t, labels: &matchLabels}
+	if err := q.AddPattern("p", `{"a": [1]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, useInto := range []bool{false, true} {
+		var matches []X
+		if useInto {
+			matches, err = q.MatchesForEventInto([]byte(`{"a": 1}`), nil)
+		} else {
+			matches, err = q.MatchesForEvent([]byte(`{"a": 1}`))
+		}
+		if err != nil || !containsExactly(matches, []X{"p"}) {
+			t.Fatalf("matches %v, err %v", matches, err)
+		}
+		if flattenLabels != `# labels: {"quamina":"flatten"}` {
+			t.Errorf("while flattening: %s", flattenLabels)
+		}
+		if matchLabels != `# labels: {"quamina":"match"}` {
+			t.Errorf("while matching: %s", matchLabels)
+		}
+		if after := goroutineLabels(t); after != "" {
+			t.Errorf("after matching: %s", after)
+		}
+	}
+
+	// labels are reset even when the event is bad
+	if _, err := q.MatchesForEvent([]byte(`{"a": `)); err == nil {
+		t.Error("bad event accepted")
+	}
+	if after := goroutineLabels(t); after != "" {
+		t.Errorf("after error: %s", after)
+	}
+
+	// the caller's own labels are kept when they come in a context
+	pprof.Do(context.Background(), pprof.Labels("service", "api"), func(ctx context.Context) {
+		matches, err := q.MatchesForEventContext(ctx, []byte(`{"a": 1}`))
+		if err != nil || !containsExactly(matches, []X{"p"}) {
+			t.Fatalf("matches %v, err %v", matches, err)
+		}
+		if flattenLabels != `# labels: {"quamina":"flatten", "service":"api"}` {
+			t.Errorf("while flattening with context: %s", flattenLabels)
+		}
+		if matchLabels != `# labels: {"quamina":"match", "service":"api"}` {
+			t.Errorf("while matching with context: %s", matchLabels)
+		}
+		if after := goroutineLabels(t); after != `# labels: {"service":"api"}` {
+			t.Errorf("after matching with context: %s", after)
+		}
+	})
+	if q.labels != sharedProfilerLabels {
+		t.Error("MatchesForEventContext didn't restore the shared labels")
+	}
+
+	// off by default, and inherited by Copy
+	flattenLabels = ""
+	plain, _ := New(WithFlattener(&labelRecordingFlattener{Flattener: newJSONFlattener(), t: t, labels: &flattenLabels}))
+	_, _ = plain.MatchesForEvent([]byte(`{"a": 1}`))
+	if flattenLabels != "" {
+		t.Errorf("labels without the option: %s", flattenLabels)
+	}
+	pprof.Do(context.Background(), pprof.Labels("service", "api"), func(context.Context) {
+		_, _ = plain.MatchesForEvent([]byte(`{"a": 1}`))
+		if after := goroutineLabels(t); after != `# labels: {"service":"api"}` {
+			t.Errorf("labels changed without the option: %s", after)
+		}
+	})
+	if q.Copy().labels == nil {
+		t.Error("Copy lost the profiler labels")
+	}
+	if _, err := New(WithProfilerLabels(true), WithProfilerLabels(false)); err == nil {
+		t.Error("option accepted twice")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build tinygo
+
+package quamina
+
+import "context"
+
+// TinyGo has no profiler labels, so WithProfilerLabels is accepted but does nothing.
+type profilerLabels struct{}
+
+var sharedProfilerLabels = &profilerLabels{}
+
+func newProfilerLabels(context.Context) *profilerLabels {
+	return sharedProfilerLabels
+}
+
+func (p *profilerLabels) flattening() {}
+
+func (p *profilerLabels) matching() {}
+
+func (p *profilerLabels) done() {}
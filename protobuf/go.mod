@@ -0,0 +1,11 @@
+module quamina.net/go/quamina/v2/protobuf
+
+go 1.23
+
+require (
+	google.golang.org/protobuf v1.36.9
+	quamina.net/go/quamina/v2 v2.0.0
+)
+
+// develop against the Quamina in this repository
+replace quamina.net/go/quamina/v2 => ..
@@ -0,0 +1,331 @@
+// Package protobuf provides a Flattener for Protocol Buffers messages in the binary wire format, so that they
+// can be matched against Quamina Patterns without first converting them to JSON. The message type is given
+// by its descriptor, so it needn't be compiled into the program: a descriptor from a FileDescriptorSet read at
+// run time works as well as one from generated code.
+//
+// A message is flattened as if it were its JSON mapping, as protojson would produce it, so fields are named by
+// their JSON names, enum values by their names, bytes are base64 strings, and the well-known types have their
+// JSON forms; a Timestamp, for example, is an RFC 3339 string. Unlike protojson, 64-bit integers are numbers,
+// not strings, so that numeric Patterns work on them. As in protojson, fields that aren't present, including
+// proto3 scalars with their default values, are left out.
+//
+// A google.protobuf.Any is resolved to the message it holds, using the Resolver provided WithResolver, which
+// defaults to protoregistry.GlobalTypes, and that message's fields are flattened under a member named by the
+// Any's type URL, beside an "@type" member whose value is the URL. For a field "payload" holding an Any whose
+// message is an acme.Order, this Pattern matches Orders over 100:
+//
+//	{"payload": {"type.googleapis.com/acme.Order": {"amount": [{"numeric": [">", 100]}]}}}
+//
+// Keeping the fields of each type apart means that Patterns for different payload types never match each
+// other's fields, however they're named. An Any whose type the Resolver doesn't know has only its "@type".
+//
+// The package is a module of its own, so that Quamina itself keeps no dependencies outside the standard
+// library.
+package protobuf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/internal/flatten"
+)
+
+// Resolver finds the message types of Any values, and extensions. *protoregistry.Types is one.
+type Resolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+// Option is an option for NewFlattener.
+type Option func(f *Flattener)
+
+// WithResolver provides the Resolver used to find the types of the messages in Any values, and extensions.
+func WithResolver(r Resolver) Option {
+	return func(f *Flattener) {
+		f.resolver = r
+	}
+}
+
+// Flattener flattens messages of one type. Like Quamina instances, a Flattener is not safe for concurrent
+// use; Copy makes one for another goroutine.
+type Flattener struct {
+	desc     protoreflect.MessageDescriptor
+	resolver Resolver
+	message  *dynamicpb.Message
+	w        flatten.Writer
+}
+
+// NewFlattener returns a Flattener for messages described by desc.
+func NewFlattener(desc protoreflect.MessageDescriptor, opts ...Option) *Flattener {
+	f := &Flattener{desc: desc, resolver: protoregistry.GlobalTypes}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Copy returns a new Flattener for the same type, with the same Resolver.
+func (f *Flattener) Copy() quamina.Flattener {
+	return &Flattener{desc: f.desc, resolver: f.resolver}
+}
+
+// Flatten decodes the message in event, returning an error if it can't. The Fields returned are valid until
+// the next call.
+func (f *Flattener) Flatten(event []byte, tracker quamina.SegmentsTreeTracker) ([]quamina.Field, error) {
+	f.w.Reset()
+	if f.message == nil {
+		f.message = dynamicpb.NewMessage(f.desc)
+	}
+	proto.Reset(f.message)
+	if err := f.unmarshal(event, f.message); err != nil {
+		return nil, err
+	}
+	if err := f.writeMessage(f.message, tracker); err != nil {
+		return nil, err
+	}
+	return f.w.Fields(), nil
+}
+
+func (f *Flattener) unmarshal(b []byte, m proto.Message) error {
+	return proto.UnmarshalOptions{Resolver: f.resolver}.Unmarshal(b, m)
+}
+
+// writeMessage writes the fields of m as the members of the object at node
+func (f *Flattener) writeMessage(m protoreflect.Message, node quamina.SegmentsTreeTracker) error {
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		name := fieldName(fd)
+		if !flatten.Used(node, name) {
+			return true
+		}
+		member := f.w.Member(node, name)
+		switch {
+		case fd.IsList():
+			err = f.writeList(member, fd, v.List())
+		case fd.IsMap():
+			err = f.writeMap(member, fd, v.Map())
+		default:
+			err = f.writeValue(member, fd, v)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// fieldName returns the name protojson gives a field
+func fieldName(fd protoreflect.FieldDescriptor) string {
+	if fd.IsExtension() {
+		return "[" + string(fd.FullName()) + "]"
+	}
+	return fd.JSONName()
+}
+
+func (f *Flattener) writeList(sink flatten.Sink, fd protoreflect.FieldDescriptor, list protoreflect.List) error {
+	array, ok := sink.Array()
+	if !ok {
+		return nil
+	}
+	defer array.End()
+	for i := 0; i < list.Len(); i++ {
+		array.Next()
+		if err := f.writeValue(array, fd, list.Get(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Flattener) writeMap(sink flatten.Sink, fd protoreflect.FieldDescriptor, m protoreflect.Map) error {
+	node, ok := sink.Object()
+	if !ok {
+		return nil
+	}
+	var err error
+	m.Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+		// the JSON forms of map keys are the strings of their values
+		key := k.String()
+		if flatten.Used(node, key) {
+			err = f.writeValue(f.w.Member(node, key), fd.MapValue(), v)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// writeValue writes a single value of the field fd
+func (f *Flattener) writeValue(sink flatten.Sink, fd protoreflect.FieldDescriptor, v protoreflect.Value) error {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		sink.Literal(strconv.FormatBool(v.Bool()))
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		sink.Number(strconv.FormatInt(v.Int(), 10))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		sink.Number(strconv.FormatUint(v.Uint(), 10))
+	case protoreflect.FloatKind:
+		writeFloat(sink, v.Float(), 32)
+	case protoreflect.DoubleKind:
+		writeFloat(sink, v.Float(), 64)
+	case protoreflect.StringKind:
+		sink.String(v.String())
+	case protoreflect.BytesKind:
+		sink.String(base64.StdEncoding.EncodeToString(v.Bytes()))
+	case protoreflect.EnumKind:
+		if fd.Enum().FullName() == "google.protobuf.NullValue" {
+			sink.Literal("null")
+		} else if value := fd.Enum().Values().ByNumber(v.Enum()); value != nil {
+			sink.String(string(value.Name()))
+		} else {
+			sink.Number(strconv.FormatInt(int64(v.Enum()), 10))
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return f.writeMessageValue(sink, v.Message())
+	default:
+		return fmt.Errorf("protobuf: field %s has unknown kind %v", fd.FullName(), fd.Kind())
+	}
+	return nil
+}
+
+// writeMessageValue writes a message, giving the well-known types their JSON forms
+func (f *Flattener) writeMessageValue(sink flatten.Sink, m protoreflect.Message) error {
+	desc := m.Descriptor()
+	switch desc.FullName() {
+	case "google.protobuf.Any":
+		return f.writeAny(sink, m)
+	case "google.protobuf.Timestamp":
+		seconds, nanos := secondsAndNanos(m)
+		t := time.Unix(seconds, nanos).UTC()
+		sink.String(t.Format("2006-01-02T15:04:05") + fraction(nanos) + "Z")
+	case "google.protobuf.Duration":
+		seconds, nanos := secondsAndNanos(m)
+		sign := ""
+		if seconds < 0 || nanos < 0 {
+			sign, seconds, nanos = "-", -seconds, -nanos
+		}
+		sink.String(sign + strconv.FormatInt(seconds, 10) + fraction(nanos) + "s")
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value",
+		"google.protobuf.UInt64Value", "google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		value := desc.Fields().ByNumber(1)
+		return f.writeValue(sink, value, m.Get(value))
+	case "google.protobuf.Struct":
+		return f.writeMap(sink, desc.Fields().ByNumber(1), m.Get(desc.Fields().ByNumber(1)).Map())
+	case "google.protobuf.ListValue":
+		return f.writeList(sink, desc.Fields().ByNumber(1), m.Get(desc.Fields().ByNumber(1)).List())
+	case "google.protobuf.Value":
+		kind := m.WhichOneof(desc.Oneofs().ByName("kind"))
+		if kind == nil {
+			return errors.New("protobuf: google.protobuf.Value has no value")
+		}
+		return f.writeValue(sink, kind, m.Get(kind))
+	case "google.protobuf.FieldMask":
+		var paths []string
+		list := m.Get(desc.Fields().ByNumber(1)).List()
+		for i := 0; i < list.Len(); i++ {
+			paths = append(paths, lowerCamel(list.Get(i).String()))
+		}
+		sink.String(strings.Join(paths, ","))
+	default:
+		node, ok := sink.Object()
+		if !ok {
+			return nil
+		}
+		return f.writeMessage(m, node)
+	}
+	return nil
+}
+
+// writeAny writes the "@type" of an Any, and the fields of its message under a member named by the type URL
+func (f *Flattener) writeAny(sink flatten.Sink, m protoreflect.Message) error {
+	node, ok := sink.Object()
+	if !ok {
+		return nil
+	}
+	fields := m.Descriptor().Fields()
+	url := m.Get(fields.ByNumber(1)).String()
+	if url == "" {
+		return nil
+	}
+	f.w.String(node, "@type", url)
+	if !flatten.Used(node, url) {
+		return nil
+	}
+	mt, err := f.resolver.FindMessageByURL(url)
+	if errors.Is(err, protoregistry.NotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("protobuf: resolving %s: %w", url, err)
+	}
+	inner := mt.New()
+	if err := f.unmarshal(m.Get(fields.ByNumber(2)).Bytes(), inner.Interface()); err != nil {
+		return fmt.Errorf("protobuf: decoding %s: %w", url, err)
+	}
+	return f.writeMessageValue(f.w.Member(node, url), inner)
+}
+
+func secondsAndNanos(m protoreflect.Message) (int64, int64) {
+	fields := m.Descriptor().Fields()
+	return m.Get(fields.ByNumber(1)).Int(), m.Get(fields.ByNumber(2)).Int()
+}
+
+// fraction formats nanoseconds as protojson does, with 0, 3, 6, or 9 digits
+func fraction(nanos int64) string {
+	switch {
+	case nanos == 0:
+		return ""
+	case nanos%1e6 == 0:
+		return fmt.Sprintf(".%03d", nanos/1e6)
+	case nanos%1e3 == 0:
+		return fmt.Sprintf(".%06d", nanos/1e3)
+	}
+	return fmt.Sprintf(".%09d", nanos)
+}
+
+// writeFloat writes a float as protojson does, with NaN and the infinities as strings
+func writeFloat(sink flatten.Sink, n float64, bits int) {
+	switch {
+	case math.IsNaN(n):
+		sink.String("NaN")
+	case math.IsInf(n, 1):
+		sink.String("Infinity")
+	case math.IsInf(n, -1):
+		sink.String("-Infinity")
+	default:
+		// as protojson and encoding/json do, use exponents only for very large and very small numbers
+		format := byte('f')
+		if abs := math.Abs(n); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+		sink.Number(strconv.FormatFloat(n, format, -1, bits))
+	}
+}
+
+// lowerCamel converts a field path such as foo_bar.baz_qux to fooBar.bazQux, as protojson does for FieldMasks
+func lowerCamel(path string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range path {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && c >= 'a' && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(c)
+			upper = false
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,222 @@
+package protobuf
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+	"quamina.net/go/quamina/v2"
+)
+
+// acme.proto, as a FileDescriptorProto, so the tests don't need protoc
+const acmeProto = `
+name: "acme.proto"
+package: "acme"
+dependency: ["google/protobuf/any.proto", "google/protobuf/duration.proto", "google/protobuf/struct.proto",
+  "google/protobuf/timestamp.proto", "google/protobuf/wrappers.proto"]
+syntax: "proto3"
+enum_type { name: "Status" value { name: "UNKNOWN" number: 0 } value { name: "SHIPPED" number: 1 } }
+message_type {
+  name: "Event"
+  field { name: "id" number: 1 type: TYPE_STRING label: LABEL_OPTIONAL json_name: "id" }
+  field { name: "customer_id" number: 2 type: TYPE_STRING label: LABEL_OPTIONAL json_name: "customerId" }
+  field { name: "amount" number: 3 type: TYPE_INT64 label: LABEL_OPTIONAL json_name: "amount" }
+  field { name: "items" number: 4 type: TYPE_MESSAGE type_name: ".acme.Item" label: LABEL_REPEATED json_name: "items" }
+  field { name: "labels" number: 5 type: TYPE_MESSAGE type_name: ".acme.Event.LabelsEntry" label: LABEL_REPEATED json_name: "labels" }
+  field { name: "payload" number: 6 type: TYPE_MESSAGE type_name: ".google.protobuf.Any" label: LABEL_OPTIONAL json_name: "payload" }
+  field { name: "created" number: 7 type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" label: LABEL_OPTIONAL json_name: "created" }
+  field { name: "status" number: 8 type: TYPE_ENUM type_name: ".acme.Status" label: LABEL_OPTIONAL json_name: "status" }
+  field { name: "blob" number: 9 type: TYPE_BYTES label: LABEL_OPTIONAL json_name: "blob" }
+  field { name: "attrs" number: 10 type: TYPE_MESSAGE type_name: ".google.protobuf.Struct" label: LABEL_OPTIONAL json_name: "attrs" }
+  field { name: "ratio" number: 11 type: TYPE_DOUBLE label: LABEL_OPTIONAL json_name: "ratio" }
+  field { name: "retries" number: 12 type: TYPE_MESSAGE type_name: ".google.protobuf.Int32Value" label: LABEL_OPTIONAL json_name: "retries" }
+  field { name: "timeout" number: 13 type: TYPE_MESSAGE type_name: ".google.protobuf.Duration" label: LABEL_OPTIONAL json_name: "timeout" }
+  field { name: "tags" number: 14 type: TYPE_STRING label: LABEL_REPEATED json_name: "tags" }
+  nested_type {
+    name: "LabelsEntry"
+    field { name: "key" number: 1 type: TYPE_STRING label: LABEL_OPTIONAL json_name: "key" }
+    field { name: "value" number: 2 type: TYPE_STRING label: LABEL_OPTIONAL json_name: "value" }
+    options { map_entry: true }
+  }
+}
+message_type {
+  name: "Item"
+  field { name: "sku" number: 1 type: TYPE_STRING label: LABEL_OPTIONAL json_name: "sku" }
+  field { name: "qty" number: 2 type: TYPE_INT32 label: LABEL_OPTIONAL json_name: "qty" }
+}
+message_type {
+  name: "Refund"
+  field { name: "reason" number: 1 type: TYPE_STRING label: LABEL_OPTIONAL json_name: "reason" }
+  field { name: "amount" number: 2 type: TYPE_INT64 label: LABEL_OPTIONAL json_name: "amount" }
+}
+message_type {
+  name: "Unregistered"
+  field { name: "x" number: 1 type: TYPE_STRING label: LABEL_OPTIONAL json_name: "x" }
+}
+`
+
+// acmeTypes returns a Resolver for the acme messages, which knows all but acme.Unregistered, and the
+// descriptor of acme.Event
+func acmeTypes(t *testing.T) (*protoregistry.Types, protoreflect.FileDescriptor) {
+	t.Helper()
+	var fdp descriptorpb.FileDescriptorProto
+	if err := prototext.Unmarshal([]byte(acmeProto), &fdp); err != nil {
+		t.Fatal(err)
+	}
+	file, err := protodesc.NewFile(&fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := new(protoregistry.Types)
+	for _, name := range []protoreflect.Name{"Event", "Item", "Refund"} {
+		if err := types.RegisterMessage(dynamicpb.NewMessageType(file.Messages().ByName(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return types, file
+}
+
+// encode returns the wire format of the acme.Event written in protojson
+func encode(t *testing.T, types *protoregistry.Types, file protoreflect.FileDescriptor, event string) []byte {
+	t.Helper()
+	m := dynamicpb.NewMessage(file.Messages().ByName("Event"))
+	resolver := &fallbackResolver{types}
+	if err := (protojson.UnmarshalOptions{Resolver: resolver}).Unmarshal([]byte(event), m); err != nil {
+		t.Fatalf("%s: %v", event, err)
+	}
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// fallbackResolver also knows acme.Unregistered, for writing events that hold it
+type fallbackResolver struct {
+	*protoregistry.Types
+}
+
+func (r *fallbackResolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if url == "type.googleapis.com/acme.Unregistered" {
+		var fdp descriptorpb.FileDescriptorProto
+		_ = prototext.Unmarshal([]byte(acmeProto), &fdp)
+		file, _ := protodesc.NewFile(&fdp, protoregistry.GlobalFiles)
+		return dynamicpb.NewMessageType(file.Messages().ByName("Unregistered")), nil
+	}
+	return r.Types.FindMessageByURL(url)
+}
+
+const event = `{
+  "id": "e-1",
+  "customerId": "c-17",
+  "amount": "5000000000",
+  "items": [{"sku": "a", "qty": 2}, {"sku": "b", "qty": 5}],
+  "labels": {"env": "prod"},
+  "payload": {"@type": "type.googleapis.com/acme.Refund", "reason": "damaged", "amount": "250"},
+  "created": "2024-02-29T12:00:00.500Z",
+  "status": "SHIPPED",
+  "blob": "aGVsbG8=",
+  "attrs": {"region": "eu", "weight": 1.5, "fragile": true, "dims": [1, 2], "box": {"kind": null}},
+  "ratio": 1000000,
+  "retries": 0,
+  "timeout": "-1.500s",
+  "tags": ["x", "y"]
+}`
+
+func TestFlatten(t *testing.T) {
+	types, file := acmeTypes(t)
+	wire := encode(t, types, file, event)
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"id": ["e-1"], "customerId": ["c-17"]}`, true},
+		{`{"amount": [{"numeric": [">", 4294967296]}]}`, true},
+		{`{"items": {"sku": ["a"], "qty": [2]}}`, true},
+		{`{"items": {"sku": ["a"], "qty": [5]}}`, false},
+		{`{"labels": {"env": ["prod"]}}`, true},
+		{`{"payload": {"@type": ["type.googleapis.com/acme.Refund"]}}`, true},
+		{`{"payload": {"type.googleapis.com/acme.Refund": {"reason": ["damaged"], "amount": [250]}}}`, true},
+		{`{"payload": {"type.googleapis.com/acme.Order": {"amount": [250]}}}`, false},
+		{`{"payload": {"amount": [250]}}`, false},
+		{`{"created": ["2024-02-29T12:00:00.500Z"]}`, true},
+		{`{"status": ["SHIPPED"]}`, true},
+		{`{"blob": ["aGVsbG8="]}`, true},
+		{`{"attrs": {"region": ["eu"], "weight": [1.5], "fragile": [true], "dims": [2], "box": {"kind": [null]}}}`, true},
+		{`{"ratio": ["1000000"]}`, false},
+		{`{"ratio": [1000000]}`, true},
+		{`{"retries": [0]}`, true},
+		{`{"timeout": ["-1.500s"]}`, true},
+		{`{"tags": ["y"]}`, true},
+		{`{"status": [{"exists": true}], "missing": [{"exists": false}]}`, true},
+	} {
+		q, err := quamina.New(quamina.WithFlattener(NewFlattener(file.Messages().ByName("Event"), WithResolver(types))),
+			quamina.WithEventBridgeCompat())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := q.AddPattern("p", test.pattern); err != nil {
+			t.Fatalf("%s: %v", test.pattern, err)
+		}
+		matches, err := q.MatchesForEvent(wire)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(matches) == 1; got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+}
+
+func TestDefaultsAreLeftOut(t *testing.T) {
+	types, file := acmeTypes(t)
+	q, _ := quamina.New(quamina.WithFlattener(NewFlattener(file.Messages().ByName("Event"), WithResolver(types))))
+	_ = q.AddPattern("p", `{"status": [{"exists": false}], "amount": [{"exists": false}], "id": [""]}`)
+	_ = q.AddPattern("q", `{"id": ["e-2"], "status": [{"exists": false}]}`)
+	matches, err := q.MatchesForEvent(encode(t, types, file, `{"id": "e-2", "status": "UNKNOWN", "amount": "0"}`))
+	if err != nil || len(matches) != 1 || matches[0] != "q" {
+		t.Errorf("matched %v, %v", matches, err)
+	}
+}
+
+func TestUnresolvedAny(t *testing.T) {
+	types, file := acmeTypes(t)
+	wire := encode(t, types, file, `{"payload": {"@type": "type.googleapis.com/acme.Unregistered", "x": "1"}}`)
+	q, _ := quamina.New(quamina.WithFlattener(NewFlattener(file.Messages().ByName("Event"), WithResolver(types))))
+	_ = q.AddPattern("type", `{"payload": {"@type": [{"prefix": "type.googleapis.com/acme.U"}]}}`)
+	_ = q.AddPattern("x", `{"payload": {"type.googleapis.com/acme.Unregistered": {"x": ["1"]}}}`)
+	matches, err := q.MatchesForEvent(wire)
+	if err != nil || len(matches) != 1 || matches[0] != "type" {
+		t.Errorf("matched %v, %v", matches, err)
+	}
+}
+
+func TestBadEvent(t *testing.T) {
+	_, file := acmeTypes(t)
+	q, _ := quamina.New(quamina.WithFlattener(NewFlattener(file.Messages().ByName("Event"))))
+	_ = q.AddPattern("p", `{"id": ["x"]}`)
+	if _, err := q.MatchesForEvent([]byte{0x0a, 0x05, 'x'}); err == nil {
+		t.Error("truncated message accepted")
+	}
+}
+
+func TestCopy(t *testing.T) {
+	types, file := acmeTypes(t)
+	q, _ := quamina.New(quamina.WithFlattener(NewFlattener(file.Messages().ByName("Event"), WithResolver(types))))
+	_ = q.AddPattern("p", `{"payload": {"type.googleapis.com/acme.Refund": {"reason": ["damaged"]}}}`)
+	matches, err := q.Copy().MatchesForEvent(encode(t, types, file, event))
+	if err != nil || len(matches) != 1 {
+		t.Errorf("copy matched %v, %v", matches, err)
+	}
+}
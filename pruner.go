@@ -72,6 +72,9 @@ type prunerMatcher struct {
 	// If nil, no automatic rebuild is ever triggered.
 	rebuildTrigger rebuildTrigger
 
+	// fieldPaths are the paths added with addFieldPaths, which rebuilds have to add to the new matcher.
+	fieldPaths []string
+
 	// lock protects the pointer the underlying Matcher as well as stats.
 	//
 	// The Matcher pointer is updated after a successful rebuild.
@@ -228,24 +231,99 @@ func (m *prunerMatcher) getStats() *matcherStats {
 	return m.Matcher.getStats()
 }
 
+func (m *prunerMatcher) automatonStats() AutomatonStats {
+	return m.Matcher.automatonStats()
+}
+
+func (m *prunerMatcher) fieldsReport() map[string]FieldReport {
+	return m.Matcher.fieldsReport()
+}
+
+func (m *prunerMatcher) memoryUsage() int64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.Matcher.memoryUsage()
+}
+
+func (m *prunerMatcher) setMemoryBudgets(budgets []*MemoryBudget) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setMemoryBudgets(budgets)
+}
+
+func (m *prunerMatcher) setEventBridgeCompat() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setEventBridgeCompat()
+}
+
+func (m *prunerMatcher) setNumericTolerance(relative float64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setNumericTolerance(relative)
+}
+
+func (m *prunerMatcher) setCaseInsensitiveFieldNames() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setCaseInsensitiveFieldNames()
+}
+
+func (m *prunerMatcher) setRepeatedFields(policy RepeatedFields) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setRepeatedFields(policy)
+}
+
+func (m *prunerMatcher) setHMACKey(key []byte) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setHMACKey(key)
+}
+
+func (m *prunerMatcher) setCollation(key func(s []byte) []byte) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setCollation(key)
+}
+
+func (m *prunerMatcher) setLookups(lookups map[string]Lookup) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.Matcher.setLookups(lookups)
+}
+
+// compact compacts the underlying matcher; the lock keeps a rebuild from replacing it meanwhile.
+func (m *prunerMatcher) compact(minimize bool) (int, int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.Matcher.compact(minimize)
+}
+
 // MatchesForFields calls the underlying
 // quamina.coreMatcher.matchesForFields and then maybe rebuilds the
 // index.
 func (m *prunerMatcher) matchesForFields(fields []Field, bufs *nfaBuffers) ([]X, error) {
-	xs, err := m.Matcher.matchesForFields(fields, bufs)
+	return m.matchesForFieldsInto(fields, bufs, nil)
+}
+
+// matchesForFieldsInto is like matchesForFields, but appends the matches
+// to dst, filtering them in place.
+func (m *prunerMatcher) matchesForFieldsInto(fields []Field, bufs *nfaBuffers, dst []X) ([]X, error) {
+	xs, err := m.Matcher.matchesForFieldsInto(fields, bufs, dst)
 	if err != nil {
-		return nil, err
+		return dst, err
 	}
 
 	// Remove any X that isn't in the live set.
 
-	acc := make([]X, 0, len(xs))
+	acc := xs[:len(dst)]
 
 	var emitted, filtered int64
-	for _, x := range xs {
+	for _, x := range xs[len(dst):] {
 		have, err := m.live.Contains(x)
 		if err != nil {
-			return nil, err
+			return dst, err
 		}
 		if !have {
 			filtered++
@@ -255,13 +333,41 @@ func (m *prunerMatcher) matchesForFields(fields []Field, bufs *nfaBuffers) ([]X,
 		emitted++
 	}
 
+	m.recordEmitted(emitted, filtered)
+	return acc, nil
+}
+
+// countForFields is like matchesForFieldsInto, but only counts the
+// live matches.
+func (m *prunerMatcher) countForFields(fields []Field, bufs *nfaBuffers) (int, error) {
+	matches := m.Matcher.matchSetForFields(fields, bufs)
+	if err := bufs.limitError(); err != nil {
+		return 0, err
+	}
+	var emitted, filtered int64
+	for x := range matches.set {
+		have, err := m.live.Contains(x)
+		if err != nil {
+			return 0, err
+		}
+		if have {
+			emitted++
+		} else {
+			filtered++
+		}
+	}
+	m.recordEmitted(emitted, filtered)
+	return int(emitted), nil
+}
+
+// recordEmitted adds to the counts of emitted and filtered patterns
+// and then maybe rebuilds the index.
+func (m *prunerMatcher) recordEmitted(emitted, filtered int64) {
 	m.lock.Lock()
 	m.stats.Filtered += filtered
 	m.stats.Emitted += emitted
 	_ = m.maybeRebuild(false)
 	m.lock.Unlock()
-
-	return acc, nil
 }
 
 // DeletePattern removes the pattern from the index and maybe rebuilds
@@ -302,14 +408,27 @@ func (m *prunerMatcher) rebuildWhileLocked(fearlessly bool) error {
 
 	var (
 		then = time.Now()
+		m0   = m.Matcher
 		m1   = newCoreMatcher()
 	)
+	m1.eventBridge = m0.eventBridge
+	m1.numericTolerance = m0.numericTolerance
+	m1.repeatedFields = m0.repeatedFields
+	m1.hmacKey = m0.hmacKey
+	m1.collation = m0.collation
+	m1.lookups = m0.lookups
+	if m0.foldFieldNames {
+		m1.setCaseInsensitiveFieldNames()
+	}
 
 	if fearlessly {
 		// Let the GC reduce heap requirements?
 		m.Matcher = nil
 	}
 
+	if len(m.fieldPaths) > 0 {
+		m1.addFieldPaths(m.fieldPaths)
+	}
 	count := 0
 	err := m.live.Iterate(func(x X, p string) error {
 		err := m1.addPattern(x, p, BuiltForComfort)
@@ -320,6 +439,7 @@ func (m *prunerMatcher) rebuildWhileLocked(fearlessly bool) error {
 	})
 
 	if err == nil {
+		m1.takeBudgets(m0)
 		m.Matcher = m1
 		m.stats.RebuildPurged = m.stats.Deleted
 		m.stats.Live = count
@@ -345,3 +465,11 @@ func (m *prunerMatcher) getPrunerStats() prunerStats {
 func (m *prunerMatcher) getSegmentsTreeTracker() SegmentsTreeTracker {
 	return m.Matcher.getSegmentsTreeTracker()
 }
+
+// addFieldPaths adds the paths to the underlying matcher, and remembers them for future rebuilds.
+func (m *prunerMatcher) addFieldPaths(paths []string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.fieldPaths = append(m.fieldPaths, paths...)
+	m.Matcher.addFieldPaths(paths)
+}
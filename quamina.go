@@ -1,20 +1,66 @@
 package quamina
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 // Quamina instances provide the public APIs of this pattern-matching library.  A single Quamina instance is
 // not thread-safe in that it cannot safely be used simultaneously in multiple goroutines. To re-use a
 // Quamina instance concurrently in multiple goroutines, create copies using the Copy API.
 type Quamina struct {
-	flattener          Flattener
-	bufs               *nfaBuffers
-	matcher            matcher
-	mediaTypeSpecified bool
-	deletionSpecified  bool
-	buildMode          MatcherBuildMode
+	flattener       Flattener
+	bufs            *nfaBuffers
+	matcher         matcher
+	samples         *sampleRates
+	eventHelpers    []*Quamina         // made when MatchesForEvents first needs them
+	structFlattener *structFlattener   // made when MatchesForStruct is first called
+	typedFields     *typedFieldsReader // made when MatchesForTypedFields is first called
+	options
+}
+
+// options holds what New's Options set. Copy copies it as one value, so that an instance's copies get all its
+// options, including any added later, without each having to be listed there.
+type options struct {
+	mediaTypeSpecified    bool
+	deletionSpecified     bool
+	bufferOptions         *BufferOptions
+	parallel              *parallelMatching
+	eventWorkers          int
+	labels                *profilerLabels
+	labelsSpecified       bool
+	budgets               []*MemoryBudget
+	buildMode             MatcherBuildMode
+	eventBridge           bool
+	patternTexts          *patternTexts
+	patternTextSpecified  bool
+	stateLimit            int
+	stateLimitWarn        func(x X, patternJSON string, err *PatternTooBigError)
+	workLimit             int
+	arrayLimit            int
+	arrayPolicy           ArrayLimitPolicy
+	nonFinite             NonFiniteNumbers
+	nonFiniteSpecified    bool
+	numericTolerance      float64
+	foldFieldNames        bool
+	repeatedFields        RepeatedFields
+	repeatedSpecified     bool
+	hmacKey               []byte
+	collation             func(s []byte) []byte
+	derivedFields         []DerivedField
+	valueTransforms       []ValueTransform
+	lookups               map[string]Lookup
+	enrichers             *enrichments
+	maxFields             int
+	matchOrder            *matchOrder
+	matchOrderSpecified   bool
+	multiplicity          *patternKeys
+	multiplicitySpecified bool
 }
 
 // Option is an interface type used in Quamina's New API to pass in options. By convention, Option names
@@ -62,6 +108,9 @@ func WithFlattener(f Flattener) Option {
 	}
 }
 
+// ErrDeletionNotSupported is returned by DeletePatterns in instances not created WithPatternDeletion(true).
+var ErrDeletionNotSupported = errors.New("operation not supported")
+
 // WithPatternDeletion arranges, if the argument is true, that this Quamina instance will support
 // the DeletePatterns() method. This option call may not be provided more than once.
 func WithPatternDeletion(b bool) Option {
@@ -79,6 +128,126 @@ func WithPatternDeletion(b bool) Option {
 	}
 }
 
+// BufferOptions tunes the buffers each Quamina instance keeps for use in MatchesForEvent calls. These grow to fit
+// the Events and Patterns being processed, after which matching needs little or no memory allocation, but
+// services that see occasional very large Events, or that are sensitive to memory use, may want to limit them.
+type BufferOptions struct {
+	// ScratchCapacity is the initial capacity of the lists of automaton states and transitions used in matching.
+	ScratchCapacity int
+	// ResultCapacity is the initial capacity of the slice returned by MatchesForEvent.
+	ResultCapacity int
+	// MaxRetainedCapacity, if positive, is the largest capacity a buffer may keep from one MatchesForEvent call to
+	// the next; a buffer that has grown beyond it is dropped and reallocated at its initial capacity when next
+	// needed. Zero means there is no limit.
+	MaxRetainedCapacity int
+	// ClearOnReturn, if true, means the buffers are zeroed at the end of each MatchesForEvent call so that they
+	// keep nothing reachable by the garbage collector. The slice returned by MatchesForEvent is not affected.
+	ClearOnReturn bool
+}
+
+// WithBufferOptions tunes the instance's matching buffers, and those of instances created from it with Copy. Zero
+// values of ScratchCapacity and ResultCapacity mean the defaults are used. This option call may not be provided
+// more than once.
+func WithBufferOptions(opts BufferOptions) Option {
+	return func(q *Quamina) error {
+		if q.bufferOptions != nil {
+			return errors.New("buffer options specified more than once")
+		}
+		if opts.ScratchCapacity < 0 || opts.ResultCapacity < 0 || opts.MaxRetainedCapacity < 0 {
+			return errors.New("buffer capacities must not be negative")
+		}
+		if opts.ScratchCapacity == 0 {
+			opts.ScratchCapacity = defaultBufferOptions.ScratchCapacity
+		}
+		if opts.ResultCapacity == 0 {
+			opts.ResultCapacity = defaultBufferOptions.ResultCapacity
+		}
+		q.bufferOptions = &opts
+		return nil
+	}
+}
+
+// WithParallelFieldMatching arranges for MatchesForEvent calls on Events with at least minFields fields to
+// spread the work of matching the fields across as many as workers goroutines, including the calling one;
+// zero minFields means 500. Matching each field against the Patterns is independent of matching the others,
+// so for very wide Events, such as documents with thousands of fields, this can reduce the latency of
+// MatchesForEvent substantially, at the cost of more total CPU. Instances created with Copy get the same
+// setting, with their own goroutines. workers must be at least 1; with 1, this option has no effect. This
+// option call may not be provided more than once.
+func WithParallelFieldMatching(workers, minFields int) Option {
+	return func(q *Quamina) error {
+		if q.parallel != nil {
+			return errors.New("parallel field matching specified more than once")
+		}
+		if workers < 1 {
+			return errors.New("workers must be at least 1")
+		}
+		if minFields < 0 {
+			return errors.New("minFields must not be negative")
+		}
+		if minFields == 0 {
+			minFields = defaultParallelMinFields
+		}
+		q.parallel = newParallelMatching(workers, minFields)
+		return nil
+	}
+}
+
+// WithParallelEventMatching arranges for MatchesForEvents calls to spread the Events across as many as workers
+// goroutines, including the calling one, each matching with a Copy of the instance which it keeps from call
+// to call. Events are independent of each other, so for large batches this raises throughput nearly in
+// proportion to the number of workers, where WithParallelFieldMatching helps only with very wide Events.
+// Instances created with Copy get the same setting, with their own goroutines. workers must be at least 1;
+// with 1, this option has no effect. This option call may not be provided more than once.
+func WithParallelEventMatching(workers int) Option {
+	return func(q *Quamina) error {
+		if q.eventWorkers != 0 {
+			return errors.New("parallel event matching specified more than once")
+		}
+		if workers < 1 {
+			return errors.New("workers must be at least 1")
+		}
+		q.eventWorkers = workers
+		return nil
+	}
+}
+
+// WithProfilerLabels arranges, if the argument is true, that while MatchesForEvent is running, the calling
+// goroutine carries the pprof label "quamina", with the value "flatten" while the Event is being flattened
+// and "match" while its fields are being matched against the Patterns, so that CPU profiles of programs
+// using Quamina attribute time to those phases directly. The other methods which match Events do the same.
+// When they return, the goroutine is left with no labels, so callers which set labels of their own should use
+// MatchesForEventContext, which keeps them. Instances created with Copy get the same setting. This option call may not be provided more than once.
+func WithProfilerLabels(b bool) Option {
+	return func(q *Quamina) error {
+		if q.labelsSpecified {
+			return errors.New("profiler labels specified more than once")
+		}
+		if b {
+			q.labels = sharedProfilerLabels
+		}
+		q.labelsSpecified = true
+		return nil
+	}
+}
+
+// WithEventBridgeCompat makes the Quamina instance accept Patterns in the grammar of AWS EventBridge rules, and
+// match them as EventBridge does, so that rules can be tested locally. This adds the forms of "anything-but",
+// "prefix", and "suffix" that EventBridge allows, and "$or"; it also lets values which Quamina would otherwise
+// require to be alone in their array, such as "exists" and "anything-but", be combined with others. The
+// "suffix", "numeric", and "cidr" Pattern types are accepted with or without it, and the Quamina-only
+// "shellstyle" and "regexp" types remain available. See PATTERNS.md for details. This option call may not be
+// provided more than once.
+func WithEventBridgeCompat() Option {
+	return func(q *Quamina) error {
+		if q.eventBridge {
+			return errors.New("EventBridge compatibility specified more than once")
+		}
+		q.eventBridge = true
+		return nil
+	}
+}
+
 // WithPatternStorage supplies the Quamina instance with a LivePatternState
 // instance to be used to store the active patterns, i.e. those that have been
 // added with AddPattern but not deleted with DeletePattern. This option call
@@ -104,19 +273,101 @@ func New(opts ...Option) (*Quamina, error) {
 	if (!q.mediaTypeSpecified) && (q.flattener == nil) {
 		q.flattener = newJSONFlattener()
 	}
+	if q.arrayLimit > 0 {
+		fj, ok := q.flattener.(*flattenJSON)
+		if !ok {
+			return nil, errors.New("array limit is only supported by the JSON Flattener")
+		}
+		fj.arrayLimit = q.arrayLimit
+		fj.arrayPolicy = q.arrayPolicy
+	}
+	if q.nonFinite != NonFiniteError {
+		fj, ok := q.flattener.(*flattenJSON)
+		if !ok {
+			return nil, errors.New("non-finite number policy is only supported by the JSON Flattener")
+		}
+		fj.nonFinite = q.nonFinite
+	}
+	if q.valueTransforms != nil {
+		q.flattener = newTransformFlattener(q.flattener, q.valueTransforms, q.foldFieldNames)
+	}
+	// derived fields are computed from the transformed values
+	if q.derivedFields != nil {
+		if q.foldFieldNames {
+			for i := range q.derivedFields {
+				q.derivedFields[i].Source = strings.ToLower(q.derivedFields[i].Source)
+			}
+		}
+		q.flattener = &derivedFlattener{flattener: q.flattener, derived: q.derivedFields}
+	}
 	if !q.deletionSpecified {
 		q.matcher = newCoreMatcher()
 	}
-	q.bufs = newNfaBuffers()
+	if _, ok := q.matcher.(*coreMatcher); !ok && q.multiplicity != nil {
+		return nil, errors.New("match multiplicity is not supported with pattern deletion")
+	}
+	if q.bufferOptions != nil {
+		q.bufs = newNfaBuffersWith(*q.bufferOptions)
+	} else {
+		q.bufs = newNfaBuffers()
+	}
+	if q.parallel != nil && q.parallel.workers > 1 {
+		q.bufs.parallel = q.parallel
+	}
+	q.bufs.workLimit = q.workLimit
+	q.bufs.maxFields = q.maxFields
+	q.bufs.matchOrder = q.matchOrder
+	if q.multiplicity != nil {
+		q.bufs.counts = make(map[X]int)
+	}
+	if q.budgets != nil {
+		q.matcher.setMemoryBudgets(q.budgets)
+	}
+	if q.eventBridge {
+		q.matcher.setEventBridgeCompat()
+	}
+	if q.numericTolerance > 0 {
+		q.matcher.setNumericTolerance(q.numericTolerance)
+	}
+	if q.foldFieldNames {
+		q.matcher.setCaseInsensitiveFieldNames()
+	}
+	if q.repeatedFields != RepeatedAny {
+		q.matcher.setRepeatedFields(q.repeatedFields)
+	}
+	if q.hmacKey != nil {
+		q.matcher.setHMACKey(q.hmacKey)
+	}
+	if q.collation != nil {
+		q.matcher.setCollation(q.collation)
+	}
+	if q.lookups != nil {
+		q.matcher.setLookups(q.lookups)
+	}
+	if sources := derivedSources(q.derivedFields); sources != nil {
+		q.matcher.addFieldPaths(sources)
+	}
+	q.samples = newSampleRates()
 	q.buildMode = BuiltForComfort
 	return &q, nil
 }
 
 // Copy produces a new Quamina instance designed to be used safely in parallel with existing instances on different
 // goroutines.  Copy'ed instances share the same underlying data structures, so a pattern added to any instance
-// with AddPattern will be visible in all of them.
+// with AddPattern will be visible in all of them. A copy has the options the instance was created with, and its
+// MatcherBuildMode.
 func (q *Quamina) Copy() *Quamina {
-	return &Quamina{matcher: q.matcher, flattener: q.flattener.Copy(), bufs: newNfaBuffers()}
+	bufs := newNfaBuffersWith(q.bufs.opts)
+	if q.bufs.parallel != nil {
+		bufs.parallel = newParallelMatching(q.bufs.parallel.workers, q.bufs.parallel.minFields)
+	}
+	bufs.workLimit = q.workLimit
+	bufs.maxFields = q.maxFields
+	bufs.matchOrder = q.matchOrder
+	if q.multiplicity != nil {
+		bufs.counts = make(map[X]int)
+	}
+	return &Quamina{matcher: q.matcher, flattener: q.flattener.Copy(), bufs: bufs, samples: q.samples, options: q.options}
 }
 
 // X is used in the AddPattern and MatchesForEvent APIs to identify the patterns that are added to
@@ -130,13 +381,60 @@ type X any
 // from multiple goroutines (in instances created using the Copy method) calls will block until any other
 // AddPattern call in progress succeeds.
 func (q *Quamina) AddPattern(x X, patternJSON string) error {
-	return q.matcher.addPattern(x, patternJSON, q.buildMode)
+	if err := q.addPattern(x, patternJSON); err != nil {
+		return err
+	}
+	q.samples.set(x, 1)
+	return nil
+}
+
+// addPattern adds a Pattern, if it's within any state limit, along with its text if that's kept
+func (q *Quamina) addPattern(x X, patternJSON string) error {
+	if q.stateLimit > 0 {
+		if err := q.checkStateLimit(x, patternJSON); err != nil {
+			return err
+		}
+	}
+	// with multiplicity, each Pattern is added with a key of its own, which matching turns back into x
+	key := x
+	if q.multiplicity != nil {
+		key = q.multiplicity.key(x, patternJSON)
+	}
+	var err error
+	if q.eventBridge {
+		err = q.addEventBridgePattern(key, patternJSON)
+	} else {
+		err = q.matcher.addPattern(key, patternJSON, q.buildMode)
+	}
+	if err == nil && q.patternTexts != nil {
+		q.patternTexts.add(x, patternJSON)
+	}
+	if err == nil && q.matchOrder != nil {
+		q.matchOrder.add(x)
+	}
+	if err == nil && q.enrichers != nil {
+		q.enrichers.add(x, patternJSON, q.foldFieldNames)
+	}
+	return err
 }
 
 // DeletePatterns removes patterns identified by the x argument from the Quamina instance; the effect
 // is that return values from future calls to MatchesForEvent will not include this x value.
 func (q *Quamina) DeletePatterns(x X) error {
-	return q.matcher.deletePatterns(x)
+	err := q.matcher.deletePatterns(x)
+	if err == nil {
+		if q.patternTexts != nil {
+			q.patternTexts.delete(x)
+		}
+		if q.matchOrder != nil {
+			q.matchOrder.delete(x)
+		}
+		if q.enrichers != nil {
+			q.enrichers.delete(x)
+		}
+		q.samples.set(x, 1)
+	}
+	return err
 }
 
 // MatchesForEvent returns a slice of X values which identify patterns that have previously been added to this
@@ -144,21 +442,222 @@ func (q *Quamina) DeletePatterns(x X) error {
 // if no patterns match. error can be returned in case that the event is not a valid JSON object or contains
 // invalid UTF-8 byte sequences.
 func (q *Quamina) MatchesForEvent(event []byte) ([]X, error) {
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
 	fields, err := q.flattener.Flatten(event, q.matcher.getSegmentsTreeTracker())
 	if err != nil {
 		return nil, err
 	}
-	return q.matcher.matchesForFields(fields, q.bufs)
+	q.labels.matching()
+	matches, err := q.matcher.matchesForFields(fields, q.bufs)
+	q.bufs.release()
+	if err == nil && q.samples.active() {
+		matches = q.samples.filter(matches, eventHash(event))
+	}
+	return matches, err
+}
+
+// MatchesForEventContext is MatchesForEvent for callers which label their goroutines for profiling, as
+// pprof.Do does. In an instance created WithProfilerLabels, the "quamina" label is added to those carried by
+// ctx, and when it returns, the goroutine's labels are set back to ctx's, rather than removed. Otherwise ctx
+// is unused.
+func (q *Quamina) MatchesForEventContext(ctx context.Context, event []byte) ([]X, error) {
+	if q.labels == nil {
+		return q.MatchesForEvent(event)
+	}
+	shared := q.labels
+	q.labels = newProfilerLabels(ctx)
+	defer func() { q.labels = shared }()
+	return q.MatchesForEvent(event)
+}
+
+// MatchesForEventInto is like MatchesForEvent, but appends the matches to dst and returns the result, so that
+// the caller owns it. The slice returned by MatchesForEvent belongs to the Quamina instance and is overwritten by
+// the next call, so callers who keep it have to copy it; with MatchesForEventInto, a caller who reuses dst, as
+// in dst, err = q.MatchesForEventInto(event, dst[:0]), matches without any heap allocation once the instance's
+// internal buffers and dst have grown to fit the workload.
+func (q *Quamina) MatchesForEventInto(event []byte, dst []X) ([]X, error) {
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	fields, err := q.flattener.Flatten(event, q.matcher.getSegmentsTreeTracker())
+	if err != nil {
+		return dst, err
+	}
+	q.labels.matching()
+	matches, err := q.matcher.matchesForFieldsInto(fields, q.bufs, dst)
+	q.bufs.release()
+	if err == nil && q.samples.active() {
+		kept := q.samples.filter(matches[len(dst):], eventHash(event))
+		matches = matches[:len(dst)+len(kept)]
+	}
+	return matches, err
+}
+
+// CountMatchesForEvent returns the number of distinct X values that MatchesForEvent would return for the
+// event, for consumers such as metrics which need to know how many Patterns matched but not which. It
+// doesn't make the slice of matches, so it never allocates once the instance's buffers have grown to fit.
+func (q *Quamina) CountMatchesForEvent(event []byte) (int, error) {
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	fields, err := q.flattener.Flatten(event, q.matcher.getSegmentsTreeTracker())
+	if err != nil {
+		return 0, err
+	}
+	q.labels.matching()
+	if q.samples.active() {
+		matches, err := q.matcher.matchesForFields(fields, q.bufs)
+		q.bufs.release()
+		return len(q.samples.filter(matches, eventHash(event))), err
+	}
+	count, err := q.matcher.countForFields(fields, q.bufs)
+	q.bufs.release()
+	return count, err
+}
+
+// MatchesForEvents returns, for each of the Events, the X values of the Patterns which match it, as
+// MatchesForEvent would. It's faster than calling MatchesForEvent for each Event, since the instance's
+// Flattener and buffers are set up once for the batch, and the results share a few large slices, which,
+// unlike MatchesForEvent's, belong to the caller. An instance created WithParallelEventMatching matches the
+// Events on several goroutines. An Event which can't be matched doesn't stop the others from being matched;
+// its result is nil, and the error returned joins an *EventError for each such Event, in the order of the
+// Events.
+func (q *Quamina) MatchesForEvents(events [][]byte) ([][]X, error) {
+	results := make([][]X, len(events))
+	var next atomic.Int64
+	workers := min(q.eventWorkers, len(events))
+	if workers <= 1 {
+		return results, errors.Join(q.matchEventsFrom(events, results, &next)...)
+	}
+
+	for len(q.eventHelpers) < workers-1 {
+		helper := q.Copy()
+		helper.eventWorkers = 0
+		q.eventHelpers = append(q.eventHelpers, helper)
+	}
+	errs := make([][]error, workers)
+	var wg sync.WaitGroup
+	for i, helper := range q.eventHelpers[:workers-1] {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i+1] = helper.matchEventsFrom(events, results, &next)
+		}()
+	}
+	errs[0] = q.matchEventsFrom(events, results, &next)
+	wg.Wait()
+
+	allErrs := slices.Concat(errs...)
+	slices.SortFunc(allErrs, func(a, b error) int {
+		return a.(*EventError).Index - b.(*EventError).Index
+	})
+	return results, errors.Join(allErrs...)
+}
+
+// matchEventsFrom matches the Events whose indexes it takes from next, until there are none left, storing the
+// results in results and returning the errors, so that any number of instances can share the work
+func (q *Quamina) matchEventsFrom(events [][]byte, results [][]X, next *atomic.Int64) []error {
+	var errs []error
+	var matches []X
+	for {
+		i := int(next.Add(1) - 1)
+		if i >= len(events) {
+			return errs
+		}
+		start := len(matches)
+		got, err := q.MatchesForEventInto(events[i], matches)
+		if err != nil {
+			errs = append(errs, &EventError{Index: i, Err: err})
+			continue
+		}
+		matches = got
+		results[i] = matches[start:len(matches):len(matches)]
+	}
+}
+
+// EventError is the error for an Event that MatchesForEvents couldn't match.
+type EventError struct {
+	Index int // the Event's index in the batch
+	Err   error
+}
+
+func (e *EventError) Error() string {
+	return fmt.Sprintf("event %d: %v", e.Index, e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+// MatchesForStruct returns the X values of the Patterns which match v, which must be, or point to, a struct or a
+// map, as they would match json.Marshal(v), but without the cost of encoding v and flattening the result. It
+// follows json.Marshal's rules, including those for json tags, embedded structs, and MarshalJSON methods; values
+// such as channels, which json.Marshal can't encode, are errors. This is for in-process event buses which pass
+// typed values; MatchesForStruct doesn't use the instance's Flattener. As with MatchesForEvent, the returned
+// slice is overwritten by the next call.
+func (q *Quamina) MatchesForStruct(v any) ([]X, error) {
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	if q.structFlattener == nil {
+		q.structFlattener = newStructFlattener()
+	}
+	fields, err := q.structFlattener.flatten(v, q.matcher.getSegmentsTreeTracker())
+	if err != nil {
+		return nil, err
+	}
+	q.labels.matching()
+	matches, err := q.matcher.matchesForFields(fields, q.bufs)
+	q.bufs.release()
+	if err == nil && q.samples.active() {
+		matches = q.samples.filter(matches, fieldsHash(fields))
+	}
+	return matches, err
+}
+
+// AddFieldPaths declares that the fields with the provided paths should be extracted from Events by the
+// Flattener, as though they were used in Patterns. Ordinarily the Flattener extracts only the fields that
+// Patterns use, and skips everything else, including whole objects that no Pattern reaches into. A path is
+// made of the member names leading to the field, separated by SegmentSeparator; for example,
+// "context\nuser\nid". JoinPath makes the paths of fields whose member names contain SegmentSeparator.
+// Adding paths doesn't change what matches.
+func (q *Quamina) AddFieldPaths(paths ...string) error {
+	for _, path := range paths {
+		if path == "" {
+			return errors.New("empty field path")
+		}
+	}
+	q.matcher.addFieldPaths(paths)
+	return nil
+}
+
+// FieldPaths returns, sorted, the paths of all the fields the Flattener extracts from Events, which are those
+// used in Patterns and those provided to AddFieldPaths. Callers which filter or trim Events before matching
+// them can use it to know which parts of the Events Quamina needs, and embedders can use it to configure the
+// producers or serializers upstream to send only those fields; the paths are made as by JoinPath, so
+// splitting them on SegmentSeparator gives the member names from the outermost in, as the example shows.
+func (q *Quamina) FieldPaths() []string {
+	if tree, ok := q.matcher.getSegmentsTreeTracker().(*segmentsTree); ok {
+		return tree.paths()
+	}
+	return nil
 }
 
 // GetMatcherStats retrieves resource consumption data from a Quamina instance; its results depend only
 // on the AddPattern() calls that have been made previously. It runs in read-only mode without mutex
 // locking, so it should not be run in parallel with AddPattern() calls.
-// It returns a map to allow for the addition of consumption metrics in future. At the moment, the
-// only useful key is "bytes" and the corresponding value is the number of bytes consumed by the Quamina
-// matcher's data structures. The growth in this value correlates reasonably well with the slowdown
-// in AddPattern() and MatchesForEvent() performance in the case when the Patterns being added are
-// of the "wildcard" or "regexp" flavors.
+// It returns a map to allow for the addition of consumption metrics in future. The most useful key is "bytes"
+// and the corresponding value is the number of bytes consumed by the Quamina matcher's data structures.
+// The growth in this value correlates reasonably well with the slowdown in AddPattern() and MatchesForEvent()
+// performance in the case when the Patterns being added are of the "wildcard" or "regexp" flavors.
+// The value for "prefixFastPaths" is the number of fields whose Patterns are all "prefix" and are
+// being matched by a radix tree rather than an automaton.
 func (q *Quamina) GetMatcherStats() map[string]float64 {
 	stats := q.matcher.getStats()
 	return map[string]float64{
@@ -166,9 +665,33 @@ func (q *Quamina) GetMatcherStats() map[string]float64 {
 		"bytes":     float64(stats.bytes),
 		"fanouts":   float64(stats.fanouts),
 		"maxFanout": float64(stats.maxFanout),
+
+		"prefixFastPaths": float64(stats.prefixFastPaths),
 	}
 }
 
+// Compact merges the duplicate states that accumulate in the automata built for a Quamina instance's Patterns,
+// reducing its memory use and often speeding up MatchesForEvent because there is less memory to traverse.
+// Each AddPattern call builds states for its Pattern and merges them with the existing ones, so after many
+// calls, particularly with Patterns of the "wildcard", "shellstyle", and "regexp" flavors, there are often
+// many states that are indistinguishable; Compact is a good thing to call after adding a large batch of
+// Patterns. It does not change which Patterns match any Event. Like AddPattern, it may be called while
+// MatchesForEvent calls are in progress in other instances created with Copy, and blocks any AddPattern calls
+// until it is finished. The effect can be seen with GetMatcherStats.
+func (q *Quamina) Compact() {
+	q.matcher.compact(false)
+}
+
+// Minimize does what Compact does, and also minimizes the parts of the automata that are deterministic, merging
+// all the states which lead to the same results for every possible remainder of a value, not just those which
+// are exact duplicates. With large numbers of Patterns, especially literal-heavy ones built in BuiltForSpeed
+// mode, this can substantially reduce the number of states and speed up MatchesForEvent. It takes longer than
+// Compact, so is intended for instances whose Patterns are all in place; AddPattern may still be called
+// afterward, but its new states won't be minimized until Minimize is called again.
+func (q *Quamina) Minimize() {
+	q.matcher.compact(true)
+}
+
 // MatcherBuildMode enumerates the modes a Quamina instance can be in. The default is BuiltForComfort.
 // When a Quamina instance is in BuiltForComfort mode, adding Patterns which include wildcards and regexps
 // result in NFA-based matchers. These are more compact and faster to build, but result in MatchesForEvent
@@ -203,7 +726,7 @@ func (q *Quamina) GetMatcherBuildMode() MatcherBuildMode {
 
 // SetMemoryBudget used to set an approximate limit on the number of bytes allocated in building matchers for complex
 // patterns. However, it proved difficult to find an implementation that was both deterministic and had
-// acceptable cost. Thus, this method is deprecated.
+// acceptable cost. Thus, this method is deprecated; use WithMemoryBudget.
 func (q *Quamina) SetMemoryBudget(budget uint64) (uint64, error) {
 	return 0, errors.New("the MemoryBudget API is deprecated")
 }
@@ -1,7 +1,11 @@
 package quamina
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -17,6 +21,64 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+// TestCopyKeepsOptions sets every option, then checks that a Copy has them all. An option added without
+// being set here fails the test, so that this keeps checking every one of them.
+func TestCopyKeepsOptions(t *testing.T) {
+	budget, _ := NewMemoryBudget(1 << 30)
+	q, err := New(
+		WithMediaType("application/json"),
+		WithPatternDeletion(false),
+		WithBufferOptions(BufferOptions{}),
+		WithParallelFieldMatching(2, 0),
+		WithParallelEventMatching(2),
+		WithProfilerLabels(true),
+		WithMemoryBudget(budget),
+		WithEventBridgeCompat(),
+		WithPatternText(true),
+		WithPatternStateLimit(1000, func(X, string, *PatternTooBigError) {}),
+		WithWorkLimit(1000),
+		WithArrayLimit(10, ArrayLimitTruncate),
+		WithNonFiniteNumbers(NonFiniteAsStrings),
+		WithNumericTolerance(0.01),
+		WithCaseInsensitiveFieldNames(),
+		WithRepeatedFields(RepeatedAll),
+		WithHMACKey([]byte("key")),
+		WithCollation(bytes.ToLower),
+		WithDerivedFields(DerivedField{Name: "len", Compute: func(val []byte) ([]byte, bool) { return nil, false }}),
+		WithValueTransforms(ValueTransform{Path: "a", Transform: bytes.ToUpper}),
+		WithLookups(map[string]Lookup{"set": newLiveSet()}),
+		WithEnrichers(map[X]Enricher{"x": func([]Field) map[string]string { return nil }}),
+		WithMaxFields(100),
+		WithOrderedMatches(true),
+		WithMatchMultiplicity(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SetMatcherBuildMode(BuiltForSpeed); err != nil {
+		t.Fatal(err)
+	}
+
+	original, copied := reflect.ValueOf(q.options), reflect.ValueOf(q.Copy().options)
+	for i := 0; i < original.NumField(); i++ {
+		name := original.Type().Field(i).Name
+		if original.Field(i).IsZero() {
+			t.Errorf("%s isn't set by this test", name)
+			continue
+		}
+		var same bool
+		switch field := original.Field(i); field.Kind() {
+		case reflect.Func, reflect.Map, reflect.Pointer, reflect.Slice:
+			same = field.Pointer() == copied.Field(i).Pointer()
+		default:
+			same = field.Equal(copied.Field(i))
+		}
+		if !same {
+			t.Errorf("Copy didn't keep %s", name)
+		}
+	}
+}
+
 func TestSetBuildModeDisabled(t *testing.T) {
 	q, err := New(WithPatternDeletion(true))
 	if err != nil {
@@ -28,6 +90,184 @@ func TestSetBuildModeDisabled(t *testing.T) {
 	}
 }
 
+// matchesIntoSetup makes a Quamina instance with a mix of pattern types, and an event that matches them all
+func matchesIntoSetup(t testing.TB, opts ...Option) (*Quamina, []byte) {
+	t.Helper()
+	q, _ := New(opts...)
+	patterns := map[X]string{
+		"exact":    `{"a": ["x"], "b": [{"prefix": "y"}]}`,
+		"shell":    `{"c": [{"shellstyle": "*z*"}]}`,
+		"regexp":   `{"d": [{"regexp": "a+b"}], "e": {"f": [1, 2, 3]}}`,
+		"absent":   `{"g": [{"exists": false}]}`,
+		"notQ":     `{"h": [{"anything-but": ["q"]}]}`,
+		"nocase":   `{"h": [{"equals-ignore-case": "R"}]}`,
+		"wildcard": `{"i": {"j": [{"wildcard": "k*"}]}}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := []byte(`{"a": "x", "b": "yes", "c": "zzz", "d": "aab", "e": {"f": 2.0}, "h": "r", "i": [1, {"j": "kk"}]}`)
+	return q, event
+}
+
+func TestMatchesForEventInto(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, event := matchesIntoSetup(t, WithPatternDeletion(deletion))
+		wanted := []X{"exact", "shell", "regexp", "absent", "notQ", "nocase", "wildcard"}
+		dst := []X{"already", "here"}
+		got, err := q.MatchesForEventInto(event, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2+len(wanted) || got[0] != "already" || got[1] != "here" || !containsExactly(got[2:], wanted) {
+			t.Errorf("deletion %v: got %v", deletion, got)
+		}
+
+		// results must not be disturbed by later calls
+		kept := got
+		got, err = q.MatchesForEventInto([]byte(`{"a": "x", "b": "y"}`), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(got, []X{"exact", "absent"}) || !containsExactly(kept[2:], wanted) {
+			t.Errorf("deletion %v: got %v, kept %v", deletion, got, kept)
+		}
+
+		if deletion {
+			if err = q.DeletePatterns("shell"); err != nil {
+				t.Fatal(err)
+			}
+			got, _ = q.MatchesForEventInto(event, dst[:1])
+			if got[0] != "already" || !containsExactly(got[1:], []X{"exact", "regexp", "absent", "notQ", "nocase", "wildcard"}) {
+				t.Errorf("after deletion got %v", got)
+			}
+		}
+
+		if _, err = q.MatchesForEventInto([]byte(`{"a": `), nil); err == nil {
+			t.Error("accepted bad JSON")
+		}
+	}
+}
+
+// TestMatchesForEventIntoAllocs is the gate on allocation-free steady-state matching
+func TestMatchesForEventIntoAllocs(t *testing.T) {
+	q, event := matchesIntoSetup(t)
+	var dst []X
+	allocs := testing.AllocsPerRun(100, func() {
+		dst, _ = q.MatchesForEventInto(event, dst[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per match", allocs)
+	}
+	if len(dst) != 7 {
+		t.Errorf("%d matches", len(dst))
+	}
+}
+
+func TestCountMatchesForEvent(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, event := matchesIntoSetup(t, WithPatternDeletion(deletion))
+		// a second Pattern with the same X is counted once
+		if err := q.AddPattern("exact", `{"a": ["x"]}`); err != nil {
+			t.Fatal(err)
+		}
+		count, err := q.CountMatchesForEvent(event)
+		if err != nil || count != 7 {
+			t.Errorf("deletion %v: count %d, %v", deletion, count, err)
+		}
+		if deletion {
+			_ = q.DeletePatterns("shell")
+			if count, _ = q.CountMatchesForEvent(event); count != 6 {
+				t.Errorf("after deletion count %d", count)
+			}
+		}
+		if _, err = q.CountMatchesForEvent([]byte(`{"a": `)); err == nil {
+			t.Error("accepted bad JSON")
+		}
+	}
+
+	q, event := matchesIntoSetup(t)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = q.CountMatchesForEvent(event)
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocations per count", allocs)
+	}
+}
+
+func BenchmarkMatchesForEventInto(b *testing.B) {
+	q, event := matchesIntoSetup(b)
+	var dst []X
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst, _ = q.MatchesForEventInto(event, dst[:0])
+	}
+}
+
+func TestBufferOptions(t *testing.T) {
+	if _, err := New(WithBufferOptions(BufferOptions{ScratchCapacity: -1})); err == nil {
+		t.Error("accepted negative capacity")
+	}
+	if _, err := New(WithBufferOptions(BufferOptions{}), WithBufferOptions(BufferOptions{})); err == nil {
+		t.Error("accepted options twice")
+	}
+	q, _ := New(WithBufferOptions(BufferOptions{MaxRetainedCapacity: 4}))
+	if q.bufs.opts.ScratchCapacity != 16 || q.bufs.opts.ResultCapacity != 16 || q.Copy().bufs.opts != q.bufs.opts {
+		t.Errorf("options %+v", q.bufs.opts)
+	}
+
+	// MaxRetainedCapacity: enough matches to grow the result buffer past the limit
+	q, event := matchesIntoSetup(t, WithBufferOptions(BufferOptions{ResultCapacity: 2, MaxRetainedCapacity: 4}))
+	if cap(q.bufs.resultBuf) != 2 {
+		t.Errorf("initial result capacity %d", cap(q.bufs.resultBuf))
+	}
+	matches, _ := q.MatchesForEvent(event)
+	if len(matches) != 7 || q.bufs.resultBuf != nil || q.bufs.matches != nil {
+		t.Errorf("%d matches, kept %d results and %v", len(matches), cap(q.bufs.resultBuf), q.bufs.matches)
+	}
+	matches, _ = q.MatchesForEvent([]byte(`{"a": "x", "b": "y"}`))
+	if len(matches) != 2 || cap(q.bufs.resultBuf) != 2 || q.bufs.matches == nil {
+		t.Errorf("%d matches, kept %d results", len(matches), cap(q.bufs.resultBuf))
+	}
+
+	// ClearOnReturn
+	q, event = matchesIntoSetup(t, WithBufferOptions(BufferOptions{ClearOnReturn: true}))
+	matches, _ = q.MatchesForEvent(event)
+	if len(matches) != 7 {
+		t.Errorf("%d matches", len(matches))
+	}
+	if cap(q.bufs.buf1) == 0 {
+		t.Error("buf1 not used")
+	}
+	for _, state := range q.bufs.buf1[:cap(q.bufs.buf1)] {
+		if state != nil {
+			t.Error("buf1 not cleared")
+		}
+	}
+	for _, level := range q.bufs.transmap.levels {
+		for _, fm := range level[:cap(level)] {
+			if fm != nil {
+				t.Error("transmap not cleared")
+			}
+		}
+	}
+	if len(q.bufs.matches.set) != 0 {
+		t.Error("matchSet not cleared")
+	}
+	matches, _ = q.MatchesForEvent([]byte(`{"a": "x", "b": "y"}`))
+	if !containsExactly(matches, []X{"exact", "absent"}) {
+		t.Errorf("got %v", matches)
+	}
+	for _, x := range matches[len(matches):cap(matches)] {
+		if x != nil {
+			t.Error("stale results kept")
+		}
+	}
+}
+
 func TestNewQOptions(t *testing.T) {
 	var q *Quamina
 	var err error
@@ -189,3 +429,123 @@ func TestCityLots(t *testing.T) {
 		}
 	}
 }
+
+func TestFieldPaths(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, _ := New(WithPatternDeletion(deletion))
+		if err := q.AddPattern("p", `{"a": [1], "b": {"c": ["x"]}}`); err != nil {
+			t.Fatal(err)
+		}
+		want := func(wanted ...string) {
+			t.Helper()
+			got := q.FieldPaths()
+			if fmt.Sprint(got) != fmt.Sprint(wanted) {
+				t.Errorf("deletion=%v: got %q wanted %q", deletion, got, wanted)
+			}
+		}
+		want("a", "b\nc")
+
+		if err := q.AddFieldPaths("b\nd", "z"); err != nil {
+			t.Fatal(err)
+		}
+		if q.AddFieldPaths("y", "") == nil {
+			t.Error("empty path accepted")
+		}
+		want("a", "b\nc", "b\nd", "z")
+
+		// the flattener now extracts the declared fields, and matching is unaffected
+		event := []byte(`{"a": 1, "b": {"c": "x", "d": "y", "e": "no"}, "z": [true], "q": 0}`)
+		fields, err := q.flattener.Copy().Flatten(event, q.matcher.getSegmentsTreeTracker())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, field := range fields {
+			paths = append(paths, string(field.Path))
+		}
+		if fmt.Sprint(paths) != fmt.Sprint([]string{"a", "b\nc", "b\nd", "z"}) {
+			t.Errorf("deletion=%v: flattened %q", deletion, paths)
+		}
+		matches, err := q.MatchesForEvent(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(matches, []X{"p"}) {
+			t.Errorf("deletion=%v: matches %v", deletion, matches)
+		}
+
+		// declared paths survive the pruner's rebuilds
+		if pruner, ok := q.matcher.(*prunerMatcher); ok {
+			if err := pruner.rebuild(false); err != nil {
+				t.Fatal(err)
+			}
+			want("a", "b\nc", "b\nd", "z")
+		}
+	}
+}
+
+func TestMatchesForEvents(t *testing.T) {
+	var events [][]byte
+	for i := 0; i < 200; i++ {
+		events = append(events, []byte(fmt.Sprintf(`{"n": %d, "kind": "k%d"}`, i, i%3)))
+	}
+	events[17] = []byte(`{"n": `)
+	events[150] = []byte(`not an event`)
+
+	for _, workers := range []int{1, 4} {
+		q, _ := New(WithParallelEventMatching(workers))
+		want, _ := New()
+		for _, pattern := range []string{`{"kind": ["k0"]}`, `{"kind": ["k1", "k2"]}`, `{"n": [5, 150, 151]}`} {
+			if err := q.AddPattern(pattern, pattern); err != nil {
+				t.Fatal(err)
+			}
+			_ = want.AddPattern(pattern, pattern)
+		}
+		// the second time round, the instance's helpers are reused
+		for round := 0; round < 2; round++ {
+			got, err := q.MatchesForEvents(events)
+			if len(got) != len(events) {
+				t.Fatalf("%d workers: %d results for %d events", workers, len(got), len(events))
+			}
+			for i, event := range events {
+				wantMatches, wantErr := want.MatchesForEvent(event)
+				if (wantErr != nil && got[i] != nil) || !containsExactly(got[i], wantMatches) {
+					t.Errorf("%d workers: event %d got %v, want %v", workers, i, got[i], wantMatches)
+				}
+			}
+			var eventErr *EventError
+			if !errors.As(err, &eventErr) || eventErr.Index != 17 {
+				t.Errorf("%d workers: error %v", workers, err)
+			}
+			if !strings.Contains(err.Error(), "event 150: ") {
+				t.Errorf("%d workers: error %v", workers, err)
+			}
+		}
+		if copied := q.Copy(); copied.eventWorkers != workers {
+			t.Errorf("copy has %d workers", copied.eventWorkers)
+		}
+	}
+
+	// an error from the matcher, rather than the Flattener, doesn't disturb the other Events' results
+	pruned, _ := New(WithPatternDeletion(true), WithWorkLimit(50))
+	_ = pruned.AddPattern("p", `{"x": [{"regexp": "(a|b)*b"}]}`)
+	long := []byte(`{"x": "` + strings.Repeat("ab", 100) + `"}`)
+	results, err := pruned.MatchesForEvents([][]byte{[]byte(`{"x": "ab"}`), long, []byte(`{"x": "b"}`)})
+	if len(results[0]) != 1 || results[1] != nil || len(results[2]) != 1 || !errors.Is(err, ErrWorkLimitExceeded) {
+		t.Errorf("pruner: %v, %v", results, err)
+	}
+
+	q, _ := New(WithParallelEventMatching(3))
+	got, err := q.MatchesForEvents(nil)
+	if len(got) != 0 || err != nil {
+		t.Errorf("got %v, %v", got, err)
+	}
+	for _, opts := range [][]Option{
+		{WithParallelEventMatching(0)},
+		{WithParallelEventMatching(2), WithParallelEventMatching(2)},
+	} {
+		if _, err := New(opts...); err == nil {
+			t.Errorf("%d options accepted", len(opts))
+		}
+	}
+}
@@ -72,3 +72,36 @@ func testConcurrency(t *testing.T, m matcher) {
 	}
 	wg.Wait()
 }
+
+// TestConcurrencyNFA matches values which need nondeterministic automata, from several instances made by
+// Copy, while Patterns are being added; run with -race, it checks that matching writes nothing to the
+// shared automata.
+func TestConcurrencyNFA(t *testing.T) {
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		q, _ := New()
+		_ = q.SetMatcherBuildMode(mode)
+		_ = q.AddPattern("re", `{"x": [{"regexp": "(a|b)*a(a|b)"}]}`)
+		_ = q.AddPattern("wild", `{"x": [{"wildcard": "*ab*ba*"}]}`)
+
+		var wg sync.WaitGroup
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(instance *Quamina) {
+				defer wg.Done()
+				for i := 0; i < 200; i++ {
+					matches, err := instance.MatchesForEvent([]byte(`{"x": "babbaab", "y": "123"}`))
+					if err != nil || !containsExactly(matches, []X{"re", "wild"}) {
+						t.Errorf("mode %v: %v, %v", mode, matches, err)
+						return
+					}
+				}
+			}(q.Copy())
+		}
+		for i := 0; i < 50; i++ {
+			if err := q.AddPattern(i, fmt.Sprintf(`{"y": [{"wildcard": "*%c*"}]}`, 'a'+rune(i%26))); err != nil {
+				t.Error(err)
+			}
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,382 @@
+package quamina
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"unsafe"
+)
+
+// numericRange is the parsed form of a "numeric" pattern such as {"numeric": [">", 0, "<=", 5]}. A missing
+// bound is an infinite one.
+type numericRange struct {
+	lo, hi         float64
+	loOpen, hiOpen bool
+}
+
+func (r numericRange) contains(f float64) bool {
+	return (r.lo < f || (r.lo == f && !r.loOpen)) && (f < r.hi || (f == r.hi && !r.hiOpen))
+}
+
+// containsBig is contains for a number out of the float64 range, which can't be equal to either bound
+func (r numericRange) containsBig(v *big.Float) bool {
+	return big.NewFloat(r.lo).Cmp(v) < 0 && v.Cmp(big.NewFloat(r.hi)) < 0
+}
+
+// readNumericSpecial reads the array of one or two comparisons in a "numeric" pattern. "=" must appear alone;
+// otherwise there may be one of ">" and ">=" and one of "<" and "<=".
+func readNumericSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("value for 'numeric' must be an array")
+	}
+	r := numericRange{lo: math.Inf(-1), hi: math.Inf(1)}
+	var ops []string
+	for {
+		t, err = pb.jd.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := t.(json.Delim); ok && delim == ']' {
+			break
+		}
+		op, ok := t.(string)
+		if !ok {
+			return nil, errors.New("'numeric' comparisons must be an operator followed by a number")
+		}
+		t, err = pb.jd.Token()
+		if err != nil {
+			return nil, err
+		}
+		num, ok := t.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("'numeric' operator %s must be followed by a number", op)
+		}
+		f, err := parseNumber([]byte(num))
+		if err != nil {
+			return nil, fmt.Errorf("'numeric' can't use %s: %w", num, err)
+		}
+		switch op {
+		case "=":
+			r.lo, r.hi = f, f
+		case ">", ">=":
+			r.lo, r.loOpen = f, op == ">"
+		case "<", "<=":
+			r.hi, r.hiOpen = f, op == "<"
+		default:
+			return nil, fmt.Errorf("unknown 'numeric' operator %q", op)
+		}
+		ops = append(ops, op)
+	}
+	switch {
+	case len(ops) == 0:
+		return nil, errors.New("empty 'numeric' comparison list")
+	case len(ops) > 2 || (len(ops) == 2 && (ops[0] == "=" || ops[1] == "=" || ops[0][0] == ops[1][0])):
+		return nil, errors.New(`'numeric' allows "=" alone, or at most one lower and one upper bound`)
+	case r.lo > r.hi || (r.lo == r.hi && (r.loOpen || r.hiOpen)):
+		return nil, errors.New("'numeric' range contains no numbers")
+	}
+
+	// has to be } or tokenizer will throw error
+	if _, err = pb.jd.Token(); err != nil {
+		return nil, err
+	}
+	return append(valsIn, typedVal{vType: numericType, numeric: r}), nil
+}
+
+// readCIDRSpecial reads a "cidr" pattern, an IPv4 or IPv6 address block in CIDR notation such as "10.0.0.0/8"
+func readCIDRSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	block, ok := t.(string)
+	if !ok {
+		return nil, errors.New("value for 'cidr' must be a string")
+	}
+	prefix, err := netip.ParsePrefix(block)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'cidr' value: %w", err)
+	}
+
+	// has to be } or tokenizer will throw error
+	if _, err = pb.jd.Token(); err != nil {
+		return nil, err
+	}
+	return append(valsIn, typedVal{vType: cidrType, cidr: prefix.Masked()}), nil
+}
+
+// rangeMatcher handles, for one valueMatcher, the "numeric", "cidr", "timestamp", "duration", "soundslike",
+// "sha256", "hmac-sha256", and "lookup" patterns, and with WithCollation, plain strings. Whether a number,
+// instant, or duration falls in a range, an address in a block, a name has a Soundex code, a string a digest,
+// or a value is in a Lookup, is easy to compute once the value is parsed and painful to express as an
+// automaton over its text, so these are kept out of the automaton and each value is checked against them in
+// turn. A field seldom has more than a few, so there's no need for anything cleverer than a list, except for
+// digests, which may be a long allowlist and are looked up in a map.
+//
+// Like the substringMatcher, a rangeMatcher is never updated once built; addTransition makes a new one and
+// swaps it into the valueMatcher's vmFields, so that concurrent matching is unaffected.
+type rangeMatcher struct {
+	numerics   []numericTest
+	cidrs      []cidrTest
+	timestamps []timestampTest
+	durations  []durationTest
+	phonetics  []phoneticTest
+	digests    map[[sha256.Size]byte]*fieldMatcher // never updated once stored, like vmFields.exacts
+	hmacs      map[[sha256.Size]byte]*fieldMatcher // the same, for "hmac-sha256" patterns, whose digests use hmacKey
+	hmacKey    []byte
+	collated   map[string]*fieldMatcher // the collation keys of strings, never updated once stored
+	collation  func(s []byte) []byte
+	lookups    []lookupTest
+}
+
+type numericTest struct {
+	r    numericRange
+	next *fieldMatcher
+}
+
+type cidrTest struct {
+	block netip.Prefix
+	next  *fieldMatcher
+}
+
+// with returns a rangeMatcher which adds the range or block in val to those in rm, along with the
+// fieldMatcher to transition to when a value falls in it. As with singleton string matches, one that is
+// already present gets its existing transition. rm may be nil.
+func (rm *rangeMatcher) with(val typedVal) (*rangeMatcher, *fieldMatcher) {
+	fresh := &rangeMatcher{}
+	if rm != nil {
+		fresh.numerics = append(fresh.numerics, rm.numerics...)
+		fresh.cidrs = append(fresh.cidrs, rm.cidrs...)
+		fresh.timestamps = append(fresh.timestamps, rm.timestamps...)
+		fresh.durations = append(fresh.durations, rm.durations...)
+		fresh.phonetics = append(fresh.phonetics, rm.phonetics...)
+		fresh.lookups = append(fresh.lookups, rm.lookups...)
+		fresh.digests = rm.digests
+		fresh.hmacs, fresh.hmacKey = rm.hmacs, rm.hmacKey
+		fresh.collated, fresh.collation = rm.collated, rm.collation
+	}
+	nextField := newFieldMatcher()
+	switch val.vType {
+	case numericType:
+		for _, test := range fresh.numerics {
+			if test.r == val.numeric {
+				return rm, test.next
+			}
+		}
+		fresh.numerics = append(fresh.numerics, numericTest{r: val.numeric, next: nextField})
+	case timestampType:
+		for _, test := range fresh.timestamps {
+			if test.r == val.timestamp {
+				return rm, test.next
+			}
+		}
+		fresh.timestamps = append(fresh.timestamps, timestampTest{r: val.timestamp, next: nextField})
+	case durationType:
+		for _, test := range fresh.durations {
+			if test.r == val.duration {
+				return rm, test.next
+			}
+		}
+		fresh.durations = append(fresh.durations, durationTest{r: val.duration, next: nextField})
+	case soundsLikeType:
+		code, _ := soundex([]byte(val.val[1 : len(val.val)-1]))
+		for _, test := range fresh.phonetics {
+			if test.code == code {
+				return rm, test.next
+			}
+		}
+		fresh.phonetics = append(fresh.phonetics, phoneticTest{code: code, next: nextField})
+	case sha256Type:
+		if next, ok := fresh.digests[val.digest]; ok {
+			return rm, next
+		}
+		fresh.digests = withDigest(fresh.digests, val.digest, nextField)
+	case hmacSHA256Type:
+		if next, ok := fresh.hmacs[val.digest]; ok {
+			return rm, next
+		}
+		fresh.hmacs = withDigest(fresh.hmacs, val.digest, nextField)
+		fresh.hmacKey = val.key
+	case lookupType:
+		for _, test := range fresh.lookups {
+			if test.name == val.val {
+				return rm, test.next
+			}
+		}
+		fresh.lookups = append(fresh.lookups, lookupTest{name: val.val, lookup: val.lookup, next: nextField})
+	case collatedType:
+		if next, ok := fresh.collated[val.val]; ok {
+			return rm, next
+		}
+		collated := make(map[string]*fieldMatcher, len(fresh.collated)+1)
+		for key, next := range fresh.collated {
+			collated[key] = next
+		}
+		collated[val.val] = nextField
+		fresh.collated, fresh.collation = collated, val.collation
+	default:
+		for _, test := range fresh.cidrs {
+			if test.block == val.cidr {
+				return rm, test.next
+			}
+		}
+		fresh.cidrs = append(fresh.cidrs, cidrTest{block: val.cidr, next: nextField})
+	}
+	return fresh, nextField
+}
+
+// transitionOn adds the transitions for the ranges the event field's value falls in. Only numbers can be
+// in numeric ranges and only strings in address blocks, timestamp and duration ranges, Soundex codes, and digests.
+// Lookups are asked about every value.
+func (rm *rangeMatcher) transitionOn(eventField *Field, transitions []*fieldMatcher) []*fieldMatcher {
+	val := eventField.Val
+	if len(rm.numerics) > 0 && eventField.IsNumber {
+		f, ok := eventField.number, eventField.hasNumber
+		var err error
+		if !ok {
+			f, err = parseNumber(val)
+			ok = err == nil
+		}
+		if ok {
+			for _, test := range rm.numerics {
+				if test.r.contains(f) {
+					transitions = append(transitions, test.next)
+				}
+			}
+		} else if errors.Is(err, errNumberOutOfRange) {
+			// a big.Float's exponent has room for any number an Event is likely to hold
+			if v, _, err := big.ParseFloat(string(val), 10, 64, big.ToNearestEven); err == nil {
+				for _, test := range rm.numerics {
+					if test.r.containsBig(v) {
+						transitions = append(transitions, test.next)
+					}
+				}
+			}
+		}
+	}
+	if len(rm.cidrs) > 0 && len(val) > 2 && val[0] == '"' {
+		// ParseAddr keeps nothing of the address it's given except an IPv6 zone, and zoned addresses aren't
+		// in any block, so they're skipped and the value needn't be copied
+		inner := val[1 : len(val)-1]
+		if bytes.IndexByte(inner, '%') < 0 {
+			if addr, err := netip.ParseAddr(unsafe.String(&inner[0], len(inner))); err == nil {
+				for _, test := range rm.cidrs {
+					if test.block.Contains(addr) {
+						transitions = append(transitions, test.next)
+					}
+				}
+			}
+		}
+	}
+	if len(rm.timestamps) > 0 {
+		if t, ok := parseTimestamp(val); ok {
+			for _, test := range rm.timestamps {
+				if test.r.contains(t) {
+					transitions = append(transitions, test.next)
+				}
+			}
+		}
+	}
+	if len(rm.durations) > 0 {
+		if d, ok := durationFromEvent(val); ok {
+			for _, test := range rm.durations {
+				if test.r.contains(d) {
+					transitions = append(transitions, test.next)
+				}
+			}
+		}
+	}
+	if len(rm.phonetics) > 0 && len(val) > 2 && val[0] == '"' {
+		if code, ok := soundex(val[1 : len(val)-1]); ok {
+			for _, test := range rm.phonetics {
+				if test.code == code {
+					transitions = append(transitions, test.next)
+				}
+			}
+		}
+	}
+	if len(rm.digests) > 0 && len(val) >= 2 && val[0] == '"' {
+		if next, ok := rm.digests[sha256.Sum256(val[1:len(val)-1])]; ok {
+			transitions = append(transitions, next)
+		}
+	}
+	if len(rm.hmacs) > 0 && len(val) >= 2 && val[0] == '"' {
+		if next, ok := rm.hmacs[hmacDigest(rm.hmacKey, val[1:len(val)-1])]; ok {
+			transitions = append(transitions, next)
+		}
+	}
+	if len(rm.collated) > 0 && len(val) >= 2 && val[0] == '"' {
+		if next, ok := rm.collated[string(rm.collation(val[1:len(val)-1]))]; ok {
+			transitions = append(transitions, next)
+		}
+	}
+	if len(rm.lookups) > 0 {
+		inner := val
+		if len(val) >= 2 && val[0] == '"' {
+			inner = val[1 : len(val)-1]
+		}
+		for _, test := range rm.lookups {
+			if test.lookup.Contains(inner) {
+				transitions = append(transitions, test.next)
+			}
+		}
+	}
+	return transitions
+}
+
+// withDigest returns a copy of digests with the digest added, so that concurrent lookups are unaffected
+func withDigest(digests map[[sha256.Size]byte]*fieldMatcher, digest [sha256.Size]byte, next *fieldMatcher) map[[sha256.Size]byte]*fieldMatcher {
+	fresh := make(map[[sha256.Size]byte]*fieldMatcher, len(digests)+1)
+	for d, n := range digests {
+		fresh[d] = n
+	}
+	fresh[digest] = next
+	return fresh
+}
+
+func (rm *rangeMatcher) visit(f func(next *fieldMatcher)) {
+	for _, test := range rm.numerics {
+		f(test.next)
+	}
+	for _, test := range rm.cidrs {
+		f(test.next)
+	}
+	for _, test := range rm.timestamps {
+		f(test.next)
+	}
+	for _, test := range rm.durations {
+		f(test.next)
+	}
+	for _, test := range rm.phonetics {
+		f(test.next)
+	}
+	for _, next := range rm.digests {
+		f(next)
+	}
+	for _, next := range rm.hmacs {
+		f(next)
+	}
+	for _, next := range rm.collated {
+		f(next)
+	}
+	for _, test := range rm.lookups {
+		f(test.next)
+	}
+}
+
+// size estimates the memory consumed by the rangeMatcher, not including the fieldMatchers
+func (rm *rangeMatcher) size() int64 {
+	return mcNumericTest*int64(cap(rm.numerics)) + mcCIDRTest*int64(cap(rm.cidrs)) +
+		mcTimestampTest*int64(cap(rm.timestamps)) + mcDurationTest*int64(cap(rm.durations)) +
+		mcPhoneticTest*int64(cap(rm.phonetics)) + mcDigestEntry*int64(len(rm.digests)+len(rm.hmacs)) +
+		int64(cap(rm.hmacKey)) + mcMapEntry*int64(len(rm.collated)) + mcLookupTest*int64(cap(rm.lookups))
+}
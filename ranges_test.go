@@ -0,0 +1,44 @@
+package quamina
+
+import (
+	"math"
+	"net/netip"
+	"testing"
+)
+
+func TestNumericRangeContains(t *testing.T) {
+	r := numericRange{lo: 0, loOpen: true, hi: 5}
+	for f, want := range map[float64]bool{-1: false, 0: false, 0.001: true, 5: true, 5.001: false} {
+		if r.contains(f) != want {
+			t.Errorf("(0, 5] contains %g: %t", f, !want)
+		}
+	}
+	unbounded := numericRange{lo: math.Inf(-1), hi: 10, hiOpen: true}
+	if !unbounded.contains(-1e300) || unbounded.contains(10) {
+		t.Error("(-Inf, 10)")
+	}
+}
+
+func TestRangeMatcherWith(t *testing.T) {
+	var rm *rangeMatcher
+	r := typedVal{vType: numericType, numeric: numericRange{lo: 1, hi: 2}}
+	block := typedVal{vType: cidrType, cidr: netip.MustParsePrefix("10.0.0.0/8")}
+	rm1, next1 := rm.with(r)
+	rm2, next2 := rm1.with(block)
+	rm3, next3 := rm2.with(r)
+	if rm3 != rm2 || next3 != next1 || next1 == next2 {
+		t.Error("duplicate range wasn't shared")
+	}
+	if len(rm1.cidrs) != 0 || len(rm2.numerics) != 1 || len(rm2.cidrs) != 1 {
+		t.Error("with changed an existing rangeMatcher")
+	}
+
+	var transitions []*fieldMatcher
+	transitions = rm2.transitionOn(&Field{Val: []byte("1.5"), IsNumber: true}, transitions)
+	transitions = rm2.transitionOn(&Field{Val: []byte(`"1.5"`)}, transitions)
+	transitions = rm2.transitionOn(&Field{Val: []byte(`"10.1.2.3"`)}, transitions)
+	transitions = rm2.transitionOn(&Field{Val: []byte(`""`)}, transitions)
+	if len(transitions) != 2 || transitions[0] != next1 || transitions[1] != next2 {
+		t.Errorf("transitions %v", transitions)
+	}
+}
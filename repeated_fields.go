@@ -0,0 +1,166 @@
+package quamina
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// RepeatedFields says how a Pattern's field is matched when an Event has several fields with its path, as it
+// does when the field is in an array, or in objects in an array: {"tags": ["a", "b"]} has two fields with the
+// path "tags", and {"items": [{"kind": "x"}, {"kind": "y"}]} two with the path "items\nkind".
+type RepeatedFields int
+
+const (
+	// RepeatedAny matches a Pattern's field if any one of the Event's fields with its path has one of the
+	// Pattern's values.
+	RepeatedAny RepeatedFields = iota
+	// RepeatedAll matches a Pattern's field only if every one of the Event's fields with its path has one of
+	// the Pattern's values. As with RepeatedAny, there must be at least one.
+	RepeatedAll
+)
+
+// WithRepeatedFields sets how the fields of Patterns are matched when an Event has several fields with their
+// path; by default, RepeatedAny, any one of them matching is enough. A Pattern may choose for itself, field by
+// field, by wrapping a field's values in "any" or "all", as in {"tags": [{"all": ["public", "beta"]}]}, which
+// matches Events whose tags are all either "public" or "beta", or
+// {"latencies": [{"all": {"numeric": ["<", 500]}}]}, which matches those whose latencies are all under 500.
+// "exists" Patterns are about whether there are any such fields, so aren't affected. Each Pattern matched with
+// RepeatedAll is checked again, field by field, after it matches, so they cost more to match than others. The
+// policy applies to instances created with Copy. This option call may not be provided more than once.
+func WithRepeatedFields(policy RepeatedFields) Option {
+	return func(q *Quamina) error {
+		if q.repeatedSpecified {
+			return errors.New("repeated-field policy specified more than once")
+		}
+		if policy != RepeatedAny && policy != RepeatedAll {
+			return errors.New("unknown repeated-field policy")
+		}
+		q.repeatedSpecified = true
+		q.repeatedFields = policy
+		return nil
+	}
+}
+
+func (m *coreMatcher) setRepeatedFields(policy RepeatedFields) {
+	m.repeatedFields = policy
+}
+
+// readRepeatedSpecial reads an "any" or "all" pattern, whose value is the array of values that it applies to, or
+// for a single special pattern, just that, as in {"all": {"numeric": ["<", 500]}}
+func readRepeatedSpecial(pb *patternBuild, valsIn []typedVal, patternType string) ([]typedVal, error) {
+	if pb.repeated != "" {
+		return nil, fmt.Errorf("'%s' can't be inside '%s'", patternType, pb.repeated)
+	}
+	t, err := pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := t.(json.Delim)
+	if !ok || (delim != '[' && delim != '{') {
+		return nil, fmt.Errorf("value for '%s' must be an array or an object", patternType)
+	}
+	pb.repeated = patternType
+	var vals []typedVal
+	if delim == '[' {
+		vals, err = readPatternValues(pb)
+	} else {
+		vals, _, err = readSpecialPattern(pb, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, val := range vals {
+		if val.vType == existsTrueType || val.vType == existsFalseType {
+			return nil, fmt.Errorf("'exists' can't be inside '%s'", patternType)
+		}
+	}
+
+	// has to be } or tokenizer will throw error
+	if _, err = pb.jd.Token(); err != nil {
+		return nil, err
+	}
+	return append(valsIn, vals...), nil
+}
+
+// matchesEvery reports whether the field, in an instance whose policy is as given, is matched with RepeatedAll.
+// Fields without values, which match nothing, and "exists" fields aren't.
+func (field *patternField) matchesEvery(policy RepeatedFields) bool {
+	if len(field.vals) == 0 || field.vals[0].vType == existsTrueType || field.vals[0].vType == existsFalseType {
+		return false
+	}
+	return field.repeated == "all" || (field.repeated == "" && policy == RepeatedAll)
+}
+
+// repeatedPattern is what a Pattern which has fields matched with RepeatedAll is added to the automaton with, in
+// place of its X. The automaton matches it as though its fields were matched with RepeatedAny, and each match is
+// then checked by checkRepeated.
+type repeatedPattern struct {
+	x      X
+	checks []repeatedCheck
+}
+
+// repeatedCheck holds one of the fields of a repeatedPattern that are matched with RepeatedAll, as a Pattern in
+// its own matcher, with which each of an Event's fields with its path is matched
+type repeatedCheck struct {
+	path    []byte
+	matcher *coreMatcher
+}
+
+// repeatedPatternFor returns the repeatedPattern to add the Pattern with, if any of its fields are matched with
+// RepeatedAll
+func (m *coreMatcher) repeatedPatternFor(x X, fields []*patternField, printer printer) (*repeatedPattern, error) {
+	var rp *repeatedPattern
+	for _, field := range fields {
+		if !field.matchesEvery(m.repeatedFields) {
+			continue
+		}
+		check := repeatedCheck{path: []byte(field.path), matcher: newCoreMatcher()}
+		only := &patternField{path: field.path, vals: field.vals}
+		if err := check.matcher.addPatternFields(true, []*patternField{only}, printer, BuiltForComfort); err != nil {
+			return nil, err
+		}
+		if rp == nil {
+			rp = &repeatedPattern{x: x}
+		}
+		rp.checks = append(rp.checks, check)
+	}
+	if rp != nil {
+		m.hasRepeated.Store(true)
+	}
+	return rp, nil
+}
+
+// checkRepeated replaces the repeatedPatterns among the matches with their Xs, if every one of the fields, which
+// are sorted by path, that each of their checks applies to matches
+func checkRepeated(matches *matchSet, fields []Field) {
+	var passed []X
+	for x := range matches.set {
+		rp, ok := x.(*repeatedPattern)
+		if !ok {
+			continue
+		}
+		delete(matches.set, x)
+		if rp.matchesAll(fields) {
+			passed = append(passed, rp.x)
+		}
+	}
+	matches.addXSingleThreaded(passed...)
+}
+
+func (rp *repeatedPattern) matchesAll(fields []Field) bool {
+	// the matches being checked are in the caller's buffers, so the checks need their own
+	bufs := nfaBuffersPool.Get().(*nfaBuffers)
+	defer nfaBuffersPool.Put(bufs)
+	for _, check := range rp.checks {
+		i, _ := slices.BinarySearchFunc(fields, check.path, func(f Field, path []byte) int { return bytes.Compare(f.Path, path) })
+		for ; i < len(fields) && bytes.Equal(fields[i].Path, check.path); i++ {
+			if len(check.matcher.matchSetForFields(fields[i:i+1], bufs).set) == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestRepeatedFields(t *testing.T) {
+	events := []string{
+		`{"tags": ["public", "beta"]}`,
+		`{"tags": ["public", "internal"]}`,
+		`{"tags": "public"}`,
+		`{"items": [{"kind": "x", "n": 1}, {"kind": "x", "n": 7}]}`,
+		`{"items": [{"kind": "x", "n": 1}, {"kind": "y", "n": 2}]}`,
+	}
+	patterns := map[X]string{
+		"public":       `{"tags": ["public"]}`,
+		"all-known":    `{"tags": [{"all": ["public", "beta"]}]}`,
+		"any-beta":     `{"tags": [{"any": ["beta"]}]}`,
+		"none-inside":  `{"tags": [{"all": [{"anything-but": ["internal"]}]}]}`,
+		"all-x":        `{"items": {"kind": [{"all": ["x"]}]}}`,
+		"small-x":      `{"items": {"kind": ["x"], "n": [{"all": [1, 7]}]}}`,
+		"has-tags":     `{"tags": [{"exists": true}]}`,
+		"default-kind": `{"items": {"kind": ["x"]}}`,
+	}
+	tests := []struct {
+		policy RepeatedFields
+		want   [][]X
+	}{
+		{RepeatedAny, [][]X{
+			{"public", "all-known", "any-beta", "none-inside", "has-tags"},
+			{"public", "has-tags"},
+			{"public", "all-known", "none-inside", "has-tags"},
+			{"all-x", "small-x", "default-kind"},
+			{"default-kind"},
+		}},
+		{RepeatedAll, [][]X{
+			{"all-known", "any-beta", "none-inside", "has-tags"},
+			{"has-tags"},
+			{"public", "all-known", "none-inside", "has-tags"},
+			{"all-x", "small-x", "default-kind"},
+			{},
+		}},
+	}
+	for _, test := range tests {
+		for _, deletion := range []bool{false, true} {
+			q, err := New(WithRepeatedFields(test.policy), WithPatternDeletion(deletion))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for x, p := range patterns {
+				if err := q.AddPattern(x, p); err != nil {
+					t.Fatalf("%s: %v", p, err)
+				}
+			}
+			for i, event := range events {
+				for _, instance := range []*Quamina{q, q.Copy()} {
+					matches, err := instance.MatchesForEvent([]byte(event))
+					if err != nil || !containsExactly(matches, test.want[i]) {
+						t.Errorf("policy %d, deletion=%v, %s: %v, %v", test.policy, deletion, event, matches, err)
+					}
+				}
+			}
+			if deletion {
+				// a rebuild keeps the policy
+				_ = q.DeletePatterns("has-tags")
+				if err := q.matcher.(*prunerMatcher).rebuild(false); err != nil {
+					t.Fatal(err)
+				}
+				matches, _ := q.MatchesForEvent([]byte(events[1]))
+				want := []X{"public"}
+				if test.policy == RepeatedAll {
+					want = []X{}
+				}
+				if !containsExactly(matches, want) {
+					t.Errorf("policy %d after rebuild: %v", test.policy, matches)
+				}
+			}
+		}
+	}
+}
+
+func TestRepeatedFieldsSpecial(t *testing.T) {
+	q, err := New(WithRepeatedFields(RepeatedAny))
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := map[X]string{
+		"all-fast":  `{"latencies": [{"all": {"numeric": ["<", 500]}}]}`,
+		"any-slow":  `{"latencies": [{"any": {"numeric": [">=", 1000]}}]}`,
+		"all-hosts": `{"hosts": [{"all": {"prefix": "web-"}}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	tests := map[string][]X{
+		`{"latencies": [120, 80, 499.5]}`:                     {"all-fast"},
+		`{"latencies": [120, 800]}`:                           {},
+		`{"latencies": [120, 1500]}`:                          {"any-slow"},
+		`{"latencies": 1000}`:                                 {"any-slow"},
+		`{"latencies": []}`:                                   {},
+		`{"hosts": ["web-1", "web-2"]}`:                       {"all-hosts"},
+		`{"hosts": ["web-1", "db-1"], "latencies": [1, 2e3]}`: {"any-slow"},
+	}
+	for event, want := range tests {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+}
+
+func TestRepeatedFieldsWithOtherOptions(t *testing.T) {
+	q, err := New(WithRepeatedFields(RepeatedAll), WithMatchMultiplicity(true), WithCaseInsensitiveFieldNames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = q.AddPattern("p", `{"Tags": ["a", "b"]}`)
+	_ = q.AddPattern("p", `{"Tags": ["a", "c"]}`)
+	counts, err := q.MatchCountsForEvent([]byte(`{"tags": ["a", "b", "a"]}`))
+	if err != nil || len(counts) != 1 || counts["p"] != 1 {
+		t.Errorf("counts %v, %v", counts, err)
+	}
+	n, err := q.CountMatchesForEvent([]byte(`{"TAGS": ["a", "d"]}`))
+	if err != nil || n != 0 {
+		t.Errorf("count %d, %v", n, err)
+	}
+}
+
+func TestRepeatedFieldsShadowing(t *testing.T) {
+	q, _ := New(WithPatternText(true))
+	_ = q.AddPattern("x", `{"tags": [{"all": ["a"]}]}`)
+	_ = q.AddPattern("x", `{"tags": ["a", "b"]}`)
+	shadowed, err := q.ShadowedPatterns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "all" matches only Events that the other Pattern does, but not the other way round
+	if len(shadowed) != 1 || shadowed[0].Pattern != `{"tags": [{"all": ["a"]}]}` {
+		t.Errorf("shadowed: %v", shadowed)
+	}
+}
+
+func TestRepeatedFieldsErrors(t *testing.T) {
+	for _, bad := range []string{
+		`{"x": [{"all": "a"}]}`,
+		`{"x": [{"all": ["a", {"any": ["b"]}]}]}`,
+		`{"x": [{"all": {"any": ["b"]}}]}`,
+		`{"x": [{"all": {"exists": true}}]}`,
+		`{"x": [{"all": {"prefix": "a", "suffix": "b"}}]}`,
+		`{"x": [{"all": {}}]}`,
+		`{"x": [{"any": [{"exists": true}]}]}`,
+		`{"x": [{"all": ["a"]}, "b"]}`,
+		`{"x": [{"all": [{"anything-but": ["a"]}, "b"]}]}`,
+	} {
+		q, _ := New()
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+	if _, err := New(WithRepeatedFields(RepeatedAll), WithRepeatedFields(RepeatedAny)); err == nil {
+		t.Error("accepted option twice")
+	}
+	if _, err := New(WithRepeatedFields(RepeatedFields(5))); err == nil {
+		t.Error("accepted unknown policy")
+	}
+}
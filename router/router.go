@@ -0,0 +1,198 @@
+// Package router resolves Events to the destinations, such as queues or topics, that they should be sent to,
+// so that the common case of routing by Pattern needs no code beyond the routing table:
+//
+//	r, _ := router.New(q)
+//	_ = r.AddRoute("orders", router.Route{
+//		Pattern:      `{"type": ["order"]}`,
+//		Destinations: []router.Destination{{Name: "orders-a", Weight: 3}, {Name: "orders-b", Weight: 1}},
+//		Fallbacks:    []string{"orders-overflow"},
+//	})
+//	r.SetDefault("unrouted")
+//	...
+//	destinations, err := r.Route(event)
+//
+// The Routes' Patterns are added to the Quamina instance the Router was made with, with the Routes' names as
+// their X values.
+package router
+
+import (
+	"errors"
+	"hash/fnv"
+	"slices"
+	"sync"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Destination is somewhere a Route sends Events, with its share of them.
+type Destination struct {
+	Name string
+	// Weight is the Destination's share of the Route's Events, relative to the other Destinations' Weights;
+	// zero counts as 1.
+	Weight int
+}
+
+// Route says where the Events matching a Pattern go.
+type Route struct {
+	Pattern string
+	// Destinations are chosen among by weight, each Event going to one of those that are available. The
+	// choice depends only on the Event, so the same Event always goes to the same Destination.
+	Destinations []Destination
+	// Fallbacks are the names of the destinations, in order of preference, that the Events go to when none of
+	// the Destinations is available; only the first available one is used.
+	Fallbacks []string
+}
+
+// Router resolves Events to destinations with a routing table. Like a Quamina instance, a Router mustn't be
+// used by more than one goroutine at once; Copy makes one for another goroutine, which shares its table.
+type Router struct {
+	q     *quamina.Quamina
+	table *table
+}
+
+// table is the routing table shared by a Router and its copies
+type table struct {
+	lock     sync.RWMutex
+	routes   map[string]*Route
+	down     map[string]bool
+	defaults []string
+}
+
+// New returns a Router whose Routes are matched with q.
+func New(q *quamina.Quamina) (*Router, error) {
+	if q == nil {
+		return nil, errors.New("nil Quamina instance")
+	}
+	return &Router{q: q, table: &table{routes: make(map[string]*Route), down: make(map[string]bool)}}, nil
+}
+
+// Copy returns a Router for use on another goroutine, which matches Events with a copy of r's Quamina
+// instance, and shares r's routing table.
+func (r *Router) Copy() *Router {
+	return &Router{q: r.q.Copy(), table: r.table}
+}
+
+// AddRoute adds a Route with the name. Adding a name again adds the Pattern as an alternative to those already
+// added with it, and replaces its Destinations and Fallbacks.
+func (r *Router) AddRoute(name string, route Route) error {
+	if name == "" {
+		return errors.New("empty route name")
+	}
+	if len(route.Destinations) == 0 {
+		return errors.New("route has no destinations")
+	}
+	for _, destination := range route.Destinations {
+		if destination.Name == "" {
+			return errors.New("empty destination name")
+		}
+		if destination.Weight < 0 {
+			return errors.New("destination weight must not be negative")
+		}
+	}
+	if slices.Contains(route.Fallbacks, "") {
+		return errors.New("empty fallback name")
+	}
+	if err := r.q.AddPattern(name, route.Pattern); err != nil {
+		return err
+	}
+	route.Destinations = slices.Clone(route.Destinations)
+	route.Fallbacks = slices.Clone(route.Fallbacks)
+	r.table.lock.Lock()
+	defer r.table.lock.Unlock()
+	r.table.routes[name] = &route
+	return nil
+}
+
+// DeleteRoute deletes the Route with the name, and its Patterns from the Quamina instance, which must support
+// that.
+func (r *Router) DeleteRoute(name string) error {
+	if err := r.q.DeletePatterns(name); err != nil {
+		return err
+	}
+	r.table.lock.Lock()
+	defer r.table.lock.Unlock()
+	delete(r.table.routes, name)
+	return nil
+}
+
+// SetDefault sets the destinations that Events go to when they resolve to no other, because they match no
+// Route or the Routes they match have no available destination; all those available are used.
+func (r *Router) SetDefault(destinations ...string) {
+	r.table.lock.Lock()
+	defer r.table.lock.Unlock()
+	r.table.defaults = slices.Clone(destinations)
+}
+
+// SetAvailable marks a destination as available, which all are to begin with, or not, for example when a
+// health check finds that its queue is down. Events aren't sent to destinations that aren't available.
+func (r *Router) SetAvailable(destination string, available bool) {
+	r.table.lock.Lock()
+	defer r.table.lock.Unlock()
+	if available {
+		delete(r.table.down, destination)
+	} else {
+		r.table.down[destination] = true
+	}
+}
+
+// Route returns the names of the destinations the Event should be sent to, in no particular order and without
+// repeats: one for each Route it matches, if the Route has an available destination, or else the defaults.
+func (r *Router) Route(event []byte) ([]string, error) {
+	names, err := r.q.MatchesForEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	t := r.table
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	var destinations []string
+	h := fnv.New64a()
+	_, _ = h.Write(event)
+	hash := h.Sum64()
+	for _, x := range names {
+		// the instance may have Patterns of its own, whose X values needn't be strings
+		name, _ := x.(string)
+		route, ok := t.routes[name]
+		if !ok {
+			continue
+		}
+		if destination, ok := t.resolve(route, hash); ok && !slices.Contains(destinations, destination) {
+			destinations = append(destinations, destination)
+		}
+	}
+	if destinations == nil {
+		for _, destination := range t.defaults {
+			if !t.down[destination] && !slices.Contains(destinations, destination) {
+				destinations = append(destinations, destination)
+			}
+		}
+	}
+	return destinations, nil
+}
+
+// resolve chooses the destination for an Event with the hash, if the Route has one that's available
+func (t *table) resolve(route *Route, hash uint64) (string, bool) {
+	total := 0
+	for _, destination := range route.Destinations {
+		if !t.down[destination.Name] {
+			total += max(destination.Weight, 1)
+		}
+	}
+	if total > 0 {
+		n := int(hash % uint64(total))
+		for _, destination := range route.Destinations {
+			if t.down[destination.Name] {
+				continue
+			}
+			if n -= max(destination.Weight, 1); n < 0 {
+				return destination.Name, true
+			}
+		}
+	}
+	for _, fallback := range route.Fallbacks {
+		if !t.down[fallback] {
+			return fallback, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,175 @@
+package router
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func newTestRouter(t *testing.T, opts ...quamina.Option) *Router {
+	t.Helper()
+	q, err := quamina.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRoute(t *testing.T) {
+	r := newTestRouter(t)
+	routes := map[string]Route{
+		"orders": {
+			Pattern:      `{"type": ["order"]}`,
+			Destinations: []Destination{{Name: "orders-a"}, {Name: "orders-b"}},
+			Fallbacks:    []string{"overflow", "dead-letter"},
+		},
+		"big": {
+			Pattern:      `{"amount": [5000]}`,
+			Destinations: []Destination{{Name: "review"}},
+		},
+		"audit": {
+			Pattern:      `{"type": ["order", "refund"]}`,
+			Destinations: []Destination{{Name: "review"}},
+		},
+	}
+	for name, route := range routes {
+		if err := r.AddRoute(name, route); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	r.SetDefault("unrouted", "archive", "unrouted")
+
+	route := func(event string) []string {
+		t.Helper()
+		destinations, err := r.Route([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(destinations)
+		return destinations
+	}
+	order := `{"type": "order", "amount": 5}`
+	first := route(order)
+	if len(first) != 2 || first[1] != "review" || (first[0] != "orders-a" && first[0] != "orders-b") {
+		t.Errorf("order went to %v", first)
+	}
+	if again := route(order); !slices.Equal(again, first) {
+		t.Errorf("order went to %v, then %v", first, again)
+	}
+	// the Routes resolve to the same destination, which is only reported once
+	if got := route(`{"type": "refund", "amount": 5000}`); !slices.Equal(got, []string{"review"}) {
+		t.Errorf("refund went to %v", got)
+	}
+	if got := route(`{"type": "other"}`); !slices.Equal(got, []string{"archive", "unrouted"}) {
+		t.Errorf("other went to %v", got)
+	}
+
+	steps := []struct {
+		destination string
+		available   bool
+		want        []string
+	}{
+		{"orders-a", false, []string{"orders-b", "review"}},
+		{"orders-b", false, []string{"overflow", "review"}},
+		{"overflow", false, []string{"dead-letter", "review"}},
+		{"dead-letter", false, []string{"review"}},
+		// with no destination, the defaults
+		{"review", false, []string{"archive", "unrouted"}},
+		{"unrouted", false, []string{"archive"}},
+		{"archive", false, nil},
+		{"orders-b", true, []string{"orders-b"}},
+	}
+	for _, step := range steps {
+		r.SetAvailable(step.destination, step.available)
+		if got := route(order); !slices.Equal(got, step.want) {
+			t.Errorf("%s available %t: order went to %v", step.destination, step.available, got)
+		}
+	}
+
+	if _, err := r.Route([]byte(`{`)); err == nil {
+		t.Error("accepted bad JSON")
+	}
+}
+
+func TestRouteWeights(t *testing.T) {
+	r := newTestRouter(t)
+	err := r.AddRoute("x", Route{
+		Pattern:      `{"id": [{"exists": true}]}`,
+		Destinations: []Destination{{Name: "a", Weight: 3}, {Name: "b", Weight: 1}, {Name: "c", Weight: 0}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 5000; i++ {
+		destinations, err := r.Route([]byte(`{"id": ` + strconv.Itoa(i) + `}`))
+		if err != nil || len(destinations) != 1 {
+			t.Fatalf("%d: %v, %v", i, destinations, err)
+		}
+		counts[destinations[0]]++
+	}
+	// shares of 3/5, 1/5, and 1/5
+	for name, want := range map[string]int{"a": 3000, "b": 1000, "c": 1000} {
+		if counts[name] < want*9/10 || counts[name] > want*11/10 {
+			t.Errorf("counts %v", counts)
+		}
+	}
+}
+
+func TestRouteCopyAndDelete(t *testing.T) {
+	r := newTestRouter(t, quamina.WithPatternDeletion(true))
+	_ = r.AddRoute("x", Route{Pattern: `{"a": [1]}`, Destinations: []Destination{{Name: "q1"}}})
+	// the instance's own Patterns don't route
+	if err := r.q.AddPattern(17, `{"a": [1]}`); err != nil {
+		t.Fatal(err)
+	}
+	copied := r.Copy()
+	r.SetAvailable("q1", false)
+	_ = r.AddRoute("x", Route{Pattern: `{"a": [2]}`, Destinations: []Destination{{Name: "q1"}}, Fallbacks: []string{"q2"}})
+	for _, event := range []string{`{"a": 1}`, `{"a": 2}`} {
+		if got, err := copied.Route([]byte(event)); err != nil || !slices.Equal(got, []string{"q2"}) {
+			t.Errorf("%s went to %v, %v", event, got, err)
+		}
+	}
+	if err := copied.DeleteRoute("x"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Route([]byte(`{"a": 1}`)); err != nil || got != nil {
+		t.Errorf("deleted, went to %v, %v", got, err)
+	}
+}
+
+func TestRouteErrors(t *testing.T) {
+	r := newTestRouter(t)
+	bads := []struct {
+		name  string
+		route Route
+	}{
+		{"", Route{Pattern: `{"a": [1]}`, Destinations: []Destination{{Name: "q"}}}},
+		{"x", Route{Pattern: `{"a": [1]}`}},
+		{"x", Route{Pattern: `{"a": [1]}`, Destinations: []Destination{{Name: ""}}}},
+		{"x", Route{Pattern: `{"a": [1]}`, Destinations: []Destination{{Name: "q", Weight: -1}}}},
+		{"x", Route{Pattern: `{"a": [1]}`, Destinations: []Destination{{Name: "q"}}, Fallbacks: []string{""}}},
+		{"x", Route{Pattern: `{"a": 1}`, Destinations: []Destination{{Name: "q"}}}},
+	}
+	for _, bad := range bads {
+		if err := r.AddRoute(bad.name, bad.route); err == nil {
+			t.Errorf("accepted %q %v", bad.name, bad.route)
+		}
+	}
+	if got, err := r.Route([]byte(`{"a": 1}`)); err != nil || got != nil {
+		t.Errorf("went to %v, %v", got, err)
+	}
+	if err := r.DeleteRoute("x"); err == nil {
+		t.Error("deleted without pattern deletion")
+	}
+	if _, err := New(nil); err == nil {
+		t.Error("accepted nil instance")
+	}
+}
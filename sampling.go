@@ -0,0 +1,108 @@
+package quamina
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// AddSampledPattern adds a Pattern, as AddPattern does, but one that is reported as matching only a sample of
+// the Events it matches, in proportion to rate, which must be more than 0 and no more than 1. New rules can
+// then be tried out on, say, 1% of the traffic of a production router, without any logic outside Quamina.
+//
+// Which Events are in the sample depends only on a hash of the Event, so an Event is always in it or always
+// not, whichever instance matches it, and the sample for a rate includes the samples for all lower rates. The
+// hash is of the Event's bytes, or, for MatchesForStruct, MatchesForTypedFields, and MatchesForColumns, of the
+// fields that Patterns use. The rate belongs to x, so it applies to all the Patterns added with x, until
+// they're deleted; adding x again with a different rate replaces it, and adding it with AddPattern removes it.
+func (q *Quamina) AddSampledPattern(x X, patternJSON string, rate float64) error {
+	if !(rate > 0 && rate <= 1) {
+		return errors.New("sampling rate must be more than 0 and no more than 1")
+	}
+	if err := q.addPattern(x, patternJSON); err != nil {
+		return err
+	}
+	q.samples.set(x, rate)
+	return nil
+}
+
+// sampleRates are the rates of the sampled Patterns of an instance and its copies, by X
+type sampleRates struct {
+	count atomic.Int64 // how many rates there are, so that instances without any needn't lock
+	lock  sync.RWMutex
+	rates map[X]float64
+}
+
+func newSampleRates() *sampleRates {
+	return &sampleRates{rates: make(map[X]float64)}
+}
+
+// set gives x the rate, or, if it's 1, stops sampling it
+func (s *sampleRates) set(x X, rate float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if rate == 1 {
+		delete(s.rates, x)
+	} else {
+		s.rates[x] = rate
+	}
+	s.count.Store(int64(len(s.rates)))
+}
+
+func (s *sampleRates) active() bool {
+	return s.count.Load() > 0
+}
+
+// filter removes from the matches those which aren't sampled for the Event with the hash; it's only worth
+// computing the hash if the rates are active
+func (s *sampleRates) filter(matches []X, hash uint64) []X {
+	// the top 53 bits of the hash are a fraction, which is in the sample for rates above it
+	fraction := float64(hash>>11) / (1 << 53)
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	kept := matches[:0]
+	for _, x := range matches {
+		if rate, ok := s.rates[x]; ok && fraction >= rate {
+			continue
+		}
+		kept = append(kept, x)
+	}
+	return kept
+}
+
+// eventHash is the hash by which an Event's bytes are sampled
+func eventHash(event []byte) uint64 {
+	h := fnvOffset64
+	for _, b := range event {
+		h ^= uint64(b)
+		h *= fnvPrime64
+	}
+	return mix64(h)
+}
+
+// fieldsHash is the hash by which an Event that has been flattened already is sampled. The fields have been
+// sorted by matching, so their order doesn't depend on the Event's.
+func fieldsHash(fields []Field) uint64 {
+	h := fnvOffset64
+	for i := range fields {
+		for _, part := range [2][]byte{fields[i].Path, fields[i].Val} {
+			for _, b := range part {
+				h ^= uint64(b)
+				h *= fnvPrime64
+			}
+			h ^= 0xff // a byte that can't be in UTF-8, between the parts
+			h *= fnvPrime64
+		}
+	}
+	return mix64(h)
+}
+
+// mix64 spreads the bits of an FNV hash, whose high bits change little for Events that differ only at the end;
+// it's the finalizer of SplitMix64
+func mix64(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	return h ^ h>>31
+}
@@ -0,0 +1,106 @@
+package quamina
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestAddSampledPattern(t *testing.T) {
+	q, _ := New(WithPatternDeletion(true))
+	pattern := `{"kind": ["order"]}`
+	if err := q.AddPattern("all", pattern); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddSampledPattern("tenth", pattern, 0.1); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddSampledPattern("half", pattern, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	copied := q.Copy()
+
+	const events = 10000
+	counts := map[X]int{}
+	for i := 0; i < events; i++ {
+		event := []byte(fmt.Sprintf(`{"kind": "order", "id": %d}`, i))
+		matches, err := q.MatchesForEvent(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, x := range matches {
+			counts[x]++
+		}
+		// the tenth sampled are among the half sampled
+		if slices.Contains(matches, "tenth") && !slices.Contains(matches, "half") {
+			t.Errorf("event %d is sampled at 0.1 but not 0.5", i)
+		}
+		// every way of matching makes the same decision, in every copy
+		copiedMatches, _ := copied.MatchesForEventInto(event, []X{"dst"})
+		count, _ := copied.CountMatchesForEvent(event)
+		if !containsExactly(copiedMatches[1:], matches) || count != len(matches) {
+			t.Errorf("event %d: %v, copy %v, count %d", i, matches, copiedMatches, count)
+		}
+	}
+	if counts["all"] != events {
+		t.Errorf("all matched %d", counts["all"])
+	}
+	if counts["tenth"] < 850 || counts["tenth"] > 1150 {
+		t.Errorf("tenth matched %d", counts["tenth"])
+	}
+	if counts["half"] < 4700 || counts["half"] > 5300 {
+		t.Errorf("half matched %d", counts["half"])
+	}
+
+	// adding the X with AddPattern, or deleting it, stops its sampling
+	_ = q.AddPattern("tenth", `{"kind": ["refund"]}`)
+	_ = q.DeletePatterns("half")
+	_ = q.AddPattern("half", pattern)
+	sampled := 0
+	for i := 0; i < 100; i++ {
+		matches, _ := q.MatchesForEvent([]byte(fmt.Sprintf(`{"kind": "order", "id": %d}`, i)))
+		if len(matches) != 3 {
+			sampled++
+		}
+	}
+	if sampled != 0 || q.samples.active() {
+		t.Errorf("%d events sampled", sampled)
+	}
+
+	for _, rate := range []float64{0, -0.5, 1.5} {
+		if err := q.AddSampledPattern("bad", pattern, rate); err == nil {
+			t.Errorf("rate %v accepted", rate)
+		}
+	}
+	if err := q.AddSampledPattern("bad", `{"kind": "order"}`, 0.5); err == nil {
+		t.Error("bad Pattern accepted")
+	}
+	if q.samples.active() {
+		t.Error("bad Pattern was sampled")
+	}
+}
+
+func TestSampledWithoutBytes(t *testing.T) {
+	q, _ := New()
+	_ = q.AddSampledPattern("half", `{"id": [{"exists": true}]}`, 0.5)
+	matched := 0
+	for i := 0; i < 1000; i++ {
+		fields := []TypedField{{Path: []byte("id"), Value: i}}
+		typed, err := q.MatchesForTypedFields(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the fields of a value are hashed, so the same value is sampled the same way however it's given
+		structMatches, err := q.MatchesForStruct(map[string]int{"id": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(typed) != len(structMatches) {
+			t.Errorf("%d: typed %v, struct %v", i, typed, structMatches)
+		}
+		matched += len(typed)
+	}
+	if matched < 420 || matched > 580 {
+		t.Errorf("%d of 1000 matched", matched)
+	}
+}
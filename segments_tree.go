@@ -2,11 +2,65 @@ package quamina
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 )
 
 const SegmentSeparator = "\n"
 
+// segmentEscape is written before a SegmentSeparator, or a segmentEscape, which is part of a member name
+// rather than separating two of them, so that every path names only one field.
+const segmentEscape = '\\'
+
+// JoinPath makes a path, such as the Path of a Field or the paths given to AddFieldPaths, from the member names
+// leading to a field. Usually it's the same as joining them with SegmentSeparator, but a member name which
+// itself contains SegmentSeparator or a backslash has a backslash written before each of them, so that
+// {"a\nb": 1} and {"a": {"b": 1}} have different paths.
+func JoinPath(segments ...string) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		if i > 0 {
+			b.WriteString(SegmentSeparator)
+		}
+		if !strings.ContainsAny(segment, SegmentSeparator+string(segmentEscape)) {
+			b.WriteString(segment)
+			continue
+		}
+		for j := 0; j < len(segment); j++ {
+			if segment[j] == SegmentSeparator[0] || segment[j] == segmentEscape {
+				b.WriteByte(segmentEscape)
+			}
+			b.WriteByte(segment[j])
+		}
+	}
+	return b.String()
+}
+
+// splitPath is the inverse of JoinPath
+func splitPath(path string) []string {
+	if strings.IndexByte(path, segmentEscape) < 0 {
+		return strings.Split(path, SegmentSeparator)
+	}
+	var segments []string
+	var segment []byte
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case segmentEscape:
+			// an escape at the end of a path, which JoinPath doesn't make, stands for itself
+			if i+1 < len(path) {
+				i++
+			}
+			segment = append(segment, path[i])
+		case SegmentSeparator[0]:
+			segments = append(segments, string(segment))
+			segment = segment[:0]
+		default:
+			segment = append(segment, path[i])
+		}
+	}
+	return append(segments, string(segment))
+}
+
 // segmentsTree implements the SegmentsTreeTracker interface, and includes other calls used by
 // the AddPattern() code to load up the tree tracker.
 type segmentsTree struct {
@@ -23,6 +77,10 @@ type segmentsTree struct {
 	//  leaf "id" will be mapped to []byte("context\nuser\nid")
 	//  leaf "user", if it has non-node values, will be mapped to []byte("context\nuser")
 	fields map[string][]byte
+
+	// foldCase means the member names Flatteners look up are folded to lower case, as the paths added to
+	// the tree are; see WithCaseInsensitiveFieldNames.
+	foldCase bool
 }
 
 // newSegmentsIndex creates a segmentsTree node which is the root.
@@ -45,12 +103,12 @@ func newSegmentsIndexNode(root bool) *segmentsTree {
 }
 
 func (p *segmentsTree) add(path string) {
-	segments := strings.Split(path, SegmentSeparator)
+	segments := splitPath(path)
 
 	// If we have only one segment, it's a field on the root.
 	if len(segments) == 1 {
 		// It's a direct field.
-		p.fields[path] = []byte(path)
+		p.fields[segments[0]] = []byte(path)
 		return
 	}
 
@@ -71,7 +129,9 @@ func (p *segmentsTree) add(path string) {
 func (p *segmentsTree) getOrCreate(name string) *segmentsTree {
 	_, ok := p.nodes[name]
 	if !ok {
-		p.nodes[name] = newSegmentsIndexNode(false)
+		node := newSegmentsIndexNode(false)
+		node.foldCase = p.foldCase
+		p.nodes[name] = node
 	}
 	return p.nodes[name]
 }
@@ -85,6 +145,9 @@ func (p *segmentsTree) addSegment(segment string, path []byte) {
 
 // Get implements SegmentsTreeTracker
 func (p *segmentsTree) Get(name []byte) (SegmentsTreeTracker, bool) {
+	if p.foldCase {
+		name = foldName(name)
+	}
 	n, ok := p.nodes[string(name)]
 	return n, ok
 }
@@ -100,6 +163,9 @@ func (p *segmentsTree) IsSegmentUsed(segment []byte) bool {
 	// "context" / "user" are nodes, while "id" is a field
 	// As a result a segment can be both node and field, we need to check
 	// in both maps.
+	if p.foldCase {
+		segment = foldName(segment)
+	}
 	_, isField := p.fields[string(segment)]
 	if isField {
 		return true
@@ -110,6 +176,9 @@ func (p *segmentsTree) IsSegmentUsed(segment []byte) bool {
 
 // PathForSegment implements SegmentsTreeTracker
 func (p *segmentsTree) PathForSegment(segment []byte) []byte {
+	if p.foldCase {
+		segment = foldName(segment)
+	}
 	return p.fields[string(segment)]
 }
 
@@ -123,6 +192,23 @@ func (p *segmentsTree) FieldsCount() int {
 	return len(p.fields)
 }
 
+// paths returns the full paths of all the fields in the tree, sorted
+func (p *segmentsTree) paths() []string {
+	var paths []string
+	var walk func(node *segmentsTree)
+	walk = func(node *segmentsTree) {
+		for _, path := range node.fields {
+			paths = append(paths, string(path))
+		}
+		for _, child := range node.nodes {
+			walk(child)
+		}
+	}
+	walk(p)
+	slices.Sort(paths)
+	return paths
+}
+
 // String used for debugging purposes
 func (p *segmentsTree) String() string {
 	nodeNames := make([]string, 0)
@@ -142,6 +228,7 @@ func (p *segmentsTree) String() string {
 // the Quamina automaton.
 func (p *segmentsTree) copy() *segmentsTree {
 	np := newSegmentsIndexNode(p.root)
+	np.foldCase = p.foldCase
 
 	// copy fields
 	for name, path := range p.fields {
@@ -1,6 +1,7 @@
 package quamina
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -77,3 +78,71 @@ func expectCounts(t *testing.T, tree SegmentsTreeTracker, fieldsCount, nodesCoun
 		t.Fatalf("Expected to have %v fields & %v nodes: %s", fieldsCount, nodesCount, tree.String())
 	}
 }
+
+func TestSegmentsTreePaths(t *testing.T) {
+	tree := newSegmentsIndex("node\nsub_node\nfield", "root_field", "node\nfield", "node")
+	wanted := []string{"node", "node\nfield", "node\nsub_node\nfield", "root_field"}
+	if got := tree.paths(); !slices.Equal(got, wanted) {
+		t.Errorf("got %q wanted %q", got, wanted)
+	}
+	if got := newSegmentsIndex().paths(); len(got) != 0 {
+		t.Errorf("empty tree has paths %q", got)
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	for _, segments := range [][]string{
+		{"a"},
+		{"a", "b", "c"},
+		{"a\nb"},
+		{"a\nb", "c"},
+		{`a\b`, `\`, "\n", ""},
+		{`a\nb`},
+		{"a.b"},
+	} {
+		path := JoinPath(segments...)
+		if got := splitPath(path); !slices.Equal(got, segments) {
+			t.Errorf("%q: joined to %q, split to %q", segments, path, got)
+		}
+	}
+	if JoinPath("a", "b") != "a\nb" || JoinPath("a\nb") == "a\nb" {
+		t.Error("JoinPath mixed up member names containing the separator with nested ones")
+	}
+}
+
+func TestSeparatorInMemberNames(t *testing.T) {
+	q, _ := New()
+	patterns := map[X]string{
+		"newline":   `{"a\nb": [1]}`,
+		"nested":    `{"a": {"b": [1]}}`,
+		"backslash": `{"c\\nd": [2]}`,
+		"deep":      `{"e": {"f\ng": {"h": [3]}}}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for event, want := range map[string][]X{
+		`{"a\nb": 1}`:                   {"newline"},
+		`{"a": {"b": 1}}`:               {"nested"},
+		`{"c\\nd": 2}`:                  {"backslash"},
+		`{"c\nd": 2}`:                   {},
+		`{"c": {"d": 2}}`:               {},
+		`{"e": {"f\ng": {"h": 3}}}`:     {"deep"},
+		`{"e": {"f": {"g": {"h": 3}}}}`: {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+
+	typed, err := q.MatchesForTypedFields([]TypedField{{Path: []byte(JoinPath("a\nb")), Value: 1}})
+	if err != nil || !containsExactly(typed, []X{"newline"}) {
+		t.Errorf("typed fields: %v, %v", typed, err)
+	}
+	if !slices.Contains(q.FieldPaths(), JoinPath("e", "f\ng", "h")) {
+		t.Errorf("paths %q", q.FieldPaths())
+	}
+}
@@ -0,0 +1,186 @@
+package quamina
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// ShadowedPattern is a Pattern that ShadowedPatterns found to be covered by another, ShadowedBy, which was
+// added with the same X and matches every Event that it does.
+type ShadowedPattern struct {
+	X          X
+	Pattern    string
+	ShadowedBy string
+}
+
+// ShadowedPatterns finds the Patterns which can never add a match, because another Pattern with the same X
+// matches every Event they match, and so can be deleted without changing the results of matching. Huge sets of
+// rules, maintained over years, accumulate these. Of two Patterns which match exactly the same Events, only
+// the one added later is reported, or, by instances which don't know the order, the one that sorts later.
+// The analysis is conservative: it compares the Patterns' values exactly, numbers and numeric ranges, and
+// prefixes, while other types of value such as wildcards are only taken to be covered by "exists": true. So
+// every Pattern reported is shadowed, but some that are may not be reported.
+//
+// It needs the text of the Patterns, which is kept by instances created WithPatternText(true) or
+// WithPatternDeletion(true). The results are in no particular order.
+func (q *Quamina) ShadowedPatterns() ([]ShadowedPattern, error) {
+	byX, err := q.patternsByX()
+	if err != nil {
+		return nil, err
+	}
+	var shadowed []ShadowedPattern
+	for x, patterns := range byX {
+		alternatives := make([][]Constraints, len(patterns))
+		for i, pattern := range patterns {
+			if alternatives[i], err = q.patternAlternatives(pattern); err != nil {
+				return nil, err
+			}
+		}
+		for j := range patterns {
+			for i := range patterns {
+				if i == j || !alternativesCover(alternatives[i], alternatives[j]) {
+					continue
+				}
+				// of two equivalent Patterns, the earlier one is kept
+				if i > j && alternativesCover(alternatives[j], alternatives[i]) {
+					continue
+				}
+				shadowed = append(shadowed, ShadowedPattern{X: x, Pattern: patterns[j], ShadowedBy: patterns[i]})
+				break
+			}
+		}
+	}
+	return shadowed, nil
+}
+
+// patternsByX returns the texts of the instance's Patterns, in the order they were added where that's known
+func (q *Quamina) patternsByX() (map[X][]string, error) {
+	byX := make(map[X][]string)
+	if q.patternTexts != nil {
+		q.patternTexts.lock.RLock()
+		for x, texts := range q.patternTexts.texts {
+			byX[x] = texts
+		}
+		q.patternTexts.lock.RUnlock()
+		return byX, nil
+	}
+	if pruner, ok := q.matcher.(*prunerMatcher); ok {
+		err := pruner.live.Iterate(func(x X, pattern string) error {
+			byX[x] = append(byX[x], pattern)
+			return nil
+		})
+		// the live state doesn't keep the order, so it's made repeatable
+		for _, patterns := range byX {
+			slices.Sort(patterns)
+		}
+		return byX, err
+	}
+	return nil, errors.New("pattern text is only kept by instances created WithPatternText(true) or WithPatternDeletion(true)")
+}
+
+// patternAlternatives returns the Constraints of the Patterns that AddPattern adds for a Pattern, which are
+// several if it is an EventBridge Pattern with "$or"; the Pattern matches an Event if any of them does
+func (q *Quamina) patternAlternatives(pattern string) ([]Constraints, error) {
+	texts := []string{pattern}
+	if q.eventBridge {
+		var err error
+		if texts, err = eventBridgePatterns(pattern); err != nil {
+			return nil, err
+		}
+	}
+	alternatives := make([]Constraints, len(texts))
+	for i, text := range texts {
+		var err error
+		if alternatives[i], err = patternConstraints(text, q.repeatedFields); err != nil {
+			return nil, err
+		}
+		if q.collation != nil {
+			uncollated(alternatives[i])
+		}
+	}
+	return alternatives, nil
+}
+
+// alternativesCover reports whether each of the alternatives in b is covered by one in a
+func alternativesCover(a, b []Constraints) bool {
+	for _, bAlt := range b {
+		if !slices.ContainsFunc(a, func(aAlt Constraints) bool { return aAlt.covers(bAlt) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// covers reports whether every Event that a Pattern with the Constraints d matches is matched by one with
+// cs: each of cs's fields must be one of d's, and allow all the values that d's does. A field with no values
+// is ignored, as AddPattern ignores it, and a Pattern without any other fields matches nothing, so covers
+// nothing.
+func (cs Constraints) covers(d Constraints) bool {
+	tested := false
+	for _, c := range cs {
+		if len(c.Comparisons) == 0 {
+			continue
+		}
+		tested = true
+		i := slices.IndexFunc(d, func(dc Constraint) bool { return dc.Path == c.Path && len(dc.Comparisons) > 0 })
+		if i < 0 || !c.covers(d[i]) {
+			return false
+		}
+	}
+	return tested
+}
+
+func (c Constraint) covers(d Constraint) bool {
+	// with several fields at the path, d may match one that c doesn't
+	if c.Every && !d.Every {
+		return false
+	}
+	for _, dComparison := range d.Comparisons {
+		if !slices.ContainsFunc(c.Comparisons, func(cComparison Comparison) bool {
+			return cComparison.covers(dComparison)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// covers reports whether every value that satisfies d satisfies c
+func (c Comparison) covers(d Comparison) bool {
+	switch c.Op {
+	case "exists":
+		// every test of a value needs the field to be there
+		return d.Op != "absent"
+	case "absent":
+		return d.Op == "absent"
+	case "=":
+		if d.Op != "=" || c.IsNumber != d.IsNumber {
+			return false
+		}
+		if c.IsNumber {
+			cn, cErr := parseNumber([]byte(c.Value))
+			dn, dErr := parseNumber([]byte(d.Value))
+			return cErr == nil && dErr == nil && cn == dn
+		}
+		return c.Value == d.Value
+	case "prefix":
+		switch d.Op {
+		case "prefix":
+			return strings.HasPrefix(d.Value, c.Value)
+		case "=":
+			return !d.IsNumber && strings.HasPrefix(d.Value, `"`+c.Value)
+		}
+	case "numeric":
+		r := numericRange{lo: c.Lo, hi: c.Hi, loOpen: c.LoOpen, hiOpen: c.HiOpen}
+		switch d.Op {
+		case "numeric":
+			return (c.Lo < d.Lo || (c.Lo == d.Lo && (!c.LoOpen || d.LoOpen))) &&
+				(d.Hi < c.Hi || (d.Hi == c.Hi && (!c.HiOpen || d.HiOpen)))
+		case "=":
+			n, err := parseNumber([]byte(d.Value))
+			return d.IsNumber && err == nil && r.contains(n)
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package quamina
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestShadowedPatterns(t *testing.T) {
+	for _, option := range []Option{WithPatternText(true), WithPatternDeletion(true)} {
+		q, _ := New(option, WithEventBridgeCompat())
+		add := func(x X, pattern string) {
+			t.Helper()
+			if err := q.AddPattern(x, pattern); err != nil {
+				t.Fatal(err)
+			}
+		}
+		add("orders", `{"kind": ["order", "refund"]}`)
+		add("orders", `{"kind": ["order"], "amount": [12]}`)
+		add("orders", `{"kind": [{"prefix": "ord"}], "user": [{"exists": true}]}`)
+		add("orders", `{"kind": ["orders"], "user": [{"wildcard": "a*"}]}`)
+		add("big", `{"amount": [{"numeric": [">=", 100]}]}`)
+		add("big", `{"amount": [{"numeric": [">", 100, "<", 200]}, 1e3], "currency": ["EUR"]}`)
+		add("big", `{"amount": [{"numeric": [">", 50]}], "currency": ["USD"]}`)
+		add("same", `{"a": ["x"], "b": [1]}`)
+		add("same", `{"b": [1.0], "a": ["x"]}`)
+		add("or", `{"$or": [{"a": ["x"]}, {"b": ["y"]}]}`)
+		add("or", `{"a": ["x"]}`)
+		add("or", `{"$or": [{"a": ["x"], "c": ["z"]}, {"b": ["y"]}]}`)
+		add("or", `{"$or": [{"a": ["x"]}, {"d": ["w"]}]}`)
+		add("other", `{"kind": ["order", "refund"]}`)
+		add("other", `{"kind": [{"anything-but": "x"}]}`)
+
+		shadowed, err := q.ShadowedPatterns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range shadowed {
+			got = append(got, fmt.Sprintf("%v: %s", s.X, s.Pattern))
+			// each really is covered
+			if !slices.Contains(q.texts(s.X), s.ShadowedBy) || s.ShadowedBy == s.Pattern {
+				t.Errorf("%s shadowed by %s", s.Pattern, s.ShadowedBy)
+			}
+		}
+		slices.Sort(got)
+		want := []string{
+			`big: {"amount": [{"numeric": [">", 100, "<", 200]}, 1e3], "currency": ["EUR"]}`,
+			`or: {"$or": [{"a": ["x"], "c": ["z"]}, {"b": ["y"]}]}`,
+			`or: {"a": ["x"]}`,
+			`orders: {"kind": ["order"], "amount": [12]}`,
+			`orders: {"kind": ["orders"], "user": [{"wildcard": "a*"}]}`,
+		}
+		// the pruner keeps the Patterns as EventBridge compatibility rewrites them, one for each "$or"
+		// alternative, and not in the order they were added, so the later of an equivalent pair is the one
+		// that sorts later
+		if _, ok := q.matcher.(*prunerMatcher); ok {
+			want = []string{
+				`big: {"amount":[{"numeric":[">",100,"<",200]},1e3],"currency":["EUR"]}`,
+				`or: {"a":["x"],"c":["z"]}`,
+				`orders: {"amount":[12],"kind":["order"]}`,
+				`orders: {"kind":["orders"],"user":[{"wildcard":"a*"}]}`,
+				`same: {"a":["x"],"b":[1]}`,
+			}
+		} else {
+			want = append(want, `same: {"b": [1.0], "a": ["x"]}`)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%T:\ngot  %q\nwant %q", q.matcher, got, want)
+		}
+	}
+
+	q, _ := New()
+	if _, err := q.ShadowedPatterns(); err == nil {
+		t.Error("ShadowedPatterns without pattern text")
+	}
+}
+
+// texts returns the Patterns ShadowedPatterns sees for x
+func (q *Quamina) texts(x X) []string {
+	byX, _ := q.patternsByX()
+	return byX[x]
+}
@@ -45,16 +45,28 @@ func readShellStyleSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []type
 // makeShellStyleFA does what it says.  It is precisely equivalent to a regex with the only operator
 // being a single ".*". Once we've implemented regular expressions we can use that to more or less eliminate this
 func makeShellStyleFA(val []byte, pp printer) (start *faState, nextField *fieldMatcher) {
+	return makeGlobFA(val, false, "SHELLSTYLE", pp)
+}
+
+// makeGlobFA builds the automaton for shellstyle and wildcard patterns, which differ only in that, if escapes is
+// set, as it is for wildcard patterns, "\*" and "\\" stand for "*" and "\". \-escape processing is simplified
+// because illegal constructs such as \a and \ at the end of the value have been rejected by readWildcardSpecial.
+func makeGlobFA(val []byte, escapes bool, label string, pp printer) (start *faState, nextField *fieldMatcher) {
 	state := &faState{table: newSmallTable()}
 	start = state
-	pp.labelTable(&start.table, "SHELLSTYLE")
+	pp.labelTable(&start.table, label)
 	nextField = newFieldMatcher()
 
 	// for each byte in the pattern
 	valIndex := 0
 	for valIndex < len(val) {
 		ch := val[valIndex]
-		if ch == '*' {
+		escaped := escapes && ch == '\\'
+		if escaped {
+			valIndex++
+			ch = val[valIndex]
+		}
+		if ch == '*' && !escaped {
 			spinner := state
 			spinner.isSpinner = true
 
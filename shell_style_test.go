@@ -281,6 +281,41 @@ func TestMixedPatterns(t *testing.T) {
 	}
 }
 
+// A trailing-star pattern becomes a prefix match, and merging a prefix into a lone "*" spinner leaves the
+// prefix's last state with nothing but an epsilon back to the spinner; it used to be left out of closures,
+// losing the prefix's match.
+func TestPrefixWithSpinner(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		event    string
+		want     []X
+	}{
+		{[]string{`{"x":[{"shellstyle":"*"}]}`, `{"x":[{"shellstyle":"b*"}]}`}, `{"x":"bb"}`, []X{0, 1}},
+		{[]string{`{"x":[{"shellstyle":"*"}]}`, `{"x":[{"shellstyle":"b*"}]}`}, `{"x":"ab"}`, []X{0}},
+		{[]string{`{"x":[{"shellstyle":"b*"}]}`, `{"x":[{"shellstyle":"*"}]}`}, `{"x":"bb"}`, []X{0, 1}},
+		{[]string{`{"x":[{"wildcard":"*"}]}`, `{"x":[{"wildcard":"a*"}]}`, `{"x":[{"wildcard":"bb*"}]}`}, `{"x":"bb"}`, []X{0, 2}},
+		{[]string{`{"x":[{"wildcard":"*"}]}`, `{"x":[{"wildcard":"a*"}]}`, `{"x":[{"wildcard":"bb*"}]}`}, `{"x":"ab"}`, []X{0, 1}},
+		{[]string{`{"x":[{"shellstyle":"*"}]}`, `{"x":[{"prefix":"b"}]}`}, `{"x":"bb"}`, []X{0, 1}},
+	}
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		for _, test := range tests {
+			m := newCoreMatcher()
+			for i, pattern := range test.patterns {
+				if err := m.addPattern(i, pattern, mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			matches, err := m.matchesForJSONEvent([]byte(test.event))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !containsExactly(matches, test.want) {
+				t.Errorf("%v %s mode %d: got %v, want %v", test.patterns, test.event, mode, matches, test.want)
+			}
+		}
+	}
+}
+
 /*
 // useful for debugging when an NFA ends up having a state with no table
 func sanityCheck(t *testing.T, fa *smallTable, pp *prettyPrinter) {
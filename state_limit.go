@@ -0,0 +1,55 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+)
+
+// PatternTooBigError is returned by AddPattern, for instances created WithPatternStateLimit, when the automata
+// for a Pattern need more states than the limit allows.
+type PatternTooBigError struct {
+	States int
+	Limit  int
+}
+
+func (e *PatternTooBigError) Error() string {
+	return fmt.Sprintf("pattern needs %d automaton states, more than the limit of %d", e.States, e.Limit)
+}
+
+// WithPatternStateLimit limits the number of automaton states that the Patterns added to the instance may each
+// need, as PreviewAddPattern counts them. Some Patterns, such as regexps like "(a|b)*a(a|b)(a|b)(a|b)" in
+// BuiltForSpeed mode, need a number of states that grows exponentially with their length, and one of them
+// can make adding and matching slow for every Pattern; the limit blocks them when they're submitted, so the
+// rule's author finds out rather than everyone else. If warn is nil, AddPattern fails with a
+// *PatternTooBigError for a Pattern over the limit; otherwise, warn is called with the error and the Pattern
+// is added anyway, so that limits can be tried out before they're enforced. Checking means compiling each
+// Pattern twice, so AddPattern takes about twice as long. The limit applies to instances created with Copy.
+// This option call may not be provided more than once.
+func WithPatternStateLimit(limit int, warn func(x X, patternJSON string, err *PatternTooBigError)) Option {
+	return func(q *Quamina) error {
+		if q.stateLimit != 0 {
+			return errors.New("pattern state limit specified more than once")
+		}
+		if limit <= 0 {
+			return errors.New("pattern state limit must be positive")
+		}
+		q.stateLimit = limit
+		q.stateLimitWarn = warn
+		return nil
+	}
+}
+
+// checkStateLimit returns a *PatternTooBigError if the Pattern needs more states than the instance's limit and
+// the instance doesn't just warn about it, or the error that stops the Pattern from being compiled
+func (q *Quamina) checkStateLimit(x X, patternJSON string) error {
+	preview, err := q.PreviewAddPattern(x, patternJSON)
+	if err != nil || preview.States <= q.stateLimit {
+		return err
+	}
+	tooBig := &PatternTooBigError{States: preview.States, Limit: q.stateLimit}
+	if q.stateLimitWarn == nil {
+		return tooBig
+	}
+	q.stateLimitWarn(x, patternJSON, tooBig)
+	return nil
+}
@@ -0,0 +1,68 @@
+package quamina
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPatternStateLimit(t *testing.T) {
+	// each (a|b) after the a doubles the states of the deterministic automaton
+	explosive := `{"x": [{"regexp": "(a|b)*a(a|b)(a|b)(a|b)(a|b)(a|b)(a|b)"}]}`
+	modest := `{"x": [{"regexp": "abc(d|e)"}], "y": ["z"]}`
+
+	q, _ := New(WithPatternStateLimit(100, nil))
+	_ = q.SetMatcherBuildMode(BuiltForSpeed)
+	if err := q.AddPattern("modest", modest); err != nil {
+		t.Fatal(err)
+	}
+	err := q.AddPattern("explosive", explosive)
+	var tooBig *PatternTooBigError
+	if !errors.As(err, &tooBig) || tooBig.Limit != 100 || tooBig.States <= 100 {
+		t.Fatalf("explosive Pattern: %v", err)
+	}
+	matches, _ := q.MatchesForEvent([]byte(`{"x": "abaaaaaaa"}`))
+	if len(matches) != 0 {
+		t.Errorf("rejected Pattern matched: %v", matches)
+	}
+	// copies have the limit too, and errors in Patterns are still reported as such
+	copied := q.Copy()
+	_ = copied.SetMatcherBuildMode(BuiltForSpeed)
+	if err := copied.AddPattern("explosive", explosive); !errors.As(err, &tooBig) {
+		t.Errorf("copy: %v", err)
+	}
+	if err := q.AddPattern("bad", `{"x": "a"}`); err == nil || errors.As(err, &tooBig) {
+		t.Errorf("bad Pattern: %v", err)
+	}
+
+	var warned []X
+	warning, _ := New(WithPatternStateLimit(100, func(x X, patternJSON string, err *PatternTooBigError) {
+		if patternJSON != explosive || err.States <= 100 {
+			t.Errorf("warned of %s: %v", patternJSON, err)
+		}
+		warned = append(warned, x)
+	}))
+	_ = warning.SetMatcherBuildMode(BuiltForSpeed)
+	for _, x := range []X{"modest", "explosive"} {
+		pattern := modest
+		if x == "explosive" {
+			pattern = explosive
+		}
+		if err := warning.AddPattern(x, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(warned) != 1 || warned[0] != "explosive" {
+		t.Errorf("warned %v", warned)
+	}
+	matches, _ = warning.MatchesForEvent([]byte(`{"x": "abaaaaaaa"}`))
+	if !containsExactly(matches, []X{"explosive"}) {
+		t.Errorf("warned-of Pattern not added: %v", matches)
+	}
+
+	if _, err := New(WithPatternStateLimit(0, nil)); err == nil {
+		t.Error("zero limit accepted")
+	}
+	if _, err := New(WithPatternStateLimit(1, nil), WithPatternStateLimit(2, nil)); err == nil {
+		t.Error("limit specified twice")
+	}
+}
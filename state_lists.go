@@ -1,12 +1,5 @@
 package quamina
 
-import (
-	"cmp"
-	"encoding/binary"
-	"slices"
-	"unsafe"
-)
-
 // internEntry bundles the list and DFA state into one map value so that
 // cache hits require a single map lookup instead of two.
 type internEntry struct {
@@ -15,61 +8,117 @@ type internEntry struct {
 }
 
 // The idea is that in we are going to be computing the epsilon closures of NFA states, which
-// will be slices of states. There will be duplicate slices and we want to deduplicate. There's
-// probably a more idiomatic and efficient way to do this.
+// will be slices of states. There will be duplicate slices and we want to deduplicate. Each
+// candidate set is canonicalized by sorting, then reduced to a 64-bit FNV-1a hash; since hashes
+// can collide, each hash maps to a (nearly always one-element) bucket of entries which are
+// compared member-by-member before a hit is declared. Sets are ordered by faState id rather
+// than by address so that the canonical form, and thus the shape of the DFA built from it,
+// is the same on every run.
 type stateLists struct {
-	entries map[string]internEntry
+	entries map[uint64][]internEntry
 	// Scratch space reused across intern() calls
-	sortBuf []*faState // reusable sorted buffer
-	keyBuf  []byte     // reusable key bytes buffer
+	sortBuf []*faState // reusable sorted, deduplicated buffer
 }
 
 func newStateLists() *stateLists {
 	return &stateLists{
-		entries: make(map[string]internEntry),
+		entries: make(map[uint64][]internEntry),
 	}
 }
 
+// FNV-1a 64-bit parameters, as in hash/fnv, inlined here to avoid the hash.Hash64 interface overhead
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
 // intern turns a collection of states that may have dupes and, when deduped and
 // considered as a set of states, may be identical to a previously-seen set of states.
 // It returns a canonicalized set representation of the collection, a DFA state
 // which either has already been computed for the set or is created and empty, and
 // a boolean indicating whether the DFA state has already been computed or not.
 func (sl *stateLists) intern(list []*faState) ([]*faState, *faState, bool) {
-	// Dedup by sorting then compacting adjacent duplicates. The set key is
-	// built from sorted pointers anyway, so sorting is not extra work; once
-	// sorted, duplicates are adjacent and Compact removes them in one linear
-	// pass. This avoids both a per-call dedup map and a per-faState
-	// generation field (the latter was removed to shrink steady-state memory).
-	sl.sortBuf = append(sl.sortBuf[:0], list...)
-	slices.SortFunc(sl.sortBuf, func(a, b *faState) int {
-		return cmp.Compare(uintptr(unsafe.Pointer(a)), uintptr(unsafe.Pointer(b)))
-	})
-	sl.sortBuf = slices.Compact(sl.sortBuf)
-
-	// Pre-size the key buffer and write pointers with PutUint64 instead of
-	// appending byte-by-byte, avoiding 8 append calls and bounds checks per state.
-	needed := len(sl.sortBuf) * 8
-	if cap(sl.keyBuf) < needed {
-		sl.keyBuf = make([]byte, needed)
-	} else {
-		sl.keyBuf = sl.keyBuf[:needed]
-	}
-	for i, state := range sl.sortBuf {
-		binary.LittleEndian.PutUint64(sl.keyBuf[i*8:], uint64(uintptr(unsafe.Pointer(state))))
-	}
+	sl.sortBuf = sortedInsertStates(sl.sortBuf[:0], list)
+	key := hashStates(sl.sortBuf)
 
-	// string(sl.keyBuf) in a map lookup is optimized by the compiler to avoid allocation
-	if entry, exists := sl.entries[string(sl.keyBuf)]; exists {
-		return entry.states, entry.dfaState, true
+	bucket := sl.entries[key]
+	for _, entry := range bucket {
+		if sameStates(entry.states, sl.sortBuf) {
+			return entry.states, entry.dfaState, true
+		}
 	}
 
-	// cache miss: allocate owned copies for the map
-	key := string(sl.keyBuf)
+	// cache miss: allocate an owned copy for the map
 	stored := make([]*faState, len(sl.sortBuf))
 	copy(stored, sl.sortBuf)
 
 	dfaState := &faState{table: newSmallTable()}
-	sl.entries[key] = internEntry{states: stored, dfaState: dfaState}
+	sl.entries[key] = append(bucket, internEntry{states: stored, dfaState: dfaState})
 	return stored, dfaState, false
 }
+
+// sortedInsertStates appends each member of list into sorted, keeping it ordered and
+// free of duplicates. The lists being interned are short (a handful of epsilon-closure
+// members) and heavily duplicated, so a binary-search insert that drops dupes on
+// arrival beats sorting the whole list and then compacting it.
+func sortedInsertStates(sorted []*faState, list []*faState) []*faState {
+	for _, state := range list {
+		key := stateSortKey(state)
+		lo, hi := 0, len(sorted)
+		for lo < hi {
+			mid := int(uint(lo+hi) >> 1)
+			if stateSortKey(sorted[mid]) < key {
+				lo = mid + 1
+			} else {
+				hi = mid
+			}
+		}
+		// ids are unique unless they've wrapped, in which case equal keys must be
+		// told apart by identity
+		dupe := false
+		for i := lo; i < len(sorted) && stateSortKey(sorted[i]) == key; i++ {
+			if sorted[i] == state {
+				dupe = true
+				break
+			}
+		}
+		if dupe {
+			continue
+		}
+		sorted = append(sorted, nil)
+		copy(sorted[lo+1:], sorted[lo:])
+		sorted[lo] = state
+	}
+	return sorted
+}
+
+// stateSortKey provides the ordering used to canonicalize sets of states.
+func stateSortKey(state *faState) uint32 {
+	return state.stateID()
+}
+
+// hashStates computes an FNV-1a hash over the sort keys of an already-canonicalized list.
+func hashStates(states []*faState) uint64 {
+	h := fnvOffset64
+	for _, state := range states {
+		k := stateSortKey(state)
+		for i := 0; i < 4; i++ {
+			h ^= uint64(k & 0xff)
+			h *= fnvPrime64
+			k >>= 8
+		}
+	}
+	return h
+}
+
+func sameStates(a, b []*faState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, state := range a {
+		if state != b[i] {
+			return false
+		}
+	}
+	return true
+}
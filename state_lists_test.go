@@ -81,3 +81,71 @@ func stateListsEquals(t *testing.T, list1, list2 []*faState) bool {
 	t.Helper()
 	return &list1[0] == &list2[0]
 }
+
+func TestSortedInsertStates(t *testing.T) {
+	states := []*faState{{}, {}, {}, {}}
+	list := []*faState{states[2], states[0], states[3], states[0], states[2], states[1], states[3]}
+	sorted := sortedInsertStates(nil, list)
+	if len(sorted) != 4 {
+		t.Fatalf("wanted 4 states, got %d", len(sorted))
+	}
+	for i := 1; i < len(sorted); i++ {
+		if stateSortKey(sorted[i-1]) >= stateSortKey(sorted[i]) {
+			t.Errorf("out of order at %d", i)
+		}
+	}
+}
+
+func TestStateListsHashCollision(t *testing.T) {
+	f1 := &faState{}
+	f2 := &faState{}
+	f3 := &faState{}
+	lists := newStateLists()
+
+	// plant an entry for a different set under the hash that {f1, f2} will produce, to
+	// simulate a collision
+	key := hashStates(sortedInsertStates(nil, []*faState{f1, f2}))
+	impostor := &faState{}
+	lists.entries[key] = []internEntry{{states: []*faState{f3}, dfaState: impostor}}
+
+	stored, dfa, already := lists.intern([]*faState{f2, f1})
+	if already || dfa == impostor {
+		t.Error("collision treated as hit")
+	}
+	if len(lists.entries[key]) != 2 {
+		t.Errorf("bucket should have 2 entries, has %d", len(lists.entries[key]))
+	}
+	again, dfa2, already := lists.intern([]*faState{f1, f2, f1})
+	if !already || dfa2 != dfa || !stateListsEquals(t, stored, again) {
+		t.Error("second intern missed")
+	}
+}
+
+func TestStateIDs(t *testing.T) {
+	f1 := &faState{}
+	f2 := &faState{}
+	id2 := f2.stateID()
+	id1 := f1.stateID()
+	if id1 == 0 || id2 == 0 || id1 <= id2 {
+		t.Errorf("ids should be handed out in first-use order: %d, %d", id1, id2)
+	}
+	if f1.stateID() != id1 || f2.stateID() != id2 {
+		t.Error("ids not stable")
+	}
+
+	// the canonical order follows ids, not addresses
+	sorted := sortedInsertStates(nil, []*faState{f1, f2})
+	if sorted[0] != f2 || sorted[1] != f1 {
+		t.Error("not sorted by id")
+	}
+}
+
+func TestSortedInsertDuplicateIDs(t *testing.T) {
+	// simulate wrapped-around ids
+	f1 := &faState{id: 7}
+	f2 := &faState{id: 7}
+	sorted := sortedInsertStates(nil, []*faState{f1, f2, f1, f2})
+	if len(sorted) != 2 {
+		t.Errorf("wanted 2 states, got %d", len(sorted))
+	}
+}
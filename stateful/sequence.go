@@ -0,0 +1,117 @@
+package stateful
+
+import (
+	"errors"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Sequence describes Events that matter only in order: one matching First, followed by one matching Then,
+// such as a login from a new device followed by a change of password.
+type Sequence struct {
+	// First is the Pattern of the Event that starts the Sequence.
+	First string
+	// Then is the Pattern of the Event that completes it.
+	Then string
+	// Within is how long after the First Event the Then Event has to come.
+	Within time.Duration
+	// Key, if not empty, is the path of a field, such as []string{"user"}, which correlates the Events: the
+	// Sequence is only completed by a Then Event with the same value as the First. Events without the field,
+	// with it inside an array, or with an object or array as its value, aren't part of any Sequence.
+	Key []string
+}
+
+// sequenceStep is the X that a Sequence's First or Then Pattern is added to the Quamina instance with; the
+// Matcher never reports it
+type sequenceStep struct {
+	x    quamina.X
+	then bool
+}
+
+// sequence is a Sequence and the times of the latest First Events, by key
+type sequence struct {
+	Sequence
+	path   string // the path of the Key
+	firsts map[string]time.Time
+	swept  int // the number of keys after the last sweep
+}
+
+// AddSequence adds a Sequence, which the Matcher reports with x when an Event matches its Then Pattern within
+// Within after an Event, with the same Key, matched its First Pattern. The First Event is then used up, so
+// each report stands for one pair; one Event that matches both Patterns doesn't complete a Sequence on its
+// own. Adding x again adds the Patterns as alternatives to those already added with it, and replaces Within
+// and Key, forgetting the First Events seen. Reports of x are subject to its Suppression, if it has one.
+func (m *Matcher) AddSequence(x quamina.X, s Sequence) error {
+	if s.Within <= 0 {
+		return errors.New("sequence window must be positive")
+	}
+	// neither Pattern is added unless both are good
+	for _, patternJSON := range []string{s.First, s.Then} {
+		if _, err := quamina.PatternConstraints(patternJSON); err != nil {
+			return err
+		}
+	}
+	path, err := m.keyPath(s.Key)
+	if err != nil {
+		return err
+	}
+	if err := m.q.AddPattern(sequenceStep{x: x}, s.First); err != nil {
+		return err
+	}
+	if err := m.q.AddPattern(sequenceStep{x: x, then: true}, s.Then); err != nil {
+		return err
+	}
+	s.Key = append([]string{}, s.Key...)
+	m.state.lock.Lock()
+	defer m.state.lock.Unlock()
+	m.state.sequences[x] = &sequence{Sequence: s, path: path, firsts: make(map[string]time.Time)}
+	return nil
+}
+
+// completed returns the X values of the Sequences that the Event, which matched the steps, completes, and
+// records the Sequences it starts. The caller holds the state's lock.
+func (m *Matcher) completed(steps []sequenceStep, now time.Time) []quamina.X {
+	var xs []quamina.X
+
+	// Then steps go first, so that an Event doesn't follow itself
+	for _, step := range steps {
+		seq, ok := m.state.sequences[step.x]
+		if !ok || !step.then {
+			continue
+		}
+		key, ok := m.key(seq.path)
+		if !ok {
+			continue
+		}
+		if first, ok := seq.firsts[key]; ok && now.Sub(first) < seq.Within {
+			delete(seq.firsts, key)
+			xs = append(xs, step.x)
+		}
+	}
+	for _, step := range steps {
+		seq, ok := m.state.sequences[step.x]
+		if !ok || step.then {
+			continue
+		}
+		if key, ok := m.key(seq.path); ok {
+			seq.started(key, now)
+		}
+	}
+	return xs
+}
+
+// started records a First Event with the key at the time
+func (s *sequence) started(key string, now time.Time) {
+	s.firsts[key] = now
+
+	// as with thresholds, keys that aren't seen again are dropped once they've doubled in number
+	if len(s.firsts) > 2*s.swept {
+		for k, first := range s.firsts {
+			if now.Sub(first) >= s.Within {
+				delete(s.firsts, k)
+			}
+		}
+		s.swept = len(s.firsts)
+	}
+}
@@ -0,0 +1,126 @@
+package stateful
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func TestSequence(t *testing.T) {
+	m, c := newTestMatcher(t)
+	err := m.AddSequence("takeover", Sequence{
+		First:  `{"event": ["new-device"]}`,
+		Then:   `{"event": ["password-changed"]}`,
+		Within: time.Hour,
+		Key:    []string{"user"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.q.AddPattern("device", `{"event": ["new-device"]}`); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		after time.Duration
+		event string
+		want  []quamina.X
+	}{
+		// Then without First
+		{0, `{"event": "password-changed", "user": "alice"}`, []quamina.X{}},
+		{time.Minute, `{"event": "new-device", "user": "alice"}`, []quamina.X{"device"}},
+		// correlated by user
+		{time.Minute, `{"event": "password-changed", "user": "bob"}`, []quamina.X{}},
+		{time.Minute, `{"event": "password-changed", "user": "alice"}`, []quamina.X{"takeover"}},
+		// the First Event is used up
+		{time.Minute, `{"event": "password-changed", "user": "alice"}`, []quamina.X{}},
+		// too late
+		{time.Minute, `{"event": "new-device", "user": "bob"}`, []quamina.X{"device"}},
+		{time.Hour, `{"event": "password-changed", "user": "bob"}`, []quamina.X{}},
+		// without the key, no Sequence
+		{time.Minute, `{"event": "new-device"}`, []quamina.X{"device"}},
+		{time.Minute, `{"event": "password-changed"}`, []quamina.X{}},
+		// the latest First Event counts
+		{time.Minute, `{"event": "new-device", "user": "carol"}`, []quamina.X{"device"}},
+		{59 * time.Minute, `{"event": "new-device", "user": "carol"}`, []quamina.X{"device"}},
+		{2 * time.Minute, `{"event": "password-changed", "user": "carol"}`, []quamina.X{"takeover"}},
+	}
+	for i, step := range steps {
+		c.advance(step.after)
+		got, err := m.MatchesForEvent([]byte(step.event))
+		slices.SortFunc(got, func(a, b quamina.X) int { return cmp.Compare(a.(string), b.(string)) })
+		if err != nil || !slices.Equal(got, step.want) {
+			t.Errorf("step %d, %s: %v, %v", i, step.event, got, err)
+		}
+	}
+}
+
+func TestSequenceItself(t *testing.T) {
+	m, c := newTestMatcher(t)
+	_ = m.AddSequence("x", Sequence{First: `{"a": [1]}`, Then: `{"b": [2]}`, Within: time.Minute})
+	_ = m.Suppress("x", Suppression{Window: time.Hour})
+	events := []string{
+		`{"a": 1, "b": 2}`,
+		`{"a": 1, "b": 2}`,
+		`{"a": 1, "b": 2}`,
+		`{"b": 2}`,
+	}
+	var reported []int
+	for i, event := range events {
+		c.advance(time.Second)
+		if xs, _ := m.MatchesForEvent([]byte(event)); len(xs) > 0 {
+			reported = append(reported, i)
+		}
+	}
+	// an Event doesn't follow itself, and the third report is suppressed
+	if !slices.Equal(reported, []int{1}) {
+		t.Errorf("reported %v", reported)
+	}
+}
+
+func TestSequenceDelete(t *testing.T) {
+	m, c := newTestMatcher(t, quamina.WithPatternDeletion(true))
+	_ = m.AddSequence("x", Sequence{First: `{"a": [1]}`, Then: `{"b": [2]}`, Within: time.Second, Key: []string{"k"}})
+	s := m.state.sequences["x"]
+	for i := 0; i < 100; i++ {
+		c.advance(2 * time.Second)
+		_, _ = m.MatchesForEvent([]byte(`{"a": 1, "k": ` + strconv.Itoa(i) + `}`))
+	}
+	if len(s.firsts) > 4 {
+		t.Errorf("%d keys kept", len(s.firsts))
+	}
+
+	_, _ = m.MatchesForEvent([]byte(`{"a": 1, "k": 0}`))
+	if err := m.DeletePatterns("x"); err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []string{`{"a": 1, "k": 0}`, `{"b": 2, "k": 0}`} {
+		if xs, err := m.q.MatchesForEvent([]byte(event)); err != nil || len(xs) != 0 {
+			t.Errorf("%s: %v, %v", event, xs, err)
+		}
+	}
+	if _, ok := m.state.sequences["x"]; ok {
+		t.Error("sequence kept")
+	}
+}
+
+func TestSequenceErrors(t *testing.T) {
+	m, _ := newTestMatcher(t)
+	bads := []Sequence{
+		{First: `{"a": [1]}`, Then: `{"b": [2]}`},
+		{First: `{"a": [1]}`, Then: `{"b": 2}`, Within: time.Second},
+		{First: `{"a": 1}`, Then: `{"b": [2]}`, Within: time.Second},
+	}
+	for _, bad := range bads {
+		if err := m.AddSequence("x", bad); err == nil {
+			t.Errorf("accepted %v", bad)
+		}
+	}
+	// nothing was added
+	if xs, _ := m.q.MatchesForEvent([]byte(`{"a": 1, "b": 2}`)); len(xs) != 0 {
+		t.Errorf("matched %v", xs)
+	}
+}
@@ -0,0 +1,194 @@
+// Package stateful adds, above a Quamina instance, matching that depends on the Events that came before, which
+// Quamina itself, matching each Event on its own, doesn't keep track of. A Matcher reports a Pattern added
+// with a Threshold only once it has matched enough Events in a span of time, and one with a Suppression no more
+// than once in a span of time, and a Sequence when one Event follows another, so simple alerting needs nothing
+// more:
+//
+//	m, _ := stateful.New(q)
+//	_ = m.AddThreshold("brute-force", `{"event": ["login-failed"]}`,
+//		stateful.Threshold{Count: 5, Window: time.Minute, Key: []string{"user"}})
+//	_ = m.Suppress("brute-force", stateful.Suppression{Window: time.Hour, Key: []string{"user"}})
+//	_ = m.AddSequence("takeover", stateful.Sequence{First: `{"event": ["new-device"]}`,
+//		Then: `{"event": ["password-changed"]}`, Within: time.Hour, Key: []string{"user"}})
+//	...
+//	xs, err := m.MatchesForEvent(event)
+//
+// The Matcher's Patterns are added to the instance it was made with, so Events should be matched through the
+// Matcher rather than the instance, which would report them every time they match. Keys are read from the
+// fields the instance's Flattener extracts, so Events needn't be JSON.
+package stateful
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Matcher matches Events with a Quamina instance, and keeps what it needs to know of them to report the
+// matches of the Patterns added to it. Like a Quamina instance, a Matcher mustn't be used by more than one
+// goroutine at once; Copy makes one for another goroutine, which shares what it knows.
+type Matcher struct {
+	q      *quamina.Quamina
+	state  *state
+	fields []quamina.Field // the fields of the Event being matched with the paths of Keys
+}
+
+// state is what a Matcher and its copies know of the Events they've matched
+type state struct {
+	now          func() time.Time
+	lock         sync.Mutex
+	thresholds   map[quamina.X]*threshold
+	suppressions map[quamina.X]*suppression
+	sequences    map[quamina.X]*sequence
+	paths        []string // the paths of the Keys, which are only ever appended to
+}
+
+// Option is the type of the arguments to New which configure a Matcher.
+type Option func(s *state) error
+
+// WithClock provides the function which the Matcher calls to find the time at which each Event is matched,
+// instead of time.Now, for example to process Events with the times they were recorded at.
+func WithClock(now func() time.Time) Option {
+	return func(s *state) error {
+		if now == nil {
+			return errors.New("nil clock")
+		}
+		s.now = now
+		return nil
+	}
+}
+
+// New returns a Matcher which matches Events with q.
+func New(q *quamina.Quamina, opts ...Option) (*Matcher, error) {
+	if q == nil {
+		return nil, errors.New("nil Quamina instance")
+	}
+	s := &state{
+		now:          time.Now,
+		thresholds:   make(map[quamina.X]*threshold),
+		suppressions: make(map[quamina.X]*suppression),
+		sequences:    make(map[quamina.X]*sequence),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+	return &Matcher{q: q, state: s}, nil
+}
+
+// Copy returns a Matcher for use on another goroutine, which matches Events with a copy of m's Quamina
+// instance, and shares what m knows of the Events it has matched.
+func (m *Matcher) Copy() *Matcher {
+	return &Matcher{q: m.q.Copy(), state: m.state}
+}
+
+// DeletePatterns deletes the Patterns added with x from the Quamina instance, which must support that, and
+// forgets what the Matcher knows of their matches.
+func (m *Matcher) DeletePatterns(x quamina.X) error {
+	for _, x := range []quamina.X{x, sequenceStep{x: x}, sequenceStep{x: x, then: true}} {
+		if err := m.q.DeletePatterns(x); err != nil {
+			return err
+		}
+	}
+	m.state.lock.Lock()
+	defer m.state.lock.Unlock()
+	delete(m.state.thresholds, x)
+	delete(m.state.suppressions, x)
+	delete(m.state.sequences, x)
+	return nil
+}
+
+// MatchesForEvent returns the X values of the Patterns that match the Event, as the Quamina instance's
+// MatchesForEvent does, except that those with Thresholds are only reported when they're reached, and those
+// with Suppressions aren't reported again until their Windows have passed. The X values of the Sequences that
+// the Event completes are included.
+func (m *Matcher) MatchesForEvent(event []byte) ([]quamina.X, error) {
+	s := m.state
+	s.lock.Lock()
+	paths := s.paths
+	s.lock.Unlock()
+	xs, fields, err := m.q.MatchesForEventWithFields(event, paths...)
+	if err != nil || len(xs) == 0 {
+		return xs, err
+	}
+	m.fields = fields
+	now := s.now()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	reported := xs[:0]
+	var steps []sequenceStep
+	for _, x := range xs {
+		if step, ok := x.(sequenceStep); ok {
+			steps = append(steps, step)
+		} else if m.reports(x, now) {
+			reported = append(reported, x)
+		}
+	}
+	if steps != nil {
+		for _, x := range m.completed(steps, now) {
+			if !slices.Contains(reported, x) && m.reports(x, now) {
+				reported = append(reported, x)
+			}
+		}
+	}
+	return reported, nil
+}
+
+// reports says whether a match of x by the Event at the time is reported, given x's Threshold and Suppression.
+// The caller holds the state's lock.
+func (m *Matcher) reports(x quamina.X, now time.Time) bool {
+	if t, ok := m.state.thresholds[x]; ok {
+		key, ok := m.key(t.path)
+		if !ok || !t.reached(key, now) {
+			return false
+		}
+	}
+	if s, ok := m.state.suppressions[x]; ok {
+		key, _ := m.key(s.path)
+		if s.suppressed(key, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// keyPath returns the path of the field named by a Key, which is "" for an empty Key, and has the Quamina
+// instance's Flattener extract the field, so that the Key can be found in Events of any format.
+func (m *Matcher) keyPath(key []string) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	path := quamina.JoinPath(key...)
+	if err := m.q.AddFieldPaths(path); err != nil {
+		return "", err
+	}
+	m.state.lock.Lock()
+	defer m.state.lock.Unlock()
+	if !slices.Contains(m.state.paths, path) {
+		m.state.paths = append(slices.Clip(m.state.paths), path)
+	}
+	return path, nil
+}
+
+// key returns the value, as the Flattener gave it, of the field of the Event with the path, which is "" for an
+// empty path. A field inside an array, or one that isn't there, has none; nor has an object or array, which
+// the Flattener doesn't give as a field.
+func (m *Matcher) key(path string) (string, bool) {
+	if path == "" {
+		return "", true
+	}
+	for _, field := range m.fields {
+		if string(field.Path) == path {
+			if len(field.ArrayTrail) > 0 {
+				return "", false
+			}
+			return string(field.Val), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,120 @@
+package stateful
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/syslog"
+)
+
+// clock is a time that tests move forward by hand
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestMatcher(t *testing.T, opts ...quamina.Option) (*Matcher, *clock) {
+	t.Helper()
+	q, err := quamina.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &clock{t: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	m, err := New(q, WithClock(c.now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, c
+}
+
+func TestKey(t *testing.T) {
+	m, _ := newTestMatcher(t)
+	_ = m.q.AddPattern("x", `{"user": {"name": [{"exists": true}]}}`)
+	event := []byte(`{"user": {"name": "alice", "id": 17, "tags": ["a"], "address": {"zip": "02134", "city": "Boston"}}}`)
+	tests := []struct {
+		key  []string
+		want string
+		ok   bool
+	}{
+		{nil, "", true},
+		{[]string{"user", "name"}, `"alice"`, true},
+		{[]string{"user", "id"}, `17`, true},
+		{[]string{"user", "address"}, "", false},
+		{[]string{"user", "missing"}, "", false},
+		{[]string{"user", "tags"}, "", false},
+		{[]string{"user", "tags", "0"}, "", false},
+	}
+	paths := make([]string, len(tests))
+	for i, test := range tests {
+		var err error
+		if paths[i], err = m.keyPath(test.key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if xs, err := m.MatchesForEvent(event); err != nil || len(xs) != 1 {
+		t.Fatalf("matches %v, %v", xs, err)
+	}
+	for i, test := range tests {
+		got, ok := m.key(paths[i])
+		if got != test.want || ok != test.ok {
+			t.Errorf("%v: %q, %v", test.key, got, ok)
+		}
+	}
+	if _, err := m.keyPath([]string{""}); err == nil {
+		t.Error("accepted an empty key path")
+	}
+}
+
+// Keys are found in Events of any format the instance's Flattener reads
+func TestKeyFlattener(t *testing.T) {
+	q, _ := quamina.New(quamina.WithFlattener(syslog.NewFlattener()))
+	m, err := New(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.AddThreshold("errors", `{"severity": [{"numeric": ["<=", 3]}]}`,
+		Threshold{Count: 2, Window: time.Minute, Key: []string{"hostname"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported []int
+	for i, host := range []string{"a", "b", "c", "b"} {
+		xs, err := m.MatchesForEvent([]byte("<11>1 - " + host + " app - - - disk failed"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(xs) > 0 {
+			reported = append(reported, i)
+		}
+	}
+	if !slices.Equal(reported, []int{3}) {
+		t.Errorf("reported %v", reported)
+	}
+}
+
+func TestCopyAndDelete(t *testing.T) {
+	m, _ := newTestMatcher(t, quamina.WithPatternDeletion(true))
+	_ = m.AddThreshold("x", `{"a": [1]}`, Threshold{Count: 2, Window: time.Minute})
+	other := m.Copy()
+	if xs, _ := m.MatchesForEvent([]byte(`{"a": 1}`)); len(xs) != 0 {
+		t.Errorf("first match reported: %v", xs)
+	}
+	if xs, _ := other.MatchesForEvent([]byte(`{"a": 1}`)); len(xs) != 1 {
+		t.Errorf("copy didn't share the count: %v", xs)
+	}
+	if err := m.DeletePatterns("x"); err != nil {
+		t.Fatal(err)
+	}
+	if xs, _ := other.MatchesForEvent([]byte(`{"a": 1}`)); len(xs) != 0 {
+		t.Errorf("deleted pattern reported: %v", xs)
+	}
+}
@@ -0,0 +1,69 @@
+package stateful
+
+import (
+	"errors"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Suppression says how often a Matcher may report the Patterns added with an X.
+type Suppression struct {
+	// Window is how long after a report the X isn't reported again.
+	Window time.Duration
+	// Key, if not empty, is the path of a field, such as []string{"host"}, for each of whose values the X is
+	// reported separately, so that one noisy host doesn't hide the others. Events without the field are
+	// suppressed together.
+	Key []string
+}
+
+// suppression is a Suppression and the times the X was last reported, by key
+type suppression struct {
+	Suppression
+	path  string // the path of the Key
+	last  map[string]time.Time
+	swept int // the number of keys after the last sweep
+}
+
+// Suppress arranges that the Matcher reports x, for each value of the Suppression's Key, at most once per
+// Window: after a report, the matches of x's Patterns are dropped until Window has passed. It applies to the
+// Patterns added with x by any means, after their Thresholds, if they have them, so that an alert which
+// keeps firing is reported once. Suppress with a zero Window stops suppressing x.
+func (m *Matcher) Suppress(x quamina.X, s Suppression) error {
+	if s.Window < 0 {
+		return errors.New("suppression window must not be negative")
+	}
+	path, err := m.keyPath(s.Key)
+	if err != nil {
+		return err
+	}
+	m.state.lock.Lock()
+	defer m.state.lock.Unlock()
+	if s.Window == 0 {
+		delete(m.state.suppressions, x)
+		return nil
+	}
+	s.Key = append([]string{}, s.Key...)
+	m.state.suppressions[x] = &suppression{Suppression: s, path: path, last: make(map[string]time.Time)}
+	return nil
+}
+
+// suppressed reports whether a match for the key at the time is suppressed, and if not, records it as
+// reported
+func (s *suppression) suppressed(key string, now time.Time) bool {
+	if last, ok := s.last[key]; ok && now.Sub(last) < s.Window {
+		return true
+	}
+	s.last[key] = now
+
+	// as with thresholds, keys that aren't seen again are dropped once they've doubled in number
+	if len(s.last) > 2*s.swept {
+		for k, last := range s.last {
+			if now.Sub(last) >= s.Window {
+				delete(s.last, k)
+			}
+		}
+		s.swept = len(s.last)
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package stateful
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func TestSuppress(t *testing.T) {
+	m, c := newTestMatcher(t)
+	if err := m.q.AddPattern("disk-full", `{"alert": ["disk-full"]}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.q.AddPattern("any-alert", `{"alert": [{"exists": true}]}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Suppress("disk-full", Suppression{Window: time.Minute, Key: []string{"host"}}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		after time.Duration
+		event string
+		want  []quamina.X
+	}{
+		{0, `{"alert": "disk-full", "host": "a"}`, []quamina.X{"any-alert", "disk-full"}},
+		{time.Second, `{"alert": "disk-full", "host": "a"}`, []quamina.X{"any-alert"}},
+		{time.Second, `{"alert": "disk-full", "host": "b"}`, []quamina.X{"any-alert", "disk-full"}},
+		// without the key, suppressed together
+		{time.Second, `{"alert": "disk-full"}`, []quamina.X{"any-alert", "disk-full"}},
+		{time.Second, `{"alert": "disk-full", "host": null}`, []quamina.X{"any-alert", "disk-full"}},
+		{time.Second, `{"alert": "disk-full"}`, []quamina.X{"any-alert"}},
+		// the window runs from the report, not from the matches suppressed since
+		{55 * time.Second, `{"alert": "disk-full", "host": "a"}`, []quamina.X{"any-alert", "disk-full"}},
+		{time.Second, `{"alert": "disk-full", "host": "b"}`, []quamina.X{"any-alert"}},
+		{time.Second, `{"alert": "disk-full", "host": "b"}`, []quamina.X{"any-alert", "disk-full"}},
+	}
+	check := func(i int, event string, want []quamina.X) {
+		t.Helper()
+		got, err := m.MatchesForEvent([]byte(event))
+		slices.SortFunc(got, func(a, b quamina.X) int { return cmp.Compare(a.(string), b.(string)) })
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("step %d, %s: %v, %v", i, event, got, err)
+		}
+	}
+	for i, step := range steps {
+		c.advance(step.after)
+		check(i, step.event, step.want)
+	}
+
+	// a zero window stops suppressing
+	if err := m.Suppress("disk-full", Suppression{}); err != nil {
+		t.Fatal(err)
+	}
+	check(len(steps), `{"alert": "disk-full", "host": "b"}`, []quamina.X{"any-alert", "disk-full"})
+
+	if err := m.Suppress("disk-full", Suppression{Window: -time.Second}); err == nil {
+		t.Error("accepted negative window")
+	}
+}
+
+func TestSuppressThreshold(t *testing.T) {
+	m, c := newTestMatcher(t, quamina.WithPatternDeletion(true))
+	_ = m.AddThreshold("x", `{"a": [1]}`, Threshold{Count: 2, Window: time.Minute})
+	_ = m.Suppress("x", Suppression{Window: time.Hour})
+
+	// only reports of the threshold are suppressed, so matches below it don't start the window
+	var reported []int
+	for i := 0; i < 10; i++ {
+		c.advance(time.Second)
+		if xs, _ := m.MatchesForEvent([]byte(`{"a": 1}`)); len(xs) > 0 {
+			reported = append(reported, i)
+		}
+	}
+	c.advance(time.Hour)
+	for i := 10; i < 12; i++ {
+		if xs, _ := m.MatchesForEvent([]byte(`{"a": 1}`)); len(xs) > 0 {
+			reported = append(reported, i)
+		}
+	}
+	if !slices.Equal(reported, []int{1, 11}) {
+		t.Errorf("reported %v", reported)
+	}
+
+	// deleting forgets the suppression
+	if err := m.DeletePatterns("x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.state.suppressions["x"]; ok {
+		t.Error("suppression kept")
+	}
+}
+
+func TestSuppressSweep(t *testing.T) {
+	m, c := newTestMatcher(t)
+	_ = m.q.AddPattern("x", `{"a": [1]}`)
+	_ = m.Suppress("x", Suppression{Window: time.Second, Key: []string{"k"}})
+	s := m.state.suppressions["x"]
+	for i := 0; i < 100; i++ {
+		c.advance(2 * time.Second)
+		if xs, _ := m.MatchesForEvent([]byte(`{"a": 1, "k": ` + strconv.Itoa(i) + `}`)); len(xs) != 1 {
+			t.Errorf("event %d suppressed", i)
+		}
+	}
+	if len(s.last) > 4 {
+		t.Errorf("%d keys kept", len(s.last))
+	}
+}
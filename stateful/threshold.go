@@ -0,0 +1,89 @@
+package stateful
+
+import (
+	"errors"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// Threshold says how many matches a Pattern needs, in how long, before a Matcher reports it.
+type Threshold struct {
+	// Count is the number of Events the Pattern has to match within Window.
+	Count int
+	// Window is how far back the Events are counted from the one being matched.
+	Window time.Duration
+	// Key, if not empty, is the path of a field, such as []string{"user"}, whose values the matches are counted
+	// separately for, so that the Pattern is reported when one user, not several, fails to log in too often.
+	// Events without the field, with it inside an array, or with an object or array as its value, aren't
+	// counted.
+	Key []string
+}
+
+// threshold is a Threshold and the times of the matches it has counted, by key
+type threshold struct {
+	Threshold
+	path  string // the path of the Key
+	hits  map[string][]time.Time
+	swept int // the number of keys after the last sweep
+}
+
+// AddThreshold adds a Pattern to the Quamina instance with x, which the Matcher reports only when, counting
+// the Event being matched, the Pattern has matched Count Events within Window. Its count then starts again,
+// so each report stands for Count Events. The Threshold applies to all the Patterns added with x; adding x
+// again, with AddThreshold, replaces it and forgets the matches counted.
+func (m *Matcher) AddThreshold(x quamina.X, patternJSON string, t Threshold) error {
+	if t.Count < 1 {
+		return errors.New("threshold count must be at least 1")
+	}
+	if t.Window <= 0 {
+		return errors.New("threshold window must be positive")
+	}
+	path, err := m.keyPath(t.Key)
+	if err != nil {
+		return err
+	}
+	if err := m.q.AddPattern(x, patternJSON); err != nil {
+		return err
+	}
+	t.Key = append([]string{}, t.Key...)
+	m.state.lock.Lock()
+	defer m.state.lock.Unlock()
+	m.state.thresholds[x] = &threshold{Threshold: t, path: path, hits: make(map[string][]time.Time)}
+	return nil
+}
+
+// reached counts a match of the Event with the key at the time, and reports whether the Threshold has been
+// reached
+func (t *threshold) reached(key string, now time.Time) bool {
+	hits := t.recent(t.hits[key], now)
+	hits = append(hits, now)
+	if len(hits) >= t.Count {
+		delete(t.hits, key)
+		return true
+	}
+	t.hits[key] = hits
+
+	// keys that aren't seen again would stay forever, so when there are twice as many as there were, those
+	// without recent matches are dropped
+	if len(t.hits) > 2*t.swept {
+		for k, hits := range t.hits {
+			if hits = t.recent(hits, now); len(hits) == 0 {
+				delete(t.hits, k)
+			} else {
+				t.hits[k] = hits
+			}
+		}
+		t.swept = len(t.hits)
+	}
+	return false
+}
+
+// recent returns the times that are within the Window before now
+func (t *threshold) recent(hits []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && now.Sub(hits[i]) >= t.Window {
+		i++
+	}
+	return hits[i:]
+}
@@ -0,0 +1,107 @@
+package stateful
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+func TestThreshold(t *testing.T) {
+	m, c := newTestMatcher(t)
+	err := m.AddThreshold("brute-force", `{"event": ["login-failed"]}`,
+		Threshold{Count: 3, Window: time.Minute, Key: []string{"user"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.AddThreshold("outage", `{"status": [503]}`, Threshold{Count: 2, Window: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.q.AddPattern("any-failure", `{"event": ["login-failed"]}`); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		after time.Duration
+		event string
+		want  []quamina.X
+	}{
+		{0, `{"event": "login-failed", "user": "alice"}`, []quamina.X{"any-failure"}},
+		{time.Second, `{"event": "login-failed", "user": "bob"}`, []quamina.X{"any-failure"}},
+		{time.Second, `{"event": "login-failed", "user": "alice"}`, []quamina.X{"any-failure"}},
+		{time.Second, `{"event": "login-failed", "user": "alice"}`, []quamina.X{"any-failure", "brute-force"}},
+		// the count starts again after a report
+		{time.Second, `{"event": "login-failed", "user": "alice"}`, []quamina.X{"any-failure"}},
+		// without the key, nothing is counted
+		{time.Second, `{"event": "login-failed"}`, []quamina.X{"any-failure"}},
+		{time.Second, `{"event": "login-failed", "user": ["alice"]}`, []quamina.X{"any-failure"}},
+		// bob's first failure has left the window
+		{time.Minute, `{"event": "login-failed", "user": "bob"}`, []quamina.X{"any-failure"}},
+		{time.Second, `{"event": "login-failed", "user": "bob"}`, []quamina.X{"any-failure"}},
+		{time.Second, `{"event": "login-failed", "user": "bob"}`, []quamina.X{"any-failure", "brute-force"}},
+		{0, `{"status": 503}`, []quamina.X{}},
+		{10 * time.Second, `{"status": 503}`, []quamina.X{}},
+		{9 * time.Second, `{"status": 503}`, []quamina.X{"outage"}},
+	}
+	for i, step := range steps {
+		c.advance(step.after)
+		got, err := m.MatchesForEvent([]byte(step.event))
+		slices.SortFunc(got, func(a, b quamina.X) int { return cmp.Compare(a.(string), b.(string)) })
+		if err != nil || !slices.Equal(got, step.want) {
+			t.Errorf("step %d, %s: %v, %v", i, step.event, got, err)
+		}
+	}
+}
+
+func TestThresholdKeys(t *testing.T) {
+	m, c := newTestMatcher(t)
+	_ = m.AddThreshold("x", `{"a": [1]}`, Threshold{Count: 2, Window: time.Second, Key: []string{"k", "id"}})
+	events := []string{
+		`{"a": 1, "k": {"id": 7}}`,
+		`{"a": 1, "k": {"id": "7"}}`,
+		`{"a": 1, "k": {"id": {"n": 7}}}`,
+		`{"a": 1, "k": {"id": [7]}}`,
+		`{"a": 1, "k": {"id": "7"}}`,
+	}
+	var reported []int
+	for i, event := range events {
+		xs, _ := m.MatchesForEvent([]byte(event))
+		if len(xs) > 0 {
+			reported = append(reported, i)
+		}
+	}
+	if !slices.Equal(reported, []int{4}) {
+		t.Errorf("reported %v", reported)
+	}
+
+	// keys whose matches are old are forgotten
+	t1 := m.state.thresholds["x"]
+	for i := 0; i < 100; i++ {
+		c.advance(2 * time.Second)
+		_, _ = m.MatchesForEvent([]byte(`{"a": 1, "k": {"id": ` + strconv.Itoa(i) + `}}`))
+	}
+	if len(t1.hits) > 4 {
+		t.Errorf("%d keys kept", len(t1.hits))
+	}
+}
+
+func TestThresholdErrors(t *testing.T) {
+	m, _ := newTestMatcher(t)
+	for _, bad := range []Threshold{{Count: 0, Window: time.Second}, {Count: 1}} {
+		if err := m.AddThreshold("x", `{"a": [1]}`, bad); err == nil {
+			t.Errorf("accepted %v", bad)
+		}
+	}
+	if err := m.AddThreshold("x", `{"a": 1}`, Threshold{Count: 1, Window: time.Second}); err == nil {
+		t.Error("accepted bad pattern")
+	}
+	if _, err := New(nil); err == nil {
+		t.Error("accepted nil instance")
+	}
+	q, _ := quamina.New()
+	if _, err := New(q, WithClock(nil)); err == nil {
+		t.Error("accepted nil clock")
+	}
+}
@@ -155,6 +155,24 @@ func vmStats(m *valueMatcher, s *statsAccum) {
 	if state.start != nil {
 		faStats(&state.start.table, s)
 	}
+	for _, next := range state.exacts {
+		fmStats(next, s)
+	}
+	if state.substrings != nil {
+		for _, pattern := range state.substrings.patterns {
+			fmStats(pattern.next, s)
+		}
+	}
+	if state.prefixes != nil {
+		state.prefixes.visit(func(next *fieldMatcher) {
+			fmStats(next, s)
+		})
+	}
+	if state.ranges != nil {
+		state.ranges.visit(func(next *fieldMatcher) {
+			fmStats(next, s)
+		})
+	}
 }
 
 func faStats(t *smallTable, s *statsAccum) {
@@ -187,3 +205,134 @@ func faStats(t *smallTable, s *statsAccum) {
 		faStats(&epsilon.table, s)
 	}
 }
+
+// AutomatonStats describes the structure of the automata a Quamina instance has built for its Patterns; see
+// Quamina.AutomatonStats
+type AutomatonStats struct {
+	// FieldMatchers is the number of points at which matching moves from one field to the next
+	FieldMatchers int
+	// ValueMatchers is the number of fields with values to match, one for each FieldMatcher they're reached from
+	ValueMatchers int
+	// States is the number of automaton states, each of which has one table of transitions
+	States int
+	// Fanouts is a histogram of the states' tables: Fanouts[n] is the number of states with transitions on n
+	// ranges of byte values
+	Fanouts []int
+	// EpsilonTransitions is the number of transitions, in all the tables, which are taken without consuming
+	// a byte; these are the ones that make an automaton nondeterministic
+	EpsilonTransitions int
+	// MaxEpsilons is the largest number of epsilon transitions in any one table
+	MaxEpsilons int
+	// MaxDepth is the largest number of transitions it takes to reach a state from the start of its automaton
+	MaxDepth int
+}
+
+// AutomatonStats traverses the automata built for the instance's Patterns and reports on their structure,
+// which is useful in working out why adding or matching is slow for a particular set of Patterns. Like
+// GetMatcherStats, it should not be run in parallel with AddPattern calls.
+func (q *Quamina) AutomatonStats() AutomatonStats {
+	return q.matcher.automatonStats()
+}
+
+func (m *coreMatcher) automatonStats() AutomatonStats {
+	w := automatonWalk{
+		fmVisited: make(map[*fieldMatcher]bool),
+		seen:      make(map[*faState]bool),
+	}
+	w.fieldMatcher(m.fields().state)
+	return w.stats
+}
+
+// automatonWalk accumulates AutomatonStats. Each automaton is traversed breadth-first from its start state,
+// so that the depth at which a state is first seen is the shortest path to it.
+type automatonWalk struct {
+	stats     AutomatonStats
+	fmVisited map[*fieldMatcher]bool
+	seen      map[*faState]bool
+}
+
+func (w *automatonWalk) fieldMatcher(fm *fieldMatcher) {
+	if w.fmVisited[fm] {
+		return
+	}
+	w.fmVisited[fm] = true
+	w.stats.FieldMatchers++
+	for _, vm := range fm.fields().transitions {
+		w.stats.ValueMatchers++
+		fields := vm.fields()
+		if fields.singletonTransition != nil {
+			w.fieldMatcher(fields.singletonTransition)
+		}
+		for _, next := range fields.exacts {
+			w.fieldMatcher(next)
+		}
+		if fields.substrings != nil {
+			for _, pattern := range fields.substrings.patterns {
+				w.fieldMatcher(pattern.next)
+			}
+		}
+		if fields.prefixes != nil {
+			fields.prefixes.visit(w.fieldMatcher)
+		}
+		if fields.ranges != nil {
+			fields.ranges.visit(w.fieldMatcher)
+		}
+		if fields.start != nil {
+			w.automaton(fields.start)
+		}
+	}
+	for _, next := range fm.fields().existsTrue {
+		w.fieldMatcher(next)
+	}
+	for _, next := range fm.fields().existsFalse {
+		w.fieldMatcher(next)
+	}
+}
+
+func (w *automatonWalk) automaton(start *faState) {
+	if w.seen[start] {
+		return
+	}
+	w.seen[start] = true
+	level := []*faState{start}
+	for depth := 0; len(level) > 0; depth++ {
+		w.stats.MaxDepth = max(w.stats.MaxDepth, depth)
+		var next []*faState
+		visit := func(state *faState) {
+			if state != nil && !w.seen[state] {
+				w.seen[state] = true
+				next = append(next, state)
+			}
+		}
+		for _, state := range level {
+			w.state(state)
+			for _, step := range state.table.steps {
+				visit(step)
+			}
+			for _, eps := range state.table.epsilons {
+				visit(eps)
+			}
+		}
+		level = next
+	}
+}
+
+func (w *automatonWalk) state(state *faState) {
+	w.stats.States++
+	fanout := 0
+	for _, step := range state.table.steps {
+		if step != nil {
+			fanout++
+		}
+	}
+	for len(w.stats.Fanouts) <= fanout {
+		w.stats.Fanouts = append(w.stats.Fanouts, 0)
+	}
+	w.stats.Fanouts[fanout]++
+	epsilons := len(state.table.epsilons)
+	w.stats.EpsilonTransitions += epsilons
+	w.stats.MaxEpsilons = max(w.stats.MaxEpsilons, epsilons)
+	for _, next := range state.fieldTransitions {
+		w.fieldMatcher(next)
+	}
+}
@@ -0,0 +1,56 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestAutomatonStats(t *testing.T) {
+	q, _ := New()
+	if stats := q.AutomatonStats(); stats.FieldMatchers != 1 || stats.States != 0 || stats.MaxDepth != 0 {
+		t.Errorf("empty: %+v", stats)
+	}
+
+	patterns := []string{
+		`{"a": [{"regexp": "xyz(p|q)"}], "b": ["1"]}`,
+		`{"a": [{"shellstyle": "*yz*"}]}`,
+		`{"a": [{"regexp": "x(ab)*c"}]}`,
+		`{"c": [{"prefix": "pre"}]}`,
+		`{"d": [{"exists": true}], "e": [{"regexp": "x(ab)*c"}]}`,
+	}
+	for _, pattern := range patterns {
+		if err := q.AddPattern(pattern, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := q.AutomatonStats()
+	total := 0
+	for _, n := range stats.Fanouts {
+		total += n
+	}
+	if total != stats.States {
+		t.Errorf("fanouts %v for %d states", stats.Fanouts, stats.States)
+	}
+	// the final states have no byte transitions, and most of the others, spelling out literals, have one
+	if len(stats.Fanouts) < 2 || stats.Fanouts[0] == 0 || stats.Fanouts[1] == 0 {
+		t.Errorf("fanouts %v", stats.Fanouts)
+	}
+	if stats.EpsilonTransitions == 0 || stats.MaxEpsilons == 0 {
+		t.Errorf("no epsilons in %+v", stats)
+	}
+	// "xyzp" takes at least four steps
+	if stats.MaxDepth < 4 {
+		t.Errorf("depth %d", stats.MaxDepth)
+	}
+	// a and c from the start, b after the regexp, and e after d exists
+	if stats.ValueMatchers != 4 || stats.FieldMatchers < 4 {
+		t.Errorf("matchers %+v", stats)
+	}
+
+	pruned, _ := New(WithPatternDeletion(true))
+	for _, pattern := range patterns {
+		_ = pruned.AddPattern(pattern, pattern)
+	}
+	if got := pruned.AutomatonStats(); got.States != stats.States {
+		t.Errorf("pruner: %+v, core %+v", got, stats)
+	}
+}
@@ -0,0 +1,106 @@
+// Package bolt provides a store.PatternStore kept in a bbolt database, for rule sets that change often or
+// grow too large for store.FileStore to rewrite on each change. It's a module of its own so that Quamina
+// itself doesn't depend on bbolt.
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+
+	bolt "go.etcd.io/bbolt"
+	"quamina.net/go/quamina/v2/store"
+)
+
+var (
+	// patternsBucket maps each X value to the JSON of its store.Entry
+	patternsBucket = []byte("quamina-patterns")
+	errNoBucket    = errors.New("the database has no Quamina patterns bucket")
+)
+
+// Store is a store.PatternStore kept in a bbolt database. Its version is the bucket's sequence number, so
+// each change is a single transaction.
+type Store struct {
+	db *bolt.DB
+}
+
+// New returns a Store kept in db, which may hold other buckets too. The caller remains responsible for
+// closing db.
+func New(db *bolt.DB) (*Store, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(patternsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Put stores patterns under x, replacing any already there, and returns the store's new version.
+func (s *Store) Put(x string, patterns ...string) (version uint64, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(patternsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+		if version, err = bucket.NextSequence(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(store.Entry{X: x, Patterns: slices.Clone(patterns), Version: version})
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(x), data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Delete removes the Patterns under x, if there are any, and returns the store's version.
+func (s *Store) Delete(x string) (version uint64, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(patternsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+		if bucket.Get([]byte(x)) == nil {
+			version = bucket.Sequence()
+			return nil
+		}
+		if version, err = bucket.NextSequence(); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(x))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// List returns everything stored, ordered by X, and the store's version.
+func (s *Store) List() (entries []store.Entry, version uint64, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(patternsBucket)
+		if bucket == nil {
+			return errNoBucket
+		}
+		version = bucket.Sequence()
+		// bbolt orders keys bytewise, as strings.Compare does
+		return bucket.ForEach(func(_, data []byte) error {
+			var entry store.Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, version, nil
+}
@@ -0,0 +1,39 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+	"quamina.net/go/quamina/v2/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patterns.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storetest.Run(t, s)
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = bolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s, err = New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, version, err := s.List()
+	if err != nil || len(entries) != 21 || version != 24 {
+		t.Errorf("reopened with %d entries at version %d, %v", len(entries), version, err)
+	}
+}
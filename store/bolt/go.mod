@@ -0,0 +1,13 @@
+module quamina.net/go/quamina/v2/store/bolt
+
+go 1.22.0
+
+require (
+	go.etcd.io/bbolt v1.3.10
+	quamina.net/go/quamina/v2 v2.0.0
+)
+
+require golang.org/x/sys v0.20.0 // indirect
+
+// develop against the Quamina in this repository
+replace quamina.net/go/quamina/v2 => ../..
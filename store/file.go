@@ -0,0 +1,118 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FileStore is a PatternStore kept in a JSON file. Each change rewrites the whole file, which suits rule sets
+// of the size people edit and review, up to some thousands of Patterns. The file is replaced atomically, by
+// renaming a new file over it, so it's never left half-written, even by a crash. Only one FileStore, in one
+// process, should use a file at a time.
+type FileStore struct {
+	path    string
+	lock    sync.Mutex
+	version uint64
+	entries []Entry // sorted by X
+}
+
+type fileContents struct {
+	Version uint64  `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// OpenFile returns a FileStore kept in the named file, which is created by the first change if it doesn't
+// exist.
+func OpenFile(path string) (*FileStore, error) {
+	s := &FileStore{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var contents fileContents
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil, &fs.PathError{Op: "parse", Path: path, Err: err}
+	}
+	s.version, s.entries = contents.Version, contents.Entries
+	slices.SortFunc(s.entries, func(a, b Entry) int { return strings.Compare(a.X, b.X) })
+	return s, nil
+}
+
+// Put stores patterns under x, replacing any already there, and returns the store's new version.
+func (s *FileStore) Put(x string, patterns ...string) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry := Entry{X: x, Patterns: slices.Clone(patterns), Version: s.version + 1}
+	entries := slices.Clone(s.entries)
+	i, found := s.find(x)
+	if found {
+		entries[i] = entry
+	} else {
+		entries = slices.Insert(entries, i, entry)
+	}
+	return s.save(entries)
+}
+
+// Delete removes the Patterns under x, if there are any, and returns the store's version.
+func (s *FileStore) Delete(x string) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i, found := s.find(x)
+	if !found {
+		return s.version, nil
+	}
+	return s.save(slices.Delete(slices.Clone(s.entries), i, i+1))
+}
+
+// List returns everything stored, ordered by X, and the store's version.
+func (s *FileStore) List() ([]Entry, uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entries := make([]Entry, len(s.entries))
+	for i, entry := range s.entries {
+		entries[i] = entry
+		entries[i].Patterns = slices.Clone(entry.Patterns)
+	}
+	return entries, s.version, nil
+}
+
+func (s *FileStore) find(x string) (int, bool) {
+	return slices.BinarySearchFunc(s.entries, x, func(e Entry, x string) int { return strings.Compare(e.X, x) })
+}
+
+// save writes entries at the next version, and adopts them if that succeeds
+func (s *FileStore) save(entries []Entry) (uint64, error) {
+	data, err := json.MarshalIndent(fileContents{Version: s.version + 1, Entries: entries}, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(temp.Name()) // fails harmlessly once it's been renamed
+	_, err = temp.Write(append(data, '\n'))
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), s.path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.version, s.entries = s.version+1, entries
+	return s.version, nil
+}
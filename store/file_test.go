@@ -0,0 +1,61 @@
+package store_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"quamina.net/go/quamina/v2/store"
+	"quamina.net/go/quamina/v2/store/storetest"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patterns.json")
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storetest.Run(t, s)
+
+	// a second FileStore on the file sees what the first stored
+	reopened, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, version, err := reopened.List()
+	if err != nil || len(entries) != 21 || version != 24 {
+		t.Errorf("reopened with %d entries at version %d, %v", len(entries), version, err)
+	}
+	if v, err := reopened.Put("c", "{}"); err != nil || v != 25 {
+		t.Errorf("Put after reopening gave %d, %v", v, err)
+	}
+
+	// the temporary files are cleaned up
+	files, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(files) != 1 {
+		t.Errorf("directory has %d files, %v", len(files), err)
+	}
+}
+
+func TestFileStoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenFile(bad); err == nil {
+		t.Error("opened a file that isn't JSON")
+	}
+
+	// a change that can't be saved isn't made
+	s, err := store.OpenFile(filepath.Join(dir, "missing", "patterns.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put("a", "{}"); err == nil {
+		t.Error("Put into a missing directory succeeded")
+	}
+	if entries, version, _ := s.List(); len(entries) != 0 || version != 0 {
+		t.Errorf("failed Put left %v at version %d", entries, version)
+	}
+}
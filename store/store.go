@@ -0,0 +1,76 @@
+// Package store keeps a Quamina instance's Patterns durably, so that a process can add them again when it
+// restarts, rather than each embedder devising its own storage. A PatternStore holds the Patterns under
+// their X values, and Load adds all of them to an instance:
+//
+//	s, err := store.OpenFile("patterns.json")
+//	...
+//	version, err := store.Load(s, q)
+//
+// Changes go to both, the PatternStore so that they're kept and the instance so that they take effect:
+//
+//	if _, err := s.Put("order-filter", pattern); err == nil {
+//		err = q.AddPattern("order-filter", pattern)
+//	}
+//
+// Every change advances the store's version, so a process that shares a store with others can tell, by
+// comparing the version List returns with the one it loaded, whether it needs to load again, or it can
+// leave that to a Watcher, from WatchPatternFile, which reloads a file whenever it changes. FileStore is
+// included; the module quamina.net/go/quamina/v2/store/bolt adds a PatternStore kept in a bbolt database,
+// in a module of its own so that Quamina itself has no dependencies.
+package store
+
+import (
+	"quamina.net/go/quamina/v2"
+)
+
+// Entry is the Patterns stored under an X value.
+type Entry struct {
+	X string `json:"x"`
+	// Patterns are all added with X; there may be more than one, as with those from pattern.CompileRule.
+	Patterns []string `json:"patterns"`
+	// Version is the store's version after the Put that stored the Patterns.
+	Version uint64 `json:"version"`
+}
+
+// PatternStore is durable storage for Patterns. Its methods may be called from several goroutines at once.
+type PatternStore interface {
+	// Put stores patterns under x, replacing any already there, and returns the store's new version.
+	Put(x string, patterns ...string) (uint64, error)
+	// Delete removes the Patterns under x, if there are any, and returns the store's version, which is new
+	// if there were.
+	Delete(x string) (uint64, error)
+	// List returns everything stored, ordered by X, and the store's version.
+	List() ([]Entry, uint64, error)
+}
+
+// Load adds every Pattern in s to q and returns the version of s that was loaded. If a Pattern can't be
+// added, Load stops, and the error says which it was; the Patterns before it stay added.
+func Load(s PatternStore, q *quamina.Quamina) (uint64, error) {
+	entries, version, err := s.List()
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		for _, pattern := range entry.Patterns {
+			if err := q.AddPattern(entry.X, pattern); err != nil {
+				return 0, &LoadError{X: entry.X, Pattern: pattern, Err: err}
+			}
+		}
+	}
+	return version, nil
+}
+
+// LoadError reports a stored Pattern that Load couldn't add.
+type LoadError struct {
+	X       string
+	Pattern string
+	Err     error
+}
+
+func (e *LoadError) Error() string {
+	return "loading the Pattern for " + e.X + ": " + e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,45 @@
+package store_test
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/store"
+)
+
+func TestLoad(t *testing.T) {
+	s, err := store.OpenFile(filepath.Join(t.TempDir(), "patterns.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = s.Put("ab", `{"a": ["x"]}`, `{"b": ["y"]}`)
+	_, _ = s.Put("c", `{"c": ["z"]}`)
+
+	q, _ := quamina.New()
+	version, err := store.Load(s, q)
+	if err != nil || version != 2 {
+		t.Fatalf("loaded version %d, %v", version, err)
+	}
+	for event, want := range map[string][]quamina.X{
+		`{"a": "x"}`: {"ab"},
+		`{"b": "y"}`: {"ab"},
+		`{"c": "z"}`: {"c"},
+		`{"a": "z"}`: {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !slices.Equal(matches, want) {
+			t.Errorf("%s matched %v, %v", event, matches, err)
+		}
+	}
+
+	_, _ = s.Put("bad", `{"a": [`)
+	q, _ = quamina.New()
+	_, err = store.Load(s, q)
+	var loadErr *store.LoadError
+	if !errors.As(err, &loadErr) || loadErr.X != "bad" || errors.Unwrap(err) == nil {
+		t.Errorf("loading a bad Pattern gave %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Package storetest checks that implementations of store.PatternStore behave as the interface requires.
+package storetest
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"quamina.net/go/quamina/v2/store"
+)
+
+// Run checks s, which must be empty.
+func Run(t *testing.T, s store.PatternStore) {
+	t.Helper()
+	list := func(wantVersion uint64, want ...store.Entry) {
+		t.Helper()
+		entries, version, err := s.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != wantVersion {
+			t.Errorf("version %d, wanted %d", version, wantVersion)
+		}
+		if !slices.EqualFunc(entries, want, equal) {
+			t.Errorf("listed %v, wanted %v", entries, want)
+		}
+	}
+	step := func(version uint64, err error, wantVersion uint64) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != wantVersion {
+			t.Errorf("version %d, wanted %d", version, wantVersion)
+		}
+	}
+
+	list(0)
+	v, err := s.Put("b", `{"b": [1]}`)
+	step(v, err, 1)
+	v, err = s.Put("a", `{"a": [1]}`, `{"a": [2]}`)
+	step(v, err, 2)
+	list(2, store.Entry{X: "a", Patterns: []string{`{"a": [1]}`, `{"a": [2]}`}, Version: 2},
+		store.Entry{X: "b", Patterns: []string{`{"b": [1]}`}, Version: 1})
+
+	v, err = s.Put("b", `{"b": [2]}`)
+	step(v, err, 3)
+	v, err = s.Delete("a")
+	step(v, err, 4)
+	v, err = s.Delete("not there")
+	step(v, err, 4)
+	list(4, store.Entry{X: "b", Patterns: []string{`{"b": [2]}`}, Version: 3})
+
+	// what List returns is the caller's
+	entries, _, _ := s.List()
+	entries[0].Patterns[0] = "changed"
+	list(4, store.Entry{X: "b", Patterns: []string{`{"b": [2]}`}, Version: 3})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				if _, err := s.Put(fmt.Sprintf("g%d-%d", g, i), "{}"); err != nil {
+					t.Error(err)
+				}
+				if _, _, err := s.List(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	entries, version, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 21 || version != 24 {
+		t.Errorf("after concurrent Puts, %d entries at version %d", len(entries), version)
+	}
+}
+
+func equal(a, b store.Entry) bool {
+	return a.X == b.X && a.Version == b.Version && slices.Equal(a.Patterns, b.Patterns)
+}
@@ -0,0 +1,245 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+)
+
+// DefaultWatchInterval is how often a Watcher checks its file unless told otherwise.
+const DefaultWatchInterval = time.Second
+
+// WatchConfig adjusts a Watcher; its zero value is usable.
+type WatchConfig struct {
+	// Options are used to make each instance the Watcher loads.
+	Options []quamina.Option
+	// Interval is how often the file is checked for changes; zero means DefaultWatchInterval.
+	Interval time.Duration
+	// OnReload, if not nil, is called after the Watcher has tried to reload a changed file, with nil if
+	// it succeeded, or else the error, often a *ReloadError.
+	OnReload func(err error)
+}
+
+// PatternError reports a Pattern in a watched file that couldn't be added.
+type PatternError struct {
+	// Line is the line of an NDJSON file the Pattern is on, or zero.
+	Line int
+	X    string
+	Err  error
+}
+
+func (e *PatternError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %v", e.Line, e.X, e.Err)
+	}
+	return e.X + ": " + e.Err.Error()
+}
+
+func (e *PatternError) Unwrap() error {
+	return e.Err
+}
+
+// ReloadError lists the problems that stopped a watched file from being loaded.
+type ReloadError struct {
+	Path   string
+	Errors []*PatternError
+}
+
+func (e *ReloadError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return e.Path + ": " + strings.Join(messages, "; ")
+}
+
+// Watcher matches events against the Patterns in a file, which it reloads when the file changes. A reload
+// happens in the background: the Patterns are added to a new instance, which replaces the old one
+// atomically once they're all in, so that matching never sees a partial set. If any of them can't be added,
+// the Watcher keeps the Patterns it had, and reports every one that failed. Unlike a Quamina instance, a
+// Watcher may be used by many goroutines at once.
+type Watcher struct {
+	path    string
+	config  WatchConfig
+	current atomic.Pointer[generation]
+	copies  sync.Pool
+	// reloadLock serializes reloads, which may come from the polling goroutine and from Reload
+	reloadLock sync.Mutex
+	modTime    time.Time
+	size       int64
+	stop       chan struct{}
+	stopped    sync.WaitGroup
+}
+
+// generation is one loading of the file; its instance is only used through copies
+type generation struct {
+	q *quamina.Quamina
+}
+
+type generationCopy struct {
+	gen *generation
+	q   *quamina.Quamina
+}
+
+// WatchPatternFile loads the Patterns in the named file and returns a Watcher that reloads them whenever
+// the file changes, until it's closed. The file may be in FileStore's format, so that changes made through a
+// FileStore reach the processes watching it, or be newline-delimited JSON, with a Pattern and its X value on
+// each line:
+//
+//	{"x": "big-orders", "pattern": {"amount": [{"numeric": [">", 1000]}]}}
+//
+// X values are strings. If the file can't be loaded at first, WatchPatternFile returns the error.
+func WatchPatternFile(path string, config WatchConfig) (*Watcher, error) {
+	if config.Interval == 0 {
+		config.Interval = DefaultWatchInterval
+	}
+	w := &Watcher{path: path, config: config, stop: make(chan struct{})}
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	w.stopped.Add(1)
+	go w.poll()
+	return w, nil
+}
+
+// MatchesForEvent returns the X values of the Patterns that match event, as Quamina's MatchesForEvent does,
+// using the Patterns most recently loaded. The slice returned is the caller's to keep.
+func (w *Watcher) MatchesForEvent(event []byte) ([]quamina.X, error) {
+	gen := w.current.Load()
+	c, _ := w.copies.Get().(*generationCopy)
+	if c == nil || c.gen != gen {
+		c = &generationCopy{gen: gen, q: gen.q.Copy()}
+	}
+	defer w.copies.Put(c)
+	matches, err := c.q.MatchesForEvent(event)
+	return slices.Clone(matches), err
+}
+
+// Reload loads the file now, whether or not it has changed, and returns what would be passed to OnReload.
+func (w *Watcher) Reload() error {
+	w.reloadLock.Lock()
+	defer w.reloadLock.Unlock()
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	// remember the file as it was read, so that a failed load isn't retried until the file changes again
+	w.modTime, w.size = info.ModTime(), info.Size()
+	q, err := quamina.New(w.config.Options...)
+	if err != nil {
+		return err
+	}
+	if errs := addPatternFile(q, data); len(errs) > 0 {
+		return &ReloadError{Path: w.path, Errors: errs}
+	}
+	w.current.Store(&generation{q: q})
+	return nil
+}
+
+// Close stops watching the file. The Watcher goes on matching with the Patterns it has.
+func (w *Watcher) Close() error {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	w.stopped.Wait()
+	return nil
+}
+
+func (w *Watcher) poll() {
+	defer w.stopped.Done()
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+		if !w.changed() {
+			continue
+		}
+		err := w.Reload()
+		if w.config.OnReload != nil {
+			w.config.OnReload(err)
+		}
+	}
+}
+
+// changed reports whether the file looks different from when it was last read; a file that can't be
+// examined, perhaps because it's being replaced, is left until it can be
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	w.reloadLock.Lock()
+	defer w.reloadLock.Unlock()
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+// addPatternFile adds the Patterns in data, in either of the formats WatchPatternFile reads, to q, and
+// returns the problems with those it couldn't add
+func addPatternFile(q *quamina.Quamina, data []byte) (errs []*PatternError) {
+	var contents fileContents
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if decoder.Decode(&contents) == nil && isEOF(decoder) {
+		for _, entry := range contents.Entries {
+			for _, pattern := range entry.Patterns {
+				if err := q.AddPattern(entry.X, pattern); err != nil {
+					errs = append(errs, &PatternError{X: entry.X, Err: err})
+				}
+			}
+		}
+		return errs
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var entry struct {
+			X       string          `json:"x"`
+			Pattern json.RawMessage `json:"pattern"`
+		}
+		if err := json.Unmarshal(text, &entry); err != nil {
+			errs = append(errs, &PatternError{Line: line, Err: err})
+			continue
+		}
+		if len(entry.Pattern) == 0 {
+			errs = append(errs, &PatternError{Line: line, X: entry.X, Err: errors.New("no pattern")})
+			continue
+		}
+		if err := q.AddPattern(entry.X, string(entry.Pattern)); err != nil {
+			errs = append(errs, &PatternError{Line: line, X: entry.X, Err: err})
+		}
+	}
+	return errs
+}
+
+func isEOF(decoder *json.Decoder) bool {
+	_, err := decoder.Token()
+	return err == io.EOF
+}
@@ -0,0 +1,184 @@
+package store_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/store"
+)
+
+func matchesOf(t *testing.T, w *store.Watcher, event string) []quamina.X {
+	t.Helper()
+	matches, err := w.MatchesForEvent([]byte(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(matches, func(a, b quamina.X) int { return strings.Compare(a.(string), b.(string)) })
+	return matches
+}
+
+func TestWatchNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patterns.ndjson")
+	write := func(contents string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"x": "a", "pattern": {"a": ["x"]}}
+
+{"x": "b", "pattern": {"b": ["y"]}}
+{"x": "big-orders", "pattern": {"amount": [{"numeric": [">", 1000]}]}}
+`)
+	w, err := store.WatchPatternFile(path, store.WatchConfig{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if got := matchesOf(t, w, `{"a": "x", "b": "y", "amount": 500}`); !slices.Equal(got, []quamina.X{"a", "b"}) {
+		t.Errorf("matched %v", got)
+	}
+	if got := matchesOf(t, w, `{"amount": 1500}`); !slices.Equal(got, []quamina.X{"big-orders"}) {
+		t.Errorf("matched %v", got)
+	}
+
+	// a file with bad Patterns is reported, line by line, and the previous Patterns are kept
+	write(`{"x": "a", "pattern": {"a": ["z"]}}
+{"x": "bad", "pattern": {"a": [}
+not JSON
+{"x": "none"}
+`)
+	err = w.Reload()
+	var reloadErr *store.ReloadError
+	if !errors.As(err, &reloadErr) || len(reloadErr.Errors) != 3 {
+		t.Fatalf("reloading gave %v", err)
+	}
+	for i, line := range []int{2, 3, 4} {
+		if reloadErr.Errors[i].Line != line {
+			t.Errorf("error %d is on line %d, not %d: %v", i, reloadErr.Errors[i].Line, line, reloadErr.Errors[i])
+		}
+	}
+	if got := matchesOf(t, w, `{"a": "x"}`); !slices.Equal(got, []quamina.X{"a"}) {
+		t.Errorf("after a failed reload, matched %v", got)
+	}
+
+	write(`{"x": "a", "pattern": {"a": ["z"]}}`)
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := matchesOf(t, w, `{"a": "x", "b": "y"}`); len(got) != 0 {
+		t.Errorf("after reloading, matched %v", got)
+	}
+	if got := matchesOf(t, w, `{"a": "z"}`); !slices.Equal(got, []quamina.X{"a"}) {
+		t.Errorf("after reloading, matched %v", got)
+	}
+}
+
+func TestWatchFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patterns.json")
+	s, err := store.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put("a", `{"a": ["x"]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	reloads := make(chan error, 10)
+	w, err := store.WatchPatternFile(path, store.WatchConfig{
+		Interval: 10 * time.Millisecond,
+		OnReload: func(err error) { reloads <- err },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if got := matchesOf(t, w, `{"a": "x"}`); !slices.Equal(got, []quamina.X{"a"}) {
+		t.Errorf("matched %v", got)
+	}
+
+	// a change through the FileStore is noticed and loaded
+	if _, err := s.Put("b", `{"a": [{"prefix": "x"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-reloads:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the change wasn't noticed")
+	}
+	if got := matchesOf(t, w, `{"a": "x"}`); !slices.Equal(got, []quamina.X{"a", "b"}) {
+		t.Errorf("after the change, matched %v", got)
+	}
+
+	if _, err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := matchesOf(t, w, `{"a": "x"}`); len(got) != 0 {
+		t.Errorf("after deleting everything, matched %v", got)
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := store.WatchPatternFile(filepath.Join(dir, "missing"), store.WatchConfig{}); err == nil {
+		t.Error("watched a missing file")
+	}
+	bad := filepath.Join(dir, "bad.ndjson")
+	if err := os.WriteFile(bad, []byte(`{"x": "a", "pattern": 3}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := store.WatchPatternFile(bad, store.WatchConfig{})
+	var reloadErr *store.ReloadError
+	if !errors.As(err, &reloadErr) || reloadErr.Errors[0].X != "a" {
+		t.Errorf("watching a bad file gave %v", err)
+	}
+}
+
+// TestWatchConcurrency matches from many goroutines while reloading; run it with -race
+func TestWatchConcurrency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "patterns.ndjson")
+	if err := os.WriteFile(path, []byte(`{"x": "a", "pattern": {"a": ["x"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := store.WatchPatternFile(path, store.WatchConfig{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				matches, err := w.MatchesForEvent([]byte(`{"a": "x"}`))
+				if err != nil || len(matches) != 1 {
+					t.Errorf("matched %v, %v", matches, err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if err := w.Reload(); err != nil {
+			t.Error(err)
+		}
+	}
+	wg.Wait()
+}
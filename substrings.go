@@ -0,0 +1,331 @@
+package quamina
+
+import (
+	"bytes"
+	"slices"
+)
+
+// substringMatcher handles, for one valueMatcher, the shellstyle and wildcard patterns that are
+// sequences of literal pieces separated by "*", such as "*literal*", "*.jpg" or "a*b*c". These are
+// common (think log-message matching) and expensive when merged into the automaton: each "*" brings
+// a spinner state, and lots of them make for a big, heavily-spliced NFA whose traversal cost grows
+// with their number. Since all they need is some anchored comparisons and substring searches,
+// they're kept out of the automaton entirely. The plain prefix form "literal*", and patterns without
+// any "*", aren't included; plainShellValue turns them into prefix and string matches.
+// "contains" patterns, which are "*literal*" by another name, "suffix-equals-ignore-case" patterns,
+// anchored suffixes compared regardless of case, and "contains-ignore-case" patterns, literals looked for
+// anywhere with ASCII letters compared regardless of case, are kept here too.
+//
+// All the patterns on a field share the work of matching. The "floating" pieces, those not anchored
+// to either end of the value, are deduplicated across patterns; with only a few of them, each pattern
+// is checked separately with bytes.Index or, for long pieces, horspool, but with more, they are
+// compiled into a single Aho-Corasick automaton so that one pass over the value finds which pieces
+// occur anywhere, and patterns needing a piece that doesn't are dismissed without further work.
+// Patterns are kept ordered so that those with the same anchored prefix are adjacent, and the prefix
+// is compared once per group.
+//
+// A substringMatcher is never updated once built; addTransition makes a new one and swaps it into
+// the valueMatcher's vmFields, so that concurrent matching is unaffected.
+type substringMatcher struct {
+	patterns  []substringPattern // those sharing a prefix are adjacent
+	prefixes  [][]byte           // distinct anchored prefixes, indexed by substringPattern.prefixID
+	literals  [][]byte           // distinct floating pieces
+	searchers []*horspool        // searchers[i] is non-nil if literals[i] is long enough to use horspool
+	ac        *ahoCorasick       // nil if there are fewer than acMinLiterals literals
+}
+
+// substringPattern is one pattern broken into pieces, and the fieldMatcher to transition to when
+// it matches. The value must start with prefix, end with suffix, and contain the middles in order
+// and without overlap, in the part between them.
+type substringPattern struct {
+	prefix   []byte // empty if the pattern starts with "*"
+	prefixID int32  // index into substringMatcher.prefixes, or -1 if prefix is empty
+	// foldSuffix is set for "suffix-equals-ignore-case" patterns, which have nothing but a suffix, compared
+	// regardless of case
+	foldSuffix bool
+	// foldContains is set for "contains-ignore-case" patterns, which have nothing but folded, their literal
+	// with its ASCII letters in lower case, to look for anywhere in the value with indexFoldedASCII
+	foldContains bool
+	folded       []byte
+	suffix       []byte // empty if the pattern ends with "*"
+	middles      []int32
+	next         *fieldMatcher
+}
+
+// acMinLiterals is the number of literals at which Aho-Corasick starts to beat repeated bytes.Index
+// calls, which are heavily optimized and thus hard to beat for small numbers of literals.
+const acMinLiterals = 4
+
+// shellPieces checks whether a shellstyle or wildcard pattern value, including its enclosing quotes,
+// qualifies for the substringMatcher and if so returns its pieces: the literal before the first "*",
+// the non-empty literals between stars, and the literal after the last "*".
+func shellPieces(vType valType, val []byte) (prefix []byte, middles [][]byte, suffix []byte, ok bool) {
+	if len(val) < 3 {
+		return nil, nil, nil, false
+	}
+	pieces := splitShellValue(vType, val)
+
+	// no stars means it's a plain string match, and a single trailing star a plain prefix match
+	if len(pieces) == 1 || (len(pieces) == 2 && len(pieces[1]) == 0) {
+		return nil, nil, nil, false
+	}
+	prefix, suffix = pieces[0], pieces[len(pieces)-1]
+	for _, middle := range pieces[1 : len(pieces)-1] {
+		if len(middle) > 0 {
+			middles = append(middles, middle)
+		}
+	}
+	// there has to be something to look for
+	if len(prefix) == 0 && len(suffix) == 0 && len(middles) == 0 {
+		return nil, nil, nil, false
+	}
+	return prefix, middles, suffix, true
+}
+
+// plainShellValue returns, for a shellstyle or wildcard pattern value that shellPieces turns down because it
+// has no "*" or only a trailing one, the string or prefix pattern which matches the same values, so that the
+// automaton needn't make a spinner for it. A lone "*", which matches every string, is rare enough to be left to
+// the automaton.
+func plainShellValue(vType valType, val []byte) (typedVal, bool) {
+	if len(val) < 2 {
+		return typedVal{}, false
+	}
+	pieces := splitShellValue(vType, val)
+	switch {
+	case len(pieces) == 1:
+		return typedVal{vType: stringType, val: `"` + string(pieces[0]) + `"`}, true
+	case len(pieces) == 2 && len(pieces[0]) > 0 && len(pieces[1]) == 0:
+		return typedVal{vType: prefixType, val: `"` + string(pieces[0]) + `"`}, true
+	}
+	return typedVal{}, false
+}
+
+// splitShellValue returns the literal pieces around the "*"s of a shellstyle or wildcard pattern value,
+// including its enclosing quotes. This is the only place their escaping differs: for wildcard patterns, "\*"
+// and "\\" are unescaped; readWildcardSpecial has already rejected any other use of "\".
+func splitShellValue(vType valType, val []byte) [][]byte {
+	inner := val[1 : len(val)-1]
+	if vType != wildcardType || bytes.IndexByte(inner, '\\') < 0 {
+		// no unescaping to do, so no need to allocate
+		return bytes.Split(inner, []byte{'*'})
+	}
+	var pieces [][]byte
+	var piece []byte
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '\\':
+			i++
+			piece = append(piece, inner[i])
+		case '*':
+			pieces = append(pieces, piece)
+			piece = nil
+		default:
+			piece = append(piece, inner[i])
+		}
+	}
+	return append(pieces, piece)
+}
+
+// with returns a substringMatcher which adds the pattern made of the provided pieces to those in sm,
+// along with the fieldMatcher to transition to when it matches. As with singleton string matches,
+// a pattern that is already present gets its existing transition. sm may be nil.
+func (sm *substringMatcher) with(prefix []byte, middles [][]byte, suffix []byte) (*substringMatcher, *fieldMatcher) {
+	return sm.withPattern(substringPattern{prefix: prefix, prefixID: -1, suffix: suffix}, middles)
+}
+
+// withFoldedSuffix is like with, for a "suffix-equals-ignore-case" pattern. Its suffix is compared with
+// hasFoldedSuffix.
+func (sm *substringMatcher) withFoldedSuffix(suffix []byte) (*substringMatcher, *fieldMatcher) {
+	return sm.withPattern(substringPattern{prefixID: -1, suffix: suffix, foldSuffix: true}, nil)
+}
+
+// withFoldedContains is like with, for a "contains-ignore-case" pattern.
+func (sm *substringMatcher) withFoldedContains(literal []byte) (*substringMatcher, *fieldMatcher) {
+	return sm.withPattern(substringPattern{prefixID: -1, foldContains: true, folded: lowerASCII(literal)}, nil)
+}
+
+func (sm *substringMatcher) withPattern(pattern substringPattern, middles [][]byte) (*substringMatcher, *fieldMatcher) {
+	prefix, suffix := pattern.prefix, pattern.suffix
+	fresh := &substringMatcher{}
+	if sm != nil {
+		fresh.patterns = append(fresh.patterns, sm.patterns...)
+		fresh.prefixes = append(fresh.prefixes, sm.prefixes...)
+		fresh.literals = append(fresh.literals, sm.literals...)
+		fresh.searchers = append(fresh.searchers, sm.searchers...)
+	}
+
+	for _, middle := range middles {
+		pattern.middles = append(pattern.middles, fresh.literalID(middle))
+	}
+	if len(prefix) > 0 {
+		for i, existing := range fresh.prefixes {
+			if bytes.Equal(existing, prefix) {
+				//nolint:gosec // prefix counts are bounded far below MaxInt32 by memory
+				pattern.prefixID = int32(i)
+				break
+			}
+		}
+		if pattern.prefixID < 0 {
+			//nolint:gosec // prefix counts are bounded far below MaxInt32 by memory
+			pattern.prefixID = int32(len(fresh.prefixes))
+			fresh.prefixes = append(fresh.prefixes, prefix)
+		}
+	}
+
+	// the new pattern goes after the last one with the same prefix, or at the end
+	at := len(fresh.patterns)
+	for i := range fresh.patterns {
+		existing := &fresh.patterns[i]
+		if existing.prefixID != pattern.prefixID {
+			continue
+		}
+		if existing.foldSuffix == pattern.foldSuffix && existing.foldContains == pattern.foldContains &&
+			bytes.Equal(existing.folded, pattern.folded) && bytes.Equal(existing.suffix, suffix) &&
+			slices.Equal(existing.middles, pattern.middles) {
+			return sm, existing.next
+		}
+		at = i + 1
+	}
+	pattern.next = newFieldMatcher()
+	fresh.patterns = slices.Insert(fresh.patterns, at, pattern)
+
+	if sm == nil || len(fresh.literals) != len(sm.literals) {
+		if len(fresh.literals) >= acMinLiterals {
+			fresh.ac = newAhoCorasick(fresh.literals)
+		}
+	} else {
+		fresh.ac = sm.ac
+	}
+	return fresh, pattern.next
+}
+
+// literalID returns the index of literal among the floating pieces, adding it if necessary. Only
+// called on a substringMatcher that's still being built.
+func (sm *substringMatcher) literalID(literal []byte) int32 {
+	for i, existing := range sm.literals {
+		if bytes.Equal(existing, literal) {
+			//nolint:gosec // literal counts are bounded far below MaxInt32 by memory
+			return int32(i)
+		}
+	}
+	var searcher *horspool
+	if len(literal) >= horspoolMinLength {
+		searcher = newHorspool(literal)
+	}
+	sm.literals = append(sm.literals, literal)
+	sm.searchers = append(sm.searchers, searcher)
+	//nolint:gosec // literal counts are bounded far below MaxInt32 by memory
+	return int32(len(sm.literals) - 1)
+}
+
+// transitionOn appends to transitions the fieldMatchers for each pattern that matches the value. Only
+// string values, which arrive with their enclosing quotes, can match.
+func (sm *substringMatcher) transitionOn(val []byte, transitions []*fieldMatcher, bufs *nfaBuffers) []*fieldMatcher {
+	if len(val) < 2 || val[0] != '"' || val[len(val)-1] != '"' {
+		return transitions
+	}
+	inner := val[1 : len(val)-1]
+
+	// one pass to find which of the floating pieces occur
+	var seen []uint32
+	var gen uint32
+	if sm.ac != nil {
+		seen, gen = bufs.getLiteralMarks(len(sm.literals))
+		remaining := len(sm.literals)
+		sm.ac.scan(inner, func(literal int32) bool {
+			if seen[literal] != gen {
+				seen[literal] = gen
+				remaining--
+			}
+			return remaining > 0
+		})
+	}
+
+	lastPrefixID := int32(-1)
+	prefixMatched := false
+	for i := range sm.patterns {
+		pattern := &sm.patterns[i]
+		if pattern.prefixID >= 0 {
+			if pattern.prefixID != lastPrefixID {
+				lastPrefixID = pattern.prefixID
+				prefixMatched = bytes.HasPrefix(inner, pattern.prefix)
+			}
+			if !prefixMatched {
+				continue
+			}
+		}
+		if seen != nil && !allSeen(pattern.middles, seen, gen) {
+			continue
+		}
+		if sm.matches(pattern, inner, seen != nil) {
+			transitions = append(transitions, pattern.next)
+		}
+	}
+	return transitions
+}
+
+func allSeen(middles []int32, seen []uint32, gen uint32) bool {
+	for _, middle := range middles {
+		if seen[middle] != gen {
+			return false
+		}
+	}
+	return true
+}
+
+// matches checks everything about the pattern other than its prefix, which transitionOn has
+// already checked. middlesSeen says that each of the middles is known to occur somewhere in val.
+func (sm *substringMatcher) matches(pattern *substringPattern, val []byte, middlesSeen bool) bool {
+	if pattern.foldSuffix {
+		return hasFoldedSuffix(val, pattern.suffix)
+	}
+	if pattern.foldContains {
+		return indexFoldedASCII(val, pattern.folded) >= 0
+	}
+	start, end := len(pattern.prefix), len(val)-len(pattern.suffix)
+	if end < start || !bytes.HasSuffix(val, pattern.suffix) {
+		return false
+	}
+	// "*literal*" needs nothing more than to know that the literal is there
+	if middlesSeen && len(pattern.middles) == 1 && start == 0 && end == len(val) {
+		return true
+	}
+	// the leftmost occurrence of each middle leaves the most room for those following
+	region := val[start:end]
+	for _, middle := range pattern.middles {
+		var at int
+		if sm.searchers[middle] != nil {
+			at = sm.searchers[middle].index(region)
+		} else {
+			at = bytes.Index(region, sm.literals[middle])
+		}
+		if at < 0 {
+			return false
+		}
+		region = region[at+len(sm.literals[middle]):]
+	}
+	return true
+}
+
+// size estimates the memory consumed by the substringMatcher, not including the fieldMatchers
+func (sm *substringMatcher) size() int64 {
+	cost := mcSubstringPattern*int64(cap(sm.patterns)) + mcPointer*int64(cap(sm.searchers))
+	for i := range sm.patterns {
+		cost += 4*int64(cap(sm.patterns[i].middles)) + int64(cap(sm.patterns[i].folded))
+	}
+	for _, prefix := range sm.prefixes {
+		cost += int64(cap(prefix))
+	}
+	for _, searcher := range sm.searchers {
+		if searcher != nil {
+			cost += mcHorspool
+		}
+	}
+	for _, literal := range sm.literals {
+		cost += int64(cap(literal))
+	}
+	if sm.ac != nil {
+		cost += sm.ac.size()
+	}
+	return cost
+}
@@ -0,0 +1,560 @@
+package quamina
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestShellPieces(t *testing.T) {
+	type pieces struct {
+		prefix  string
+		middles []string
+		suffix  string
+	}
+	yes := map[string]pieces{
+		`"*a*"`:       {"", []string{"a"}, ""},
+		`"*foo bar*"`: {"", []string{"foo bar"}, ""},
+		`"*\*"`:       {"", []string{`\`}, ""},
+		`"*a"`:        {"", nil, "a"},
+		`"a*b"`:       {"a", nil, "b"},
+		`"*a*b*"`:     {"", []string{"a", "b"}, ""},
+		`"x*a*b*y"`:   {"x", []string{"a", "b"}, "y"},
+	}
+	no := []string{`"*"`, `"a*"`, `"foo"`, `""`}
+	check := func(vType valType, pattern string, want pieces) {
+		t.Helper()
+		prefix, middles, suffix, ok := shellPieces(vType, []byte(pattern))
+		if !ok || string(prefix) != want.prefix || string(suffix) != want.suffix || len(middles) != len(want.middles) {
+			t.Errorf("%s: got %s/%s/%s/%v", pattern, prefix, middles, suffix, ok)
+			return
+		}
+		for i, middle := range middles {
+			if string(middle) != want.middles[i] {
+				t.Errorf("%s: middle %d is %s", pattern, i, middle)
+			}
+		}
+	}
+	for pattern, want := range yes {
+		check(shellStyleType, pattern, want)
+	}
+	for _, pattern := range no {
+		if _, _, _, ok := shellPieces(shellStyleType, []byte(pattern)); ok {
+			t.Errorf("%s accepted", pattern)
+		}
+	}
+
+	wcYes := map[string]pieces{
+		`"*a*"`:     {"", []string{"a"}, ""},
+		`"*a\*b*"`:  {"", []string{"a*b"}, ""},
+		`"*\\*"`:    {"", []string{`\`}, ""},
+		`"*\**"`:    {"", []string{"*"}, ""},
+		`"*a\\\**"`: {"", []string{`a\*`}, ""},
+		`"*a\*"`:    {"", nil, "a*"},
+		`"*a\\*b*"`: {"", []string{`a\`, "b"}, ""},
+		`"a\**b"`:   {"a*", nil, "b"},
+	}
+	wcNo := []string{`"*"`, `"a\*"`, `"a\\*"`, `"a\**"`}
+	for pattern, want := range wcYes {
+		check(wildcardType, pattern, want)
+	}
+	for _, pattern := range wcNo {
+		if _, _, _, ok := shellPieces(wildcardType, []byte(pattern)); ok {
+			t.Errorf("wildcard %s accepted", pattern)
+		}
+	}
+}
+
+func TestPlainShellValue(t *testing.T) {
+	type plain struct {
+		vType valType
+		val   string
+	}
+	for _, test := range []struct {
+		vType   valType
+		pattern string
+		want    plain
+	}{
+		{shellStyleType, `"foo"`, plain{stringType, `"foo"`}},
+		{shellStyleType, `""`, plain{stringType, `""`}},
+		{shellStyleType, `"foo*"`, plain{prefixType, `"foo"`}},
+		{shellStyleType, `"a\b"`, plain{stringType, `"a\b"`}},
+		{wildcardType, `"foo*"`, plain{prefixType, `"foo"`}},
+		{wildcardType, `"a\*"`, plain{stringType, `"a*"`}},
+		{wildcardType, `"a\\*"`, plain{prefixType, `"a\"`}},
+		{wildcardType, `"a\**"`, plain{prefixType, `"a*"`}},
+	} {
+		got, ok := plainShellValue(test.vType, []byte(test.pattern))
+		if !ok || got.vType != test.want.vType || got.val != test.want.val {
+			t.Errorf("%s: got %v %s, %v", test.pattern, got.vType, got.val, ok)
+		}
+	}
+	for _, pattern := range []string{`"*"`, `"*a"`, `"a*b"`, `"*a*"`} {
+		if _, ok := plainShellValue(wildcardType, []byte(pattern)); ok {
+			t.Errorf("%s accepted", pattern)
+		}
+	}
+}
+
+// TestShellAndWildcardAlike checks that shellstyle and wildcard patterns are matched the same way, and that those
+// other than a lone "*" don't need an automaton
+func TestShellAndWildcardAlike(t *testing.T) {
+	for _, val := range []typedVal{
+		{vType: shellStyleType, val: `"foo"`},
+		{vType: shellStyleType, val: `"foo*"`},
+		{vType: shellStyleType, val: `"*foo"`},
+		{vType: shellStyleType, val: `"f*o*o"`},
+		{vType: shellStyleType, val: `""`},
+		{vType: wildcardType, val: `"foo"`},
+		{vType: wildcardType, val: `"foo*"`},
+		{vType: wildcardType, val: `"*foo"`},
+		{vType: wildcardType, val: `"f*o*o"`},
+		{vType: wildcardType, val: `"a\*b"`},
+		{vType: wildcardType, val: `"a\\*"`},
+	} {
+		vm := newValueMatcher()
+		_ = vm.addTransition(val, sharedNullPrinter, newClosureBuffers(), BuiltForComfort)
+		if fields := vm.fields(); fields.start != nil || fields.isNondeterministic {
+			t.Errorf("%v %s built an automaton", val.vType, val.val)
+		}
+	}
+
+	q, _ := New()
+	patterns := map[X]string{
+		"shell-prefix":    `{"x": [{"shellstyle": "ab*"}]}`,
+		"wildcard-prefix": `{"x": [{"wildcard": "ab*"}]}`,
+		"escaped-star":    `{"x": [{"wildcard": "a\\*b"}]}`,
+		"shell-exact":     `{"x": [{"shellstyle": "abc"}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for event, want := range map[string][]X{
+		`{"x": "abc"}`: {"shell-prefix", "wildcard-prefix", "shell-exact"},
+		`{"x": "a*b"}`: {"escaped-star"},
+		`{"x": "ab"}`:  {"shell-prefix", "wildcard-prefix"},
+		`{"x": "axb"}`: {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+}
+
+func TestEscapedWildcardSubstrings(t *testing.T) {
+	q, _ := New()
+	patterns := map[string]string{
+		"star":      `{"x": [{"wildcard": "*2\\*3*"}]}`,
+		"backslash": `{"x": [{"wildcard": "*C:\\\\*"}]}`,
+		"plain":     `{"x": [{"wildcard": "*plain*"}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := map[string][]X{
+		`{"x": "2*3=6"}`:            {"star"},
+		`{"x": "2x3=6"}`:            {},
+		`{"x": "path C:\\windows"}`: {"backslash"},
+		`{"x": "plain 2*3"}`:        {"plain", "star"},
+	}
+	for event, wanted := range cases {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(matches, wanted) {
+			t.Errorf("%s: wanted %v got %v", event, wanted, matches)
+		}
+	}
+}
+
+// TestSubstringMatcherAgreesWithNFA checks that the substring fast path produces the same
+// results as the NFA that would otherwise have been built, both below and above the
+// threshold at which Aho-Corasick takes over.
+func TestSubstringMatcherAgreesWithNFA(t *testing.T) {
+	patterns := []string{
+		"*foo*", "*oba*", "*bar*", "*r b*", "*x*", "*foobar*", `*"*`, "*é*",
+		"*bar", "foo*bar", "f*o*o", "*o*o*", "a*", "fo*ob*", "*oo*ob*", "f*r", "foo*oba*bar",
+	}
+	values := []string{
+		`"foobar"`, `"foo bar"`, `"xyz"`, `"nothing"`, `""`, `"oba"`, `"fo"`, `"a"b"`, `"café"`,
+		`12`, `"bar`, `bar"`, `"foo"`, `"fobar"`, `"fooobar"`, `"foobaobar"`,
+	}
+	for n := 1; n <= len(patterns); n++ {
+		var sm *substringMatcher
+		nfas := make(map[*fieldMatcher]*faState)
+		for _, pattern := range patterns[:n] {
+			quoted := []byte(`"` + pattern + `"`)
+			prefix, middles, suffix, ok := shellPieces(shellStyleType, quoted)
+			if !ok {
+				continue
+			}
+			var fm *fieldMatcher
+			sm, fm = sm.with(prefix, middles, suffix)
+			nfa, _ := makeShellStyleFA(quoted, sharedNullPrinter)
+			epsilonClosure(nfa)
+			nfas[fm] = nfa
+		}
+		if (len(sm.literals) >= acMinLiterals) != (sm.ac != nil) {
+			t.Errorf("n=%d, ac=%v", n, sm.ac != nil)
+		}
+		bufs := newNfaBuffers()
+		for _, value := range values {
+			tm := bufs.getTransmap()
+			tm.push()
+			got := sm.transitionOn([]byte(value), nil, bufs)
+			tm.pop()
+			gotSet := make(map[*fieldMatcher]bool)
+			for _, fm := range got {
+				if gotSet[fm] {
+					t.Errorf("duplicate transition for %s", value)
+				}
+				gotSet[fm] = true
+			}
+			for fm, nfa := range nfas {
+				wanted := len(testTraverseNFA(nfa, []byte(value), nil, bufs)) > 0
+				if wanted != gotSet[fm] {
+					t.Errorf("n=%d value %s: NFA says %v", n, value, wanted)
+				}
+			}
+		}
+	}
+}
+
+// TestSubstringMatcherRandom compares the substring fast path with the NFA on random patterns
+// and values over a small alphabet, where pieces overlap and repeat a lot.
+func TestSubstringMatcherRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(2323))
+	randomString := func(maxLen int) string {
+		b := make([]byte, rng.Intn(maxLen+1))
+		for i := range b {
+			b[i] = "abc"[rng.Intn(3)]
+		}
+		return string(b)
+	}
+	var sm *substringMatcher
+	nfas := make(map[*fieldMatcher]*faState)
+	for len(nfas) < 40 {
+		pattern := randomString(3)
+		for stars := rng.Intn(3) + 1; stars > 0; stars-- {
+			pattern += "*" + randomString(3)
+		}
+		quoted := []byte(`"` + pattern + `"`)
+		prefix, middles, suffix, ok := shellPieces(shellStyleType, quoted)
+		if !ok || strings.Contains(pattern, "**") {
+			continue
+		}
+		var fm *fieldMatcher
+		sm, fm = sm.with(prefix, middles, suffix)
+		if nfas[fm] != nil {
+			continue
+		}
+		nfa, _ := makeShellStyleFA(quoted, sharedNullPrinter)
+		epsilonClosure(nfa)
+		nfas[fm] = nfa
+	}
+	bufs := newNfaBuffers()
+	for i := 0; i < 500; i++ {
+		value := []byte(`"` + randomString(10) + `"`)
+		tm := bufs.getTransmap()
+		tm.push()
+		got := sm.transitionOn(value, nil, bufs)
+		tm.pop()
+		gotSet := make(map[*fieldMatcher]bool)
+		for _, fm := range got {
+			gotSet[fm] = true
+		}
+		for fm, nfa := range nfas {
+			wanted := len(testTraverseNFA(nfa, value, nil, bufs)) > 0
+			if wanted != gotSet[fm] {
+				t.Errorf("value %s: NFA says %v", value, wanted)
+			}
+		}
+	}
+}
+
+func TestSubstringPatterns(t *testing.T) {
+	q, _ := New()
+	words := []string{"timeout", "refused", "reset", "denied", "overflow", "panic"}
+	for _, word := range words {
+		pattern := fmt.Sprintf(`{"msg": [{"shellstyle": "*%s*"}]}`, word)
+		if err := q.AddPattern(word, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// mixing in other kinds of value on the same field
+	if err := q.AddPattern("exact", `{"msg": ["connection reset"]}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("start", `{"msg": [{"shellstyle": "conn*"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	// same literal twice
+	if err := q.AddPattern("timeout2", `{"msg": [{"shellstyle": "*timeout*"}]}`); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string][]X{
+		`{"msg": "connection reset"}`:                   {"reset", "exact", "start"},
+		`{"msg": "read timeout, connection refused"}`:   {"timeout", "timeout2", "refused"},
+		`{"msg": "all good"}`:                           {},
+		`{"msg": "kernel panic: stack overflow panic"}`: {"panic", "overflow"},
+		`{"msg": 12}`:                                   {},
+	}
+	for event, wanted := range cases {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(matches, wanted) {
+			t.Errorf("%s: wanted %v got %v", event, wanted, matches)
+		}
+	}
+}
+
+// TestNestedDeterministicTransitions covers a case where a field's automaton yields more than one
+// transition and the next field's does as well; the second field's results must not overwrite the
+// first's while they're being iterated.
+func TestNestedDeterministicTransitions(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("p1", `{"a":[{"prefix":"x"}], "b":[{"prefix":"q"}, {"prefix":"qr"}]}`)
+	_ = q.AddPattern("p2", `{"a":[{"prefix":"xy"}], "b":[{"prefix":"qrs"}]}`)
+	matches, _ := q.MatchesForEvent([]byte(`{"a":"xyz","b":"qrs"}`))
+	if !containsExactly(matches, []X{"p1", "p2"}) {
+		t.Errorf("got %v", matches)
+	}
+}
+
+func containsExactly(got []X, wanted []X) bool {
+	if len(got) != len(wanted) {
+		return false
+	}
+	for _, w := range wanted {
+		found := false
+		for _, g := range got {
+			if g == w {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSubstringPrefixGrouping(t *testing.T) {
+	var sm *substringMatcher
+	for _, pattern := range []string{`"ab*x"`, `"*q*"`, `"cd*y"`, `"ab*z"`, `"cd*q*"`, `"ab*x"`} {
+		prefix, middles, suffix, ok := shellPieces(shellStyleType, []byte(pattern))
+		if !ok {
+			t.Fatalf("%s rejected", pattern)
+		}
+		sm, _ = sm.with(prefix, middles, suffix)
+	}
+	if len(sm.patterns) != 5 || len(sm.prefixes) != 2 || len(sm.literals) != 1 {
+		t.Fatalf("%d patterns, %d prefixes, %d literals", len(sm.patterns), len(sm.prefixes), len(sm.literals))
+	}
+	var order []string
+	for _, pattern := range sm.patterns {
+		order = append(order, string(pattern.prefix))
+	}
+	if strings.Join(order, ",") != "ab,ab,,cd,cd" {
+		t.Errorf("order %v", order)
+	}
+
+	q, _ := New()
+	patterns := map[X]string{
+		"jpg":   `{"img": [{"shellstyle": "*.jpg"}]}`,
+		"site":  `{"img": [{"shellstyle": "https://example.com/*"}]}`,
+		"both":  `{"img": [{"wildcard": "https://example.com/*.jpg"}]}`,
+		"any":   `{"img": [{"shellstyle": "https://example.*/*.jpg"}]}`,
+		"digit": `{"img": [{"shellstyle": "*9*4*"}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := map[string][]X{
+		`{"img": "https://example.com/9943.jpg"}`: {"jpg", "site", "both", "any", "digit"},
+		`{"img": "https://example.org/9943.jpg"}`: {"jpg", "any", "digit"},
+		`{"img": "https://example.com/4.png"}`:    {"site"},
+		`{"img": "https://example.com.jpg"}`:      {"jpg"},
+	}
+	for event, wanted := range cases {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsExactly(matches, wanted) {
+			t.Errorf("%s: wanted %v got %v", event, wanted, matches)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	q, _ := New()
+	patterns := map[X]string{
+		"timeout":  `{"msg": [{"contains": "timeout"}]}`,
+		"same":     `{"msg": [{"wildcard": "*timeout*"}]}`,
+		"star":     `{"msg": [{"contains": "a*b"}]}`,
+		"anything": `{"code": [{"contains": ""}]}`,
+		"mixed":    `{"code": [{"contains": "E1"}, "ok", {"prefix": "W"}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	for event, want := range map[string][]X{
+		`{"msg": "read timeout after 5s"}`: {"timeout", "same"},
+		`{"msg": "timeout"}`:               {"timeout", "same"},
+		`{"msg": "time out"}`:              {},
+		`{"msg": "xa*by"}`:                 {"star"},
+		`{"msg": "xaaby"}`:                 {},
+		`{"code": ""}`:                     {"anything"},
+		`{"code": "XE12"}`:                 {"anything", "mixed"},
+		`{"code": "ok"}`:                   {"anything", "mixed"},
+		`{"code": "Warn"}`:                 {"anything", "mixed"},
+		`{"code": 12}`:                     {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+
+	// never in the automaton
+	for _, val := range []string{`"timeout"`, `""`, `"*"`} {
+		vm := newValueMatcher()
+		_ = vm.addTransition(typedVal{vType: containsType, val: val}, sharedNullPrinter, newClosureBuffers(), BuiltForComfort)
+		if fields := vm.fields(); fields.start != nil || fields.substrings == nil {
+			t.Errorf("%s built an automaton", val)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"x": [{"contains": 3}]}`,
+		`{"x": [{"contains": ["a"]}]}`,
+		`{"x": [{"contains": "a", "b": 1}]}`,
+	} {
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestContainsIgnoreCase(t *testing.T) {
+	q, _ := New()
+	patterns := map[X]string{
+		"timeout":  `{"msg": [{"contains-ignore-case": "TimeOut"}]}`,
+		"cased":    `{"msg": [{"contains": "timeout"}]}`,
+		"über":     `{"msg": [{"contains-ignore-case": "über"}]}`,
+		"anything": `{"code": [{"contains-ignore-case": ""}]}`,
+		"mixed":    `{"code": [{"contains-ignore-case": "e1"}, "ok", {"suffix-equals-ignore-case": "x"}]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	for event, want := range map[string][]X{
+		`{"msg": "read TIMEOUT after 5s"}`: {"timeout"},
+		`{"msg": "read timeout after 5s"}`: {"timeout", "cased"},
+		`{"msg": "tImEoUt"}`:               {"timeout"},
+		`{"msg": "time out"}`:              {},
+		`{"msg": "timeou"}`:                {},
+		// only ASCII letters are folded
+		`{"msg": "über alles"}`: {"über"},
+		`{"msg": "ÜBER alles"}`: {},
+		`{"msg": "üBER alles"}`: {"über"},
+		`{"code": ""}`:          {"anything"},
+		`{"code": "xE12"}`:      {"anything", "mixed"},
+		`{"code": "ok"}`:        {"anything", "mixed"},
+		`{"code": "OK"}`:        {"anything"},
+		`{"code": "WX"}`:        {"anything", "mixed"},
+		`{"code": 12}`:          {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(event))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+
+	// never in the automaton, and the same literal in another case is the same pattern
+	vm := newValueMatcher()
+	first := vm.addTransition(typedVal{vType: monocaseContainsType, val: `"Abc"`}, sharedNullPrinter, newClosureBuffers(), BuiltForComfort)
+	again := vm.addTransition(typedVal{vType: monocaseContainsType, val: `"aBC"`}, sharedNullPrinter, newClosureBuffers(), BuiltForComfort)
+	if fields := vm.fields(); fields.start != nil || fields.substrings == nil || first != again {
+		t.Error("contains-ignore-case built an automaton, or a second pattern")
+	}
+
+	for _, bad := range []string{
+		`{"x": [{"contains-ignore-case": 3}]}`,
+		`{"x": [{"contains-ignore-case": ["a"]}]}`,
+		`{"x": [{"contains-ignore-case": "a", "b": 1}]}`,
+	} {
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestIndexFoldedASCII(t *testing.T) {
+	alphabet := []byte("aAbB")
+	randBytes := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = alphabet[rand.Intn(len(alphabet))]
+		}
+		return b
+	}
+	for trial := 0; trial < 2000; trial++ {
+		literal := randBytes(rand.Intn(8))
+		val := randBytes(rand.Intn(60))
+		want := bytes.Index(bytes.ToLower(val), bytes.ToLower(literal))
+		if got := indexFoldedASCII(val, lowerASCII(literal)); got != want {
+			t.Errorf("%s in %s: got %d want %d", literal, val, got, want)
+		}
+	}
+	// only ASCII letters are folded, and other bytes compared as they are
+	if indexFoldedASCII([]byte("xÜBER"), lowerASCII([]byte("über"))) != -1 {
+		t.Error("folded a non-ASCII letter")
+	}
+	if indexFoldedASCII([]byte("x@Y"), lowerASCII([]byte("`y"))) != -1 {
+		t.Error("folded a non-letter")
+	}
+}
+
+func BenchmarkContainsIgnoreCase(b *testing.B) {
+	literal := lowerASCII([]byte("connection reset by peer"))
+	val := []byte(strings.Repeat("Lorem ipsum dolor sit amet, CONNECTION refused. ", 40) + "Connection Reset By Peer")
+	b.Run("indexFoldedASCII", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			indexFoldedASCII(val, literal)
+		}
+	})
+	q, _ := New()
+	_ = q.AddPattern("x", `{"msg": [{"contains-ignore-case": "connection reset by peer"}]}`)
+	event := []byte(`{"msg": "` + string(val) + `"}`)
+	b.Run("contains-ignore-case", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = q.MatchesForEvent(event)
+		}
+	})
+	re, _ := New()
+	_ = re.AddPattern("x", `{"msg": [{"regexp": ".*[cC][oO][nN][nN][eE][cC][tT][iI][oO][nN] [rR][eE][sS][eE][tT] [bB][yY] [pP][eE][eE][rR].*"}]}`)
+	b.Run("regexp", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = re.MatchesForEvent(event)
+		}
+	})
+}
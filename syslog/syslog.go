@@ -0,0 +1,327 @@
+// Package syslog provides a Flattener for syslog messages in the format of RFC 5424, so that syslog pipelines
+// can match messages against Quamina Patterns without first converting them to JSON. A message such as
+//
+//	<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event
+//
+// is flattened as if it were the Event
+//
+//	{
+//	  "priority": 165, "facility": 20, "severity": 5, "version": 1,
+//	  "timestamp": "2003-10-11T22:14:15.003Z", "hostname": "mymachine.example.com",
+//	  "appName": "evntslog", "msgId": "ID47",
+//	  "structuredData": {"exampleSDID@32473": {"iut": "3", "eventSource": "Application"}},
+//	  "message": "An application event"
+//	}
+//
+// so this Pattern matches warnings and worse from applications reporting an eventSource:
+//
+//	{"severity": [{"numeric": ["<=", 4]}], "structuredData": {"exampleSDID@32473": {"eventSource": [{"exists": true}]}}}
+//
+// Header fields whose value is the NILVALUE "-" are left out, as is the message if there isn't one; a
+// parameter that appears more than once in an SD-ELEMENT is an array of its values, in order, so a Pattern
+// matches if any of them does. Use the Flattener like this:
+//
+//	q, err := quamina.New(quamina.WithFlattener(syslog.NewFlattener()))
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"quamina.net/go/quamina/v2"
+	"quamina.net/go/quamina/v2/internal/flatten"
+)
+
+// Flattener flattens RFC 5424 syslog messages. Like Quamina instances, a Flattener is not safe for
+// concurrent use; Copy makes one for another goroutine.
+type Flattener struct {
+	w      flatten.Writer
+	params []param
+}
+
+type param struct {
+	name, value string
+}
+
+// NewFlattener returns a Flattener for RFC 5424 syslog messages.
+func NewFlattener() *Flattener {
+	return &Flattener{}
+}
+
+// Copy returns a new Flattener.
+func (f *Flattener) Copy() quamina.Flattener {
+	return NewFlattener()
+}
+
+// maximum lengths of header fields, from RFC 5424 section 6
+const (
+	maxHostname = 255
+	maxAppName  = 48
+	maxProcID   = 128
+	maxMsgID    = 32
+	maxSDName   = 32
+)
+
+// Flatten parses the syslog message in event, returning an error if it isn't in the format of RFC 5424.
+// The Fields returned are valid until the next call.
+func (f *Flattener) Flatten(event []byte, tracker quamina.SegmentsTreeTracker) ([]quamina.Field, error) {
+	f.w.Reset()
+	p := parser{msg: event}
+
+	pri, err := p.priority()
+	if err != nil {
+		return nil, err
+	}
+	f.w.Number(tracker, "priority", strconv.Itoa(pri))
+	f.w.Number(tracker, "facility", strconv.Itoa(pri/8))
+	f.w.Number(tracker, "severity", strconv.Itoa(pri%8))
+
+	version, err := p.version()
+	if err != nil {
+		return nil, err
+	}
+	f.w.Number(tracker, "version", version)
+
+	for _, header := range []struct {
+		name   string
+		maxLen int
+	}{
+		{"timestamp", 0},
+		{"hostname", maxHostname},
+		{"appName", maxAppName},
+		{"procId", maxProcID},
+		{"msgId", maxMsgID},
+	} {
+		if err = p.space(); err != nil {
+			return nil, err
+		}
+		value, err := p.headerField(header.name, header.maxLen)
+		if err != nil {
+			return nil, err
+		}
+		if value != "-" {
+			f.w.String(tracker, header.name, value)
+		}
+	}
+
+	if err = p.space(); err != nil {
+		return nil, err
+	}
+	if err = f.structuredData(&p, tracker); err != nil {
+		return nil, err
+	}
+
+	if p.done() {
+		return f.w.Fields(), nil
+	}
+	if err = p.space(); err != nil {
+		return nil, err
+	}
+	message := p.msg[p.pos:]
+	if len(message) >= 3 && message[0] == 0xEF && message[1] == 0xBB && message[2] == 0xBF {
+		message = message[3:] // the BOM marks the message as UTF-8, and isn't part of it
+	}
+	f.w.String(tracker, "message", string(message))
+	return f.w.Fields(), nil
+}
+
+// structuredData reads the STRUCTURED-DATA of a message, writing the parameters of its SD-ELEMENTs as the
+// members of the objects named by their SD-IDs.
+func (f *Flattener) structuredData(p *parser, tracker quamina.SegmentsTreeTracker) error {
+	if p.peek() == '-' {
+		p.pos++
+		return nil
+	}
+	if p.peek() != '[' {
+		return p.error("expected structured data")
+	}
+	sdNode, sdUsed := f.w.Object(tracker, "structuredData")
+	for p.peek() == '[' {
+		p.pos++
+		id, err := p.sdName("SD-ID")
+		if err != nil {
+			return err
+		}
+		f.params = f.params[:0]
+		for p.peek() == ' ' {
+			p.pos++
+			name, err := p.sdName("PARAM-NAME")
+			if err != nil {
+				return err
+			}
+			if p.peek() != '=' {
+				return p.error("expected '=' after PARAM-NAME")
+			}
+			p.pos++
+			value, err := p.paramValue()
+			if err != nil {
+				return err
+			}
+			f.params = append(f.params, param{name, value})
+		}
+		if p.peek() != ']' {
+			return p.error("expected ']' at end of SD-ELEMENT")
+		}
+		p.pos++
+		if sdUsed {
+			if elementNode, ok := f.w.Object(sdNode, id); ok {
+				f.writeParams(elementNode)
+			}
+		}
+	}
+	return nil
+}
+
+// writeParams writes the parameters of an SD-ELEMENT, those whose name is repeated as an array.
+func (f *Flattener) writeParams(node quamina.SegmentsTreeTracker) {
+	for i, prm := range f.params {
+		first, repeated := true, false
+		for j, other := range f.params {
+			if other.name == prm.name && j != i {
+				repeated = true
+				if j < i {
+					first = false
+				}
+			}
+		}
+		switch {
+		case !repeated:
+			f.w.String(node, prm.name, prm.value)
+		case first:
+			if array, ok := f.w.Array(node, prm.name); ok {
+				for _, other := range f.params[i:] {
+					if other.name == prm.name {
+						array.Next()
+						array.String(other.value)
+					}
+				}
+				array.End()
+			}
+		}
+	}
+}
+
+// parser reads the parts of a syslog message in turn.
+type parser struct {
+	msg []byte
+	pos int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.msg)
+}
+
+// peek returns the next byte, or 0 at the end of the message
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.msg[p.pos]
+}
+
+func (p *parser) error(message string) error {
+	return fmt.Errorf("syslog message at %d: %s", p.pos, message)
+}
+
+// priority reads PRI, which is a number from 0 to 191 in angle brackets
+func (p *parser) priority() (int, error) {
+	if p.peek() != '<' {
+		return 0, errors.New("syslog message must start with '<'")
+	}
+	p.pos++
+	start := p.pos
+	for p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	digits := p.msg[start:p.pos]
+	if len(digits) == 0 || len(digits) > 3 || (len(digits) > 1 && digits[0] == '0') {
+		return 0, p.error("PRI must be a number of 1 to 3 digits")
+	}
+	pri, _ := strconv.Atoi(string(digits))
+	if pri > 191 {
+		return 0, p.error("PRI must be no larger than 191")
+	}
+	if p.peek() != '>' {
+		return 0, p.error("expected '>' after PRI")
+	}
+	p.pos++
+	return pri, nil
+}
+
+// version reads VERSION, a number of 1 to 3 digits that doesn't start with 0
+func (p *parser) version() (string, error) {
+	start := p.pos
+	for p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	digits := p.msg[start:p.pos]
+	if len(digits) == 0 || len(digits) > 3 || digits[0] == '0' {
+		return "", p.error("VERSION must be a number of 1 to 3 digits")
+	}
+	return string(digits), nil
+}
+
+func (p *parser) space() error {
+	if p.peek() != ' ' {
+		return p.error("expected a space")
+	}
+	p.pos++
+	return nil
+}
+
+// headerField reads a header field, which is printable ASCII; maxLen, if not 0, limits its length
+func (p *parser) headerField(name string, maxLen int) (string, error) {
+	start := p.pos
+	for p.peek() > ' ' && p.peek() < 0x7f {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.error(name + " is missing")
+	}
+	if maxLen > 0 && p.pos-start > maxLen {
+		return "", p.error(fmt.Sprintf("%s is longer than %d characters", name, maxLen))
+	}
+	return string(p.msg[start:p.pos]), nil
+}
+
+// sdName reads an SD-ID or PARAM-NAME, which is printable ASCII other than '=', ']', and '"'
+func (p *parser) sdName(what string) (string, error) {
+	start := p.pos
+	for c := p.peek(); c > ' ' && c < 0x7f && c != '=' && c != ']' && c != '"'; c = p.peek() {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", p.error(what + " is missing")
+	}
+	if p.pos-start > maxSDName {
+		return "", p.error(fmt.Sprintf("%s is longer than %d characters", what, maxSDName))
+	}
+	return string(p.msg[start:p.pos]), nil
+}
+
+// paramValue reads a quoted PARAM-VALUE, in which '"', '\', and ']' are escaped with '\'; a '\' before any
+// other character is part of the value, as RFC 5424 requires.
+func (p *parser) paramValue() (string, error) {
+	if p.peek() != '"' {
+		return "", p.error("PARAM-VALUE must be quoted")
+	}
+	p.pos++
+	var value []byte
+	for !p.done() {
+		c := p.msg[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return string(value), nil
+		case '\\':
+			if next := p.peek(); next == '"' || next == '\\' || next == ']' {
+				value = append(value, next)
+				p.pos++
+				continue
+			}
+		}
+		value = append(value, c)
+	}
+	return "", p.error("PARAM-VALUE is not terminated")
+}
@@ -0,0 +1,114 @@
+package syslog
+
+import (
+	"strings"
+	"testing"
+
+	"quamina.net/go/quamina/v2"
+)
+
+const example = `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 ` +
+	`[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] ` +
+	"\xEF\xBB\xBFAn application event log entry..."
+
+func matches(t *testing.T, pattern, message string) bool {
+	t.Helper()
+	q, err := quamina.New(quamina.WithFlattener(NewFlattener()), quamina.WithEventBridgeCompat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.AddPattern("p", pattern); err != nil {
+		t.Fatalf("%s: %v", pattern, err)
+	}
+	xs, err := q.MatchesForEvent([]byte(message))
+	if err != nil {
+		t.Fatalf("%s: %v", message, err)
+	}
+	return len(xs) == 1
+}
+
+func TestFields(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		message string
+		want    bool
+	}{
+		{`{"priority": [165], "facility": [20], "severity": [5], "version": [1]}`, example, true},
+		{`{"severity": [{"numeric": ["<=", 4]}]}`, example, false},
+		{`{"timestamp": [{"prefix": "2003-10-11T"}], "hostname": ["mymachine.example.com"]}`, example, true},
+		{`{"appName": ["evntslog"], "msgId": ["ID47"]}`, example, true},
+		{`{"procId": [{"exists": false}]}`, example, true},
+		{`{"structuredData": {"exampleSDID@32473": {"iut": ["3"], "eventID": ["1011"]}}}`, example, true},
+		{`{"structuredData": {"examplePriority@32473": {"class": ["high"]}}}`, example, true},
+		{`{"structuredData": {"exampleSDID@32473": {"class": [{"exists": true}]}}}`, example, false},
+		{`{"message": ["An application event log entry..."]}`, example, true},
+		{`{"message": [{"exists": false}]}`, `<34>1 - - - - - -`, true},
+		{`{"hostname": [{"exists": false}], "severity": [2]}`, `<34>1 - - - - - -`, true},
+		{`{"message": [""]}`, `<34>1 - - - - - - `, true},
+		{`{"procId": ["8710"], "message": ["hello world"]}`, `<0>1 - host app 8710 - - hello world`, true},
+		{`{"structuredData": {"x": {"v": ["a\"b]c\\d\\e"]}}}`, `<0>1 - - - - - [x v="a\"b\]c\\d\e"]`, true},
+	} {
+		if got := matches(t, test.pattern, test.message); got != test.want {
+			t.Errorf("%s on %q: got %t", test.pattern, test.message, got)
+		}
+	}
+}
+
+func TestRepeatedParams(t *testing.T) {
+	message := `<14>1 - - - - - [meta ip="10.0.0.1" ip="10.0.0.2" zone="a"]`
+	for _, test := range []struct {
+		pattern string
+		want    bool
+	}{
+		{`{"structuredData": {"meta": {"ip": ["10.0.0.2"]}}}`, true},
+		{`{"structuredData": {"meta": {"ip": ["10.0.0.1"], "zone": ["a"]}}}`, true},
+		{`{"structuredData": {"meta": {"ip": ["10.0.0.3"]}}}`, false},
+	} {
+		if got := matches(t, test.pattern, message); got != test.want {
+			t.Errorf("%s: got %t", test.pattern, got)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	f := NewFlattener()
+	q, _ := quamina.New(quamina.WithFlattener(f))
+	_ = q.AddPattern("p", `{"message": ["x"]}`)
+	for _, message := range []string{
+		``,
+		`34>1 - - - - - -`,
+		`<>1 - - - - - -`,
+		`<192>1 - - - - - -`,
+		`<034>1 - - - - - -`,
+		`<34 1 - - - - - -`,
+		`<34>0 - - - - - -`,
+		`<34> - - - - - -`,
+		`<34>1 - - - - -`,
+		`<34>1  - - - - -`,
+		`<34>1 - - - - - x`,
+		`<34>1 - - - - -x`,
+		`<34>1 - - ` + strings.Repeat("a", 49) + ` - - -`,
+		`<34>1 - - - - - [`,
+		`<34>1 - - - - - []`,
+		`<34>1 - - - - - [x`,
+		`<34>1 - - - - - [x a]`,
+		`<34>1 - - - - - [x a=b]`,
+		`<34>1 - - - - - [x a="b]`,
+		`<34>1 - - - - - [x a="b" ]`,
+		`<34>1 - - - - - [x a="b"]x`,
+	} {
+		if _, err := q.MatchesForEvent([]byte(message)); err == nil {
+			t.Errorf("%q: no error", message)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	q, _ := quamina.New(quamina.WithFlattener(NewFlattener()))
+	_ = q.AddPattern("app", `{"appName": ["evntslog"]}`)
+	c := q.Copy()
+	xs, err := c.MatchesForEvent([]byte(example))
+	if err != nil || len(xs) != 1 {
+		t.Errorf("copy matched %v, %v", xs, err)
+	}
+}
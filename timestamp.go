@@ -0,0 +1,134 @@
+package quamina
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// timestampRange is the parsed form of a "timestamp" pattern such as
+// {"timestamp": [">=", "2024-06-01T00:00:00+02:00", "<", "2024-06-02T00:00:00Z"]}. The bounds are instants, kept
+// in UTC so that equal ranges are ==, and a missing bound is an unbounded one. If hasZone is set, only
+// timestamps written with the offset from UTC zone, in seconds, are in the range.
+type timestampRange struct {
+	lo, hi         time.Time
+	hasLo, hasHi   bool
+	loOpen, hiOpen bool
+	zone           int
+	hasZone        bool
+}
+
+func (r timestampRange) contains(t time.Time) bool {
+	if r.hasZone {
+		if _, offset := t.Zone(); offset != r.zone {
+			return false
+		}
+	}
+	if r.hasLo {
+		if c := t.Compare(r.lo); c < 0 || (c == 0 && r.loOpen) {
+			return false
+		}
+	}
+	if r.hasHi {
+		if c := t.Compare(r.hi); c > 0 || (c == 0 && r.hiOpen) {
+			return false
+		}
+	}
+	return true
+}
+
+// readTimestampSpecial reads the array of comparisons in a "timestamp" pattern. As with "numeric", "=" must
+// appear alone, or there may be one of ">" and ">=" and one of "<" and "<="; "zone" may be added to any of
+// them, or appear alone.
+func readTimestampSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := t.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("value for 'timestamp' must be an array")
+	}
+	var r timestampRange
+	var ops []string
+	for {
+		t, err = pb.jd.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := t.(json.Delim); ok && delim == ']' {
+			break
+		}
+		op, ok := t.(string)
+		if !ok {
+			return nil, errors.New("'timestamp' comparisons must be an operator followed by a string")
+		}
+		t, err = pb.jd.Token()
+		if err != nil {
+			return nil, err
+		}
+		s, ok := t.(string)
+		if !ok {
+			return nil, fmt.Errorf("'timestamp' operator %s must be followed by a string", op)
+		}
+		if op == "zone" {
+			if r.hasZone {
+				return nil, errors.New("'timestamp' allows only one zone")
+			}
+			zoned, err := time.Parse("Z07:00", s)
+			if err != nil {
+				return nil, fmt.Errorf("'timestamp' zone must be Z or an offset such as +02:00, not %q", s)
+			}
+			_, r.zone = zoned.Zone()
+			r.hasZone = true
+			continue
+		}
+		instant, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("'timestamp' can't use %q: %w", s, err)
+		}
+		instant = instant.UTC()
+		switch op {
+		case "=":
+			r.lo, r.hi, r.hasLo, r.hasHi = instant, instant, true, true
+		case ">", ">=":
+			r.lo, r.hasLo, r.loOpen = instant, true, op == ">"
+		case "<", "<=":
+			r.hi, r.hasHi, r.hiOpen = instant, true, op == "<"
+		default:
+			return nil, fmt.Errorf("unknown 'timestamp' operator %q", op)
+		}
+		ops = append(ops, op)
+	}
+	switch {
+	case len(ops) == 0 && !r.hasZone:
+		return nil, errors.New("empty 'timestamp' comparison list")
+	case len(ops) > 2 || (len(ops) == 2 && (ops[0] == "=" || ops[1] == "=" || ops[0][0] == ops[1][0])):
+		return nil, errors.New(`'timestamp' allows "=" alone, or at most one lower and one upper bound`)
+	case r.hasLo && r.hasHi && (r.lo.After(r.hi) || (r.lo.Equal(r.hi) && (r.loOpen || r.hiOpen))):
+		return nil, errors.New("'timestamp' range contains no instants")
+	}
+
+	// has to be } or tokenizer will throw error
+	if _, err = pb.jd.Token(); err != nil {
+		return nil, err
+	}
+	return append(valsIn, typedVal{vType: timestampType, timestamp: r}), nil
+}
+
+type timestampTest struct {
+	r    timestampRange
+	next *fieldMatcher
+}
+
+// parseTimestamp parses an Event's value as an RFC 3339 timestamp, if it's a string holding one
+func parseTimestamp(val []byte) (time.Time, bool) {
+	if len(val) < 3 || val[0] != '"' {
+		return time.Time{}, false
+	}
+	// Parse keeps nothing of the string it's given, so the value needn't be copied
+	inner := val[1 : len(val)-1]
+	t, err := time.Parse(time.RFC3339, unsafe.String(&inner[0], len(inner)))
+	return t, err == nil
+}
@@ -0,0 +1,62 @@
+package quamina
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTimestampPatterns(t *testing.T) {
+	q, _ := New()
+	patterns := map[X]string{
+		"june1":    `{"t": [{"timestamp": ["=", "2024-06-01T00:00:00+02:00"]}]}`,
+		"june":     `{"t": [{"timestamp": [">=", "2024-06-01T00:00:00Z", "<", "2024-07-01T00:00:00Z"]}]}`,
+		"after":    `{"t": [{"timestamp": [">", "2024-05-31T22:00:00Z"]}]}`,
+		"utc":      `{"t": [{"timestamp": ["zone", "Z"]}]}`,
+		"paris":    `{"t": [{"timestamp": [">=", "2024-06-01T00:00:00Z", "zone", "+02:00"]}]}`,
+		"mqtt":     `{"t": [{"mqtt": "x/#"}]}`,
+		"combined": `{"t": [{"timestamp": ["<", "2000-01-01T00:00:00Z"]}, "not a time"]}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", x, err)
+		}
+	}
+	// a duplicate shares the existing range
+	_ = q.AddPattern("june1", `{"t": [{"timestamp": ["=", "2024-05-31T22:00:00Z"]}]}`)
+
+	for event, want := range map[string][]X{
+		"2024-06-01T00:00:00+02:00":     {"june1"},
+		"2024-05-31T22:00:00Z":          {"june1", "utc"},
+		"2024-05-31T22:00:00.000+00:00": {"june1", "utc"},
+		"2024-06-01T10:00:00+02:00":     {"june", "after", "paris"},
+		"2024-06-01T08:00:00Z":          {"june", "after", "utc"},
+		"2024-07-01T00:00:00Z":          {"after", "utc"},
+		"1999-12-31T23:59:59.999Z":      {"utc", "combined"},
+		"not a time":                    {"combined"},
+		"2024-06-01":                    {},
+	} {
+		matches, err := q.MatchesForEvent([]byte(fmt.Sprintf(`{"t": %q}`, event)))
+		if err != nil || !containsExactly(matches, want) {
+			t.Errorf("%s: %v, %v", event, matches, err)
+		}
+	}
+	if matches, _ := q.MatchesForEvent([]byte(`{"t": 20240601}`)); len(matches) != 0 {
+		t.Errorf("number matched %v", matches)
+	}
+
+	for _, bad := range []string{
+		`{"t": [{"timestamp": "2024-06-01T00:00:00Z"}]}`,
+		`{"t": [{"timestamp": []}]}`,
+		`{"t": [{"timestamp": ["=", "2024-06-01"]}]}`,
+		`{"t": [{"timestamp": [">", 5]}]}`,
+		`{"t": [{"timestamp": ["~", "2024-06-01T00:00:00Z"]}]}`,
+		`{"t": [{"timestamp": ["zone", "Europe/Paris"]}]}`,
+		`{"t": [{"timestamp": ["zone", "Z", "zone", "Z"]}]}`,
+		`{"t": [{"timestamp": ["=", "2024-06-01T00:00:00Z", "<", "2025-06-01T00:00:00Z"]}]}`,
+		`{"t": [{"timestamp": [">", "2024-06-01T00:00:00Z", "<", "2024-06-01T02:00:00+02:00"]}]}`,
+	} {
+		if err := q.AddPattern("bad", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package quamina
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ValueTransform rewrites the string values of a field of each Event before they're matched, so that values
+// that producers send dirty, such as with stray spaces or terminal color codes, match Patterns written for
+// clean ones. bytes.TrimSpace, URLDecode, and StripANSI are suitable Transforms.
+type ValueTransform struct {
+	// Path is the path of the field, as made by JoinPath.
+	Path string
+	// Transform returns the new value of a string, without the quotes. It must not modify s, which may be
+	// part of the Event, and is called concurrently by instances created with Copy.
+	Transform func(s []byte) []byte
+}
+
+// WithValueTransforms arranges that the instance's Flattener applies the transforms to the string values of
+// their fields, in the order given when there are several for a field, before they're matched. Values of
+// other types are left alone. The transforms apply to instances created with Copy, but not to the fields given
+// to MatchesForStruct or MatchesForTypedFields, which don't use the Flattener. This option call may not be
+// provided more than once.
+func WithValueTransforms(transforms ...ValueTransform) Option {
+	return func(q *Quamina) error {
+		if q.valueTransforms != nil {
+			return errors.New("value transforms specified more than once")
+		}
+		for _, vt := range transforms {
+			if vt.Path == "" {
+				return errors.New("value transform with an empty path")
+			}
+			if vt.Transform == nil {
+				return errors.New("value transform for " + vt.Path + " has no Transform function")
+			}
+		}
+		q.valueTransforms = append([]ValueTransform{}, transforms...)
+		return nil
+	}
+}
+
+// URLDecode decodes the escapes in a URL's query component, such as "%20" and "+" for spaces, so that
+// "a%2Fb" becomes "a/b". A string that isn't validly escaped is returned as it is.
+func URLDecode(s []byte) []byte {
+	if bytes.IndexByte(s, '%') < 0 && bytes.IndexByte(s, '+') < 0 {
+		return s
+	}
+	decoded, err := url.QueryUnescape(string(s))
+	if err != nil {
+		return s
+	}
+	return []byte(decoded)
+}
+
+// StripANSI removes the ANSI escape sequences, which terminals use for colors and cursor movement, from s:
+// the control sequences that begin with ESC [, the operating system commands that begin with ESC ] and end
+// with BEL or ESC \, and the other escape sequences, such as ESC ( B, which end with the first byte after ESC
+// that isn't from space to "/".
+func StripANSI(s []byte) []byte {
+	const esc, bel = 0x1b, 0x07
+	if bytes.IndexByte(s, esc) < 0 {
+		return s
+	}
+	stripped := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != esc {
+			stripped = append(stripped, s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			break
+		}
+		i++
+		switch s[i] {
+		case '[':
+			// parameter and intermediate bytes, up to the final byte
+			for i+1 < len(s) && s[i+1] >= 0x20 && s[i+1] <= 0x3f {
+				i++
+			}
+			if i+1 < len(s) && s[i+1] >= 0x40 && s[i+1] <= 0x7e {
+				i++
+			}
+		case ']':
+			for i+1 < len(s) && s[i+1] != bel && !(s[i+1] == esc && i+2 < len(s) && s[i+2] == '\\') {
+				i++
+			}
+			switch {
+			case i+1 < len(s) && s[i+1] == bel:
+				i++
+			case i+1 < len(s):
+				i += 2
+			}
+		default:
+			// intermediate bytes, such as the "(" of ESC ( B, come before the final byte
+			for i+1 < len(s) && s[i] >= 0x20 && s[i] <= 0x2f {
+				i++
+			}
+		}
+	}
+	return stripped
+}
+
+// transformFlattener applies the transforms that WithValueTransforms provides to the fields its Flattener
+// extracts
+type transformFlattener struct {
+	flattener  Flattener
+	transforms map[string][]func(s []byte) []byte
+}
+
+func newTransformFlattener(flattener Flattener, transforms []ValueTransform, foldFieldNames bool) *transformFlattener {
+	tf := &transformFlattener{flattener: flattener, transforms: make(map[string][]func(s []byte) []byte)}
+	for _, vt := range transforms {
+		path := vt.Path
+		if foldFieldNames {
+			path = strings.ToLower(path)
+		}
+		tf.transforms[path] = append(tf.transforms[path], vt.Transform)
+	}
+	return tf
+}
+
+func (f *transformFlattener) Copy() Flattener {
+	return &transformFlattener{flattener: f.flattener.Copy(), transforms: f.transforms}
+}
+
+func (f *transformFlattener) Flatten(event []byte, tracker SegmentsTreeTracker) ([]Field, error) {
+	fields, err := f.flattener.Flatten(event, tracker)
+	if err != nil {
+		return nil, err
+	}
+	for i, field := range fields {
+		transforms, ok := f.transforms[string(field.Path)]
+		if !ok || len(field.Val) < 2 || field.Val[0] != '"' {
+			continue
+		}
+		s := field.Val[1 : len(field.Val)-1]
+		for _, transform := range transforms {
+			s = transform(s)
+		}
+		val := make([]byte, 0, len(s)+2)
+		val = append(val, '"')
+		val = append(val, s...)
+		fields[i].Val = append(val, '"')
+	}
+	return fields, nil
+}
@@ -0,0 +1,98 @@
+package quamina
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestValueTransforms(t *testing.T) {
+	q, err := New(
+		WithValueTransforms(
+			ValueTransform{Path: "user", Transform: bytes.TrimSpace},
+			ValueTransform{Path: JoinPath("request", "query"), Transform: URLDecode},
+			ValueTransform{Path: "log", Transform: StripANSI},
+			ValueTransform{Path: "log", Transform: bytes.TrimSpace},
+		),
+		WithDerivedFields(DerivedLength("user")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := map[X]string{
+		"alice":  `{"user": ["alice"]}`,
+		"search": `{"request": {"query": ["q=red shoes&page=2"]}}`,
+		"failed": `{"log": [{"prefix": "ERROR"}]}`,
+		"short":  `{"$derived": {"len(user)": [3]}}`,
+	}
+	for x, p := range patterns {
+		if err := q.AddPattern(x, p); err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+	}
+	tests := map[string][]X{
+		`{"user": "  alice\t"}`:                                  {"alice"},
+		`{"user": "bob "}`:                                       {"short"},
+		`{"user": ["x", " alice"]}`:                              {"alice"},
+		`{"request": {"query": "q%3Dred+shoes%26page%3D2"}}`:     {"search"},
+		`{"request": {"query": "q=red shoes&page=2"}}`:           {"search"},
+		`{"log": "\u001b[1;31mERROR\u001b[0m disk full"}`:        {"failed"},
+		`{"log": " \u001b]0;title\u0007\u001b(BERROR: no room"}`: {"failed"},
+		`{"log": "WARN \u001b[33mERROR\u001b[0m"}`:               {},
+		`{"other": "  alice"}`:                                   {},
+	}
+	for event, want := range tests {
+		for _, instance := range []*Quamina{q, q.Copy()} {
+			matches, err := instance.MatchesForEvent([]byte(event))
+			if err != nil || !containsExactly(matches, want) {
+				t.Errorf("%s: %v, %v", event, matches, err)
+			}
+		}
+	}
+}
+
+func TestURLDecode(t *testing.T) {
+	tests := map[string]string{
+		"plain":          "plain",
+		"a%2Fb":          "a/b",
+		"red+shoes":      "red shoes",
+		"caf%C3%A9":      "café",
+		"100%":           "100%",
+		"%zz and things": "%zz and things",
+	}
+	for s, want := range tests {
+		if got := string(URLDecode([]byte(s))); got != want {
+			t.Errorf("%q: %q", s, got)
+		}
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	tests := map[string]string{
+		"plain":                                  "plain",
+		"\x1b[31mred\x1b[0m":                     "red",
+		"\x1b[1;38;5;208morange\x1b[m":           "orange",
+		"\x1b]8;;http://x\x1b\\link\x1b]8;;\x07": "link",
+		"\x1bcreset":                             "reset",
+		"cut\x1b":                                "cut",
+		"cut\x1b[12":                             "cut",
+	}
+	for s, want := range tests {
+		if got := string(StripANSI([]byte(s))); got != want {
+			t.Errorf("%q: %q", s, got)
+		}
+	}
+}
+
+func TestValueTransformsErrors(t *testing.T) {
+	for _, transforms := range [][]ValueTransform{
+		{{Transform: bytes.TrimSpace}},
+		{{Path: "a"}},
+	} {
+		if _, err := New(WithValueTransforms(transforms...)); err == nil {
+			t.Errorf("accepted %v", transforms)
+		}
+	}
+	if _, err := New(WithValueTransforms(), WithValueTransforms()); err == nil {
+		t.Error("accepted option twice")
+	}
+}
@@ -0,0 +1,130 @@
+package quamina
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// TypedField is a field of an Event which the caller has flattened already, as a Flattener would, but whose
+// value is a Go value rather than text, for MatchesForTypedFields.
+type TypedField struct {
+	// Path and ArrayTrail are as in Field.
+	Path       []byte
+	ArrayTrail []ArrayPos
+	// Value is nil, which is null, a bool, an int, int64, uint64, or float64, or a string or a []byte holding
+	// a string's bytes.
+	Value any
+}
+
+// MatchesForTypedFields returns the X values of the Patterns which match the Event whose fields are given.
+// This is for sources, such as binary encodings and columnar stores, whose values are typed already: numbers
+// are matched as the numbers they are, without being written out as text and parsed back, so integers too
+// large for JSON parsers to hold exactly are matched as exactly as Quamina matches any number. NaN and the
+// infinities are errors, as are values of other types. As with MatchesForEvent, the returned slice is
+// overwritten by the next call.
+func (q *Quamina) MatchesForTypedFields(fields []TypedField) ([]X, error) {
+	q.labels.flattening()
+	if q.labels != nil {
+		defer q.labels.done()
+	}
+	if q.typedFields == nil {
+		q.typedFields = &typedFieldsReader{foldNames: q.foldFieldNames}
+	}
+	converted, err := q.typedFields.convert(fields)
+	if err != nil {
+		return nil, err
+	}
+	q.labels.matching()
+	matches, err := q.matcher.matchesForFields(converted, q.bufs)
+	q.bufs.release()
+	if err == nil && q.samples.active() {
+		matches = q.samples.filter(matches, fieldsHash(converted))
+	}
+	return matches, err
+}
+
+// typedFieldsReader turns TypedFields into Fields, reusing its buffers from call to call
+type typedFieldsReader struct {
+	fields []Field
+	vals   []byte // the Vals of the fields which aren't literals are written here, one after another
+	ends   []int
+	// foldNames means the paths are folded to lower case; see WithCaseInsensitiveFieldNames
+	foldNames bool
+}
+
+func (tr *typedFieldsReader) convert(typed []TypedField) ([]Field, error) {
+	tr.fields = tr.fields[:0]
+	tr.vals = tr.vals[:0]
+	tr.ends = tr.ends[:0]
+	for i := range typed {
+		tf := &typed[i]
+		f := Field{Path: tf.Path, ArrayTrail: tf.ArrayTrail}
+		if tr.foldNames {
+			f.Path = foldName(f.Path)
+		}
+		switch v := tf.Value.(type) {
+		case nil:
+			f.Val = nullBytes
+		case bool:
+			f.Val = falseBytes
+			if v {
+				f.Val = trueBytes
+			}
+		case int:
+			tr.vals = strconv.AppendInt(tr.vals, int64(v), 10)
+			f.number, f.IsNumber = float64(v), true
+		case int64:
+			tr.vals = strconv.AppendInt(tr.vals, v, 10)
+			f.number, f.IsNumber = float64(v), true
+		case uint64:
+			tr.vals = strconv.AppendUint(tr.vals, v, 10)
+			f.number, f.IsNumber = float64(v), true
+		case float64:
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, fmt.Errorf("field %s: %v isn't a number that can be matched", tf.Path, v)
+			}
+			format := byte('f')
+			if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+				format = 'e'
+			}
+			tr.vals = strconv.AppendFloat(tr.vals, v, format, -1, 64)
+			f.number, f.IsNumber = v, true
+		case string:
+			tr.vals = appendQuotedString(tr.vals, v)
+		case []byte:
+			if utf8.Valid(v) {
+				tr.vals = append(append(append(tr.vals, '"'), v...), '"')
+			} else {
+				tr.vals = appendQuotedString(tr.vals, string(v))
+			}
+		default:
+			return nil, fmt.Errorf("field %s: values of type %T can't be matched", tf.Path, v)
+		}
+		f.hasNumber = f.IsNumber
+		tr.fields = append(tr.fields, f)
+		tr.ends = append(tr.ends, len(tr.vals))
+	}
+
+	// the values written into vals are sliced out once it has stopped growing
+	start := 0
+	for i, end := range tr.ends {
+		if tr.fields[i].Val == nil {
+			tr.fields[i].Val = tr.vals[start:end:end]
+		}
+		start = end
+	}
+	return tr.fields, nil
+}
+
+// appendQuotedString writes s as Quamina represents a string value, in quotation marks but otherwise unescaped
+func appendQuotedString(buf []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "�")
+	}
+	buf = append(buf, '"')
+	buf = append(buf, s...)
+	return append(buf, '"')
+}
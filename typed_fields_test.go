@@ -0,0 +1,88 @@
+package quamina
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMatchesForTypedFields(t *testing.T) {
+	q, _ := New(WithEventBridgeCompat())
+	for x, pattern := range map[string]string{
+		"int":       `{"count": [1500]}`,
+		"exponent":  `{"count": [1.5e3]}`,
+		"range":     `{"price": [{"numeric": [">", 9.99, "<=", 10]}]}`,
+		"big":       `{"id": [{"numeric": [">", 9007199254740000]}]}`,
+		"unsigned":  `{"mask": [18446744073709551615]}`,
+		"string":    `{"name": ["pat"], "ok": [true], "note": [null]}`,
+		"bytes":     `{"key": [{"prefix": "ord"}]}`,
+		"notNumber": `{"name": ["1500"]}`,
+		"sameItem":  `{"items": {"sku": ["a"], "qty": [2]}}`,
+		"otherItem": `{"items": {"sku": ["a"], "qty": [5]}}`,
+		"tiny":      `{"tiny": [1e-7]}`,
+	} {
+		if err := q.AddPattern(x, pattern); err != nil {
+			t.Fatalf("%s: %v", pattern, err)
+		}
+	}
+	fields := []TypedField{
+		{Path: []byte("count"), Value: int64(1500)},
+		{Path: []byte("price"), Value: 10.0},
+		{Path: []byte("id"), Value: int64(9007199254740993)},
+		{Path: []byte("mask"), Value: uint64(math.MaxUint64)},
+		{Path: []byte("name"), Value: "pat"},
+		{Path: []byte("ok"), Value: true},
+		{Path: []byte("note"), Value: nil},
+		{Path: []byte("key"), Value: []byte("order-17")},
+		{Path: []byte("items\nsku"), Value: "a", ArrayTrail: []ArrayPos{{0, 1}}},
+		{Path: []byte("items\nqty"), Value: 2, ArrayTrail: []ArrayPos{{0, 1}}},
+		{Path: []byte("items\nsku"), Value: "b", ArrayTrail: []ArrayPos{{0, 2}}},
+		{Path: []byte("items\nqty"), Value: 5, ArrayTrail: []ArrayPos{{0, 2}}},
+		{Path: []byte("tiny"), Value: 1e-7},
+	}
+	got, err := q.MatchesForTypedFields(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []X{"int", "exponent", "range", "big", "unsigned", "string", "bytes", "sameItem", "tiny"}
+	if !containsExactly(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// the same Event as JSON matches the same Patterns
+	event := `{"count": 1500, "price": 10.0, "id": 9007199254740993, "mask": 18446744073709551615, "name": "pat",
+		"ok": true, "note": null, "key": "order-17", "items": [{"sku": "a", "qty": 2}, {"sku": "b", "qty": 5}],
+		"tiny": 1e-7}`
+	got, err = q.MatchesForEvent([]byte(event))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !containsExactly(got, want) {
+		t.Errorf("as JSON, got %v, want %v", got, want)
+	}
+
+	got, err = q.MatchesForTypedFields([]TypedField{{Path: []byte("price"), Value: 9.99}})
+	if err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v", got, err)
+	}
+
+	for _, bad := range []any{math.NaN(), math.Inf(1), int32(1), []string{"a"}} {
+		if _, err := q.MatchesForTypedFields([]TypedField{{Path: []byte("count"), Value: bad}}); err == nil {
+			t.Errorf("%v accepted", bad)
+		}
+	}
+}
+
+func TestMatchesForTypedFieldsAllocs(t *testing.T) {
+	q, _ := New()
+	_ = q.AddPattern("p", `{"count": [1500], "name": ["pat"]}`)
+	fields := []TypedField{{Path: []byte("count"), Value: int64(1500)}, {Path: []byte("name"), Value: []byte("pat")}}
+	if _, err := q.MatchesForTypedFields(fields); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = q.MatchesForTypedFields(fields)
+	})
+	if allocs > 0 {
+		t.Errorf("%v allocations per match", allocs)
+	}
+}
@@ -15,7 +15,10 @@ import (
 // e.g. a string-valued field with only one string match. In this case, the FA
 // will be null and the value being matched has to exactly equal the singletonMatch
 // field; if so, the singletonTransition is the return value. This is to avoid
-// having a long chain of smallTables each with only one entry.
+// having a long chain of smallTables each with only one entry. As more exact string matches
+// arrive, for as long as there are no other kinds of value, they go into the exacts map, which
+// for enumerated fields such as event names is much smaller than the automaton and is
+// matched with a single lookup.
 // To allow for concurrent access between one thread running AddPattern and many
 // others running MatchesForEvent, the valueMatcher payload is stored in an
 // atomic.Pointer
@@ -28,6 +31,13 @@ type vmFields struct {
 	singletonTransition *fieldMatcher
 	hasNumbers          bool
 	isNondeterministic  bool
+	substrings          *substringMatcher
+	prefixes            *prefixMatcher
+	ranges              *rangeMatcher
+	exacts              map[string]*fieldMatcher // never updated once stored; see addTransition
+	// bytes is the memory the structures above consume, and automatonBytes the automaton's share; see account
+	bytes          int64
+	automatonBytes int64
 }
 
 func (m *valueMatcher) fields() *vmFields {
@@ -41,6 +51,7 @@ func (m *valueMatcher) getFieldsForUpdate() *vmFields {
 }
 
 func (m *valueMatcher) update(state *vmFields) {
+	state.account()
 	m.updateable.Store(state)
 }
 
@@ -52,7 +63,14 @@ func newValueMatcher() *valueMatcher {
 
 func (m *valueMatcher) transitionOn(eventField *Field, bufs *nfaBuffers) []*fieldMatcher {
 	vmFields := m.fields()
-	transitions := bufs.transitionsBuf[:0]
+
+	// results accumulate in the transmap level pushed by tryToMatch, which keeps them safe from
+	// the transitionOn calls made by tryToMatch's recursion while it iterates them
+	tm := bufs.getTransmap()
+	transitions := tm.levels[tm.depth][:0]
+	if bufs.stats != nil {
+		bufs.stats.Values++
+	}
 
 	val := eventField.Val
 	switch {
@@ -64,50 +82,177 @@ func (m *valueMatcher) transitionOn(eventField *Field, bufs *nfaBuffers) []*fiel
 		if bytes.Equal(vmFields.singletonMatch, val) {
 			transitions = append(transitions, vmFields.singletonTransition)
 		}
-		return transitions
 
 	case vmFields.start != nil:
-		// if there is a potential for a numeric match, try making a Q number from the event
-		if vmFields.hasNumbers && eventField.IsNumber {
-			qNum, err := qNumFromBytesBuf(val, &bufs.qNumBuf)
-			if err == nil {
-				if vmFields.isNondeterministic {
-					return traverseNFA(vmFields.start, qNum, transitions, bufs)
-				}
-				return traverseDFA(vmFields.start, qNum, transitions)
-			}
+		transitions = traverseValue(vmFields, eventField, transitions, bufs)
+
+	case vmFields.exacts != nil:
+		if next, ok := vmFields.exacts[string(val)]; ok {
+			transitions = append(transitions, next)
 		}
 
-		// if it doesn't work as a Q number for some reason, go ahead and compare the string values
+	case vmFields.prefixes != nil:
+		transitions = vmFields.prefixes.transitionOn(val, transitions)
+
+	default:
+		// no FA, no singleton, no exacts, no prefixes, nothing to do unless there are substring or range patterns
+	}
+
+	if vmFields.substrings != nil {
+		transitions = vmFields.substrings.transitionOn(val, transitions, bufs)
+	}
+	if vmFields.ranges != nil {
+		transitions = vmFields.ranges.transitionOn(eventField, transitions)
+	}
+	tm.levels[tm.depth] = transitions
+	return transitions
+}
+
+// traverseValue runs the value through the automaton, in Q-number form if appropriate
+func traverseValue(vmFields *vmFields, eventField *Field, transitions []*fieldMatcher, bufs *nfaBuffers) []*fieldMatcher {
+	val := eventField.Val
+	if bufs.stats != nil {
 		if vmFields.isNondeterministic {
-			return traverseNFA(vmFields.start, val, transitions, bufs)
+			bufs.stats.NFATraversals++
+		} else {
+			bufs.stats.DFATraversals++
 		}
-		return traverseDFA(vmFields.start, val, transitions)
+	}
 
-	default:
-		// no FA, no singleton, nothing to do, this probably can't happen because a flattener
-		// shouldn't preserve a field that hasn't appeared in a pattern
-		return transitions
+	// if there is a potential for a numeric match, try making a Q number from the event
+	if vmFields.hasNumbers && eventField.IsNumber {
+		var qNum qNumber
+		var err error
+		if eventField.hasNumber {
+			qNum = numbitsFromFloat64(eventField.number).toQNumberBuf(&bufs.qNumBuf)
+		} else {
+			qNum, err = bufs.qNumFor(val)
+		}
+		if err == nil {
+			if vmFields.isNondeterministic {
+				return traverseNFA(vmFields.start, qNum, transitions, bufs)
+			}
+			return traverseDFA(vmFields.start, qNum, transitions)
+		}
 	}
+
+	// if it doesn't work as a Q number for some reason, go ahead and compare the string values
+	if vmFields.isNondeterministic {
+		return traverseNFA(vmFields.start, val, transitions, bufs)
+	}
+	return traverseDFA(vmFields.start, val, transitions)
 }
 
 func (m *valueMatcher) addTransition(val typedVal, printer printer, bufs *closureBuffers, buildMode MatcherBuildMode) *fieldMatcher {
 	valBytes := []byte(val.val)
 	fields := m.getFieldsForUpdate()
 
-	// special case - virgin state and this is a string match
-	if fields.start == nil && fields.singletonMatch == nil && (val.vType == stringType || val.vType == literalType) {
-		fields.singletonMatch = valBytes
-		fields.singletonTransition = newFieldMatcher()
+	// most shellstyle and wildcard patterns don't go into the automaton at all; see substringMatcher. The rest
+	// are plain strings or prefixes, and are matched as such.
+	if val.vType == shellStyleType || val.vType == wildcardType {
+		if prefix, middles, suffix, ok := shellPieces(val.vType, valBytes); ok {
+			var nextField *fieldMatcher
+			fields.substrings, nextField = fields.substrings.with(prefix, middles, suffix)
+			m.update(fields)
+			return nextField
+		}
+		if plain, ok := plainShellValue(val.vType, valBytes); ok {
+			val = plain
+			valBytes = []byte(val.val)
+		}
+	}
+
+	// nor do contains patterns, which are always the substringMatcher's to match
+	if val.vType == containsType {
+		var middles [][]byte
+		if len(valBytes) > 2 {
+			middles = [][]byte{valBytes[1 : len(valBytes)-1]}
+		}
+		var nextField *fieldMatcher
+		fields.substrings, nextField = fields.substrings.with(nil, middles, nil)
+		m.update(fields)
+		return nextField
+	}
+
+	// nor suffixes compared regardless of case
+	if val.vType == monocaseSuffixType {
+		var nextField *fieldMatcher
+		fields.substrings, nextField = fields.substrings.withFoldedSuffix(valBytes[1 : len(valBytes)-1])
+		m.update(fields)
+		return nextField
+	}
+
+	// nor literals looked for regardless of ASCII case
+	if val.vType == monocaseContainsType {
+		var nextField *fieldMatcher
+		fields.substrings, nextField = fields.substrings.withFoldedContains(valBytes[1 : len(valBytes)-1])
+		m.update(fields)
+		return nextField
+	}
+
+	// numeric, timestamp, and duration ranges, address blocks, Soundex codes, digests, collation keys, and
+	// Lookups never go into the automaton; see rangeMatcher
+	switch val.vType {
+	case numericType, cidrType, timestampType, durationType, soundsLikeType, sha256Type, hmacSHA256Type, collatedType,
+		lookupType:
+		var nextField *fieldMatcher
+		fields.ranges, nextField = fields.ranges.with(val)
+		m.update(fields)
+		return nextField
+	}
+
+	// as long as there's nothing but prefixes, they don't need the automaton either; see prefixMatcher
+	if val.vType == prefixType && fields.start == nil && fields.singletonMatch == nil && fields.exacts == nil {
+		var nextField *fieldMatcher
+		fields.prefixes, nextField = fields.prefixes.with(valBytes[:len(valBytes)-1])
 		m.update(fields)
-		return fields.singletonTransition
+		return nextField
 	}
 
-	// special case: singleton match is here and this value matches it
-	if val.vType == stringType || val.vType == literalType {
+	isExact := val.vType == stringType || val.vType == literalType
+	if isExact && fields.start == nil && fields.prefixes == nil {
+		// special case - virgin state and this is a string match
+		if fields.singletonMatch == nil && fields.exacts == nil {
+			fields.singletonMatch = valBytes
+			fields.singletonTransition = newFieldMatcher()
+			m.update(fields)
+			return fields.singletonTransition
+		}
+
+		// special case: singleton match is here and this value matches it
 		if bytes.Equal(fields.singletonMatch, valBytes) {
 			return fields.singletonTransition
 		}
+
+		// nothing but exact matches so far, so a map does the job. It's copied rather than
+		// updated, so that concurrent lookups are unaffected
+		if next, ok := fields.exacts[val.val]; ok {
+			return next
+		}
+		exacts := make(map[string]*fieldMatcher, len(fields.exacts)+2)
+		for exact, next := range fields.exacts {
+			exacts[exact] = next
+		}
+		if fields.singletonMatch != nil {
+			exacts[string(fields.singletonMatch)] = fields.singletonTransition
+			fields.singletonMatch = nil
+			fields.singletonTransition = nil
+		}
+		nextField := newFieldMatcher()
+		exacts[val.val] = nextField
+		fields.exacts = exacts
+		m.update(fields)
+		return nextField
+	}
+
+	// anything else means building an automaton, which the prefixes or exact matches have to move into
+	if fields.prefixes != nil {
+		fields.start = fields.prefixes.automaton()
+		fields.prefixes = nil
+	}
+	if fields.exacts != nil {
+		fields.start = exactsAutomaton(fields.exacts)
+		fields.exacts = nil
 	}
 
 	// no dodges, we have to build an automaton to match this value
@@ -127,18 +272,35 @@ func (m *valueMatcher) addTransition(val typedVal, printer printer, bufs *closur
 		newFA, nextField = &faState{table: t}, fm
 		fields.hasNumbers = true
 	case anythingButType:
-		newFA, nextField = makeMultiAnythingButFA(val.list)
+		if val.excluded != nil {
+			newFA, nextField = makeComplementFA(val.excluded, printer)
+			for _, excluded := range val.excluded {
+				if excluded.vType == numberType {
+					fields.hasNumbers = true
+				}
+			}
+		} else {
+			newFA, nextField = makeMultiAnythingButFA(val.list)
+		}
 	case shellStyleType:
 		newFA, nextField = makeShellStyleFA(valBytes, printer)
 		fields.isNondeterministic = true
 	case wildcardType:
 		newFA, nextField = makeWildCardFA(valBytes, printer)
 		fields.isNondeterministic = true
+	case wordType:
+		newFA, nextField = makeWordFA(valBytes, printer)
+		fields.isNondeterministic = true
+	case fuzzyType:
+		newFA, nextField = makeFuzzyFA(valBytes, val.distance, printer)
+		fields.isNondeterministic = true
 	case prefixType:
 		t, fm := makePrefixFA(valBytes)
 		newFA, nextField = &faState{table: t}, fm
 	case monocaseType:
 		newFA, nextField = makeMonocaseFA(valBytes, printer)
+	case monocasePrefixType:
+		newFA, nextField = makeMonocasePrefixFA(valBytes, printer)
 	case regexpType:
 		newFA, nextField = makeRegexpNFA(val.parsedRegexp, sharedNullPrinter)
 		if newFA.table.isNondeterministic() {
@@ -196,6 +358,20 @@ func (m *valueMatcher) addTransition(val typedVal, printer printer, bufs *closur
 	return nextField
 }
 
+// exactsAutomaton builds the automaton equivalent to a vmFields.exacts map, using the same fieldMatchers
+func exactsAutomaton(exacts map[string]*fieldMatcher) *faState {
+	var start *faState
+	for exact, next := range exacts {
+		table, _ := makeStringFA([]byte(exact), next, false)
+		if start == nil {
+			start = &faState{table: table}
+		} else {
+			start = mergeStartStates(start, &faState{table: table}, sharedNullPrinter)
+		}
+	}
+	return start
+}
+
 func makePrefixFA(val []byte) (smallTable, *fieldMatcher) {
 	nextField := newFieldMatcher()
 	return makeOnePrefixFAStep(val, 0, nextField), nextField
@@ -51,7 +51,7 @@ func addInvalid(t *testing.T, before []typedVal) {
 
 func TestNoOpTransition(t *testing.T) {
 	vm := newValueMatcher()
-	tr := vm.transitionOn(&Field{Val: []byte("foo")}, &nfaBuffers{})
+	tr := testTransitionOn(vm, []byte("foo"), &nfaBuffers{})
 	if len(tr) != 0 {
 		t.Error("matched on empty valuematcher")
 	}
@@ -67,7 +67,7 @@ func TestAddTransition(t *testing.T) {
 	if t1 == nil {
 		t.Error("nil addTrans")
 	}
-	t1x := m.transitionOn(&Field{Val: []byte("one")}, &nfaBuffers{})
+	t1x := testTransitionOn(m, []byte("one"), &nfaBuffers{})
 	if len(t1x) != 1 || t1x[0] != t1 {
 		t.Error("Retrieve failed")
 	}
@@ -83,11 +83,11 @@ func TestAddTransition(t *testing.T) {
 	}
 	t2 := m.addTransition(v2, &nullPrinter{}, newClosureBuffers(), BuiltForComfort)
 
-	t2x := m.transitionOn(&Field{Val: []byte("two")}, &nfaBuffers{})
+	t2x := testTransitionOn(m, []byte("two"), &nfaBuffers{})
 	if len(t2x) != 1 || t2x[0] != t2 {
 		t.Error("trans failed T2")
 	}
-	t1x = m.transitionOn(&Field{Val: []byte("one")}, &nfaBuffers{})
+	t1x = testTransitionOn(m, []byte("one"), &nfaBuffers{})
 	if len(t1x) != 1 || t1x[0] != t1 {
 		t.Error("Retrieve failed")
 	}
@@ -96,15 +96,15 @@ func TestAddTransition(t *testing.T) {
 		val:   "three",
 	}
 	t3 := m.addTransition(v3, &nullPrinter{}, newClosureBuffers(), BuiltForComfort)
-	t3x := m.transitionOn(&Field{Val: []byte("three")}, &nfaBuffers{})
+	t3x := testTransitionOn(m, []byte("three"), &nfaBuffers{})
 	if len(t3x) != 1 || t3x[0] != t3 {
 		t.Error("Match failed T3")
 	}
-	t2x = m.transitionOn(&Field{Val: []byte("two")}, &nfaBuffers{})
+	t2x = testTransitionOn(m, []byte("two"), &nfaBuffers{})
 	if len(t2x) != 1 || t2x[0] != t2 {
 		t.Error("trans failed T2")
 	}
-	t1x = m.transitionOn(&Field{Val: []byte("one")}, &nfaBuffers{})
+	t1x = testTransitionOn(m, []byte("one"), &nfaBuffers{})
 	if len(t1x) != 1 || t1x[0] != t1 {
 		t.Error("Retrieve failed")
 	}
@@ -406,6 +406,62 @@ func TestExerciseSingletonReplacement(t *testing.T) {
 	}
 }
 
+func TestExactsFastPath(t *testing.T) {
+	cm := newCoreMatcher()
+	names := []string{"OrderPlaced", "OrderShipped", "OrderCancelled", "Order", "Refund"}
+	for _, name := range names {
+		if err := cm.addPattern(name, `{"event": ["`+name+`"]}`, BuiltForComfort); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cm.addPattern("again", `{"event": ["Refund"]}`, BuiltForComfort); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.addPattern("flag", `{"flag": [true, null, "true"]}`, BuiltForComfort); err != nil {
+		t.Fatal(err)
+	}
+	vm := cm.fields().state.fields().transitions["event"]
+	if vm.fields().start != nil || vm.fields().singletonMatch != nil || len(vm.fields().exacts) != len(names) {
+		t.Fatalf("expected %d exacts, got %d", len(names), len(vm.fields().exacts))
+	}
+
+	check := func(cases map[string][]X) {
+		t.Helper()
+		for event, wanted := range cases {
+			matches, err := cm.matchesForJSONEvent([]byte(event))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !containsExactly(matches, wanted) {
+				t.Errorf("%s: wanted %v got %v", event, wanted, matches)
+			}
+		}
+	}
+	cases := map[string][]X{
+		`{"event": "OrderShipped"}`: {"OrderShipped"},
+		`{"event": "Order"}`:        {"Order"},
+		`{"event": "Orde"}`:         {},
+		`{"event": "Refund"}`:       {"Refund", "again"},
+		`{"event": 12}`:             {},
+		`{"flag": true}`:            {"flag"},
+		`{"flag": null}`:            {"flag"},
+		`{"flag": "true"}`:          {"flag"},
+		`{"flag": false}`:           {},
+	}
+	check(cases)
+
+	// anything other than an exact match moves them all into the automaton
+	if err := cm.addPattern("orders", `{"event": [{"prefix": "Order"}]}`, BuiltForComfort); err != nil {
+		t.Fatal(err)
+	}
+	if vm.fields().start == nil || vm.fields().exacts != nil {
+		t.Error("exacts not moved to automaton")
+	}
+	cases[`{"event": "OrderShipped"}`] = []X{"OrderShipped", "orders"}
+	cases[`{"event": "Order"}`] = []X{"Order", "orders"}
+	check(cases)
+}
+
 func TestMergeNfaAndNumeric(t *testing.T) {
 	cm := newCoreMatcher()
 	err := cm.addPattern("x", `{"x": [{"wildcard":"x*y"}]}`, BuiltForComfort)
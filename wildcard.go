@@ -24,6 +24,27 @@ func readWildcardSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error
 	if !ok {
 		return nil, errors.New("value for `wildcard` must be a string")
 	}
+	if err := checkWildcard(wcInput); err != nil {
+		return nil, err
+	}
+	pathVals = append(pathVals, typedVal{vType: wildcardType, val: `"` + wcInput + `"`})
+
+	t, err = pb.jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t.(type) {
+	case json.Delim:
+		// } is all that will be returned
+	default:
+		return nil, errors.New("trailing garbage in wildcard pattern")
+	}
+
+	return pathVals, nil
+}
+
+// checkWildcard reports an error if wcInput isn't a valid wildcard pattern
+func checkWildcard(wcInput string) error {
 	inBytes := []byte(wcInput)
 	state := wcChilling
 	for i, b := range inBytes {
@@ -32,7 +53,7 @@ func readWildcardSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error
 			switch b {
 			case '\\':
 				if i == len(inBytes)-1 {
-					return nil, errors.New("'\\' at end of string not allowed")
+					return errors.New("'\\' at end of string not allowed")
 				}
 				state = wcAfterBS
 			case '*':
@@ -43,12 +64,12 @@ func readWildcardSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error
 			case '\\', '*':
 				state = wcChilling
 			default:
-				return nil, errors.New("`\\` can only be followed by '\\' or '*'")
+				return errors.New("`\\` can only be followed by '\\' or '*'")
 			}
 		case wcAfterGlob:
 			switch b {
 			case '*':
-				return nil, fmt.Errorf("adjacent '*' characters not allowed")
+				return fmt.Errorf("adjacent '*' characters not allowed")
 			case '\\':
 				state = wcAfterBS
 			default:
@@ -56,62 +77,11 @@ func readWildcardSpecial(pb *patternBuild, valsIn []typedVal) ([]typedVal, error
 			}
 		}
 	}
-	pathVals = append(pathVals, typedVal{vType: wildcardType, val: `"` + wcInput + `"`})
-
-	t, err = pb.jd.Token()
-	if err != nil {
-		return nil, err
-	}
-	switch t.(type) {
-	case json.Delim:
-		// } is all that will be returned
-	default:
-		return nil, errors.New("trailing garbage in wildcard pattern")
-	}
-
-	return pathVals, nil
+	return nil
 }
 
 // makeWildcardFA is a replacement for shellstyle patterns, the only difference being that escaping is
 // provided for * and \.
 func makeWildCardFA(val []byte, pp printer) (start *faState, nextField *fieldMatcher) {
-	state := &faState{table: newSmallTable()}
-	start = state
-	pp.labelTable(&start.table, "WILDCARD")
-	nextField = newFieldMatcher()
-
-	// for each byte in the pattern. \-escape processing is simplified because illegal constructs such as \a and \
-	// at the end of the value have been rejected by readWildcardSpecial.
-	valIndex := 0
-	for valIndex < len(val) {
-		ch := val[valIndex]
-		escaped := ch == '\\'
-		if escaped {
-			valIndex++
-			ch = val[valIndex]
-		}
-		if ch == '*' && !escaped {
-			spinner := state
-			spinner.isSpinner = true
-
-			valIndex++
-			spinEscape := &faState{table: newSmallTable()}
-			spinEscape.table.epsilons = []*faState{spinner}
-			spinner.table = makeByteDotFA(spinner, pp)
-			spinner.table.addByteStep(val[valIndex], spinEscape)
-			pp.labelTable(&spinner.table, "*-Spinner")
-			pp.labelTable(&spinEscape.table, fmt.Sprintf("spinEscape on %c at %d", val[valIndex], valIndex))
-			state = spinEscape
-		} else {
-			nextStep := &faState{table: newSmallTable()}
-			pp.labelTable(&nextStep.table, fmt.Sprintf("on %c at %d", val[valIndex], valIndex))
-			state.table.addByteStep(ch, nextStep)
-			state = nextStep
-		}
-		valIndex++
-	}
-	lastStep := &faState{table: newSmallTable(), fieldTransitions: []*fieldMatcher{nextField}}
-	pp.labelTable(&lastStep.table, fmt.Sprintf("last step at %d", valIndex))
-	state.table.addByteStep(valueTerminator, lastStep)
-	return
+	return makeGlobFA(val, true, "WILDCARD", pp)
 }
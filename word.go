@@ -0,0 +1,94 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+)
+
+// readWordSpecial reads a "word" pattern, which matches strings containing its value as a whole word
+func readWordSpecial(pb *patternBuild, valsIn []typedVal) (pathVals []typedVal, err error) {
+	t, err := pb.jd.Token()
+	if err != nil {
+		return
+	}
+	pathVals = valsIn
+
+	word, ok := t.(string)
+	if !ok {
+		err = errors.New("value for 'word' must be a string")
+		return
+	}
+	if word == "" {
+		err = errors.New("value for 'word' must not be empty")
+		return
+	}
+	pathVals = append(pathVals, typedVal{vType: wordType, val: `"` + word + `"`})
+
+	// has to be } or tokenizer will throw error
+	_, err = pb.jd.Token()
+	return
+}
+
+// isWordByte reports whether a byte of a string value is part of a word, for "word" patterns. ASCII letters and
+// digits are, and so are all the bytes of non-ASCII characters, so that words in other alphabets aren't split.
+func isWordByte(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || b >= 0x80
+}
+
+// makeWordFA builds a NFA to match "word" patterns, which match string values where the word occurs with
+// either the start or end of the value, or a byte which isWordByte rejects, on each side, so that "error"
+// matches "disk error: retrying" but not "terrorist". Every time the NFA passes a boundary between words, an
+// epsilon transition tries the word there; after that, the boundary has to be checked at the end of the word,
+// and the rest of the value is skipped.
+func makeWordFA(val []byte, pp printer) (start *faState, nextField *fieldMatcher) {
+	word := val[1 : len(val)-1]
+	nextField = newFieldMatcher()
+
+	// boundary is where a word may begin, inWord where one can't, both step to one or the other on each byte
+	boundary := &faState{}
+	inWord := &faState{}
+	var u unpackedTable
+	for b := 0; b < int(valueTerminator); b++ {
+		if isWordByte(byte(b)) {
+			u[b] = inWord
+		} else {
+			u[b] = boundary
+		}
+	}
+	boundary.table.pack(&u)
+	inWord.table.pack(&u)
+	pp.labelTable(&boundary.table, "word boundary")
+	pp.labelTable(&inWord.table, "in word")
+
+	wordStart := &faState{table: newSmallTable()}
+	boundary.table.epsilons = []*faState{wordStart}
+	state := wordStart
+	for i, ch := range word {
+		nextStep := &faState{table: newSmallTable()}
+		pp.labelTable(&nextStep.table, fmt.Sprintf("on %c at %d", ch, i))
+		state.table.addByteStep(ch, nextStep)
+		state = nextStep
+	}
+
+	// after the word and a boundary, anything may follow. There's always one, the closing quote.
+	lastStep := &faState{table: newSmallTable(), fieldTransitions: []*fieldMatcher{nextField}}
+	pp.labelTable(&lastStep.table, "last step")
+	rest := &faState{table: smallTable{
+		ceilings: []byte{valueTerminator, valueTerminator + 1, byte(byteCeiling)},
+		steps:    []*faState{nil, lastStep, nil},
+	}}
+	rest.table.steps[0] = rest
+	pp.labelTable(&rest.table, "after word")
+	var after unpackedTable
+	for b := 0; b < 0x80; b++ {
+		if !isWordByte(byte(b)) {
+			after[b] = rest
+		}
+	}
+	state.table.pack(&after)
+
+	start = &faState{table: newSmallTable()}
+	pp.labelTable(&start.table, "WORD")
+	start.table.addByteStep('"', boundary)
+	return start, nextField
+}
@@ -0,0 +1,58 @@
+package quamina
+
+import (
+	"testing"
+)
+
+func TestWordPattern(t *testing.T) {
+	patterns := map[X]string{
+		"error":   `{"msg": [{"word": "error"}]}`,
+		"two":     `{"msg": [{"word": "time out"}]}`,
+		"mixed":   `{"msg": [{"word": "disk"}, "ok", {"prefix": "WARN"}, {"wildcard": "*fatal*"}]}`,
+		"unicode": `{"msg": [{"word": "naïve"}]}`,
+	}
+	events := map[string][]X{
+		`{"msg": "error"}`:                 {"error"},
+		`{"msg": "disk error: retrying"}`:  {"error", "mixed"},
+		`{"msg": "terrorist"}`:             {},
+		`{"msg": "errors"}`:                {},
+		`{"msg": "error_code"}`:            {"error"},
+		`{"msg": "an erro error"}`:         {"error"},
+		`{"msg": "eerror error2 (error)"}`: {"error"},
+		`{"msg": "a time out"}`:            {"two"},
+		`{"msg": "a time outage"}`:         {},
+		`{"msg": "diskette"}`:              {},
+		`{"msg": "ok"}`:                    {"mixed"},
+		`{"msg": "WARNING"}`:               {"mixed"},
+		`{"msg": "nonfatal"}`:              {"mixed"},
+		`{"msg": "so naïve."}`:             {"unicode"},
+		`{"msg": "naïveté"}`:               {},
+		`{"msg": "héerror"}`:               {},
+		`{"msg": 12}`:                      {},
+	}
+	for _, mode := range []MatcherBuildMode{BuiltForComfort, BuiltForSpeed} {
+		cm := newCoreMatcher()
+		for x, p := range patterns {
+			if err := cm.addPattern(x, p, mode); err != nil {
+				t.Fatalf("%s: %v", p, err)
+			}
+		}
+		for event, want := range events {
+			matches, err := cm.matchesForJSONEvent([]byte(event))
+			if err != nil || !containsExactly(matches, want) {
+				t.Errorf("mode %d, %s: %v, %v", mode, event, matches, err)
+			}
+		}
+	}
+
+	for _, bad := range []string{
+		`{"x": [{"word": ""}]}`,
+		`{"x": [{"word": 3}]}`,
+		`{"x": [{"word": "a", "b": 1}]}`,
+	} {
+		q, _ := New()
+		if err := q.AddPattern("x", bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyFields is returned, wrapped, by MatchesForEvent and the other matching APIs when an Event has more
+// fields than the limit set WithMaxFields allows.
+var ErrTooManyFields = errors.New("too many fields")
+
+// ErrWorkLimitExceeded is returned, wrapped, by MatchesForEvent and the other matching APIs when matching an
+// Event takes more work than the limit set WithWorkLimit allows.
+var ErrWorkLimitExceeded = errors.New("work limit exceeded")
+
+// WithWorkLimit limits the work matching may do for one Event, counted as the number of automaton states it
+// visits, including those in the epsilon closures of the states it steps to. Matching against Patterns with
+// many wildcards or regexps keeps many states active at once, and an Event crafted to keep them all active
+// for a long value can use a great deal of CPU; with a limit, matching it stops part way and fails with an
+// error wrapping ErrWorkLimitExceeded, reporting no matches. A limit of several times the number of states
+// GetMatcherStats reports, times the length of the longest values expected, leaves ordinary Events
+// unaffected. The limit applies to instances created with Copy. With WithParallelFieldMatching, each
+// goroutine stops at the limit, and the Event fails if their total exceeds it. This option call may not be
+// provided more than once.
+func WithWorkLimit(states int) Option {
+	return func(q *Quamina) error {
+		if q.workLimit != 0 {
+			return errors.New("work limit specified more than once")
+		}
+		if states <= 0 {
+			return errors.New("work limit must be positive")
+		}
+		q.workLimit = states
+		return nil
+	}
+}
+
+// WithMaxFields limits the number of fields an Event may have, once flattened, to n. Fields are matched in
+// combination, so an Event with a huge number of them, such as one with objects fanning out many levels deep,
+// can take far longer to match than any ordinary Event; with a limit, it fails with an error wrapping
+// ErrTooManyFields before any matching is done. Only the fields that Patterns use are counted, since the
+// Flattener skips the others. The limit applies to all the matching APIs, and to instances created with Copy.
+// This option call may not be provided more than once.
+func WithMaxFields(n int) Option {
+	return func(q *Quamina) error {
+		if q.maxFields != 0 {
+			return errors.New("max fields specified more than once")
+		}
+		if n <= 0 {
+			return errors.New("max fields must be positive")
+		}
+		q.maxFields = n
+		return nil
+	}
+}
+
+// resetWork starts counting the work done matching an Event afresh
+func (nb *nfaBuffers) resetWork() {
+	nb.work = 0
+	nb.workExceeded = false
+	nb.tooManyFields = 0
+}
+
+// addWork counts states visited, and reports whether matching may go on
+func (nb *nfaBuffers) addWork(states int) bool {
+	nb.work += states
+	if nb.workLimit > 0 && nb.work > nb.workLimit {
+		nb.workExceeded = true
+	}
+	return !nb.workExceeded
+}
+
+// limitError is the error for an Event whose matching was stopped by the work limit or the limit on fields,
+// if it was
+func (nb *nfaBuffers) limitError() error {
+	switch {
+	case nb.tooManyFields > 0:
+		return fmt.Errorf("%w: %d fields, limit %d", ErrTooManyFields, nb.tooManyFields, nb.maxFields)
+	case nb.workExceeded:
+		return fmt.Errorf("%w: visited %d automaton states, limit %d", ErrWorkLimitExceeded, nb.work, nb.workLimit)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package quamina
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestWorkLimit(t *testing.T) {
+	long := []byte(fmt.Sprintf(`{"x": "%s"}`, strings.Repeat("ab", 5000)))
+	short := []byte(`{"x": "aab", "y": "aab"}`)
+	for _, parallel := range []bool{false, true} {
+		opts := []Option{WithWorkLimit(2000)}
+		if parallel {
+			opts = append(opts, WithParallelFieldMatching(2, 1))
+		}
+		q, _ := New(opts...)
+		for i, re := range []string{"(a|b)*a(a|b)", "(a|b)*b", "a+b+", "(ab)*"} {
+			_ = q.AddPattern(i, fmt.Sprintf(`{"x": [{"regexp": "%s"}]}`, re))
+			_ = q.AddPattern(i, fmt.Sprintf(`{"y": [{"regexp": "%s"}]}`, re))
+		}
+
+		matches, err := q.MatchesForEvent(short)
+		if err != nil || !containsExactly(matches, []X{0, 1, 2}) {
+			t.Errorf("parallel=%v: short Event %v, %v", parallel, matches, err)
+		}
+		for _, instance := range []*Quamina{q, q.Copy()} {
+			matches, err = instance.MatchesForEvent(long)
+			if !errors.Is(err, ErrWorkLimitExceeded) || len(matches) != 0 {
+				t.Errorf("parallel=%v: long Event %v, %v", parallel, matches, err)
+			}
+			if _, err = instance.CountMatchesForEvent(long); !errors.Is(err, ErrWorkLimitExceeded) {
+				t.Errorf("parallel=%v: count %v", parallel, err)
+			}
+		}
+		// the limit is for each Event
+		if matches, err = q.MatchesForEvent(short); err != nil || len(matches) != 3 {
+			t.Errorf("parallel=%v: after long Event %v, %v", parallel, matches, err)
+		}
+	}
+
+	pruned, _ := New(WithPatternDeletion(true), WithWorkLimit(100))
+	_ = pruned.AddPattern("p", `{"x": [{"regexp": "(a|b)*b"}]}`)
+	if _, err := pruned.MatchesForEvent(long); !errors.Is(err, ErrWorkLimitExceeded) {
+		t.Errorf("pruner: %v", err)
+	}
+
+	unlimited, _ := New()
+	_ = unlimited.AddPattern("p", `{"x": [{"regexp": "(a|b)*b"}]}`)
+	if matches, err := unlimited.MatchesForEvent(long); err != nil || len(matches) != 1 {
+		t.Errorf("unlimited: %v, %v", matches, err)
+	}
+
+	if _, err := New(WithWorkLimit(0)); err == nil {
+		t.Error("zero limit accepted")
+	}
+	if _, err := New(WithWorkLimit(1), WithWorkLimit(2)); err == nil {
+		t.Error("limit specified twice")
+	}
+}
+
+func TestMaxFields(t *testing.T) {
+	for _, deletion := range []bool{false, true} {
+		q, _ := New(WithMaxFields(3), WithPatternDeletion(deletion))
+		_ = q.AddPattern("p", `{"a": {"b": [1]}}`)
+		ok := []byte(`{"a": [{"b": 1}, {"b": 2}, {"b": 3}], "c": [1, 2, 3, 4, 5]}`)
+		tooMany := []byte(`{"a": [{"b": 1}, {"b": 2}, {"b": 3}, {"b": 4}]}`)
+
+		for _, instance := range []*Quamina{q, q.Copy()} {
+			// only the fields Patterns use count
+			if matches, err := instance.MatchesForEvent(ok); err != nil || len(matches) != 1 {
+				t.Errorf("deletion=%v: %v, %v", deletion, matches, err)
+			}
+			if matches, err := instance.MatchesForEvent(tooMany); !errors.Is(err, ErrTooManyFields) || len(matches) != 0 {
+				t.Errorf("deletion=%v: too many %v, %v", deletion, matches, err)
+			}
+			if _, err := instance.CountMatchesForEvent(tooMany); !errors.Is(err, ErrTooManyFields) {
+				t.Errorf("deletion=%v: count %v", deletion, err)
+			}
+			if _, err := instance.MatchesForStruct(map[string]any{"a": map[string][]int{"b": {1, 2, 3, 4}}}); !errors.Is(err, ErrTooManyFields) {
+				t.Errorf("deletion=%v: struct %v", deletion, err)
+			}
+			_, err := instance.MatchesForEvents([][]byte{ok, tooMany})
+			var eventErr *EventError
+			if !errors.As(err, &eventErr) || eventErr.Index != 1 || !errors.Is(err, ErrTooManyFields) {
+				t.Errorf("deletion=%v: events %v", deletion, err)
+			}
+		}
+	}
+
+	if _, err := New(WithMaxFields(0)); err == nil {
+		t.Error("zero limit accepted")
+	}
+	if _, err := New(WithMaxFields(1), WithMaxFields(2)); err == nil {
+		t.Error("limit specified twice")
+	}
+}